@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/ardanlabs/conf"
@@ -19,11 +20,13 @@ import (
 	"github.com/pkg/errors"
 	"gitlab.com/vjsideprojects/relay/internal/account"
 	"gitlab.com/vjsideprojects/relay/internal/bootstrap"
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
 	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/schema"
+	"gitlab.com/vjsideprojects/relay/internal/team"
 	"gitlab.com/vjsideprojects/relay/internal/token"
 	"gitlab.com/vjsideprojects/relay/internal/user"
 )
@@ -156,6 +159,12 @@ func run() error {
 		err = bootstrap.BootEM(schema.SeedAccountID, schema.SeedUserID1, db, sdb, cfg.Auth.GoogleKeyFile)
 	case "incadd":
 		err = bootstrap.BootIncident(schema.SeedAccountID, schema.SeedUserID1, db, sdb, cfg.Auth.GoogleKeyFile)
+	case "pkgexport":
+		err = pkgexport(db, schema.SeedAccountID, cfg.Args.Num(1), cfg.Args.Num(2))
+	case "pkgimport":
+		err = pkgimport(db, sdb, schema.SeedAccountID, schema.SeedUserID1, cfg.Auth.GoogleKeyFile, cfg.Args.Num(1))
+	case "pkggen":
+		err = pkggen(db, sdb, cfg.Auth.GoogleKeyFile, cfg.Args.Num(1))
 	case "useradd":
 		err = useradd(db, schema.SeedAccountID, cfg.Args.Num(1), cfg.Args.Num(2))
 	case "keygen":
@@ -270,6 +279,107 @@ func useradd(db *sqlx.DB, accountID, email, password string) error {
 	return nil
 }
 
+// pkgexport writes the team of the account as the package file.
+func pkgexport(db *sqlx.DB, accountID, teamID, path string) error {
+	if teamID == "" || path == "" {
+		return errors.New("pkgexport command must be called with two additional arguments for team_id and file path")
+	}
+
+	p, err := pack.Export(context.Background(), db, accountID, teamID, "1.0.0", true)
+	if err != nil {
+		return err
+	}
+	return writePackage(path, p)
+}
+
+// pkgimport installs the package file as a new team of the account.
+func pkgimport(db *sqlx.DB, sdb *database.SecDB, accountID, userID, firebaseSDKPath, path string) error {
+	if path == "" {
+		return errors.New("pkgimport command must be called with the file path")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "opening package file")
+	}
+	defer file.Close()
+
+	p, err := pack.Decode(file)
+	if err != nil {
+		return err
+	}
+
+	inst, err := pack.Import(context.Background(), db, sdb, accountID, userID, p, firebaseSDKPath, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Package %s %s installed on the team %s\n", inst.Name, inst.Version, inst.TeamID)
+	return nil
+}
+
+// pkggen regenerates the packages of the go bootstraps. It boots every template on the seeded
+// account and exports the team into the dir. Run it on a freshly seeded database.
+func pkggen(db *sqlx.DB, sdb *database.SecDB, firebaseSDKPath, dir string) error {
+	if dir == "" {
+		dir = "internal/bootstrap/packages"
+	}
+
+	boots := []struct {
+		lookUp string
+		boot   func(accountID, userID string, db *sqlx.DB, sdb *database.SecDB, firebaseSDKPath string) error
+	}{
+		{team.PredefinedTeamCRP, bootstrap.BootCRM},
+		{team.PredefinedTeamCSP, bootstrap.BootCSM},
+		{team.PredefinedTeamEMP, bootstrap.BootEM},
+		{team.PredefinedTeamCSup, bootstrap.BootSupport},
+		{team.PredefinedTeamINC, bootstrap.BootIncident},
+	}
+
+	ctx := context.Background()
+	for _, b := range boots {
+		if err := b.boot(schema.SeedAccountID, schema.SeedUserID1, db, sdb, firebaseSDKPath); err != nil {
+			return errors.Wrapf(err, "booting %s", b.lookUp)
+		}
+
+		teams, err := team.List(ctx, schema.SeedAccountID, db)
+		if err != nil {
+			return err
+		}
+		for _, t := range teams {
+			if t.LookUp != b.lookUp {
+				continue
+			}
+			p, err := pack.Export(ctx, db, schema.SeedAccountID, t.ID, "1.0.0", true)
+			if err != nil {
+				return errors.Wrapf(err, "exporting %s", b.lookUp)
+			}
+			if err := writePackage(filepath.Join(dir, b.lookUp+".yaml"), p); err != nil {
+				return err
+			}
+			fmt.Printf("Package %s generated\n", b.lookUp)
+		}
+	}
+	return nil
+}
+
+// writePackage writes the package in YAML when the path ends with .yaml/.yml and in JSON otherwise.
+func writePackage(path string, p pack.Package) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "creating package file")
+	}
+	defer file.Close()
+
+	encode := pack.Encode
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		encode = pack.EncodeYAML
+	}
+	if err := encode(file, p); err != nil {
+		return errors.Wrap(err, "encoding package file")
+	}
+	return file.Close()
+}
+
 // keygen creates an x509 private key for signing auth tokens.
 func keygen(path string) error {
 	if path == "" {
@@ -72,6 +72,25 @@ func (a *Account) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.R
 	return web.Respond(ctx, w, createViewModelAccount(account), http.StatusOK)
 }
 
+// UpdateCalendar sets the timezone, the week start and the fiscal year start the charts of the account follow.
+func (a *Account) UpdateCalendar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Account.UpdateCalendar")
+	defer span.End()
+
+	var uc account.Calendar
+	if err := web.Decode(r, &uc); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	if err := account.UpdateCalendar(ctx, a.db, params["account_id"], uc); err != nil {
+		if errors.Cause(err) == account.ErrInvalidTimeZone {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
 func (a *Account) GenerateToken(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Account.GenerateToken")
 	defer span.End()
@@ -105,7 +124,29 @@ func (a *Account) GenerateToken(ctx context.Context, w http.ResponseWriter, r *h
 		return web.NewRequestError(err, http.StatusForbidden)
 	}
 
-	tkn, err := generateUserJWT(ctx, account.ID, userEmail, time.Now(), a.authenticator, a.db)
+	// the email as the subject means the member signed in without the identity provider of the account
+	if _, err := mail.ParseAddress(emailOrUserID); err == nil {
+		if err := ssoEnforced(ctx, account.ID, a.db); err != nil {
+			return err
+		}
+	}
+
+	// the token is exchanged without the two-factor only when the token of the caller is issued after it
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+	if !claims.MFA {
+		challenge, err := mfaChallenge(ctx, a.db, a.sdb, userEmail)
+		if err != nil {
+			return err
+		}
+		if challenge != nil {
+			return web.Respond(ctx, w, challenge, http.StatusOK)
+		}
+	}
+
+	tkn, err := generateUserJWT(ctx, account.ID, userEmail, claims.MFA, time.Now(), a.authenticator, a.db)
 	if err != nil {
 		return web.NewRequestError(err, http.StatusUnauthorized)
 	}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/alert"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+// Alert represents the alert ingestion from the monitoring tools and the grouping rules of the alerts.
+type Alert struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// Ingest receives the alert webhook of the source (alertmanager, grafana, datadog, generic).
+// The entity where the alerts get saved is passed in the query param `block`.
+func (a *Alert) Ingest(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Alert.Ingest")
+	defer span.End()
+
+	claims, err := a.authenticator.ParseClaims(params["account_key"])
+	if err != nil {
+		return web.NewRequestError(err, http.StatusUnauthorized)
+	}
+	accountID := claims.Subject
+
+	entityID := r.URL.Query().Get("block")
+	if entityID == "" {
+		return web.NewRequestError(errors.New("paramater block is missing"), http.StatusBadRequest)
+	}
+
+	body, err := getBody(r.Body)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the alert body")
+	}
+
+	newAlerts, err := alert.Parse(params["source"], body)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	alerts := make([]alert.Alert, 0, len(newAlerts))
+	for _, na := range newAlerts {
+		al, err := alert.Ingest(ctx, a.db, a.sdb, accountID, entityID, na, a.authenticator.FireBaseAdminSDK, time.Now())
+		if err != nil {
+			if err == alert.ErrNotFound {
+				continue
+			}
+			return errors.Wrapf(err, "ingesting the alert %s", na.Fingerprint)
+		}
+		alerts = append(alerts, al)
+	}
+
+	log.Printf("internal.handlers.alert : source: %s : received %d alerts : saved %d alerts\n", params["source"], len(newAlerts), len(alerts))
+	return web.Respond(ctx, w, alerts, http.StatusAccepted)
+}
+
+// List returns the recent alerts of the entity.
+func (a *Alert) List(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Alert.List")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, a.db)
+	alerts, err := alert.List(ctx, a.db, accountID, entityID, 100)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, alerts, http.StatusOK)
+}
+
+// ListRules returns the grouping rules of the alert entity.
+func (a *Alert) ListRules(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Alert.ListRules")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, a.db)
+	rules, err := alert.ListRules(ctx, a.db, accountID, entityID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, rules, http.StatusOK)
+}
+
+// CreateRule adds the grouping rule for the alert entity.
+func (a *Alert) CreateRule(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Alert.CreateRule")
+	defer span.End()
+
+	var nr alert.NewRule
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	nr.AccountID, nr.EntityID, _ = takeAEI(ctx, params, a.db)
+	rule, err := alert.CreateRule(ctx, a.db, nr, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "Alert rule: %+v", &nr)
+	}
+	return web.Respond(ctx, w, rule, http.StatusCreated)
+}
+
+// DeleteRule removes the grouping rule.
+func (a *Alert) DeleteRule(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Alert.DeleteRule")
+	defer span.End()
+
+	if err := alert.DeleteRule(ctx, a.db, params["account_id"], params["rule_id"]); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
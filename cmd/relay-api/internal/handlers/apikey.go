@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/token"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// defaultOverlap is how long the rotated key keeps working when the overlap is not specified.
+const defaultOverlap = 24 * time.Hour
+
+// APIKey represents the scoped API keys of the account. The keys are managed only with the
+// token of a user, the requests authenticated with an API key are rejected by mid.Authenticate.
+type APIKey struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// ViewModelAPIKey is the API key without its hash. The key is set only when it is created or rotated.
+type ViewModelAPIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	State      int        `json:"state"`
+	CreatedBy  *string    `json:"created_by"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Key        string     `json:"key,omitempty"`
+}
+
+// List returns the API keys of the account.
+func (ak *APIKey) List(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.APIKey.List")
+	defer span.End()
+
+	keys, err := token.ListAPIKeys(ctx, ak.db, params["account_id"])
+	if err != nil {
+		return err
+	}
+
+	vmKeys := make([]ViewModelAPIKey, 0, len(keys))
+	for _, k := range keys {
+		vmKeys = append(vmKeys, createViewModelAPIKey(k, ""))
+	}
+	return web.Respond(ctx, w, vmKeys, http.StatusOK)
+}
+
+// Create creates the API key. The scopes qualified with the entity name are saved with the entity id.
+func (ak *APIKey) Create(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.APIKey.Create")
+	defer span.End()
+
+	accountID := params["account_id"]
+	var nk token.NewAPIKey
+	if err := web.Decode(r, &nk); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	scopes, err := ak.resolveScopes(ctx, accountID, nk.Scopes)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	nk.Scopes = scopes
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, t, err := token.CreateAPIKey(ctx, ak.db, accountID, currentUserID, nk, time.Now())
+	if err != nil {
+		if errors.Cause(err) == token.ErrInvalidScope {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, createViewModelAPIKey(t, key), http.StatusCreated)
+}
+
+// Rotate replaces the API key with a new one. The old key keeps working for the overlap period
+// passed in hours with the query param `overlap`.
+func (ak *APIKey) Rotate(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.APIKey.Rotate")
+	defer span.End()
+
+	overlap := defaultOverlap
+	if o := r.URL.Query().Get("overlap"); o != "" {
+		hours, err := time.ParseDuration(o + "h")
+		if err != nil || hours < 0 {
+			return web.NewRequestError(errors.New("overlap should be the number of hours"), http.StatusBadRequest)
+		}
+		overlap = hours
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, t, err := token.RotateAPIKey(ctx, ak.db, params["account_id"], params["key_id"], currentUserID, overlap, time.Now())
+	switch err {
+	case nil:
+	case token.ErrTokenNotFound:
+		return web.NewRequestError(err, http.StatusNotFound)
+	case token.ErrInvalidKey:
+		return web.NewRequestError(errors.New("only the active keys can be rotated"), http.StatusConflict)
+	default:
+		return err
+	}
+	return web.Respond(ctx, w, createViewModelAPIKey(t, key), http.StatusCreated)
+}
+
+// Revoke stops the API key from working immediately.
+func (ak *APIKey) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.APIKey.Revoke")
+	defer span.End()
+
+	if err := token.RevokeAPIKey(ctx, ak.db, params["account_id"], params["key_id"]); err != nil {
+		if err == token.ErrTokenNotFound {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, "", http.StatusNoContent)
+}
+
+// resolveScopes replaces the entity name in the qualifier of the scope with the entity id,
+// e.g. "items:write:deals" becomes "items:write:<id of deals>".
+func (ak *APIKey) resolveScopes(ctx context.Context, accountID string, scopes []string) ([]string, error) {
+	resolved := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		if !auth.ValidScope(s) {
+			return nil, errors.Wrapf(token.ErrInvalidScope, "scope %q", s)
+		}
+		parts := strings.Split(s, ":")
+		if len(parts) == 3 {
+			if _, err := uuid.Parse(parts[2]); err != nil {
+				e, err := entity.RetrieveByName(ctx, accountID, parts[2], ak.db)
+				if err != nil {
+					return nil, errors.Wrapf(token.ErrInvalidScope, "unknown entity %q in scope %q", parts[2], s)
+				}
+				parts[2] = e.ID
+			}
+		}
+		resolved = append(resolved, strings.Join(parts, ":"))
+	}
+	return resolved, nil
+}
+
+func createViewModelAPIKey(t token.Token, key string) ViewModelAPIKey {
+	return ViewModelAPIKey{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scope,
+		State:      t.State,
+		CreatedBy:  t.CreatedBy,
+		ExpiresAt:  t.Expiry,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+		Key:        key,
+	}
+}
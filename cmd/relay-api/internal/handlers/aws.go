@@ -131,13 +131,7 @@ func (ass *AwsSnsSubscription) ManageIncidents(ctx context.Context, w http.Respo
 }
 
 func saveAlertMessage(ctx context.Context, accountID string, msg aws.Message, db *sqlx.DB, sdb *database.SecDB, fbSDKPath string) error {
-	descJsonStr := msg.AlarmDescription
-	fieldsMap := map[string]interface{}{}
-	err := json.Unmarshal([]byte(descJsonStr), &fieldsMap)
-	if err != nil {
-		return err
-	}
-	return aws.SaveAlert(ctx, accountID, fieldsMap, db, sdb, fbSDKPath)
+	return aws.SaveAlarm(ctx, accountID, msg, db, sdb, fbSDKPath)
 }
 
 func getMailBody(body []byte) (email.MailBody, error) {
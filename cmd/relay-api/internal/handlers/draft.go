@@ -109,7 +109,7 @@ func (a *Account) Launch(ctx context.Context, w http.ResponseWriter, r *http.Req
 		return web.NewRequestError(errors.Wrap(err, "System JWT token save failed"), http.StatusInternalServerError)
 	}
 
-	userToken, err := generateUserJWT(ctx, acc.ID, tokenEmail, time.Now(), a.authenticator, a.db)
+	userToken, err := generateUserJWT(ctx, acc.ID, tokenEmail, false, time.Now(), a.authenticator, a.db)
 	if err != nil {
 		account.Delete(ctx, a.db, acc.ID)
 		return web.NewRequestError(errors.Wrap(err, "User JWT creation failed"), http.StatusInternalServerError)
@@ -129,6 +129,15 @@ func (a *Account) Launch(ctx context.Context, w http.ResponseWriter, r *http.Req
 	//FIX THIS
 	go job.NewJob(a.db, a.sdb, a.authenticator.FireBaseAdminSDK).Stream(stream.NewAccountLaunchMessage(ctx, a.db, accountID, usr.ID))
 
+	// the member with the two-factor enabled in the other accounts gets the new account after the second step
+	challenge, err := mfaChallenge(ctx, a.db, a.sdb, tokenEmail)
+	if err != nil {
+		return err
+	}
+	if challenge != nil {
+		return web.Respond(ctx, w, challenge, http.StatusCreated)
+	}
+
 	return web.Respond(ctx, w, userToken, http.StatusCreated)
 }
 
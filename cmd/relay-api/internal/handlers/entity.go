@@ -18,12 +18,15 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/entity"
 	"gitlab.com/vjsideprojects/relay/internal/item"
 	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/migration"
 	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
 	"gitlab.com/vjsideprojects/relay/internal/reference"
 	"gitlab.com/vjsideprojects/relay/internal/team"
+	"gitlab.com/vjsideprojects/relay/internal/timeline"
 	"gitlab.com/vjsideprojects/relay/internal/user"
 	"go.opencensus.io/trace"
 )
@@ -172,6 +175,22 @@ func (e *Entity) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Re
 	return web.Respond(ctx, w, createViewModelEntityWithChoices(enty, fields), http.StatusOK)
 }
 
+// Schema returns the JSON schema the item values of the entity are validated against.
+func (e *Entity) Schema(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Entity.Schema")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, e.db)
+	enty, err := entity.Retrieve(ctx, accountID, entityID, e.db, e.sdb)
+	if err != nil {
+		return err
+	}
+
+	schema := enty.JSONSchema()
+	schema.ID = r.URL.Path
+	return web.Respond(ctx, w, schema, http.StatusOK)
+}
+
 // Create inserts a new team into the system.
 func (e *Entity) Create(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Entity.Create")
@@ -209,7 +228,8 @@ func (e *Entity) Create(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	return web.Respond(ctx, w, createViewModelEntity(enty), http.StatusCreated)
 }
 
-// Update updates the entity
+// Update updates the entity. When the existing fields are removed or retyped, the values of the items are
+// migrated in the background. Removing a field used in the workflows is blocked unless the query param force is true.
 func (e *Entity) Update(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Entity.Update")
 	defer span.End()
@@ -220,6 +240,19 @@ func (e *Entity) Update(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return errors.Wrap(err, "")
 	}
 
+	current, err := entity.Retrieve(ctx, accountID, entityID, e.db, e.sdb)
+	if err != nil {
+		return err
+	}
+	changes := migration.Changes(current.EasyFields(), ve.Fields)
+	impact, err := migration.Analyze(ctx, e.db, accountID, entityID, changes)
+	if err != nil {
+		return err
+	}
+	if impact.Blocking && r.URL.Query().Get("force") != "true" {
+		return web.NewRequestError(migration.ErrFieldInUse, http.StatusConflict)
+	}
+
 	input, err := json.Marshal(ve.Fields)
 	if err != nil {
 		return errors.Wrap(err, "encode fields to input")
@@ -230,6 +263,18 @@ func (e *Entity) Update(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return errors.Wrapf(err, "Entity: %+v", &ve)
 	}
 
+	if impact.Items > 0 {
+		currentUserID, err := user.RetrieveCurrentUserID(ctx)
+		if err != nil {
+			return err
+		}
+		mig, err := migration.Create(ctx, e.db, accountID, entityID, currentUserID, changes, impact.Items, time.Now())
+		if err != nil {
+			return err
+		}
+		go job.NewJob(e.db, e.sdb, e.authenticator.FireBaseAdminSDK).Stream(stream.NewFieldMigrationMessage(ctx, e.db, accountID, currentUserID, entityID, mig.ID))
+	}
+
 	return web.Respond(ctx, w, ve, http.StatusOK)
 }
 
@@ -347,6 +392,12 @@ func (e *Entity) UpdateLS(ctx context.Context, w http.ResponseWriter, r *http.Re
 			return err
 		}
 		return web.Respond(ctx, w, createViewModelEntity(enty), http.StatusOK)
+	} else if (ls == entity.MetaRenderCalendar || ls == entity.MetaRenderTimeline) && timeline.EntityKeys(enty).Start != "" {
+		err := enty.UpdateMeta(ctx, e.db)
+		if err != nil {
+			return err
+		}
+		return web.Respond(ctx, w, createViewModelEntity(enty), http.StatusOK)
 	} else {
 		return web.Respond(ctx, w, "failure", http.StatusBadRequest)
 	}
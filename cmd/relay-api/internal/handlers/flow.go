@@ -79,7 +79,18 @@ func (f *Flow) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		viewModelNodes[i] = createViewModelNodeActor(node)
 	}
 
-	return web.Respond(ctx, w, createViewModelFlow(fl, viewModelNodes), http.StatusOK)
+	vmf := createViewModelFlow(fl, viewModelNodes)
+	if fl.Type == flow.FlowTypeScheduled {
+		sch, err := flow.RetrieveSchedule(ctx, f.db, fl.ID)
+		if err != nil && err != flow.ErrNotFound {
+			return err
+		}
+		if err == nil {
+			vmf.Schedule = &sch
+		}
+	}
+
+	return web.Respond(ctx, w, vmf, http.StatusOK)
 }
 
 //remove this method. useful only for verification of flow path
@@ -191,13 +202,27 @@ func (f *Flow) Create(ctx context.Context, w http.ResponseWriter, r *http.Reques
 	nf.AccountID = params["account_id"]
 	nf.EntityID = params["entity_id"]
 	nf.Expression, nf.Tokens = makeExpression(nf.Queries)
+	if nf.Type == flow.FlowTypeScheduled {
+		if _, err := flow.NextRun(nf.Cron, nf.Timezone, time.Now()); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+	}
 
-	flow, err := flow.Create(ctx, f.db, nf, time.Now())
+	fl, err := flow.Create(ctx, f.db, nf, time.Now())
 	if err != nil {
-		return errors.Wrapf(err, "error creating flow: %+v", &flow)
+		return errors.Wrapf(err, "error creating flow: %+v", &fl)
 	}
 
-	return web.Respond(ctx, w, createViewModelFlow(flow, []node.ViewModelNode{}), http.StatusCreated)
+	vmf := createViewModelFlow(fl, []node.ViewModelNode{})
+	if fl.Type == flow.FlowTypeScheduled {
+		sch, err := flow.SaveSchedule(ctx, f.db, fl.AccountID, fl.ID, nf.Cron, nf.Timezone, time.Now())
+		if err != nil {
+			return err
+		}
+		vmf.Schedule = &sch
+	}
+
+	return web.Respond(ctx, w, vmf, http.StatusCreated)
 }
 
 func (f *Flow) Update(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
@@ -211,12 +236,26 @@ func (f *Flow) Update(ctx context.Context, w http.ResponseWriter, r *http.Reques
 	uf.Expression, uf.Tokens = makeExpression(uf.Queries)
 	uf.AccountID = params["account_id"]
 	uf.EntityID = params["entity_id"]
+	if uf.Type == flow.FlowTypeScheduled {
+		if _, err := flow.NextRun(uf.Cron, uf.Timezone, time.Now()); err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+	}
 
 	uuf, err := flow.Update(ctx, f.db, uf, time.Now())
 	if err != nil {
 		return errors.Wrapf(err, "Error updating flow")
 	}
 
+	var sch *flow.Schedule
+	if uuf.Type == flow.FlowTypeScheduled {
+		s, err := flow.SaveSchedule(ctx, f.db, uuf.AccountID, uuf.ID, uf.Cron, uf.Timezone, time.Now())
+		if err != nil {
+			return err
+		}
+		sch = &s
+	}
+
 	nodes, err := node.NodeActorsList(ctx, uf.AccountID, uuf.ID, f.db)
 	if err != nil {
 		return err
@@ -227,7 +266,9 @@ func (f *Flow) Update(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		viewModelNodes[i] = createViewModelNodeActor(node)
 	}
 
-	return web.Respond(ctx, w, createViewModelFlow(uuf, viewModelNodes), http.StatusOK)
+	vmf := createViewModelFlow(uuf, viewModelNodes)
+	vmf.Schedule = sch
+	return web.Respond(ctx, w, vmf, http.StatusOK)
 }
 
 func (f *Flow) UpdateStatus(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
@@ -52,6 +52,10 @@ func (f *Form) Adder(ctx context.Context, w http.ResponseWriter, r *http.Request
 		return web.NewRequestError(err, http.StatusForbidden)
 	}
 
+	if err := validateItemValues(ctx, accountID, entityID, newItem.Fields, nil, true, f.db, f.sdb); err != nil {
+		return err
+	}
+
 	if validate(fields, it.Fields(), newItem.Fields) != nil {
 		web.NewRequestError(err, http.StatusBadRequest)
 	}
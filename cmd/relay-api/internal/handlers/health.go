@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/health"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// defaultHistory is the number of the scores returned when the limit is not asked.
+const defaultHistory = 90
+
+// Health represents the health models scoring the items of the entities.
+type Health struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// ListModels returns the health models of the entity.
+func (h *Health) ListModels(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Health.ListModels")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, h.db)
+	models, err := health.ListModels(ctx, h.db, accountID, entityID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, models, http.StatusOK)
+}
+
+// CreateModel adds the health model with its fields to the entity and queues the scoring of the items.
+func (h *Health) CreateModel(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Health.CreateModel")
+	defer span.End()
+
+	var nm health.NewModel
+	if err := web.Decode(r, &nm); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	nm.AccountID, nm.EntityID, _ = takeAEI(ctx, params, h.db)
+	e, err := entity.Retrieve(ctx, nm.AccountID, nm.EntityID, h.db, h.sdb)
+	if err != nil {
+		return err
+	}
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m, err := health.CreateModel(ctx, h.db, nm, time.Now())
+	if err != nil {
+		if errors.Cause(err) == health.ErrInvalidModel {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return errors.Wrapf(err, "Health model: %+v", &nm)
+	}
+
+	if err := health.EnsureFields(ctx, h.db, h.sdb, e, m); err != nil {
+		return errors.Wrapf(err, "adding the health fields to the entity %s", e.ID)
+	}
+	go job.NewJob(h.db, h.sdb, h.authenticator.FireBaseAdminSDK).Stream(stream.NewHealthScoreMessage(ctx, h.db, m.AccountID, currentUserID, m.EntityID, m.ID))
+	return web.Respond(ctx, w, m, http.StatusCreated)
+}
+
+// DeleteModel removes the health model along with the history of its scores.
+func (h *Health) DeleteModel(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Health.DeleteModel")
+	defer span.End()
+
+	if err := health.DeleteModel(ctx, h.db, params["account_id"], params["model_id"]); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Score queues the scoring of all the items of the model, such as after the usage was backfilled.
+func (h *Health) Score(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Health.Score")
+	defer span.End()
+
+	m, err := health.RetrieveModel(ctx, h.db, params["account_id"], params["model_id"])
+	if err != nil {
+		if err == health.ErrNotFound {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+	go job.NewJob(h.db, h.sdb, h.authenticator.FireBaseAdminSDK).Stream(stream.NewHealthScoreMessage(ctx, h.db, m.AccountID, currentUserID, m.EntityID, m.ID))
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
+
+// History returns the scores of the item, newest first. The model_id narrows them to the model.
+func (h *Health) History(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Health.History")
+	defer span.End()
+
+	limit := defaultHistory
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	scores, err := health.History(ctx, h.db, params["account_id"], params["item_id"], r.URL.Query().Get("model_id"), limit)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, scores, http.StatusOK)
+}
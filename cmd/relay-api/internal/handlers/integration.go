@@ -3,6 +3,7 @@ package handlers
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
@@ -15,6 +16,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/integration/email"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/slack"
 	"gitlab.com/vjsideprojects/relay/internal/user"
 	"go.opencensus.io/trace"
 	"golang.org/x/net/context"
@@ -46,6 +48,8 @@ func (g *Integration) AccessIntegration(ctx context.Context, w http.ResponseWrit
 		accessURL, err = integration.GetGoogleAccessURL(ctx, g.authenticator.GoogleClientSecret, integrationID, integration.GmailScopes...)
 	case integration.TypeGoogleCalendar:
 		accessURL, err = integration.GetGoogleAccessURL(ctx, g.authenticator.GoogleClientSecret, integrationID, integration.GoogleCalendarScopes...)
+	case integration.TypeSlack:
+		accessURL = slack.AuthorizeURL(g.authenticator.SlackClientID, integrationID)
 	default:
 		return web.Respond(ctx, w, "FAILURE", http.StatusNotImplemented)
 	}
@@ -132,12 +136,99 @@ func (i *Integration) SaveIntegration(ctx context.Context, w http.ResponseWriter
 		if err != nil {
 			return errors.Wrapf(err, "Unable to create integration")
 		}
+	case integration.TypeCalDAV, integration.TypeICSFeed:
+		if code.URL == "" {
+			return web.NewRequestError(errors.New("the url of the calendar is required"), http.StatusBadRequest)
+		}
+
+		calendarEntityItem := entity.CaldendarEntity{
+			ID:       code.URL,
+			Provider: integrationID,
+			Username: code.Username,
+			APIKey:   code.Password,
+			Common:   "false",
+			Owner:    []string{currentUserID},
+		}
+
+		_, err = entity.SaveFixedEntityItem(ctx, accountID, teamID, currentUserID, entity.FixedEntityCalendar, "Calendar Config", calendarEntityItem.ID, integrationID, util.ConvertInterfaceToMap(calendarEntityItem), i.db)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to create integration")
+		}
+	case integration.TypeSlack:
+		install, err := slack.API{}.OAuthAccess(i.authenticator.SlackClientID, i.authenticator.SlackClientSecret, code.Code)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to get token from slack")
+		}
+
+		ws := slack.Workspace{
+			TeamID:      install.TeamID,
+			AccountID:   accountID,
+			BotToken:    install.BotToken,
+			BotUserID:   install.BotUserID,
+			InstalledBy: currentUserID,
+			CreatedAt:   time.Now(),
+		}
+		if err := slack.SaveWorkspace(ctx, i.db, ws); err != nil {
+			return errors.Wrapf(err, "Unable to create integration")
+		}
 	default:
 		return web.Respond(ctx, w, "FAILURE", http.StatusNotImplemented)
 	}
 	return web.Respond(ctx, w, "SUCCESS", http.StatusOK)
 }
 
+// SetSlackChannel sets the slack channel the items of the entity are notified to.
+func (i *Integration) SetSlackChannel(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Integration.SetSlackChannel")
+	defer span.End()
+
+	var sc SlackChannel
+	if err := web.Decode(r, &sc); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	accountID, entityID := params["account_id"], params["entity_id"]
+	if _, err := slack.AccountWorkspace(ctx, i.db, accountID); err != nil {
+		if err == slack.ErrWorkspaceNotFound {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	if _, err := entity.Retrieve(ctx, accountID, entityID, i.db, i.sdb); err != nil {
+		return err
+	}
+
+	c := slack.Channel{
+		AccountID: accountID,
+		EntityID:  entityID,
+		ChannelID: sc.ChannelID,
+		CreatedAt: time.Now(),
+	}
+	if err := slack.SaveChannel(ctx, i.db, c); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, c, http.StatusOK)
+}
+
+// DeleteSlackChannel stops notifying the items of the entity to slack.
+func (i *Integration) DeleteSlackChannel(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Integration.DeleteSlackChannel")
+	defer span.End()
+
+	if err := slack.DeleteChannel(ctx, i.db, params["account_id"], params["entity_id"]); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// SlackChannel is the slack channel of the entity.
+type SlackChannel struct {
+	ChannelID string `json:"channel_id" validate:"required"`
+}
+
 type Code struct {
-	Code string `json:"code"`
+	Code     string `json:"code"`
+	URL      string `json:"url"` // the calendar collection of CalDAV or the ICS feed
+	Username string `json:"username"`
+	Password string `json:"password"`
 }
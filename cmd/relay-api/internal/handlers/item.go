@@ -12,6 +12,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
 	"gitlab.com/vjsideprojects/relay/internal/schema"
 	"gitlab.com/vjsideprojects/relay/internal/user"
+	"gitlab.com/vjsideprojects/relay/internal/view"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
@@ -53,6 +54,10 @@ func (i *Item) List(ctx context.Context, w http.ResponseWriter, r *http.Request,
 	groupby := r.URL.Query().Get("groupby")
 	direction := r.URL.Query().Get("direction")
 	page := util.ConvertStrToInt(r.URL.Query().Get("page"))
+	from, to, windowed, err := listWindow(r)
+	if err != nil {
+		return err
+	}
 
 	e, err := entity.Retrieve(ctx, accountID, entityID, i.db, i.sdb)
 	if err != nil {
@@ -65,7 +70,28 @@ func (i *Item) List(ctx context.Context, w http.ResponseWriter, r *http.Request,
 		Items:    make(map[string][]ViewModelItem, 0),
 	}
 
+	// the saved view sets everything not given in the query. the older clients pass the flow as the view.
+	var sv *view.View
 	if !util.IsEmpty(viewID) {
+		sv, err = savedView(ctx, accountID, params["team_id"], entityID, viewID, i.db)
+		if err != nil {
+			return err
+		}
+	}
+	if sv != nil {
+		exp = util.AddExpression(sv.Expression, exp)
+		sortby, direction = pick(sortby, sv.SortBy), pick(direction, sv.Direction)
+		groupby = pick(groupby, sv.GroupBy)
+		if sv.Style == view.StylePipe && ls == "" {
+			ls = entity.MetaRenderPipe
+		}
+		if sv.FlowID != nil {
+			piper.Nodes, err = nodeStages(ctx, accountID, *sv.FlowID, i.db)
+			if err != nil {
+				return err
+			}
+		}
+	} else if !util.IsEmpty(viewID) {
 		exp = ""
 		fl, err := flow.Retrieve(ctx, viewID, i.db)
 		if err != nil {
@@ -85,8 +111,14 @@ func (i *Item) List(ctx context.Context, w http.ResponseWriter, r *http.Request,
 
 	var viewModelItems []ViewModelItem
 	var countMap map[string]int
+	var tl *Timeline
 
-	if groupby != "" && page == 0 {
+	if windowed { // the calendar and the timeline list the items in the window, not by the pages
+		viewModelItems, tl, err = NewSegmenter(exp).windowWrapper(ctx, accountID, e, fields, from, to, i.db, i.sdb)
+		if err != nil {
+			return err
+		}
+	} else if groupby != "" && page == 0 {
 		err := loadItemsWithGroupByLogic(ctx, accountID, e, exp, sortby, direction, groupby, page, &piper, i.db, i.sdb)
 		if err != nil {
 			return err
@@ -123,6 +155,8 @@ func (i *Item) List(ctx context.Context, w http.ResponseWriter, r *http.Request,
 		Entity   entity.ViewModelEntity `json:"entity"`
 		Piper    Piper                  `json:"piper"`
 		CountMap map[string]int         `json:"count_map"`
+		View     *view.View             `json:"view,omitempty"`
+		Timeline *Timeline              `json:"timeline,omitempty"`
 	}{
 		Items:    viewModelItems,
 		Category: e.Category,
@@ -130,6 +164,8 @@ func (i *Item) List(ctx context.Context, w http.ResponseWriter, r *http.Request,
 		Entity:   createViewModelEntity(e),
 		Piper:    piper,
 		CountMap: countMap,
+		View:     sv,
+		Timeline: tl,
 	}
 
 	return web.Respond(ctx, w, response, http.StatusOK)
@@ -202,6 +238,10 @@ func (i *Item) Update(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		return err
 	}
 
+	if err := validateItemValues(ctx, accountID, entityID, ni.Fields, existingItem.Fields(), lenientValidation(r), i.db, i.sdb); err != nil {
+		return err
+	}
+
 	errorMap := validateItemUpdate(ctx, accountID, entityID, itemID, ni.Fields, i.db, i.sdb)
 	if errorMap != nil {
 		return web.Respond(ctx, w, errorMap, http.StatusForbidden)
@@ -239,6 +279,10 @@ func (i *Item) Create(ctx context.Context, w http.ResponseWriter, r *http.Reques
 	ni.ID = uuid.New().String()
 	ni.GenieID = util.PickGenieID(ni.Source)
 
+	if err := validateItemValues(ctx, accountID, entityID, ni.Fields, nil, lenientValidation(r), i.db, i.sdb); err != nil {
+		return err
+	}
+
 	errorMap := validateItemCreate(ctx, accountID, entityID, ni.Fields, i.db, i.sdb)
 	if errorMap != nil {
 		return web.Respond(ctx, w, errorMap, http.StatusForbidden)
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/metric"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Metric represents the daily metrics the charts of the entity read instead of counting the items.
+type Metric struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// Retrieve returns the state of the metrics of the entity.
+func (mt *Metric) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Metric.Retrieve")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, mt.db)
+	b, err := metric.Retrieve(ctx, mt.db, accountID, entityID)
+	if err != nil {
+		if err == metric.ErrNotBuilt {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, b, http.StatusOK)
+}
+
+// Backfill queues the worker to count the items of the entity. The metrics built already are recounted.
+func (mt *Metric) Backfill(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Metric.Backfill")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, mt.db)
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+	go job.NewJob(mt.db, mt.sdb, mt.authenticator.FireBaseAdminSDK).Stream(stream.NewMetricBackfillMessage(ctx, mt.db, accountID, currentUserID, entityID))
+	return web.Respond(ctx, w, nil, http.StatusAccepted)
+}
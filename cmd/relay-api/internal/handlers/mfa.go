@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/mfa"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// mfaIssuer is the name shown by the authenticator apps against the code.
+	mfaIssuer = "workbaseONE"
+	// mfaChallengeTTL is the time in seconds given to the member to enter the code after the first step of the sign in.
+	mfaChallengeTTL = 300
+)
+
+// ErrForbiddenMFAToken is used when the second step of the sign in comes after the challenge expired.
+var ErrForbiddenMFAToken = web.NewRequestError(
+	errors.New("Two-factor sign in expired. Please sign in again"),
+	http.StatusForbidden,
+)
+
+// ViewModelMFASetup is shown to the member once to add the secret in the authenticator app.
+type ViewModelMFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFAVerification is the second step of the sign in.
+type MFAVerification struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// SetupMFA generates the secret for the current user. The two-factor is enabled only after the confirmation.
+func (u *User) SetupMFA(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.SetupMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	email, err := u.currentEmail(ctx, params["account_id"])
+	if err != nil {
+		return err
+	}
+
+	m, err := mfa.Setup(ctx, u.db, email, v.Now)
+	if err != nil {
+		if err == mfa.ErrAlreadyEnabled {
+			return web.NewRequestError(err, http.StatusConflict)
+		}
+		return err
+	}
+
+	return web.Respond(ctx, w, ViewModelMFASetup{Secret: m.Secret, URI: mfa.KeyURI(mfaIssuer, m.Email, m.Secret)}, http.StatusCreated)
+}
+
+// ConfirmMFA enables the two-factor with the first code from the authenticator app and returns the recovery codes.
+func (u *User) ConfirmMFA(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.ConfirmMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var vf mfa.Verification
+	if err := web.Decode(r, &vf); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	email, err := u.currentEmail(ctx, params["account_id"])
+	if err != nil {
+		return err
+	}
+
+	codes, err := mfa.Enable(ctx, u.db, email, vf.Code, v.Now)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return web.Respond(ctx, w, map[string][]string{"recovery_codes": codes}, http.StatusOK)
+}
+
+// DisableMFA turns off the two-factor of the current user.
+func (u *User) DisableMFA(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.DisableMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var vf mfa.Verification
+	if err := web.Decode(r, &vf); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	email, err := u.currentEmail(ctx, params["account_id"])
+	if err != nil {
+		return err
+	}
+
+	if err := mfa.Disable(ctx, u.db, email, vf.Code, v.Now); err != nil {
+		return mfaError(err)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// VerifyMFA is the second step of the sign in for the members with the two-factor.
+func (u *User) VerifyMFA(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.VerifyMFA")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var vf MFAVerification
+	if err := web.Decode(r, &vf); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	email, err := u.sdb.PopAuthChallenge(vf.MFAToken)
+	if err != nil || email == "" {
+		return ErrForbiddenMFAToken
+	}
+
+	if err := mfa.Verify(ctx, u.db, email, vf.Code, v.Now); err != nil {
+		return mfaError(err)
+	}
+
+	tkn, err := generateUserJWTForAnyAccount(ctx, email, true, v.Now, u.authenticator, u.db)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// mfaChallenge holds the first step of the sign in when the member has the two-factor enabled.
+// It returns nil when the member signs in without the two-factor. Every path issuing the token
+// of the member calls it before the token is generated.
+func mfaChallenge(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, email string) (*UserToken, error) {
+	enabled, err := mfa.Enabled(ctx, db, email)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	key, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := sdb.SetAuthChallenge(key, []byte(email), mfaChallengeTTL); err != nil {
+		return nil, errors.Wrap(err, "saving two-factor challenge")
+	}
+
+	return &UserToken{MFARequired: true, MFAToken: key}, nil
+}
+
+func (u *User) currentEmail(ctx context.Context, accountID string) (string, error) {
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return "", err
+	}
+	usr, err := user.RetrieveUser(ctx, u.db, accountID, currentUserID)
+	if err != nil {
+		return "", err
+	}
+	return usr.Email, nil
+}
+
+func mfaError(err error) error {
+	switch err {
+	case mfa.ErrNotFound:
+		return web.NewRequestError(err, http.StatusNotFound)
+	case mfa.ErrAlreadyEnabled:
+		return web.NewRequestError(err, http.StatusConflict)
+	case mfa.ErrInvalidCode:
+		return web.NewRequestError(err, http.StatusUnauthorized)
+	default:
+		return err
+	}
+}
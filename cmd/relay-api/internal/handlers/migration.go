@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/migration"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"go.opencensus.io/trace"
+)
+
+// previewLimit is the number of items converted in the preview.
+const previewLimit = 20
+
+// FieldMigration represents the impact analysis and the backfill of the field changes of an entity.
+type FieldMigration struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// MigrationPreview is the impact of the field changes along with the converted values of a few items.
+type MigrationPreview struct {
+	Impact   migration.Impact    `json:"impact"`
+	Previews []migration.Preview `json:"previews"`
+}
+
+// Preview returns the impact of saving the fields in the body without saving them.
+func (fm *FieldMigration) Preview(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.FieldMigration.Preview")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, fm.db)
+	var ve entity.ViewModelEntity
+	if err := web.Decode(r, &ve); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	current, err := entity.Retrieve(ctx, accountID, entityID, fm.db, fm.sdb)
+	if err != nil {
+		return err
+	}
+	changes := migration.Changes(current.EasyFields(), ve.Fields)
+
+	impact, err := migration.Analyze(ctx, fm.db, accountID, entityID, changes)
+	if err != nil {
+		return err
+	}
+	previews, err := migration.Previews(ctx, fm.db, fm.sdb, accountID, entityID, changes, previewLimit)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, MigrationPreview{Impact: impact, Previews: previews}, http.StatusOK)
+}
+
+// List returns the migrations of the entity with their progress.
+func (fm *FieldMigration) List(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.FieldMigration.List")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, fm.db)
+	migrations, err := migration.List(ctx, fm.db, accountID, entityID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, migrations, http.StatusOK)
+}
+
+// Retrieve returns the progress of the migration.
+func (fm *FieldMigration) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.FieldMigration.Retrieve")
+	defer span.End()
+
+	m, err := migration.Retrieve(ctx, fm.db, params["account_id"], params["migration_id"])
+	if err != nil {
+		if err == migration.ErrNotFound {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, m, http.StatusOK)
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/platform/conversation"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Inbox lists the page of the notices of the current user with the count of the unread ones. The notices
+// on the same item are grouped into one thread when asked for. The notices can be narrowed to the types, e.g. ?type=1&type=3.
+func (n *Notification) Inbox(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.Inbox")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	accountID := params["account_id"]
+	page := util.ConvertStrToInt(r.URL.Query().Get("page"))
+	f := notification.InboxFilter{
+		Unread:   r.URL.Query().Get("unread") == "true",
+		Archived: r.URL.Query().Get("archived") == "true",
+	}
+	for _, t := range r.URL.Query()["type"] {
+		f.Types = append(f.Types, util.ConvertStrToInt(t))
+	}
+
+	response := struct {
+		Notices []notification.Notice `json:"notices,omitempty"`
+		Threads []notification.Thread `json:"threads,omitempty"`
+		Unread  int                   `json:"unread"`
+	}{}
+	if r.URL.Query().Get("grouped") == "true" {
+		response.Threads, err = notification.Threads(ctx, n.db, accountID, currentUserID, f, page)
+	} else {
+		response.Notices, err = notification.Inbox(ctx, n.db, accountID, currentUserID, f, page)
+	}
+	if err != nil {
+		return err
+	}
+
+	response.Unread, err = notification.UnreadCount(ctx, n.db, accountID, currentUserID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, response, http.StatusOK)
+}
+
+// UnreadCount returns the count of the unread notices of the current user.
+func (n *Notification) UnreadCount(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.UnreadCount")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, err := notification.UnreadCount(ctx, n.db, params["account_id"], currentUserID)
+	if err != nil {
+		return err
+	}
+
+	response := struct {
+		Unread int `json:"unread"`
+	}{
+		Unread: count,
+	}
+	return web.Respond(ctx, w, response, http.StatusOK)
+}
+
+// MarkRead marks the notices of the current user read.
+func (n *Notification) MarkRead(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	return n.markRead(ctx, w, r, params, true)
+}
+
+// MarkUnread marks the notices of the current user unread.
+func (n *Notification) MarkUnread(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	return n.markRead(ctx, w, r, params, false)
+}
+
+func (n *Notification) markRead(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string, read bool) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.MarkRead")
+	defer span.End()
+
+	var m notification.Mark
+	if err := web.Decode(r, &m); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := notification.MarkRead(ctx, n.db, params["account_id"], currentUserID, m, read, time.Now()); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// MarkAllRead marks every notice of the current user read.
+func (n *Notification) MarkAllRead(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.MarkAllRead")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := notification.MarkAllRead(ctx, n.db, params["account_id"], currentUserID, time.Now()); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Archive takes the notices of the current user out of the inbox.
+func (n *Notification) Archive(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.Archive")
+	defer span.End()
+
+	var m notification.Mark
+	if err := web.Decode(r, &m); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := notification.Archive(ctx, n.db, params["account_id"], currentUserID, m, time.Now()); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// NoticeSocket joins the current user to the room the new notices of the account are pushed to.
+func (cv *Conversation) NoticeSocket(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("***> unexpected error occurred when serving the notice sockets. error: %v\n", err)
+		return err
+	}
+
+	currentUserID, err := user.RetrieveWSCurrentUserID(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "auth claims missing from context")
+	}
+
+	accountID := params["account_id"]
+	cuser, err := user.RetrieveUser(ctx, cv.db, accountID, currentUserID)
+	if err != nil {
+		return err
+	}
+
+	room := conversation.NoticeRoom(accountID, currentUserID)
+	client := conversation.NewClient(conn, cv.hub, uuid.New().String(), "", room, currentUserID, cuser.Email, *cuser.Name, *cuser.Avatar)
+
+	go client.WritePump()
+	go client.ListenPump()
+
+	cv.hub.Register <- client
+	return nil
+}
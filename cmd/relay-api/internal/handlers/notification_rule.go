@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// ListRules returns the routing rules of the current user with the rules for everyone in the account.
+func (n *Notification) ListRules(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.ListRules")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules, err := notification.ListRules(ctx, n.db, params["account_id"], currentUserID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, rules, http.StatusOK)
+}
+
+// CreateRule saves the routing rule of the current user. The admins save the rules for everyone.
+func (n *Notification) CreateRule(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.CreateRule")
+	defer span.End()
+
+	var nr notification.NewRule
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := validateRule(nr); err != nil {
+		return err
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+	userID := &currentUserID
+	if nr.Everyone {
+		if role, _ := ctx.Value(auth.RoleKey).(string); role != auth.RoleAdmin {
+			return web.NewRequestError(errors.New("only the admins can route the notifications of everyone"), http.StatusForbidden)
+		}
+		userID = nil
+	}
+
+	rule, err := notification.CreateRule(ctx, n.db, params["account_id"], userID, nr, time.Now())
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, rule, http.StatusCreated)
+}
+
+// UpdateRule replaces the routing of the rule.
+func (n *Notification) UpdateRule(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.UpdateRule")
+	defer span.End()
+
+	var nr notification.NewRule
+	if err := web.Decode(r, &nr); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := validateRule(nr); err != nil {
+		return err
+	}
+
+	rule, err := ownedRule(ctx, params["account_id"], params["rule_id"], n)
+	if err != nil {
+		return err
+	}
+
+	rule, err = notification.UpdateRule(ctx, n.db, rule, nr, time.Now())
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, rule, http.StatusOK)
+}
+
+// DeleteRule removes the routing rule.
+func (n *Notification) DeleteRule(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Notification.DeleteRule")
+	defer span.End()
+
+	rule, err := ownedRule(ctx, params["account_id"], params["rule_id"], n)
+	if err != nil {
+		return err
+	}
+
+	if err := notification.DeleteRule(ctx, n.db, rule.AccountID, rule.ID); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ownedRule returns the rule the current user can change. The rules for everyone are changed by the admins.
+func ownedRule(ctx context.Context, accountID, ruleID string, n *Notification) (notification.Rule, error) {
+	rule, err := notification.RetrieveRule(ctx, n.db, accountID, ruleID)
+	if err != nil {
+		if err == notification.ErrRuleNotFound || err == notification.ErrInvalidID {
+			return notification.Rule{}, web.NewRequestError(notification.ErrRuleNotFound, http.StatusNotFound)
+		}
+		return notification.Rule{}, err
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return notification.Rule{}, err
+	}
+	role, _ := ctx.Value(auth.RoleKey).(string)
+	if rule.UserID == nil && role != auth.RoleAdmin {
+		return notification.Rule{}, web.NewRequestError(errors.New("only the admins can change the rules of everyone"), http.StatusForbidden)
+	}
+	if rule.UserID != nil && *rule.UserID != currentUserID {
+		return notification.Rule{}, web.NewRequestError(notification.ErrRuleNotFound, http.StatusNotFound)
+	}
+	return rule, nil
+}
+
+func validateRule(nr notification.NewRule) error {
+	for _, ch := range nr.Channels {
+		if ch == notification.ChannelSlack && nr.SlackWebhook == "" {
+			return &web.Error{
+				Err:    errors.New("field validation error"),
+				Status: http.StatusBadRequest,
+				Fields: []web.FieldError{{Field: "slack_webhook", Error: "is required for the slack channel"}},
+			}
+		}
+	}
+	return nil
+}
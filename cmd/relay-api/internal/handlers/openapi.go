@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/alert"
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+	"gitlab.com/vjsideprojects/relay/internal/chart"
+	conv "gitlab.com/vjsideprojects/relay/internal/conversation"
+	"gitlab.com/vjsideprojects/relay/internal/draft"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/event"
+	"gitlab.com/vjsideprojects/relay/internal/health"
+	integ "gitlab.com/vjsideprojects/relay/internal/integration"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/metric"
+	"gitlab.com/vjsideprojects/relay/internal/mfa"
+	"gitlab.com/vjsideprojects/relay/internal/migration"
+	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
+	"gitlab.com/vjsideprojects/relay/internal/platform/openapi"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/report"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"gitlab.com/vjsideprojects/relay/internal/sandbox"
+	"gitlab.com/vjsideprojects/relay/internal/scim"
+	"gitlab.com/vjsideprojects/relay/internal/sla"
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"gitlab.com/vjsideprojects/relay/internal/sso"
+	"gitlab.com/vjsideprojects/relay/internal/team"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+	"gitlab.com/vjsideprojects/relay/internal/token"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"gitlab.com/vjsideprojects/relay/internal/view"
+	"go.opencensus.io/trace"
+)
+
+// APIVersion is the version of the api shown in the OpenAPI document.
+const APIVersion = "1.0.0"
+
+// OpenAPI serves the OpenAPI document of the routes registered on the app.
+type OpenAPI struct {
+	app  *web.App
+	once sync.Once
+	doc  openapi.Document
+}
+
+// Spec returns the OpenAPI document. It is built on the first request when all the routes are registered.
+func (o *OpenAPI) Spec(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OpenAPI.Spec")
+	defer span.End()
+
+	o.once.Do(func() {
+		o.doc, _ = Spec(o.app.Routes())
+	})
+
+	return web.Respond(ctx, w, o.doc, http.StatusOK)
+}
+
+// Spec builds the OpenAPI document of the routes and returns the routes missing in operations.
+func Spec(routes []web.Route) (openapi.Document, []string) {
+	return openapi.Build(openapi.Info{Title: "Relay API", Version: APIVersion}, routes, operations)
+}
+
+// jsonObject documents the responses built on the fly in the handlers.
+var jsonObject = map[string]interface{}{}
+
+// operations annotate the routes registered in API with the request and the response types.
+// Every route should have the operation, the test of the document fails otherwise.
+var operations = map[string]openapi.Operation{
+	"GET /v1/health":       {Summary: "Check the health of the api and the database", Response: jsonObject},
+	"GET /v1/openapi.json": {Summary: "Get the OpenAPI document of the api", Response: jsonObject},
+	"GET /v1/unsubscribe":  {Summary: "Unsubscribe from the emails", Response: ""},
+
+	// users
+	"GET /v1/users/verify":                                  {Summary: "Sign in with the firebase token", Response: UserToken{}},
+	"GET /v1/users/join":                                    {Summary: "Join the account with the invitation token", Response: UserToken{}, Status: http.StatusCreated},
+	"GET /v1/users/visit":                                   {Summary: "Sign in the visitor with the invitation token", Response: UserToken{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/users/current/profile":    {Summary: "Update the profile of the current user", Request: user.UpdateUser{}, Status: http.StatusNoContent},
+	"DELETE /v1/accounts/:account_id/users/current/profile": {Summary: "Delete the current user", Status: http.StatusNoContent},
+	"GET /v1/accounts/:account_id/users/current/profile":    {Summary: "Get the profile of the current user", Response: user.ViewModelUser{}},
+	"PUT /v1/accounts/:account_id/users/current/setting":    {Summary: "Update the settings of the current user", Request: user.NewUserSetting{}, Status: http.StatusNoContent},
+	"GET /v1/accounts/:account_id/users/current/setting":    {Summary: "Get the settings of the current user", Response: user.ViewModelUserSetting{}},
+
+	// two-factor
+	"POST /v1/users/mfa/verify":                               {Summary: "Complete the sign in with the two-factor code", Request: MFAVerification{}, Response: UserToken{}},
+	"POST /v1/accounts/:account_id/users/current/mfa":         {Summary: "Start the two-factor setup", Response: ViewModelMFASetup{}, Status: http.StatusCreated},
+	"POST /v1/accounts/:account_id/users/current/mfa/confirm": {Summary: "Confirm the two-factor setup", Request: mfa.Verification{}, Response: map[string][]string{}},
+	"DELETE /v1/accounts/:account_id/users/current/mfa":       {Summary: "Turn off the two-factor", Request: mfa.Verification{}, Status: http.StatusNoContent},
+
+	// single sign on
+	"GET /v1/sso/token":                     {Summary: "Exchange the single sign on code for the token", Response: UserToken{}},
+	"GET /v1/sso/:account_id/login":         {Summary: "Get the redirect to the identity provider", Response: map[string]string{}},
+	"GET /v1/sso/:account_id/oidc/callback": {Summary: "Receive the OIDC authorization code", Status: http.StatusFound},
+	"POST /v1/sso/:account_id/saml/acs":     {Summary: "Receive the SAML assertion", Status: http.StatusFound},
+	"GET /v1/accounts/:account_id/sso":      {Summary: "Get the single sign on provider", Response: ViewModelSSO{}},
+	"PUT /v1/accounts/:account_id/sso":      {Summary: "Save the single sign on provider", Request: sso.NewProvider{}, Response: ViewModelSSO{}},
+	"DELETE /v1/accounts/:account_id/sso":   {Summary: "Remove the single sign on provider", Status: http.StatusNoContent},
+
+	// provisioning
+	"GET /v1/accounts/:account_id/scim": {Summary: "Get the provisioning config", Response: scim.Config{}},
+	"PUT /v1/accounts/:account_id/scim": {Summary: "Save the provisioning config", Request: scim.NewConfig{}, Response: scim.Config{}},
+	"GET /scim/v2/Users":                {Summary: "List the provisioned users", Response: scim.ListResponse{}},
+	"POST /scim/v2/Users":               {Summary: "Provision the user", Request: scim.User{}, Response: scim.User{}, Status: http.StatusCreated},
+	"GET /scim/v2/Users/:user_id":       {Summary: "Get the provisioned user", Response: scim.User{}},
+	"PUT /scim/v2/Users/:user_id":       {Summary: "Replace the provisioned user", Request: scim.User{}, Response: scim.User{}},
+	"PATCH /scim/v2/Users/:user_id":     {Summary: "Change the provisioned user", Request: scim.PatchOp{}, Response: scim.User{}},
+	"DELETE /scim/v2/Users/:user_id":    {Summary: "Deprovision the user", Status: http.StatusNoContent},
+	"GET /scim/v2/Groups":               {Summary: "List the provisioned groups", Response: scim.ListResponse{}},
+	"POST /scim/v2/Groups":              {Summary: "Provision the group", Request: scim.Group{}, Response: scim.Group{}, Status: http.StatusCreated},
+	"GET /scim/v2/Groups/:group_id":     {Summary: "Get the provisioned group", Response: scim.Group{}},
+	"PUT /scim/v2/Groups/:group_id":     {Summary: "Replace the provisioned group", Request: scim.Group{}, Response: scim.Group{}},
+	"PATCH /scim/v2/Groups/:group_id":   {Summary: "Change the provisioned group", Request: scim.PatchOp{}, Response: scim.Group{}},
+	"DELETE /scim/v2/Groups/:group_id":  {Summary: "Remove the provisioned group", Status: http.StatusNoContent},
+
+	// accounts
+	"POST /v1/accounts/drafts":                            {Summary: "Start the sign up", Request: draft.NewDraft{}, Response: true, Status: http.StatusCreated},
+	"POST /v1/accounts/launch/:draft_id":                  {Summary: "Launch the account of the draft", Response: UserToken{}, Status: http.StatusCreated},
+	"GET /v1/accounts/availability":                       {Summary: "Check the domain is available", Response: true},
+	"GET /v1/accounts":                                    {Summary: "List the accounts of the current user", Response: []ViewModelAccountPage{}},
+	"GET /v1/accounts/:account_id":                        {Summary: "Get the account", Response: ViewModelAccount{}},
+	"POST /v1/accounts/:account_id":                       {Summary: "Switch the token to the account", Response: UserToken{}},
+	"PUT /v1/accounts/:account_id/calendar":               {Summary: "Set the calendar the charts of the account follow", Request: account.Calendar{}, Status: http.StatusNoContent},
+	"GET /v1/accounts/:account_id/api":                    {Summary: "Get the api token of the account", Response: APIToken{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/tokens": {Summary: "Generate the webhook url of the entity", Request: entity.NewEntity{}, Response: "", Status: http.StatusCreated},
+	"POST /v1/accounts/:account_id/billing/portal":        {Summary: "Get the url of the billing portal", Response: ""},
+	"POST /stripe/webhook":                                {Summary: "Receive the stripe events"},
+
+	// api keys
+	"GET /v1/accounts/:account_id/api_keys":                 {Summary: "List the api keys", Response: []ViewModelAPIKey{}},
+	"POST /v1/accounts/:account_id/api_keys":                {Summary: "Create the api key", Request: token.NewAPIKey{}, Response: ViewModelAPIKey{}, Status: http.StatusCreated},
+	"POST /v1/accounts/:account_id/api_keys/:key_id/rotate": {Summary: "Rotate the api key", Response: ViewModelAPIKey{}, Status: http.StatusCreated},
+	"DELETE /v1/accounts/:account_id/api_keys/:key_id":      {Summary: "Revoke the api key", Status: http.StatusNoContent},
+
+	// visitors
+	"GET /v1/accounts/:account_id/visitors":                                                     {Summary: "List the visitors", Response: []ViewModelVisitor{}},
+	"GET /v1/accounts/:account_id/visitors/:visitor_id":                                         {Summary: "Get the visitor", Response: ViewModelVisitor{}},
+	"POST /v1/accounts/:account_id/visitors":                                                    {Summary: "Invite the visitor", Request: ViewModelVisitor{}, Response: ViewModelVisitor{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/visitors/:visitor_id/toggle_active":                           {Summary: "Turn the visitor on or off", Response: ""},
+	"PUT /v1/accounts/:account_id/visitors/:visitor_id/resend":                                  {Summary: "Resend the invitation to the visitor", Request: ViewModelVisitor{}, Response: ""},
+	"DELETE /v1/accounts/:account_id/visitors/:visitor_id":                                      {Summary: "Remove the visitor", Response: ""},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/vistorinfo": {Summary: "Get the visitors of the item", Response: jsonObject},
+
+	// integrations
+	"POST /notifications":                                                                    {Summary: "Receive the calendar notifications", Response: ""},
+	"POST /receive/gmail/message":                                                            {Summary: "Receive the gmail push messages", Request: PushMsgPayload{}, Response: ""},
+	"GET /v1/accounts/:account_id/integrations/:integration_id":                              {Summary: "Get the url to connect the integration", Response: ""},
+	"POST /v1/accounts/:account_id/integrations/:integration_id":                             {Summary: "Save the integration with the authorization code", Request: Code{}, Response: ""},
+	"POST /v1/accounts/:account_id/integrations/:integration_id/actions/:action_id":          {Summary: "Run the action of the integration", Request: integ.ActionPayload{}, Response: ""},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/calendar.ics":           {Summary: "Export the meetings as the iCalendar feed", Response: ""},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/slack_channel":          {Summary: "Notify the items of the entity to the slack channel", Request: SlackChannel{}, Response: slack.Channel{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/slack_channel":       {Summary: "Stop notifying the items of the entity to slack", Status: http.StatusNoContent},
+	"POST /v1/accounts/:account_id/twilio/:account_token/entities/:entity_id/items/:item_id": {Summary: "Receive the twilio call events"},
+
+	// teams
+	"POST /v1/accounts/:account_id/teams":           {Summary: "Create the team", Request: team.NewTeam{}, Response: team.Team{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/teams":            {Summary: "List the teams", Response: []team.Team{}},
+	"POST /v1/accounts/:account_id/teams/templates": {Summary: "Add the templates to the team", Request: team.Template{}, Response: ""},
+	"GET /v1/accounts/:account_id/teams/templates":  {Summary: "List the templates", Response: []team.Template{}},
+	"GET /v1/accounts/:account_id/teams/modules":    {Summary: "List the modules", Response: []team.Module{}},
+
+	// members
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/members":              {Summary: "Add the member", Request: ViewModelMember{}, Response: ViewModelMember{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/members/:member_id":    {Summary: "Update the member", Request: ViewModelMember{}, Response: ViewModelItem{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/members":               {Summary: "List the members", Response: jsonObject},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/members/:member_id": {Summary: "Remove the member", Response: "", Status: http.StatusAccepted},
+
+	// entities
+	"POST /v1/accounts/:account_id/teams/:team_id/entities":                                    {Summary: "Create the entity", Request: entity.NewEntity{}, Response: entity.ViewModelEntity{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities":                                     {Summary: "List the entities", Response: []entity.ViewModelEntity{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/home":                                         {Summary: "Get the entities of the home page", Response: jsonObject},
+	"GET /v1/accounts/:account_id/teams/:team_id/dash":                                         {Summary: "Get the entities of the dashboard", Response: jsonObject},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id":                          {Summary: "Get the entity", Response: entity.ViewModelEntity{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id":                          {Summary: "Update the entity", Request: entity.ViewModelEntity{}, Response: entity.ViewModelEntity{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/meta/:ls":                 {Summary: "Update the layout of the entity", Response: entity.ViewModelEntity{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/share":                    {Summary: "Share the entity with the teams", Request: entity.Entity{}, Response: entity.ViewModelEntity{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/schema":                   {Summary: "Get the JSON schema of the item values of the entity", Response: entity.JSONSchema{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/share":                 {Summary: "Stop sharing the entity with the team", Response: entity.ViewModelEntity{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/associate":                {Summary: "List the associations of the entity", Response: []ViewModelChildren{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/associate":                {Summary: "Associate the entities", Request: AssociationReqBody{}, Response: ""},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/mark":                     {Summary: "Mark the entity", Request: entity.ViewModelEntity{}, Response: entity.ViewModelEntity{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id":                       {Summary: "Delete the entity", Response: "", Status: http.StatusAccepted},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/toggleaccess":             {Summary: "Turn the public access of the entity on or off", Response: entity.ViewModelEntity{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views":                    {Summary: "List the saved views of the entity", Response: []view.View{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views":                   {Summary: "Save the view of the entity", Request: view.NewView{}, Response: view.View{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views/:view_id":           {Summary: "Get the saved view", Response: view.View{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views/:view_id":           {Summary: "Update the saved view", Request: view.NewView{}, Response: view.View{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views/:view_id":        {Summary: "Delete the saved view", Status: http.StatusNoContent},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/migrations/preview":      {Summary: "Preview the impact of changing the fields", Request: entity.ViewModelEntity{}, Response: MigrationPreview{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/migrations":               {Summary: "List the field migrations", Response: []migration.Migration{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/migrations/:migration_id": {Summary: "Get the field migration", Response: migration.Migration{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/metrics":                  {Summary: "Get the state of the daily metrics of the entity", Response: metric.Build{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/metrics":                 {Summary: "Backfill the daily metrics of the entity", Status: http.StatusAccepted},
+
+	// forms
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/forms/:item_id":  {Summary: "Render the form", Response: jsonObject},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/forms/:item_id": {Summary: "Submit the form", Request: item.NewItem{}, Response: "", Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/teams/:team_id/forms":                               {Summary: "List the forms", Response: jsonObject},
+
+	// notifications
+	"GET /v1/accounts/:account_id/notifications/rules":                                             {Summary: "List the notification routing rules of the user", Response: []notification.Rule{}},
+	"POST /v1/accounts/:account_id/notifications/rules":                                            {Summary: "Route the notifications of the user", Request: notification.NewRule{}, Response: notification.Rule{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/notifications/rules/:rule_id":                                    {Summary: "Update the notification routing rule", Request: notification.NewRule{}, Response: notification.Rule{}},
+	"DELETE /v1/accounts/:account_id/notifications/rules/:rule_id":                                 {Summary: "Delete the notification routing rule", Status: http.StatusNoContent},
+	"GET /v1/accounts/:account_id/notifications/inbox":                                             {Summary: "List the notices of the user, grouped by the item when asked for", Response: jsonObject},
+	"GET /v1/accounts/:account_id/notifications/inbox/count":                                       {Summary: "Count the unread notices of the user", Response: map[string]int{}},
+	"PUT /v1/accounts/:account_id/notifications/inbox/read":                                        {Summary: "Mark the notices read", Request: notification.Mark{}, Status: http.StatusNoContent},
+	"PUT /v1/accounts/:account_id/notifications/inbox/unread":                                      {Summary: "Mark the notices unread", Request: notification.Mark{}, Status: http.StatusNoContent},
+	"PUT /v1/accounts/:account_id/notifications/inbox/read_all":                                    {Summary: "Mark all the notices read", Status: http.StatusNoContent},
+	"PUT /v1/accounts/:account_id/notifications/inbox/archive":                                     {Summary: "Archive the notices", Request: notification.Mark{}, Status: http.StatusNoContent},
+	"POST /v1/accounts/:account_id/notifications/registration":                                     {Summary: "Register the device for the push notifications", Request: notification.ViewModelClientRegister{}, Response: true},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/notifications": {Summary: "Clear the notifications of the item", Response: ViewModelItem{}},
+
+	// items
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items":                      {Summary: "Create the item", Request: item.NewItem{}, Response: ViewModelItem{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items":                       {Summary: "List the items, or the items overlapping the from and to window", Response: jsonObject},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/records/:state":              {Summary: "List the items in the state", Response: jsonObject},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id":              {Summary: "Update the item", Request: item.NewItem{}, Response: ViewModelItem{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id":              {Summary: "Get the item with its relationships", Response: jsonObject},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/schedule":     {Summary: "Move the start and the end of the item", Request: Schedule{}, Response: ViewModelItem{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id":           {Summary: "Delete the item", Response: "", Status: http.StatusAccepted},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/search":                {Summary: "Search the items", Response: []entity.Choice{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/templates":                  {Summary: "Create the template item", Request: item.NewItem{}, Response: ViewModelItem{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/toggleaccess": {Summary: "Turn the public access of the item on or off", Response: ViewModelItem{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/segments":                   {Summary: "Create the segment", Request: FilterBody{}, Response: flow.ViewModelFlow{}, Status: http.StatusCreated},
+
+	// flows
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows":                           {Summary: "Create the flow", Request: flow.NewFlow{}, Response: flow.ViewModelFlow{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id":                   {Summary: "Update the flow", Request: flow.NewFlow{}, Response: flow.ViewModelFlow{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/status":            {Summary: "Update the status of the flow", Request: flow.NewFlow{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows":                            {Summary: "List the flows", Response: []flow.ViewModelFlow{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id":                   {Summary: "Get the flow", Response: flow.ViewModelFlow{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id":                {Summary: "Delete the flow", Response: "", Status: http.StatusAccepted},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/items":             {Summary: "List the items in the flow", Response: jsonObject},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/items/:item_id":    {Summary: "Get the trail of the item in the flow", Response: jsonObject},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/nodes":            {Summary: "Create the node", Request: node.NewNode{}, Response: node.ViewModelNode{}, Status: http.StatusCreated},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/nodes/:node_id":    {Summary: "Update the node", Request: node.NewNode{}, Response: node.NewNode{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/nodes/:node_id":    {Summary: "Get the node", Response: node.ViewModelNode{}},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/nodes/:node_id": {Summary: "Delete the node", Response: "", Status: http.StatusAccepted},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/nodes/map":         {Summary: "Save the nodes of the flow", Request: node.NodeMapWrapper{}, Response: jsonObject},
+
+	// relationships
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/relationships/:relationship_id":       {Summary: "List the child items", Response: jsonObject},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/relationships/:relationship_id/tasks": {Summary: "List the child tasks with the progress", Response: jsonObject},
+
+	// incidents and alerts
+	"POST /aws/sns/:accountkey/:productkey":                                                    {Summary: "Receive the aws sns notifications"},
+	"POST /aws/alerts/:account_key":                                                            {Summary: "Receive the aws cloudwatch alarms"},
+	"POST /alerts/:account_key/:source":                                                        {Summary: "Receive the alerts of the monitoring tool", Response: []alert.Alert{}, Status: http.StatusAccepted},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts":                   {Summary: "List the alerts", Response: []alert.Alert{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts/rules":             {Summary: "List the alert rules", Response: []alert.Rule{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts/rules":            {Summary: "Create the alert rule", Request: alert.NewRule{}, Response: alert.Rule{}, Status: http.StatusCreated},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts/rules/:rule_id": {Summary: "Delete the alert rule", Status: http.StatusNoContent},
+
+	// sla
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/sla/policies":               {Summary: "List the sla policies", Response: []sla.Policy{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/sla/policies":              {Summary: "Create the sla policy", Request: sla.NewPolicy{}, Response: sla.Policy{}, Status: http.StatusCreated},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/sla/policies/:policy_id": {Summary: "Delete the sla policy", Status: http.StatusNoContent},
+	"GET /v1/accounts/:account_id/sla/calendars":                                                 {Summary: "List the business calendars", Response: []sla.Calendar{}},
+	"POST /v1/accounts/:account_id/sla/calendars":                                                {Summary: "Create the business calendar", Request: sla.NewCalendar{}, Response: sla.Calendar{}, Status: http.StatusCreated},
+	"DELETE /v1/accounts/:account_id/sla/calendars/:calendar_id":                                 {Summary: "Delete the business calendar", Status: http.StatusNoContent},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/sla":         {Summary: "List the sla timers of the item", Response: []sla.Timer{}},
+	// health
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models":                  {Summary: "List the health models", Response: []health.Model{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models":                 {Summary: "Create the health model", Request: health.NewModel{}, Response: health.Model{}, Status: http.StatusCreated},
+	"DELETE /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models/:model_id":     {Summary: "Delete the health model", Status: http.StatusNoContent},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models/:model_id/score": {Summary: "Score the items of the health model", Status: http.StatusAccepted},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/health":          {Summary: "List the health scores of the item", Response: []health.Score{}},
+
+	// pipelines
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/stages":                     {Summary: "List the stages of the pipeline", Response: []pipeline.Stage{}},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/stages/:node_id":            {Summary: "Set the probability, the expected days and the category of the stage", Request: pipeline.NewStage{}, Response: pipeline.Stage{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/items/:item_id/transitions": {Summary: "List the moves of the item between the stages", Response: []pipeline.Transition{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/conversion":                 {Summary: "Report the conversion of the stages", Response: []pipeline.Conversion{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/velocity":                   {Summary: "Report the days spent in the stages", Response: []pipeline.Velocity{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/forecast":                   {Summary: "Forecast the deals by the period, the owner and the category", Response: pipeline.Forecast{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/forecast/trend":             {Summary: "Compare the weekly snapshots of the forecast", Response: pipeline.Trend{}},
+
+	// packages and sandboxes
+	"GET /v1/accounts/:account_id/teams/:team_id/package":          {Summary: "Export the team as the package", Response: pack.Package{}},
+	"POST /v1/accounts/:account_id/packages":                       {Summary: "Install the package", Request: pack.Package{}, Response: pack.Installation{}, Status: http.StatusCreated},
+	"POST /v1/accounts/:account_id/teams/:team_id/package/diff":    {Summary: "Compare the package with the installed team", Request: pack.Package{}, Response: []pack.Change{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/package/upgrade": {Summary: "Upgrade the team to the package", Request: pack.Package{}, Response: []pack.Change{}},
+	"GET /v1/accounts/:account_id/sandboxes":                       {Summary: "List the sandboxes", Response: []ViewModelAccountPage{}},
+	"POST /v1/accounts/:account_id/sandboxes":                      {Summary: "Create the sandbox", Request: sandbox.NewSandbox{}, Response: ViewModelAccount{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/sandbox/changes":                 {Summary: "List the changes made in the sandbox", Response: []sandbox.TeamChanges{}},
+	"POST /v1/accounts/:account_id/sandbox/promote":                {Summary: "Promote the changes to the production account", Request: []sandbox.TeamChanges{}, Response: []sandbox.TeamChanges{}},
+
+	// conversations
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/conversations":    {Summary: "List the conversations of the item", Response: []conv.ViewModelConversation{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/socket/auth":      {Summary: "Get the token to open the socket", Response: map[string]string{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/notifications/socket/auth":                                          {Summary: "Get the token to open the socket of the notices", Response: map[string]string{}, Status: http.StatusCreated},
+	"GET /v1/ws/accounts/:account_id/notifications/socket/:token":                                     {Summary: "Open the socket the new notices are pushed to", Status: http.StatusSwitchingProtocols},
+	"GET /v1/ws/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/socket/:token": {Summary: "Open the socket of the conversation", Status: http.StatusSwitchingProtocols},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/conversations":   {Summary: "Create the conversation", Request: conv.NewConversation{}, Response: conv.Conversation{}, Status: http.StatusCreated},
+
+	// events, counters and charts
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/events": {Summary: "List the events of the item", Response: jsonObject},
+	"PUT /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/count/:destination":    {Summary: "Count the items of the destination", Request: CountRequest{}, Response: jsonObject},
+	"GET /v1/accounts/:account_id/teams/:team_id/overview":                                  {Summary: "Get the overview of the team", Response: jsonObject},
+	"POST /v1/streams": {Summary: "Send the event", Request: event.NewEvent{}, Response: item.Item{}, Status: http.StatusCreated},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/timeseries":           {Summary: "List the charts", Response: []VMChart{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/timeseries/:chart_id": {Summary: "Get the chart", Response: VMChart{}},
+	"GET /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/timeseries/onme":      {Summary: "List the charts of the current user", Response: []VMChart{}},
+	"GET /v1/accounts/:account_id/timerange":                                               {Summary: "Resolve the time range with its previous period", Response: timerange.Range{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/reports":             {Summary: "Run the report over the items", Request: report.Report{}, Response: ReportResult{}},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/reports/export":      {Summary: "Export the report as the csv or the xlsx file", Request: report.Report{}, Response: ""},
+	"POST /v1/accounts/:account_id/teams/:team_id/entities/:entity_id/reports/charts":      {Summary: "Save the report as the chart of the dashboard", Request: SaveReport{}, Response: chart.NewChart{}, Status: http.StatusCreated},
+}
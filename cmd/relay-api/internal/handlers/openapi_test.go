@@ -0,0 +1,78 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"gitlab.com/vjsideprojects/relay/cmd/relay-api/internal/handlers"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/conversation"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/ratelimit"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestOpenAPI(t *testing.T) {
+	// the routes are only registered, nothing is served so the stores are never reached.
+	offline := &redis.Pool{Dial: func() (redis.Conn, error) { return nil, errors.New("offline") }}
+	sdb := database.Init(offline, offline, offline)
+	app := handlers.API(make(chan os.Signal, 1), log.New(os.Stdout, "", 0), nil, nil, sdb, &auth.Authenticator{}, &conversation.Publisher{}, ratelimit.Config{}).(*web.App)
+
+	t.Log("Given the need to document every route of the api")
+	{
+		doc, missing := handlers.Spec(app.Routes())
+
+		t.Log("\twhen the document is built from the registered routes")
+		{
+			if len(missing) > 0 {
+				t.Fatalf("\t%s should have the operation for every route. missing %d : %v", tests.Failed, len(missing), missing)
+			}
+			t.Logf("\t%s should have the operation for every route", tests.Success)
+
+			ids := make(map[string]bool, 0)
+			for path, item := range doc.Paths {
+				for method, op := range item {
+					if ids[op.OperationID] {
+						t.Fatalf("\t%s should have the unique operation IDs. %s %s repeats %s", tests.Failed, method, path, op.OperationID)
+					}
+					ids[op.OperationID] = true
+				}
+			}
+			t.Logf("\t%s should have the unique operation IDs", tests.Success)
+
+			if _, err := json.Marshal(doc); err != nil {
+				t.Fatalf("\t%s should encode the document : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s should encode the document", tests.Success)
+		}
+
+		t.Log("\twhen the route is limited to the admins")
+		{
+			op := doc.Paths["/v1/accounts/{account_id}/sso"]["put"]
+			if len(op.Security) != 1 || len(op.Roles) != 1 || op.Roles[0] != auth.RoleAdmin || !op.AccountAccess {
+				t.Fatalf("\t%s should require the token, the admin role and the account access. got %+v, %v, %v", tests.Failed, op.Security, op.Roles, op.AccountAccess)
+			}
+			if op.RequestBody == nil || op.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/handlers.ViewModelSSO" {
+				t.Fatalf("\t%s should refer the request and the response schemas. got %+v", tests.Failed, op.Responses)
+			}
+			if _, ok := doc.Components.Schemas["handlers.ViewModelSSO"].Properties["redirect_url"]; !ok {
+				t.Fatalf("\t%s should list the fields of the response", tests.Failed)
+			}
+			t.Logf("\t%s should require the token, the admin role and the account access", tests.Success)
+		}
+
+		t.Log("\twhen the route is public")
+		{
+			op := doc.Paths["/v1/users/verify"]["get"]
+			if len(op.Security) != 0 || len(op.Roles) != 0 {
+				t.Fatalf("\t%s should not require the token. got %+v, %v", tests.Failed, op.Security, op.Roles)
+			}
+			t.Logf("\t%s should not require the token", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Pack represents the declarative app packages. A team can be exported as a package and
+// a package can be imported into another account or upgraded on the team where it was installed.
+type Pack struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// Export returns the team as a package. The sample items are included when the query param samples is true
+// and the package is written in YAML when the query param format is yaml.
+func (pk *Pack) Export(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pack.Export")
+	defer span.End()
+
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		version = "1.0.0"
+	}
+	p, err := pack.Export(ctx, pk.db, params["account_id"], params["team_id"], version, r.URL.Query().Get("samples") == "true")
+	if err != nil {
+		if err == pack.ErrTeamNotFound {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+	if r.URL.Query().Get("format") == "yaml" {
+		var buf bytes.Buffer
+		if err := pack.EncodeYAML(&buf, p); err != nil {
+			return err
+		}
+		return web.RespondRaw(ctx, w, buf.Bytes(), "application/yaml", http.StatusOK)
+	}
+	return web.Respond(ctx, w, p, http.StatusOK)
+}
+
+// Import installs the package as a new team of the account.
+func (pk *Pack) Import(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pack.Import")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	p, err := pack.Decode(r.Body)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	inst, err := pack.Import(ctx, pk.db, pk.sdb, params["account_id"], currentUserID, p, pk.authenticator.FireBaseAdminSDK, time.Now())
+	if err != nil {
+		if errors.Cause(err) == pack.ErrRequirementMissing {
+			return web.NewRequestError(err, http.StatusUnprocessableEntity)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, inst, http.StatusCreated)
+}
+
+// Diff returns the changes the upgrade to the package would make on the team.
+func (pk *Pack) Diff(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pack.Diff")
+	defer span.End()
+
+	p, err := pack.Decode(r.Body)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	changes, err := pack.DiffInstalled(ctx, pk.db, params["account_id"], params["team_id"], p)
+	if err != nil {
+		return packError(err)
+	}
+	return web.Respond(ctx, w, changes, http.StatusOK)
+}
+
+// Upgrade applies the new version of the package on the team.
+func (pk *Pack) Upgrade(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pack.Upgrade")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	p, err := pack.Decode(r.Body)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	changes, err := pack.Upgrade(ctx, pk.db, pk.sdb, params["account_id"], params["team_id"], currentUserID, p, pk.authenticator.FireBaseAdminSDK, time.Now())
+	if err != nil {
+		return packError(err)
+	}
+	return web.Respond(ctx, w, changes, http.StatusOK)
+}
+
+func packError(err error) error {
+	switch errors.Cause(err) {
+	case pack.ErrNotInstalled:
+		return web.NewRequestError(err, http.StatusNotFound)
+	case pack.ErrRequirementMissing:
+		return web.NewRequestError(err, http.StatusUnprocessableEntity)
+	}
+	return err
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+	"go.opencensus.io/trace"
+)
+
+// The reports of the pipelines cover the last quarter and the forecasts this quarter, unless asked otherwise.
+const (
+	defaultReportRange   = timerange.Last90Days
+	defaultForecastRange = timerange.ThisQuarter
+	defaultTrendWeeks    = 8
+)
+
+// Pipeline represents the stages, the reports and the forecasts of the pipelines.
+type Pipeline struct {
+	db  *sqlx.DB
+	sdb *database.SecDB
+}
+
+// Stages returns the stages of the pipeline in their order with their probability and expected days.
+func (p *Pipeline) Stages(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.Stages")
+	defer span.End()
+
+	if _, err := p.pipeline(ctx, params); err != nil {
+		return err
+	}
+	stages, err := pipeline.Stages(ctx, p.db, params["account_id"], params["flow_id"])
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, stages, http.StatusOK)
+}
+
+// SaveStage sets the probability, the expected days and the forecast category of the stage.
+func (p *Pipeline) SaveStage(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.SaveStage")
+	defer span.End()
+
+	var ns pipeline.NewStage
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if _, err := p.pipeline(ctx, params); err != nil {
+		return err
+	}
+
+	s, err := pipeline.SaveStage(ctx, p.db, params["account_id"], params["flow_id"], params["node_id"], ns, time.Now())
+	if err != nil {
+		switch errors.Cause(err) {
+		case pipeline.ErrInvalidStage:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case pipeline.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return errors.Wrapf(err, "Stage: %+v", &ns)
+	}
+	return web.Respond(ctx, w, s, http.StatusOK)
+}
+
+// Transitions returns the moves of the item between the stages of the pipeline, oldest first.
+func (p *Pipeline) Transitions(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.Transitions")
+	defer span.End()
+
+	transitions, err := pipeline.Transitions(ctx, p.db, params["account_id"], params["flow_id"], params["item_id"])
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, transitions, http.StatusOK)
+}
+
+// Conversion returns how the items entering each stage in the range went on to the later stages.
+func (p *Pipeline) Conversion(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.Conversion")
+	defer span.End()
+
+	rg, _, err := p.reportRange(ctx, r, params, defaultReportRange)
+	if err != nil {
+		return err
+	}
+	if _, err := p.pipeline(ctx, params); err != nil {
+		return err
+	}
+	conversions, err := pipeline.Conversions(ctx, p.db, params["account_id"], params["flow_id"], rg.Start, rg.End)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, conversions, http.StatusOK)
+}
+
+// Velocity returns the days the items leaving each stage in the range spent in it.
+func (p *Pipeline) Velocity(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.Velocity")
+	defer span.End()
+
+	rg, _, err := p.reportRange(ctx, r, params, defaultReportRange)
+	if err != nil {
+		return err
+	}
+	if _, err := p.pipeline(ctx, params); err != nil {
+		return err
+	}
+	velocities, err := pipeline.Velocities(ctx, p.db, params["account_id"], params["flow_id"], rg.Start, rg.End)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, velocities, http.StatusOK)
+}
+
+// Forecast returns the deals closing in the range weighted by their stages, by the period asked for, the owner
+// and the category.
+func (p *Pipeline) Forecast(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.Forecast")
+	defer span.End()
+
+	rg, cal, err := p.reportRange(ctx, r, params, defaultForecastRange)
+	if err != nil {
+		return err
+	}
+	f, err := p.pipeline(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	fc, err := pipeline.Project(ctx, p.db, p.sdb, f, r.URL.Query().Get("period"), rg.Start, rg.End, cal)
+	if err != nil {
+		switch errors.Cause(err) {
+		case pipeline.ErrNoAmount, timerange.ErrUnknownRange:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, fc, http.StatusOK)
+}
+
+// Trend returns the weekly snapshots of the forecast with the changes of the latest week against the one before.
+func (p *Pipeline) Trend(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Pipeline.Trend")
+	defer span.End()
+
+	if _, err := p.pipeline(ctx, params); err != nil {
+		return err
+	}
+	weeks := defaultTrendWeeks
+	if n, err := strconv.Atoi(r.URL.Query().Get("weeks")); err == nil && n > 0 {
+		weeks = n
+	}
+	tr, err := pipeline.Trends(ctx, p.db, params["account_id"], params["flow_id"], weeks, time.Now())
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, tr, http.StatusOK)
+}
+
+// pipeline retrieves the flow of the request, not found when it is not the pipeline of the account.
+func (p *Pipeline) pipeline(ctx context.Context, params map[string]string) (flow.Flow, error) {
+	f, err := pipeline.Retrieve(ctx, p.db, params["account_id"], params["flow_id"])
+	if err == pipeline.ErrNotFound {
+		return f, web.NewRequestError(err, http.StatusNotFound)
+	}
+	return f, err
+}
+
+// reportRange resolves the range of the report in the calendar of the account and the user.
+func (p *Pipeline) reportRange(ctx context.Context, r *http.Request, params map[string]string, duration string) (timerange.Range, timerange.Settings, error) {
+	cal, err := rangeSettings(ctx, r, params["account_id"], p.db)
+	if err != nil {
+		return timerange.Range{}, cal, err
+	}
+	rg, err := requestRange(r, duration, time.Now(), cal)
+	return rg, cal, err
+}
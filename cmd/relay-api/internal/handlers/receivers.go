@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -33,6 +34,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
 	"gitlab.com/vjsideprojects/relay/internal/schema"
 	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
 )
 
 type PushMsgPayload struct {
@@ -70,15 +72,38 @@ func (g *Integration) Act(ctx context.Context, w http.ResponseWriter, r *http.Re
 	switch integrationID {
 	case integration.TypeGmail:
 		return web.Respond(ctx, w, "FAILURE", http.StatusNotImplemented)
-	case integration.TypeGoogleCalendar:
-		c := calendar.Calendar{}
-		c.Act(ctx, accountID, actionID, actionPayload, g.db)
+	case integration.TypeGoogleCalendar, integration.TypeCalDAV, integration.TypeICSFeed:
+		c := calendar.Calendar{Stream: job.NewJob(g.db, g.sdb, g.authenticator.FireBaseAdminSDK).Stream}
+		if err := c.Act(ctx, accountID, actionID, actionPayload, g.db); err != nil {
+			return err
+		}
 	default:
 		return web.Respond(ctx, w, "FAILURE", http.StatusNotImplemented)
 	}
 	return web.Respond(ctx, w, "SUCCESS", http.StatusOK)
 }
 
+// ExportCalendar returns the meetings of the entity as the iCalendar feed.
+func (g *Integration) ExportCalendar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Integration.ExportCalendar")
+	defer span.End()
+
+	accountID := params["account_id"]
+	e, err := entity.Retrieve(ctx, accountID, params["entity_id"], g.db, g.sdb)
+	if err != nil {
+		return err
+	}
+	if e.Category != entity.CategoryMeeting {
+		return web.NewRequestError(errors.New("the entity has no meetings"), http.StatusBadRequest)
+	}
+
+	var buf bytes.Buffer
+	if err := calendar.Export(ctx, &buf, accountID, e, g.db); err != nil {
+		return err
+	}
+	return web.RespondRaw(ctx, w, buf.Bytes(), "text/calendar; charset=utf-8", http.StatusOK)
+}
+
 func (g *Integration) Notifications(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	log.Printf("handlers.receivers: notifications received with body %s\n", r.Body)
 	return web.Respond(ctx, w, "SUCCESS", http.StatusOK)
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/chart"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/report"
+	"go.opencensus.io/trace"
+)
+
+// Report represents the ad-hoc reports over the items of the entities.
+type Report struct {
+	db  *sqlx.DB
+	sdb *database.SecDB
+}
+
+// ReportResult is the report both as it is and pivoted.
+type ReportResult struct {
+	Result report.Result `json:"result"`
+	Pivot  report.Pivot  `json:"pivot"`
+}
+
+// SaveReport is the report to be saved as the chart of the dashboard.
+type SaveReport struct {
+	Report      report.Report `json:"report"`
+	DashboardID string        `json:"dashboard_id"`
+	DisplayName string        `json:"display_name" validate:"required"`
+	Type        chart.Type    `json:"type"`
+}
+
+// Run runs the report over the items of the entity.
+func (rep *Report) Run(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Report.Run")
+	defer span.End()
+
+	var rp report.Report
+	if err := web.Decode(r, &rp); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	res, err := rep.run(ctx, r, params, &rp)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, ReportResult{Result: res, Pivot: res.Pivot(len(rp.Rows))}, http.StatusOK)
+}
+
+// Export runs the report and sends it as the csv or the xlsx file. The reports with the columns are pivoted.
+func (rep *Report) Export(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Report.Export")
+	defer span.End()
+
+	var rp report.Report
+	if err := web.Decode(r, &rp); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	write, contentType, ext := report.WriteCSV, report.ContentTypeCSV, "csv"
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+	case "xlsx":
+		write, contentType, ext = report.WriteXLSX, report.ContentTypeXLSX, "xlsx"
+	default:
+		return web.NewRequestError(errors.Errorf("unknown export format %q", format), http.StatusBadRequest)
+	}
+
+	res, err := rep.run(ctx, r, params, &rp)
+	if err != nil {
+		return err
+	}
+	t := res.Table()
+	if len(rp.Columns) > 0 {
+		t = res.Pivot(len(rp.Rows)).Table()
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, t); err != nil {
+		return err
+	}
+	name := rp.Name
+	if name == "" {
+		name = "report"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+ext))
+	return web.RespondRaw(ctx, w, buf.Bytes(), contentType, http.StatusOK)
+}
+
+// Save saves the report as the chart of the dashboard.
+func (rep *Report) Save(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Report.Save")
+	defer span.End()
+
+	var sr SaveReport
+	if err := web.Decode(r, &sr); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	if _, err := rep.run(ctx, r, params, &sr.Report); err != nil {
+		return err
+	}
+	if sr.Type == "" {
+		sr.Type = chart.TypeGrid
+	}
+
+	nc, err := sr.Report.Chart(params["account_id"], params["team_id"], sr.DashboardID, sr.DisplayName, sr.Type)
+	if err != nil {
+		return err
+	}
+	if err := chart.Create(ctx, rep.db, *nc, time.Now()); err != nil {
+		return errors.Wrapf(err, "Report chart: %+v", nc)
+	}
+	return web.Respond(ctx, w, nc, http.StatusCreated)
+}
+
+// run runs the report on the entity of the path, filtering the items by the expression of the report. The dates
+// are bucketed by the calendar of the account and the current user.
+func (rep *Report) run(ctx context.Context, r *http.Request, params map[string]string, rp *report.Report) (report.Result, error) {
+	accountID, entityID, _ := takeAEI(ctx, params, rep.db)
+	rp.EntityID = entityID
+
+	conditionFields, err := makeConditionsFromExp(ctx, accountID, entityID, rp.Exp, rep.db, rep.sdb)
+	if err != nil {
+		return report.Result{}, err
+	}
+	cal, err := rangeSettings(ctx, r, accountID, rep.db)
+	if err != nil {
+		return report.Result{}, err
+	}
+	res, err := report.Run(ctx, rep.db, rep.sdb, accountID, *rp, conditionFields, cal)
+	if err != nil {
+		if errors.Cause(err) == report.ErrInvalidReport {
+			return report.Result{}, web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return report.Result{}, err
+	}
+	return res, nil
+}
@@ -11,14 +11,16 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
 	"gitlab.com/vjsideprojects/relay/internal/platform/conversation"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/ratelimit"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
 )
 
 // API constructs an http.Handler with all application routes defined.
-func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sdb *database.SecDB, authenticator *auth.Authenticator, publisher *conversation.Publisher) http.Handler {
+func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sdb *database.SecDB, authenticator *auth.Authenticator, publisher *conversation.Publisher, limits ratelimit.Config) http.Handler {
 
 	// Construct the web.App which holds all routes as well as common Middleware.
-	app := web.NewApp(shutdown, log, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
+	// The rate limit sits inside Errors so the rejected requests get the error response.
+	app := web.NewApp(shutdown, log, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log), mid.RateLimit(log, limits, authenticator, db))
 
 	// Register health check endpoint. This route is not authenticated.
 	check := Check{
@@ -47,6 +49,46 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts/:account_id/users/current/profile", u.Retrieve, mid.Authenticate(authenticator), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/users/current/setting", u.UpdateUserSetting, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/users/current/setting", u.RetriveUserSetting, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	// two-factor
+	app.Handle("POST", "/v1/users/mfa/verify", u.VerifyMFA)
+	app.Handle("POST", "/v1/accounts/:account_id/users/current/mfa", u.SetupMFA, mid.Authenticate(authenticator), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/users/current/mfa/confirm", u.ConfirmMFA, mid.Authenticate(authenticator), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/users/current/mfa", u.DisableMFA, mid.Authenticate(authenticator), mid.HasAccountAccess(db))
+
+	ss := SSO{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	// single sign on. The login and the callbacks are hit by the browser before the member has the token.
+	app.Handle("GET", "/v1/sso/token", ss.Token)
+	app.Handle("GET", "/v1/sso/:account_id/login", ss.Login)
+	app.Handle("GET", "/v1/sso/:account_id/oidc/callback", ss.OIDCCallback)
+	app.Handle("POST", "/v1/sso/:account_id/saml/acs", ss.SAMLACS)
+	app.Handle("GET", "/v1/accounts/:account_id/sso", ss.Retrieve, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/sso", ss.Save, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/sso", ss.Delete, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+
+	sc := SCIM{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	// provisioning by the identity providers with the API key having the scim scope
+	app.Handle("GET", "/v1/accounts/:account_id/scim", sc.RetrieveConfig, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/scim", sc.SaveConfig, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/scim/v2/Users", sc.ListUsers, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("POST", "/scim/v2/Users", sc.CreateUser, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("GET", "/scim/v2/Users/:user_id", sc.RetrieveUser, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("PUT", "/scim/v2/Users/:user_id", sc.ReplaceUser, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("PATCH", "/scim/v2/Users/:user_id", sc.PatchUser, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("DELETE", "/scim/v2/Users/:user_id", sc.DeleteUser, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("GET", "/scim/v2/Groups", sc.ListGroups, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("POST", "/scim/v2/Groups", sc.CreateGroup, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("GET", "/scim/v2/Groups/:group_id", sc.RetrieveGroup, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("PUT", "/scim/v2/Groups/:group_id", sc.ReplaceGroup, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("PATCH", "/scim/v2/Groups/:group_id", sc.PatchGroup, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("DELETE", "/scim/v2/Groups/:group_id", sc.DeleteGroup, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
 
 	a := Account{
 		db:            db,
@@ -61,9 +103,21 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts", a.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser))
 	app.Handle("GET", "/v1/accounts/:account_id", a.Retrieve, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser), mid.HasAccountAccess(db))
 	app.Handle("POST", "/v1/accounts/:account_id", a.GenerateToken, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
+	app.Handle("PUT", "/v1/accounts/:account_id/calendar", a.UpdateCalendar, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/api", a.APIToken, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin))
 	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/tokens", a.GenerateURL, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 
+	ak := APIKey{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	// Register the scoped api keys of the account.
+	app.Handle("GET", "/v1/accounts/:account_id/api_keys", ak.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/api_keys", ak.Create, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/api_keys/:key_id/rotate", ak.Rotate, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/api_keys/:key_id", ak.Revoke, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+
 	v := Visitor{
 		db:            db,
 		sdb:           sdb,
@@ -90,6 +144,9 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts/:account_id/integrations/:integration_id", integ.AccessIntegration, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
 	app.Handle("POST", "/v1/accounts/:account_id/integrations/:integration_id", integ.SaveIntegration, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
 	app.Handle("POST", "/v1/accounts/:account_id/integrations/:integration_id/actions/:action_id", integ.Act, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/calendar.ics", integ.ExportCalendar, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/slack_channel", integ.SetSlackChannel, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/slack_channel", integ.DeleteSlackChannel, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
 
 	t := Team{
 		db:            db,
@@ -127,6 +184,7 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/home", e.Home, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/dash", e.Dash, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id", e.Retrieve, mid.Authenticate(authenticator))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/schema", e.Schema, mid.Authenticate(authenticator), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id", e.Update, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/meta/:ls", e.UpdateLS, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/share", e.ShareTeam, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
@@ -137,6 +195,34 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id", e.Delete, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/toggleaccess", e.ToggleAccess, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
 
+	// Register saved view endpoints.
+	vw := View{
+		db:  db,
+		sdb: sdb,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views", vw.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views", vw.Create, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views/:view_id", vw.Retrieve, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views/:view_id", vw.Update, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/views/:view_id", vw.Delete, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+
+	fmg := FieldMigration{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/migrations/preview", fmg.Preview, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/migrations", fmg.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/migrations/:migration_id", fmg.Retrieve, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+
+	mt := Metric{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/metrics", mt.Retrieve, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/metrics", mt.Backfill, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+
 	fom := Form{
 		db:            db,
 		sdb:           sdb,
@@ -153,6 +239,16 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	}
 	// Register teams management endpoints.
 	app.Handle("POST", "/v1/accounts/:account_id/notifications/registration", noti.Register, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/notifications/rules", noti.ListRules, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/notifications/rules", noti.CreateRule, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/notifications/rules/:rule_id", noti.UpdateRule, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/notifications/rules/:rule_id", noti.DeleteRule, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/notifications/inbox", noti.Inbox, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/notifications/inbox/count", noti.UnreadCount, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/notifications/inbox/read", noti.MarkRead, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/notifications/inbox/unread", noti.MarkUnread, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/notifications/inbox/read_all", noti.MarkAllRead, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/notifications/inbox/archive", noti.Archive, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/notifications", noti.Clear, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 
 	i := Item{
@@ -168,6 +264,7 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/records/:state", i.StateRecords, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id", i.Update, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser, auth.RoleMyself), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id", i.Retrieve, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser, auth.RoleVisitor), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/schedule", i.Schedule, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser, auth.RoleMyself), mid.HasAccountAccess(db))
 	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id", i.Delete, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser, auth.RoleMyself), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/search", i.Search, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser), mid.HasAccountAccess(db))
 	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/templates", i.CreateTemplate, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser), mid.HasAccountAccess(db))
@@ -228,6 +325,74 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("POST", "/aws/sns/:accountkey/:productkey", ass.Create)
 	app.Handle("POST", "/aws/alerts/:account_key", ass.ManageIncidents)
 
+	al := Alert{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	// Register alert webhooks from the monitoring tools. The account key authorizes the request.
+	app.Handle("POST", "/alerts/:account_key/:source", al.Ingest)
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts", al.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts/rules", al.ListRules, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts/rules", al.CreateRule, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/alerts/rules/:rule_id", al.DeleteRule, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+
+	sl := SLA{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/sla/policies", sl.ListPolicies, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/sla/policies", sl.CreatePolicy, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/sla/policies/:policy_id", sl.DeletePolicy, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/sla/calendars", sl.ListCalendars, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/sla/calendars", sl.CreateCalendar, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/sla/calendars/:calendar_id", sl.DeleteCalendar, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/sla", sl.ItemTimers, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+
+	hl := Health{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models", hl.ListModels, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models", hl.CreateModel, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("DELETE", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models/:model_id", hl.DeleteModel, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/health/models/:model_id/score", hl.Score, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/health", hl.History, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+
+	pl := Pipeline{
+		db:  db,
+		sdb: sdb,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/stages", pl.Stages, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("PUT", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/stages/:node_id", pl.SaveStage, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/items/:item_id/transitions", pl.Transitions, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/conversion", pl.Conversion, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/velocity", pl.Velocity, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/forecast", pl.Forecast, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/flows/:flow_id/forecast/trend", pl.Trend, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+
+	pk := Pack{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/package", pk.Export, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/packages", pk.Import, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/package/diff", pk.Diff, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/package/upgrade", pk.Upgrade, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+
+	sb := Sandbox{
+		db:            db,
+		sdb:           sdb,
+		authenticator: authenticator,
+	}
+	app.Handle("GET", "/v1/accounts/:account_id/sandboxes", sb.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/sandboxes", sb.Create, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/sandbox/changes", sb.Changes, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/sandbox/promote", sb.Promote, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
+
 	twil := Twilio{
 		db:            db,
 		sdb:           sdb,
@@ -247,6 +412,8 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/conversations", cv.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/socket/auth", cv.SocketPreAuth, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser, auth.RoleVisitor), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/ws/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/socket/:token", cv.WebSocketMessage, mid.HasSocketAccess(sdb))
+	app.Handle("GET", "/v1/accounts/:account_id/notifications/socket/auth", cv.SocketPreAuth, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/ws/accounts/:account_id/notifications/socket/:token", cv.NoticeSocket, mid.HasSocketAccess(sdb))
 	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/items/:item_id/conversations", cv.Create, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember, auth.RoleUser), mid.HasAccountAccess(db))
 
 	// Register events endpoints.
@@ -280,6 +447,16 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/timeseries", ts.List, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/timeseries/:chart_id", ts.Chart, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 	app.Handle("GET", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/timeseries/onme", ts.OnMe, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("GET", "/v1/accounts/:account_id/timerange", ts.Range, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+
+	// Register report endpoints.
+	rep := Report{
+		db:  db,
+		sdb: sdb,
+	}
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/reports", rep.Run, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/reports/export", rep.Export, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
+	app.Handle("POST", "/v1/accounts/:account_id/teams/:team_id/entities/:entity_id/reports/charts", rep.Save, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin, auth.RoleMember), mid.HasAccountAccess(db))
 
 	// Register bill endpoints.
 	bi := Bill{
@@ -289,5 +466,11 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, chdb *sql.DB, sd
 	app.Handle("POST", "/stripe/webhook", bi.Events)
 	app.Handle("POST", "/v1/accounts/:account_id/billing/portal", bi.Portal, mid.Authenticate(authenticator), mid.HasRole(auth.RoleAdmin), mid.HasAccountAccess(db))
 
+	// Register the OpenAPI document of the routes above.
+	oa := OpenAPI{
+		app: app,
+	}
+	app.Handle("GET", "/v1/openapi.json", oa.Spec)
+
 	return app
 }
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/sandbox"
+	"gitlab.com/vjsideprojects/relay/internal/token"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Sandbox represents the sandbox accounts used to try the config changes before promoting them to the parent account.
+type Sandbox struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// List returns the sandboxes of the account.
+func (s *Sandbox) List(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Sandbox.List")
+	defer span.End()
+
+	accounts, err := sandbox.List(ctx, s.db, params["account_id"])
+	if err != nil {
+		return err
+	}
+
+	vmAccPages := make([]ViewModelAccountPage, 0)
+	for _, acc := range accounts {
+		vmAccPages = append(vmAccPages, createViewModelAccountPage(acc))
+	}
+	return web.Respond(ctx, w, vmAccPages, http.StatusOK)
+}
+
+// Create clones the account into a new sandbox account.
+func (s *Sandbox) Create(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Sandbox.Create")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var ns sandbox.NewSandbox
+	if err := web.Decode(r, &ns); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	acc, err := sandbox.Create(ctx, s.db, s.sdb, params["account_id"], currentUserID, ns, s.authenticator.FireBaseAdminSDK, time.Now())
+	if err != nil {
+		switch err {
+		case sandbox.ErrNameTaken:
+			return web.NewRequestError(err, http.StatusConflict)
+		case sandbox.ErrNestedSandbox:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+
+	systemToken, err := generateSystemUserJWT(ctx, acc.ID, []string{}, time.Now(), s.authenticator, s.db)
+	if err != nil {
+		account.Delete(ctx, s.db, acc.ID)
+		return web.NewRequestError(errors.Wrap(err, "System JWT creation failed"), http.StatusInternalServerError)
+	}
+	if err := token.Create(ctx, s.db, systemToken, acc.ID, time.Now()); err != nil {
+		account.Delete(ctx, s.db, acc.ID)
+		return web.NewRequestError(errors.Wrap(err, "System JWT token save failed"), http.StatusInternalServerError)
+	}
+
+	return web.Respond(ctx, w, createViewModelAccount(&acc), http.StatusCreated)
+}
+
+// Changes returns the config changes of the sandbox which are not promoted yet.
+func (s *Sandbox) Changes(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Sandbox.Changes")
+	defer span.End()
+
+	changes, err := sandbox.Changes(ctx, s.db, params["account_id"])
+	if err != nil {
+		if err == sandbox.ErrNotSandbox {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, changes, http.StatusOK)
+}
+
+// Promote applies the selected changes of the sandbox on the parent account.
+func (s *Sandbox) Promote(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Sandbox.Promote")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var selected []sandbox.TeamChanges
+	if err := web.Decode(r, &selected); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	promoted, err := sandbox.Promote(ctx, s.db, s.sdb, params["account_id"], currentUserID, selected, s.authenticator.FireBaseAdminSDK, time.Now())
+	if err != nil {
+		switch err {
+		case sandbox.ErrNotSandbox:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case sandbox.ErrUnknownUser:
+			return web.NewRequestError(err, http.StatusForbidden)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, promoted, http.StatusOK)
+}
@@ -0,0 +1,931 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/scim"
+	"gitlab.com/vjsideprojects/relay/internal/sso"
+	"gitlab.com/vjsideprojects/relay/internal/team"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// scimPageSize is the number of the resources returned when the client does not ask for the count.
+const scimPageSize = 100
+
+// SCIM is the SCIM 2.0 server used by the identity providers to provision the members (users)
+// and the teams (groups) of the account. It is authenticated by the API key with the "scim" scope
+// and the account is the account of the key.
+type SCIM struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// directory is the owners entity used to serve the SCIM resources. The users and the teams of the
+// members served are read by their ids and kept for the request.
+type directory struct {
+	accountID string
+	owner     entity.Entity
+	keys      map[string]string
+	users     map[string]user.User
+	teams     map[string]team.Team
+}
+
+// RetrieveConfig returns the provisioning setting of the account.
+func (s *SCIM) RetrieveConfig(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.RetrieveConfig")
+	defer span.End()
+
+	c, err := scim.RetrieveConfig(ctx, s.db, params["account_id"])
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, c, http.StatusOK)
+}
+
+// SaveConfig changes the provisioning setting of the account.
+func (s *SCIM) SaveConfig(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.SaveConfig")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var nc scim.NewConfig
+	if err := web.Decode(r, &nc); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	c, err := scim.SaveConfig(ctx, s.db, params["account_id"], nc, v.Now)
+	if err != nil {
+		if errors.Cause(err) == scim.ErrInvalidConfig {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+
+	return web.Respond(ctx, w, c, http.StatusOK)
+}
+
+// ListUsers returns the members of the account. The clients filter by the userName to find the member.
+func (s *SCIM) ListUsers(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.ListUsers")
+	defer span.End()
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+
+	var f scim.Filter
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		if f, err = scim.ParseFilter(filter); err != nil {
+			return scimError(ctx, w, http.StatusBadRequest, "invalidFilter", err)
+		}
+	}
+
+	var members []item.Item
+	var total int
+	if f.Attribute != "" {
+		if members, err = s.filterMembers(ctx, d, f); err != nil {
+			return err
+		}
+		total = len(members)
+		from, to := scimBounds(r, total)
+		members = members[from:to]
+	} else {
+		counts, err := item.Counts(ctx, d.accountID, d.owner.ID, "", s.db)
+		if err != nil {
+			return err
+		}
+		total = counts["total_count"]
+		from, count := scimRange(r)
+		if members, err = item.Range(ctx, d.accountID, d.owner.ID, from, count, s.db); err != nil {
+			return err
+		}
+	}
+	if err := s.load(ctx, d, members); err != nil {
+		return err
+	}
+
+	users := make([]scim.User, 0, len(members))
+	for _, it := range members {
+		users = append(users, d.user(r, it))
+	}
+
+	return scimList(ctx, w, r, users, total)
+}
+
+// RetrieveUser returns the member.
+func (s *SCIM) RetrieveUser(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.RetrieveUser")
+	defer span.End()
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+
+	it, ok, err := s.member(ctx, d, params["user_id"])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return scimError(ctx, w, http.StatusNotFound, "", errors.New("User not found"))
+	}
+	if err := s.load(ctx, d, []item.Item{it}); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, d.user(r, it), http.StatusOK)
+}
+
+// CreateUser provisions the member. The invitation mail is not sent as the member signs in through the IdP.
+func (s *SCIM) CreateUser(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.CreateUser")
+	defer span.End()
+
+	var su scim.User
+	if err := web.Decode(r, &su); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidSyntax", err)
+	}
+	email := su.Email()
+	if email == "" {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidValue", errors.New("userName should be the email of the member"))
+	}
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+	existing, err := item.Matching(ctx, d.accountID, d.owner.ID, d.keys["email"], []string{strings.ToLower(email)}, s.db)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return scimError(ctx, w, http.StatusConflict, "uniqueness", errors.Errorf("User %s already exists", email))
+	}
+
+	name := su.FullName()
+	if name == "" {
+		name = util.NameInEmail(email)
+	}
+
+	var usr user.User
+	if su.IsActive() {
+		usr, err = s.activate(ctx, d.accountID, name, email)
+		if err != nil {
+			return err
+		}
+		d.users[usr.ID] = usr
+	}
+	userID := usr.ID
+
+	vm := ViewModelMember{
+		UserID: userID,
+		Name:   name,
+		Email:  email,
+		Teams:  []ViewTeam{},
+		Role:   []interface{}{s.provisionRole(ctx, d.accountID)},
+	}
+	ni := item.NewItem{
+		ID:        uuid.New().String(),
+		AccountID: d.accountID,
+		EntityID:  d.owner.ID,
+		Fields:    recreateFields(vm, d.keys),
+	}
+	if userID != "" {
+		ni.UserID = &userID
+	}
+	it, err := item.Create(ctx, s.db, ni, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "creating member %s", email)
+	}
+	if userID != "" {
+		if err := usr.UpdateMemberID(ctx, it.ID, s.db); err != nil {
+			return err
+		}
+	}
+
+	return web.Respond(ctx, w, d.user(r, it), http.StatusCreated)
+}
+
+// ReplaceUser updates the member with the user sent by the client.
+func (s *SCIM) ReplaceUser(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.ReplaceUser")
+	defer span.End()
+
+	var su scim.User
+	if err := web.Decode(r, &su); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidSyntax", err)
+	}
+
+	return s.updateUser(ctx, w, r, params["user_id"], func(existing *scim.User) error {
+		*existing = su
+		return nil
+	})
+}
+
+// PatchUser applies the changes to the member. The IdPs deactivate the member by replacing active with false.
+func (s *SCIM) PatchUser(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.PatchUser")
+	defer span.End()
+
+	var patch scim.PatchOp
+	if err := web.Decode(r, &patch); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidSyntax", err)
+	}
+
+	return s.updateUser(ctx, w, r, params["user_id"], func(existing *scim.User) error {
+		return existing.ApplyPatch(patch.Operations)
+	})
+}
+
+// DeleteUser removes the access of the member to the account and then the member itself.
+func (s *SCIM) DeleteUser(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.DeleteUser")
+	defer span.End()
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+
+	it, ok, err := s.member(ctx, d, params["user_id"])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return scimError(ctx, w, http.StatusNotFound, "", errors.New("User not found"))
+	}
+	if err := s.load(ctx, d, []item.Item{it}); err != nil {
+		return err
+	}
+	if usr, ok := d.users[d.field(it, "user_id")]; ok {
+		if err := scim.Deprovision(ctx, s.db, usr); err != nil {
+			return err
+		}
+	}
+	if err := item.Delete(ctx, s.db, d.accountID, d.owner.ID, it.ID); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ListGroups returns the teams of the account with their members.
+func (s *SCIM) ListGroups(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.ListGroups")
+	defer span.End()
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+
+	var f scim.Filter
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		if f, err = scim.ParseFilter(filter); err != nil {
+			return scimError(ctx, w, http.StatusBadRequest, "invalidFilter", err)
+		}
+	}
+
+	var teams []team.Team
+	switch {
+	case f.Attribute == "":
+		teams, err = team.List(ctx, d.accountID, s.db)
+	case strings.EqualFold(f.Attribute, "displayName"):
+		var t team.Team
+		t, err = team.RetrieveByName(ctx, s.db, d.accountID, f.Value)
+		if err == nil {
+			teams = []team.Team{t}
+		} else if err == team.ErrNotFound {
+			err = nil
+		}
+	case strings.EqualFold(f.Attribute, "id"):
+		var t team.Team
+		var ok bool
+		t, ok, err = s.team(ctx, d, f.Value)
+		if ok {
+			teams = []team.Team{t}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	from, to := scimBounds(r, len(teams))
+	groups := make([]scim.Group, 0, to-from)
+	for _, t := range teams[from:to] {
+		g, err := s.group(ctx, d, r, t)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, g)
+	}
+
+	return scimList(ctx, w, r, groups, len(teams))
+}
+
+// RetrieveGroup returns the team with its members.
+func (s *SCIM) RetrieveGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.RetrieveGroup")
+	defer span.End()
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+
+	t, ok, err := s.team(ctx, d, params["group_id"])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return scimError(ctx, w, http.StatusNotFound, "", errors.New("Group not found"))
+	}
+
+	g, err := s.group(ctx, d, r, t)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, g, http.StatusOK)
+}
+
+// CreateGroup creates the team and adds the members to it.
+func (s *SCIM) CreateGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.CreateGroup")
+	defer span.End()
+
+	var g scim.Group
+	if err := web.Decode(r, &g); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidSyntax", err)
+	}
+	if strings.TrimSpace(g.DisplayName) == "" {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidValue", errors.New("displayName is required"))
+	}
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := team.RetrieveByName(ctx, s.db, d.accountID, g.DisplayName); err == nil {
+		return scimError(ctx, w, http.StatusConflict, "uniqueness", errors.Errorf("Group %s already exists", g.DisplayName))
+	} else if err != team.ErrNotFound {
+		return err
+	}
+
+	nt := team.NewTeam{
+		AccountID: d.accountID,
+		LookUp:    strings.ReplaceAll(g.DisplayName, " ", "_"),
+		Name:      g.DisplayName,
+	}
+	t, err := team.Create(ctx, s.db, nt, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "Team: %+v", &nt)
+	}
+	d.teams[t.ID] = t
+
+	members := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		members = append(members, m.Value)
+	}
+	if err := s.updateMembership(ctx, d, t.ID, scim.GroupPatch{ReplaceMembers: true, Members: members}); err != nil {
+		return err
+	}
+
+	created, err := s.group(ctx, d, r, t)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, created, http.StatusCreated)
+}
+
+// ReplaceGroup renames the team and replaces its members.
+func (s *SCIM) ReplaceGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.ReplaceGroup")
+	defer span.End()
+
+	var g scim.Group
+	if err := web.Decode(r, &g); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidSyntax", err)
+	}
+
+	members := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		members = append(members, m.Value)
+	}
+	gp := scim.GroupPatch{ReplaceMembers: true, Members: members}
+	if g.DisplayName != "" {
+		gp.DisplayName = &g.DisplayName
+	}
+	return s.updateGroup(ctx, w, r, params["group_id"], gp)
+}
+
+// PatchGroup applies the changes to the team. The IdPs add and remove the members one by one with it.
+func (s *SCIM) PatchGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.PatchGroup")
+	defer span.End()
+
+	var patch scim.PatchOp
+	if err := web.Decode(r, &patch); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidSyntax", err)
+	}
+	gp, err := scim.GroupChanges(patch.Operations)
+	if err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidValue", err)
+	}
+
+	return s.updateGroup(ctx, w, r, params["group_id"], gp)
+}
+
+// DeleteGroup removes all the members from the team. The team is not deleted along with its
+// entities and items as the data of the team is not owned by the IdP.
+func (s *SCIM) DeleteGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SCIM.DeleteGroup")
+	defer span.End()
+
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+	t, ok, err := s.team(ctx, d, params["group_id"])
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return scimError(ctx, w, http.StatusNotFound, "", errors.New("Group not found"))
+	}
+	if err := s.updateMembership(ctx, d, t.ID, scim.GroupPatch{ReplaceMembers: true, Members: []string{}}); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+func (s *SCIM) updateUser(ctx context.Context, w http.ResponseWriter, r *http.Request, memberID string, change func(existing *scim.User) error) error {
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+	it, ok, err := s.member(ctx, d, memberID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return scimError(ctx, w, http.StatusNotFound, "", errors.New("User not found"))
+	}
+	if err := s.load(ctx, d, []item.Item{it}); err != nil {
+		return err
+	}
+
+	existing := d.user(r, it)
+	wasActive := existing.IsActive()
+	if err := change(&existing); err != nil {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidValue", err)
+	}
+
+	email := existing.Email()
+	if email == "" {
+		return scimError(ctx, w, http.StatusBadRequest, "invalidValue", errors.New("userName should be the email of the member"))
+	}
+	name := existing.FullName()
+	if name == "" {
+		name = d.field(it, "name")
+	}
+	updates := map[string]interface{}{
+		"name":  name,
+		"email": email,
+	}
+
+	switch {
+	case wasActive && !existing.IsActive():
+		if usr, ok := d.users[d.field(it, "user_id")]; ok {
+			if err := scim.Deprovision(ctx, s.db, usr); err != nil {
+				return err
+			}
+			delete(d.users, usr.ID)
+		}
+		updates["user_id"] = nil
+	case !wasActive && existing.IsActive():
+		usr, err := s.activate(ctx, d.accountID, name, email)
+		if err != nil {
+			return err
+		}
+		if err := usr.UpdateMemberID(ctx, it.ID, s.db); err != nil {
+			return err
+		}
+		d.users[usr.ID] = usr
+		updates["user_id"] = usr.ID
+	}
+
+	if it, err = s.updateMember(ctx, d, it, updates); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, d.user(r, it), http.StatusOK)
+}
+
+func (s *SCIM) updateGroup(ctx context.Context, w http.ResponseWriter, r *http.Request, teamID string, gp scim.GroupPatch) error {
+	d, err := s.directory(ctx)
+	if err != nil {
+		return err
+	}
+	t, ok, err := s.team(ctx, d, teamID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return scimError(ctx, w, http.StatusNotFound, "", errors.New("Group not found"))
+	}
+
+	if gp.DisplayName != nil && *gp.DisplayName != "" && *gp.DisplayName != t.Name {
+		if err := team.UpdateName(ctx, s.db, d.accountID, t.ID, *gp.DisplayName, time.Now()); err != nil {
+			return err
+		}
+		t.Name = *gp.DisplayName
+		d.teams[t.ID] = t
+	}
+	if err := s.updateMembership(ctx, d, t.ID, gp); err != nil {
+		return err
+	}
+
+	g, err := s.group(ctx, d, r, t)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, g, http.StatusOK)
+}
+
+// updateMembership changes the teams of the members as per the patch. Only the members of the
+// team and the members named in the patch are read.
+func (s *SCIM) updateMembership(ctx context.Context, d *directory, teamID string, gp scim.GroupPatch) error {
+	current, err := s.teamMembers(ctx, d, teamID)
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]item.Item, len(current))
+	want := make(map[string]bool, len(current))
+	for _, it := range current {
+		members[it.ID] = it
+		want[it.ID] = !gp.ReplaceMembers
+	}
+	if gp.ReplaceMembers {
+		for _, id := range gp.Members {
+			want[id] = true
+		}
+	}
+	for _, id := range gp.Add {
+		want[id] = true
+	}
+	for _, id := range gp.Remove {
+		want[id] = false
+	}
+
+	for id, in := range want {
+		it, ok := members[id]
+		if !ok {
+			if !in {
+				continue
+			}
+			if it, ok, err = s.member(ctx, d, id); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+		}
+
+		teamIDs := d.teamIDs(it)
+		if util.Contains(teamIDs, teamID) == in {
+			continue
+		}
+		updated := make([]interface{}, 0, len(teamIDs)+1)
+		for _, tid := range teamIDs {
+			if tid != teamID {
+				updated = append(updated, tid)
+			}
+		}
+		if in {
+			updated = append(updated, teamID)
+		}
+		if _, err := s.updateMember(ctx, d, it, map[string]interface{}{"team_ids": updated}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateMember changes the fields of the member item keeping the rest of the fields as they are.
+func (s *SCIM) updateMember(ctx context.Context, d *directory, it item.Item, updates map[string]interface{}) (item.Item, error) {
+	fields := it.Fields()
+	for name, value := range updates {
+		if key, ok := d.keys[name]; ok {
+			fields[key] = value
+		}
+	}
+	return item.UpdateFields(ctx, s.db, d.accountID, d.owner.ID, it.ID, fields)
+}
+
+// activate gives the access to the account, reusing the user if the member still has one.
+func (s *SCIM) activate(ctx context.Context, accountID, name, email string) (user.User, error) {
+	usr, err := user.RetrieveUserByUniqIdentifier(ctx, accountID, email, "", s.db)
+	if err == nil {
+		return usr, nil
+	}
+	if err != user.ErrNotFound {
+		return user.User{}, errors.Wrapf(err, "retrival of user failed for reason other than not found")
+	}
+	return createNewVerifiedUser(ctx, accountID, name, email, []string{s.provisionRole(ctx, accountID)}, s.db)
+}
+
+// provisionRole is the default role of the identity provider if the account has one.
+func (s *SCIM) provisionRole(ctx context.Context, accountID string) string {
+	if p, err := sso.Retrieve(ctx, s.db, accountID); err == nil && p.DefaultRole != "" {
+		return p.DefaultRole
+	}
+	return auth.RoleMember
+}
+
+func (s *SCIM) directory(ctx context.Context) (*directory, error) {
+	apiKey, ok := ctx.Value(auth.APIKeyKey).(auth.APIKey)
+	if !ok {
+		return nil, web.NewRequestError(errors.New("SCIM needs the API key with the scim scope"), http.StatusUnauthorized)
+	}
+
+	owner, err := entity.RetrieveFixedEntity(ctx, s.db, apiKey.AccountID, "", entity.FixedEntityOwner)
+	if err != nil {
+		return nil, err
+	}
+	d := &directory{
+		accountID: apiKey.AccountID,
+		owner:     owner,
+		keys:      entity.NameKeyMap(owner.EasyFields()),
+		users:     make(map[string]user.User),
+		teams:     make(map[string]team.Team),
+	}
+	return d, nil
+}
+
+// member gets the member item. It is false when the id is not of a member of the account.
+func (s *SCIM) member(ctx context.Context, d *directory, id string) (item.Item, bool, error) {
+	it, err := item.Retrieve(ctx, d.accountID, d.owner.ID, id, s.db)
+	switch err {
+	case nil:
+		return it, true, nil
+	case item.ErrNotFound, item.ErrInvalidID:
+		return item.Item{}, false, nil
+	}
+	return item.Item{}, false, err
+}
+
+// filterMembers finds the members matching the filter sent by the client.
+func (s *SCIM) filterMembers(ctx context.Context, d *directory, f scim.Filter) ([]item.Item, error) {
+	switch strings.ToLower(f.Attribute) {
+	case "username", "emails.value":
+		return item.Matching(ctx, d.accountID, d.owner.ID, d.keys["email"], []string{strings.ToLower(f.Value)}, s.db)
+	case "id":
+		it, ok, err := s.member(ctx, d, f.Value)
+		if err != nil || !ok {
+			return []item.Item{}, err
+		}
+		return []item.Item{it}, nil
+	case "displayname":
+		matching, err := item.Matching(ctx, d.accountID, d.owner.ID, d.keys["name"], []string{strings.ToLower(f.Value)}, s.db)
+		if err != nil {
+			return nil, err
+		}
+		members := make([]item.Item, 0, len(matching))
+		for _, it := range matching {
+			if d.field(it, "name") == f.Value {
+				members = append(members, it)
+			}
+		}
+		return members, nil
+	}
+	return []item.Item{}, nil
+}
+
+// team gets the team of the account. It is false when the id is not of a team of the account.
+func (s *SCIM) team(ctx context.Context, d *directory, id string) (team.Team, bool, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return team.Team{}, false, nil
+	}
+	if err := s.loadTeams(ctx, d, []string{id}); err != nil {
+		return team.Team{}, false, err
+	}
+	t, ok := d.teams[id]
+	return t, ok, nil
+}
+
+// teamMembers returns the members having the team in their teams.
+func (s *SCIM) teamMembers(ctx context.Context, d *directory, teamID string) ([]item.Item, error) {
+	return item.Referring(ctx, d.accountID, d.owner.ID, d.keys["team_ids"], teamID, s.db)
+}
+
+// load reads the users and the teams of the members which are not read yet.
+func (s *SCIM) load(ctx context.Context, d *directory, members []item.Item) error {
+	userIDs := make([]string, 0)
+	teamIDs := make([]string, 0)
+	for _, it := range members {
+		if id := d.field(it, "user_id"); id != "" {
+			if _, ok := d.users[id]; !ok {
+				userIDs = append(userIDs, id)
+			}
+		}
+		teamIDs = append(teamIDs, d.teamIDs(it)...)
+	}
+
+	if len(userIDs) > 0 {
+		users, err := user.BulkRetrieveUsers(ctx, d.accountID, userIDs, s.db)
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			d.users[u.ID] = u
+		}
+	}
+	return s.loadTeams(ctx, d, teamIDs)
+}
+
+func (s *SCIM) loadTeams(ctx context.Context, d *directory, ids []string) error {
+	missing := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := d.teams[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	teams, err := team.BulkRetrieve(ctx, s.db, d.accountID, missing)
+	if err != nil {
+		return err
+	}
+	for _, t := range teams {
+		d.teams[t.ID] = t
+	}
+	return nil
+}
+
+// group reads the members of the team to serve it as the group.
+func (s *SCIM) group(ctx context.Context, d *directory, r *http.Request, t team.Team) (scim.Group, error) {
+	members, err := s.teamMembers(ctx, d, t.ID)
+	if err != nil {
+		return scim.Group{}, err
+	}
+	return d.group(r, t, members), nil
+}
+
+func (d *directory) field(it item.Item, name string) string {
+	if v, ok := it.Fields()[d.keys[name]].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (d *directory) teamIDs(it item.Item) []string {
+	ids := make([]string, 0)
+	if list, ok := it.Fields()[d.keys["team_ids"]].([]interface{}); ok {
+		for _, id := range list {
+			if s, ok := id.(string); ok {
+				ids = append(ids, s)
+			}
+		}
+	}
+	return ids
+}
+
+func (d *directory) user(r *http.Request, it item.Item) scim.User {
+	_, active := d.users[d.field(it, "user_id")]
+	email := d.field(it, "email")
+
+	groups := make([]scim.Ref, 0)
+	for _, id := range d.teamIDs(it) {
+		if t, ok := d.teams[id]; ok {
+			groups = append(groups, scim.Ref{Value: t.ID, Display: t.Name, Ref: scimURL(r, "Groups", t.ID)})
+		}
+	}
+
+	return scim.User{
+		Schemas:     []string{scim.SchemaUser},
+		ID:          it.ID,
+		UserName:    email,
+		DisplayName: d.field(it, "name"),
+		Name:        &scim.Name{Formatted: d.field(it, "name")},
+		Emails:      []scim.Email{{Value: email, Type: "work", Primary: true}},
+		Active:      &active,
+		Groups:      groups,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      it.CreatedAt,
+			LastModified: time.Unix(it.UpdatedAt, 0).UTC(),
+			Location:     scimURL(r, "Users", it.ID),
+		},
+	}
+}
+
+func (d *directory) group(r *http.Request, t team.Team, teamMembers []item.Item) scim.Group {
+	members := make([]scim.Ref, 0, len(teamMembers))
+	for _, it := range teamMembers {
+		members = append(members, scim.Ref{Value: it.ID, Display: d.field(it, "email"), Ref: scimURL(r, "Users", it.ID)})
+	}
+
+	return scim.Group{
+		Schemas:     []string{scim.SchemaGroup},
+		ID:          t.ID,
+		DisplayName: t.Name,
+		Members:     members,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      t.CreatedAt,
+			LastModified: time.Unix(t.UpdatedAt, 0).UTC(),
+			Location:     scimURL(r, "Groups", t.ID),
+		},
+	}
+}
+
+// scimRange is the offset and the count of the page asked with the startIndex (1-based) and the count.
+func scimRange(r *http.Request) (int, int) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if start < 1 {
+		start = 1
+	}
+	count, err := strconv.Atoi(r.URL.Query().Get("count"))
+	if err != nil || count < 0 {
+		count = scimPageSize
+	}
+	return start - 1, count
+}
+
+// scimBounds is the page asked within the n resources filtered in memory.
+func scimBounds(r *http.Request, n int) (int, int) {
+	from, count := scimRange(r)
+	if from > n {
+		from = n
+	}
+	to := from + count
+	if to > n {
+		to = n
+	}
+	return from, to
+}
+
+// scimList responds the page of the resources out of the total.
+func scimList(ctx context.Context, w http.ResponseWriter, r *http.Request, page interface{}, total int) error {
+	from, _ := scimRange(r)
+
+	var n int
+	switch res := page.(type) {
+	case []scim.User:
+		n = len(res)
+	case []scim.Group:
+		n = len(res)
+	}
+
+	lr := scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   from + 1,
+		ItemsPerPage: n,
+		Resources:    page,
+	}
+	return web.Respond(ctx, w, lr, http.StatusOK)
+}
+
+// scimError responds the error in the format expected by the SCIM clients.
+func scimError(ctx context.Context, w http.ResponseWriter, status int, scimType string, err error) error {
+	e := scim.Error{
+		Schemas:  []string{scim.SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   err.Error(),
+	}
+	return web.Respond(ctx, w, e, status)
+}
+
+func scimURL(r *http.Request, resource, id string) string {
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/scim/v2/%s/%s", scheme, r.Host, resource, id)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/sla"
+	"go.opencensus.io/trace"
+)
+
+// SLA represents the SLA policies of the entities and the business hours calendars of the account.
+type SLA struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// ListPolicies returns the SLA policies of the entity in the order of evaluation.
+func (s *SLA) ListPolicies(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.ListPolicies")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, s.db)
+	policies, err := sla.ListPolicies(ctx, s.db, accountID, entityID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, policies, http.StatusOK)
+}
+
+// CreatePolicy adds the SLA policy and the sla status field to the entity.
+func (s *SLA) CreatePolicy(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.CreatePolicy")
+	defer span.End()
+
+	var np sla.NewPolicy
+	if err := web.Decode(r, &np); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	np.AccountID, np.EntityID, _ = takeAEI(ctx, params, s.db)
+	e, err := entity.Retrieve(ctx, np.AccountID, np.EntityID, s.db, s.sdb)
+	if err != nil {
+		return err
+	}
+
+	p, err := sla.CreatePolicy(ctx, s.db, np, time.Now())
+	if err != nil {
+		return errors.Wrapf(err, "SLA policy: %+v", &np)
+	}
+
+	if err := sla.EnsureField(ctx, s.db, s.sdb, e); err != nil {
+		return errors.Wrapf(err, "adding the sla field to the entity %s", e.ID)
+	}
+	return web.Respond(ctx, w, p, http.StatusCreated)
+}
+
+// DeletePolicy removes the SLA policy along with its timers.
+func (s *SLA) DeletePolicy(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.DeletePolicy")
+	defer span.End()
+
+	if err := sla.DeletePolicy(ctx, s.db, params["account_id"], params["policy_id"]); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ListCalendars returns the business hours calendars of the account.
+func (s *SLA) ListCalendars(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.ListCalendars")
+	defer span.End()
+
+	calendars, err := sla.ListCalendars(ctx, s.db, params["account_id"])
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, calendars, http.StatusOK)
+}
+
+// CreateCalendar adds the business hours calendar with its holidays.
+func (s *SLA) CreateCalendar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.CreateCalendar")
+	defer span.End()
+
+	var nc sla.NewCalendar
+	if err := web.Decode(r, &nc); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	c, err := sla.CreateCalendar(ctx, s.db, params["account_id"], nc, time.Now())
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	return web.Respond(ctx, w, c, http.StatusCreated)
+}
+
+// DeleteCalendar removes the calendar. The policies using it fall back to 24x7.
+func (s *SLA) DeleteCalendar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.DeleteCalendar")
+	defer span.End()
+
+	if err := sla.DeleteCalendar(ctx, s.db, params["account_id"], params["calendar_id"]); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// ItemTimers returns the SLA timers of the item.
+func (s *SLA) ItemTimers(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SLA.ItemTimers")
+	defer span.End()
+
+	timers, err := sla.ItemTimers(ctx, s.db, params["account_id"], params["item_id"])
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, timers, http.StatusOK)
+}
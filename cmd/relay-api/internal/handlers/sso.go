@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/sso"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+const (
+	// ssoStateTTL is the time in seconds given to the member to finish the sign in at the IdP.
+	ssoStateTTL = 600
+	// ssoCodeTTL is the time in seconds given to the frontend to exchange the code for the token.
+	ssoCodeTTL = 60
+)
+
+// ErrSSOEnforced is used when the member signs in without the identity provider of the account which enforces it.
+var ErrSSOEnforced = web.NewRequestError(
+	errors.New("sso_enforced"), // value used in the UI dont change the string message.
+	http.StatusForbidden,
+)
+
+// SSO represents the single sign on through the identity provider of the account.
+type SSO struct {
+	db            *sqlx.DB
+	sdb           *database.SecDB
+	authenticator *auth.Authenticator
+}
+
+// ViewModelSSO is the identity provider along with the urls to be registered at the IdP.
+type ViewModelSSO struct {
+	sso.Provider
+	RoleMappings map[string]string `json:"role_mappings"`
+	HasSecret    bool              `json:"has_secret"`
+	RedirectURL  string            `json:"redirect_url"`
+	ACSURL       string            `json:"acs_url"`
+	SPEntityID   string            `json:"sp_entity_id"`
+}
+
+// ssoState is kept until the IdP sends the member back.
+type ssoState struct {
+	AccountID string `json:"account_id"`
+	Nonce     string `json:"nonce,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Retrieve returns the identity provider of the account.
+func (s *SSO) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.Retrieve")
+	defer span.End()
+
+	p, err := sso.Retrieve(ctx, s.db, params["account_id"])
+	if err != nil {
+		if err == sso.ErrNotFound {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web.Respond(ctx, w, createViewModelSSO(r, p), http.StatusOK)
+}
+
+// Save configures the identity provider of the account.
+func (s *SSO) Save(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.Save")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var np sso.NewProvider
+	if err := web.Decode(r, &np); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	p, err := sso.Save(ctx, s.db, params["account_id"], np, v.Now)
+	if err != nil {
+		if errors.Cause(err) == sso.ErrInvalidProvider {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+
+	return web.Respond(ctx, w, createViewModelSSO(r, p), http.StatusOK)
+}
+
+// Delete removes the identity provider of the account.
+func (s *SSO) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.Delete")
+	defer span.End()
+
+	if err := sso.Delete(ctx, s.db, params["account_id"]); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
+
+// Login returns the url of the IdP where the frontend sends the member to sign in.
+func (s *SSO) Login(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.Login")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	accountID := params["account_id"]
+	p, err := sso.Retrieve(ctx, s.db, accountID)
+	if err != nil {
+		if err == sso.ErrNotFound {
+			return web.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	key, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	state := ssoState{AccountID: accountID}
+	var redirectURL string
+	switch p.Type {
+	case sso.TypeOIDC:
+		state.Nonce, err = auth.GenerateRandomToken(16)
+		if err != nil {
+			return err
+		}
+		redirectURL, err = sso.OIDCAuthURL(ctx, p, ssoURL(r, accountID, "oidc/callback"), key, state.Nonce)
+		if err != nil {
+			return web.NewRequestError(err, http.StatusBadGateway)
+		}
+	case sso.TypeSAML:
+		req, err := sso.SAMLAuthRequest(p, ssoURL(r, accountID, "saml"), ssoURL(r, accountID, "saml/acs"), key, v.Now)
+		if err != nil {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		state.RequestID = req.ID
+		redirectURL = req.URL
+	}
+
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := s.sdb.SetAuthChallenge(key, encoded, ssoStateTTL); err != nil {
+		return errors.Wrap(err, "saving sso state")
+	}
+
+	return web.Respond(ctx, w, map[string]string{"redirect_url": redirectURL}, http.StatusOK)
+}
+
+// OIDCCallback receives the member back from the OIDC provider with the authorization code.
+func (s *SSO) OIDCCallback(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.OIDCCallback")
+	defer span.End()
+
+	accountID := params["account_id"]
+	if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+		return web.NewRequestError(errors.Errorf("identity provider declined the sign in: %s", errMsg), http.StatusUnauthorized)
+	}
+
+	state, err := s.popState(accountID, r.URL.Query().Get("state"))
+	if err != nil {
+		return err
+	}
+
+	p, err := sso.Retrieve(ctx, s.db, accountID)
+	if err != nil || p.Type != sso.TypeOIDC {
+		return web.NewRequestError(sso.ErrNotFound, http.StatusNotFound)
+	}
+
+	identity, err := sso.OIDCExchange(ctx, p, ssoURL(r, accountID, "oidc/callback"), r.URL.Query().Get("code"), state.Nonce)
+	if err != nil {
+		log.Printf("***> oidc sign in failed for account: %s error: %v\n", accountID, err)
+		return web.NewRequestError(sso.ErrInvalidResponse, http.StatusUnauthorized)
+	}
+
+	return s.signIn(ctx, w, r, p, identity)
+}
+
+// SAMLACS is the assertion consumer service where the SAML provider posts the signed response.
+func (s *SSO) SAMLACS(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.SAMLACS")
+	defer span.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	accountID := params["account_id"]
+	if err := r.ParseForm(); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	state, err := s.popState(accountID, r.PostForm.Get("RelayState"))
+	if err != nil {
+		return err
+	}
+
+	p, err := sso.Retrieve(ctx, s.db, accountID)
+	if err != nil || p.Type != sso.TypeSAML {
+		return web.NewRequestError(sso.ErrNotFound, http.StatusNotFound)
+	}
+
+	identity, err := sso.SAMLIdentity(p, ssoURL(r, accountID, "saml"), ssoURL(r, accountID, "saml/acs"), state.RequestID, r.PostForm.Get("SAMLResponse"), v.Now)
+	if err != nil {
+		log.Printf("***> saml sign in failed for account: %s error: %v\n", accountID, err)
+		return web.NewRequestError(sso.ErrInvalidResponse, http.StatusUnauthorized)
+	}
+
+	return s.signIn(ctx, w, r, p, identity)
+}
+
+// Token exchanges the one time code given to the frontend after the sign in for the token.
+func (s *SSO) Token(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.SSO.Token")
+	defer span.End()
+
+	encoded, err := s.sdb.PopAuthChallenge(r.URL.Query().Get("code"))
+	if err != nil || encoded == "" {
+		return web.NewRequestError(errors.New("Sign in code expired or not found"), http.StatusUnauthorized)
+	}
+
+	var tkn UserToken
+	if err := json.Unmarshal([]byte(encoded), &tkn); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// signIn provisions the member on the first sign in, syncs the role with the groups and
+// sends the member to the app with the code which can be exchanged for the token.
+func (s *SSO) signIn(ctx context.Context, w http.ResponseWriter, r *http.Request, p sso.Provider, identity sso.Identity) error {
+	if _, err := mail.ParseAddress(identity.Email); err != nil {
+		return web.NewRequestError(sso.ErrInvalidResponse, http.StatusUnauthorized)
+	}
+
+	acc, err := account.Retrieve(ctx, s.db, p.AccountID)
+	if err != nil {
+		return err
+	}
+
+	role, mapped := p.Role(identity.Groups)
+	usr, err := user.RetrieveUserByUniqIdentifier(ctx, p.AccountID, identity.Email, "", s.db)
+	if err == user.ErrNotFound {
+		if err := s.provision(ctx, p.AccountID, identity, role); err != nil {
+			return errors.Wrap(err, "provisioning member from the identity provider")
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "retrival of user failed for reason other than not found")
+	} else if mapped && (len(usr.Roles) != 1 || usr.Roles[0] != role) {
+		if err := user.UpdateRoles(ctx, s.db, usr.ID, []string{role}, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	tkn, err := generateUserJWT(ctx, p.AccountID, identity.Email, false, time.Now(), s.authenticator, s.db)
+	if err != nil {
+		return err
+	}
+
+	code, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(tkn)
+	if err != nil {
+		return err
+	}
+	if err := s.sdb.SetAuthChallenge(code, encoded, ssoCodeTTL); err != nil {
+		return errors.Wrap(err, "saving sso code")
+	}
+
+	return web.Redirect(ctx, w, r, fmt.Sprintf("https://%s/home/sso?code=%s", acc.Domain, code))
+}
+
+// provision creates the user and the member item in the owners entity. The invitation
+// mail is not sent as the member is already signed in.
+func (s *SSO) provision(ctx context.Context, accountID string, identity sso.Identity, role string) error {
+	name := identity.Name
+	if name == "" {
+		name = util.NameInEmail(identity.Email)
+	}
+
+	usr, err := createNewVerifiedUser(ctx, accountID, name, identity.Email, []string{role}, s.db)
+	if err != nil {
+		return err
+	}
+
+	ownerEntity, err := entity.RetrieveFixedEntity(ctx, s.db, accountID, "", entity.FixedEntityOwner)
+	if err != nil {
+		return err
+	}
+	vm := ViewModelMember{
+		UserID: usr.ID,
+		Name:   name,
+		Email:  identity.Email,
+		Teams:  []ViewTeam{},
+		Role:   []interface{}{role},
+	}
+	ni := item.NewItem{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		EntityID:  ownerEntity.ID,
+		UserID:    &usr.ID,
+		Fields:    recreateFields(vm, entity.NameKeyMap(ownerEntity.EasyFields())),
+	}
+	it, err := item.Create(ctx, s.db, ni, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return usr.UpdateMemberID(ctx, it.ID, s.db)
+}
+
+func (s *SSO) popState(accountID, key string) (ssoState, error) {
+	var state ssoState
+	encoded, err := s.sdb.PopAuthChallenge(key)
+	if err != nil || encoded == "" {
+		return state, web.NewRequestError(errors.New("Sign in expired. Please try again"), http.StatusUnauthorized)
+	}
+	if err := json.Unmarshal([]byte(encoded), &state); err != nil {
+		return state, err
+	}
+	if state.AccountID != accountID {
+		return state, web.NewRequestError(errors.New("Sign in is not for this account"), http.StatusUnauthorized)
+	}
+	return state, nil
+}
+
+// ssoEnforced returns ErrSSOEnforced if the account accepts the sign in only through the identity provider.
+func ssoEnforced(ctx context.Context, accountID string, db *sqlx.DB) error {
+	enforced, err := sso.Enforced(ctx, db, accountID)
+	if err != nil {
+		return err
+	}
+	if enforced {
+		return ErrSSOEnforced
+	}
+	return nil
+}
+
+// ssoURL is the public url of the sso endpoints of the account which are registered at the IdP.
+func ssoURL(r *http.Request, accountID, path string) string {
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v1/sso/%s/%s", scheme, r.Host, accountID, path)
+}
+
+func createViewModelSSO(r *http.Request, p sso.Provider) ViewModelSSO {
+	return ViewModelSSO{
+		Provider:     p,
+		RoleMappings: p.RoleMappings(),
+		HasSecret:    p.ClientSecret != "",
+		RedirectURL:  ssoURL(r, p.AccountID, "oidc/callback"),
+		ACSURL:       ssoURL(r, p.AccountID, "saml/acs"),
+		SPEntityID:   ssoURL(r, p.AccountID, "saml"),
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/reference"
+	"gitlab.com/vjsideprojects/relay/internal/timeline"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// maxWindow bounds the window listed at once, the recurring items are expanded for each day in it.
+const maxWindow = 366 * 24 * time.Hour
+
+// windowLimit bounds the items listed in the window. The timeline is marked truncated when there are more.
+const windowLimit = 1000
+
+// Timeline places the items listed in the window on the calendar or the timeline.
+type Timeline struct {
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Keys        timeline.Keys         `json:"keys"`
+	Occurrences []timeline.Occurrence `json:"occurrences"`
+	Edges       []timeline.Edge       `json:"edges"`
+	Truncated   bool                  `json:"truncated"`
+}
+
+// Schedule is the new start and end of the item dragged on the calendar or the timeline.
+type Schedule struct {
+	Start string `json:"start" validate:"required"`
+	End   string `json:"end"`
+}
+
+// Schedule moves the start and the end of the item together. The workflows are triggered once for the move.
+func (i *Item) Schedule(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Item.Schedule")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	accountID, entityID, itemID := takeAEI(ctx, params, i.db)
+	var sc Schedule
+	if err := web.Decode(r, &sc); err != nil {
+		return errors.Wrap(err, "")
+	}
+
+	e, err := entity.Retrieve(ctx, accountID, entityID, i.db, i.sdb)
+	if err != nil {
+		return err
+	}
+	k := timeline.EntityKeys(e)
+	if k.Start == "" {
+		return web.NewRequestError(errors.New("the entity has no dates to schedule with"), http.StatusBadRequest)
+	}
+
+	existingItem, err := item.Retrieve(ctx, accountID, entityID, itemID, i.db)
+	if err != nil {
+		return errors.Wrapf(err, "error retriving item")
+	}
+	if err := validateMyselfWithOwner(ctx, accountID, entityID, existingItem, i.db, i.sdb); err != nil {
+		return err
+	}
+
+	changed := map[string]interface{}{k.Start: sc.Start}
+	if sc.End != "" {
+		if k.End == "" {
+			return scheduleError("end", "the entity has no end")
+		}
+		changed[k.End] = sc.End
+	}
+	if err := validateItemValues(ctx, accountID, entityID, changed, nil, false, i.db, i.sdb); err != nil {
+		return err
+	}
+	start, _ := timeline.ValueTime(sc.Start)
+	if end, ok := timeline.ValueTime(sc.End); ok && end.Before(start) {
+		return scheduleError("end", "should not be before the start")
+	}
+
+	fields := existingItem.Fields()
+	if fields == nil {
+		fields = make(map[string]interface{}, 0)
+	}
+	for key, val := range changed {
+		fields[key] = val
+	}
+	it, err := item.UpdateFields(ctx, i.db, accountID, entityID, itemID, fields)
+	if err != nil {
+		return errors.Wrapf(err, "error when scheduling item %s", itemID)
+	}
+	if it.State == item.StateDefault {
+		go job.NewJob(i.db, i.sdb, i.authenticator.FireBaseAdminSDK).Stream(stream.NewUpdateItemMessage(ctx, i.db, accountID, currentUserID, entityID, itemID, it.Fields(), existingItem.Fields()))
+	}
+	return web.Respond(ctx, w, createViewModelItem(it, nil), http.StatusOK)
+}
+
+// windowWrapper lists the items overlapping the window with the recurring items expanded and the
+// dependencies between them.
+func (s Segmenter) windowWrapper(ctx context.Context, accountID string, e entity.Entity, fields []entity.Field, from, to time.Time, db *sqlx.DB, sdb *database.SecDB) ([]ViewModelItem, *Timeline, error) {
+	k := timeline.EntityKeys(e)
+	if k.Start == "" {
+		return nil, nil, web.NewRequestError(errors.New("the entity has no dates to list in the window"), http.StatusBadRequest)
+	}
+
+	conditionFields, err := makeConditionsFromExp(ctx, accountID, e.ID, s.exp, db, sdb)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !auth.God(ctx) {
+		conditionFields = append(conditionFields, publicRecordsOnly())
+	}
+	where := ""
+	if wh := dbservice.WhBuilder(conditionFields); len(wh) > 0 {
+		where = fmt.Sprintf("AND %s", strings.Join(wh, " AND "))
+	}
+
+	items, err := item.Window(ctx, accountID, e.ID, k.Start, k.End, k.Recurrence, from, to, where, windowLimit+1, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tl := &Timeline{From: from, To: to, Keys: k, Occurrences: make([]timeline.Occurrence, 0)}
+	if len(items) > windowLimit {
+		// the client narrows the window to see the rest.
+		items, tl.Truncated = items[:windowLimit], true
+	}
+	placed := make([]item.Item, 0, len(items))
+	dependsOn := make(map[string][]string, 0)
+	for _, it := range items {
+		itemFields := it.Fields()
+		occs, err := timeline.Expand(it.ID, itemFields, k, from, to)
+		if err != nil {
+			// the item with the broken rule is placed once.
+			log.Printf("***> recurrence of the item %s not expanded. error: %v\n", it.ID, err)
+			occs, _ = timeline.Expand(it.ID, itemFields, timeline.Keys{Start: k.Start, End: k.End}, from, to)
+		}
+		if len(occs) == 0 {
+			continue
+		}
+		tl.Occurrences = append(tl.Occurrences, occs...)
+		placed = append(placed, it)
+		dependsOn[it.ID] = timeline.DependsOn(itemFields, k)
+	}
+	timeline.Sort(tl.Occurrences)
+	tl.Edges = timeline.Edges(dependsOn)
+
+	userIDs := make(map[string]bool, 0)
+	for _, it := range placed {
+		userIDs[*it.UserID] = true
+	}
+	uMap, _ := userMap(ctx, accountID, userIDs, db)
+	viewModelItems := itemResponse(placed, uMap, fields)
+	reference.UpdateReferenceFields(ctx, accountID, e.ID, fields, placed, map[string]interface{}{}, db, sdb, job.NewJabEngine())
+	return viewModelItems, tl, nil
+}
+
+// listWindow parses the window of the calendar and the timeline modes. Both ends are needed for the window.
+func listWindow(r *http.Request) (time.Time, time.Time, bool, error) {
+	fromStr, toStr := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if fromStr == "" && toStr == "" {
+		return time.Time{}, time.Time{}, false, nil
+	}
+	from, err := entity.ParseValueTime(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, scheduleError("from", "should be a date or a date-time")
+	}
+	to, err := entity.ParseValueTime(toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, false, scheduleError("to", "should be a date or a date-time")
+	}
+	if !to.After(from) || to.Sub(from) > maxWindow {
+		return time.Time{}, time.Time{}, false, scheduleError("to", "should be after the from and within a year of it")
+	}
+	return from, to, true, nil
+}
+
+func scheduleError(field, reason string) error {
+	return &web.Error{
+		Err:    errors.New("field validation error"),
+		Status: http.StatusBadRequest,
+		Fields: []web.FieldError{{Field: field, Error: reason}},
+	}
+}
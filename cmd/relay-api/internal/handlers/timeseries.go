@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -20,6 +21,8 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/report"
+	"gitlab.com/vjsideprojects/relay/internal/sla"
 	"gitlab.com/vjsideprojects/relay/internal/timeseries"
 	"gitlab.com/vjsideprojects/relay/internal/user"
 	"go.opencensus.io/trace"
@@ -48,11 +51,20 @@ func (ts *Timeseries) Create(ctx context.Context, w http.ResponseWriter, r *http
 		return errors.Wrap(err, "token invalid")
 	}
 
-	it, err := event.Process(ctx, accountID, ne, log, ts.db)
+	res, items, err := event.Ingest(ctx, ts.db, accountID, []event.NewEvent{ne}, time.Now())
 	if err != nil {
+		log.Println("processEvent : errored : save event")
 		return errors.Wrapf(err, "process failed")
 	}
+	if len(res.Rejected) > 0 {
+		return web.NewRequestError(errors.New(res.Rejected[0].Error), http.StatusBadRequest)
+	}
 
+	// the rolled up events and the duplicates are not kept as the items
+	if len(items) == 0 {
+		return web.Respond(ctx, w, res, http.StatusAccepted)
+	}
+	it := items[0]
 	// log.Printf("processEvent : started : sqs streaming -- %+v", it)
 	go job.NewJob(ts.db, ts.sdb, ts.authenticator.FireBaseAdminSDK).Stream(stream.NewCreteItemMessage(ctx, ts.db, accountID, *it.UserID, it.EntityID, it.ID, nil))
 	//go job.NewJob(ts.db, ts.sdb, ts.authenticator.FireBaseAdminSDK).Stream(stream.NewEventItemMessage(ctx, ts.db, accountID, *it.UserID, it.EntityID, it.ID))
@@ -67,8 +79,6 @@ func (ts *Timeseries) List(ctx context.Context, w http.ResponseWriter, r *http.R
 	accountID := params["account_id"]
 	teamID := params["team_id"]
 	entityID := params["entity_id"]
-	zone, _ := util.ParseTime(r.URL.Query().Get("zone"))
-	loc := time.FixedZone(zone.Zone())
 	exp := r.URL.Query().Get("exp")
 	eagerLoad, _ := strconv.ParseBool(r.URL.Query().Get("eager_load")) // blue print
 	baseEntityID := r.URL.Query().Get("be")
@@ -84,8 +94,12 @@ func (ts *Timeseries) List(ctx context.Context, w http.ResponseWriter, r *http.R
 	// * base_entity_id should be NoEntityID
 	// * entity_id should be the actual entity_id
 
+	cal, err := rangeSettings(ctx, r, accountID, ts.db)
+	if err != nil {
+		return err
+	}
+
 	var charts []chart.Chart
-	var err error
 	if util.NotEmpty(entityID) { // handles main page & sub page charts
 		charts, err = chart.ListByEntityID(ctx, accountID, teamID, entityID, ts.db)
 		if err != nil {
@@ -105,7 +119,7 @@ func (ts *Timeseries) List(ctx context.Context, w http.ResponseWriter, r *http.R
 	}
 
 	//populate the value for charts if the charts with grid type exists...
-	eagerLoader, err := grids(ctx, charts, exp, loc, ts.db, ts.sdb)
+	eagerLoader, err := grids(ctx, charts, exp, cal, ts.db, ts.sdb)
 	if err != nil {
 		return err
 	}
@@ -127,8 +141,8 @@ func (ts *Timeseries) List(ctx context.Context, w http.ResponseWriter, r *http.R
 
 			//overloading the existing chart exp with the additional expression
 			wholeExp := util.AddExpression(exp, ch.GetExp())
-			stTime, endTime, _ := timeseries.Duration(ch.Duration)
-			series, err := loadSeries(ctx, ch, wholeExp, stTime, endTime, ts.db, ts.sdb)
+			rg := rangeOf(ch.Duration, time.Now(), cal)
+			series, err := loadSeries(ctx, ch, wholeExp, rg.Start, rg.End, ts.db, ts.sdb)
 			if err != nil {
 				return err
 			}
@@ -147,8 +161,6 @@ func (ts *Timeseries) Chart(ctx context.Context, w http.ResponseWriter, r *http.
 	defer span.End()
 
 	exp := r.URL.Query().Get("exp")
-	duration := r.URL.Query().Get("duration")
-	zone, _ := util.ParseTime(r.URL.Query().Get("zone"))
 	baseEntityID := r.URL.Query().Get("be")
 	baseItemID := r.URL.Query().Get("bi")
 
@@ -160,52 +172,107 @@ func (ts *Timeseries) Chart(ctx context.Context, w http.ResponseWriter, r *http.
 	//overloading the existing chart exp with the additional expression
 	exp = util.AddExpression(exp, ch.GetExp())
 
-	if duration != "undefined" && duration != "" {
-		ch.Duration = duration
+	cal, err := rangeSettings(ctx, r, ch.AccountID, ts.db)
+	if err != nil {
+		return err
+	}
+	rg, err := requestRange(r, ch.Duration, time.Now(), cal)
+	if err != nil {
+		return err
 	}
-	startTime, endTime, lastStart := timeseries.DurationWithZone(ch.Duration, time.FixedZone(zone.Zone()))
+	ch.Duration = rg.Name
 
 	var vmc VMChart
 	var series []timeseries.Timeseries
 	switch ch.GetDType() {
 	case string(chart.DTypeTimeseries):
-		series, err = timeseries.List(ctx, ch.AccountID, ch.EntityID, startTime, endTime, ts.db)
-		count, err := timeseries.Count(ctx, ch.AccountID, ch.EntityID, lastStart, startTime, ts.db)
+		series, err = timeseries.List(ctx, ch.AccountID, ch.EntityID, rg.Start, rg.End, ts.db)
+		if err != nil {
+			return err
+		}
+		count, err := timeseries.Count(ctx, ch.AccountID, ch.EntityID, rg.PrevStart, rg.PrevEnd, ts.db)
 		if err != nil {
 			return err
 		}
 		vmc = createViewModelChart(*ch, vmseries(series), len(series), change(len(series), count))
 	case string(chart.DTypeDefault):
-		series, err := loadCHSeries(ctx, *ch, exp, baseEntityID, baseItemID, startTime, endTime, ts.db, ts.sdb)
+		series, err := loadCHSeries(ctx, *ch, exp, baseEntityID, baseItemID, rg.Start, rg.End, ts.db, ts.sdb)
 		if err != nil {
 			return err
 		}
 		vmc = createViewModelChartNoChange(*ch, series, 0)
+	case string(chart.DTypeSLA):
+		metric := ch.GetField()
+		if metric == "" {
+			metric = sla.MetricResolution
+		}
+		counts, err := sla.Compliance(ctx, ts.db, ch.AccountID, ch.EntityID, metric, rg.Start, rg.End)
+		if err != nil {
+			return err
+		}
+		vmc = createViewModelChartNoChange(*ch, slaseries(counts), compliance(counts))
+	case string(chart.DTypeReport):
+		rp, err := report.FromChart(*ch)
+		if err != nil {
+			return err
+		}
+		conditionFields, err := makeConditionsFromExp(ctx, ch.AccountID, ch.EntityID, util.AddExpression(exp, rp.Exp), ts.db, ts.sdb)
+		if err != nil {
+			return err
+		}
+		res, err := report.Run(ctx, ts.db, ts.sdb, ch.AccountID, rp, conditionFields, cal)
+		if err != nil {
+			return err
+		}
+		vmc = createViewModelChartNoChange(*ch, reportseries(res), len(res.Rows))
+		vmc.Report = &ReportResult{Result: res, Pivot: res.Pivot(len(rp.Rows))}
 	}
 	if err != nil {
 		return err
 	}
+	vmc.Range = &rg
 
 	return web.Respond(ctx, w, vmc, http.StatusOK)
 }
 
+// Range resolves the time range in the calendar of the account and the current user along with the
+// previous period it is compared with.
+func (ts *Timeseries) Range(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Timeseries.Range")
+	defer span.End()
+
+	cal, err := rangeSettings(ctx, r, params["account_id"], ts.db)
+	if err != nil {
+		return err
+	}
+	rg, err := requestRange(r, "", time.Now(), cal)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, rg, http.StatusOK)
+}
+
 func (ts *Timeseries) OnMe(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
 	ctx, span := trace.StartSpan(ctx, "handlers.Entity.OnMe")
 	defer span.End()
 
-	zone, _ := util.ParseTime(r.URL.Query().Get("zone"))
 	accountID := params["account_id"]
 
 	charts, err := chart.ListByDashID(ctx, accountID, "", "", ts.db)
 	if err != nil {
 		return err
 	}
+	cal, err := rangeSettings(ctx, r, accountID, ts.db)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
 
 	cards := make([]VMChart, 0)
 	for _, ch := range charts {
 		if ch.Type == string(chart.TypeCard) {
-			startTime, endTime, _ := timeseries.DurationWithZone(ch.Duration, time.FixedZone(zone.Zone()))
-			series, err := loadSeries(ctx, ch, ch.GetExp(), startTime, endTime, ts.db, ts.sdb)
+			rg := rangeOf(ch.Duration, now, cal)
+			series, err := loadSeries(ctx, ch, ch.GetExp(), rg.Start, rg.End, ts.db, ts.sdb)
 			if err != nil {
 				return err
 			}
@@ -245,6 +312,38 @@ func vmseriesFromMap(m map[string]int, f entity.Field) []Series {
 	return vmseries
 }
 
+// reportseries are the groups of the report with the first measure as the count.
+func reportseries(res report.Result) []Series {
+	series := make([]Series, 0, len(res.Rows))
+	for _, r := range res.Rows {
+		label := strings.Join(r.Keys, " / ")
+		s := Series{ID: label, Label: label}
+		if len(r.Values) > 0 && r.Values[0] != nil {
+			s.Count = int(*r.Values[0])
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+func slaseries(counts map[string]int) []Series {
+	return []Series{
+		createPartialVMSeries(sla.StateMet, "Met", "#46b17b", entity.FuExpNone, counts[sla.StateMet]),
+		createPartialVMSeries(sla.StateBreached, "Breached", "#e05260", entity.FuExpNone, counts[sla.StateBreached]),
+		createPartialVMSeries(sla.StateRunning, "Running", "#4a90e2", entity.FuExpNone, counts[sla.StateRunning]),
+		createPartialVMSeries(sla.StatePaused, "Paused", "#eaeaea", entity.FuExpNone, counts[sla.StatePaused]),
+	}
+}
+
+// compliance returns the percentage of the closed timers met within the target.
+func compliance(counts map[string]int) int {
+	closed := counts[sla.StateMet] + counts[sla.StateBreached]
+	if closed == 0 {
+		return 100
+	}
+	return counts[sla.StateMet] * 100 / closed
+}
+
 func strValue(v interface{}) string {
 	if v != nil {
 		return v.(string)
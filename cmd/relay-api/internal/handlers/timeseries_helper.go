@@ -3,18 +3,24 @@ package handlers
 import (
 	"context"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"gitlab.com/vjsideprojects/relay/internal/account"
 	"gitlab.com/vjsideprojects/relay/internal/chart"
 	"gitlab.com/vjsideprojects/relay/internal/entity"
 	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/metric"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
 	"gitlab.com/vjsideprojects/relay/internal/reference"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
 	"gitlab.com/vjsideprojects/relay/internal/timeseries"
+	"gitlab.com/vjsideprojects/relay/internal/user"
 )
 
 func loadSeries(ctx context.Context, ch chart.Chart, exp string, stTime, endTime time.Time, db *sqlx.DB, sdb *database.SecDB) ([]Series, error) {
@@ -48,6 +54,7 @@ func loadCHSeries(ctx context.Context, ch chart.Chart, exp, baseEntityID, baseIt
 	}
 
 	var filterByField entity.Field
+	var dateKey string
 	for _, f := range fields {
 		if f.Name == fieldName {
 			filterByField = f
@@ -72,10 +79,15 @@ func loadCHSeries(ctx context.Context, ch chart.Chart, exp, baseEntityID, baseIt
 				reference.ChoicesMaker(&filterByField, "", reference.NodeActorChoices(nodes))
 			}
 		} else if f.Name == fieldDate {
+			dateKey = f.Key
 			conditionFields = append(conditionFields, timeRange(f.Key, stTime, endTime))
 		}
 	}
 
+	if counts, ok := metricCounts(ctx, ch, exp, source, baseItemID, filterByField, dateKey, stTime, endTime, db); ok {
+		return vmseriesFromMap(counts, filterByField), nil
+	}
+
 	// log.Printf("chart conditionFields---%+v", conditionFields)
 
 	useDB := account.UseDB(ctx, db, ch.AccountID)
@@ -98,6 +110,44 @@ func loadCHSeries(ctx context.Context, ch chart.Chart, exp, baseEntityID, baseIt
 	return vmseriesFromMap(counts(counters), filterByField), nil
 }
 
+// metricCounts reads the counts of the chart from the daily metrics when they can answer it. The charts filtered
+// by the expression, the source or the base item, grouped other than by the field or by none, or dated other
+// than by the creation of the items are counted live.
+func metricCounts(ctx context.Context, ch chart.Chart, exp, source, baseItemID string, groupBy entity.Field, dateKey string, stTime, endTime time.Time, db *sqlx.DB) (map[string]int, bool) {
+	if exp != "" || (source != entity.NoEntityID && source != ch.EntityID) || util.NotEmpty(baseItemID) {
+		return nil, false
+	}
+	key := metric.KeyAll
+	switch ch.GetGroupByLogic() {
+	case string(chart.GroupLogicNone):
+	case string(chart.GroupLogicField):
+		if groupBy.IsNode() || len(metric.Tracked([]entity.Field{groupBy})) == 0 {
+			return nil, false
+		}
+		key = groupBy.Key
+	default:
+		return nil, false
+	}
+	switch dateKey {
+	case "":
+		stTime, endTime = time.Time{}, time.Time{}
+	case "system_created_at":
+	default:
+		return nil, false
+	}
+
+	counts, ok, err := metric.Counts(ctx, db, ch.AccountID, ch.EntityID, key, stTime, endTime)
+	if err != nil {
+		log.Printf("***> unexpected error occurred when reading the metrics of the chart %s error: %v.\n continuing...", ch.ID, err)
+		return nil, false
+	}
+	if ok && key == metric.KeyAll {
+		// the live count without the group is the total count
+		return map[string]int{"total_count": counts[""]}, true
+	}
+	return counts, ok
+}
+
 func sum(ctx context.Context, ch chart.Chart, exp string, db *sqlx.DB, sdb *database.SecDB) ([]Series, error) {
 	e, err := entity.Retrieve(ctx, ch.AccountID, ch.EntityID, db, sdb)
 	if err != nil {
@@ -107,7 +157,7 @@ func sum(ctx context.Context, ch chart.Chart, exp string, db *sqlx.DB, sdb *data
 	source := ch.GetSource()
 	//groupedLogic := ch.GetGroupByLogic()
 	fieldName := ch.GetField()
-	startTime, endTime, _ := timeseries.Duration(ch.Duration)
+	rg := rangeOf(ch.Duration, time.Now(), timerange.Settings{})
 
 	conditionFields, err := makeConditionsFromExp(ctx, ch.AccountID, ch.EntityID, exp, db, sdb)
 	if err != nil {
@@ -132,7 +182,7 @@ func sum(ctx context.Context, ch chart.Chart, exp string, db *sqlx.DB, sdb *data
 		reference.ChoicesMaker(&filterByField, "", reference.ItemChoices(&filterByField, refItems, e.WhoKeyMap()))
 	}
 
-	conditionFields = append(conditionFields, timeRange("system_created_at", startTime, endTime))
+	conditionFields = append(conditionFields, timeRange("system_created_at", rg.Start, rg.End))
 	summers, err := dbservice.NewDBservice(dbservice.Spider, db, sdb).Sum(ctx, ch.AccountID, ch.EntityID, filterByField.Key, conditionFields)
 	if err != nil {
 		return nil, err
@@ -141,20 +191,22 @@ func sum(ctx context.Context, ch chart.Chart, exp string, db *sqlx.DB, sdb *data
 	return vmseriesFromMap(counts(summers), filterByField), nil
 }
 
-func grids(ctx context.Context, charts []chart.Chart, exp string, loc *time.Location, db *sqlx.DB, sdb *database.SecDB) (map[string]EagerLoader, error) {
+func grids(ctx context.Context, charts []chart.Chart, exp string, s timerange.Settings, db *sqlx.DB, sdb *database.SecDB) (map[string]EagerLoader, error) {
 	gridResMap := make(map[string]EagerLoader, 0)
+	now := time.Now()
 	var err error
 	for _, ch := range charts {
 		if ch.Type == string(chart.TypeGrid) {
+			rg := rangeOf(ch.Duration, now, s)
 			var newcount, oldcount int
 			switch ch.GetDType() {
 			case string(chart.DTypeDefault):
-				newcount, oldcount, err = gridDefault(ctx, ch.AccountID, ch.EntityID, ch.Duration, ch, loc, db, sdb)
+				newcount, oldcount, err = gridDefault(ctx, rg, ch, db, sdb)
 				if err != nil {
 					return nil, err
 				}
 			case string(chart.DTypeTimeseries):
-				newcount, oldcount, err = gridTimeseries(ctx, ch.AccountID, ch.EntityID, ch.Duration, loc, db)
+				newcount, oldcount, err = gridTimeseries(ctx, ch.AccountID, ch.EntityID, rg, db)
 				if err != nil {
 					return nil, err
 				}
@@ -170,23 +222,20 @@ func grids(ctx context.Context, charts []chart.Chart, exp string, loc *time.Loca
 	return gridResMap, nil
 }
 
-func gridTimeseries(ctx context.Context, accountID, entityID, duration string, loc *time.Location, db *sqlx.DB) (int, int, error) {
-	startTime, endTime, lastStart := timeseries.DurationWithZone(duration, loc)
-
-	countNew, err := timeseries.Count(ctx, accountID, entityID, startTime, endTime, db)
+func gridTimeseries(ctx context.Context, accountID, entityID string, rg timerange.Range, db *sqlx.DB) (int, int, error) {
+	countNew, err := timeseries.Count(ctx, accountID, entityID, rg.Start, rg.End, db)
 	if err != nil {
 		return 0, 0, err
 	}
-	countOld, err := timeseries.Count(ctx, accountID, entityID, lastStart, startTime, db)
+	countOld, err := timeseries.Count(ctx, accountID, entityID, rg.PrevStart, rg.PrevEnd, db)
 	if err != nil {
 		return 0, 0, err
 	}
 	return countNew, countOld, nil
 }
 
-func gridDefault(ctx context.Context, accountID, entityID, duration string, ch chart.Chart, loc *time.Location, db *sqlx.DB, sdb *database.SecDB) (int, int, error) {
-	stTime, endTime, lastStart := timeseries.DurationWithZone(duration, loc)
-	series, err := loadSeries(ctx, ch, ch.GetExp(), stTime, endTime, db, sdb)
+func gridDefault(ctx context.Context, rg timerange.Range, ch chart.Chart, db *sqlx.DB, sdb *database.SecDB) (int, int, error) {
+	series, err := loadSeries(ctx, ch, ch.GetExp(), rg.Start, rg.End, db, sdb)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -200,7 +249,7 @@ func gridDefault(ctx context.Context, accountID, entityID, duration string, ch c
 			return series[0].Count, 0, nil
 		}
 	case string(chart.CalcRate):
-		oldseries, err := loadSeries(ctx, ch, ch.GetExp(), lastStart, stTime, db, sdb)
+		oldseries, err := loadSeries(ctx, ch, ch.GetExp(), rg.PrevStart, rg.PrevEnd, db, sdb)
 		if err != nil {
 			return 0, 0, err
 		}
@@ -215,10 +264,55 @@ func gridDefault(ctx context.Context, accountID, entityID, duration string, ch c
 	return 0, 0, nil
 }
 
+// change is the percent the count changed by since the previous period.
 func change(count, oldCount int) int {
-	total := count + oldCount
-	if total == 0 {
-		return 0
+	if oldCount == 0 {
+		if count == 0 {
+			return 0
+		}
+		return 100
+	}
+	return (count - oldCount) * 100 / oldCount
+}
+
+// rangeSettings reads the calendar of the account and the current user. The zone of the request, such as
+// the one of the browser, is used when neither has the timezone.
+func rangeSettings(ctx context.Context, r *http.Request, accountID string, db *sqlx.DB) (timerange.Settings, error) {
+	zone, _ := util.ParseTime(r.URL.Query().Get("zone"))
+	userID, _ := user.RetrieveCurrentUserID(ctx)
+	return timerange.Load(ctx, db, accountID, userID, time.FixedZone(zone.Zone()))
+}
+
+// requestRange resolves the range the request asks for, the from and to of the request make the custom
+// range and the duration the named range. The charts saved with the range use it when the request has none.
+func requestRange(r *http.Request, duration string, now time.Time, s timerange.Settings) (timerange.Range, error) {
+	q := r.URL.Query()
+	var rg timerange.Range
+	var err error
+	if from, to := q.Get("from"), q.Get("to"); from != "" && to != "" {
+		rg, err = timerange.Parse(from, to, s)
+	} else {
+		if d := q.Get("duration"); d != "" && d != "undefined" {
+			duration = d
+		}
+		rg, err = timerange.Named(duration, now, s)
+	}
+	if err != nil {
+		switch errors.Cause(err) {
+		case timerange.ErrUnknownRange, timerange.ErrInvalidRange:
+			return rg, web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return rg, err
+	}
+	return rg, nil
+}
+
+// rangeOf resolves the range the chart is saved with, the last week when it is not known.
+func rangeOf(duration string, now time.Time, s timerange.Settings) timerange.Range {
+	rg, err := timerange.Named(duration, now, s)
+	if err != nil {
+		log.Printf("***> unexpected range %q for the chart. continuing with the default: %v", duration, err)
+		rg, _ = timerange.Named("", now, s)
 	}
-	return (count / total) * 100
+	return rg
 }
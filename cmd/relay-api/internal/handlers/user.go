@@ -17,6 +17,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/sso"
 	"gitlab.com/vjsideprojects/relay/internal/user"
 	"gitlab.com/vjsideprojects/relay/internal/visitor"
 	"go.opencensus.io/trace"
@@ -190,8 +191,19 @@ func (u *User) Verfiy(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		return errors.Wrap(err, "verifying token with firebase")
 	}
 
-	tkn, err := generateUserJWTForAnyAccount(ctx, tokenEmail, v.Now, u.authenticator, u.db)
+	challenge, err := mfaChallenge(ctx, u.db, u.sdb, tokenEmail)
 	if err != nil {
+		return err
+	}
+	if challenge != nil {
+		return web.Respond(ctx, w, challenge, http.StatusOK)
+	}
+
+	tkn, err := generateUserJWTForAnyAccount(ctx, tokenEmail, false, v.Now, u.authenticator, u.db)
+	if err != nil {
+		if err == ErrSSOEnforced {
+			return err
+		}
 		return web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
@@ -242,6 +254,10 @@ func (u *User) Join(ctx context.Context, w http.ResponseWriter, r *http.Request,
 		return web.NewRequestError(errors.Wrap(err, "token mismatch detected"), http.StatusUnauthorized)
 	}
 
+	if err := ssoEnforced(ctx, userInfo.AccountID, u.db); err != nil {
+		return err
+	}
+
 	// all authentication completed. Proceed with the next steps
 	usr, err := user.RetrieveUserByUniqIdentifier(ctx, userInfo.AccountID, tokenEmail, "", u.db)
 	if err == user.ErrNotFound {
@@ -269,7 +285,16 @@ func (u *User) Join(ctx context.Context, w http.ResponseWriter, r *http.Request,
 		return errors.Wrap(err, "adding member record for this user failed")
 	}
 
-	tkn, err := generateUserJWT(ctx, userInfo.AccountID, tokenEmail, time.Now(), u.authenticator, u.db)
+	// the member joining with the two-factor enabled in the other accounts completes the sign in with it
+	challenge, err := mfaChallenge(ctx, u.db, u.sdb, tokenEmail)
+	if err != nil {
+		return err
+	}
+	if challenge != nil {
+		return web.Respond(ctx, w, challenge, http.StatusOK)
+	}
+
+	tkn, err := generateUserJWT(ctx, userInfo.AccountID, tokenEmail, false, time.Now(), u.authenticator, u.db)
 	if err != nil {
 		return errors.Wrap(err, "generating token")
 	}
@@ -310,7 +335,7 @@ func (u *User) Visit(ctx context.Context, w http.ResponseWriter, r *http.Request
 		return errors.Wrapf(err, "retrival of user failed for reason other than not found")
 	}
 
-	tkn, err := generateUserJWT(ctx, userInfo.AccountID, vis.Email, time.Now(), u.authenticator, u.db)
+	tkn, err := generateUserJWT(ctx, userInfo.AccountID, vis.Email, false, time.Now(), u.authenticator, u.db)
 	if err != nil {
 		return errors.Wrap(err, "generating token")
 	}
@@ -401,17 +426,36 @@ func verifyToken(ctx context.Context, adminSDK string, idToken string) (string,
 	return token.UID, token.Claims["email"].(string), nil
 }
 
-func generateUserJWTForAnyAccount(ctx context.Context, email string, now time.Time, a *auth.Authenticator, db *sqlx.DB) (*UserToken, error) {
+// generateUserJWTForAnyAccount issues the token of the account when the member belongs to only one,
+// otherwise the token to pick the account. mfa marks the tokens of the sign in completed with the two-factor.
+func generateUserJWTForAnyAccount(ctx context.Context, email string, mfa bool, now time.Time, a *auth.Authenticator, db *sqlx.DB) (*UserToken, error) {
 	dbUsers, err := user.List(ctx, email, "", db)
 	if err != nil {
 		errors.Wrap(err, "user does not exist in the DB. Cannot generate JWT token")
 		return nil, web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
+	// the accounts enforcing the sso are reachable only through their identity provider
+	allowedUsers := make([]user.User, 0, len(dbUsers))
+	for _, dbUser := range dbUsers {
+		enforced, err := sso.Enforced(ctx, db, dbUser.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if !enforced {
+			allowedUsers = append(allowedUsers, dbUser)
+		}
+	}
+	if len(dbUsers) > 0 && len(allowedUsers) == 0 {
+		return nil, ErrSSOEnforced
+	}
+	dbUsers = allowedUsers
+
 	var tkn UserToken
 	if len(dbUsers) == 1 {
 		dbUser := dbUsers[0]
 		claims := auth.NewClaims(dbUser.ID, dbUser.Roles, now, 96*time.Hour)
+		claims.MFA = mfa
 		if err != nil {
 			switch err {
 			case user.ErrAuthenticationFailure:
@@ -428,6 +472,7 @@ func generateUserJWTForAnyAccount(ctx context.Context, email string, now time.Ti
 		tkn.Accounts = []string{dbUser.AccountID}
 	} else if len(dbUsers) > 1 {
 		claims := auth.NewClaims(email, []string{auth.RoleAdmin}, now, 1*time.Hour)
+		claims.MFA = mfa
 		if err != nil {
 			switch err {
 			case user.ErrAuthenticationFailure:
@@ -450,13 +495,14 @@ func generateUserJWTForAnyAccount(ctx context.Context, email string, now time.Ti
 	return &tkn, nil
 }
 
-func generateUserJWT(ctx context.Context, accountID, email string, now time.Time, a *auth.Authenticator, db *sqlx.DB) (*UserToken, error) {
+func generateUserJWT(ctx context.Context, accountID, email string, mfa bool, now time.Time, a *auth.Authenticator, db *sqlx.DB) (*UserToken, error) {
 	dbUser, err := user.RetrieveUserByUniqIdentifier(ctx, accountID, email, "", db)
 	if err != nil {
 		errors.Wrap(err, "user does not exist in the DB. Cannot generate JWT token")
 		return nil, web.NewRequestError(err, http.StatusUnauthorized)
 	}
 	claims := auth.NewClaims(dbUser.ID, dbUser.Roles, now, 96*time.Hour)
+	claims.MFA = mfa
 	if err != nil {
 		switch err {
 		case user.ErrAuthenticationFailure:
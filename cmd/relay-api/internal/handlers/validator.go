@@ -2,17 +2,123 @@ package handlers
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"reflect"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"gitlab.com/vjsideprojects/relay/internal/account"
 	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
 	"gitlab.com/vjsideprojects/relay/internal/platform/graphdb"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
 )
 
+// validationStrict is the value of the validation query param which checks the item values strictly
+// against the schema. The values are checked leniently by default as the clients still send the legacy data.
+const validationStrict = "strict"
+
+func lenientValidation(r *http.Request) bool {
+	return r.URL.Query().Get("validation") != validationStrict
+}
+
+// validateItemValues checks the item values against the schema of the entity and the referred items
+// against the store. The existing values are the stored values of the item on update, in the lenient
+// mode the values left unchanged, including the read only ones, are not checked again.
+func validateItemValues(ctx context.Context, accountID, entityID string, values, existing map[string]interface{}, lenient bool, db *sqlx.DB, sdb *database.SecDB) error {
+	e, err := entity.Retrieve(ctx, accountID, entityID, db, sdb)
+	if err != nil {
+		return errors.Wrapf(err, "item values validation failed")
+	}
+
+	if lenient {
+		changed := make(map[string]interface{}, 0)
+		for k, v := range values {
+			if !reflect.DeepEqual(existing[k], v) {
+				changed[k] = v
+			}
+		}
+		values = changed
+	}
+
+	verrs := e.ValidateValues(values, lenient)
+	if len(verrs) == 0 {
+		verrs, err = validateReferences(ctx, e, values, db)
+		if err != nil {
+			return errors.Wrapf(err, "reference validation failed")
+		}
+	}
+	if len(verrs) == 0 {
+		return nil
+	}
+
+	fields := make([]web.FieldError, len(verrs))
+	for i, ve := range verrs {
+		fields[i] = web.FieldError{Field: ve.Key, Error: ve.Error}
+	}
+	return &web.Error{
+		Err:    errors.New("field validation error"),
+		Status: http.StatusBadRequest,
+		Fields: fields,
+	}
+}
+
+// validateReferences checks the referred items exist in the entity of the reference field. The flows,
+// the nodes and the pipelines are not items, so they are left out.
+func validateReferences(ctx context.Context, e entity.Entity, values map[string]interface{}, db *sqlx.DB) ([]entity.ValueError, error) {
+	refs := make(map[string][]interface{}, 0)
+	ids := make([]interface{}, 0)
+	for _, f := range e.EasyFields() {
+		if !f.IsReference() || f.IsFlow() || f.IsNode() || f.RefID == "" || f.RefID == entity.PipeReferenceID || f.RefID == entity.FlowsReferenceID {
+			continue
+		}
+		switch v := values[f.Key].(type) {
+		case []interface{}:
+			refs[f.Key] = v
+		case string:
+			if v != "" {
+				refs[f.Key] = []interface{}{v}
+			}
+		}
+		for _, id := range refs[f.Key] {
+			// the malformed IDs can not be looked up, they are reported as missing.
+			if _, err := uuid.Parse(fmt.Sprint(id)); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	entityOf := make(map[string]string, 0)
+	if len(ids) > 0 {
+		items, err := item.BulkRetrieveItems(ctx, e.AccountID, ids, db)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			entityOf[it.ID] = it.EntityID
+		}
+	}
+
+	verrs := make([]entity.ValueError, 0)
+	for _, f := range e.EasyFields() {
+		for _, id := range refs[f.Key] {
+			if entityOf[fmt.Sprint(id)] != f.RefID {
+				verrs = append(verrs, entity.ValueError{Key: f.Key, Error: fmt.Sprintf("%v is not an item of the referred entity", id)})
+				break
+			}
+		}
+	}
+	return verrs, nil
+}
+
 func validateItemCreate(ctx context.Context, accountID, entityID string, values map[string]interface{}, db *sqlx.DB, sdb *database.SecDB) *ErrorResponse {
 	e, err := entity.Retrieve(ctx, accountID, entityID, db, sdb)
 	if err != nil {
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"gitlab.com/vjsideprojects/relay/internal/view"
+	"go.opencensus.io/trace"
+)
+
+// View represents the saved views of the entities.
+type View struct {
+	db  *sqlx.DB
+	sdb *database.SecDB
+}
+
+// List returns the views of the entity the current user sees in the team.
+func (v *View) List(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.View.List")
+	defer span.End()
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	views, err := view.List(ctx, v.db, params["account_id"], params["team_id"], params["entity_id"], currentUserID)
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, views, http.StatusOK)
+}
+
+// Retrieve returns the view when the current user sees it.
+func (v *View) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.View.Retrieve")
+	defer span.End()
+
+	sv, err := savedView(ctx, params["account_id"], params["team_id"], params["entity_id"], params["view_id"], v.db)
+	if err != nil {
+		return err
+	}
+	if sv == nil {
+		return web.NewRequestError(view.ErrNotFound, http.StatusNotFound)
+	}
+	return web.Respond(ctx, w, sv, http.StatusOK)
+}
+
+// Create saves the view of the entity owned by the current user.
+func (v *View) Create(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.View.Create")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, v.db)
+	var nv view.NewView
+	if err := web.Decode(r, &nv); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := v.validateColumns(ctx, accountID, entityID, nv); err != nil {
+		return err
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	sv, err := view.Create(ctx, v.db, accountID, params["team_id"], entityID, currentUserID, nv, time.Now())
+	if err != nil {
+		if errors.Cause(err) == view.ErrInvalidView {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, sv, http.StatusCreated)
+}
+
+// Update replaces the settings of the view. Only the owner and the admins change the view.
+func (v *View) Update(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.View.Update")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, v.db)
+	var nv view.NewView
+	if err := web.Decode(r, &nv); err != nil {
+		return errors.Wrap(err, "")
+	}
+	if err := v.validateColumns(ctx, accountID, entityID, nv); err != nil {
+		return err
+	}
+
+	sv, err := ownedView(ctx, accountID, entityID, params["view_id"], v.db)
+	if err != nil {
+		return err
+	}
+
+	updated, err := view.Update(ctx, v.db, sv, nv, time.Now())
+	if err != nil {
+		if errors.Cause(err) == view.ErrInvalidView {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return err
+	}
+	return web.Respond(ctx, w, updated, http.StatusOK)
+}
+
+// Delete removes the view. Only the owner and the admins remove the view.
+func (v *View) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.View.Delete")
+	defer span.End()
+
+	accountID, entityID, _ := takeAEI(ctx, params, v.db)
+	sv, err := ownedView(ctx, accountID, entityID, params["view_id"], v.db)
+	if err != nil {
+		return err
+	}
+
+	if err := view.Delete(ctx, v.db, accountID, sv.ID); err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, "", http.StatusNoContent)
+}
+
+// validateColumns checks the columns and the sort/group keys are the fields of the entity.
+func (v *View) validateColumns(ctx context.Context, accountID, entityID string, nv view.NewView) error {
+	e, err := entity.Retrieve(ctx, accountID, entityID, v.db, v.sdb)
+	if err != nil {
+		return err
+	}
+	keyMap := entity.KeyMap(e.EasyFields())
+
+	fieldErrs := make([]web.FieldError, 0)
+	for _, key := range nv.Columns {
+		if _, ok := keyMap[key]; !ok {
+			fieldErrs = append(fieldErrs, web.FieldError{Field: "columns", Error: key + " is not a field of the entity"})
+		}
+	}
+	if _, ok := keyMap[nv.SortBy]; !ok && nv.SortBy != "" && nv.SortBy != "created_at" && nv.SortBy != "updated_at" {
+		fieldErrs = append(fieldErrs, web.FieldError{Field: "sort_by", Error: nv.SortBy + " is not a field of the entity"})
+	}
+	if _, ok := keyMap[nv.GroupBy]; !ok && nv.GroupBy != "" {
+		fieldErrs = append(fieldErrs, web.FieldError{Field: "group_by", Error: nv.GroupBy + " is not a field of the entity"})
+	}
+	if len(fieldErrs) > 0 {
+		return &web.Error{
+			Err:    errors.New("field validation error"),
+			Status: http.StatusBadRequest,
+			Fields: fieldErrs,
+		}
+	}
+	return nil
+}
+
+// savedView returns the view of the entity when the current user sees it in the team. The view is nil, not an
+// error, when the ID is not of a saved view so the callers can look for the flow with the same ID.
+func savedView(ctx context.Context, accountID, teamID, entityID, viewID string, db *sqlx.DB) (*view.View, error) {
+	sv, err := view.Retrieve(ctx, db, accountID, viewID)
+	switch err {
+	case nil:
+	case view.ErrNotFound, view.ErrInvalidID:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	if sv.EntityID != entityID {
+		// the view of another entity could refer the fields this entity does not have.
+		return nil, web.NewRequestError(view.ErrNotFound, http.StatusNotFound)
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !sv.Visible(currentUserID, teamID) {
+		// the private views of the others are not disclosed.
+		return nil, web.NewRequestError(view.ErrNotFound, http.StatusNotFound)
+	}
+	return &sv, nil
+}
+
+// ownedView returns the view of the entity when the current user is its owner or an admin.
+func ownedView(ctx context.Context, accountID, entityID, viewID string, db *sqlx.DB) (view.View, error) {
+	sv, err := view.Retrieve(ctx, db, accountID, viewID)
+	if err != nil {
+		if err == view.ErrNotFound || err == view.ErrInvalidID {
+			return view.View{}, web.NewRequestError(view.ErrNotFound, http.StatusNotFound)
+		}
+		return view.View{}, err
+	}
+	if sv.EntityID != entityID {
+		return view.View{}, web.NewRequestError(view.ErrNotFound, http.StatusNotFound)
+	}
+
+	currentUserID, err := user.RetrieveCurrentUserID(ctx)
+	if err != nil {
+		return view.View{}, err
+	}
+	role, _ := ctx.Value(auth.RoleKey).(string)
+	if sv.UserID != currentUserID && role != auth.RoleAdmin {
+		return view.View{}, web.NewRequestError(errors.New("only the owner or the admins can change the view"), http.StatusForbidden)
+	}
+	return sv, nil
+}
+
+// pick returns the given value or the fallback when it is empty.
+func pick(given, fallback string) string {
+	if given == "" {
+		return fallback
+	}
+	return given
+}
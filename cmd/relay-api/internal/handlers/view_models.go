@@ -14,6 +14,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
 	"gitlab.com/vjsideprojects/relay/internal/rule/node"
 	"gitlab.com/vjsideprojects/relay/internal/team"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
 	"gitlab.com/vjsideprojects/relay/internal/timeseries"
 	"gitlab.com/vjsideprojects/relay/internal/token"
 	"gitlab.com/vjsideprojects/relay/internal/user"
@@ -21,20 +22,26 @@ import (
 )
 
 type ViewModelAccount struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Plan     int     `json:"plan"`
-	Status   string  `json:"status"`
-	TrailEnd float64 `json:"trail_end"`
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Plan     int              `json:"plan"`
+	Status   string           `json:"status"`
+	TrailEnd float64          `json:"trail_end"`
+	Calendar account.Calendar `json:"calendar"`
 }
 
 func createViewModelAccount(acc *account.Account) ViewModelAccount {
+	var timezone string
+	if acc.TimeZone != nil {
+		timezone = *acc.TimeZone
+	}
 	return ViewModelAccount{
 		ID:       acc.ID,
 		Name:     acc.Name,
 		Plan:     acc.CustomerPlan,
 		Status:   acc.CustomerStatus,
 		TrailEnd: acc.TrailEnd * 1000,
+		Calendar: account.Calendar{TimeZone: timezone, WeekStart: acc.WeekStart, FiscalStart: acc.FiscalStart},
 	}
 }
 
@@ -114,6 +121,8 @@ type UserToken struct {
 	Entity       string   `json:"entity"`
 	Item         string   `json:"item"`
 	JustLaunched bool     `json:"just_launched"`
+	MFARequired  bool     `json:"mfa_required,omitempty"`
+	MFAToken     string   `json:"mfa_token,omitempty"`
 }
 
 func createViewModelCharts(charts []chart.Chart, eagerLoader map[string]EagerLoader) []VMChart {
@@ -189,6 +198,8 @@ type VMChart struct {
 	Change   int               `json:"change"`
 	Icon     string            `json:"icon"`
 	Advanced map[string]string `json:"advanced"`
+	Report   *ReportResult     `json:"report,omitempty"`
+	Range    *timerange.Range  `json:"range,omitempty"`
 }
 
 type Series struct {
@@ -21,9 +21,12 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
 	"gitlab.com/vjsideprojects/relay/cmd/relay-api/internal/handlers"
+	"gitlab.com/vjsideprojects/relay/internal/account"
 	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
 	"gitlab.com/vjsideprojects/relay/internal/platform/conversation"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/ratelimit"
+	"gitlab.com/vjsideprojects/relay/internal/token"
 )
 
 func main() {
@@ -76,6 +79,8 @@ func run() error {
 			Algorithm          string `conf:"default:RS256"`
 			GoogleKeyFile      string `conf:"default:config/dev/relay-70013-firebase-adminsdk-cfun3-58caec85f0.json,env:AUTH_GOOGLE_KEY_FILE"`
 			GoogleClientSecret string `conf:"default:config/dev/google-apps-client-secret.json,env:AUTH_GOOGLE_CLIENT_SECRET"`
+			SlackClientID      string `conf:"env:SLACK_CLIENT_ID"`
+			SlackClientSecret  string `conf:"noprint,env:SLACK_CLIENT_SECRET"`
 		}
 		Zipkin struct {
 			LocalEndpoint string  `conf:"default:0.0.0.0:3000"`
@@ -95,6 +100,19 @@ func run() error {
 			StripeLiveKey    string `conf:"default:sk_test_51M0BSXHUBFGeRHv5Qalelfhv8NO1kdnM0FgGd37iG74b2HNQfRLSolOgcvuFjvkfRP4KYTmZwztk5qMCmN245IDW00IUDFBOmp,env:STRIPE_LIVE_KEY"`
 			StripePublishKey string `conf:"default:whsec_41d7022cc154e767fe96054ac413c1cde21b2d9c23b4c7743f20315901f247cc,env:STRIPE_PUBLISH_KEY"`
 		}
+		RateLimit struct {
+			Disabled    bool   `conf:"default:false,env:RATE_LIMIT_DISABLED"`
+			Auth        string `conf:"default:20/1m,env:RATE_LIMIT_AUTH"`
+			Signup      string `conf:"default:10/1m,env:RATE_LIMIT_SIGNUP"`
+			Forms       string `conf:"default:30/1m,env:RATE_LIMIT_FORMS"`
+			Webhooks    string `conf:"default:1200/1m,env:RATE_LIMIT_WEBHOOKS"`
+			API         string `conf:"default:600/1m,env:RATE_LIMIT_API"`
+			PlanFree    string `conf:"default:300/1m,env:RATE_LIMIT_PLAN_FREE"`
+			PlanStartup string `conf:"default:1200/1m,env:RATE_LIMIT_PLAN_STARTUP"`
+			PlanPro     string `conf:"default:6000/1m,env:RATE_LIMIT_PLAN_PRO"`
+			// TrustedProxies is the number of the proxies (load balancers) in front of the api.
+			TrustedProxies int `conf:"default:1,env:RATE_LIMIT_TRUSTED_PROXIES"`
+		}
 		Build string `conf:"default:dev,env:BUILD"`
 	}
 
@@ -277,7 +295,33 @@ func run() error {
 
 	sdb := database.Init(rp, cp, rp)
 
-	handler := c.Handler(handlers.API(shutdown, log, db, chDB, sdb, authenticator, publisher))
+	// the api keys are stored in the primary database, so the lookup is wired up once it is open.
+	authenticator.APIKeyLookup = token.NewAPIKeyLookupFunc(db)
+	authenticator.SlackClientID = cfg.Auth.SlackClientID
+	authenticator.SlackClientSecret = cfg.Auth.SlackClientSecret
+
+	// the buckets are kept in the cache database so all the instances share them.
+	var limitStore ratelimit.Store
+	if !cfg.RateLimit.Disabled {
+		limitStore = ratelimit.NewRedisStore(cp)
+	}
+	limits, err := ratelimit.NewConfig(limitStore, map[string]string{
+		ratelimit.GroupAuth:     cfg.RateLimit.Auth,
+		ratelimit.GroupSignup:   cfg.RateLimit.Signup,
+		ratelimit.GroupForms:    cfg.RateLimit.Forms,
+		ratelimit.GroupWebhooks: cfg.RateLimit.Webhooks,
+		ratelimit.GroupAPI:      cfg.RateLimit.API,
+	}, map[int]string{
+		account.PlanFree:    cfg.RateLimit.PlanFree,
+		account.PlanStartup: cfg.RateLimit.PlanStartup,
+		account.PlanPro:     cfg.RateLimit.PlanPro,
+	})
+	if err != nil {
+		return errors.Wrap(err, "parsing rate limits")
+	}
+	limits.TrustedHops = cfg.RateLimit.TrustedProxies
+
+	handler := c.Handler(handlers.API(shutdown, log, db, chDB, sdb, authenticator, publisher, limits))
 
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rsa"
 	"encoding/json"
@@ -10,7 +11,11 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ardanlabs/conf"
 	"github.com/aws/aws-lambda-go/events"
@@ -24,18 +29,36 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	tokens "gitlab.com/vjsideprojects/relay/internal/token"
 )
 
 var (
 	eHandler *EventsHandler
 )
 
-//informations exists in the handlers only useful for the local testing.
+// retryAfter is the seconds the clients wait before sending the batch again when the batches in flight are full.
+const retryAfter = 1
+
+// informations exists in the handlers only useful for the local testing.
 type EventsHandler struct {
 	db            *sqlx.DB
 	sdb           *database.SecDB
 	authenticator *auth.Authenticator
 	log           *log.Logger
+	web           webConfig
+	inflight      chan struct{}
+}
+
+// webConfig is how the events are received. The lambda mode takes the events from the API gateway, while
+// the http mode serves them by itself.
+type webConfig struct {
+	Mode            string        `conf:"default:lambda,env:EVENT_MODE"`
+	APIHost         string        `conf:"default:0.0.0.0:3002,env:WEB_API_HOST"`
+	ReadTimeout     time.Duration `conf:"default:5s"`
+	WriteTimeout    time.Duration `conf:"default:30s"`
+	ShutdownTimeout time.Duration `conf:"default:5s"`
+	MaxBodyBytes    int64         `conf:"default:5242880,env:EVENT_MAX_BODY_BYTES"`
+	MaxInflight     int           `conf:"default:8,env:EVENT_MAX_INFLIGHT"`
 }
 
 func newEventsHandler() *EventsHandler {
@@ -54,7 +77,8 @@ func initialize(eHandler *EventsHandler) error {
 	eHandler.log.Println("main : initialization started")
 
 	var cfg struct {
-		DB struct {
+		Web webConfig
+		DB  struct {
 			User       string `conf:"default:postgres,env:DB_USER"`
 			Password   string `conf:"default:postgres,noprint,env:DB_PASSWORD"`
 			Host       string `conf:"default:0.0.0.0,env:DB_HOST"`
@@ -87,6 +111,11 @@ func initialize(eHandler *EventsHandler) error {
 		return errors.Wrap(err, "error: parsing config")
 	}
 
+	eHandler.web = cfg.Web
+	if cfg.Web.MaxInflight > 0 {
+		eHandler.inflight = make(chan struct{}, cfg.Web.MaxInflight)
+	}
+
 	// Store Global Variables
 	expvar.NewString("build").Set(cfg.Build)
 	expvar.NewString("aws_region").Set(cfg.Service.Region)
@@ -145,7 +174,76 @@ func initializeAuth(eHandler *EventsHandler, privateKeyFile, keyID, googleKeyFil
 }
 
 func main() {
-	lambda.Start(newEventsHandler().handleEvent)
+	h := newEventsHandler()
+	if h.web.Mode != "http" {
+		lambda.Start(h.handleEvent)
+		return
+	}
+	if err := h.serve(); err != nil {
+		h.log.Println("main : error :", err)
+		os.Exit(1)
+	}
+}
+
+// serve receives the events over http until the process is told to stop.
+func (h *EventsHandler) serve() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/events", h.handleHTTP)
+	api := http.Server{
+		Addr:         h.web.APIHost,
+		Handler:      mux,
+		ReadTimeout:  h.web.ReadTimeout,
+		WriteTimeout: h.web.WriteTimeout,
+	}
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		h.log.Printf("main : events listening on %s", api.Addr)
+		serverErrors <- api.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErrors:
+		return errors.Wrap(err, "server error")
+
+	case sig := <-shutdown:
+		h.log.Printf("main : %v : Start shutdown", sig)
+
+		// Give the batches in flight a deadline for completion.
+		ctx, cancel := context.WithTimeout(context.Background(), h.web.ShutdownTimeout)
+		defer cancel()
+
+		if err := api.Shutdown(ctx); err != nil {
+			h.log.Printf("main : Graceful shutdown did not complete in %v : %v", h.web.ShutdownTimeout, err)
+			if err := api.Close(); err != nil {
+				return errors.Wrap(err, "could not stop server gracefully")
+			}
+		}
+	}
+	return nil
+}
+
+func (h EventsHandler) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTP(w, nil, web.NewRequestError(errors.New("method not allowed"), http.StatusMethodNotAllowed))
+		return
+	}
+
+	var token interface{}
+	if v := r.Header.Get("Authorization"); v != "" {
+		token = v
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, h.web.MaxBodyBytes))
+	if err != nil {
+		writeHTTP(w, nil, web.NewRequestError(errors.Wrap(err, "reading body"), http.StatusRequestEntityTooLarge))
+		return
+	}
+
+	res, err := h.ingest(r.Context(), body, r.Header.Get("Content-Type"), token)
+	writeHTTP(w, res, err)
 }
 
 func (h EventsHandler) handleEvent(ctx context.Context, payloadIntf interface{}) (events.APIGatewayProxyResponse, error) {
@@ -154,48 +252,73 @@ func (h EventsHandler) handleEvent(ctx context.Context, payloadIntf interface{})
 	payload, ok := payloadIntf.(map[string]interface{})
 	if !ok {
 		eHandler.log.Println("handleEvent : errored : parse payload")
-		return newErrReponse(errors.New("post payload not exist"))
+		return newErrReponse(web.NewRequestError(errors.New("post payload not exist"), http.StatusBadRequest))
 	}
 
-	var eventBody event.NewEvent
-	if payload["body"] != nil {
-		err := json.Unmarshal([]byte(payload["body"].(string)), &eventBody)
-		if err != nil {
-			return newErrReponse(errors.New("cannot parse payload"))
-		}
-	} else {
+	body, ok := payload["body"].(string)
+	if !ok {
 		eHandler.log.Println("handleEvent : errored : parse body empty")
-		return newErrReponse(errors.New("post body not exist"))
+		return newErrReponse(web.NewRequestError(errors.New("post body not exist"), http.StatusBadRequest))
+	}
+	if h.web.MaxBodyBytes > 0 && int64(len(body)) > h.web.MaxBodyBytes {
+		return newErrReponse(web.NewRequestError(errors.New("post body too large"), http.StatusRequestEntityTooLarge))
 	}
 
-	headers := payload["headers"].(map[string]interface{})
+	headers, _ := payload["headers"].(map[string]interface{})
 	token := headers["authorization"]
+	contentType := strValue(headers["content-type"])
 	eHandler.log.Println("handleEvent : completed : parse payload")
 
-	eHandler.log.Println("handleEvent : started : authentication")
-	accountID, err := h.authenticate(token)
+	res, err := h.ingest(ctx, []byte(body), contentType, token)
 	if err != nil {
-		eHandler.log.Println("handleEvent : errored : authentication")
+		eHandler.log.Println("handleEvent : errored : ingest", err)
 		return newErrReponse(err)
 	}
-	eHandler.log.Println("handleEvent : completed : authentication")
-
-	return h.processEvent(ctx, accountID, eventBody)
+	return newSuccessReponse(res)
 }
 
-func (h EventsHandler) processEvent(ctx context.Context, accountID string, eventBody event.NewEvent) (events.APIGatewayProxyResponse, error) {
-	eHandler.log.Println("processEvent : started : save event")
+// ingest takes in the batch of the events in the body sent with the token. The items created are streamed
+// before the batch is answered, so the events are not lost when the lambda freezes after the response.
+func (h EventsHandler) ingest(ctx context.Context, body []byte, contentType string, token interface{}) (event.Result, error) {
+	if h.inflight != nil {
+		select {
+		case h.inflight <- struct{}{}:
+			defer func() { <-h.inflight }()
+		default:
+			err := errors.New("too many batches in flight")
+			return event.Result{}, web.NewRequestError(err, http.StatusTooManyRequests)
+		}
+	}
 
-	itEve, err := event.Process(ctx, accountID, eventBody, eHandler.log, h.db)
+	accountID, err := h.authenticate(ctx, token)
 	if err != nil {
-		return newErrReponse(err)
+		return event.Result{}, err
+	}
+
+	batch, err := event.Decode(bytes.NewReader(body), contentType)
+	if err != nil {
+		switch errors.Cause(err) {
+		case event.ErrTooMany:
+			return event.Result{}, web.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		case event.ErrInvalidEvent:
+			return event.Result{}, web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return event.Result{}, err
 	}
-	go job.NewJob(h.db, h.sdb, h.authenticator.FireBaseAdminSDK).Stream(stream.NewEventItemMessage(ctx, h.db, accountID, *itEve.UserID, itEve.EntityID, itEve.ID))
 
-	return newSuccessReponse("success")
+	res, items, err := event.Ingest(ctx, h.db, accountID, batch, time.Now())
+	messages := make([]*stream.Message, 0, len(items))
+	for _, it := range items {
+		messages = append(messages, stream.NewEventItemMessage(ctx, h.db, accountID, *it.UserID, it.EntityID, it.ID))
+	}
+	if err := job.NewJob(h.db, h.sdb, h.authenticator.FireBaseAdminSDK).StreamBatch(messages); err != nil {
+		eHandler.log.Println("ingest : errored : stream items", err)
+	}
+	return res, err
 }
 
-func (h EventsHandler) authenticate(token interface{}) (string, error) {
+// authenticate accepts the API keys with the events:ingest scope and the system token of the account.
+func (h EventsHandler) authenticate(ctx context.Context, token interface{}) (string, error) {
 	if token == nil {
 		err := errors.New("token does not exist")
 		return "", web.NewRequestError(err, http.StatusUnauthorized)
@@ -213,11 +336,30 @@ func (h EventsHandler) authenticate(token interface{}) (string, error) {
 		return "", web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
+	if auth.IsAPIKey(parts[1]) {
+		key, err := tokens.Authenticate(ctx, h.db, parts[1], time.Now())
+		if err != nil {
+			return "", web.NewRequestError(err, http.StatusUnauthorized)
+		}
+		if !auth.HasScope(key.Scope, "events", auth.ScopeIngest, "") {
+			err := errors.New("api key does not have the scope events:ingest")
+			return "", web.NewRequestError(err, http.StatusForbidden)
+		}
+		return key.AccountID, nil
+	}
+
 	claims, err := h.authenticator.ParseClaims(parts[1])
 	if err != nil {
 		return "", web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
+	// the user tokens are valid JWTs as well, only the system token of the account is accepted.
+	systemToken, err := tokens.Retrieve(ctx, h.db, claims.Subject)
+	if err != nil || systemToken.Token != parts[1] || systemToken.State != tokens.StateActive {
+		err := errors.New("token is not the system token of the account")
+		return "", web.NewRequestError(err, http.StatusUnauthorized)
+	}
+
 	return claims.Subject, nil
 }
 
@@ -228,16 +370,54 @@ func strValue(v interface{}) string {
 	return ""
 }
 
-func newSuccessReponse(message string) (events.APIGatewayProxyResponse, error) {
+// statusOf is the status of the response to the error.
+func statusOf(err error) int {
+	if webErr, ok := errors.Cause(err).(*web.Error); ok {
+		return webErr.Status
+	}
+	return http.StatusInternalServerError
+}
+
+func writeHTTP(w http.ResponseWriter, res interface{}, err error) {
+	code := http.StatusOK
+	if err != nil {
+		code = statusOf(err)
+		res = web.ErrorResponse{Error: err.Error()}
+	}
+	if code == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
+
+func newSuccessReponse(res interface{}) (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(res)
+	if err != nil {
+		return newErrReponse(err)
+	}
 	return events.APIGatewayProxyResponse{
-		Body:       message,
-		StatusCode: 200,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+		StatusCode: http.StatusOK,
 	}, nil
 }
 
+// newErrReponse answers the error with its status. Only the errors not told to the client fail the lambda.
 func newErrReponse(err error) (events.APIGatewayProxyResponse, error) {
-	return events.APIGatewayProxyResponse{
-		Body:       err.Error(),
-		StatusCode: 500,
-	}, err
+	code := statusOf(err)
+	body, _ := json.Marshal(web.ErrorResponse{Error: err.Error()})
+	resp := events.APIGatewayProxyResponse{
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(body),
+		StatusCode: code,
+	}
+	if code == http.StatusTooManyRequests {
+		resp.Headers["Retry-After"] = strconv.Itoa(retryAfter)
+	}
+	if code < http.StatusInternalServerError {
+		return resp, nil
+	}
+	return resp, err
 }
@@ -1,13 +1,187 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/ardanlabs/conf"
+	"github.com/gomodule/redigo/redis"
+	pkgerrors "github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/integration/calendar"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 )
 
 func main() {
+	if err := run(); err != nil {
+		log.Printf("main : error: %s", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	// =========================================================================
+	// Logging
+
+	log := log.New(os.Stdout, "RELAY SCHEDULER : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+
+	// =========================================================================
+	// Configuration
+
+	var cfg struct {
+		DB struct {
+			User       string `conf:"default:postgres,env:DB_USER"`
+			Password   string `conf:"default:postgres,noprint,env:DB_PASSWORD"`
+			Host       string `conf:"default:0.0.0.0,env:DB_HOST"`
+			Name       string `conf:"default:relaydb,env:DB_NAME"`
+			DisableTLS bool   `conf:"default:true"`
+		}
+		SecDB struct {
+			User     string `conf:"default:redisgraph,env:SEC_DB_USER"`
+			Password string `conf:"default:redis,noprint,env:SEC_DB_PASSWORD"`
+			Host     string `conf:"default:127.0.0.1:6379,env:SEC_DB_HOST"`
+			Name     string `conf:"default:relaydb,env:SEC_DB_NAME"`
+		}
+		CacheDB struct {
+			User     string `conf:"default:redisgraph,env:CACHE_DB_USER"`
+			Password string `conf:"default:redis,noprint,env:CACHE_DB_PASSWORD"`
+			Host     string `conf:"default:127.0.0.1:6379,env:CACHE_DB_HOST"`
+			Name     string `conf:"default:relaydb,env:CACHE_DB_NAME"`
+		}
+		Auth struct {
+			GoogleKeyFile string `conf:"default:config/dev/relay-70013-firebase-adminsdk-cfun3-58caec85f0.json,env:AUTH_GOOGLE_KEY_FILE"`
+		}
+		Schedule struct {
+			SweepMins     int `conf:"default:15,env:SCHEDULE_SWEEP_MINS"`
+			CalendarMins  int `conf:"default:5,env:SCHEDULE_CALENDAR_MINS"`
+			MetricHours   int `conf:"default:24,env:SCHEDULE_METRIC_HOURS"`
+			ReceiptHours  int `conf:"default:24,env:SCHEDULE_RECEIPT_HOURS"`
+			HealthHours   int `conf:"default:6,env:SCHEDULE_HEALTH_HOURS"`
+			ForecastHours int `conf:"default:24,env:SCHEDULE_FORECAST_HOURS"`
+		}
+		Build string `conf:"default:dev,env:BUILD"`
+	}
+
+	if err := conf.Parse(os.Args[1:], "SCHEDULER", &cfg); err != nil {
+		if err == conf.ErrHelpWanted {
+			usage, err := conf.Usage("SCHEDULER", &cfg)
+			if err != nil {
+				return pkgerrors.Wrap(err, "generating usage")
+			}
+			fmt.Println(usage)
+			return nil
+		}
+		return pkgerrors.Wrap(err, "error: parsing config")
+	}
 
+	// =========================================================================
+	// App Starting
+	log.Printf("main : Started : Application initializing : version %q", cfg.Build)
+	defer log.Println("main : Completed")
+
+	db, err := database.Open(database.Config{
+		User:       cfg.DB.User,
+		Password:   cfg.DB.Password,
+		Host:       cfg.DB.Host,
+		Name:       cfg.DB.Name,
+		DisableTLS: cfg.DB.DisableTLS,
+	})
+	if err != nil {
+		return pkgerrors.Wrap(err, "connecting to primary db")
+	}
+	defer func() {
+		log.Printf("main : Primary Database Stopping : %s", cfg.DB.Host)
+		db.Close()
+	}()
+
+	rp := redisPool(cfg.SecDB.Host, cfg.SecDB.Password)
+	defer func() {
+		log.Printf("main : Redis Database Stopping : %s", cfg.SecDB.Host)
+		rp.Close()
+	}()
+	cp := redisPool(cfg.CacheDB.Host, cfg.CacheDB.Password)
+	defer func() {
+		log.Printf("main : Redis Cache Database Stopping : %s", cfg.CacheDB.Host)
+		cp.Close()
+	}()
+	sdb := database.Init(rp, cp, rp)
+
+	// =========================================================================
+	// Start Jobs
+
+	AddJob("flow-schedules", &recurrent{units: 1, period: time.Minute}, func(id string) {
+		log.Printf("main : Running %s", id)
+		job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).RunDueSchedules(time.Now())
+	})
+	AddJob("notification-queue", &recurrent{units: 1, period: time.Minute}, func(id string) {
+		log.Printf("main : Running %s", id)
+		if err := notification.Flush(context.Background(), db, cfg.Auth.GoogleKeyFile, time.Now()); err != nil {
+			log.Printf("main : %s : %v", id, err)
+		}
+	})
+	AddJob("calendar-sync", &recurrent{units: cfg.Schedule.CalendarMins, period: time.Minute}, func(id string) {
+		log.Printf("main : Running %s", id)
+		if err := calendar.SyncAll(context.Background(), db, job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).Stream); err != nil {
+			log.Printf("main : %s : %v", id, err)
+		}
+	})
+	AddJob("segment-sweep", &recurrent{units: cfg.Schedule.SweepMins, period: time.Minute}, func(id string) {
+		log.Printf("main : Running %s", id)
+		job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).SweepSegments()
+	})
+	AddJob("metric-reconcile", &recurrent{units: cfg.Schedule.MetricHours, period: time.Hour}, func(id string) {
+		log.Printf("main : Running %s", id)
+		job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).ReconcileMetrics()
+	})
+	AddJob("event-receipts", &recurrent{units: cfg.Schedule.ReceiptHours, period: time.Hour}, func(id string) {
+		log.Printf("main : Running %s", id)
+		job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).PruneEventReceipts()
+	})
+	AddJob("health-score", &recurrent{units: cfg.Schedule.HealthHours, period: time.Hour}, func(id string) {
+		log.Printf("main : Running %s", id)
+		job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).ScoreHealth()
+	})
+	AddJob("forecast-snapshot", &recurrent{units: cfg.Schedule.ForecastHours, period: time.Hour}, func(id string) {
+		log.Printf("main : Running %s", id)
+		job.NewJob(db, sdb, cfg.Auth.GoogleKeyFile).SnapshotForecasts()
+	})
+
+	// =========================================================================
+	// Shutdown
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	sig := <-shutdown
+	log.Printf("main : %v : Start shutdown", sig)
+	return nil
+}
+
+func redisPool(host, password string) *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   50,
+		IdleTimeout: 240 * time.Second,
+		Dial: func() (redis.Conn, error) {
+			c, err := redis.Dial("tcp", host, redis.DialPassword(password))
+			if err != nil {
+				return nil, err
+			}
+			return c, err
+		},
+
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
 }
 
 type scheduled interface {
@@ -9,6 +9,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
 	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"gitlab.com/vjsideprojects/relay/internal/slackapp"
 	"go.opencensus.io/trace"
 )
 
@@ -16,6 +17,7 @@ import (
 type Event struct {
 	db            *sqlx.DB
 	authenticator *auth.Authenticator
+	app           slackapp.App
 	// ADD OTHER STATE LIKE THE LOGGER IF NEEDED.
 }
 
@@ -30,12 +32,57 @@ func (e *Event) Create(ctx context.Context, w http.ResponseWriter, r *http.Reque
 		return errors.Wrap(err, "")
 	}
 
-	s := slack.Slack{
-		BotToken: e.authenticator.SlackBotToken,
-		Payload:  sp,
+	var err error
+	switch sp.Event.Type {
+	case slack.EventTypeLinkShared:
+		err = e.app.Unfurl(ctx, sp)
+	case slack.EventTypeMention:
+		err = e.app.Mention(ctx, sp)
+	case slack.EventTypeHomeOpened:
+		err = e.app.Home(ctx, sp)
+	default:
+		s := slack.Slack{
+			BotToken: e.authenticator.SlackBotToken,
+			Payload:  sp,
+		}
+		err = s.Call()
+	}
+	if err != nil {
+		return web.NewRequestError(err, http.StatusInternalServerError)
+	}
+	return web.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// Command answers the /relay slash command.
+func (e *Event) Command(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.event.Command")
+	defer span.End()
+
+	if err := r.ParseForm(); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	msg, err := e.app.Command(ctx, slack.ParseCommand(r.PostForm))
+	if err != nil {
+		return err
+	}
+	return web.Respond(ctx, w, msg, http.StatusOK)
+}
+
+// Interact acts on the buttons of the cards and the message shortcuts.
+func (e *Event) Interact(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.event.Interact")
+	defer span.End()
+
+	if err := r.ParseForm(); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	in, err := slack.ParseInteraction(r.PostForm)
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	if err := s.Call(); err != nil {
+	if err := e.app.Interact(ctx, in); err != nil {
 		return web.NewRequestError(err, http.StatusInternalServerError)
 	}
 	return web.Respond(ctx, w, nil, http.StatusOK)
@@ -5,16 +5,18 @@ import (
 	"net/http"
 	"os"
 
-	"github.com/gomodule/redigo/redis"
 	"github.com/jmoiron/sqlx"
+	"gitlab.com/vjsideprojects/relay/internal/job"
 	"gitlab.com/vjsideprojects/relay/internal/mid"
 	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
 	"gitlab.com/vjsideprojects/relay/internal/platform/conversation"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/slackapp"
 )
 
 // API constructs an http.Handler with all application routes defined.
-func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, redisPool *redis.Pool, authenticator *auth.Authenticator, publisher *conversation.Publisher) http.Handler {
+func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, sdb *database.SecDB, authenticator *auth.Authenticator, publisher *conversation.Publisher) http.Handler {
 
 	// Construct the web.App which holds all routes as well as common Middleware.
 	app := web.NewApp(shutdown, log, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
@@ -27,8 +29,15 @@ func API(shutdown chan os.Signal, log *log.Logger, db *sqlx.DB, redisPool *redis
 	event := Event{
 		db:            db,
 		authenticator: authenticator,
+		app: slackapp.App{
+			DB:     db,
+			SDB:    sdb,
+			Stream: job.NewJob(db, sdb, authenticator.FireBaseAdminSDK).Stream,
+		},
 	}
 	app.Handle("POST", "/v1/slack/event", event.Create, mid.HasSlackAccess(authenticator))
+	app.Handle("POST", "/v1/slack/command", event.Command, mid.HasSlackAccess(authenticator))
+	app.Handle("POST", "/v1/slack/interaction", event.Interact, mid.HasSlackAccess(authenticator))
 
 	return app
 }
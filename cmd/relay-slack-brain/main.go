@@ -201,7 +201,10 @@ func run() error {
 		Topic: cfg.PubSub.GmailPublisherTopic,
 	}
 
-	handler := c.Handler(handlers.API(shutdown, log, db, rp, authenticator, publisher))
+	// the brain keeps the graph, the cache and the pubsub in the same redis.
+	sdb := database.Init(rp, rp, rp)
+
+	handler := c.Handler(handlers.API(shutdown, log, db, sdb, authenticator, publisher))
 
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
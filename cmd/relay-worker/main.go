@@ -197,6 +197,12 @@ func run() error {
 		l.RunReminderListener(db, sdb, path.FirebaseSDKPath)
 	}()
 
+	go func() {
+		log.Printf("main : Debug Running SLA Listener")
+		l := job.Listener{}
+		l.RunSLAListener(db, sdb, path.FirebaseSDKPath)
+	}()
+
 	// =========================================================================
 	// Start WebServer
 
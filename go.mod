@@ -11,6 +11,7 @@ require (
 	github.com/ardanlabs/conf v1.2.0
 	github.com/aws/aws-lambda-go v1.34.1
 	github.com/aws/aws-sdk-go v1.42.22
+	github.com/beevik/etree v1.1.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/dimfeld/httptreemux/v5 v5.0.2
 	github.com/dimiro1/darwin v0.0.0-20191008194338-370f81775d3b
@@ -43,6 +44,7 @@ require (
 	github.com/redislabs/redisgraph-go v2.0.2+incompatible
 	github.com/rivo/uniseg v0.2.0 // indirect
 	github.com/rs/cors v1.7.0
+	github.com/russellhaering/goxmldsig v1.1.1
 	github.com/stretchr/testify v1.8.1 // indirect
 	github.com/stripe/stripe-go/v74 v74.2.0
 	github.com/twilio/twilio-go v1.5.0 // indirect
@@ -60,4 +62,5 @@ require (
 	gopkg.in/go-playground/assert.v1 v1.2.1 // indirect
 	gopkg.in/go-playground/validator.v9 v9.30.0
 	gopkg.in/mail.v2 v2.3.1
+	gopkg.in/yaml.v3 v3.0.1
 )
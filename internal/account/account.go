@@ -21,6 +21,9 @@ var (
 
 	// ErrInvalidID occurs when an ID is not in a valid form.
 	ErrInvalidID = errors.New("ID is not in its proper form")
+
+	// ErrInvalidTimeZone occurs when the timezone is not an IANA zone.
+	ErrInvalidTimeZone = errors.New("Timezone is not known")
 )
 
 var ExistingSubDomains = []string{"www", "app", "api", "csp", "crp", "emp", "client", "clients", "customer", "customers", "employee", "employees", "success", "event", "events"}
@@ -61,6 +64,20 @@ func Retrieve(ctx context.Context, db *sqlx.DB, id string) (*Account, error) {
 	return &a, nil
 }
 
+// Children retrieves the sandbox accounts created from the account.
+func Children(ctx context.Context, db *sqlx.DB, parentAccountID string) ([]Account, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.account.Children")
+	defer span.End()
+
+	accounts := []Account{}
+	const q = `SELECT * FROM accounts WHERE parent_account_id = $1 ORDER BY created_at DESC`
+
+	if err := db.SelectContext(ctx, &accounts, q, parentAccountID); err != nil {
+		return nil, errors.Wrapf(err, "selecting child accounts of %q", parentAccountID)
+	}
+	return accounts, nil
+}
+
 func RetrieveByStripeID(ctx context.Context, stripeCusID string, db *sqlx.DB) (*Account, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.account.RetrieveByStripeID")
 	defer span.End()
@@ -111,24 +128,25 @@ func CheckAvailability(ctx context.Context, name string, db *sqlx.DB) (*Account,
 // Create inserts a new user into the database. Call AccountBootstrap instead
 func Create(ctx context.Context, db *sqlx.DB, n NewAccount, now time.Time) (Account, error) {
 	a := Account{
-		ID:             n.ID,
-		Name:           n.Name,
-		Domain:         n.Domain,
-		CustomerPlan:   n.CustomerPlan,
-		CustomerStatus: n.CustomerStatus,
-		TrailStart:     n.TrailStart,
-		TrailEnd:       n.TrailEnd,
-		UseDB:          n.UseDB,
-		CreatedAt:      now.UTC(),
-		UpdatedAt:      now.UTC().Unix(),
+		ID:              n.ID,
+		ParentAccountID: n.ParentAccountID,
+		Name:            n.Name,
+		Domain:          n.Domain,
+		CustomerPlan:    n.CustomerPlan,
+		CustomerStatus:  n.CustomerStatus,
+		TrailStart:      n.TrailStart,
+		TrailEnd:        n.TrailEnd,
+		UseDB:           n.UseDB,
+		CreatedAt:       now.UTC(),
+		UpdatedAt:       now.UTC().Unix(),
 	}
 
 	const q = `INSERT INTO accounts
-		(account_id, name, domain, cus_plan, cus_status, trail_start, trail_end, use_db, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+		(account_id, parent_account_id, name, domain, cus_plan, cus_status, trail_start, trail_end, use_db, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 	_, err := db.ExecContext(
 		ctx, q,
-		a.ID, a.Name, a.Domain, a.CustomerPlan, a.CustomerStatus,
+		a.ID, a.ParentAccountID, a.Name, a.Domain, a.CustomerPlan, a.CustomerStatus,
 		a.TrailStart, a.TrailEnd, a.UseDB,
 		a.CreatedAt, a.UpdatedAt,
 	)
@@ -169,6 +187,21 @@ func UpdateStripePlan(ctx context.Context, accountID, cusStatus string, plan, se
 	return nil
 }
 
+// UpdateCalendar sets the timezone, the week start and the fiscal year start of the account.
+func UpdateCalendar(ctx context.Context, db *sqlx.DB, accountID string, uc Calendar) error {
+	ctx, span := trace.StartSpan(ctx, "internal.account.UpdateCalendar")
+	defer span.End()
+
+	if _, err := time.LoadLocation(uc.TimeZone); err != nil {
+		return errors.Wrapf(ErrInvalidTimeZone, "%q", uc.TimeZone)
+	}
+	const q = `UPDATE accounts SET timezone = $2, week_start = $3, fiscal_start = $4, updated_at = $5 WHERE account_id = $1`
+	if _, err := db.ExecContext(ctx, q, accountID, uc.TimeZone, uc.WeekStart, uc.FiscalStart, time.Now().UTC().Unix()); err != nil {
+		return errors.Wrap(err, "updating account calendar")
+	}
+	return nil
+}
+
 func UseDB(ctx context.Context, db *sqlx.DB, id string) string {
 	acc, err := Retrieve(ctx, db, id)
 	if err != nil || acc.UseDB == "" {
@@ -31,6 +31,8 @@ type Account struct {
 	TrailStart      float64   `db:"trail_start" json:"trail_start"`
 	TrailEnd        float64   `db:"trail_end" json:"trail_end"`
 	TimeZone        *string   `db:"timezone" json:"timezone"`
+	WeekStart       int       `db:"week_start" json:"week_start"`     // the weekday the weeks start, 0 is sunday
+	FiscalStart     int       `db:"fiscal_start" json:"fiscal_start"` // the month the fiscal year starts, 1 is january
 	Language        *string   `db:"language" json:"language"`
 	Country         *string   `db:"country" json:"country"`
 	UseDB           string    `db:"use_db" json:"use_db"`
@@ -40,15 +42,23 @@ type Account struct {
 
 // NewAccount contains information needed to create a new Account.
 type NewAccount struct {
-	ID             string  `json:"id" validate:"required"`
-	Name           string  `json:"name" validate:"required"`
-	Domain         string  `json:"domain"`
-	DraftID        string  `json:"draft_id"`
-	CustomerPlan   int     `json:"cus_plan"`
-	CustomerStatus string  `json:"cus_status"`
-	TrailStart     float64 `json:"trail_start"`
-	TrailEnd       float64 `json:"trail_end"`
-	UseDB          string  `json:"use_db"`
+	ID              string  `json:"id" validate:"required"`
+	ParentAccountID *string `json:"parent_account_id"`
+	Name            string  `json:"name" validate:"required"`
+	Domain          string  `json:"domain"`
+	DraftID         string  `json:"draft_id"`
+	CustomerPlan    int     `json:"cus_plan"`
+	CustomerStatus  string  `json:"cus_status"`
+	TrailStart      float64 `json:"trail_start"`
+	TrailEnd        float64 `json:"trail_end"`
+	UseDB           string  `json:"use_db"`
+}
+
+// Calendar contains the calendar the charts and the dashboards of the account follow.
+type Calendar struct {
+	TimeZone    string `json:"timezone"`
+	WeekStart   int    `json:"week_start" validate:"gte=0,lte=6"`
+	FiscalStart int    `json:"fiscal_start" validate:"gte=1,lte=12"`
 }
 
 type LaunchAccount struct {
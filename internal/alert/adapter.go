@@ -0,0 +1,320 @@
+package alert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnknownSource is used when the alert source has no adapter.
+	ErrUnknownSource = errors.New("Alert source not supported")
+	// ErrNoFingerprint occurs when neither the fingerprint nor the labels are available to dedup the alert.
+	ErrNoFingerprint = errors.New("Alert fingerprint cannot be derived")
+)
+
+// Parse converts the payload of the source into the normalized alerts.
+// One payload can carry more than one alert (alertmanager, grafana unified alerting)
+func Parse(source string, body []byte) ([]NewAlert, error) {
+	var alerts []NewAlert
+	var err error
+	switch source {
+	case SourceAlertmanager:
+		alerts, err = parseAlertmanager(body)
+	case SourceGrafana:
+		alerts, err = parseGrafana(body)
+	case SourceDatadog:
+		alerts, err = parseDatadog(body)
+	case SourceGeneric, "":
+		alerts, err = parseGeneric(body)
+	default:
+		return nil, ErrUnknownSource
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s payload", source)
+	}
+
+	for i := range alerts {
+		if err := alerts[i].normalize(source); err != nil {
+			return nil, err
+		}
+	}
+	return alerts, nil
+}
+
+// Fingerprint derives the dedup key from the labels. The keys are sorted so the
+// order of the labels in the payload does not change the fingerprint.
+func Fingerprint(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, labels[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Normalize fills the defaults of the alert built outside of the adapters and derives its fingerprint.
+func Normalize(na NewAlert) (NewAlert, error) {
+	err := na.normalize(na.Source)
+	return na, err
+}
+
+func (na *NewAlert) normalize(source string) error {
+	if na.Source == "" {
+		na.Source = source
+	}
+	if na.Source == "" {
+		na.Source = SourceGeneric
+	}
+	if na.Labels == nil {
+		na.Labels = map[string]string{}
+	}
+	if na.Fields == nil {
+		na.Fields = map[string]interface{}{}
+	}
+
+	switch strings.ToLower(na.Status) {
+	case StatusResolved, "ok", "recovered", "resolve":
+		na.Status = StatusResolved
+	default:
+		na.Status = StatusFiring
+	}
+
+	if na.Name == "" {
+		na.Name = na.Labels["alertname"]
+	}
+
+	if na.Fingerprint == "" {
+		na.Fingerprint = Fingerprint(na.Labels)
+	}
+	if na.Fingerprint == "" {
+		return ErrNoFingerprint
+	}
+	return nil
+}
+
+// generic
+
+func parseGeneric(body []byte) ([]NewAlert, error) {
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		var alerts []NewAlert
+		err := json.Unmarshal(body, &alerts)
+		return alerts, err
+	}
+
+	var wrapper struct {
+		Alerts []NewAlert `json:"alerts"`
+	}
+	if err := json.Unmarshal(body, &wrapper); err == nil && len(wrapper.Alerts) > 0 {
+		return wrapper.Alerts, nil
+	}
+
+	var na NewAlert
+	if err := json.Unmarshal(body, &na); err != nil {
+		return nil, err
+	}
+	return []NewAlert{na}, nil
+}
+
+// alertmanager
+
+type amPayload struct {
+	Status            string            `json:"status"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	Alerts            []amAlert         `json:"alerts"`
+	// grafana legacy fields. unused by the alertmanager.
+	Title    string            `json:"title"`
+	RuleID   json.Number       `json:"ruleId"`
+	RuleName string            `json:"ruleName"`
+	State    string            `json:"state"`
+	Message  string            `json:"message"`
+	Tags     map[string]string `json:"tags"`
+}
+
+type amAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+	Fingerprint string            `json:"fingerprint"`
+}
+
+func parseAlertmanager(body []byte) ([]NewAlert, error) {
+	var p amPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	return p.alerts(), nil
+}
+
+func (p amPayload) alerts() []NewAlert {
+	alerts := make([]NewAlert, 0, len(p.Alerts))
+	for _, a := range p.Alerts {
+		labels := merge(p.CommonLabels, a.Labels)
+		annotations := merge(p.CommonAnnotations, a.Annotations)
+		status := a.Status
+		if status == "" {
+			status = p.Status
+		}
+		occurredAt := a.StartsAt
+		if status == StatusResolved && !a.EndsAt.IsZero() {
+			occurredAt = a.EndsAt
+		}
+		na := NewAlert{
+			Fingerprint: a.Fingerprint,
+			Status:      status,
+			Name:        labels["alertname"],
+			Description: firstNonEmpty(annotations["description"], annotations["summary"], annotations["message"]),
+			Severity:    labels["severity"],
+			Labels:      labels,
+			Fields:      fieldsFrom(annotations),
+		}
+		if !occurredAt.IsZero() {
+			na.OccurredAt = &occurredAt
+		}
+		alerts = append(alerts, na)
+	}
+	return alerts
+}
+
+// grafana
+
+// parseGrafana accepts both the unified alerting payload (alertmanager shape) and the legacy dashboard alert payload.
+func parseGrafana(body []byte) ([]NewAlert, error) {
+	var p amPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+	if len(p.Alerts) > 0 {
+		return p.alerts(), nil
+	}
+
+	labels := merge(nil, p.Tags)
+	if p.RuleID.String() != "" {
+		labels["rule_id"] = p.RuleID.String()
+	}
+	if p.RuleName != "" {
+		labels["alertname"] = p.RuleName
+	}
+	status := StatusFiring
+	if p.State == "ok" {
+		status = StatusResolved
+	}
+	return []NewAlert{{
+		Status:      status,
+		Name:        firstNonEmpty(p.RuleName, p.Title),
+		Description: p.Message,
+		Labels:      labels,
+	}}, nil
+}
+
+// datadog
+
+// ddPayload is the webhook payload expected from datadog. Datadog lets the user customize the payload
+// so the webhook integration should be configured with the template below.
+//
+//	{
+//	  "alert_id": "$ALERT_ID",
+//	  "aggreg_key": "$AGGREG_KEY",
+//	  "title": "$EVENT_TITLE",
+//	  "body": "$EVENT_MSG",
+//	  "alert_transition": "$ALERT_TRANSITION",
+//	  "priority": "$PRIORITY",
+//	  "hostname": "$HOSTNAME",
+//	  "tags": "$TAGS"
+//	}
+type ddPayload struct {
+	AlertID         string `json:"alert_id"`
+	AggregKey       string `json:"aggreg_key"`
+	Title           string `json:"title"`
+	Body            string `json:"body"`
+	AlertTransition string `json:"alert_transition"`
+	Priority        string `json:"priority"`
+	Hostname        string `json:"hostname"`
+	Tags            string `json:"tags"`
+}
+
+func parseDatadog(body []byte) ([]NewAlert, error) {
+	var p ddPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for _, tag := range strings.Split(p.Tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		kv := strings.SplitN(tag, ":", 2)
+		if len(kv) == 2 {
+			labels[kv[0]] = kv[1]
+		} else {
+			labels[kv[0]] = ""
+		}
+	}
+	if p.AlertID != "" {
+		labels["alert_id"] = p.AlertID
+	}
+	if p.Hostname != "" {
+		labels["host"] = p.Hostname
+	}
+
+	status := StatusFiring
+	if p.AlertTransition == "Recovered" {
+		status = StatusResolved
+	}
+	return []NewAlert{{
+		Fingerprint: firstNonEmpty(p.AggregKey, p.AlertID),
+		Status:      status,
+		Name:        p.Title,
+		Description: p.Body,
+		Severity:    p.Priority,
+		Labels:      labels,
+	}}, nil
+}
+
+func merge(common, specific map[string]string) map[string]string {
+	m := make(map[string]string, len(common)+len(specific))
+	for k, v := range common {
+		m[k] = v
+	}
+	for k, v := range specific {
+		m[k] = v
+	}
+	return m
+}
+
+func fieldsFrom(annotations map[string]string) map[string]interface{} {
+	fields := make(map[string]interface{}, len(annotations))
+	for k, v := range annotations {
+		fields[k] = v
+	}
+	return fields
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package alert_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/alert"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestParse(t *testing.T) {
+	t.Log("Given the need to parse the alert payloads of the monitoring tools")
+	{
+		t.Log("\twhen parsing the alertmanager payload")
+		{
+			body := `{"status":"firing","commonLabels":{"service":"api"},"alerts":[
+				{"status":"firing","labels":{"alertname":"HighCPU","instance":"web-1"},"annotations":{"summary":"cpu above 90%"},"startsAt":"2022-03-01T10:00:00Z"},
+				{"status":"resolved","labels":{"alertname":"HighCPU","instance":"web-2"},"annotations":{},"startsAt":"2022-03-01T09:00:00Z","endsAt":"2022-03-01T10:00:00Z","fingerprint":"fp2"}]}`
+			alerts, err := alert.Parse(alert.SourceAlertmanager, []byte(body))
+			if err != nil {
+				t.Fatalf("\t%s should parse the alertmanager payload - %s", tests.Failed, err)
+			}
+			if len(alerts) != 2 {
+				t.Fatalf("\t%s should parse two alerts. got %d", tests.Failed, len(alerts))
+			}
+			if alerts[0].Name != "HighCPU" || alerts[0].Labels["service"] != "api" || alerts[0].Description != "cpu above 90%" {
+				t.Fatalf("\t%s should merge the common labels and annotations. got %+v", tests.Failed, alerts[0])
+			}
+			if alerts[1].Status != alert.StatusResolved || alerts[1].Fingerprint != "fp2" {
+				t.Fatalf("\t%s should keep the resolved status and the fingerprint. got %+v", tests.Failed, alerts[1])
+			}
+			t.Logf("\t%s should parse the alertmanager payload", tests.Success)
+		}
+
+		t.Log("\twhen parsing the legacy grafana payload")
+		{
+			body := `{"title":"[OK] Disk full","ruleId":7,"ruleName":"Disk full","state":"ok","message":"disk usage back to normal","tags":{"host":"db-1"}}`
+			alerts, err := alert.Parse(alert.SourceGrafana, []byte(body))
+			if err != nil {
+				t.Fatalf("\t%s should parse the grafana payload - %s", tests.Failed, err)
+			}
+			if len(alerts) != 1 || alerts[0].Status != alert.StatusResolved || alerts[0].Labels["rule_id"] != "7" {
+				t.Fatalf("\t%s should parse the resolved grafana alert. got %+v", tests.Failed, alerts)
+			}
+			t.Logf("\t%s should parse the grafana payload", tests.Success)
+		}
+
+		t.Log("\twhen parsing the datadog payload")
+		{
+			body := `{"alert_id":"123","aggreg_key":"agg1","title":"Latency high","alert_transition":"Recovered","tags":"env:prod, team:core"}`
+			alerts, err := alert.Parse(alert.SourceDatadog, []byte(body))
+			if err != nil {
+				t.Fatalf("\t%s should parse the datadog payload - %s", tests.Failed, err)
+			}
+			if alerts[0].Fingerprint != "agg1" || alerts[0].Status != alert.StatusResolved || alerts[0].Labels["env"] != "prod" {
+				t.Fatalf("\t%s should parse the recovered datadog alert. got %+v", tests.Failed, alerts[0])
+			}
+			t.Logf("\t%s should parse the datadog payload", tests.Success)
+		}
+
+		t.Log("\twhen parsing the generic payload")
+		{
+			body := `[{"name":"Queue backlog","labels":{"queue":"emails"}},{"status":"resolved","fingerprint":"q1"}]`
+			alerts, err := alert.Parse(alert.SourceGeneric, []byte(body))
+			if err != nil {
+				t.Fatalf("\t%s should parse the generic payload - %s", tests.Failed, err)
+			}
+			if alerts[0].Status != alert.StatusFiring || alerts[0].Fingerprint == "" || alerts[1].Status != alert.StatusResolved {
+				t.Fatalf("\t%s should default the status and derive the fingerprint. got %+v", tests.Failed, alerts)
+			}
+			t.Logf("\t%s should parse the generic payload", tests.Success)
+		}
+
+		t.Log("\twhen parsing the payload without labels and fingerprint")
+		{
+			_, err := alert.Parse(alert.SourceGeneric, []byte(`{"name":"no dedup key"}`))
+			if err != alert.ErrNoFingerprint {
+				t.Fatalf("\t%s should fail without the dedup key. got %v", tests.Failed, err)
+			}
+			t.Logf("\t%s should fail without the dedup key", tests.Success)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Log("Given the need to dedup the alerts using the labels")
+	{
+		t.Log("\twhen the same labels are passed in a different order")
+		{
+			f1 := alert.Fingerprint(map[string]string{"a": "1", "b": "2"})
+			f2 := alert.Fingerprint(map[string]string{"b": "2", "a": "1"})
+			f3 := alert.Fingerprint(map[string]string{"a": "1", "b": "3"})
+			if f1 != f2 || f1 == f3 {
+				t.Fatalf("\t%s should derive the same fingerprint only for the same labels", tests.Failed)
+			}
+			t.Logf("\t%s should derive the same fingerprint only for the same labels", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/timeseries"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when a specific alert is requested but does not exist.
+	ErrNotFound = errors.New("Alert not found")
+	// ErrAlreadyFiring is used when another firing alert holds the fingerprint.
+	ErrAlreadyFiring = errors.New("Alert already firing")
+)
+
+// Ingest saves the normalized alert in to the entity. A firing alert with the fingerprint of an open
+// alert increments the counter of the open alert. A resolved alert closes the open alert.
+// Newly created alerts are grouped into the incidents using the alert rules of the entity.
+func Ingest(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, entityID string, na NewAlert, fbSDKPath string, now time.Time) (Alert, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.Ingest")
+	defer span.End()
+
+	if na.OccurredAt != nil {
+		now = *na.OccurredAt
+	}
+
+	open, err := RetrieveOpen(ctx, db, accountID, na.Fingerprint)
+	if err != nil && err != ErrNotFound {
+		return Alert{}, err
+	}
+
+	if na.Status == StatusResolved {
+		if err == ErrNotFound {
+			log.Printf("internal.alert : resolve event for the fingerprint %s has no open alert\n", na.Fingerprint)
+			return Alert{}, ErrNotFound
+		}
+		return resolve(ctx, db, sdb, open, fbSDKPath, now)
+	}
+
+	if err == nil {
+		return repeat(ctx, db, sdb, open, now)
+	}
+
+	return create(ctx, db, sdb, accountID, entityID, na, fbSDKPath, now)
+}
+
+// Create inserts the new alert. It returns ErrAlreadyFiring when a firing alert with the same
+// fingerprint exists, so concurrent deliveries of the same alert end up on one row.
+func Create(ctx context.Context, db *sqlx.DB, a Alert) (Alert, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.Create")
+	defer span.End()
+
+	const q = `INSERT INTO alerts
+		(alert_id, account_id, entity_id, item_id, rule_id, incident_id, fingerprint, source, status, name, count, labelsb, first_seen, last_seen)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (account_id, fingerprint) WHERE status = 'firing' DO NOTHING`
+
+	res, err := db.ExecContext(
+		ctx, q,
+		a.ID, a.AccountID, a.EntityID, a.ItemID, a.RuleID, a.IncidentID, a.Fingerprint, a.Source, a.Status, a.Name, a.Count, a.Labelsb,
+		a.FirstSeen, a.LastSeen,
+	)
+	if err != nil {
+		return Alert{}, errors.Wrap(err, "inserting alert")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return Alert{}, ErrAlreadyFiring
+	}
+
+	return a, nil
+}
+
+// Delete removes the alert.
+func Delete(ctx context.Context, db *sqlx.DB, accountID, alertID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.Delete")
+	defer span.End()
+
+	const q = `DELETE FROM alerts WHERE account_id = $1 AND alert_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, alertID); err != nil {
+		return errors.Wrap(err, "deleting alert")
+	}
+	return nil
+}
+
+// SetItem links the alert with the item created for it.
+func SetItem(ctx context.Context, db *sqlx.DB, accountID, alertID, itemID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.SetItem")
+	defer span.End()
+
+	const q = `UPDATE alerts SET "item_id" = $3 WHERE account_id = $1 AND alert_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, alertID, itemID); err != nil {
+		return errors.Wrap(err, "linking alert item")
+	}
+	return nil
+}
+
+// RetrieveOpen gets the firing alert of the fingerprint.
+func RetrieveOpen(ctx context.Context, db *sqlx.DB, accountID, fingerprint string) (Alert, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.RetrieveOpen")
+	defer span.End()
+
+	var a Alert
+	const q = `SELECT * FROM alerts WHERE account_id = $1 AND fingerprint = $2 AND status = $3`
+	if err := db.GetContext(ctx, &a, q, accountID, fingerprint, StatusFiring); err != nil {
+		if err == sql.ErrNoRows {
+			return Alert{}, ErrNotFound
+		}
+		return Alert{}, errors.Wrapf(err, "selecting open alert %q", fingerprint)
+	}
+
+	return a, nil
+}
+
+// List returns the recent alerts of the entity.
+func List(ctx context.Context, db *sqlx.DB, accountID, entityID string, limit int) ([]Alert, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.List")
+	defer span.End()
+
+	alerts := []Alert{}
+	const q = `SELECT * FROM alerts WHERE account_id = $1 AND entity_id = $2 ORDER BY last_seen DESC LIMIT $3`
+	if err := db.SelectContext(ctx, &alerts, q, accountID, entityID, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting alerts")
+	}
+
+	return alerts, nil
+}
+
+// Increment bumps the counter of the open alert.
+func Increment(ctx context.Context, db *sqlx.DB, accountID, alertID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.Increment")
+	defer span.End()
+
+	const q = `UPDATE alerts SET
+		"count" = count + 1,
+		"last_seen" = $3
+		WHERE account_id = $1 AND alert_id = $2`
+	_, err := db.ExecContext(ctx, q, accountID, alertID, now.UTC())
+	if err != nil {
+		return errors.Wrap(err, "incrementing alert")
+	}
+	return nil
+}
+
+// Resolve closes the open alert.
+func Resolve(ctx context.Context, db *sqlx.DB, accountID, alertID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.Resolve")
+	defer span.End()
+
+	const q = `UPDATE alerts SET
+		"status" = $3,
+		"last_seen" = $4,
+		"resolved_at" = $4
+		WHERE account_id = $1 AND alert_id = $2`
+	_, err := db.ExecContext(ctx, q, accountID, alertID, StatusResolved, now.UTC())
+	if err != nil {
+		return errors.Wrap(err, "resolving alert")
+	}
+	return nil
+}
+
+// Attach links the alert with the incident created/matched by the rule.
+func Attach(ctx context.Context, db *sqlx.DB, accountID, alertID, ruleID, incidentID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.Attach")
+	defer span.End()
+
+	const q = `UPDATE alerts SET
+		"rule_id" = $3,
+		"incident_id" = $4
+		WHERE account_id = $1 AND alert_id = $2`
+	_, err := db.ExecContext(ctx, q, accountID, alertID, ruleID, incidentID)
+	if err != nil {
+		return errors.Wrap(err, "attaching alert")
+	}
+	return nil
+}
+
+func create(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, entityID string, na NewAlert, fbSDKPath string, now time.Time) (Alert, error) {
+	e, err := entity.Retrieve(ctx, accountID, entityID, db, sdb)
+	if err != nil {
+		return Alert{}, err
+	}
+
+	labelsBytes, err := json.Marshal(na.Labels)
+	if err != nil {
+		return Alert{}, errors.Wrap(err, "encode labels to bytes")
+	}
+
+	// the alert row claims the fingerprint before the item gets created. A concurrent delivery of
+	// the same alert loses the claim and counts as a repeat of the winner instead of leaving an orphan item.
+	a, err := Create(ctx, db, Alert{
+		ID:          uuid.New().String(),
+		AccountID:   accountID,
+		EntityID:    entityID,
+		Fingerprint: na.Fingerprint,
+		Source:      na.Source,
+		Status:      StatusFiring,
+		Name:        na.Name,
+		Count:       1,
+		Labelsb:     string(labelsBytes),
+		FirstSeen:   now.UTC(),
+		LastSeen:    now.UTC(),
+	})
+	if err == ErrAlreadyFiring {
+		open, err := RetrieveOpen(ctx, db, accountID, na.Fingerprint)
+		if err != nil {
+			return Alert{}, err
+		}
+		return repeat(ctx, db, sdb, open, now)
+	}
+	if err != nil {
+		return Alert{}, err
+	}
+
+	namedFieldsMap := namedFields(na)
+
+	if e.FlowField() != nil {
+		flows, err := flow.List(ctx, []string{e.ID}, flow.FlowModePipeLine, flow.FlowTypeEventCreate, db)
+		if err != nil {
+			return Alert{}, discard(ctx, db, a, err)
+		}
+		if len(flows) > 0 {
+			namedFieldsMap[e.FlowField().Name] = []interface{}{flows[0].ID}
+		}
+	}
+
+	name := na.Name
+	if name == "" {
+		name = "System Generated"
+	}
+	userID := user.UUID_ENGINE_USER //system user stops workflow from executing hence engine user
+	ni := item.NewItem{
+		ID:        uuid.New().String(),
+		Name:      &name,
+		AccountID: accountID,
+		EntityID:  entityID,
+		UserID:    &userID,
+		Fields:    keyMap(e.NameKeyMapWrapper(), namedFieldsMap),
+		Type:      item.TypeDefault,
+	}
+	it, err := item.Create(ctx, db, ni, now)
+	if err != nil {
+		return Alert{}, discard(ctx, db, a, err)
+	}
+
+	if err := SetItem(ctx, db, accountID, a.ID, it.ID); err != nil {
+		return Alert{}, err
+	}
+	a.ItemID = &it.ID
+
+	source, err := group(ctx, db, sdb, &a, fbSDKPath, now)
+	if err != nil {
+		// grouping failure should not drop the alert.
+		log.Println("***> unexpected error occurred in internal.alert when grouping the alert. error: ", err)
+	}
+
+	go job.NewJob(db, sdb, fbSDKPath).Stream(stream.NewCreteItemMessage(ctx, db, accountID, userID, entityID, it.ID, source))
+	return a, nil
+}
+
+// discard releases the fingerprint claimed by the alert when its item could not be created.
+func discard(ctx context.Context, db *sqlx.DB, a Alert, cause error) error {
+	if err := Delete(ctx, db, a.AccountID, a.ID); err != nil {
+		log.Println("***> unexpected error occurred in internal.alert when discarding the alert. error: ", err)
+	}
+	return cause
+}
+
+func repeat(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, a Alert, now time.Time) (Alert, error) {
+	if err := Increment(ctx, db, a.AccountID, a.ID, now); err != nil {
+		return Alert{}, err
+	}
+	a.Count = a.Count + 1
+	a.LastSeen = now.UTC()
+
+	if a.ItemID != nil {
+		if err := updateOccurrence(ctx, db, sdb, a); err != nil {
+			return Alert{}, err
+		}
+		nt := timeseries.NewTimeseries{
+			ID:         uuid.New().String(),
+			AccountID:  a.AccountID,
+			EntityID:   a.EntityID,
+			Type:       timeseries.TypeIncident,
+			Event:      a.Source + " alert",
+			Count:      1,
+			Tags:       []string{},
+			Identifier: a.ItemID,
+		}
+		if _, err := timeseries.Create(ctx, db, nt, now); err != nil {
+			return Alert{}, err
+		}
+	}
+	return a, nil
+}
+
+func resolve(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, a Alert, fbSDKPath string, now time.Time) (Alert, error) {
+	if err := Resolve(ctx, db, a.AccountID, a.ID, now); err != nil {
+		return Alert{}, err
+	}
+	a.Status = StatusResolved
+	a.LastSeen = now.UTC()
+	resolvedAt := now.UTC()
+	a.ResolvedAt = &resolvedAt
+
+	if a.ItemID == nil {
+		return a, nil
+	}
+
+	e, err := entity.Retrieve(ctx, a.AccountID, a.EntityID, db, sdb)
+	if err != nil {
+		return Alert{}, err
+	}
+	statusField := e.WhoField(entity.WhoStatus)
+	if statusField.Key == "" {
+		return a, nil
+	}
+	doneID, err := entity.DiscoverDoneStatusID(ctx, a.AccountID, statusField.RefID, db, sdb)
+	if err != nil {
+		log.Println("***> unexpected error occurred in internal.alert when discovering the done status. error: ", err)
+		return a, nil
+	}
+
+	it, err := item.Retrieve(ctx, a.AccountID, a.EntityID, *a.ItemID, db)
+	if err != nil {
+		return Alert{}, err
+	}
+	oldFields := it.Fields()
+	newFields := it.Fields()
+	newFields[statusField.Key] = []interface{}{doneID}
+	if _, err := item.UpdateFields(ctx, db, a.AccountID, a.EntityID, it.ID, newFields); err != nil {
+		return Alert{}, err
+	}
+
+	go job.NewJob(db, sdb, fbSDKPath).Stream(stream.NewUpdateItemMessage(ctx, db, a.AccountID, user.UUID_ENGINE_USER, a.EntityID, it.ID, newFields, oldFields))
+	return a, nil
+}
+
+func updateOccurrence(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, a Alert) error {
+	it, err := item.Retrieve(ctx, a.AccountID, a.EntityID, *a.ItemID, db)
+	if err != nil {
+		return err
+	}
+	e, err := entity.Retrieve(ctx, a.AccountID, a.EntityID, db, sdb)
+	if err != nil {
+		return err
+	}
+	key := e.Key(fieldOccurrence)
+	if key == "" {
+		return nil
+	}
+	fields := it.Fields()
+	fields[key] = a.Count
+	_, err = item.UpdateFields(ctx, db, a.AccountID, a.EntityID, it.ID, fields)
+	return err
+}
+
+// namedFields are the values of the alert item by the names of the fields of the alert entity. The fields
+// sent explicitly win over the name, the description and the severity the adapters parse.
+func namedFields(na NewAlert) map[string]interface{} {
+	namedFieldsMap := make(map[string]interface{}, len(na.Fields)+5)
+	for name, v := range na.Fields {
+		namedFieldsMap[name] = v
+	}
+	for name, v := range map[string]string{fieldAlertName: na.Name, fieldDescription: na.Description, fieldSeverity: na.Severity} {
+		if _, ok := namedFieldsMap[name]; !ok && v != "" {
+			namedFieldsMap[name] = v
+		}
+	}
+	namedFieldsMap[fieldOccurrence] = 1
+	namedFieldsMap[fieldUnique] = na.Fingerprint
+	return namedFieldsMap
+}
+
+func keyMap(namedKeys map[string]string, namedVals map[string]interface{}) map[string]interface{} {
+	itemVals := make(map[string]interface{}, 0)
+	for name, key := range namedKeys {
+		itemVals[key] = namedVals[name]
+	}
+	return itemVals
+}
@@ -0,0 +1,25 @@
+package alert
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestNamedFields(t *testing.T) {
+	t.Log("Given the need to keep what the adapters parse on the alert item")
+	{
+		na := NewAlert{Fingerprint: "fp1", Name: "HighCPU", Description: "cpu above 90%", Severity: "critical"}
+		named := namedFields(na)
+		if named[fieldAlertName] != "HighCPU" || named[fieldDescription] != "cpu above 90%" || named[fieldSeverity] != "critical" || named[fieldUnique] != "fp1" {
+			t.Fatalf("\t%s should map the name, the description and the severity. got %v", tests.Failed, named)
+		}
+		t.Logf("\t%s should map the name, the description and the severity", tests.Success)
+
+		na.Fields = map[string]interface{}{fieldSeverity: "sev1"}
+		if named := namedFields(na); named[fieldSeverity] != "sev1" {
+			t.Fatalf("\t%s should keep the field sent explicitly. got %v", tests.Failed, named[fieldSeverity])
+		}
+		t.Logf("\t%s should keep the field sent explicitly", tests.Success)
+	}
+}
@@ -0,0 +1,99 @@
+package alert
+
+import "time"
+
+// Status of the alert. The same values are used by alertmanager and the generic schema.
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Sources supported by the ingestion endpoint.
+const (
+	SourceGeneric      = "generic"
+	SourceAlertmanager = "alertmanager"
+	SourceGrafana      = "grafana"
+	SourceDatadog      = "datadog"
+	SourceCloudWatch   = "cloudwatch"
+)
+
+// Alert keeps the dedup state of an alert item. Repeated alerts with the same
+// fingerprint increment the count of the open alert instead of creating a new item.
+type Alert struct {
+	ID          string     `db:"alert_id" json:"alert_id"`
+	AccountID   string     `db:"account_id" json:"account_id"`
+	EntityID    string     `db:"entity_id" json:"entity_id"`
+	ItemID      *string    `db:"item_id" json:"item_id"`         // * because it could be null
+	RuleID      *string    `db:"rule_id" json:"rule_id"`         // * because it could be null
+	IncidentID  *string    `db:"incident_id" json:"incident_id"` // * because it could be null
+	Fingerprint string     `db:"fingerprint" json:"fingerprint"`
+	Source      string     `db:"source" json:"source"`
+	Status      string     `db:"status" json:"status"`
+	Name        string     `db:"name" json:"name"`
+	Count       int        `db:"count" json:"count"`
+	Labelsb     string     `db:"labelsb" json:"labelsb"`
+	FirstSeen   time.Time  `db:"first_seen" json:"first_seen"`
+	LastSeen    time.Time  `db:"last_seen" json:"last_seen"`
+	ResolvedAt  *time.Time `db:"resolved_at" json:"resolved_at"`
+}
+
+// NewAlert is the normalized form of the alert every adapter produces.
+// It is also the documented generic JSON schema accepted at /alerts/:account_key/generic
+//
+//	{
+//	  "fingerprint": "optional. derived from the labels when empty",
+//	  "status": "firing | resolved",
+//	  "name": "High CPU on web-1",
+//	  "description": "cpu above 90% for 5m",
+//	  "severity": "critical",
+//	  "labels": {"host": "web-1", "service": "api"},
+//	  "fields": {"<entity field name>": "value"},
+//	  "occurred_at": "2006-01-02T15:04:05Z"
+//	}
+type NewAlert struct {
+	Fingerprint string                 `json:"fingerprint"`
+	Source      string                 `json:"source"`
+	Status      string                 `json:"status"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Severity    string                 `json:"severity"`
+	Labels      map[string]string      `json:"labels"`
+	Fields      map[string]interface{} `json:"fields"`
+	OccurredAt  *time.Time             `json:"occurred_at"`
+}
+
+// Rule groups the incoming alerts of an entity into the incident entity when the expression matches.
+// Alerts matched within the window of an open incident are attached to the same incident.
+type Rule struct {
+	ID               string    `db:"rule_id" json:"id"`
+	AccountID        string    `db:"account_id" json:"account_id"`
+	EntityID         string    `db:"entity_id" json:"entity_id"`
+	IncidentEntityID string    `db:"incident_entity_id" json:"incident_entity_id"`
+	Name             string    `db:"name" json:"name"`
+	Expression       string    `db:"expression" json:"expression"`
+	WindowMins       int       `db:"window_mins" json:"window_mins"`
+	Position         int       `db:"position" json:"position"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt        int64     `db:"updated_at" json:"updated_at"`
+}
+
+// NewRule contains information needed to create a new alert rule.
+type NewRule struct {
+	ID               string `json:"id"`
+	AccountID        string `json:"account_id"`
+	EntityID         string `json:"entity_id"`
+	IncidentEntityID string `json:"incident_entity_id" validate:"required"`
+	Name             string `json:"name" validate:"required"`
+	Expression       string `json:"expression"`
+	WindowMins       int    `json:"window_mins"`
+	Position         int    `json:"position"`
+}
+
+// Named fields of the alert entity which gets populated during ingestion.
+const (
+	fieldAlertName   = "alert_name"
+	fieldDescription = "description"
+	fieldSeverity    = "severity"
+	fieldOccurrence  = "occurrence"
+	fieldUnique      = "unique"
+)
@@ -0,0 +1,189 @@
+package alert
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrRuleNotFound is used when a specific rule is requested but does not exist.
+	ErrRuleNotFound = errors.New("Alert rule not found")
+)
+
+// defaultWindowMins is the window used when the rule does not specify one.
+const defaultWindowMins = 60
+
+// CreateRule inserts the alert rule.
+func CreateRule(ctx context.Context, db *sqlx.DB, nr NewRule, now time.Time) (Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.CreateRule")
+	defer span.End()
+
+	if nr.ID == "" {
+		nr.ID = uuid.New().String()
+	}
+	if nr.WindowMins <= 0 {
+		nr.WindowMins = defaultWindowMins
+	}
+
+	r := Rule{
+		ID:               nr.ID,
+		AccountID:        nr.AccountID,
+		EntityID:         nr.EntityID,
+		IncidentEntityID: nr.IncidentEntityID,
+		Name:             nr.Name,
+		Expression:       nr.Expression,
+		WindowMins:       nr.WindowMins,
+		Position:         nr.Position,
+		CreatedAt:        now.UTC(),
+		UpdatedAt:        now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO alert_rules
+		(rule_id, account_id, entity_id, incident_entity_id, name, expression, window_mins, position, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := db.ExecContext(
+		ctx, q,
+		r.ID, r.AccountID, r.EntityID, r.IncidentEntityID, r.Name, r.Expression, r.WindowMins, r.Position,
+		r.CreatedAt, r.UpdatedAt,
+	)
+	if err != nil {
+		return Rule{}, errors.Wrap(err, "inserting alert rule")
+	}
+
+	return r, nil
+}
+
+// ListRules returns the rules of the alert entity in the order of evaluation.
+func ListRules(ctx context.Context, db *sqlx.DB, accountID, entityID string) ([]Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.ListRules")
+	defer span.End()
+
+	rules := []Rule{}
+	const q = `SELECT * FROM alert_rules WHERE account_id = $1 AND entity_id = $2 ORDER BY position, created_at`
+	if err := db.SelectContext(ctx, &rules, q, accountID, entityID); err != nil {
+		return nil, errors.Wrap(err, "selecting alert rules")
+	}
+
+	return rules, nil
+}
+
+// DeleteRule removes the alert rule.
+func DeleteRule(ctx context.Context, db *sqlx.DB, accountID, ruleID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.DeleteRule")
+	defer span.End()
+
+	const q = `DELETE FROM alert_rules WHERE account_id = $1 AND rule_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, ruleID); err != nil {
+		return errors.Wrapf(err, "deleting alert rule %s", ruleID)
+	}
+
+	return nil
+}
+
+// openIncident returns the incident of the rule which received an alert within the window.
+func openIncident(ctx context.Context, db *sqlx.DB, r Rule, now time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.alert.openIncident")
+	defer span.End()
+
+	var incidentID string
+	since := now.UTC().Add(-time.Duration(r.WindowMins) * time.Minute)
+	const q = `SELECT incident_id FROM alerts WHERE account_id = $1 AND rule_id = $2 AND incident_id IS NOT NULL AND status = $3 AND last_seen > $4 ORDER BY last_seen DESC LIMIT 1`
+	if err := db.GetContext(ctx, &incidentID, q, r.AccountID, r.ID, StatusFiring, since); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "selecting open incident")
+	}
+	return incidentID, nil
+}
+
+// group evaluates the rules of the alert entity and attaches the alert to the incident of the first matching rule.
+// The incident is created when the rule has no open incident within its window. It returns the source
+// to be used in the create message of the alert item so that the connection with the incident gets created.
+func group(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, a *Alert, fbSDKPath string, now time.Time) (map[string][]string, error) {
+	source := map[string][]string{}
+	rules, err := ListRules(ctx, db, a.AccountID, a.EntityID)
+	if err != nil {
+		return source, err
+	}
+
+	eng := engine.Engine{
+		Job: job.NewJob(db, sdb, fbSDKPath),
+	}
+	for _, r := range rules {
+		if r.Expression != "" && !eng.RunExpEvaluator(ctx, db, sdb, a.AccountID, r.Expression, map[string]interface{}{a.EntityID: *a.ItemID}) {
+			continue
+		}
+
+		incidentID, err := openIncident(ctx, db, r, now)
+		if err != nil {
+			return source, err
+		}
+
+		if incidentID != "" {
+			//existing incident gets connected from the alert item
+			source[r.IncidentEntityID] = []string{incidentID}
+		} else {
+			incidentID, err = createIncident(ctx, db, sdb, r, *a, fbSDKPath, now)
+			if err != nil {
+				return source, err
+			}
+		}
+
+		if err := Attach(ctx, db, a.AccountID, a.ID, r.ID, incidentID); err != nil {
+			return source, err
+		}
+		a.RuleID = &r.ID
+		a.IncidentID = &incidentID
+		break
+	}
+	return source, nil
+}
+
+func createIncident(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, r Rule, a Alert, fbSDKPath string, now time.Time) (string, error) {
+	e, err := entity.Retrieve(ctx, a.AccountID, r.IncidentEntityID, db, sdb)
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]interface{}{}
+	titleField := entity.TitleField(e.EasyFields())
+	if titleField.Key != "" {
+		fields[titleField.Key] = a.Name
+	}
+
+	name := a.Name
+	userID := user.UUID_ENGINE_USER
+	ni := item.NewItem{
+		ID:        uuid.New().String(),
+		Name:      &name,
+		AccountID: a.AccountID,
+		EntityID:  e.ID,
+		UserID:    &userID,
+		Fields:    fields,
+		Type:      item.TypeDefault,
+	}
+	it, err := item.Create(ctx, db, ni, now)
+	if err != nil {
+		return "", err
+	}
+
+	//new incident gets connected with the alert item which created it
+	source := map[string][]string{a.EntityID: {*a.ItemID}}
+	go job.NewJob(db, sdb, fbSDKPath).Stream(stream.NewCreteItemMessage(ctx, db, a.AccountID, userID, e.ID, it.ID, source))
+	return it.ID, nil
+}
@@ -2,23 +2,14 @@ package aws
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
-	"gitlab.com/vjsideprojects/relay/internal/account"
-	"gitlab.com/vjsideprojects/relay/internal/entity"
-	"gitlab.com/vjsideprojects/relay/internal/item"
-	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/alert"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
-	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
-	"gitlab.com/vjsideprojects/relay/internal/platform/graphdb"
-	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
-	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
-	"gitlab.com/vjsideprojects/relay/internal/timeseries"
-	"gitlab.com/vjsideprojects/relay/internal/user"
 )
 
 // Subscription decribes the aws sns object
@@ -69,123 +60,63 @@ type Dimension struct {
 	Name  string `json:"name"`
 }
 
+// SaveAlert saves the firing alert described by the named fields. The field "block" carries the entity
+// and the field "unique" is used as the fingerprint to dedup the repeated alerts.
 func SaveAlert(ctx context.Context, accountID string, namedFieldsMap map[string]interface{}, db *sqlx.DB, sdb *database.SecDB, fbSDKPath string) error {
-	uniqueID := namedFieldsMap["unique"]
-	entityID := namedFieldsMap["block"]
-
-	if entityID == nil || entityID == "" {
-		return errors.New("paramater block is missing")
-	}
+	return saveAlert(ctx, accountID, alert.StatusFiring, nil, namedFieldsMap, db, sdb, fbSDKPath)
+}
 
-	e, err := entity.Retrieve(ctx, accountID, entityID.(string), db, sdb)
+// SaveAlarm saves the cloudwatch alarm. The alarm description carries the named fields in JSON.
+// The alarm moving to the OK state resolves the open alert.
+func SaveAlarm(ctx context.Context, accountID string, msg Message, db *sqlx.DB, sdb *database.SecDB, fbSDKPath string) error {
+	namedFieldsMap := map[string]interface{}{}
+	err := json.Unmarshal([]byte(msg.AlarmDescription), &namedFieldsMap)
 	if err != nil {
 		return err
 	}
 
-	if e.FlowField() != nil {
-		flows, err := flow.List(ctx, []string{e.ID}, flow.FlowModePipeLine, flow.FlowTypeEventCreate, db)
-		if err != nil {
-			return err
-		}
-		if len(flows) > 0 {
-			namedFieldsMap[e.FlowField().Name] = []interface{}{flows[0].ID}
-		}
+	status := alert.StatusFiring
+	if msg.NewStateValue == "OK" {
+		status = alert.StatusResolved
 	}
-
-	fields := keyMap(e.NameKeyMapWrapper(), namedFieldsMap)
-
-	itemID, err := findDuplicate(ctx, accountID, entityID, uniqueID, db, sdb)
-	if err != nil {
-		return err
+	labels := map[string]string{
+		"alertname":      msg.AlarmName,
+		"aws_account_id": msg.AWSAccountID,
+		"region":         msg.Region,
 	}
-
-	if itemID != nil {
-		return createItemDupEvent(ctx, accountID, entityID.(string), itemID, db)
-	} else {
-		return createItem(ctx, accountID, entityID.(string), fields, db, sdb, fbSDKPath)
-	}
-
+	return saveAlert(ctx, accountID, status, labels, namedFieldsMap, db, sdb, fbSDKPath)
 }
 
-func createItemDupEvent(ctx context.Context, accountID, entityID string, itemID *string, db *sqlx.DB) error {
-	log.Println("Coming to createItemDupEvent")
-	now := time.Now()
-	nt := timeseries.NewTimeseries{
-		ID:          uuid.New().String(),
-		AccountID:   accountID,
-		EntityID:    entityID,
-		Type:        timeseries.TypeIncident,
-		Event:       "AWS incident",
-		Description: "",
-		Count:       1,
-		Tags:        []string{},
-		Identifier:  itemID,
-		Fields:      nil,
-	}
-	_, err := timeseries.Create(ctx, db, nt, now)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func createItem(ctx context.Context, accountID, entityID string, fields map[string]interface{}, db *sqlx.DB, sdb *database.SecDB, fbSDKPath string) error {
-	name := "System Generated"
-	userID := user.UUID_ENGINE_USER //system user stops workflow from executing hence engine user
-	ni := item.NewItem{
-		ID:        uuid.New().String(),
-		Name:      &name,
-		AccountID: accountID,
-		EntityID:  entityID,
-		UserID:    &userID,
-		Fields:    fields,
-		Source:    nil,
-		Type:      item.TypeDefault,
+func saveAlert(ctx context.Context, accountID, status string, labels map[string]string, namedFieldsMap map[string]interface{}, db *sqlx.DB, sdb *database.SecDB, fbSDKPath string) error {
+	entityID, _ := namedFieldsMap["block"].(string)
+	if entityID == "" {
+		return errors.New("paramater block is missing")
 	}
+	delete(namedFieldsMap, "block")
 
-	it, err := item.Create(ctx, db, ni, time.Now())
-	if err != nil {
-		return err
+	na := alert.NewAlert{
+		Source: alert.SourceCloudWatch,
+		Status: status,
+		Labels: labels,
+		Fields: namedFieldsMap,
 	}
-
-	go job.NewJob(db, sdb, fbSDKPath).Stream(stream.NewCreteItemMessage(ctx, db, accountID, userID, entityID, it.ID, ni.Source))
-	return nil
-}
-
-func findDuplicate(ctx context.Context, accountID string, entityID, uniqueValue interface{}, db *sqlx.DB, sdb *database.SecDB) (*string, error) {
-	if uniqueValue == nil || uniqueValue == "" {
-		return nil, nil
-	} else if entityID == nil || entityID == "" {
-		return nil, nil
+	if uniqueID, ok := namedFieldsMap["unique"]; ok && uniqueID != nil {
+		na.Fingerprint = fmt.Sprint(uniqueID)
 	}
-
-	conditionFields := make([]graphdb.Field, 0)
-	gf := graphdb.Field{
-		Expression: "=",
-		Key:        "unique",
-		DataType:   graphdb.DType(graphdb.TypeString),
-		Value:      uniqueValue,
+	if name, ok := namedFieldsMap["alert_name"].(string); ok {
+		na.Name = name
+	} else if name, ok := namedFieldsMap["incident_name"].(string); ok {
+		na.Name = name
 	}
-	conditionFields = append(conditionFields, gf)
 
-	useDB := account.UseDB(ctx, db, accountID)
-	items, _, err := dbservice.NewDBservice(useDB, db, sdb).Result(ctx, accountID, entityID.(string), "", "", 0, false, false, conditionFields)
+	na, err := alert.Normalize(na)
 	if err != nil {
-		return nil, err
-	}
-	if len(items) > 0 {
-		return &items[0].ID, nil
-	} else {
-		return nil, nil
+		return err
 	}
 
-}
-
-func keyMap(namedKeys map[string]string, namedVals map[string]interface{}) map[string]interface{} {
-	itemVals := make(map[string]interface{}, 0)
-	for name, key := range namedKeys {
-		itemVals[key] = namedVals[name]
+	_, err = alert.Ingest(ctx, db, sdb, accountID, entityID, na, fbSDKPath, time.Now())
+	if err == alert.ErrNotFound {
+		return nil
 	}
-	return itemVals
+	return err
 }
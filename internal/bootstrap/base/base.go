@@ -17,6 +17,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/item"
 	"gitlab.com/vjsideprojects/relay/internal/job"
 	"gitlab.com/vjsideprojects/relay/internal/layout"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
 	"gitlab.com/vjsideprojects/relay/internal/relationship"
@@ -119,6 +120,7 @@ type CoreNode struct {
 	Type       int
 	Nodes      []*CoreNode // nodes inside stages
 	Tokens     map[string]interface{}
+	Stage      *pipeline.NewStage // probability and expected days of the stage
 }
 
 func NewBase(accountID, teamID, userID string, db *sqlx.DB, sdb *database.SecDB, firebaseSDKPath string) *Base {
@@ -3,10 +3,12 @@ package base
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gitlab.com/vjsideprojects/relay/internal/entity"
 	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
 	"gitlab.com/vjsideprojects/relay/internal/platform/integration"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
@@ -37,6 +39,11 @@ func (b *Base) AddPipelines(ctx context.Context, cwf *CoreWorkflow) error {
 			return err
 		}
 		cn.NodeID = n.ID
+		if cn.Stage != nil {
+			if _, err := pipeline.SaveStage(ctx, b.DB, b.AccountID, cwf.FlowID, n.ID, *cn.Stage, time.Now()); err != nil {
+				return err
+			}
+		}
 
 		for j := 0; j < len(cn.Nodes); j++ {
 			nus := cn.Nodes[j] // node under the stage
@@ -84,6 +84,11 @@ func Bootstrap(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, firebaseSD
 		return errors.Wrap(err, "delay bootstrap failed")
 	}
 
+	err = BootstrapScheduleEntity(ctx, b)
+	if err != nil {
+		return errors.Wrap(err, "schedule bootstrap failed")
+	}
+
 	return nil
 }
 
@@ -206,6 +211,12 @@ func BootstrapDelayEntity(ctx context.Context, b *base.Base) error {
 	return err
 }
 
+func BootstrapScheduleEntity(ctx context.Context, b *base.Base) error {
+	// add entity - schedule
+	_, err := b.EntityAdd(ctx, uuid.New().String(), entity.FixedEntitySchedule, "Schedule Timer", entity.CategorySchedule, entity.StateAccountLevel, false, false, true, forms.ScheduleFields())
+	return err
+}
+
 func CurrentOwner(ctx context.Context, db *sqlx.DB, accountID, teamID string) (string, string, error) {
 	ownerEntity, err := entity.RetrieveFixedEntity(ctx, db, accountID, teamID, entity.FixedEntityOwner)
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"gitlab.com/vjsideprojects/relay/internal/bootstrap/base"
 	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
 	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
 	"gitlab.com/vjsideprojects/relay/internal/rule/node"
 )
@@ -192,6 +193,7 @@ func salesPipeline(ctx context.Context, b *base.Base, dealEntity, taskEntity ent
 				Name:      "Opportunity",
 				ActorID:   "00000000-0000-0000-0000-000000000000",
 				ActorName: "Deals",
+				Stage:     &pipeline.NewStage{Probability: 10, ExpectedDays: 14},
 				Nodes: []*base.CoreNode{
 					{
 						Name:       "Schedule a call",
@@ -205,6 +207,7 @@ func salesPipeline(ctx context.Context, b *base.Base, dealEntity, taskEntity ent
 				Name:      "Interested",
 				ActorID:   "00000000-0000-0000-0000-000000000000",
 				ActorName: "Deals",
+				Stage:     &pipeline.NewStage{Probability: 25, ExpectedDays: 14},
 				Nodes: []*base.CoreNode{
 					{
 						Name:       "Prepare pricing deck",
@@ -218,6 +221,7 @@ func salesPipeline(ctx context.Context, b *base.Base, dealEntity, taskEntity ent
 				Name:      "Qualified",
 				ActorID:   "00000000-0000-0000-0000-000000000000",
 				ActorName: "Deals",
+				Stage:     &pipeline.NewStage{Probability: 60, ExpectedDays: 21, Category: pipeline.CategoryBestCase},
 				Nodes: []*base.CoreNode{
 					{
 						Name:       "Initimate to manager",
@@ -237,6 +241,7 @@ func salesPipeline(ctx context.Context, b *base.Base, dealEntity, taskEntity ent
 				Name:      "Won",
 				ActorID:   "00000000-0000-0000-0000-000000000000",
 				ActorName: "Deals",
+				Stage:     &pipeline.NewStage{Probability: 100, Category: pipeline.CategoryWon},
 				Nodes: []*base.CoreNode{
 					{
 						Name:       "Hand off to finance",
@@ -250,6 +255,7 @@ func salesPipeline(ctx context.Context, b *base.Base, dealEntity, taskEntity ent
 				Name:      "Lost",
 				ActorID:   "00000000-0000-0000-0000-000000000000",
 				ActorName: "Deals",
+				Stage:     &pipeline.NewStage{Probability: 0, Category: pipeline.CategoryLost},
 				Nodes: []*base.CoreNode{
 					{
 						Name:       "Log lost reason",
@@ -100,6 +100,16 @@ func MeetingFields(contactEntityID, companyEntityID, dealEntityID string, contac
 		Meta:        map[string]string{entity.MetaKeyHidden: "true"},
 	}
 
+	recurrenceFieldID := uuid.New().String()
+	recurrenceField := entity.Field{
+		Key:         recurrenceFieldID,
+		Name:        "recurrence",
+		DisplayName: "Repeat",
+		DomType:     entity.DomText,
+		DataType:    entity.TypeString,
+		Who:         entity.WhoRecurrence,
+	}
+
 	createdAtFieldID := uuid.New().String()
 	createdAtField := entity.Field{
 		Key:         createdAtFieldID,
@@ -168,7 +178,7 @@ func MeetingFields(contactEntityID, companyEntityID, dealEntityID string, contac
 		},
 	}
 
-	return []entity.Field{titleField, summaryField, attendessField, startTimeField, endTimeField, timezoneField, createdAtField, updatedAtField, contactField, companyField, dealField}
+	return []entity.Field{titleField, summaryField, attendessField, startTimeField, endTimeField, timezoneField, recurrenceField, createdAtField, updatedAtField, contactField, companyField, dealField}
 }
 
 func DealFields(contactEntityID, contactEntityKey, companyEntityID, companyEntityKey string, flowEntityID, nodeEntityID, nodeKey string) []entity.Field {
@@ -189,6 +199,7 @@ func DealFields(contactEntityID, contactEntityKey, companyEntityID, companyEntit
 		DisplayName: "Deal Amount",
 		DomType:     entity.DomText,
 		DataType:    entity.TypeNumber,
+		Who:         entity.WhoAmount,
 		Meta:        map[string]string{entity.MetaKeyLayout: entity.MetaLayoutSubTitle},
 	}
 
@@ -270,6 +281,7 @@ func DealFields(contactEntityID, contactEntityKey, companyEntityID, companyEntit
 		DisplayName: "Close date",
 		DomType:     entity.DomText,
 		DataType:    entity.TypeDate,
+		Who:         entity.WhoCloseDate,
 		Meta:        map[string]string{entity.MetaKeyLayout: entity.MetaLayoutDate},
 	}
 
@@ -55,8 +55,9 @@ func CreateContactCompanyTaskEntity(ctx context.Context, b *base.Base) error {
 	taskEntity, err := entity.RetrieveFixedEntityAccountLevel(ctx, b.DB, b.AccountID, entity.FixedEntityTask)
 	if err == entity.ErrFixedEntityNotFound {
 		// add entity - task
-		fields := forms.TaskFields(contactEntity.ID, contactEntity.Key("first_name"), companyEntity.ID, companyEntity.Key("name"), b.NodeEntity.ID, b.StatusEntity.ID, b.StatusEntity.Key("name"), b.OwnerEntity.ID, b.OwnerEntity.Key("name"))
-		taskEntity, err = b.EntityAdd(ctx, uuid.New().String(), entity.FixedEntityTask, "Tasks", entity.CategoryTask, entity.StateTeamLevel, false, false, true, fields)
+		taskEntityID := uuid.New().String()
+		fields := forms.TaskFields(taskEntityID, contactEntity.ID, contactEntity.Key("first_name"), companyEntity.ID, companyEntity.Key("name"), b.NodeEntity.ID, b.StatusEntity.ID, b.StatusEntity.Key("name"), b.OwnerEntity.ID, b.OwnerEntity.Key("name"))
+		taskEntity, err = b.EntityAdd(ctx, taskEntityID, entity.FixedEntityTask, "Tasks", entity.CategoryTask, entity.StateTeamLevel, false, false, true, fields)
 		if err != nil {
 			return err
 		}
@@ -286,6 +286,16 @@ func MeetingFields(contactEntityID, companyEntityID, projectEntityID string, con
 		Meta:        map[string]string{entity.MetaKeyHidden: "true"},
 	}
 
+	recurrenceFieldID := uuid.New().String()
+	recurrenceField := entity.Field{
+		Key:         recurrenceFieldID,
+		Name:        "recurrence",
+		DisplayName: "Repeat",
+		DomType:     entity.DomText,
+		DataType:    entity.TypeString,
+		Who:         entity.WhoRecurrence,
+	}
+
 	createdAtFieldID := uuid.New().String()
 	createdAtField := entity.Field{
 		Key:         createdAtFieldID,
@@ -354,7 +364,7 @@ func MeetingFields(contactEntityID, companyEntityID, projectEntityID string, con
 		},
 	}
 
-	return []entity.Field{titleField, summaryField, attendessField, startTimeField, endTimeField, timezoneField, createdAtField, updatedAtField, contactField, companyField, projectField}
+	return []entity.Field{titleField, summaryField, attendessField, startTimeField, endTimeField, timezoneField, recurrenceField, createdAtField, updatedAtField, contactField, companyField, projectField}
 }
 
 func ActivitiesFields(contactEntityID, contactEntityKey, contactEntityEmailKey, companyEntityID, companyEntityKey string) []entity.Field {
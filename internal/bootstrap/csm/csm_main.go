@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/google/uuid"
 	"gitlab.com/vjsideprojects/relay/internal/bootstrap/base"
@@ -12,6 +13,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/chart"
 	"gitlab.com/vjsideprojects/relay/internal/dashboard"
 	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/health"
 )
 
 func Boot(ctx context.Context, b *base.Base) error {
@@ -95,9 +97,38 @@ func AddWorkflows(ctx context.Context, b *base.Base) error {
 	}
 
 	fmt.Println("\tCRM:SAMPLES Sample Segments Created For Contacts/Companies/Deals")
+
+	err = addHealthModel(ctx, b)
+	if err != nil {
+		return err
+	}
+	fmt.Println("\tCSM:SAMPLES Company Health Model Created")
 	return nil
 }
 
+// addHealthModel scores the companies by their page visits and their overdue tasks.
+func addHealthModel(ctx context.Context, b *base.Base) error {
+	pageVisitsEntity, err := entity.RetrieveFixedEntity(ctx, b.DB, b.AccountID, b.TeamID, entity.FixedEntityPageVisits)
+	if err != nil {
+		return err
+	}
+
+	nm := health.NewModel{
+		AccountID: b.AccountID,
+		EntityID:  b.CompanyEntity.ID,
+		Name:      "Customer Health",
+		Components: []health.Component{
+			{Name: "Page visits", Kind: health.KindUsage, Weight: 2, EntityID: pageVisitsEntity.ID, FieldKey: b.CompanyEntity.Key("website"), Days: 30, Target: 100},
+			{Name: "Overdue tasks", Kind: health.KindOverdue, Weight: 1, EntityID: b.TaskEntity.ID, FieldKey: b.TaskEntity.Key("associated_companies"), Target: 3},
+		},
+	}
+	m, err := health.CreateModel(ctx, b.DB, nm, time.Now())
+	if err != nil {
+		return err
+	}
+	return health.EnsureFields(ctx, b.DB, b.SecDB, b.CompanyEntity, m)
+}
+
 func AddSamples(ctx context.Context, b *base.Base) error {
 	contactEntity, err := entity.RetrieveFixedEntity(ctx, b.DB, b.AccountID, b.TeamID, entity.FixedEntityContacts)
 	if err != nil {
@@ -89,5 +89,25 @@ func CalendarFields(ownerEntityID string, ownerEmailFieldKey string) []entity.Fi
 		DataType:    entity.TypeString,
 	}
 
-	return []entity.Field{idField, apiKeyField, emailField, commanField, ownerField, syncTokenField, syncedAtField, retriesField}
+	providerFieldID := uuid.New().String()
+	providerField := entity.Field{
+		Key:         providerFieldID,
+		Name:        "provider",
+		DisplayName: "Provider",
+		Meta:        map[string]string{entity.MetaKeyConfig: "true"},
+		DomType:     entity.DomNotApplicable,
+		DataType:    entity.TypeString,
+	}
+
+	usernameFieldID := uuid.New().String()
+	usernameField := entity.Field{
+		Key:         usernameFieldID,
+		Name:        "username",
+		DisplayName: "Username",
+		Meta:        map[string]string{entity.MetaKeyConfig: "true"},
+		DomType:     entity.DomNotApplicable,
+		DataType:    entity.TypeString,
+	}
+
+	return []entity.Field{idField, apiKeyField, emailField, commanField, ownerField, syncTokenField, syncedAtField, retriesField, providerField, usernameField}
 }
@@ -0,0 +1,38 @@
+package forms
+
+import (
+	"github.com/google/uuid"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+)
+
+func ScheduleFields() []entity.Field {
+	titleFieldID := uuid.New().String()
+	titleField := entity.Field{
+		Key:         titleFieldID,
+		Name:        "title",
+		DisplayName: "Title",
+		DomType:     entity.DomText,
+		DataType:    entity.TypeString,
+		Meta:        map[string]string{entity.MetaKeyLayout: "title"},
+	}
+
+	cronFieldID := uuid.New().String()
+	cronField := entity.Field{
+		Key:         cronFieldID,
+		Name:        "cron",
+		DisplayName: "Cron",
+		DomType:     entity.DomText,
+		DataType:    entity.TypeString,
+	}
+
+	timezoneFieldID := uuid.New().String()
+	timezoneField := entity.Field{
+		Key:         timezoneFieldID,
+		Name:        "timezone",
+		DisplayName: "Timezone",
+		DomType:     entity.DomText,
+		DataType:    entity.TypeString,
+	}
+
+	return []entity.Field{titleField, cronField, timezoneField}
+}
@@ -5,7 +5,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/entity"
 )
 
-func TaskFields(contactEntityID, contactEntityKey, companyEntityID, companyEntityKey, nodeEntityID, statusEntityID, statusEntityKey string, ownerEntityID, ownerEntitySearchKey string) []entity.Field {
+func TaskFields(taskEntityID, contactEntityID, contactEntityKey, companyEntityID, companyEntityKey, nodeEntityID, statusEntityID, statusEntityKey string, ownerEntityID, ownerEntitySearchKey string) []entity.Field {
 
 	nameFieldID := uuid.New().String()
 	nameField := entity.Field{
@@ -149,5 +149,23 @@ func TaskFields(contactEntityID, contactEntityKey, companyEntityID, companyEntit
 	// 	},
 	// }
 
-	return []entity.Field{nameField, descField, statusField, contactField, companyField, dueByField, reminderField, stageField, ownerField}
+	dependsOnFieldID := uuid.New().String()
+	dependsOnField := entity.Field{
+		Key:         dependsOnFieldID,
+		Name:        "depends_on",
+		DisplayName: "Depends On",
+		DomType:     entity.DomAutoComplete,
+		DataType:    entity.TypeReference,
+		RefID:       taskEntityID,
+		RefType:     entity.RefTypeStraight,
+		Who:         entity.WhoDependsOn,
+		Meta:        map[string]string{entity.MetaKeyDisplayGex: nameFieldID},
+		Field: &entity.Field{
+			DataType: entity.TypeString,
+			Key:      "id",
+			Value:    "--",
+		},
+	}
+
+	return []entity.Field{nameField, descField, statusField, contactField, companyField, dueByField, reminderField, stageField, ownerField, dependsOnField}
 }
@@ -206,6 +206,24 @@ func AlertFields(statusEntityID, statusEntityKey, priorityEntityID, priorityTitl
 		Meta:        map[string]string{entity.MetaKeyLayout: entity.MetaLayoutTitle},
 	}
 
+	alertDescFieldID := uuid.New().String()
+	alertDescField := entity.Field{
+		Key:         alertDescFieldID,
+		Name:        "description",
+		DisplayName: "Description",
+		DomType:     entity.DomTextArea,
+		DataType:    entity.TypeString,
+	}
+
+	severityFieldID := uuid.New().String()
+	severityField := entity.Field{
+		Key:         severityFieldID,
+		Name:        "severity",
+		DisplayName: "Severity",
+		DomType:     entity.DomText,
+		DataType:    entity.TypeString,
+	}
+
 	occurrenceFieldID := uuid.New().String()
 	occurrenceField := entity.Field{
 		Key:         occurrenceFieldID,
@@ -343,7 +361,7 @@ func AlertFields(statusEntityID, statusEntityKey, priorityEntityID, priorityTitl
 		},
 	}
 
-	return []entity.Field{alertNameField, statusField, priorityField, typeField, occurrenceField, categoryField, ownerField, pipeField, pipeStageField}
+	return []entity.Field{alertNameField, alertDescField, severityField, statusField, priorityField, typeField, occurrenceField, categoryField, ownerField, pipeField, pipeStageField}
 }
 
 func BugFields(statusEntityID, statusEntityKey, priorityEntityID, priorityTitleKey, typeEntityID, typeTitleKey, catEntityID, catTitleKey, ownerEntityID, ownerEntityKey string, flowEntityID, nodeEntityID, nodeKey string) []entity.Field {
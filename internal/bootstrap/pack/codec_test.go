@@ -0,0 +1,44 @@
+package pack_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestCodec(t *testing.T) {
+	t.Log("Given the need to keep the packages in YAML or JSON")
+	{
+		p := samplePackage()
+		p.Items = []pack.Item{{ID: "77777777-7777-7777-7777-777777777777", EntityID: dealEntityID, Fields: map[string]interface{}{amountKey: "true", "owner": []interface{}{"00"}}}}
+
+		for _, c := range []struct {
+			name   string
+			encode func(w *bytes.Buffer, p pack.Package) error
+		}{
+			{"json", func(w *bytes.Buffer, p pack.Package) error { return pack.Encode(w, p) }},
+			{"yaml", func(w *bytes.Buffer, p pack.Package) error { return pack.EncodeYAML(w, p) }},
+		} {
+			t.Logf("\twhen the package is written in %s", c.name)
+			{
+				var buf bytes.Buffer
+				if err := c.encode(&buf, p); err != nil {
+					t.Fatalf("\t%s should encode the package - %s", tests.Failed, err)
+				}
+				t.Logf("\t%s should encode the package", tests.Success)
+
+				got, err := pack.Decode(&buf)
+				if err != nil {
+					t.Fatalf("\t%s should decode the package - %s", tests.Failed, err)
+				}
+				if !reflect.DeepEqual(got, p) {
+					t.Fatalf("\t%s should read back the same package - got %+v", tests.Failed, got)
+				}
+				t.Logf("\t%s should read back the same package", tests.Success)
+			}
+		}
+	}
+}
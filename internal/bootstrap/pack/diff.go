@@ -0,0 +1,170 @@
+package pack
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Diff compares the installed package with the next version of the package. Both the packages must
+// be in the same id space (i.e) the next package must be remapped with the ids map of the installation.
+// An empty current package produces the changes needed for a fresh install.
+func Diff(current, next Package) []Change {
+	changes := make([]Change, 0)
+
+	currentEntities := make(map[string]Entity, len(current.Entities))
+	for _, e := range current.Entities {
+		currentEntities[e.ID] = e
+	}
+	nextEntities := make(map[string]bool, len(next.Entities))
+	for _, e := range next.Entities {
+		nextEntities[e.ID] = true
+		ce, ok := currentEntities[e.ID]
+		if !ok {
+			changes = append(changes, Change{Kind: KindEntity, Op: OpAdd, ID: e.ID, Name: e.DisplayName})
+		}
+		changes = append(changes, diffFields(ce, e)...)
+		changes = append(changes, diffLayouts(ce, e)...)
+	}
+	for _, e := range current.Entities {
+		if !nextEntities[e.ID] {
+			changes = append(changes, Change{Kind: KindEntity, Op: OpRemove, ID: e.ID, Name: e.DisplayName})
+		}
+	}
+
+	currentAssociations := make(map[Association]bool, len(current.Associations))
+	for _, a := range current.Associations {
+		currentAssociations[a] = true
+		currentAssociations[Association{SrcEntityID: a.DstEntityID, DstEntityID: a.SrcEntityID}] = true
+	}
+	for _, a := range next.Associations {
+		if !currentAssociations[a] {
+			changes = append(changes, Change{Kind: KindAssociation, Op: OpAdd, ID: a.SrcEntityID, Parent: a.DstEntityID})
+		}
+	}
+
+	currentFlows := make(map[string]Flow, len(current.Flows))
+	for _, f := range current.Flows {
+		currentFlows[f.ID] = f
+	}
+	nextFlows := make(map[string]bool, len(next.Flows))
+	for _, f := range next.Flows {
+		nextFlows[f.ID] = true
+		cf, ok := currentFlows[f.ID]
+		if !ok {
+			changes = append(changes, Change{Kind: KindFlow, Op: OpAdd, ID: f.ID, Name: f.Name})
+		} else if cf.Name != f.Name || cf.Description != f.Description || cf.Expression != f.Expression || cf.Type != f.Type || cf.Mode != f.Mode {
+			changes = append(changes, Change{Kind: KindFlow, Op: OpUpdate, ID: f.ID, Name: f.Name})
+		}
+		changes = append(changes, diffNodes(cf, f)...)
+	}
+	for _, f := range current.Flows {
+		if !nextFlows[f.ID] {
+			changes = append(changes, Change{Kind: KindFlow, Op: OpRemove, ID: f.ID, Name: f.Name})
+		}
+	}
+
+	currentDashboards := make(map[string]Dashboard, len(current.Dashboards))
+	for _, d := range current.Dashboards {
+		currentDashboards[d.ID] = d
+	}
+	for _, d := range next.Dashboards {
+		charts := make(map[string]bool)
+		for _, ch := range currentDashboards[d.ID].Charts {
+			charts[ch.Name] = true
+		}
+		for _, ch := range d.Charts {
+			if !charts[ch.Name] {
+				changes = append(changes, Change{Kind: KindChart, Op: OpAdd, Name: ch.Name, Parent: d.ID})
+			}
+		}
+	}
+
+	currentItems := make(map[string]bool, len(current.Items))
+	for _, i := range current.Items {
+		currentItems[i.ID] = true
+	}
+	for _, i := range next.Items {
+		if !currentItems[i.ID] {
+			changes = append(changes, Change{Kind: KindItem, Op: OpAdd, ID: i.ID, Name: name(i.Name), Parent: i.EntityID})
+		}
+	}
+
+	return changes
+}
+
+func diffFields(current, next Entity) []Change {
+	changes := make([]Change, 0)
+	currentFields := make(map[string]interface{}, len(current.Fields))
+	for _, f := range current.Fields {
+		currentFields[f.Key] = f
+	}
+	nextFields := make(map[string]bool, len(next.Fields))
+	for _, f := range next.Fields {
+		nextFields[f.Key] = true
+		cf, ok := currentFields[f.Key]
+		if !ok {
+			changes = append(changes, Change{Kind: KindField, Op: OpAdd, ID: f.Key, Name: f.DisplayName, Parent: next.ID})
+		} else if !sameJSON(cf, f) {
+			changes = append(changes, Change{Kind: KindField, Op: OpUpdate, ID: f.Key, Name: f.DisplayName, Parent: next.ID})
+		}
+	}
+	for _, f := range current.Fields {
+		if !nextFields[f.Key] {
+			changes = append(changes, Change{Kind: KindField, Op: OpRemove, ID: f.Key, Name: f.DisplayName, Parent: current.ID})
+		}
+	}
+	return changes
+}
+
+func diffLayouts(current, next Entity) []Change {
+	changes := make([]Change, 0)
+	layouts := make(map[string]bool, len(current.Layouts))
+	for _, l := range current.Layouts {
+		layouts[l.Name] = true
+	}
+	for _, l := range next.Layouts {
+		if !layouts[l.Name] {
+			changes = append(changes, Change{Kind: KindLayout, Op: OpAdd, Name: l.Name, Parent: next.ID})
+		}
+	}
+	return changes
+}
+
+func diffNodes(current, next Flow) []Change {
+	changes := make([]Change, 0)
+	currentNodes := make(map[string]Node, len(current.Nodes))
+	for _, n := range current.Nodes {
+		currentNodes[n.ID] = n
+	}
+	for _, n := range next.Nodes {
+		cn, ok := currentNodes[n.ID]
+		if !ok {
+			changes = append(changes, Change{Kind: KindNode, Op: OpAdd, ID: n.ID, Name: n.Name, Parent: next.ID})
+		} else if cn.Name != n.Name || cn.Expression != n.Expression || !sameJSON(cn.Tokens, n.Tokens) {
+			changes = append(changes, Change{Kind: KindNode, Op: OpUpdate, ID: n.ID, Name: n.Name, Parent: next.ID})
+		}
+	}
+	return changes
+}
+
+// sameJSON compares the values as they are stored. The fields read back from the db lose the go types
+// (int becomes float64) so comparing them with reflect directly would report false updates.
+func sameJSON(a, b interface{}) bool {
+	ab, err1 := json.Marshal(a)
+	bb, err2 := json.Marshal(b)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	var av, bv interface{}
+	if json.Unmarshal(ab, &av) != nil || json.Unmarshal(bb, &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func name(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
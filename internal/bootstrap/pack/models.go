@@ -0,0 +1,168 @@
+package pack
+
+import (
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+)
+
+// FormatVersion is the version of the package layout. Bump it when the structure below changes incompatibly.
+const FormatVersion = 1
+
+// Package is the declarative description of an app template (CRM, CSM, Support...).
+// All the ids inside the package are local to the package and get remapped when it is imported.
+type Package struct {
+	Format       int           `json:"format"`
+	Name         string        `json:"name"` // the look up key of the team. ex: crp
+	DisplayName  string        `json:"display_name"`
+	Description  string        `json:"description"`
+	Version      string        `json:"version"`
+	Requires     []Requirement `json:"requires,omitempty"`
+	Entities     []Entity      `json:"entities"`
+	Associations []Association `json:"associations,omitempty"`
+	Flows        []Flow        `json:"flows,omitempty"`
+	Dashboards   []Dashboard   `json:"dashboards,omitempty"`
+	Items        []Item        `json:"items,omitempty"`
+}
+
+// Requirement is an entity outside the package (owners, status, flow...) which is referred inside the package.
+// During the import it is resolved by the name in the target account and so are its fields and items.
+type Requirement struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Fields map[string]string `json:"fields,omitempty"` // key -> name
+	Items  map[string]string `json:"items,omitempty"`  // id -> name
+}
+
+// Entity is the entity of the package with its layouts.
+type Entity struct {
+	ID            string         `json:"id"`
+	Name          string         `json:"name"`
+	DisplayName   string         `json:"display_name"`
+	Category      int            `json:"category"`
+	State         int            `json:"state"`
+	IsPublic      bool           `json:"is_public"`
+	IsCore        bool           `json:"is_core"`
+	IsShared      bool           `json:"is_shared"`
+	SharedTeamIDs []string       `json:"shared_team_ids,omitempty"`
+	Fields        []entity.Field `json:"fields"`
+	Layouts       []Layout       `json:"layouts,omitempty"`
+}
+
+// Layout is the shared layout of the entity.
+type Layout struct {
+	Name   string            `json:"name"`
+	Type   int               `json:"type"`
+	Fields map[string]string `json:"fields"`
+}
+
+// Association is the explicit N:N relationship between two entities.
+type Association struct {
+	SrcEntityID string `json:"src_entity_id"`
+	DstEntityID string `json:"dst_entity_id"`
+}
+
+// Flow is the workflow/pipeline of the package along with its nodes.
+type Flow struct {
+	ID          string                 `json:"id"`
+	EntityID    string                 `json:"entity_id"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Expression  string                 `json:"expression"`
+	Tokens      map[string]interface{} `json:"tokens,omitempty"`
+	Mode        int                    `json:"mode"`
+	Type        int                    `json:"type"`
+	Condition   int                    `json:"condition"`
+	Status      int                    `json:"status"`
+	Nodes       []Node                 `json:"nodes,omitempty"`
+}
+
+// Node is the step of the flow.
+type Node struct {
+	ID           string                 `json:"id"`
+	ParentNodeID string                 `json:"parent_node_id"`
+	ActorID      string                 `json:"actor_id"`
+	StageID      string                 `json:"stage_id"`
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	Weight       int                    `json:"weight"`
+	Type         int                    `json:"type"`
+	Expression   string                 `json:"expression"`
+	Tokens       map[string]interface{} `json:"tokens,omitempty"`
+	Actuals      map[string]string      `json:"actuals,omitempty"`
+}
+
+// Dashboard is the dashboard of the package along with its charts.
+type Dashboard struct {
+	ID       string            `json:"id"`
+	EntityID string            `json:"entity_id"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Charts   []Chart           `json:"charts,omitempty"`
+}
+
+// Chart is identified by its name inside the dashboard.
+type Chart struct {
+	EntityID    string            `json:"entity_id"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	Type        string            `json:"type"`
+	Duration    string            `json:"duration"`
+	State       int               `json:"state"`
+	Position    int               `json:"position"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+// Item is the configuration item (status, templates...) or the sample item of the package.
+type Item struct {
+	ID       string                 `json:"id"`
+	EntityID string                 `json:"entity_id"`
+	Name     *string                `json:"name"`
+	Type     int                    `json:"type"`
+	State    int                    `json:"state"`
+	Fields   map[string]interface{} `json:"fields"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+}
+
+// Installation records the package installed on the team. IDMap keeps the package ids against the
+// ids created in the account, so the upgrade can find the objects it installed earlier.
+type Installation struct {
+	ID          string    `db:"package_id" json:"id"`
+	AccountID   string    `db:"account_id" json:"account_id"`
+	TeamID      string    `db:"team_id" json:"team_id"`
+	Name        string    `db:"name" json:"name"`
+	Version     string    `db:"version" json:"version"`
+	IDMapb      string    `db:"idmapb" json:"idmapb"`
+	InstalledAt time.Time `db:"installed_at" json:"installed_at"`
+	UpdatedAt   int64     `db:"updated_at" json:"updated_at"`
+}
+
+// Kinds of the objects compared in the diff.
+const (
+	KindEntity      = "entity"
+	KindField       = "field"
+	KindLayout      = "layout"
+	KindAssociation = "association"
+	KindFlow        = "flow"
+	KindNode        = "node"
+	KindChart       = "chart"
+	KindItem        = "item"
+)
+
+// Operations of the change.
+const (
+	OpAdd    = "add"
+	OpUpdate = "update"
+	OpRemove = "remove"
+)
+
+// Change is a difference between the installed package and the new version of the package.
+// Removals are only reported; the upgrade never deletes anything from the account.
+type Change struct {
+	Kind   string `json:"kind"`
+	Op     string `json:"op"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Parent string `json:"parent,omitempty"` // entity of the field/layout, flow of the node, dashboard of the chart
+}
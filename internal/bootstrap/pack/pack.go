@@ -0,0 +1,782 @@
+package pack
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/chart"
+	"gitlab.com/vjsideprojects/relay/internal/dashboard"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/job"
+	"gitlab.com/vjsideprojects/relay/internal/layout"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/relationship"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"gitlab.com/vjsideprojects/relay/internal/team"
+	"go.opencensus.io/trace"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrNotInstalled is used when the team was not installed from a package.
+	ErrNotInstalled = errors.New("Package not installed on the team")
+	// ErrTeamNotFound is used when the team to export does not exist in the account.
+	ErrTeamNotFound = errors.New("Team not found")
+	// ErrUnsupportedFormat is used when the package is created by a newer version of the format.
+	ErrUnsupportedFormat = errors.New("Package format is not supported")
+	// ErrRequirementMissing is used when an entity required by the package is not available in the account.
+	ErrRequirementMissing = errors.New("Package requirement is missing in the account")
+)
+
+// Decode reads the package written either in JSON or in YAML.
+func Decode(r io.Reader) (Package, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return Package{}, errors.Wrap(err, "reading package")
+	}
+
+	// YAML is decoded generically and converted to JSON, so both the formats share the json tags of the package.
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] != '{' {
+		var v interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return Package{}, errors.Wrap(err, "decoding yaml package")
+		}
+		if b, err = json.Marshal(v); err != nil {
+			return Package{}, errors.Wrap(err, "converting yaml package")
+		}
+	}
+
+	var p Package
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Package{}, errors.Wrap(err, "decoding package")
+	}
+	if p.Format > FormatVersion {
+		return Package{}, ErrUnsupportedFormat
+	}
+	return p, nil
+}
+
+// Encode writes the package in the indented form, so the packages kept in the repo are diffable.
+func Encode(w io.Writer, p Package) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// EncodeYAML writes the package in YAML with the same keys (and in the same order) as Encode.
+func EncodeYAML(w io.Writer, p Package) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "encoding package")
+	}
+	// JSON is valid YAML. Decoding it into a node keeps the order of the keys.
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return errors.Wrap(err, "converting package to yaml")
+	}
+	plain(&n)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&n); err != nil {
+		return errors.Wrap(err, "encoding yaml package")
+	}
+	return enc.Close()
+}
+
+// plain drops the flow (JSON) style the nodes carry over from the JSON input, so the output is block YAML.
+func plain(n *yaml.Node) {
+	n.Style &^= yaml.FlowStyle
+	if n.Kind == yaml.ScalarNode && n.Style&yaml.DoubleQuotedStyle != 0 && n.Tag == "!!str" {
+		n.Style &^= yaml.DoubleQuotedStyle
+	}
+	for _, c := range n.Content {
+		plain(c)
+	}
+}
+
+// Export builds the package from the entities, flows, layouts and dashboards of the team.
+// The configuration items (status, templates) are always exported and the other items only when samples is set.
+func Export(ctx context.Context, db *sqlx.DB, accountID, teamID, version string, samples bool) (Package, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.bootstrap.pack.Export")
+	defer span.End()
+
+	teams, err := team.Map(ctx, accountID, db)
+	if err != nil {
+		return Package{}, err
+	}
+	t, ok := teams[teamID]
+	if !ok {
+		return Package{}, ErrTeamNotFound
+	}
+
+	p := Package{
+		Format:      FormatVersion,
+		Name:        t.LookUp,
+		DisplayName: t.Name,
+		Version:     version,
+	}
+	if t.Description != nil {
+		p.Description = *t.Description
+	}
+
+	entities, err := entity.TeamEntities(ctx, accountID, teamID, []int{}, db)
+	if err != nil {
+		return Package{}, err
+	}
+	entityIDs := entity.FetchIDs(entities)
+	inPackage := make(map[string]bool, len(entities))
+	for _, id := range entityIDs {
+		inPackage[id] = true
+	}
+
+	associations := make(map[string]bool)
+	for _, e := range entities {
+		pe, err := exportEntity(ctx, db, e)
+		if err != nil {
+			return Package{}, err
+		}
+		p.Entities = append(p.Entities, pe)
+
+		relationships, err := relationship.Relationships(ctx, db, accountID, e.ID)
+		if err != nil {
+			return Package{}, err
+		}
+		for _, r := range relationships {
+			if r.FieldID != relationship.FieldAssociationKey || !inPackage[r.DstEntityID] || associations[r.RelationshipID] {
+				continue
+			}
+			associations[r.RelationshipID] = true
+			p.Associations = append(p.Associations, Association{SrcEntityID: r.SrcEntityID, DstEntityID: r.DstEntityID})
+		}
+
+		items, err := item.EntityItems(ctx, accountID, e.ID, db)
+		if err != nil {
+			return Package{}, err
+		}
+		for _, i := range items {
+			if i.State == item.StateBluePrint || i.Type == item.TypeForm || e.Category == entity.CategoryChildUnit || (samples && i.Type == item.TypeDummy) {
+				p.Items = append(p.Items, Item{ID: i.ID, EntityID: i.EntityID, Name: i.Name, Type: i.Type, State: i.State, Fields: i.Fields(), Meta: i.Meta()})
+			}
+		}
+	}
+
+	flows, err := flow.List(ctx, entityIDs, flow.FlowModeAll, flow.FlowTypeAll, db)
+	if err != nil {
+		return Package{}, err
+	}
+	for _, f := range flows {
+		pf, err := exportFlow(ctx, db, f)
+		if err != nil {
+			return Package{}, err
+		}
+		p.Flows = append(p.Flows, pf)
+	}
+
+	dashboards, err := dashboard.TeamDashboards(ctx, accountID, teamID, db)
+	if err != nil {
+		return Package{}, err
+	}
+	for _, d := range dashboards {
+		charts, err := chart.ListByDashID(ctx, accountID, teamID, d.ID, db)
+		if err != nil {
+			return Package{}, err
+		}
+		pd := Dashboard{ID: d.ID, EntityID: d.EntityID, Name: d.Name, Type: d.Type, Meta: d.Meta()}
+		for _, ch := range charts {
+			pd.Charts = append(pd.Charts, Chart{EntityID: ch.EntityID, Name: ch.Name, DisplayName: ch.DisplayName, Type: ch.Type, Duration: ch.Duration, State: ch.State, Position: ch.Position, Meta: ch.Meta()})
+		}
+		p.Dashboards = append(p.Dashboards, pd)
+	}
+
+	p.Requires, err = requirements(ctx, db, accountID, p)
+	if err != nil {
+		return Package{}, err
+	}
+	return p, nil
+}
+
+// Import installs the package as a new team of the account and records the installation for the future upgrades.
+// Everything is created in one transaction, so a failure part-way does not leave a half-installed package.
+// The items created are streamed once the transaction is committed.
+func Import(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, userID string, p Package, fbSDKPath string, now time.Time) (Installation, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.bootstrap.pack.Import")
+	defer span.End()
+
+	if p.Format > FormatVersion {
+		return Installation{}, ErrUnsupportedFormat
+	}
+
+	ids, err := resolve(ctx, db, accountID, p.Requires)
+	if err != nil {
+		return Installation{}, err
+	}
+	rp, err := Remap(p, ids)
+	if err != nil {
+		return Installation{}, err
+	}
+
+	// the shared teams belong to the source account
+	for i := range rp.Entities {
+		rp.Entities[i].SharedTeamIDs = nil
+	}
+
+	var inst Installation
+	var created []item.Item
+	err = database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		t, err := team.Create(ctx, tx, team.NewTeam{AccountID: accountID, LookUp: rp.Name, Name: rp.DisplayName, Description: rp.Description}, now)
+		if err != nil {
+			return err
+		}
+		inst, created, err = Install(ctx, tx, sdb, accountID, t.ID, userID, rp, ids, now)
+		return err
+	})
+	if err != nil {
+		return Installation{}, err
+	}
+
+	Settle(ctx, db, sdb, accountID, userID, rp, created, fbSDKPath)
+	return inst, nil
+}
+
+// Install creates everything in the remapped package on the existing team and records the installation.
+// The ids map is the one used to remap the package. It returns the items created, which the caller
+// streams after the changes are committed.
+func Install(ctx context.Context, db database.Conn, sdb *database.SecDB, accountID, teamID, userID string, rp Package, ids map[string]string, now time.Time) (Installation, []item.Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.bootstrap.pack.Install")
+	defer span.End()
+
+	created, err := Apply(ctx, db, sdb, accountID, teamID, userID, Package{}, rp, Diff(Package{}, rp), now)
+	if err != nil {
+		return Installation{}, nil, errors.Wrapf(err, "installing package %s", rp.Name)
+	}
+
+	inst, err := createInstallation(ctx, db, accountID, teamID, rp.Name, rp.Version, ids, now)
+	if err != nil {
+		return Installation{}, nil, err
+	}
+	return inst, created, nil
+}
+
+// DiffInstalled returns the changes the upgrade to the package would make on the team.
+func DiffInstalled(ctx context.Context, db *sqlx.DB, accountID, teamID string, p Package) ([]Change, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.bootstrap.pack.DiffInstalled")
+	defer span.End()
+
+	_, current, next, _, err := plan(ctx, db, accountID, teamID, p)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(current, next), nil
+}
+
+// Upgrade applies the additions and the updates of the new version of the package on the installed team.
+// The objects removed in the new version are left untouched as the customers could be using them.
+// Like Import, the changes are applied in one transaction and the items created are streamed after it.
+func Upgrade(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, teamID, userID string, p Package, fbSDKPath string, now time.Time) ([]Change, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.bootstrap.pack.Upgrade")
+	defer span.End()
+
+	inst, current, next, ids, err := plan(ctx, db, accountID, teamID, p)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Diff(current, next)
+	var created []item.Item
+	err = database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		created, err = Apply(ctx, tx, sdb, accountID, teamID, userID, current, next, changes, now)
+		if err != nil {
+			return errors.Wrapf(err, "upgrading package %s", p.Name)
+		}
+		return UpdateInstallation(ctx, tx, inst.ID, next.Version, ids, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	Settle(ctx, db, sdb, accountID, userID, next, created, fbSDKPath)
+	return changes, nil
+}
+
+// Settle runs after the commit. The entities cached while the transaction was open are dropped and
+// the items created are streamed, so the workflows, the graph and the timelines see them. Templates
+// are not streamed, same as the go bootstraps.
+func Settle(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, userID string, p Package, created []item.Item, fbSDKPath string) {
+	for _, e := range p.Entities {
+		sdb.ResetEntity(e.ID)
+	}
+	for _, it := range created {
+		if it.State == item.StateBluePrint {
+			continue
+		}
+		if err := job.NewJob(db, sdb, fbSDKPath).Stream(stream.NewCreteItemMessage(ctx, db, accountID, userID, it.EntityID, it.ID, nil)); err != nil {
+			log.Printf("***> unexpected error occurred in internal.bootstrap.pack when streaming the item %s. error: %v\n", it.ID, err)
+		}
+	}
+}
+
+// RetrieveInstallation returns the package installed on the team.
+func RetrieveInstallation(ctx context.Context, db *sqlx.DB, accountID, teamID string) (Installation, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.bootstrap.pack.RetrieveInstallation")
+	defer span.End()
+
+	var inst Installation
+	const q = `SELECT * FROM packages WHERE account_id = $1 AND team_id = $2`
+	if err := db.GetContext(ctx, &inst, q, accountID, teamID); err != nil {
+		if err == sql.ErrNoRows {
+			return Installation{}, ErrNotInstalled
+		}
+		return Installation{}, errors.Wrapf(err, "selecting package of team %q", teamID)
+	}
+	return inst, nil
+}
+
+// IDMap returns the package ids against the ids created in the account.
+func (inst Installation) IDMap() map[string]string {
+	ids := make(map[string]string)
+	if inst.IDMapb == "" {
+		return ids
+	}
+	if err := json.Unmarshal([]byte(inst.IDMapb), &ids); err != nil {
+		log.Printf("***> unexpected error occurred when unmarshalling idmap for package: %v error: %v\n", inst.ID, err)
+	}
+	return ids
+}
+
+// plan brings the installed team and the new version of the package into the same id space.
+func plan(ctx context.Context, db *sqlx.DB, accountID, teamID string, p Package) (Installation, Package, Package, map[string]string, error) {
+	if p.Format > FormatVersion {
+		return Installation{}, Package{}, Package{}, nil, ErrUnsupportedFormat
+	}
+
+	inst, err := RetrieveInstallation(ctx, db, accountID, teamID)
+	if err != nil {
+		return Installation{}, Package{}, Package{}, nil, err
+	}
+
+	ids, err := resolve(ctx, db, accountID, p.Requires)
+	if err != nil {
+		return Installation{}, Package{}, Package{}, nil, err
+	}
+	for k, v := range inst.IDMap() {
+		ids[k] = v
+	}
+
+	next, err := Remap(p, ids)
+	if err != nil {
+		return Installation{}, Package{}, Package{}, nil, err
+	}
+
+	current, err := Export(ctx, db, accountID, teamID, inst.Version, true)
+	if err != nil {
+		return Installation{}, Package{}, Package{}, nil, err
+	}
+	return inst, current, next, ids, nil
+}
+
+// Apply makes the additions and the updates of the changes and returns the items it created. The entities are
+// created without the fields first and the fields are set afterwards, so the reference fields can bond with the
+// entities created later. Pass a transaction as db to apply the changes all or nothing.
+func Apply(ctx context.Context, db database.Conn, sdb *database.SecDB, accountID, teamID, userID string, current, next Package, changes []Change, now time.Time) ([]item.Item, error) {
+	currentEntities := make(map[string]Entity, len(current.Entities))
+	for _, e := range current.Entities {
+		currentEntities[e.ID] = e
+	}
+	nextEntities := make(map[string]Entity, len(next.Entities))
+	for _, e := range next.Entities {
+		nextEntities[e.ID] = e
+	}
+	nextFlows := make(map[string]Flow, len(next.Flows))
+	for _, f := range next.Flows {
+		nextFlows[f.ID] = f
+	}
+	nextDashboards := make(map[string]Dashboard, len(next.Dashboards))
+	for _, d := range next.Dashboards {
+		nextDashboards[d.ID] = d
+	}
+	currentDashboards := make(map[string]bool, len(current.Dashboards))
+	for _, d := range current.Dashboards {
+		currentDashboards[d.ID] = true
+	}
+	nextItems := make(map[string]Item, len(next.Items))
+	for _, i := range next.Items {
+		nextItems[i.ID] = i
+	}
+
+	dirtyEntities := make([]string, 0)
+	dirty := make(map[string]bool)
+	for _, c := range changes {
+		if c.Kind == KindEntity && c.Op == OpAdd {
+			e := nextEntities[c.ID]
+			ne := entity.NewEntity{
+				ID:          e.ID,
+				AccountID:   accountID,
+				TeamID:      teamID,
+				Name:        e.Name,
+				DisplayName: e.DisplayName,
+				Category:    e.Category,
+				State:       e.State,
+				Fields:      []entity.Field{},
+				IsPublic:    e.IsPublic,
+				IsCore:      e.IsCore,
+				IsShared:    e.IsShared,
+			}
+			if _, err := entity.Create(ctx, db, ne, now); err != nil {
+				return nil, err
+			}
+			if len(e.SharedTeamIDs) > 0 {
+				if err := entity.UpdateSharedTeam(ctx, db, sdb, accountID, e.ID, e.SharedTeamIDs, now); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if c.Kind == KindField && c.Op != OpRemove && !dirty[c.Parent] {
+			dirty[c.Parent] = true
+			dirtyEntities = append(dirtyEntities, c.Parent)
+		}
+	}
+
+	for _, entityID := range dirtyEntities {
+		fields := mergeFields(currentEntities[entityID].Fields, nextEntities[entityID].Fields)
+		input, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		if err := entity.Update(ctx, db, sdb, accountID, entityID, string(input), now); err != nil {
+			return nil, errors.Wrapf(err, "setting the fields of entity %s", entityID)
+		}
+	}
+
+	created := make([]item.Item, 0)
+	for _, c := range changes {
+		if c.Op == OpRemove {
+			log.Printf("internal.bootstrap.pack : %s %q removed in the package. skipping the removal\n", c.Kind, c.Name)
+			continue
+		}
+
+		var err error
+		switch c.Kind {
+		case KindLayout:
+			err = addLayout(ctx, db, accountID, c.Parent, nextEntities[c.Parent].Layouts, c.Name, now)
+		case KindAssociation:
+			_, err = relationship.Associate(ctx, db, accountID, c.ID, c.Parent)
+		case KindFlow:
+			f := nextFlows[c.ID]
+			nf := flow.NewFlow{ID: f.ID, AccountID: accountID, EntityID: f.EntityID, Name: f.Name, Description: f.Description, Expression: f.Expression, Tokens: f.Tokens, Mode: f.Mode, Type: f.Type, Condition: f.Condition}
+			if c.Op == OpAdd {
+				_, err = flow.Create(ctx, db, nf, now)
+			} else {
+				_, err = flow.Update(ctx, db, nf, now)
+			}
+		case KindNode:
+			n := findNode(nextFlows[c.Parent], c.ID)
+			if c.Op == OpAdd {
+				nn := node.NewNode{ID: n.ID, ParentNodeID: n.ParentNodeID, AccountID: accountID, FlowID: c.Parent, ActorID: n.ActorID, StageID: n.StageID, Name: n.Name, Description: n.Description, Weight: n.Weight, Type: n.Type, Expression: n.Expression, Tokens: n.Tokens, Actuals: n.Actuals}
+				_, err = node.Create(ctx, db, nn, now)
+			} else {
+				err = node.Update(ctx, db, accountID, c.Parent, n.ID, n.Name, n.Expression, n.Tokens, now)
+			}
+		case KindChart:
+			d := nextDashboards[c.Parent]
+			if !currentDashboards[d.ID] {
+				nd := dashboard.NewDashboard{ID: d.ID, AccountID: accountID, TeamID: teamID, EntityID: d.EntityID, UserID: userID, Name: d.Name, Type: d.Type, Meta: d.Meta}
+				if err := dashboard.Create(ctx, db, nd, now); err != nil {
+					return nil, err
+				}
+				currentDashboards[d.ID] = true
+			}
+			err = addChart(ctx, db, accountID, teamID, d, c.Name, now)
+		case KindItem:
+			i := nextItems[c.ID]
+			ni := item.NewItem{ID: i.ID, AccountID: accountID, EntityID: i.EntityID, UserID: &userID, Name: i.Name, Type: i.Type, State: i.State, Fields: i.Fields, Meta: i.Meta}
+			var it item.Item
+			if it, err = item.Create(ctx, db, ni, now); err == nil {
+				created = append(created, it)
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "applying %s %s of %q", c.Op, c.Kind, c.Name)
+		}
+	}
+	return created, nil
+}
+
+// mergeFields keeps the fields added by the customer and replaces the fields owned by the package.
+func mergeFields(current, next []entity.Field) []entity.Field {
+	nextFields := make(map[string]bool, len(next))
+	for _, f := range next {
+		nextFields[f.Key] = true
+	}
+	fields := append([]entity.Field{}, next...)
+	for _, f := range current {
+		if !nextFields[f.Key] {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func findNode(f Flow, nodeID string) Node {
+	for _, n := range f.Nodes {
+		if n.ID == nodeID {
+			return n
+		}
+	}
+	return Node{}
+}
+
+func addLayout(ctx context.Context, db database.Conn, accountID, entityID string, layouts []Layout, name string, now time.Time) error {
+	for _, l := range layouts {
+		if l.Name == name {
+			_, err := layout.Create(ctx, db, layout.NewLayout{Name: l.Name, AccountID: accountID, EntityID: entityID, Type: l.Type, Fields: l.Fields}, now)
+			return err
+		}
+	}
+	return nil
+}
+
+func addChart(ctx context.Context, db database.Conn, accountID, teamID string, d Dashboard, name string, now time.Time) error {
+	for _, ch := range d.Charts {
+		if ch.Name == name {
+			nc := chart.NewChart{AccountID: accountID, TeamID: teamID, DashboardID: d.ID, EntityID: ch.EntityID, Name: ch.Name, DisplayName: ch.DisplayName, Type: ch.Type, Duration: ch.Duration, State: ch.State, Position: ch.Position, Meta: ch.Meta}
+			return chart.Create(ctx, db, nc, now)
+		}
+	}
+	return nil
+}
+
+func exportEntity(ctx context.Context, db *sqlx.DB, e entity.Entity) (Entity, error) {
+	fields, err := e.Fields()
+	if err != nil {
+		return Entity{}, err
+	}
+	pe := Entity{
+		ID:          e.ID,
+		Name:        e.Name,
+		DisplayName: e.DisplayName,
+		Category:    e.Category,
+		State:       e.State,
+		IsPublic:    e.IsPublic,
+		IsCore:      e.IsCore,
+		IsShared:    e.IsShared,
+		Fields:      fields,
+	}
+	if len(e.SharedTeamIds) > 0 {
+		pe.SharedTeamIDs = e.SharedTeamIds
+	}
+
+	layouts, err := layout.List(ctx, e.AccountID, e.ID, db)
+	if err != nil {
+		return Entity{}, err
+	}
+	for _, l := range layouts {
+		lfields := make(map[string]string)
+		if err := json.Unmarshal([]byte(l.Fieldsb), &lfields); err != nil {
+			return Entity{}, errors.Wrapf(err, "decoding layout %s", l.Name)
+		}
+		pe.Layouts = append(pe.Layouts, Layout{Name: l.Name, Type: l.Type, Fields: lfields})
+	}
+	return pe, nil
+}
+
+func exportFlow(ctx context.Context, db *sqlx.DB, f flow.Flow) (Flow, error) {
+	pf := Flow{
+		ID:          f.ID,
+		EntityID:    f.EntityID,
+		Name:        f.Name,
+		Description: f.Description,
+		Expression:  f.Expression,
+		Tokens:      f.Tokens(),
+		Mode:        f.Mode,
+		Type:        f.Type,
+		Condition:   f.Condition,
+		Status:      f.Status,
+	}
+
+	nodes, err := node.List(ctx, f.ID, db)
+	if err != nil {
+		return Flow{}, err
+	}
+	for _, n := range nodes {
+		pn := Node{
+			ID:           n.ID,
+			ParentNodeID: n.ParentNodeID,
+			ActorID:      n.ActorID,
+			StageID:      n.StageID,
+			Name:         n.Name,
+			Description:  n.Description,
+			Weight:       n.Weight,
+			Type:         n.Type,
+			Expression:   n.Expression,
+		}
+		if n.Tokenb != "" {
+			if err := json.Unmarshal([]byte(n.Tokenb), &pn.Tokens); err != nil {
+				return Flow{}, errors.Wrapf(err, "decoding tokens of node %s", n.ID)
+			}
+		}
+		if n.Actuals != "" {
+			if err := json.Unmarshal([]byte(n.Actuals), &pn.Actuals); err != nil {
+				return Flow{}, errors.Wrapf(err, "decoding actuals of node %s", n.ID)
+			}
+		}
+		pf.Nodes = append(pf.Nodes, pn)
+	}
+	return pf, nil
+}
+
+// requirements finds the entities, fields and items of the account referred by the package but not part of it.
+func requirements(ctx context.Context, db *sqlx.DB, accountID string, p Package) ([]Requirement, error) {
+	refs, err := p.references()
+	if err != nil || len(refs) == 0 {
+		return nil, err
+	}
+	refSet := make(map[string]bool, len(refs))
+	for _, id := range refs {
+		refSet[id] = true
+	}
+
+	itemIDs := make([]interface{}, len(refs))
+	for i, id := range refs {
+		itemIDs[i] = id
+	}
+	items, err := item.BulkRetrieveItems(ctx, accountID, itemIDs, db)
+	if err != nil {
+		return nil, err
+	}
+
+	entityIDs := append([]string{}, refs...)
+	for _, i := range items {
+		entityIDs = append(entityIDs, i.EntityID)
+	}
+	entities, err := entity.BulkRetrieve(ctx, entityIDs, db)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]Requirement, 0)
+	index := make(map[string]int)
+	for _, e := range entities {
+		if e.AccountID != accountID {
+			continue
+		}
+		req := Requirement{ID: e.ID, Name: e.Name, Fields: map[string]string{}, Items: map[string]string{}}
+		for _, f := range e.EasyFields() {
+			if refSet[f.Key] {
+				req.Fields[f.Key] = f.Name
+			}
+		}
+		index[e.ID] = len(reqs)
+		reqs = append(reqs, req)
+	}
+	for _, i := range items {
+		if idx, ok := index[i.EntityID]; ok {
+			reqs[idx].Items[i.ID] = name(i.Name)
+		}
+	}
+	return reqs, nil
+}
+
+// resolve maps the requirements of the package with the entities, fields and items of the account by their names.
+func resolve(ctx context.Context, db *sqlx.DB, accountID string, reqs []Requirement) (map[string]string, error) {
+	ids := make(map[string]string)
+	for _, req := range reqs {
+		e, err := entity.RetrieveByName(ctx, accountID, req.Name, db)
+		if err != nil {
+			if err == entity.ErrEntityNotFoundByName {
+				return nil, errors.Wrapf(ErrRequirementMissing, "entity %s", req.Name)
+			}
+			return nil, err
+		}
+		ids[req.ID] = e.ID
+
+		keys := e.NameKeyMapWrapper()
+		for key, fieldName := range req.Fields {
+			if k, ok := keys[fieldName]; ok {
+				ids[key] = k
+			} else {
+				log.Printf("internal.bootstrap.pack : field %q of entity %q is missing in the account\n", fieldName, req.Name)
+			}
+		}
+
+		if len(req.Items) == 0 {
+			continue
+		}
+		items, err := item.EntityItems(ctx, accountID, e.ID, db)
+		if err != nil {
+			return nil, err
+		}
+		itemIDs := make(map[string]string, len(items))
+		for _, i := range items {
+			itemIDs[name(i.Name)] = i.ID
+		}
+		for id, itemName := range req.Items {
+			if newID, ok := itemIDs[itemName]; ok {
+				ids[id] = newID
+			} else {
+				log.Printf("internal.bootstrap.pack : item %q of entity %q is missing in the account\n", itemName, req.Name)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func createInstallation(ctx context.Context, db database.Conn, accountID, teamID, name, version string, ids map[string]string, now time.Time) (Installation, error) {
+	idmap, err := json.Marshal(ids)
+	if err != nil {
+		return Installation{}, errors.Wrap(err, "encode idmap to bytes")
+	}
+
+	inst := Installation{
+		ID:          uuid.New().String(),
+		AccountID:   accountID,
+		TeamID:      teamID,
+		Name:        name,
+		Version:     version,
+		IDMapb:      string(idmap),
+		InstalledAt: now.UTC(),
+		UpdatedAt:   now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO packages
+		(package_id, account_id, team_id, name, version, idmapb, installed_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = db.ExecContext(
+		ctx, q,
+		inst.ID, inst.AccountID, inst.TeamID, inst.Name, inst.Version, inst.IDMapb,
+		inst.InstalledAt, inst.UpdatedAt,
+	)
+	if err != nil {
+		return Installation{}, errors.Wrap(err, "inserting package")
+	}
+	return inst, nil
+}
+
+// UpdateInstallation saves the version and the ids map after the upgrade.
+func UpdateInstallation(ctx context.Context, db database.Conn, installationID, version string, ids map[string]string, now time.Time) error {
+	idmap, err := json.Marshal(ids)
+	if err != nil {
+		return errors.Wrap(err, "encode idmap to bytes")
+	}
+
+	const q = `UPDATE packages SET
+		"version" = $2,
+		"idmapb" = $3,
+		"updated_at" = $4
+		WHERE package_id = $1`
+	_, err = db.ExecContext(ctx, q, installationID, version, string(idmap), now.Unix())
+	if err != nil {
+		return errors.Wrap(err, "updating package")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+// TestPackages reads the packages shipped in the repo, the ones generated from the go bootstraps.
+func TestPackages(t *testing.T) {
+	files, err := filepath.Glob("../packages/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("\t%s should find the packages shipped : %v", tests.Failed, err)
+	}
+
+	t.Log("Given the need to install the packages shipped with the app")
+	for _, file := range files {
+		t.Logf("\twhen the package is %s", filepath.Base(file))
+		{
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := Decode(f)
+			f.Close()
+			if err != nil {
+				t.Fatalf("\t%s should decode the package : %s", tests.Failed, err)
+			}
+			if p.Name == "" || p.Version == "" || len(p.Entities) == 0 {
+				t.Fatalf("\t%s should name and version the package with its entities : %+v", tests.Failed, p)
+			}
+			t.Logf("\t%s should decode the package", tests.Success)
+
+			if missing := unresolved(p); len(missing) > 0 {
+				t.Fatalf("\t%s should resolve every entity and field referred : %v", tests.Failed, missing)
+			}
+			t.Logf("\t%s should resolve every entity and field referred", tests.Success)
+		}
+	}
+}
+
+// unresolved lists the references of the package neither owned by it nor required from the account.
+func unresolved(p Package) []string {
+	entities := make(map[string]map[string]bool)
+	known := make(map[string]bool)
+	for _, e := range p.Entities {
+		keys := make(map[string]bool, len(e.Fields))
+		for _, f := range e.Fields {
+			keys[f.Key] = true
+		}
+		entities[e.ID] = keys
+	}
+	for _, req := range p.Requires {
+		entities[req.ID] = make(map[string]bool)
+		for key := range req.Fields {
+			known[key] = true
+		}
+		for id := range req.Items {
+			known[id] = true
+		}
+	}
+
+	missing := make([]string, 0)
+	refer := func(id, where string) {
+		if _, ok := entities[id]; !ok {
+			missing = append(missing, where+" refers the entity "+id)
+		}
+	}
+	for _, e := range p.Entities {
+		for _, f := range e.Fields {
+			if f.RefID != "" {
+				refer(f.RefID, "field "+e.Name+"."+f.Name)
+			}
+			if f.Dependent != nil && f.Dependent.ParentKey != "" && !entities[e.ID][f.Dependent.ParentKey] {
+				missing = append(missing, "field "+e.Name+"."+f.Name+" depends on the field "+f.Dependent.ParentKey)
+			}
+		}
+		for _, l := range e.Layouts {
+			for _, key := range l.Fields {
+				if key != "" && !entities[e.ID][key] && !known[key] {
+					missing = append(missing, "layout "+e.Name+"."+l.Name+" refers the field "+key)
+				}
+			}
+		}
+	}
+	for _, a := range p.Associations {
+		refer(a.SrcEntityID, "association")
+		refer(a.DstEntityID, "association")
+	}
+	for _, f := range p.Flows {
+		refer(f.EntityID, "flow "+f.Name)
+	}
+	for _, d := range p.Dashboards {
+		if d.EntityID != "" && d.EntityID != nilID {
+			refer(d.EntityID, "dashboard "+d.Name)
+		}
+		for _, c := range d.Charts {
+			if c.EntityID != "" && c.EntityID != nilID {
+				refer(c.EntityID, "chart "+c.Name)
+			}
+		}
+	}
+	for _, i := range p.Items {
+		refer(i.EntityID, "item "+name(i.Name))
+	}
+
+	// the ids in the expressions, the tokens and the values are remapped on install only when they are known.
+	refs, err := p.references()
+	if err != nil {
+		return append(missing, err.Error())
+	}
+	for _, id := range refs {
+		if _, ok := entities[id]; !ok && !known[id] {
+			missing = append(missing, "the id "+id)
+		}
+	}
+	return missing
+}
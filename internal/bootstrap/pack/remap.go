@@ -0,0 +1,99 @@
+package pack
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// nilID is the placeholder used for the root nodes, the default stages and the associations. It is never remapped.
+const nilID = "00000000-0000-0000-0000-000000000000"
+
+var idRegex = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// Remap returns the package with its ids replaced using the ids map. The ids owned by the package but
+// missing in the map get a new id and are added to the map, so the map can be stored and reused on upgrade.
+// Since the ids are replaced in the encoded package, the references inside the expressions, the tokens,
+// the node actuals and the item values are remapped as well.
+func Remap(p Package, ids map[string]string) (Package, error) {
+	for _, id := range p.ownedIDs() {
+		if _, ok := ids[id]; !ok {
+			ids[id] = uuid.New().String()
+		}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return Package{}, errors.Wrap(err, "encoding package")
+	}
+	b = idRegex.ReplaceAllFunc(b, func(id []byte) []byte {
+		if newID, ok := ids[string(id)]; ok {
+			return []byte(newID)
+		}
+		return id
+	})
+
+	var rp Package
+	if err := json.Unmarshal(b, &rp); err != nil {
+		return Package{}, errors.Wrap(err, "decoding remapped package")
+	}
+	return rp, nil
+}
+
+// ownedIDs returns the ids created by the package. The field keys which are not uuids stay as they are
+// because they are scoped inside the entity anyway.
+func (p Package) ownedIDs() []string {
+	ids := make([]string, 0)
+	add := func(id string) {
+		if id != nilID && idRegex.MatchString(id) && len(id) == len(nilID) {
+			ids = append(ids, id)
+		}
+	}
+
+	for _, e := range p.Entities {
+		add(e.ID)
+		for _, f := range e.Fields {
+			add(f.Key)
+		}
+	}
+	for _, f := range p.Flows {
+		add(f.ID)
+		for _, n := range f.Nodes {
+			add(n.ID)
+		}
+	}
+	for _, d := range p.Dashboards {
+		add(d.ID)
+	}
+	for _, i := range p.Items {
+		add(i.ID)
+	}
+	return ids
+}
+
+// references returns the ids referred in the package which are not owned by it.
+func (p Package) references() ([]string, error) {
+	owned := make(map[string]bool)
+	for _, id := range p.ownedIDs() {
+		owned[id] = true
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding package")
+	}
+
+	seen := make(map[string]bool)
+	refs := make([]string, 0)
+	for _, id := range idRegex.FindAll(b, -1) {
+		s := string(id)
+		if s == nilID || owned[s] || seen[s] {
+			continue
+		}
+		seen[s] = true
+		refs = append(refs, s)
+	}
+	return refs, nil
+}
@@ -0,0 +1,128 @@
+package pack_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+const (
+	dealEntityID  = "11111111-1111-1111-1111-111111111111"
+	amountKey     = "22222222-2222-2222-2222-222222222222"
+	flowID        = "33333333-3333-3333-3333-333333333333"
+	nodeID        = "44444444-4444-4444-4444-444444444444"
+	ownerEntityID = "55555555-5555-5555-5555-555555555555"
+	rootNodeID    = "00000000-0000-0000-0000-000000000000"
+)
+
+func samplePackage() pack.Package {
+	return pack.Package{
+		Format: pack.FormatVersion,
+		Name:   "crp",
+		Requires: []pack.Requirement{
+			{ID: ownerEntityID, Name: entity.FixedEntityOwner},
+		},
+		Entities: []pack.Entity{
+			{
+				ID:   dealEntityID,
+				Name: "deals",
+				Fields: []entity.Field{
+					{Key: amountKey, Name: "amount", DisplayName: "Amount"},
+					{Key: "owner", Name: "owner", RefID: ownerEntityID},
+				},
+			},
+		},
+		Flows: []pack.Flow{
+			{
+				ID:         flowID,
+				EntityID:   dealEntityID,
+				Name:       "Big deals",
+				Expression: "{{" + dealEntityID + "." + amountKey + "}} gt {1000}",
+				Nodes:      []pack.Node{{ID: nodeID, ParentNodeID: rootNodeID, Name: "Notify"}},
+			},
+		},
+	}
+}
+
+func TestRemap(t *testing.T) {
+	t.Log("Given the need to import the package into an account")
+	{
+		t.Log("\twhen the package is remapped for the first time")
+		{
+			ids := map[string]string{ownerEntityID: "66666666-6666-6666-6666-666666666666"}
+			rp, err := pack.Remap(samplePackage(), ids)
+			if err != nil {
+				t.Fatalf("\t%s should remap the package - %s", tests.Failed, err)
+			}
+
+			e := rp.Entities[0]
+			if e.ID == dealEntityID || e.ID != ids[dealEntityID] || e.Fields[0].Key != ids[amountKey] {
+				t.Fatalf("\t%s should give new ids to the entities and the fields. got %+v", tests.Failed, e)
+			}
+			if e.Fields[1].RefID != "66666666-6666-6666-6666-666666666666" || e.Fields[1].Key != "owner" {
+				t.Fatalf("\t%s should point the references to the resolved requirements. got %+v", tests.Failed, e.Fields[1])
+			}
+			f := rp.Flows[0]
+			if f.EntityID != e.ID || f.Expression != "{{"+e.ID+"."+e.Fields[0].Key+"}} gt {1000}" {
+				t.Fatalf("\t%s should remap the ids inside the expression. got %s", tests.Failed, f.Expression)
+			}
+			if f.Nodes[0].ParentNodeID != rootNodeID {
+				t.Fatalf("\t%s should not remap the root node. got %s", tests.Failed, f.Nodes[0].ParentNodeID)
+			}
+			t.Logf("\t%s should remap the ids and the references", tests.Success)
+		}
+
+		t.Log("\twhen the package is remapped again with the stored ids")
+		{
+			ids := map[string]string{}
+			first, _ := pack.Remap(samplePackage(), ids)
+			second, _ := pack.Remap(samplePackage(), ids)
+			if first.Entities[0].ID != second.Entities[0].ID || first.Flows[0].Nodes[0].ID != second.Flows[0].Nodes[0].ID {
+				t.Fatalf("\t%s should reuse the ids of the installation", tests.Failed)
+			}
+			t.Logf("\t%s should reuse the ids of the installation", tests.Success)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Log("Given the need to upgrade the installed package")
+	{
+		current := samplePackage()
+		next := samplePackage()
+		next.Entities[0].Fields[0].DisplayName = "Deal Amount"
+		next.Entities[0].Fields = append(next.Entities[0].Fields, entity.Field{Key: "stage", Name: "stage"})
+		next.Flows[0].Nodes = nil
+		next.Flows = append(next.Flows, pack.Flow{ID: "77777777-7777-7777-7777-777777777777", EntityID: dealEntityID, Name: "Lost deals"})
+
+		t.Log("\twhen the new version changes the fields and the flows")
+		{
+			changes := pack.Diff(current, next)
+			want := map[string]bool{
+				pack.KindField + pack.OpUpdate + amountKey:                          true,
+				pack.KindField + pack.OpAdd + "stage":                               true,
+				pack.KindFlow + pack.OpAdd + "77777777-7777-7777-7777-777777777777": true,
+			}
+			if len(changes) != len(want) {
+				t.Fatalf("\t%s should find %d changes. got %+v", tests.Failed, len(want), changes)
+			}
+			for _, c := range changes {
+				if !want[c.Kind+c.Op+c.ID] {
+					t.Fatalf("\t%s should not find the change %+v", tests.Failed, c)
+				}
+			}
+			t.Logf("\t%s should find the additions and the updates", tests.Success)
+		}
+
+		t.Log("\twhen the package is installed fresh")
+		{
+			changes := pack.Diff(pack.Package{}, current)
+			if len(changes) == 0 || changes[0].Kind != pack.KindEntity || changes[0].Op != pack.OpAdd {
+				t.Fatalf("\t%s should add everything. got %+v", tests.Failed, changes)
+			}
+			t.Logf("\t%s should add everything", tests.Success)
+		}
+	}
+}
@@ -125,7 +125,7 @@ func AgileTypeVals(nameKey, colorKey, name, color string) map[string]interface{}
 	return typeVals
 }
 
-func AgileTaskFields(agileStatusEntityID, agileStatusTitleKey, agilePriorityEntityID, agilePriorityTitleKey, agileTypeEntityID, agileTypeTitleKey, ownerEntityID, ownerEntityKey string) []entity.Field {
+func AgileTaskFields(agileTaskEntityID, agileStatusEntityID, agileStatusTitleKey, agilePriorityEntityID, agilePriorityTitleKey, agileTypeEntityID, agileTypeTitleKey, ownerEntityID, ownerEntityKey string) []entity.Field {
 	nameFieldID := uuid.New().String()
 	nameField := entity.Field{
 		Key:         nameFieldID,
@@ -143,6 +143,7 @@ func AgileTaskFields(agileStatusEntityID, agileStatusTitleKey, agilePriorityEnti
 		DisplayName: "Start Time",
 		DomType:     entity.DomText,
 		DataType:    entity.TypeDateTime,
+		Who:         entity.WhoStartTime,
 	}
 
 	endTimeFieldID := uuid.New().String()
@@ -152,6 +153,7 @@ func AgileTaskFields(agileStatusEntityID, agileStatusTitleKey, agilePriorityEnti
 		DisplayName: "End Time",
 		DomType:     entity.DomText,
 		DataType:    entity.TypeDateTime,
+		Who:         entity.WhoEndTime,
 		Meta:        map[string]string{entity.MetaKeyRow: "true"},
 	}
 
@@ -224,5 +226,23 @@ func AgileTaskFields(agileStatusEntityID, agileStatusTitleKey, agilePriorityEnti
 		},
 	}
 
-	return []entity.Field{nameField, startTimeField, endTimeField, statusField, priorityField, typeField, ownerField}
+	dependsOnFieldID := uuid.New().String()
+	dependsOnField := entity.Field{
+		Key:         dependsOnFieldID,
+		Name:        "depends_on",
+		DisplayName: "Depends On",
+		DomType:     entity.DomAutoComplete,
+		DataType:    entity.TypeReference,
+		RefID:       agileTaskEntityID,
+		RefType:     entity.RefTypeStraight,
+		Who:         entity.WhoDependsOn,
+		Meta:        map[string]string{entity.MetaKeyDisplayGex: nameFieldID},
+		Field: &entity.Field{
+			DataType: entity.TypeString,
+			Key:      "id",
+			Value:    "--",
+		},
+	}
+
+	return []entity.Field{nameField, startTimeField, endTimeField, statusField, priorityField, typeField, ownerField, dependsOnField}
 }
@@ -107,14 +107,16 @@ func Boot(ctx context.Context, b *base.Base) error {
 	fmt.Println("\tPM:BOOT Agile Type Items Created")
 
 	// add entity - agile task
-	_, err = b.EntityAdd(ctx, uuid.New().String(), entity.FixedEntityAgileTask, "Agile Tasks", entity.CategoryTask, entity.StateTeamLevel, false, true, false, AgileTaskFields(agileStatusEntity.ID, agileStatusEntity.Key("name"), agilePriorityEntity.ID, agilePriorityEntity.Key("name"), agileTypeEntity.ID, agileTypeEntity.Key("name"), b.OwnerEntity.ID, b.OwnerEntity.Key("email")))
+	agileTaskEntityID := uuid.New().String()
+	_, err = b.EntityAdd(ctx, agileTaskEntityID, entity.FixedEntityAgileTask, "Agile Tasks", entity.CategoryTask, entity.StateTeamLevel, false, true, false, AgileTaskFields(agileTaskEntityID, agileStatusEntity.ID, agileStatusEntity.Key("name"), agilePriorityEntity.ID, agilePriorityEntity.Key("name"), agileTypeEntity.ID, agileTypeEntity.Key("name"), b.OwnerEntity.ID, b.OwnerEntity.Key("email")))
 	if err != nil {
 		return err
 	}
 	fmt.Println("\tPM:BOOT Agile Tasks Entity Created")
 
 	// add entity - agile sub-task
-	_, err = b.EntityAdd(ctx, uuid.New().String(), entity.FixedEntityAgileSubTask, "Sub Tasks", entity.CategoryTask, entity.StateTeamLevel, false, false, false, AgileTaskFields(agileStatusEntity.ID, agileStatusEntity.Key("name"), agilePriorityEntity.ID, agilePriorityEntity.Key("name"), agileTypeEntity.ID, agileTypeEntity.Key("name"), b.OwnerEntity.ID, b.OwnerEntity.Key("email")))
+	agileSubTaskEntityID := uuid.New().String()
+	_, err = b.EntityAdd(ctx, agileSubTaskEntityID, entity.FixedEntityAgileSubTask, "Sub Tasks", entity.CategoryTask, entity.StateTeamLevel, false, false, false, AgileTaskFields(agileSubTaskEntityID, agileStatusEntity.ID, agileStatusEntity.Key("name"), agilePriorityEntity.ID, agilePriorityEntity.Key("name"), agileTypeEntity.ID, agileTypeEntity.Key("name"), b.OwnerEntity.ID, b.OwnerEntity.Key("email")))
 	if err != nil {
 		return err
 	}
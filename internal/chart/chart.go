@@ -10,6 +10,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -31,6 +32,20 @@ func ListByDashID(ctx context.Context, accountID, teamID, dashboardID string, db
 	return charts, nil
 }
 
+// EntityCharts returns the charts of the entity across the teams.
+func EntityCharts(ctx context.Context, accountID, entityID string, db *sqlx.DB) ([]Chart, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.chart.EntityCharts")
+	defer span.End()
+
+	charts := []Chart{}
+	const q = `SELECT * FROM charts where account_id = $1 AND entity_id = $2`
+	if err := db.SelectContext(ctx, &charts, q, accountID, entityID); err != nil {
+		return charts, errors.Wrap(err, "selecting charts of the entity")
+	}
+
+	return charts, nil
+}
+
 func ListByEntityID(ctx context.Context, accountID, teamID, entityID string, db *sqlx.DB) ([]Chart, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.chart.List")
 	defer span.End()
@@ -44,7 +59,7 @@ func ListByEntityID(ctx context.Context, accountID, teamID, entityID string, db
 	return charts, nil
 }
 
-func Create(ctx context.Context, db *sqlx.DB, nc NewChart, now time.Time) error {
+func Create(ctx context.Context, db database.Conn, nc NewChart, now time.Time) error {
 	ctx, span := trace.StartSpan(ctx, "internal.chart.Create")
 	defer span.End()
 
@@ -190,6 +205,14 @@ func (c Chart) GetAdvancedMap() map[string]string {
 	return map[string]string{}
 }
 
+// GetReport returns the spec of the report the chart was saved from.
+func (c Chart) GetReport() string {
+	if val, ok := c.Meta()[MetaReport]; ok {
+		return val
+	}
+	return ""
+}
+
 func BuildNewChart(accountID, teamID, dashboardID, entityID, name, displayName, fieldName string, chartType Type) *NewChart {
 	NoEntityID := "00000000-0000-0000-0000-000000000000"
 	return &NewChart{
@@ -247,6 +270,13 @@ func (ch *NewChart) SetAsCustom() *NewChart {
 	return ch
 }
 
+// SetReport makes the chart show the report with the spec.
+func (ch *NewChart) SetReport(spec string) *NewChart {
+	ch.Meta[MetaDataType] = string(DTypeReport)
+	ch.Meta[MetaReport] = spec
+	return ch
+}
+
 func (ch *NewChart) SetDurationAllTime() *NewChart {
 	ch.Duration = string(AllTime)
 	return ch
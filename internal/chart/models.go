@@ -15,6 +15,7 @@ const (
 	MetaExp          = "exp"
 	MetaDateField    = "date"
 	MetaAdvancedMap  = "advanced_map"
+	MetaReport       = "report"
 )
 
 type Calc string
@@ -52,6 +53,8 @@ const (
 	DTypeDefault    DType = "default"
 	DTypeTimeseries DType = "timeseries"
 	DTypeCustom     DType = "custom"
+	DTypeSLA        DType = "sla"
+	DTypeReport     DType = "report"
 )
 
 type Duration string
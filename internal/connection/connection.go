@@ -155,6 +155,20 @@ func AllChild(ctx context.Context, db *sqlx.DB, accountID, srcEntityID, srcItemI
 	return connectedItems, nil
 }
 
+// Parents returns the IDs of the items of the src entity connected to the dst item.
+func Parents(ctx context.Context, db *sqlx.DB, accountID, srcEntityID, dstItemID string) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.connection.Parents")
+	defer span.End()
+
+	var ids []string
+	const q = `SELECT DISTINCT(src_item_id) FROM connections where account_id = $1 AND src_entity_id = $2 AND dst_item_id = $3`
+	if err := db.SelectContext(ctx, &ids, q, accountID, srcEntityID, dstItemID); err != nil {
+		return nil, errors.Wrap(err, "selecting src items for connected dst item")
+	}
+
+	return ids, nil
+}
+
 func Delete(ctx context.Context, db *sqlx.DB, relationshipID, dstItemID string) error {
 	ctx, span := trace.StartSpan(ctx, "internal.connection.Delete")
 	defer span.End()
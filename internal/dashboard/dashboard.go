@@ -10,6 +10,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -31,7 +32,21 @@ func List(ctx context.Context, accountID, teamID, entityID string, db *sqlx.DB)
 	return dashboards, nil
 }
 
-func Create(ctx context.Context, db *sqlx.DB, nd NewDashboard, now time.Time) error {
+// TeamDashboards returns all the dashboards of the team irrespective of the entity.
+func TeamDashboards(ctx context.Context, accountID, teamID string, db *sqlx.DB) ([]Dashboard, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.dashboard.TeamDashboards")
+	defer span.End()
+
+	dashboards := []Dashboard{}
+	const q = `SELECT * FROM dashboards where account_id = $1 AND team_id = $2`
+	if err := db.SelectContext(ctx, &dashboards, q, accountID, teamID); err != nil {
+		return dashboards, errors.Wrap(err, "selecting dashboards for a team")
+	}
+
+	return dashboards, nil
+}
+
+func Create(ctx context.Context, db database.Conn, nd NewDashboard, now time.Time) error {
 	ctx, span := trace.StartSpan(ctx, "internal.dashboard.Create")
 	defer span.End()
 
@@ -0,0 +1,379 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ErrFormula is returned when the formula can not be evaluated.
+var ErrFormula = errors.New("Invalid formula")
+
+// Rollup defines the value of the field folded from the child items related to the item.
+type Rollup struct {
+	Of     string // the child entity
+	Via    string // the reference field of the child pointing the parent. empty when related with the connections
+	Fn     string
+	Field  string
+	Filter string
+}
+
+var selfKeys = regexp.MustCompile(`{{self\.([^}]+)}}`)
+
+func (f Field) IsFormula() bool {
+	return f.Meta[MetaKeyFormula] != ""
+}
+
+func (f Field) Formula() string {
+	return f.Meta[MetaKeyFormula]
+}
+
+func (f Field) IsRollup() bool {
+	return f.Meta[MetaKeyRollupOf] != ""
+}
+
+// RollupDef returns the rollup definition of the field. Not to be confused with the RollUp which is
+// the frequency the time series are folded.
+func (f Field) RollupDef() (Rollup, bool) {
+	if !f.IsRollup() {
+		return Rollup{}, false
+	}
+	r := Rollup{
+		Of:     f.Meta[MetaKeyRollupOf],
+		Via:    f.Meta[MetaKeyRollupVia],
+		Fn:     f.Meta[MetaKeyRollupFn],
+		Field:  f.Meta[MetaKeyRollupField],
+		Filter: f.Meta[MetaKeyRollupFilter],
+	}
+	if r.Fn == "" {
+		r.Fn = RollupCount
+	}
+	return r, true
+}
+
+// IsComputed tells the value of the field is evaluated by the worker and not entered by the users.
+func (f Field) IsComputed() bool {
+	return f.IsFormula() || f.IsRollup() || f.Meta[MetaKeyCalc] != ""
+}
+
+// IsReadOnly tells the value of the field is not written by the callers of the api, being computed by
+// the worker or kept by the system.
+func (f Field) IsReadOnly() bool {
+	return f.IsComputed() || f.Meta[MetaKeyReadOnly] == "true"
+}
+
+// Touched tells whether the change of the child item affects the rollup. The created and the deleted
+// children have no old or new values.
+func (r Rollup) Touched(oldFields, newFields map[string]interface{}) bool {
+	if oldFields == nil || newFields == nil {
+		return true
+	}
+	keys := []string{r.Via, r.Field}
+	for _, m := range selfKeys.FindAllStringSubmatch(r.Filter, -1) {
+		keys = append(keys, m[1])
+	}
+	for _, k := range keys {
+		if k != "" && fmt.Sprint(oldFields[k]) != fmt.Sprint(newFields[k]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Aggregate folds the field of the children. The children are expected in the newest first order
+// so the latest picks the first value.
+func (r Rollup) Aggregate(children []map[string]interface{}) interface{} {
+	if r.Fn == RollupCount {
+		return float64(len(children))
+	}
+
+	var result interface{}
+	var sum float64
+	var n int
+	for _, c := range children {
+		v := c[r.Field]
+		if v == nil || v == "" {
+			continue
+		}
+		if r.Fn == RollupLatest {
+			return v
+		}
+		num, ok := toNumber(v)
+		if !ok {
+			// the dates are folded by the time, the lexical order of the other values is meaningless.
+			if (r.Fn == RollupMin || r.Fn == RollupMax) && lessTime(v, result, r.Fn == RollupMax) {
+				result = v
+			}
+			continue
+		}
+		sum += num
+		n++
+		switch r.Fn {
+		case RollupMin:
+			if cur, ok := result.(float64); !ok || num < cur {
+				result = num
+			}
+		case RollupMax:
+			if cur, ok := result.(float64); !ok || num > cur {
+				result = num
+			}
+		}
+	}
+
+	switch r.Fn {
+	case RollupSum:
+		return sum
+	case RollupAvg:
+		if n == 0 {
+			return nil
+		}
+		return sum / float64(n)
+	}
+	return result
+}
+
+// lessTime tells the time value comes before the current one, or after when reversed.
+func lessTime(v, cur interface{}, reverse bool) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	t, err := ParseValueTime(s)
+	if err != nil {
+		return false
+	}
+	cs, ok := cur.(string)
+	if !ok {
+		return true
+	}
+	ct, err := ParseValueTime(cs)
+	if err != nil {
+		return true
+	}
+	if reverse {
+		return t.After(ct)
+	}
+	return t.Before(ct)
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// EvalFormula evaluates the arithmetic formula over the values of the item. The fields are referred
+// by the key as {{key}} and the empty values are taken as zero. The operators + - * / %, the parentheses
+// and the functions min, max, round and abs are supported.
+func EvalFormula(formula string, values map[string]interface{}) (float64, error) {
+	p := &parser{src: formula, values: values}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return 0, errors.Wrapf(ErrFormula, "unexpected %q at %d", p.src[p.pos:], p.pos)
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, errors.Wrap(ErrFormula, "division by zero")
+	}
+	return v, nil
+}
+
+// parser is the recursive descent parser of the formula, evaluating while it reads.
+type parser struct {
+	src    string
+	pos    int
+	values map[string]interface{}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// expr := term (('+' | '-') term)*
+func (p *parser) expr() (float64, error) {
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+', '-':
+			op := p.src[p.pos]
+			p.pos++
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			if op == '+' {
+				v += r
+			} else {
+				v -= r
+			}
+		default:
+			return v, nil
+		}
+	}
+}
+
+// term := unary (('*' | '/' | '%') unary)*
+func (p *parser) term() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '*', '/', '%':
+			op := p.src[p.pos]
+			p.pos++
+			r, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			switch op {
+			case '*':
+				v *= r
+			case '/':
+				v /= r
+			default:
+				v = math.Mod(v, r)
+			}
+		default:
+			return v, nil
+		}
+	}
+}
+
+// unary := '-' unary | primary
+func (p *parser) unary() (float64, error) {
+	if p.peek() == '-' {
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	}
+	return p.primary()
+}
+
+// primary := number | {{key}} | '(' expr ')' | fn '(' expr (',' expr)* ')'
+func (p *parser) primary() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, errors.Wrapf(ErrFormula, "missing ) at %d", p.pos)
+		}
+		p.pos++
+		return v, nil
+	case strings.HasPrefix(p.src[p.pos:], "{{"):
+		end := strings.Index(p.src[p.pos:], "}}")
+		if end < 0 {
+			return 0, errors.Wrapf(ErrFormula, "missing }} at %d", p.pos)
+		}
+		key := strings.TrimSpace(p.src[p.pos+2 : p.pos+end])
+		p.pos += end + 2
+		v := p.values[key]
+		if v == nil || v == "" {
+			return 0, nil
+		}
+		num, ok := toNumber(v)
+		if !ok {
+			return 0, errors.Wrapf(ErrFormula, "the value of %s is not a number", key)
+		}
+		return num, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return 0, errors.Wrapf(ErrFormula, "bad number %q", p.src[start:p.pos])
+		}
+		return v, nil
+	case unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.src) && unicode.IsLetter(rune(p.src[p.pos])) {
+			p.pos++
+		}
+		return p.call(strings.ToLower(p.src[start:p.pos]))
+	}
+	return 0, errors.Wrapf(ErrFormula, "unexpected token at %d", p.pos)
+}
+
+func (p *parser) call(fn string) (float64, error) {
+	if p.peek() != '(' {
+		return 0, errors.Wrapf(ErrFormula, "missing ( after %s", fn)
+	}
+	p.pos++
+	args := make([]float64, 0)
+	for {
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if p.peek() != ')' {
+			return 0, errors.Wrapf(ErrFormula, "missing ) at %d", p.pos)
+		}
+		p.pos++
+		break
+	}
+
+	switch fn {
+	case "min", "max":
+		v := args[0]
+		for _, a := range args[1:] {
+			if fn == "min" {
+				v = math.Min(v, a)
+			} else {
+				v = math.Max(v, a)
+			}
+		}
+		return v, nil
+	case "round":
+		if len(args) == 2 {
+			pow := math.Pow(10, args[1])
+			return math.Round(args[0]*pow) / pow, nil
+		}
+		return math.Round(args[0]), nil
+	case "abs":
+		return math.Abs(args[0]), nil
+	}
+	return 0, errors.Wrapf(ErrFormula, "unknown function %s", fn)
+}
@@ -0,0 +1,95 @@
+package entity_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestEvalFormula(t *testing.T) {
+	values := map[string]interface{}{"amount": 2000.0, "probability": "25", "discount": nil, "name": "Acme"}
+
+	t.Log("Given the need to evaluate the formula fields")
+	{
+		cases := []struct {
+			formula string
+			want    float64
+			valid   bool
+		}{
+			{"{{amount}} * {{probability}} / 100", 500, true},
+			{"({{amount}} - {{discount}}) * -1", -2000, true},
+			{"max({{amount}}, 5000) + min(1, 2, 3)", 5001, true},
+			{"round(10 / 3, 2) + abs(-1) + 7 % 4", 7.33, true},
+			{"{{amount}} / {{discount}}", 0, false},
+			{"{{name}} + 1", 0, false},
+			{"sqrt(4)", 0, false},
+			{"({{amount}}", 0, false},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the formula is %q", c.formula)
+			{
+				got, err := entity.EvalFormula(c.formula, values)
+				if (err == nil) != c.valid || got != c.want {
+					t.Fatalf("\t%s should evaluate to %v (valid %v). got %v, %v", tests.Failed, c.want, c.valid, got, err)
+				}
+				t.Logf("\t%s should evaluate to %v", tests.Success, c.want)
+			}
+		}
+	}
+}
+
+func TestRollup(t *testing.T) {
+	children := []map[string]interface{}{
+		{"amount": 300.0, "stage": []interface{}{"won"}, "closed_at": "2026-03-01"},
+		{"amount": "100", "stage": []interface{}{"open"}, "closed_at": "2026-01-01"},
+		{"amount": nil, "stage": []interface{}{"open"}, "closed_at": "2026-02-01"},
+	}
+
+	t.Log("Given the need to roll up the child items")
+	{
+		cases := []struct {
+			fn, field string
+			want      interface{}
+		}{
+			{entity.RollupCount, "", 3.0},
+			{entity.RollupSum, "amount", 400.0},
+			{entity.RollupAvg, "amount", 200.0},
+			{entity.RollupMin, "amount", 100.0},
+			{entity.RollupMax, "amount", 300.0},
+			{entity.RollupLatest, "amount", 300.0},
+			{entity.RollupMax, "closed_at", "2026-03-01"},
+			{entity.RollupMin, "closed_at", "2026-01-01"},
+		}
+		for _, c := range cases {
+			r := entity.Rollup{Fn: c.fn, Field: c.field}
+			if got := r.Aggregate(children); got != c.want {
+				t.Fatalf("\t%s %s of %s should be %v. got %v", tests.Failed, c.fn, c.field, c.want, got)
+			}
+			t.Logf("\t%s %s of %s should be %v", tests.Success, c.fn, c.field, c.want)
+		}
+
+		t.Log("\twhen there are no children")
+		{
+			if got := (entity.Rollup{Fn: entity.RollupAvg, Field: "amount"}).Aggregate(nil); got != nil {
+				t.Fatalf("\t%s should not average. got %v", tests.Failed, got)
+			}
+			if got := (entity.Rollup{Fn: entity.RollupSum, Field: "amount"}).Aggregate(nil); got != 0.0 {
+				t.Fatalf("\t%s should sum to zero. got %v", tests.Failed, got)
+			}
+			t.Logf("\t%s should sum to zero and not average", tests.Success)
+		}
+
+		t.Log("\twhen the child changes")
+		{
+			r := entity.Rollup{Via: "company", Fn: entity.RollupSum, Field: "amount", Filter: "{{self.stage}} in {open}"}
+			old := map[string]interface{}{"company": []interface{}{"c-1"}, "amount": 10.0, "stage": []interface{}{"open"}, "name": "A"}
+			renamed := map[string]interface{}{"company": []interface{}{"c-1"}, "amount": 10.0, "stage": []interface{}{"open"}, "name": "B"}
+			won := map[string]interface{}{"company": []interface{}{"c-1"}, "amount": 10.0, "stage": []interface{}{"won"}, "name": "A"}
+			if r.Touched(old, renamed) || !r.Touched(old, won) || !r.Touched(nil, old) || !r.Touched(old, nil) {
+				t.Fatalf("\t%s should recompute only when the relation, the field or the filter changes", tests.Failed)
+			}
+			t.Logf("\t%s should recompute only when the relation, the field or the filter changes", tests.Success)
+		}
+	}
+}
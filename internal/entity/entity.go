@@ -73,6 +73,18 @@ func AccountEntities(ctx context.Context, accountID string, categoryIds []int, d
 	return entities, nil
 }
 
+// RollupEntities returns the entities of the account having the fields rolled up from the child entity.
+func RollupEntities(ctx context.Context, accountID, childEntityID string, db *sqlx.DB) ([]Entity, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.entity.RollupEntities")
+	defer span.End()
+	entities := []Entity{}
+	const q = `SELECT * FROM entities where account_id = $1 AND fieldsb @> jsonb_build_array(jsonb_build_object('meta', jsonb_build_object($2::text, $3::text)))`
+	if err := db.SelectContext(ctx, &entities, q, accountID, MetaKeyRollupOf, childEntityID); err != nil {
+		return nil, errors.Wrap(err, "selecting entities rolling up the child entity")
+	}
+	return entities, nil
+}
+
 func TeamEntities(ctx context.Context, accountID, teamID string, categoryIds []int, db *sqlx.DB) ([]Entity, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.entity.TeamEntities")
 	defer span.End()
@@ -93,7 +105,7 @@ func TeamEntities(ctx context.Context, accountID, teamID string, categoryIds []i
 }
 
 // Create inserts a new user into the database.
-func Create(ctx context.Context, db *sqlx.DB, n NewEntity, now time.Time) (Entity, error) {
+func Create(ctx context.Context, db database.Conn, n NewEntity, now time.Time) (Entity, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.entity.Create")
 	defer span.End()
 
@@ -145,7 +157,7 @@ func Create(ctx context.Context, db *sqlx.DB, n NewEntity, now time.Time) (Entit
 }
 
 // Update replaces a item document in the database.
-func Update(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, entityID string, fieldsB string, now time.Time) error {
+func Update(ctx context.Context, db database.Conn, sdb *database.SecDB, accountID, entityID string, fieldsB string, now time.Time) error {
 	ctx, span := trace.StartSpan(ctx, "internal.entity.Update")
 	defer span.End()
 
@@ -170,7 +182,7 @@ func Update(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, en
 	return relationship.ReBonding(ctx, db, accountID, entityID, refFields(updatedFields))
 }
 
-func UpdateSharedTeam(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, aID, eID string, teamIds pq.StringArray, now time.Time) error {
+func UpdateSharedTeam(ctx context.Context, db database.Conn, sdb *database.SecDB, aID, eID string, teamIds pq.StringArray, now time.Time) error {
 	ctx, span := trace.StartSpan(ctx, "internal.entity.UpdateSharedTeam")
 	defer span.End()
 
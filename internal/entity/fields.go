@@ -90,6 +90,13 @@ const (
 	MetaKeyCalc         = "calc"
 	MetaKeyRollUp       = "rollup"
 	MetaKeyPublic       = "public"
+	MetaKeyReadOnly     = "read_only"     // the value is kept by the system, like the health score, and not written by the api callers
+	MetaKeyFormula      = "formula"       // the expression over the fields of the item, e.g. {{amount}} * {{probability}} / 100
+	MetaKeyRollupOf     = "rollup_of"     // the child entity rolled up
+	MetaKeyRollupVia    = "rollup_via"    // the reference field of the child entity pointing the parent. empty for the connections
+	MetaKeyRollupFn     = "rollup_fn"     // count/sum/min/max/avg/latest
+	MetaKeyRollupField  = "rollup_field"  // the field of the child entity rolled up
+	MetaKeyRollupFilter = "rollup_filter" // the expression the child items should satisfy, e.g. {{self.status}} in {open}
 )
 
 const (
@@ -111,6 +118,13 @@ const (
 	MetaRollUpHourly     = "hourly"
 	MetaRollUpMinute     = "minute"
 	MetaRollUpChangeOver = "change_over"
+
+	RollupCount  = "count"
+	RollupSum    = "sum"
+	RollupMin    = "min"
+	RollupMax    = "max"
+	RollupAvg    = "avg"
+	RollupLatest = "latest"
 )
 
 // traits of the field
@@ -140,6 +154,12 @@ const (
 	WhoPriority      = "priority"
 	WhoType          = "type"
 	WhoCategory      = "category"
+	WhoSLA           = "sla"
+	WhoSLAResponse   = "sla_response" // the first response counterpart of the sla field
+	WhoRecurrence    = "recurrence"   // the RRULE of the recurring meetings
+	WhoDependsOn     = "depends_on"   // the items of the entity the item waits for
+	WhoAmount        = "amount"       // the value of the deal the forecast weighs
+	WhoCloseDate     = "close_date"   // the date the deal is expected to close
 )
 
 // Field represents structural format of attributes in entity
@@ -50,6 +50,7 @@ const (
 	FixedEntityFlow             = "flows"
 	FixedEntityNode             = "nodes"
 	FixedEntityDelay            = "delay"
+	FixedEntitySchedule         = "schedule"
 	FixedEntityApprovals        = "approvals"
 	FixedEntityStatus           = "status"
 	FixedEntityApprovalStatus   = "approval_status"
@@ -121,7 +122,7 @@ type EmailConfigEntity struct {
 
 // CalendarxEntity represents structural format of calendar entity
 type CaldendarEntity struct {
-	ID        string    `json:"id"`
+	ID        string    `json:"id"` // the URL of the collection for CalDAV and of the feed for ICS
 	APIKey    string    `json:"api_key"`
 	Email     string    `json:"email"`
 	Owner     []string  `json:"owner"`
@@ -129,6 +130,8 @@ type CaldendarEntity struct {
 	SyncedAt  time.Time `json:"synced_at"`
 	SyncToken string    `json:"sync_token"`
 	Retries   int       `json:"retries"`
+	Provider  string    `json:"provider"` // the google calendar when empty
+	Username  string    `json:"username"`
 }
 
 // DelayEntity represents the structural format of delay entity
@@ -137,6 +140,13 @@ type DelayEntity struct {
 	DelayBy int    `json:"delay_by"` // in mins
 }
 
+// ScheduleEntity represents the structural format of schedule entity
+type ScheduleEntity struct {
+	Title    string `json:"title"`
+	Cron     string `json:"cron"`     // minute hour day-of-month month day-of-week
+	Timezone string `json:"timezone"` // IANA name. UTC when empty
+}
+
 // WebHookEntity represents structural format of webhook entity
 type WebHookEntity struct {
 	Path    string            `json:"path"`
@@ -17,9 +17,12 @@ const (
 	MetaRenderPipe  = "pipe"  // pipe/list/group
 	MetaRenderList  = "list"  // pipe/list/group
 	MetaRenderGroup = "group" // pipe/list/group
+	// the calendar and the timeline render the entities with the dates
+	MetaRenderCalendar = "calendar"
+	MetaRenderTimeline = "timeline"
 )
 
-//UpdateMeta patches the meta data right now it is used to save the UI render info (pipe/list)
+// UpdateMeta patches the meta data right now it is used to save the UI render info (pipe/list)
 func (e *Entity) UpdateMeta(ctx context.Context, db *sqlx.DB) error {
 	ctx, span := trace.StartSpan(ctx, "internal.entity.UpdateMeta")
 	defer span.End()
@@ -0,0 +1,263 @@
+package entity
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+)
+
+// SchemaDialect is the JSON Schema draft the item schemas follow.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+const dateLayout = "2006-01-02"
+
+// JSONSchema is the JSON Schema of the item fields of the entity. The fields are keyed by the field key
+// and the traits not expressible in the schema are kept in the x- extensions.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Const                interface{}            `json:"const,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	UniqueItems          bool                   `json:"uniqueItems,omitempty"`
+	ReadOnly             bool                   `json:"readOnly,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	DomType              Dom                    `json:"x-dom-type,omitempty"`
+	Who                  string                 `json:"x-who,omitempty"`
+	RefEntity            string                 `json:"x-ref-entity,omitempty"`
+	Unique               bool                   `json:"x-unique,omitempty"`
+	Hidden               bool                   `json:"x-hidden,omitempty"`
+}
+
+// ValueError is the reason the value of the field is not valid.
+type ValueError struct {
+	Key   string
+	Error string
+}
+
+// JSONSchema generates the schema of the item fields of the entity.
+func (e Entity) JSONSchema() JSONSchema {
+	closed := false
+	s := JSONSchema{
+		Schema:               SchemaDialect,
+		Title:                e.DisplayName,
+		Type:                 "object",
+		Properties:           make(map[string]*JSONSchema, 0),
+		Required:             make([]string, 0),
+		AdditionalProperties: &closed,
+	}
+	for _, f := range e.EasyFields() {
+		s.Properties[f.Key] = f.jsonSchema()
+		if f.IsRequired() {
+			s.Required = append(s.Required, f.Key)
+		}
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+func (f Field) jsonSchema() *JSONSchema {
+	s := valueSchema(f)
+	s.Title = f.DisplayName
+	s.Description = f.Name
+	s.DomType = f.DomType
+	s.Who = f.Who
+	s.Unique = f.IsUnique()
+	s.Hidden = f.IsHidden()
+	// the computed fields are filled by the worker, not by the callers.
+	s.ReadOnly = f.IsReadOnly()
+	if f.IsReference() && !f.IsFlow() && !f.IsNode() {
+		s.RefEntity = f.RefID
+	}
+	// the empty value clears the field, unless it is required.
+	if !f.IsRequired() {
+		s.Type = []interface{}{s.Type, "null"}
+	}
+	return s
+}
+
+func valueSchema(f Field) *JSONSchema {
+	switch f.DataType {
+	case TypeNumber:
+		return &JSONSchema{Type: "number"}
+	case TypeDate:
+		return &JSONSchema{Type: "string", Format: "date"}
+	case TypeDateTime:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case TypeList, TypeReference:
+		s := &JSONSchema{Type: "array", UniqueItems: true, Items: &JSONSchema{Type: "string"}}
+		if f.IsList() && f.Field != nil && f.Field.DataType == TypeNumber {
+			s.Items.Type = "number"
+		}
+		if f.IsList() && len(f.Choices) > 0 {
+			s.Items.Type = nil
+			for _, c := range f.Choices {
+				s.Items.OneOf = append(s.Items.OneOf, &JSONSchema{Const: c.ID, Title: fmt.Sprint(c.DisplayValue)})
+			}
+		}
+		if !f.multi() {
+			one := 1
+			s.MaxItems = &one
+		}
+		return s
+	}
+	return &JSONSchema{Type: "string"}
+}
+
+func (f Field) multi() bool {
+	return f.Meta[MetaMultiChoice] != "false"
+}
+
+// ValidateValues checks the item values against the fields of the entity. The unknown keys, the wrong types,
+// the choices not in the field and the malformed dates are reported. The required and the unique fields are
+// checked by the callers as they need the stored items. The read only fields are rejected in both the modes.
+// In the lenient mode the unknown keys are ignored and the values stored by the older clients, such as the
+// numbers sent as strings, are accepted.
+func (e Entity) ValidateValues(values map[string]interface{}, lenient bool) []ValueError {
+	keyMap := KeyMap(e.EasyFields())
+	verrs := make([]ValueError, 0)
+	for key, val := range values {
+		f, ok := keyMap[key]
+		if !ok {
+			if !lenient {
+				verrs = append(verrs, ValueError{Key: key, Error: "unknown field"})
+			}
+			continue
+		}
+		if f.IsReadOnly() {
+			if !emptyValue(val) {
+				verrs = append(verrs, ValueError{Key: key, Error: "is read only"})
+			}
+			continue
+		}
+		if err := f.validateValue(val, lenient); err != "" {
+			verrs = append(verrs, ValueError{Key: key, Error: err})
+		}
+	}
+	sort.Slice(verrs, func(i, j int) bool { return verrs[i].Key < verrs[j].Key })
+	return verrs
+}
+
+// emptyValue tells the value carries nothing, the clients send the read only fields of the forms that way.
+func emptyValue(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func (f Field) validateValue(val interface{}, lenient bool) string {
+	if val == nil || val == "" {
+		return ""
+	}
+
+	switch f.DataType {
+	case TypeNumber:
+		return validNumber(val, lenient)
+	case TypeDate, TypeDateTime:
+		return validTime(val, f.IsDate(), lenient)
+	case TypeList, TypeReference:
+		vals, ok := val.([]interface{})
+		if !ok {
+			if _, single := val.(string); !lenient || !single {
+				return "should be an array"
+			}
+			vals = []interface{}{val}
+		}
+		if len(vals) > 1 && !f.multi() {
+			return "should have only one value"
+		}
+		choices := f.ChoiceMap()
+		for _, v := range vals {
+			if err := f.validateElem(v, choices, lenient); err != "" {
+				return err
+			}
+		}
+		return ""
+	}
+
+	switch val.(type) {
+	case string:
+		return ""
+	case float64, bool:
+		if lenient {
+			return ""
+		}
+	}
+	return "should be a string"
+}
+
+func (f Field) validateElem(v interface{}, choices map[string]Choice, lenient bool) string {
+	if f.IsList() && len(f.Choices) > 0 {
+		id, _ := v.(string)
+		if _, ok := choices[id]; !ok {
+			return fmt.Sprintf("%v is not a choice of the field", v)
+		}
+		return ""
+	}
+	if f.IsList() && f.Field != nil && f.Field.DataType == TypeNumber {
+		return validNumber(v, lenient)
+	}
+	if id, ok := v.(string); !ok || id == "" {
+		return "should have the IDs as strings"
+	}
+	return ""
+}
+
+func validNumber(val interface{}, lenient bool) string {
+	switch v := val.(type) {
+	case float64, int, int64:
+		return ""
+	case string:
+		if _, err := strconv.ParseFloat(v, 64); lenient && err == nil {
+			return ""
+		}
+	}
+	return "should be a number"
+}
+
+func validTime(val interface{}, dateOnly, lenient bool) string {
+	want := "should be a date-time"
+	if dateOnly {
+		want = "should be a date (YYYY-MM-DD)"
+	}
+	switch v := val.(type) {
+	case string:
+		if _, err := ParseValueTime(v); err == nil {
+			return ""
+		}
+	case float64:
+		// the older clients sent the unix milliseconds.
+		if lenient {
+			return ""
+		}
+	}
+	return want
+}
+
+// ParseValueTime parses the value of the date and the date-time fields. The values are saved with the go
+// layout of the util, the RFC 3339 and the date only layouts.
+func ParseValueTime(s string) (time.Time, error) {
+	if t, err := util.ParseTime(s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, s)
+}
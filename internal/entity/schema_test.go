@@ -0,0 +1,95 @@
+package entity_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func schemaEntity(t *testing.T) entity.Entity {
+	fields := []entity.Field{
+		{Key: "name", DisplayName: "Name", DataType: entity.TypeString, DomType: entity.DomText, Meta: map[string]string{entity.MetaKeyRequired: "true"}},
+		{Key: "amount", DisplayName: "Amount", DataType: entity.TypeNumber, DomType: entity.DomText},
+		{Key: "close_on", DisplayName: "Close On", DataType: entity.TypeDate, DomType: entity.DomText},
+		{Key: "stage", DisplayName: "Stage", DataType: entity.TypeList, DomType: entity.DomSelect, Meta: map[string]string{entity.MetaMultiChoice: "false"},
+			Choices: []entity.Choice{{ID: "open", DisplayValue: "Open"}, {ID: "won", DisplayValue: "Won"}}},
+		{Key: "contact", DisplayName: "Contact", DataType: entity.TypeReference, DomType: entity.DomAutoComplete, RefID: "contacts-entity"},
+		{Key: "weighted", DisplayName: "Weighted", DataType: entity.TypeNumber, DomType: entity.DomText, Meta: map[string]string{entity.MetaKeyFormula: "{{amount}} / 2"}},
+	}
+	fieldsb, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("\t%s should encode the fields : %s", tests.Failed, err)
+	}
+	return entity.Entity{ID: "deals-entity", DisplayName: "Deals", Fieldsb: string(fieldsb)}
+}
+
+func TestJSONSchema(t *testing.T) {
+	t.Log("Given the need to describe the item values of the entity")
+	{
+		s := schemaEntity(t).JSONSchema()
+
+		t.Log("\twhen the schema is generated from the fields")
+		{
+			if s.Title != "Deals" || len(s.Properties) != 6 || len(s.Required) != 1 || s.Required[0] != "name" || *s.AdditionalProperties {
+				t.Fatalf("\t%s should list the fields and the required keys. got %+v", tests.Failed, s)
+			}
+			stage := s.Properties["stage"]
+			if stage.Items == nil || len(stage.Items.OneOf) != 2 || stage.Items.OneOf[0].Const != "open" || stage.MaxItems == nil || *stage.MaxItems != 1 {
+				t.Fatalf("\t%s should limit the list to one of the choices. got %+v", tests.Failed, stage)
+			}
+			if s.Properties["contact"].RefEntity != "contacts-entity" || s.Properties["close_on"].Format != "date" {
+				t.Fatalf("\t%s should keep the referred entity and the date format", tests.Failed)
+			}
+			if !s.Properties["weighted"].ReadOnly || s.Properties["amount"].ReadOnly {
+				t.Fatalf("\t%s should mark only the computed field read only", tests.Failed)
+			}
+			if _, err := json.Marshal(s); err != nil {
+				t.Fatalf("\t%s should encode the schema : %s", tests.Failed, err)
+			}
+			t.Logf("\t%s should describe every field", tests.Success)
+		}
+	}
+}
+
+func TestValidateValues(t *testing.T) {
+	e := schemaEntity(t)
+
+	t.Log("Given the need to validate the item values strictly")
+	{
+		cases := []struct {
+			name    string
+			values  map[string]interface{}
+			lenient bool
+			keys    []string
+		}{
+			{"valid values", map[string]interface{}{"name": "Acme", "amount": 10.5, "close_on": "2026-10-19", "stage": []interface{}{"won"}, "contact": []interface{}{"c-1"}}, false, nil},
+			{"cleared values", map[string]interface{}{"amount": nil, "close_on": "", "stage": []interface{}{}}, false, nil},
+			{"unknown key", map[string]interface{}{"nickname": "Acme"}, false, []string{"nickname"}},
+			{"wrong types", map[string]interface{}{"name": 10.0, "amount": "10", "contact": "c-1"}, false, []string{"amount", "contact", "name"}},
+			{"bad choice and date", map[string]interface{}{"stage": []interface{}{"lost"}, "close_on": "19/10/2026"}, false, []string{"close_on", "stage"}},
+			{"too many choices", map[string]interface{}{"stage": []interface{}{"open", "won"}}, false, []string{"stage"}},
+			{"legacy values", map[string]interface{}{"nickname": "Acme", "amount": "10", "contact": "c-1", "close_on": 1760832000000.0}, true, nil},
+			{"lenient bad choice", map[string]interface{}{"stage": "lost"}, true, []string{"stage"}},
+			{"computed value", map[string]interface{}{"weighted": 5.0}, false, []string{"weighted"}},
+			{"lenient computed value", map[string]interface{}{"weighted": 5.0}, true, []string{"weighted"}},
+			{"empty computed value", map[string]interface{}{"weighted": nil}, false, nil},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the payload has the %s", c.name)
+			{
+				verrs := e.ValidateValues(c.values, c.lenient)
+				if len(verrs) != len(c.keys) {
+					t.Fatalf("\t%s should report %v. got %+v", tests.Failed, c.keys, verrs)
+				}
+				for i, ve := range verrs {
+					if ve.Key != c.keys[i] {
+						t.Fatalf("\t%s should report %v. got %+v", tests.Failed, c.keys, verrs)
+					}
+				}
+				t.Logf("\t%s should report %v", tests.Success, c.keys)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTooMany is used when the batch has more than the MaxBatch events.
+	ErrTooMany = errors.New("Too many events in the batch")
+)
+
+// maxLine is the longest line of the NDJSON body.
+const maxLine = 1 << 20
+
+// Decode reads the events of the body. The NDJSON body has an event on every line, while the JSON body is
+// either the event, the array of the events or the object with the events under the events.
+func Decode(r io.Reader, contentType string) ([]NewEvent, error) {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt == "application/x-ndjson" || mt == "application/ndjson" {
+		return decodeLines(r)
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading events")
+	}
+	body = bytes.TrimSpace(body)
+
+	var batch []NewEvent
+	switch {
+	case len(body) == 0:
+	case body[0] == '[':
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, errors.Wrap(ErrInvalidEvent, err.Error())
+		}
+	default:
+		var wrapped struct {
+			Events *[]NewEvent `json:"events"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, errors.Wrap(ErrInvalidEvent, err.Error())
+		}
+		if wrapped.Events != nil {
+			batch = *wrapped.Events
+			break
+		}
+		var ne NewEvent
+		if err := json.Unmarshal(body, &ne); err != nil {
+			return nil, errors.Wrap(ErrInvalidEvent, err.Error())
+		}
+		batch = []NewEvent{ne}
+	}
+	return checked(batch)
+}
+
+func decodeLines(r io.Reader) ([]NewEvent, error) {
+	batch := make([]NewEvent, 0)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLine)
+	for line := 1; sc.Scan(); line++ {
+		b := bytes.TrimSpace(sc.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+		if len(batch) == MaxBatch {
+			return nil, ErrTooMany
+		}
+		var ne NewEvent
+		if err := json.Unmarshal(b, &ne); err != nil {
+			return nil, errors.Wrapf(ErrInvalidEvent, "line %d: %s", line, err)
+		}
+		batch = append(batch, ne)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(ErrInvalidEvent, err.Error())
+	}
+	return checked(batch)
+}
+
+func checked(batch []NewEvent) ([]NewEvent, error) {
+	if len(batch) == 0 {
+		return nil, errors.Wrap(ErrInvalidEvent, "no events")
+	}
+	if len(batch) > MaxBatch {
+		return nil, ErrTooMany
+	}
+	return batch, nil
+}
@@ -2,196 +2,301 @@ package event
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 	"gitlab.com/vjsideprojects/relay/internal/entity"
 	"gitlab.com/vjsideprojects/relay/internal/item"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
 	"gitlab.com/vjsideprojects/relay/internal/timeseries"
 	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
 )
 
-type TSData struct {
-	UpdateExisting bool
-	NewData        *timeseries.Timeseries
-	OldData        *timeseries.Timeseries
+// DedupWindow is how long the ids of the events are kept to skip the events sent again.
+const DedupWindow = 30 * 24 * time.Hour
+
+// Ingest takes in the batch of the events of the account in order. The entity of every block is resolved once
+// for the batch, the events sent before with their id are skipped and the events not valid are rejected without
+// failing the rest. The items created are returned for the worker to be told of them. On the error the result
+// tells the events taken in so far, the ones left can be sent again with their ids.
+func Ingest(ctx context.Context, db *sqlx.DB, accountID string, batch []NewEvent, now time.Time) (Result, []item.Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.event.Ingest")
+	defer span.End()
+
+	res := Result{Rejected: make([]Rejection, 0)}
+	items := make([]item.Item, 0)
+	entities := make(map[string]entity.Entity)
+	for i, ne := range batch {
+		e, ok := entities[ne.Block]
+		if !ok {
+			var err error
+			e, err = entity.RetrieveByName(ctx, accountID, ne.Block, db)
+			if err == entity.ErrEntityNotFoundByName {
+				res.Rejected = append(res.Rejected, Rejection{Index: i, ID: ne.ID, Error: fmt.Sprintf("block %q not found", ne.Block)})
+				continue
+			}
+			if err != nil {
+				return res, items, err
+			}
+			entities[ne.Block] = e
+		}
+
+		values, err := Validate(e, ne)
+		if err != nil {
+			res.Rejected = append(res.Rejected, Rejection{Index: i, ID: ne.ID, Error: err.Error()})
+			continue
+		}
+		at := ne.at(now)
+		if at.After(now.Add(MaxSkew)) {
+			res.Rejected = append(res.Rejected, Rejection{Index: i, ID: ne.ID, Error: "timestamp is in the future"})
+			continue
+		}
+
+		claimed, err := claim(ctx, db, accountID, ne.ID, now)
+		if err != nil {
+			return res, items, err
+		}
+		if !claimed {
+			res.Duplicates++
+			continue
+		}
+		it, err := take(ctx, db, e, ne, values, at)
+		if err != nil {
+			release(ctx, db, accountID, ne.ID)
+			return res, items, err
+		}
+		if it != nil {
+			items = append(items, *it)
+		}
+		res.Accepted++
+	}
+	return res, items, nil
 }
 
-func Process(ctx context.Context, accountID string, ne NewEvent, log *log.Logger, db *sqlx.DB) (item.Item, error) {
-	e, err := entity.RetrieveByName(ctx, accountID, ne.Block, db)
-	if err != nil {
-		log.Println("processEvent : errored : save event : retrive entity")
-		return item.Item{}, err
+// PruneReceipts deletes the ids of the events received before the time, after which the events sent again
+// are taken in as new.
+func PruneReceipts(ctx context.Context, db *sqlx.DB, before time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.event.PruneReceipts")
+	defer span.End()
+
+	const q = `DELETE FROM event_receipts WHERE received_at < $1`
+	if _, err := db.ExecContext(ctx, q, before.UTC()); err != nil {
+		return errors.Wrap(err, "deleting event receipts")
+	}
+	return nil
+}
+
+// take keeps the event as the item, or rolls it into the timeseries when the entity rolls its fields up. The
+// time is when the event happened.
+func take(ctx context.Context, db *sqlx.DB, e entity.Entity, ne NewEvent, values map[string]interface{}, at time.Time) (*item.Item, error) {
+	fields := e.EasyFields()
+	if rollup := rollupOf(fields); rollup != "" {
+		return nil, rollupEvent(ctx, db, e, fields, rollup, ne, values, at)
 	}
 
 	var name *string
-	if ne.Properties["name"] != nil {
-		namePtr := ne.Properties["name"].(string)
-		name = &namePtr
+	if n, ok := ne.Properties["name"].(string); ok {
+		name = &n
 	}
-
-	fields := e.KeyMap(ne.Properties)
 	//adding identifier as one of the field inside the item
-	fields["identifier"] = ne.Identifier
+	values["identifier"] = ne.Identifier
 
 	userID := user.UUID_SYSTEM_USER
 	ni := item.NewItem{
 		ID:        uuid.New().String(),
 		Name:      name,
-		AccountID: accountID,
+		AccountID: e.AccountID,
 		EntityID:  e.ID,
 		UserID:    &userID,
-		Fields:    fields,
+		Fields:    values,
 		Source:    nil,
 	}
+	it, err := item.Create(ctx, db, ni, at)
+	if err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
+
+// rollupEvent folds the event into the latest timeseries of its identifier when the rollup says so, otherwise
+// the event starts the new timeseries. The fold adds the sums in the database, so the events of the same
+// period taken in at once are all counted.
+func rollupEvent(ctx context.Context, db *sqlx.DB, e entity.Entity, fields []entity.Field, rollup string, ne NewEvent, values map[string]interface{}, at time.Time) error {
+	var identifier *string
+	if ne.Identifier != "" {
+		identifier = &ne.Identifier
+	}
+	data, err := timeseries.RetriveLatest(ctx, e.AccountID, e.ID, identifier, db)
+	if err != nil {
+		return err
+	}
+	var latest *timeseries.Timeseries
+	if len(data) > 0 {
+		latest = &data[0]
+	}
+
+	keyed, into := roll(fields, rollup, latest, values, at)
+	if into {
+		return timeseries.FoldInto(ctx, db, *latest, fold(fields, rollup, latest, values, keyed), at)
+	}
+
+	named := make(map[string]interface{}, len(keyed))
+	for _, f := range fields {
+		if v, ok := keyed[f.Key]; ok {
+			named[f.Name] = v
+		}
+	}
+	nt := timeseries.NewTimeseries{
+		ID:          uuid.New().String(),
+		AccountID:   e.AccountID,
+		EntityID:    e.ID,
+		Type:        timeseries.TypeUnknown,
+		Identifier:  identifier,
+		Event:       tsEvent(named),
+		Description: tsDesc(named),
+		Count:       tsCount(named),
+		Tags:        tsTags(named),
+		Fields:      keyed,
+	}
+	_, err = timeseries.Create(ctx, db, nt, at)
+	return err
+}
+
+// fold is the change the event rolled into the latest timeseries makes. The numbers of the fields with the
+// calc are added to the stored ones, the other values of the event are set to what the roll made of them.
+func fold(fields []entity.Field, rollup string, latest *timeseries.Timeseries, values, keyed map[string]interface{}) timeseries.Fold {
+	// the count stays as it is unless the event has it.
+	fo := timeseries.Fold{Set: make(map[string]interface{}), Add: make(map[string]float64), AddCount: true}
+	prev := latest.Fields()
+	for _, f := range fields {
+		nv, ok := values[f.Key]
+		if !ok {
+			continue
+		}
+		n, isNumber := nv.(float64)
+		_, prevNumber := prev[f.Key].(float64)
+		summed := rollup != entity.MetaRollUpChangeOver && f.Meta[entity.MetaKeyCalc] != "" && isNumber && (prevNumber || prev[f.Key] == nil)
+		if summed {
+			fo.Add[f.Key] = n
+		} else {
+			fo.Set[f.Key] = keyed[f.Key]
+		}
+		if f.Name == "count" {
+			fo.Count, fo.AddCount = int(n), summed
+			if !summed {
+				fo.Count = tsCount(map[string]interface{}{"count": keyed[f.Key]})
+			}
+		}
+	}
+	return fo
+}
+
+// rollupOf is the rollup of the entity, the one of its first field rolled up such as the count. The entities
+// without one keep their events as the items.
+func rollupOf(fields []entity.Field) string {
+	for _, f := range fields {
+		if _, ok := f.Meta[entity.MetaKeyRollUp]; ok {
+			return f.RollUp()
+		}
+	}
+	return ""
+}
+
+// roll folds the values into the latest timeseries when the rollup says so, telling whether it did. The
+// hourly, daily and minute rollups fold the events of the same period of the latest one, while the change
+// over folds the events until the values change. The fields with the calc combine the latest value with the
+// new one, the others take the new value.
+func roll(fields []entity.Field, rollup string, latest *timeseries.Timeseries, values map[string]interface{}, now time.Time) (map[string]interface{}, bool) {
+	if latest == nil {
+		return values, false
+	}
+	end := latest.EndTime.UTC()
+	now = now.UTC()
+	prev := latest.Fields()
+
+	var into bool
+	switch rollup {
+	case entity.MetaRollUpAlways:
+		into = true
+	case entity.MetaRollUpHourly:
+		into = hourEqual(end, now)
+	case entity.MetaRollUpDaily:
+		into = dateEqual(end, now)
+	case entity.MetaRollUpMinute:
+		into = minuteEqual(end, now)
+	case entity.MetaRollUpChangeOver:
+		into = true
+		for k, v := range values {
+			if fmt.Sprint(prev[k]) != fmt.Sprint(v) {
+				into = false
+			}
+		}
+		if into {
+			// the same values only extend the end of the latest one
+			return prev, true
+		}
+	}
+	if !into {
+		return values, false
+	}
+
+	keyed := latest.Fields()
+	for k, v := range values {
+		keyed[k] = v
+	}
+	for _, f := range fields {
+		nv, ok := values[f.Key]
+		if !ok || f.Meta[entity.MetaKeyCalc] == "" || reflect.TypeOf(prev[f.Key]) != reflect.TypeOf(nv) {
+			continue
+		}
+		keyed[f.Key] = f.CalcFunc().Calc(prev[f.Key], nv)
+	}
+	return keyed, true
+}
+
+// claim records the id of the event, telling false when it was received before. The events without the id
+// are always taken in.
+func claim(ctx context.Context, db *sqlx.DB, accountID, eventID string, now time.Time) (bool, error) {
+	if eventID == "" {
+		return true, nil
+	}
+	const q = `INSERT INTO event_receipts (account_id, event_id, received_at) VALUES ($1, $2, $3)
+		ON CONFLICT (account_id, event_id) DO NOTHING`
+	res, err := db.ExecContext(ctx, q, accountID, eventID, now.UTC())
+	if err != nil {
+		return false, errors.Wrap(err, "inserting event receipt")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "inserting event receipt")
+	}
+	return n == 1, nil
+}
 
-	it, err := item.Create(ctx, db, ni, time.Now())
-	return it, err
-}
-
-// func Process(ctx context.Context, accountID string, ne NewEvent, log *log.Logger, db *sqlx.DB) (TSData, error) {
-// 	tsData := TSData{}
-// 	now := time.Now()
-// 	//actual entity : page_view, events, errors, sign_ups, subscriptions
-// 	e, err := entity.RetrieveByName(ctx, accountID, ne.Block, db)
-// 	if err != nil {
-// 		log.Println("processEvent : errored : save event : retrive entity")
-// 		return tsData, err
-// 	}
-
-// 	var iMap map[string]interface{}
-// 	inrec, _ := json.Marshal(ne)
-// 	json.Unmarshal(inrec, &iMap)
-
-// 	data, err := timeseries.RetriveLatest(ctx, accountID, e.ID, tsIdentifier(ne.Tags), db)
-// 	if err != nil {
-// 		log.Println("processEvent : errored : save event : retrive items")
-// 		return tsData, err
-// 	}
-
-// 	oldTS := timeseries.Timeseries{}
-// 	if len(data) > 0 {
-// 		oldTS = data[0]
-// 	}
-
-// 	tsFields := oldTS.Fields()
-// 	tsNamedFields := make(map[string]interface{}, 0)
-// 	namedFieldsMap := e.NameMapWrapper()
-// 	for name, v := range body {
-// 		if f, ok := namedFieldsMap[name]; ok {
-// 			if f.Who == entity.WhoCounter {
-// 				rollUp(f)
-// 				tsFields[f.Key] = v
-// 				tsNamedFields[f.Name] = v
-// 			}
-// 		}
-// 	}
-
-// 	if tsData.UpdateExisting && oldTS.ID != "" {
-// 		tsData.OldData = &oldTS
-// 		newTS, err := updateTimeseries(ctx, db, oldTS, tsFields, tsNamedFields, now)
-// 		if err != nil {
-// 			log.Println("processEvent : errored : save event")
-// 			return tsData, err
-// 		}
-// 		tsData.NewData = newTS
-// 		log.Println("processEvent : completed : save event")
-
-// 		return tsData, nil
-// 	} else {
-// 		tsData.NewData, err = createTimeseries(ctx, db, accountID, e.ID, tsFields, tsNamedFields, now)
-// 		if err != nil {
-// 			log.Println("processEvent : errored : save event")
-// 			return tsData, err
-// 		}
-// 		log.Println("processEvent : completed : save event")
-// 		return tsData, nil
-// 	}
-
-// }
-
-// func rollUp(f entity.Field, tsData *TSData) {
-// 	switch f.RollUp() {
-// 	case entity.MetaRollUpAlways:
-// 		tsData.UpdateExisting = true
-// 		v = f.CalcFunc().Calc(tsFields[f.Key], v)
-// 	case entity.MetaRollUpNever:
-// 		tsData.UpdateExisting = false
-// 	case entity.MetaRollUpHourly:
-// 		if oldTS.ID != "" && hourEqual(oldTS.EndTime, now) {
-// 			tsData.UpdateExisting = true
-// 			v = f.CalcFunc().Calc(tsFields[f.Key], v)
-// 		}
-// 	case entity.MetaRollUpDaily:
-// 		if oldTS.ID != "" && dateEqual(oldTS.EndTime, now) {
-// 			tsData.UpdateExisting = true
-// 			v = f.CalcFunc().Calc(tsFields[f.Key], v)
-// 		}
-// 	case entity.MetaRollUpMinute:
-// 		if oldTS.ID != "" && minuteEqual(oldTS.EndTime, now) {
-// 			tsData.UpdateExisting = true
-// 			v = f.CalcFunc().Calc(tsFields[f.Key], v)
-// 		}
-// 	case entity.MetaRollUpChangeOver:
-// 		if oldTS.ID != "" && oldTS.Count != v {
-// 			tsData.UpdateExisting = true
-// 		}
-// 	}
-// }
-
-// func createTimeseries(ctx context.Context, db *sqlx.DB, accountID, entityID string, keyedFields, namedFields map[string]interface{}, now time.Time) (*timeseries.Timeseries, error) {
-// 	nt := timeseries.NewTimeseries{
-// 		ID:          uuid.New().String(),
-// 		AccountID:   accountID,
-// 		EntityID:    entityID,
-// 		Type:        timeseries.TypeUnknown,
-// 		Event:       tsEvent(namedFields),
-// 		Description: tsDesc(namedFields),
-// 		Count:       tsCount(namedFields),
-// 		Tags:        tsTags(namedFields),
-// 		Identifier:  tsIdentifier(namedFields),
-// 		Fields:      keyedFields,
-// 	}
-// 	t, err := timeseries.Create(ctx, db, nt, now)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return &t, nil
-// }
-
-// func updateTimeseries(ctx context.Context, db *sqlx.DB, oldTS timeseries.Timeseries, keyedFields, namedFields map[string]interface{}, now time.Time) (*timeseries.Timeseries, error) {
-// 	fieldsBytes, err := json.Marshal(keyedFields)
-// 	if err != nil {
-// 		return nil, errors.Wrap(err, "encode fields to bytes")
-// 	}
-
-// 	oldTS.Event = tsEvent(namedFields)
-// 	oldTS.Description = tsDesc(namedFields)
-// 	oldTS.Count = tsCount(namedFields)
-// 	oldTS.Tags = tsTags(namedFields)
-// 	oldTS.Identifier = tsIdentifier(namedFields)
-// 	oldTS.Fieldsb = string(fieldsBytes)
-
-// 	t, err := timeseries.Update(ctx, db, oldTS, now)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	return t, nil
-// }
+// release forgets the id of the event not taken in, so the event sent again is.
+func release(ctx context.Context, db *sqlx.DB, accountID, eventID string) {
+	if eventID == "" {
+		return
+	}
+	const q = `DELETE FROM event_receipts WHERE account_id = $1 AND event_id = $2`
+	db.ExecContext(ctx, q, accountID, eventID)
+}
 
 func tsEvent(namedFields map[string]interface{}) string {
-	if val, ok := namedFields["event"]; ok {
-		return val.(string)
+	if val, ok := namedFields["event"].(string); ok {
+		return val
 	}
 	return ""
 }
 
 func tsDesc(namedFields map[string]interface{}) string {
-	if val, ok := namedFields["description"]; ok {
-		return val.(string)
+	if val, ok := namedFields["description"].(string); ok {
+		return val
 	}
 	return ""
 }
@@ -202,7 +307,7 @@ func tsCount(namedFields map[string]interface{}) int {
 		if !ok {
 			_, ok = val.(float64)
 			if !ok {
-				return util.ConvertStrToInt(val.(string))
+				return util.ConvertStrToInt(fmt.Sprint(val))
 			} else {
 				return int(val.(float64))
 			}
@@ -214,8 +319,8 @@ func tsCount(namedFields map[string]interface{}) int {
 }
 
 func tsTags(namedFields map[string]interface{}) []string {
-	if val, ok := namedFields["tags"]; ok {
-		return util.ConvertSliceTypeRev(val.([]interface{}))
+	if val, ok := namedFields["tags"].([]interface{}); ok {
+		return util.ConvertSliceTypeRev(val)
 	}
 	return []string{}
 }
@@ -0,0 +1,174 @@
+package event
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/timeseries"
+)
+
+var visitFields = []entity.Field{
+	{Key: "uuid-00-event", Name: "event", DataType: entity.TypeString, Meta: map[string]string{entity.MetaKeyRequired: "true"}},
+	{Key: "uuid-00-count", Name: "count", DataType: entity.TypeNumber, Meta: map[string]string{entity.MetaKeyCalc: entity.MetaCalcSum, entity.MetaKeyRollUp: entity.MetaRollUpHourly}},
+	{Key: "uuid-00-tags", Name: "tags", DataType: entity.TypeList},
+	{Key: "uuid-00-seen", Name: "seen", DataType: entity.TypeDateTime},
+}
+
+func TestDecode(t *testing.T) {
+	t.Log("Given the need to read the batches of the events")
+	{
+		tt := []struct {
+			body        string
+			contentType string
+			want        int
+			about       string
+		}{
+			{`{"module":"visits","identifier":"a"}`, "application/json", 1, "read the single event"},
+			{`[{"module":"visits"},{"module":"visits"}]`, "application/json", 2, "read the array of the events"},
+			{`{"events":[{"module":"visits"},{"module":"visits"},{"module":"visits"}]}`, "application/json; charset=utf-8", 3, "read the events wrapped in the object"},
+			{"{\"module\":\"visits\"}\n\n{\"module\":\"visits\"}\n", "application/x-ndjson", 2, "read the event on every line skipping the empty ones"},
+		}
+		for _, tc := range tt {
+			batch, err := Decode(strings.NewReader(tc.body), tc.contentType)
+			if err != nil || len(batch) != tc.want {
+				t.Fatalf("\t%s should %s : got %d events %v", tests.Failed, tc.about, len(batch), err)
+			}
+			t.Logf("\t%s should %s", tests.Success, tc.about)
+		}
+
+		lines := strings.Repeat("{\"module\":\"visits\"}\n", MaxBatch+1)
+		if _, err := Decode(strings.NewReader(lines), "application/x-ndjson"); err != ErrTooMany {
+			t.Fatalf("\t%s should refuse more than the MaxBatch events : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should refuse more than the MaxBatch events", tests.Success)
+
+		if _, err := Decode(strings.NewReader("{\"module\":\"visits\"}\nnot json\n"), "application/x-ndjson"); errors.Cause(err) != ErrInvalidEvent || !strings.Contains(err.Error(), "line 2") {
+			t.Fatalf("\t%s should tell the line not valid : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should tell the line not valid", tests.Success)
+
+		if _, err := Decode(strings.NewReader("[]"), "application/json"); errors.Cause(err) != ErrInvalidEvent {
+			t.Fatalf("\t%s should refuse the empty batch : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should refuse the empty batch", tests.Success)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fieldsb, err := json.Marshal(visitFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := entity.Entity{ID: "visits", Fieldsb: string(fieldsb)}
+
+	t.Log("Given the need to check the events against the fields of their block")
+	{
+		values, err := Validate(e, NewEvent{Properties: map[string]interface{}{"event": "signup", "count": "2", "tags": "new", "seen": "2024-03-01", "other": 1}})
+		if err != nil {
+			t.Fatalf("\t%s should accept the event : %s", tests.Failed, err)
+		}
+		if values["uuid-00-count"] != 2.0 || len(values["uuid-00-tags"].([]interface{})) != 1 || len(values) != 4 {
+			t.Fatalf("\t%s should conform the values and leave out the unknown properties : %v", tests.Failed, values)
+		}
+		t.Logf("\t%s should conform the values and leave out the unknown properties", tests.Success)
+
+		_, err = Validate(e, NewEvent{Properties: map[string]interface{}{"count": "many", "seen": "yesterday"}})
+		if errors.Cause(err) != ErrInvalidEvent {
+			t.Fatalf("\t%s should reject the event : %v", tests.Failed, err)
+		}
+		for _, want := range []string{"event is required", "count is not a number", "seen is not a time"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("\t%s should tell %q : %s", tests.Failed, want, err)
+			}
+		}
+		t.Logf("\t%s should tell every problem of the event", tests.Success)
+	}
+}
+
+func TestRoll(t *testing.T) {
+	end := time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC)
+	latest := &timeseries.Timeseries{EndTime: end, Fieldsb: `{"uuid-00-event":"visit","uuid-00-count":3}`}
+	values := map[string]interface{}{"uuid-00-event": "visit", "uuid-00-count": 2.0}
+
+	t.Log("Given the need to roll the events up into the timeseries")
+	{
+		if _, into := roll(visitFields, entity.MetaRollUpHourly, nil, values, end); into {
+			t.Fatalf("\t%s should start the timeseries without the latest one", tests.Failed)
+		}
+		t.Logf("\t%s should start the timeseries without the latest one", tests.Success)
+
+		keyed, into := roll(visitFields, entity.MetaRollUpHourly, latest, values, end.Add(30*time.Minute))
+		if !into || keyed["uuid-00-count"] != 5.0 {
+			t.Fatalf("\t%s should sum the counts in the same hour : %v %v", tests.Failed, into, keyed)
+		}
+		t.Logf("\t%s should sum the counts in the same hour", tests.Success)
+
+		if _, into := roll(visitFields, entity.MetaRollUpHourly, latest, values, end.Add(time.Hour)); into {
+			t.Fatalf("\t%s should start the timeseries in the next hour", tests.Failed)
+		}
+		t.Logf("\t%s should start the timeseries in the next hour", tests.Success)
+
+		if _, into := roll(visitFields, entity.MetaRollUpDaily, latest, values, end.Add(time.Hour)); !into {
+			t.Fatalf("\t%s should roll the day up", tests.Failed)
+		}
+		t.Logf("\t%s should roll the day up", tests.Success)
+
+		same := map[string]interface{}{"uuid-00-event": "visit", "uuid-00-count": 3.0}
+		keyed, into = roll(visitFields, entity.MetaRollUpChangeOver, latest, same, end.Add(48*time.Hour))
+		if !into || keyed["uuid-00-count"] != 3.0 {
+			t.Fatalf("\t%s should extend the timeseries until the values change : %v %v", tests.Failed, into, keyed)
+		}
+		if _, into := roll(visitFields, entity.MetaRollUpChangeOver, latest, values, end); into {
+			t.Fatalf("\t%s should start the timeseries on the change", tests.Failed)
+		}
+		t.Logf("\t%s should extend the timeseries until the values change", tests.Success)
+	}
+}
+
+func TestFold(t *testing.T) {
+	end := time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC)
+	latest := &timeseries.Timeseries{EndTime: end, Fieldsb: `{"uuid-00-event":"visit","uuid-00-count":3}`}
+	values := map[string]interface{}{"uuid-00-event": "visit", "uuid-00-count": 2.0}
+
+	t.Log("Given the need to fold the events into the timeseries without losing the ones folded at the same time")
+	{
+		keyed, _ := roll(visitFields, entity.MetaRollUpHourly, latest, values, end)
+		fo := fold(visitFields, entity.MetaRollUpHourly, latest, values, keyed)
+		if fo.Add["uuid-00-count"] != 2.0 || !fo.AddCount || fo.Count != 2 || fo.Set["uuid-00-event"] != "visit" {
+			t.Fatalf("\t%s should add the count of the event and set the rest : %+v", tests.Failed, fo)
+		}
+		if _, ok := fo.Set["uuid-00-count"]; ok {
+			t.Fatalf("\t%s should not set the count it adds : %+v", tests.Failed, fo)
+		}
+		t.Logf("\t%s should add the count of the event and set the rest", tests.Success)
+
+		same := map[string]interface{}{"uuid-00-event": "visit", "uuid-00-count": 3.0}
+		keyed, _ = roll(visitFields, entity.MetaRollUpChangeOver, latest, same, end)
+		fo = fold(visitFields, entity.MetaRollUpChangeOver, latest, same, keyed)
+		if len(fo.Add) != 0 || fo.AddCount || fo.Count != 3 {
+			t.Fatalf("\t%s should keep the count when the values did not change : %+v", tests.Failed, fo)
+		}
+		t.Logf("\t%s should keep the count when the values did not change", tests.Success)
+	}
+}
+
+func TestAt(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 20, 0, 0, time.UTC)
+	happened := time.Date(2024, 3, 1, 8, 0, 0, 0, time.FixedZone("", 3600))
+
+	t.Log("Given the need to bucket the events by when they happened")
+	{
+		if got := (NewEvent{}).at(now); !got.Equal(now) {
+			t.Fatalf("\t%s should take the time received without the timestamp : %v", tests.Failed, got)
+		}
+		if got := (NewEvent{Timestamp: &happened}).at(now); !got.Equal(happened) || got.Location() != time.UTC {
+			t.Fatalf("\t%s should take the timestamp in UTC : %v", tests.Failed, got)
+		}
+		t.Logf("\t%s should take the timestamp in UTC and the time received without it", tests.Success)
+	}
+}
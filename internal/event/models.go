@@ -1,7 +1,41 @@
 package event
 
+import "time"
+
+// MaxBatch is the most events taken in one request.
+const MaxBatch = 500
+
+// MaxSkew is how far ahead of the server the clock of the client could be. The events with the time
+// beyond it are rejected.
+const MaxSkew = 5 * time.Minute
+
 type NewEvent struct {
+	ID         string                 `json:"id"` // the id the client gives, the events sent again with it are skipped
 	Block      string                 `json:"block"`
 	Identifier string                 `json:"identifier"`
 	Properties map[string]interface{} `json:"properties"`
+	Timestamp  *time.Time             `json:"timestamp"` // when the event happened, the time it is received when not set
+}
+
+// at is the time the event is bucketed by.
+func (ne NewEvent) at(now time.Time) time.Time {
+	if ne.Timestamp == nil || ne.Timestamp.IsZero() {
+		return now
+	}
+	return ne.Timestamp.UTC()
+}
+
+// Result is the outcome of the batch. The events are taken in as far as they can be, the rejected ones
+// telling why by their place in the batch.
+type Result struct {
+	Accepted   int         `json:"accepted"`
+	Duplicates int         `json:"duplicates"`
+	Rejected   []Rejection `json:"rejected"`
+}
+
+// Rejection is the event of the batch not taken in.
+type Rejection struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
 }
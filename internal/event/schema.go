@@ -0,0 +1,87 @@
+package event
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+)
+
+var (
+	// ErrInvalidEvent is used when the event does not match the fields of its block.
+	ErrInvalidEvent = errors.New("Event is not valid")
+)
+
+// Validate checks the properties of the event against the fields of the entity of its block and returns the
+// values keyed by the fields. The required fields must be there, the numbers must be numbers, the dates the
+// RFC3339 times or the dates and the lists the arrays or the single values. The properties not known to
+// the entity are left out.
+func Validate(e entity.Entity, ne NewEvent) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	problems := make([]string, 0)
+	for _, f := range e.EasyFields() {
+		v, ok := ne.Properties[f.Name]
+		if !ok || v == nil {
+			if f.IsRequired() {
+				problems = append(problems, fmt.Sprintf("%s is required", f.Name))
+			}
+			continue
+		}
+		cv, err := conform(f, v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s", f.Name, err))
+			continue
+		}
+		values[f.Key] = cv
+	}
+	if len(problems) > 0 {
+		return nil, errors.Wrap(ErrInvalidEvent, strings.Join(problems, ", "))
+	}
+	return values, nil
+}
+
+// conform makes the value the type of the field, or tells why it cannot be.
+func conform(f entity.Field, v interface{}) (interface{}, error) {
+	switch f.DataType {
+	case entity.TypeNumber:
+		switch n := v.(type) {
+		case float64:
+			return n, nil
+		case int:
+			return float64(n), nil
+		case string:
+			if fl, err := strconv.ParseFloat(n, 64); err == nil {
+				return fl, nil
+			}
+		}
+		return nil, errors.New("is not a number")
+	case entity.TypeDateTime, entity.TypeDate:
+		if s, ok := v.(string); ok {
+			if _, err := time.Parse(time.RFC3339, s); err == nil {
+				return s, nil
+			}
+			if _, err := time.Parse("2006-01-02", s); err == nil {
+				return s, nil
+			}
+		}
+		return nil, errors.New("is not a time")
+	case entity.TypeList, entity.TypeReference:
+		switch l := v.(type) {
+		case []interface{}:
+			return l, nil
+		case map[string]interface{}:
+			return nil, errors.New("is not a list")
+		default:
+			return []interface{}{l}, nil
+		}
+	default:
+		switch s := v.(type) {
+		case string, float64, bool:
+			return s, nil
+		}
+		return nil, errors.New("is not a value")
+	}
+}
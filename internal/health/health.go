@@ -0,0 +1,486 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
+	"gitlab.com/vjsideprojects/relay/internal/timeseries"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when a specific model is requested but does not exist.
+	ErrNotFound = errors.New("Health model not found")
+
+	// ErrInvalidModel is used when the components or the thresholds of the model do not make sense.
+	ErrInvalidModel = errors.New("Health model is not valid")
+)
+
+// CreateModel inserts the health model of the entity. The keys of its score and health fields are picked here,
+// EnsureFields adds them to the entity.
+func CreateModel(ctx context.Context, db *sqlx.DB, nm NewModel, now time.Time) (Model, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.CreateModel")
+	defer span.End()
+
+	if nm.ID == "" {
+		nm.ID = uuid.New().String()
+	}
+	if nm.HealthyAt == 0 && nm.CriticalBelow == 0 {
+		nm.HealthyAt, nm.CriticalBelow = DefaultHealthyAt, DefaultCriticalBelow
+	}
+	if err := validate(&nm); err != nil {
+		return Model{}, err
+	}
+
+	componentsBytes, err := json.Marshal(nm.Components)
+	if err != nil {
+		return Model{}, errors.Wrap(err, "encode components to bytes")
+	}
+
+	m := Model{
+		ID:            nm.ID,
+		AccountID:     nm.AccountID,
+		EntityID:      nm.EntityID,
+		Name:          nm.Name,
+		Componentsb:   string(componentsBytes),
+		HealthyAt:     nm.HealthyAt,
+		CriticalBelow: nm.CriticalBelow,
+		ScoreKey:      uuid.New().String(),
+		BucketKey:     uuid.New().String(),
+		CreatedAt:     now.UTC(),
+		UpdatedAt:     now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO health_models
+		(model_id, account_id, entity_id, name, componentsb, healthy_at, critical_below, score_key, bucket_key, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+
+	_, err = db.ExecContext(
+		ctx, q,
+		m.ID, m.AccountID, m.EntityID, m.Name, m.Componentsb, m.HealthyAt, m.CriticalBelow, m.ScoreKey, m.BucketKey,
+		m.CreatedAt, m.UpdatedAt,
+	)
+	if err != nil {
+		return Model{}, errors.Wrap(err, "inserting health model")
+	}
+
+	return m, nil
+}
+
+// ListModels returns the health models of the entity.
+func ListModels(ctx context.Context, db *sqlx.DB, accountID, entityID string) ([]Model, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.ListModels")
+	defer span.End()
+
+	models := []Model{}
+	const q = `SELECT * FROM health_models WHERE account_id = $1 AND entity_id = $2 ORDER BY created_at`
+	if err := db.SelectContext(ctx, &models, q, accountID, entityID); err != nil {
+		return nil, errors.Wrap(err, "selecting health models")
+	}
+	return models, nil
+}
+
+// AccountModels returns the health models of all the entities of the account.
+func AccountModels(ctx context.Context, db *sqlx.DB, accountID string) ([]Model, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.AccountModels")
+	defer span.End()
+
+	models := []Model{}
+	const q = `SELECT * FROM health_models WHERE account_id = $1 ORDER BY created_at`
+	if err := db.SelectContext(ctx, &models, q, accountID); err != nil {
+		return nil, errors.Wrap(err, "selecting health models of the account")
+	}
+	return models, nil
+}
+
+// AllModels returns the health models of all the accounts for the scheduled scoring.
+func AllModels(ctx context.Context, db *sqlx.DB) ([]Model, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.AllModels")
+	defer span.End()
+
+	models := []Model{}
+	const q = `SELECT * FROM health_models ORDER BY account_id, created_at`
+	if err := db.SelectContext(ctx, &models, q); err != nil {
+		return nil, errors.Wrap(err, "selecting all health models")
+	}
+	return models, nil
+}
+
+// RetrieveModel gets the model.
+func RetrieveModel(ctx context.Context, db *sqlx.DB, accountID, modelID string) (Model, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.RetrieveModel")
+	defer span.End()
+
+	var m Model
+	const q = `SELECT * FROM health_models WHERE account_id = $1 AND model_id = $2`
+	if err := db.GetContext(ctx, &m, q, accountID, modelID); err != nil {
+		if err == sql.ErrNoRows {
+			return Model{}, ErrNotFound
+		}
+		return Model{}, errors.Wrapf(err, "selecting health model %q", modelID)
+	}
+	return m, nil
+}
+
+// DeleteModel removes the model along with its history. The fields of the model stay on the entity with
+// their last values.
+func DeleteModel(ctx context.Context, db *sqlx.DB, accountID, modelID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.health.DeleteModel")
+	defer span.End()
+
+	const q = `DELETE FROM health_models WHERE account_id = $1 AND model_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, modelID); err != nil {
+		return errors.Wrapf(err, "deleting health model %s", modelID)
+	}
+	return nil
+}
+
+// EnsureFields adds the score and the health fields of the model to the entity if they do not exist already.
+// Being the fields of the items, the segments, the flows and the charts of the entity use them as they are.
+func EnsureFields(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, e entity.Entity, m Model) error {
+	fields, err := e.Fields()
+	if err != nil {
+		return err
+	}
+	keys := entity.KeyMap(fields)
+	_, hasScore := keys[m.ScoreKey]
+	_, hasBucket := keys[m.BucketKey]
+	if hasScore && hasBucket {
+		return nil
+	}
+
+	suffix := m.ID[:8]
+	if !hasScore {
+		fields = append(fields, entity.Field{
+			Key:         m.ScoreKey,
+			Name:        "health_score_" + suffix,
+			DisplayName: m.Name + " Score",
+			DomType:     entity.DomText,
+			DataType:    entity.TypeNumber,
+			Meta:        map[string]string{entity.MetaKeyReadOnly: "true"},
+		})
+	}
+	if !hasBucket {
+		fields = append(fields, entity.Field{
+			Key:         m.BucketKey,
+			Name:        "health_" + suffix,
+			DisplayName: m.Name,
+			DomType:     entity.DomText,
+			DataType:    entity.TypeString,
+			Meta:        map[string]string{entity.MetaKeyReadOnly: "true"},
+		})
+	}
+
+	input, err := json.Marshal(fields)
+	if err != nil {
+		return errors.Wrap(err, "encode fields to input")
+	}
+	return entity.Update(ctx, db, sdb, e.AccountID, e.ID, string(input), time.Now())
+}
+
+// Compute evaluates the components of the model for the item. The components without anything to go by,
+// such as the field left empty, are left out of the parts.
+func Compute(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, eng engine.Engine, m Model, it item.Item, now time.Time) ([]Part, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.Compute")
+	defer span.End()
+
+	fields := it.Fields()
+	parts := make([]Part, 0)
+	for _, c := range m.Components() {
+		p := Part{Name: c.Name, Kind: c.Kind, Weight: c.Weight}
+		switch c.Kind {
+		case KindRule:
+			held := eng.RunExpEvaluator(ctx, db, sdb, it.AccountID, c.Expression, map[string]interface{}{m.EntityID: it.ID})
+			p.Value = held
+			if held {
+				p.Points = 1
+			}
+		case KindUsage:
+			identifier := it.ID
+			if c.FieldKey != "" {
+				identifier = valueOf(fields[c.FieldKey])
+				if identifier == "" {
+					continue
+				}
+			}
+			days := c.Days
+			if days <= 0 {
+				days = DefaultUsageDays
+			}
+			n, err := timeseries.Total(ctx, it.AccountID, c.EntityID, identifier, now.AddDate(0, 0, -days), db)
+			if err != nil {
+				return nil, err
+			}
+			p.Value = n
+			p.Points = reached(float64(n), c.Target)
+		case KindTickets, KindOverdue:
+			n, err := open(ctx, db, sdb, it.AccountID, c, it.ID, now)
+			if err != nil {
+				return nil, err
+			}
+			p.Value = n
+			p.Points = fewer(float64(n), c.Target)
+		case KindField:
+			v, ok := number(fields[c.FieldKey])
+			if !ok {
+				continue
+			}
+			p.Value = v
+			p.Points = scaled(v, c.Min, c.Max)
+		default:
+			continue
+		}
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// Combine is the weighted average of the points of the parts out of 100. It tells false without the parts.
+func Combine(parts []Part) (int, bool) {
+	var sum, weights float64
+	for _, p := range parts {
+		w := p.Weight
+		if w <= 0 {
+			w = 1
+		}
+		sum += w * p.Points
+		weights += w
+	}
+	if weights == 0 {
+		return 0, false
+	}
+	return int(math.Round(100 * sum / weights)), true
+}
+
+// Bucket is the health of the score by the thresholds of the model.
+func (m Model) Bucket(score int) string {
+	switch {
+	case score >= m.HealthyAt:
+		return BucketHealthy
+	case score < m.CriticalBelow:
+		return BucketCritical
+	}
+	return BucketAtRisk
+}
+
+// Referred returns the items scored by the model the tickets or the tasks of the entity refer, before and
+// after their change. Those items are scored again as their open tickets or overdue tasks could have moved.
+func (m Model) Referred(entityID string, oldFields, newFields map[string]interface{}) []string {
+	seen := make(map[string]bool, 0)
+	ids := make([]string, 0)
+	for _, c := range m.Components() {
+		if (c.Kind != KindTickets && c.Kind != KindOverdue) || c.EntityID != entityID {
+			continue
+		}
+		for _, fields := range []map[string]interface{}{oldFields, newFields} {
+			refs, _ := fields[c.FieldKey].([]interface{})
+			for _, ref := range refs {
+				id := fmt.Sprint(ref)
+				if id != "" && !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// Record keeps the score in the history of the item.
+func Record(ctx context.Context, db *sqlx.DB, m Model, itemID string, score int, parts []Part, now time.Time) (Score, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.Record")
+	defer span.End()
+
+	partsBytes, err := json.Marshal(parts)
+	if err != nil {
+		return Score{}, errors.Wrap(err, "encode parts to bytes")
+	}
+	s := Score{
+		AccountID:  m.AccountID,
+		ModelID:    m.ID,
+		ItemID:     itemID,
+		Score:      score,
+		Bucket:     m.Bucket(score),
+		Partsb:     string(partsBytes),
+		ComputedAt: now.UTC(),
+	}
+
+	const q = `INSERT INTO health_scores (account_id, model_id, item_id, score, bucket, partsb, computed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := db.ExecContext(ctx, q, s.AccountID, s.ModelID, s.ItemID, s.Score, s.Bucket, s.Partsb, s.ComputedAt); err != nil {
+		return Score{}, errors.Wrap(err, "inserting health score")
+	}
+	return s, nil
+}
+
+// History returns the scores of the item, newest first. The empty model id returns the scores of all the models.
+func History(ctx context.Context, db *sqlx.DB, accountID, itemID, modelID string, limit int) ([]Score, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.health.History")
+	defer span.End()
+
+	scores := []Score{}
+	const q = `SELECT * FROM health_scores WHERE account_id = $1 AND item_id = $2 AND ($3 = '' OR model_id::text = $3)
+		ORDER BY computed_at DESC LIMIT $4`
+	if err := db.SelectContext(ctx, &scores, q, accountID, itemID, modelID, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting health scores")
+	}
+	return scores, nil
+}
+
+// open counts the items of the entity of the component referring the item which are not done. For the
+// overdue, only the ones past their due by are counted.
+func open(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID string, c Component, itemID string, now time.Time) (int, error) {
+	e, err := entity.Retrieve(ctx, accountID, c.EntityID, db, sdb)
+	if err != nil {
+		return 0, err
+	}
+	items, err := item.Referring(ctx, accountID, c.EntityID, c.FieldKey, itemID, db)
+	if err != nil {
+		return 0, err
+	}
+
+	statusField := e.WhoField(entity.WhoStatus)
+	doneID := ""
+	if statusField.RefID != "" {
+		doneID, err = entity.DiscoverDoneStatusID(ctx, accountID, statusField.RefID, db, sdb)
+		if err != nil && err != entity.ErrNotFound {
+			return 0, err
+		}
+	}
+	dueByField := e.WhoField(entity.WhoDueBy)
+
+	count := 0
+	for _, it := range items {
+		fields := it.Fields()
+		if doneID != "" && valueOf(fields[statusField.Key]) == doneID {
+			continue
+		}
+		if c.Kind == KindOverdue {
+			due, err := util.ParseTime(valueOf(fields[dueByField.Key]))
+			if err != nil || !due.Before(now) {
+				continue
+			}
+		}
+		count++
+	}
+	return count, nil
+}
+
+func validate(nm *NewModel) error {
+	if nm.CriticalBelow < 0 || nm.HealthyAt > 100 || nm.CriticalBelow > nm.HealthyAt {
+		return errors.Wrap(ErrInvalidModel, "the thresholds must be 0 <= critical below <= healthy at <= 100")
+	}
+	if len(nm.Components) == 0 {
+		return errors.Wrap(ErrInvalidModel, "no components")
+	}
+	for i := range nm.Components {
+		c := &nm.Components[i]
+		if c.Weight < 0 {
+			return errors.Wrapf(ErrInvalidModel, "component %q has the negative weight", c.Name)
+		}
+		if c.Weight == 0 {
+			c.Weight = 1
+		}
+		var missing string
+		switch c.Kind {
+		case KindRule:
+			if c.Expression == "" {
+				missing = "expression"
+			}
+		case KindUsage:
+			if c.EntityID == "" {
+				missing = "entity_id"
+			}
+		case KindTickets, KindOverdue:
+			if c.EntityID == "" || c.FieldKey == "" {
+				missing = "entity_id and field_key"
+			}
+		case KindField:
+			if c.FieldKey == "" {
+				missing = "field_key"
+			}
+		default:
+			return errors.Wrapf(ErrInvalidModel, "component %q has the unknown kind %q", c.Name, c.Kind)
+		}
+		if missing != "" {
+			return errors.Wrapf(ErrInvalidModel, "component %q needs the %s", c.Name, missing)
+		}
+	}
+	return nil
+}
+
+// reached is the share of the target made, all or nothing without the target.
+func reached(n, target float64) float64 {
+	if target <= 0 {
+		if n > 0 {
+			return 1
+		}
+		return 0
+	}
+	return math.Min(n/target, 1)
+}
+
+// fewer takes the points away as the count grows to the target, any count takes them all without the target.
+func fewer(n, target float64) float64 {
+	if target <= 0 {
+		if n > 0 {
+			return 0
+		}
+		return 1
+	}
+	return math.Max(1-n/target, 0)
+}
+
+// scaled places the value between the min and the max, out of 10 without them.
+func scaled(v, min, max float64) float64 {
+	if min == 0 && max == 0 {
+		max = DefaultFieldMax
+	}
+	if max <= min {
+		return 0
+	}
+	return math.Max(math.Min((v-min)/(max-min), 1), 0)
+}
+
+// valueOf is the string value of the field, the first one of the lists.
+func valueOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []interface{}:
+		if len(t) > 0 {
+			return fmt.Sprint(t[0])
+		}
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// number is the value of the number field, which the forms could keep as the string.
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
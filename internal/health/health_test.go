@@ -0,0 +1,89 @@
+package health
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestCombine(t *testing.T) {
+	m := Model{HealthyAt: DefaultHealthyAt, CriticalBelow: DefaultCriticalBelow}
+
+	t.Log("Given the need to score the items by the weighted components")
+	{
+		tt := []struct {
+			parts  []Part
+			score  int
+			bucket string
+			about  string
+		}{
+			{[]Part{{Weight: 2, Points: 1}, {Weight: 1, Points: 0}}, 67, BucketAtRisk, "weigh the points of the components"},
+			{[]Part{{Weight: 1, Points: reached(150, 100)}, {Weight: 1, Points: fewer(0, 3)}}, 100, BucketHealthy, "cap the usage above the target"},
+			{[]Part{{Weight: 1, Points: fewer(3, 3)}, {Weight: 3, Points: scaled(2, 0, 0)}}, 15, BucketCritical, "take the points away at the target and scale the field out of 10"},
+			{[]Part{{Points: 0.7}}, 70, BucketHealthy, "count the part without the weight once"},
+		}
+		for _, tc := range tt {
+			score, ok := Combine(tc.parts)
+			if !ok || score != tc.score || m.Bucket(score) != tc.bucket {
+				t.Fatalf("\t%s should %s : got %d %s want %d %s", tests.Failed, tc.about, score, m.Bucket(score), tc.score, tc.bucket)
+			}
+			t.Logf("\t%s should %s", tests.Success, tc.about)
+		}
+
+		if _, ok := Combine(nil); ok {
+			t.Fatalf("\t%s should not score without the parts", tests.Failed)
+		}
+		t.Logf("\t%s should not score without the parts", tests.Success)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("Given the need to refuse the models not making sense")
+	{
+		nm := NewModel{Name: "health", HealthyAt: 70, CriticalBelow: 40, Components: []Component{{Name: "nps", Kind: KindField, FieldKey: "nps"}}}
+		if err := validate(&nm); err != nil || nm.Components[0].Weight != 1 {
+			t.Fatalf("\t%s should default the weight : %v %+v", tests.Failed, err, nm.Components[0])
+		}
+		t.Logf("\t%s should default the weight", tests.Success)
+
+		tt := []struct {
+			nm    NewModel
+			about string
+		}{
+			{NewModel{HealthyAt: 30, CriticalBelow: 40, Components: nm.Components}, "refuse the critical threshold above the healthy one"},
+			{NewModel{HealthyAt: 70, CriticalBelow: 40}, "refuse the model without the components"},
+			{NewModel{HealthyAt: 70, CriticalBelow: 40, Components: []Component{{Kind: KindOverdue, EntityID: "tasks"}}}, "refuse the overdue without the reference field"},
+			{NewModel{HealthyAt: 70, CriticalBelow: 40, Components: []Component{{Kind: "mood"}}}, "refuse the unknown kind"},
+		}
+		for _, tc := range tt {
+			if err := validate(&tc.nm); errors.Cause(err) != ErrInvalidModel {
+				t.Fatalf("\t%s should %s : %v", tests.Failed, tc.about, err)
+			}
+			t.Logf("\t%s should %s", tests.Success, tc.about)
+		}
+	}
+}
+
+func TestReferred(t *testing.T) {
+	components, _ := json.Marshal([]Component{
+		{Kind: KindOverdue, EntityID: "tasks", FieldKey: "company"},
+		{Kind: KindUsage, EntityID: "visits"},
+	})
+	m := Model{Componentsb: string(components)}
+
+	t.Log("Given the need to score the items the tasks refer again")
+	{
+		ids := m.Referred("tasks", map[string]interface{}{"company": []interface{}{"acme"}}, map[string]interface{}{"company": []interface{}{"acme", "globex"}})
+		if len(ids) != 2 || ids[0] != "acme" || ids[1] != "globex" {
+			t.Fatalf("\t%s should return the companies before and after the change once : %v", tests.Failed, ids)
+		}
+		t.Logf("\t%s should return the companies before and after the change once", tests.Success)
+
+		if ids := m.Referred("visits", nil, map[string]interface{}{"company": []interface{}{"acme"}}); len(ids) != 0 {
+			t.Fatalf("\t%s should not return the items for the usage : %v", tests.Failed, ids)
+		}
+		t.Logf("\t%s should not return the items for the usage", tests.Success)
+	}
+}
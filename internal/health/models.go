@@ -0,0 +1,115 @@
+package health
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Buckets written into the health field of the item. Segments, flows and charts use them like any other value.
+const (
+	BucketHealthy  = "healthy"
+	BucketAtRisk   = "at_risk"
+	BucketCritical = "critical"
+)
+
+// Kinds of the components of the model.
+const (
+	// KindRule gives the full points when the expression holds for the item.
+	KindRule = "rule"
+	// KindUsage counts the events of the item in the timeseries entity over the last days against the target.
+	KindUsage = "usage"
+	// KindTickets counts the open items of the entity referring the item, the fewer the better.
+	KindTickets = "tickets"
+	// KindOverdue counts the open items of the entity referring the item past their due by, the fewer the better.
+	KindOverdue = "overdue"
+	// KindField scales the number in the field of the item between the min and the max, such as the NPS.
+	KindField = "field"
+)
+
+// Defaults used when the model or its components do not say.
+const (
+	DefaultHealthyAt     = 70
+	DefaultCriticalBelow = 40
+	DefaultUsageDays     = 30
+	DefaultFieldMax      = 10
+)
+
+// Model decides how the items of the entity are scored. The score is the weighted average of the points of
+// its components out of 100, bucketed by the thresholds and written into the score and the health fields.
+type Model struct {
+	ID            string    `db:"model_id" json:"id"`
+	AccountID     string    `db:"account_id" json:"account_id"`
+	EntityID      string    `db:"entity_id" json:"entity_id"`
+	Name          string    `db:"name" json:"name"`
+	Componentsb   string    `db:"componentsb" json:"componentsb"`
+	HealthyAt     int       `db:"healthy_at" json:"healthy_at"`
+	CriticalBelow int       `db:"critical_below" json:"critical_below"`
+	ScoreKey      string    `db:"score_key" json:"score_key"`
+	BucketKey     string    `db:"bucket_key" json:"bucket_key"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     int64     `db:"updated_at" json:"updated_at"`
+}
+
+// NewModel contains information needed to create a new model.
+type NewModel struct {
+	ID            string      `json:"id"`
+	AccountID     string      `json:"account_id"`
+	EntityID      string      `json:"entity_id"`
+	Name          string      `json:"name" validate:"required"`
+	Components    []Component `json:"components" validate:"required"`
+	HealthyAt     int         `json:"healthy_at"`
+	CriticalBelow int         `json:"critical_below"`
+}
+
+// Component is the weighted part of the score. The entity and the field it reads depend on the kind.
+type Component struct {
+	Name   string  `json:"name"`
+	Kind   string  `json:"kind"`
+	Weight float64 `json:"weight"`
+	// Expression is the condition of the rule on the item.
+	Expression string `json:"expression,omitempty"`
+	// EntityID is the timeseries entity of the usage or the entity of the tickets or the tasks.
+	EntityID string `json:"entity_id,omitempty"`
+	// FieldKey is the field of the item for the field and the usage, where it holds the identifier of the events.
+	// For the tickets and the overdue it is the reference field of their entity to the item.
+	FieldKey string `json:"field_key,omitempty"`
+	// Days is the window of the usage.
+	Days int `json:"days,omitempty"`
+	// Target is the events of the usage for the full points, or the tickets and the tasks for no points.
+	Target float64 `json:"target,omitempty"`
+	Min    float64 `json:"min,omitempty"`
+	Max    float64 `json:"max,omitempty"`
+}
+
+// Score is the health of the item computed by the model at the time, kept as its history.
+type Score struct {
+	AccountID  string    `db:"account_id" json:"account_id"`
+	ModelID    string    `db:"model_id" json:"model_id"`
+	ItemID     string    `db:"item_id" json:"item_id"`
+	Score      int       `db:"score" json:"score"`
+	Bucket     string    `db:"bucket" json:"bucket"`
+	Partsb     string    `db:"partsb" json:"partsb"`
+	ComputedAt time.Time `db:"computed_at" json:"computed_at"`
+}
+
+// Part is the points of the component out of 1 with the value they came from.
+type Part struct {
+	Name   string      `json:"name"`
+	Kind   string      `json:"kind"`
+	Weight float64     `json:"weight"`
+	Value  interface{} `json:"value"`
+	Points float64     `json:"points"`
+}
+
+// Components returns the components of the model.
+func (m Model) Components() []Component {
+	components := make([]Component, 0)
+	if m.Componentsb == "" {
+		return components
+	}
+	if err := json.Unmarshal([]byte(m.Componentsb), &components); err != nil {
+		log.Printf("***> unexpected error occurred when unmarshalling components for health model: %v error: %v\n", m.ID, err)
+	}
+	return components
+}
@@ -0,0 +1,103 @@
+package calendar
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// ErrLinkNotFound is used when the meeting or the event is not synced yet.
+var ErrLinkNotFound = errors.New("Calendar link not found")
+
+// Link ties the meeting item to its event in the external calendar. The etag is the one of the event and the
+// item updated at is the one of the item when they were last in sync.
+type Link struct {
+	AccountID     string    `db:"account_id"`
+	CalendarID    string    `db:"calendar_id"`
+	EventID       string    `db:"event_id"`
+	UID           string    `db:"uid"`
+	EntityID      string    `db:"entity_id"`
+	ItemID        string    `db:"item_id"`
+	ETag          string    `db:"etag"`
+	ItemUpdatedAt int64     `db:"item_updated_at"`
+	SyncedAt      time.Time `db:"synced_at"`
+}
+
+// SaveLink inserts the link or updates the one of the event.
+func SaveLink(ctx context.Context, db *sqlx.DB, l Link) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.SaveLink")
+	defer span.End()
+
+	const q = `INSERT INTO calendar_links
+		(account_id, calendar_id, event_id, uid, entity_id, item_id, etag, item_updated_at, synced_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (account_id, calendar_id, event_id) DO UPDATE SET
+		uid = $4, entity_id = $5, item_id = $6, etag = $7, item_updated_at = $8, synced_at = $9`
+
+	_, err := db.ExecContext(ctx, q, l.AccountID, l.CalendarID, l.EventID, l.UID, l.EntityID, l.ItemID, l.ETag, l.ItemUpdatedAt, l.SyncedAt.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "saving calendar link of event %q", l.EventID)
+	}
+	return nil
+}
+
+// LinkOfEvent gets the link of the event in the calendar.
+func LinkOfEvent(ctx context.Context, db *sqlx.DB, accountID, calendarID, eventID string) (Link, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.LinkOfEvent")
+	defer span.End()
+
+	var l Link
+	const q = `SELECT * FROM calendar_links WHERE account_id = $1 AND calendar_id = $2 AND event_id = $3`
+	if err := db.GetContext(ctx, &l, q, accountID, calendarID, eventID); err != nil {
+		if err == sql.ErrNoRows {
+			return Link{}, ErrLinkNotFound
+		}
+		return Link{}, errors.Wrapf(err, "selecting calendar link of event %q", eventID)
+	}
+	return l, nil
+}
+
+// LinkOfItem gets the link of the meeting in the calendar.
+func LinkOfItem(ctx context.Context, db *sqlx.DB, accountID, calendarID, itemID string) (Link, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.LinkOfItem")
+	defer span.End()
+
+	var l Link
+	const q = `SELECT * FROM calendar_links WHERE account_id = $1 AND calendar_id = $2 AND item_id = $3 LIMIT 1`
+	if err := db.GetContext(ctx, &l, q, accountID, calendarID, itemID); err != nil {
+		if err == sql.ErrNoRows {
+			return Link{}, ErrLinkNotFound
+		}
+		return Link{}, errors.Wrapf(err, "selecting calendar link of item %q", itemID)
+	}
+	return l, nil
+}
+
+// Links gets the links of the calendar.
+func Links(ctx context.Context, db *sqlx.DB, accountID, calendarID string) ([]Link, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.Links")
+	defer span.End()
+
+	links := []Link{}
+	const q = `SELECT * FROM calendar_links WHERE account_id = $1 AND calendar_id = $2`
+	if err := db.SelectContext(ctx, &links, q, accountID, calendarID); err != nil {
+		return nil, errors.Wrap(err, "selecting calendar links")
+	}
+	return links, nil
+}
+
+// DeleteLink removes the link of the event.
+func DeleteLink(ctx context.Context, db *sqlx.DB, accountID, calendarID, eventID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.DeleteLink")
+	defer span.End()
+
+	const q = `DELETE FROM calendar_links WHERE account_id = $1 AND calendar_id = $2 AND event_id = $3`
+	if _, err := db.ExecContext(ctx, q, accountID, calendarID, eventID); err != nil {
+		return errors.Wrapf(err, "deleting calendar link of event %q", eventID)
+	}
+	return nil
+}
@@ -0,0 +1,503 @@
+package calendar
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/integration"
+	"gitlab.com/vjsideprojects/relay/internal/platform/integration/calendar"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// the names of the fields of the meetings entity
+const (
+	meetingTitle      = "cal_title"
+	meetingSummary    = "summary"
+	meetingAttendees  = "attendess"
+	meetingStart      = "start_time"
+	meetingEnd        = "end_time"
+	meetingTimezone   = "timezone"
+	meetingRecurrence = "recurrence"
+)
+
+// Streamer sends the meetings created, updated and deleted by the sync to the job, as the item handlers do.
+type Streamer func(*stream.Message) error
+
+// syncer moves the meetings of the account between the meetings entity and the external calendar.
+type syncer struct {
+	accountID string
+	cfg       entity.CaldendarEntity
+	p         calendar.Provider
+	e         entity.Entity
+	contactID string // the entity the attendees refer
+	emailKey  string // the key of the email of the contacts
+	db        *sqlx.DB
+	stream    Streamer
+}
+
+func newSyncer(accountID string, cfg entity.CaldendarEntity, e entity.Entity, db *sqlx.DB, streamer Streamer) syncer {
+	attendees := entity.NameMap(e.EasyFields())[meetingAttendees]
+	return syncer{
+		accountID: accountID,
+		cfg:       cfg,
+		p:         provider(cfg),
+		e:         e,
+		contactID: attendees.RefID,
+		emailKey:  attendees.DisplayGex(),
+		db:        db,
+		stream:    streamer,
+	}
+}
+
+// provider returns the provider of the calendar integrated, the google calendar when it is not set.
+func provider(cfg entity.CaldendarEntity) calendar.Provider {
+	switch cfg.Provider {
+	case integration.TypeCalDAV:
+		return calendar.CalDAV{Username: cfg.Username, Password: cfg.APIKey}
+	case integration.TypeICSFeed:
+		return calendar.ICSFeed{}
+	}
+	return &calendar.Gcalendar{OAuthFile: oAuthFile, TokenJson: cfg.APIKey}
+}
+
+// SyncAll pulls the changes of the calendars of all the accounts integrated. The failures are logged and the
+// other accounts are synced still.
+func SyncAll(ctx context.Context, db *sqlx.DB, streamer Streamer) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.SyncAll")
+	defer span.End()
+
+	accountIDs := []string{}
+	const q = `SELECT DISTINCT e.account_id FROM entities e JOIN items i ON i.entity_id = e.entity_id WHERE e.name = $1`
+	if err := db.SelectContext(ctx, &accountIDs, q, entity.FixedEntityCalendar); err != nil {
+		return errors.Wrap(err, "selecting accounts with the calendar")
+	}
+
+	for _, accountID := range accountIDs {
+		if err := Pull(ctx, accountID, db, streamer); err != nil {
+			log.Printf("***> calendar sync failed for the account %s. error: %v\n", accountID, err)
+		}
+	}
+	return nil
+}
+
+// Pull brings the changes of the external calendar since the last sync into the meetings. The meetings changed
+// on both sides go to the side written last. The sync token is saved in the calendar config.
+func Pull(ctx context.Context, accountID string, db *sqlx.DB, streamer Streamer) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.Pull")
+	defer span.End()
+
+	cfg, updaterFunc, err := calendarEntityItem(ctx, accountID, "0", db)
+	if err != nil {
+		return err
+	}
+	e, err := meetingEntity(ctx, accountID, db)
+	if err != nil {
+		return err
+	}
+	s := newSyncer(accountID, cfg, e, db, streamer)
+
+	changes, err := s.p.Changes(cfg.ID, cfg.SyncToken)
+	if err == calendar.ErrSyncExpired {
+		changes, err = s.p.Changes(cfg.ID, "")
+	}
+	if err != nil {
+		cfg.Retries++
+		updaterFunc(ctx, cfg, db)
+		return err
+	}
+
+	listed := make(map[string]bool, len(changes.Events))
+	for _, ev := range changes.Events {
+		listed[ev.ID] = true
+		if err := s.pull(ctx, ev); err != nil {
+			return errors.Wrapf(err, "syncing calendar event %q", ev.ID)
+		}
+	}
+
+	if changes.Full {
+		links, err := Links(ctx, db, accountID, cfg.ID)
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			if !listed[l.EventID] {
+				if err := s.remove(ctx, l); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cfg.SyncToken = changes.Token
+	cfg.SyncedAt = time.Now()
+	cfg.Retries = 0
+	return updaterFunc(ctx, cfg, db)
+}
+
+// Push writes the meeting to the external calendar. The meeting not changed since the last sync is skipped, which
+// keeps the meetings pulled in from being written back.
+func Push(ctx context.Context, accountID string, e entity.Entity, it item.Item, db *sqlx.DB) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.Push")
+	defer span.End()
+
+	cfg, _, err := calendarEntityItem(ctx, accountID, "0", db)
+	if err != nil {
+		return err
+	}
+	s := newSyncer(accountID, cfg, e, db, nil)
+
+	l, err := LinkOfItem(ctx, db, accountID, cfg.ID, it.ID)
+	switch {
+	case err == ErrLinkNotFound:
+		l = Link{AccountID: accountID, CalendarID: cfg.ID, EntityID: e.ID, ItemID: it.ID, UID: it.ID}
+	case err != nil:
+		return err
+	case it.UpdatedAt <= l.ItemUpdatedAt:
+		return nil
+	}
+	return s.push(ctx, l, it, l.ETag)
+}
+
+// Cancel removes the event of the deleted meeting from the external calendar.
+func Cancel(ctx context.Context, accountID, itemID string, db *sqlx.DB) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.Cancel")
+	defer span.End()
+
+	cfg, _, err := calendarEntityItem(ctx, accountID, "0", db)
+	if err != nil {
+		return err
+	}
+	l, err := LinkOfItem(ctx, db, accountID, cfg.ID, itemID)
+	if err == ErrLinkNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p := provider(cfg)
+	err = p.Cancel(cfg.ID, calendar.Event{ID: l.EventID, UID: l.UID, ETag: l.ETag})
+	if err == calendar.ErrConflict { // the delete is the last write, it wins over the change
+		err = p.Cancel(cfg.ID, calendar.Event{ID: l.EventID, UID: l.UID})
+	}
+	if err != nil && err != calendar.ErrReadOnly {
+		return err
+	}
+	return DeleteLink(ctx, db, accountID, cfg.ID, l.EventID)
+}
+
+// Export writes the meetings of the entity as the iCalendar feed.
+func Export(ctx context.Context, w io.Writer, accountID string, e entity.Entity, db *sqlx.DB) error {
+	ctx, span := trace.StartSpan(ctx, "internal.integration.calendar.Export")
+	defer span.End()
+
+	items, err := item.List(ctx, accountID, e.ID, db)
+	if err != nil {
+		return err
+	}
+	s := newSyncer(accountID, entity.CaldendarEntity{}, e, db, nil)
+	events := make([]calendar.Event, 0, len(items))
+	for _, it := range items {
+		ev, err := s.event(ctx, it)
+		if err != nil { // the meetings without the start are not on the calendar
+			continue
+		}
+		ev.UID = it.ID
+		events = append(events, ev)
+	}
+	return calendar.EncodeICS(w, events)
+}
+
+// pull applies the event changed in the external calendar to its meeting.
+func (s syncer) pull(ctx context.Context, ev calendar.Event) error {
+	l, err := LinkOfEvent(ctx, s.db, s.accountID, s.cfg.ID, ev.ID)
+	if err == ErrLinkNotFound {
+		if ev.Cancelled {
+			return nil
+		}
+		return s.create(ctx, ev)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ev.Cancelled {
+		return s.remove(ctx, l)
+	}
+
+	it, err := item.Retrieve(ctx, s.accountID, l.EntityID, l.ItemID, s.db)
+	if err == item.ErrNotFound { // the meeting deleted before its cancel went out
+		if err := s.p.Cancel(s.cfg.ID, ev); err != nil && err != calendar.ErrReadOnly {
+			return err
+		}
+		return DeleteLink(ctx, s.db, s.accountID, s.cfg.ID, l.EventID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if localWins(l, it, ev) {
+		return s.push(ctx, l, it, ev.ETag)
+	}
+	if ev.ETag != "" && ev.ETag == l.ETag {
+		return nil
+	}
+	return s.apply(ctx, l, it, ev)
+}
+
+// push writes the meeting over the event having the etag. When the event changed since, the side written last
+// wins.
+func (s syncer) push(ctx context.Context, l Link, it item.Item, etag string) error {
+	ev, err := s.event(ctx, it)
+	if err != nil {
+		return err
+	}
+	ev.ID, ev.UID, ev.ETag = l.EventID, l.UID, etag
+
+	saved, err := s.p.Put(s.cfg.ID, ev)
+	if err == calendar.ErrConflict {
+		remote, err := s.p.Get(s.cfg.ID, l.EventID)
+		if err != nil {
+			return err
+		}
+		if !localWins(l, it, remote) {
+			return s.apply(ctx, l, it, remote)
+		}
+		ev.ETag = remote.ETag
+		saved, err = s.p.Put(s.cfg.ID, ev)
+	}
+	if err == calendar.ErrReadOnly {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	l.EventID, l.ETag, l.ItemUpdatedAt, l.SyncedAt = saved.ID, saved.ETag, it.UpdatedAt, time.Now()
+	if saved.UID != "" {
+		l.UID = saved.UID
+	}
+	return SaveLink(ctx, s.db, l)
+}
+
+// create adds the meeting of the event new to the meetings.
+func (s syncer) create(ctx context.Context, ev calendar.Event) error {
+	fields, err := s.fields(ctx, ev)
+	if err != nil {
+		return err
+	}
+
+	ni := item.NewItem{
+		ID:        uuid.New().String(),
+		AccountID: s.accountID,
+		EntityID:  s.e.ID,
+		Fields:    fields,
+	}
+	if len(s.cfg.Owner) > 0 {
+		ni.UserID = &s.cfg.Owner[0]
+	}
+	it, err := item.Create(ctx, s.db, ni, time.Now())
+	if err != nil {
+		return err
+	}
+
+	err = SaveLink(ctx, s.db, Link{
+		AccountID:     s.accountID,
+		CalendarID:    s.cfg.ID,
+		EventID:       ev.ID,
+		UID:           ev.UID,
+		EntityID:      s.e.ID,
+		ItemID:        it.ID,
+		ETag:          ev.ETag,
+		ItemUpdatedAt: it.UpdatedAt,
+		SyncedAt:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	// streamed after the link is saved, so the job finds the meeting synced and does not push it back.
+	return s.send(stream.NewCreteItemMessage(ctx, s.db, s.accountID, s.userID(), s.e.ID, it.ID, nil))
+}
+
+// apply writes the event over the meeting.
+func (s syncer) apply(ctx context.Context, l Link, it item.Item, ev calendar.Event) error {
+	fields, err := s.fields(ctx, ev)
+	if err != nil {
+		return err
+	}
+	oldFields := it.Fields()
+	merged := it.Fields()
+	for k, v := range fields {
+		merged[k] = v
+	}
+	updated, err := item.UpdateFields(ctx, s.db, s.accountID, l.EntityID, l.ItemID, merged)
+	if err != nil {
+		return err
+	}
+
+	l.ETag, l.ItemUpdatedAt, l.SyncedAt = ev.ETag, updated.UpdatedAt, time.Now()
+	if err := SaveLink(ctx, s.db, l); err != nil {
+		return err
+	}
+	return s.send(stream.NewUpdateItemMessage(ctx, s.db, s.accountID, s.userID(), l.EntityID, l.ItemID, updated.Fields(), oldFields))
+}
+
+// remove deletes the meeting of the event cancelled in the external calendar. The link goes first, so the
+// job deleting the meeting has no event left to cancel.
+func (s syncer) remove(ctx context.Context, l Link) error {
+	if err := DeleteLink(ctx, s.db, s.accountID, s.cfg.ID, l.EventID); err != nil {
+		return err
+	}
+	if s.stream == nil {
+		return item.Delete(ctx, s.db, s.accountID, l.EntityID, l.ItemID)
+	}
+	return s.send(stream.NewDeleteItemMessage(ctx, s.db, s.accountID, s.userID(), l.EntityID, l.ItemID))
+}
+
+// send streams the change of the meeting. The failure is logged, the meeting is synced already.
+func (s syncer) send(m *stream.Message) error {
+	if s.stream == nil {
+		return nil
+	}
+	if err := s.stream(m); err != nil {
+		log.Printf("***> calendar sync of the meeting %s not streamed. error: %v\n", m.ItemID, err)
+	}
+	return nil
+}
+
+// userID is the user the sync acts as, the owner of the calendar when it has one.
+func (s syncer) userID() string {
+	if len(s.cfg.Owner) > 0 {
+		return s.cfg.Owner[0]
+	}
+	return user.UUID_ENGINE_USER
+}
+
+// event reads the meeting as the calendar event. The attendees are the emails of the contacts.
+func (s syncer) event(ctx context.Context, it item.Item) (calendar.Event, error) {
+	fields := entity.NameMap(s.e.ValueAdd(it.Fields()))
+	ev := meetingEvent(fields)
+	ev.Updated = time.Unix(it.UpdatedAt, 0)
+	if ev.Start.IsZero() {
+		return calendar.Event{}, errors.Errorf("the meeting %q has no start time", it.ID)
+	}
+
+	contactIDs := refValues(fields[meetingAttendees])
+	if s.contactID == "" || s.emailKey == "" || len(contactIDs) == 0 {
+		return ev, nil
+	}
+	contacts, err := item.BulkRetrieveItems(ctx, s.accountID, contactIDs, s.db)
+	if err != nil {
+		return calendar.Event{}, err
+	}
+	for _, c := range contacts {
+		if email, ok := c.Fields()[s.emailKey].(string); ok && email != "" {
+			ev.Attendees = append(ev.Attendees, email)
+		}
+	}
+	return ev, nil
+}
+
+// fields returns the fields of the meeting of the event. The attendees are matched to the contacts by email.
+func (s syncer) fields(ctx context.Context, ev calendar.Event) (map[string]interface{}, error) {
+	contactIDs := make([]interface{}, 0)
+	if s.contactID != "" && s.emailKey != "" && len(ev.Attendees) > 0 {
+		emails := make([]string, len(ev.Attendees))
+		for i, email := range ev.Attendees {
+			emails[i] = strings.ToLower(email)
+		}
+		contacts, err := item.Matching(ctx, s.accountID, s.contactID, s.emailKey, emails, s.db)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range contacts {
+			contactIDs = append(contactIDs, c.ID)
+		}
+	}
+	return meetingFields(s.e, ev, contactIDs), nil
+}
+
+// localWins tells whether the meeting is written over the event. A side changed when it moved past the link
+// and when both did, the side written last wins. The events without the etag are taken as changed.
+func localWins(l Link, it item.Item, ev calendar.Event) bool {
+	local := it.UpdatedAt > l.ItemUpdatedAt
+	remote := ev.ETag == "" || ev.ETag != l.ETag
+	if local && remote {
+		return it.UpdatedAt > ev.Updated.Unix()
+	}
+	return local
+}
+
+// meetingEvent reads the named fields of the meeting as the event, without its attendees.
+func meetingEvent(fields map[string]entity.Field) calendar.Event {
+	ev := calendar.Event{
+		Summary:     entityFieldVal(fields[meetingTitle]),
+		Description: entityFieldVal(fields[meetingSummary]),
+		TimeZone:    entityFieldVal(fields[meetingTimezone]),
+		Recurrence:  strings.TrimPrefix(entityFieldVal(fields[meetingRecurrence]), "RRULE:"),
+	}
+	ev.Start, _ = entity.ParseValueTime(entityFieldVal(fields[meetingStart]))
+	ev.End, _ = entity.ParseValueTime(entityFieldVal(fields[meetingEnd]))
+	if ev.End.Before(ev.Start) {
+		ev.End = ev.Start
+	}
+	return ev
+}
+
+// meetingFields returns the fields of the meeting keyed for the entity.
+func meetingFields(e entity.Entity, ev calendar.Event, contactIDs []interface{}) map[string]interface{} {
+	named := map[string]interface{}{
+		meetingTitle:      ev.Summary,
+		meetingSummary:    ev.Description,
+		meetingAttendees:  contactIDs,
+		meetingStart:      util.FormatTimeGo(ev.Start),
+		meetingTimezone:   ev.TimeZone,
+		meetingRecurrence: ev.Recurrence,
+	}
+	if !ev.End.IsZero() {
+		named[meetingEnd] = util.FormatTimeGo(ev.End)
+	}
+
+	fields := make(map[string]interface{}, len(named))
+	for name, v := range named {
+		if key := e.Key(name); key != "" {
+			fields[key] = v
+		}
+	}
+	return fields
+}
+
+// meetingEntity returns the meetings entity of the account.
+func meetingEntity(ctx context.Context, accountID string, db *sqlx.DB) (entity.Entity, error) {
+	entities, err := entity.AccountEntities(ctx, accountID, []int{entity.CategoryMeeting}, db)
+	if err != nil {
+		return entity.Entity{}, err
+	}
+	for _, e := range entities {
+		if e.Name == entity.FixedEntityMeetings {
+			return e, nil
+		}
+	}
+	if len(entities) > 0 {
+		return entities[0], nil
+	}
+	return entity.Entity{}, entity.ErrFixedEntityNotFound
+}
+
+func refValues(f entity.Field) []interface{} {
+	if vals, ok := f.Value.([]interface{}); ok {
+		return vals
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package calendar
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/integration/calendar"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestLocalWins(t *testing.T) {
+	synced := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	l := Link{ETag: `"1"`, ItemUpdatedAt: synced.Unix()}
+
+	t.Log("Given the need to resolve the meetings changed on both sides by the last write")
+	{
+		cases := []struct {
+			name  string
+			it    item.Item
+			ev    calendar.Event
+			local bool
+		}{
+			{"no change", item.Item{UpdatedAt: synced.Unix()}, calendar.Event{ETag: `"1"`}, false},
+			{"meeting changed", item.Item{UpdatedAt: synced.Add(time.Minute).Unix()}, calendar.Event{ETag: `"1"`}, true},
+			{"event changed", item.Item{UpdatedAt: synced.Unix()}, calendar.Event{ETag: `"2"`, Updated: synced.Add(time.Minute)}, false},
+			{"meeting written last", item.Item{UpdatedAt: synced.Add(2 * time.Minute).Unix()}, calendar.Event{ETag: `"2"`, Updated: synced.Add(time.Minute)}, true},
+			{"event written last", item.Item{UpdatedAt: synced.Add(time.Minute).Unix()}, calendar.Event{ETag: `"2"`, Updated: synced.Add(2 * time.Minute)}, false},
+		}
+		for _, c := range cases {
+			if got := localWins(l, c.it, c.ev); got != c.local {
+				t.Fatalf("\t%s should pick the side for %s : got local %v", tests.Failed, c.name, got)
+			}
+		}
+		t.Logf("\t%s should pick the side written last", tests.Success)
+	}
+}
+
+func TestMeetingFields(t *testing.T) {
+	fields := []entity.Field{
+		{Key: "k-title", Name: meetingTitle},
+		{Key: "k-summary", Name: meetingSummary},
+		{Key: "k-attendees", Name: meetingAttendees},
+		{Key: "k-start", Name: meetingStart},
+		{Key: "k-end", Name: meetingEnd},
+		{Key: "k-tz", Name: meetingTimezone},
+		{Key: "k-rrule", Name: meetingRecurrence},
+	}
+	fieldsb, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatalf("\t%s should encode the fields of the meetings : %v", tests.Failed, err)
+	}
+	e := entity.Entity{ID: "meetings-entity", Fieldsb: string(fieldsb)}
+
+	t.Log("Given the need to map the events to the meetings and back")
+	{
+		start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		ev := calendar.Event{Summary: "Demo", Description: "Agenda", Start: start, End: start.Add(time.Hour), TimeZone: "Asia/Kolkata", Recurrence: "FREQ=WEEKLY"}
+		values := meetingFields(e, ev, []interface{}{"contact-1"})
+		if values["k-title"] != "Demo" || values["k-rrule"] != "FREQ=WEEKLY" || len(values["k-attendees"].([]interface{})) != 1 {
+			t.Fatalf("\t%s should key the fields of the meeting : %+v", tests.Failed, values)
+		}
+
+		got := meetingEvent(entity.NameMap(e.ValueAdd(values)))
+		if got.Summary != ev.Summary || got.Description != ev.Description || !got.Start.Equal(ev.Start) || !got.End.Equal(ev.End) || got.TimeZone != ev.TimeZone || got.Recurrence != ev.Recurrence {
+			t.Fatalf("\t%s should read the meeting back as the event : %+v", tests.Failed, got)
+		}
+		t.Logf("\t%s should read the meeting back as the event", tests.Success)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"gitlab.com/vjsideprojects/relay/internal/platform/ruleengine/services/ruler"
@@ -15,6 +16,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -44,7 +46,8 @@ func ListFilterByState(ctx context.Context, accountID, entityID string, state in
 	return items, nil
 }
 
-func Result(ctx context.Context, accountID, entityID string, pageNo int, wh string, db *sqlx.DB) ([]Item, error) {
+// Result lists the items satisfying the where clause in the order given, newest first when the order is empty.
+func Result(ctx context.Context, accountID, entityID string, pageNo int, wh, order string, db *sqlx.DB) ([]Item, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.item.Result")
 	defer span.End()
 
@@ -56,8 +59,12 @@ func Result(ctx context.Context, accountID, entityID string, pageNo int, wh stri
 		skipCount = pageNo * util.PageLimt
 	}
 
+	if order == "" {
+		order = "created_at DESC"
+	}
+
 	items := []Item{}
-	q := fmt.Sprintf(`SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 %s ORDER BY created_at DESC LIMIT $4 OFFSET $5`, wh)
+	q := fmt.Sprintf(`SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 %s ORDER BY %s LIMIT $4 OFFSET $5`, wh, order)
 	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, pageLimt, skipCount); err != nil {
 		return nil, errors.Wrap(err, "selecting items result")
 	}
@@ -107,6 +114,79 @@ func BulkRetrieveItems(ctx context.Context, accountID string, ids []interface{},
 	return items, nil
 }
 
+// Referring returns the items of the entity referring the item in the reference field, newest first.
+func Referring(ctx context.Context, accountID, entityID, key, refItemID string, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.Referring")
+	defer span.End()
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 AND fieldsb @> jsonb_build_object($4::text, jsonb_build_array($5::text)) ORDER BY created_at DESC`
+
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, key, refItemID); err != nil {
+		return items, errors.Wrap(err, "selecting items referring the item")
+	}
+
+	return items, nil
+}
+
+// Matching returns the items of the entity having the value of the key in the values, compared in lower case.
+func Matching(ctx context.Context, accountID, entityID, key string, values []string, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.Matching")
+	defer span.End()
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 AND lower(fieldsb->>$4) = any($5) LIMIT 100`
+
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, key, pq.Array(values)); err != nil {
+		return items, errors.Wrap(err, "selecting items matching the values")
+	}
+
+	return items, nil
+}
+
+// Search returns the items of the entity having the term in the value of the key, newest first.
+func Search(ctx context.Context, accountID, entityID, key, term string, limit int, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.Search")
+	defer span.End()
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 AND fieldsb->>$4 ILIKE '%' || $5 || '%' ORDER BY created_at DESC LIMIT $6`
+
+	term = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, key, term, limit); err != nil {
+		return items, errors.Wrap(err, "selecting items having the term")
+	}
+
+	return items, nil
+}
+
+// Window returns the items of the entity overlapping the window. The items end at the end key, or at the start
+// when it is empty, and the recurring items are returned when their series started before the window ends.
+// The items are ordered by the start, so the callers asking for one more than the limit know the rest is left.
+func Window(ctx context.Context, accountID, entityID, startKey, endKey, recurKey string, from, to time.Time, wh string, limit int, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.Window")
+	defer span.End()
+
+	start, end := timeValue("$4"), timeValue("$5")
+	items := []Item{}
+	q := fmt.Sprintf(`SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3
+		AND %[1]s < $8 AND (COALESCE(%[2]s, %[1]s) >= $7 OR COALESCE(fieldsb->>$6, '') <> '')
+		%[3]s ORDER BY %[1]s, item_id LIMIT $9`, start, end, wh)
+
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, startKey, endKey, recurKey, from, to, limit); err != nil {
+		return items, errors.Wrap(err, "selecting items in the window")
+	}
+
+	return items, nil
+}
+
+// timeValue reads the date field of the key param as the timestamp. The older clients saved the unix
+// milliseconds, and the values in neither form are null rather than failing the whole query.
+func timeValue(param string) string {
+	return fmt.Sprintf(`(CASE WHEN fieldsb->>%[1]s ~ '^[0-9]+$' THEN to_timestamp((fieldsb->>%[1]s)::bigint / 1000.0)
+		WHEN fieldsb->>%[1]s ~ '^[0-9]{4}-[0-9]{2}-[0-9]{2}' THEN (fieldsb->>%[1]s)::timestamptz END)`, param)
+}
+
 func EntityItems(ctx context.Context, accountID, entityID string, db *sqlx.DB) ([]Item, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.item.EntityItems")
 	defer span.End()
@@ -123,6 +203,87 @@ func EntityItems(ctx context.Context, accountID, entityID string, db *sqlx.DB) (
 	return items, nil
 }
 
+// CountWithFields returns the number of items of the entity having a value for any of the keys.
+func CountWithFields(ctx context.Context, accountID, entityID string, keys []string, db *sqlx.DB) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.CountWithFields")
+	defer span.End()
+
+	var count int
+	const q = `SELECT count(*) FROM items where account_id = $1 AND entity_id = $2 AND fieldsb ?| $3`
+	if err := db.GetContext(ctx, &count, q, accountID, entityID, pq.Array(keys)); err != nil {
+		return 0, errors.Wrap(err, "counting items with the fields")
+	}
+	return count, nil
+}
+
+// WithFields returns the page of items of the entity having any of the keys. The items are ordered by
+// the id, so the next page starts after the last id of the previous page.
+func WithFields(ctx context.Context, accountID, entityID string, keys []string, afterID string, limit int, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.WithFields")
+	defer span.End()
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND fieldsb ?| $3 AND item_id > $4 ORDER BY item_id LIMIT $5`
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, pq.Array(keys), afterID, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting items with the fields")
+	}
+	return items, nil
+}
+
+// Page returns the page of items of the entity ordered by the id, so the next page starts after the
+// last id of the previous page.
+func Page(ctx context.Context, accountID, entityID, afterID string, limit int, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.Page")
+	defer span.End()
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 AND item_id > $4 ORDER BY item_id LIMIT $5`
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, afterID, limit); err != nil {
+		return nil, errors.Wrap(err, "selecting page of items")
+	}
+	return items, nil
+}
+
+// PageHaving returns the page of the items of the entity like Page, of the items having the value in the
+// field. The value of the list and the reference fields is one of the values in the list.
+func PageHaving(ctx context.Context, accountID, entityID, key, value, afterID string, limit int, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.PageHaving")
+	defer span.End()
+
+	if afterID == "" {
+		afterID = "00000000-0000-0000-0000-000000000000"
+	}
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 AND item_id > $4
+		AND fieldsb->$6 @> to_jsonb($7::text) ORDER BY item_id LIMIT $5`
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, afterID, limit, key, value); err != nil {
+		return nil, errors.Wrap(err, "selecting page of items having the value")
+	}
+	return items, nil
+}
+
+// Range returns the items of the entity from the offset, oldest first. It serves the clients paging with the index.
+func Range(ctx context.Context, accountID, entityID string, offset, limit int, db *sqlx.DB) ([]Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.Range")
+	defer span.End()
+
+	items := []Item{}
+	const q = `SELECT * FROM items where account_id = $1 AND entity_id = $2 AND state = $3 ORDER BY created_at, item_id LIMIT $4 OFFSET $5`
+	if err := db.SelectContext(ctx, &items, q, accountID, entityID, StateDefault, limit, offset); err != nil {
+		return nil, errors.Wrap(err, "selecting range of items")
+	}
+	return items, nil
+}
+
 func TaskItems(ctx context.Context, accountID, entityID, itemID, taskEntityID string, db *sqlx.DB) ([]Item, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.item.TaskItems")
 	defer span.End()
@@ -154,7 +315,7 @@ func UserEntityItems(ctx context.Context, accountID, entityID, userID string, db
 }
 
 // Create inserts a new item into the database.
-func Create(ctx context.Context, db *sqlx.DB, n NewItem, now time.Time) (Item, error) {
+func Create(ctx context.Context, db database.Conn, n NewItem, now time.Time) (Item, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.item.Create")
 	defer span.End()
 
@@ -231,6 +392,36 @@ func UpdateFields(ctx context.Context, db *sqlx.DB, accountID, entityID, id stri
 	return update(ctx, db, accountID, entityID, id, upd, time.Now())
 }
 
+// MergeFields sets only the given fields of the item and keeps the rest as they are stored. The worker saves
+// the values it computes with it, so the changes made to the item meanwhile are not overwritten.
+func MergeFields(ctx context.Context, db *sqlx.DB, accountID, entityID, id string, fields map[string]interface{}) (Item, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.item.MergeFields")
+	defer span.End()
+
+	//convert empty string to null
+	for k, v := range fields {
+		if v == "" {
+			fields[k] = nil
+		}
+	}
+	input, err := json.Marshal(fields)
+	if err != nil {
+		return Item{}, errors.Wrap(err, "encode fields to input")
+	}
+
+	var i Item
+	const q = `UPDATE items SET fieldsb = fieldsb || $4::jsonb, updated_at = $5
+		WHERE account_id = $1 AND entity_id = $2 AND item_id = $3 RETURNING *`
+	if err := db.GetContext(ctx, &i, q, accountID, entityID, id, string(input), time.Now().Unix()); err != nil {
+		if err == sql.ErrNoRows {
+			return Item{}, ErrNotFound
+		}
+		return Item{}, errors.Wrapf(err, "merging fields of item %q", id)
+	}
+
+	return i, nil
+}
+
 // Update replaces a item document in the database.
 func update(ctx context.Context, db *sqlx.DB, accountID, entityID, id string, upd UpdateItem, now time.Time) (Item, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.item.Update")
@@ -307,6 +498,39 @@ func DeleteAllByUser(ctx context.Context, db *sqlx.DB, accountID, entityID, user
 	return nil
 }
 
+// ReassignUser hands over the items owned by the user to another user of the account.
+func ReassignUser(ctx context.Context, db *sqlx.DB, accountID, fromUserID, toUserID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.item.ReassignUser")
+	defer span.End()
+
+	const q = `UPDATE items SET user_id = $3, updated_at = $4 WHERE account_id = $1 AND user_id = $2`
+
+	if _, err := db.ExecContext(ctx, q, accountID, fromUserID, toUserID, time.Now().UTC().Unix()); err != nil {
+		return errors.Wrapf(err, "reassigning items of user %s", fromUserID)
+	}
+
+	return nil
+}
+
+// ReassignMember replaces the member in the values of the key, which refers the members (owners) of the account.
+// The member is removed from the values when toMemberID is empty.
+func ReassignMember(ctx context.Context, db *sqlx.DB, accountID, entityID, key, fromMemberID, toMemberID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.item.ReassignMember")
+	defer span.End()
+
+	const q = `UPDATE items SET fieldsb = jsonb_set(fieldsb, ARRAY[$3::text], (
+			SELECT COALESCE(jsonb_agg(DISTINCT to_jsonb(m)), '[]'::jsonb)
+			FROM (SELECT CASE WHEN v = $4 THEN NULLIF($5::text, '') ELSE v END AS m FROM jsonb_array_elements_text(fieldsb->$3) AS v) r
+			WHERE m IS NOT NULL)), updated_at = $6
+		WHERE account_id = $1 AND entity_id = $2 AND fieldsb->$3 @> jsonb_build_array($4::text)`
+
+	if _, err := db.ExecContext(ctx, q, accountID, entityID, key, fromMemberID, toMemberID, time.Now().UTC().Unix()); err != nil {
+		return errors.Wrapf(err, "reassigning member %s in %s", fromMemberID, key)
+	}
+
+	return nil
+}
+
 func DeleteAllByDummies(ctx context.Context, db *sqlx.DB, accountID string) error {
 	ctx, span := trace.StartSpan(ctx, "internal.item.DeleteAllByDummies")
 	defer span.End()
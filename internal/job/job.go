@@ -16,7 +16,9 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/integration/calendar"
 	"gitlab.com/vjsideprojects/relay/internal/integration/email"
 	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/metric"
 	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
 	"gitlab.com/vjsideprojects/relay/internal/platform/graphdb"
@@ -28,6 +30,7 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
 	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
 	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"gitlab.com/vjsideprojects/relay/internal/slackapp"
 	"gitlab.com/vjsideprojects/relay/internal/team"
 	"gitlab.com/vjsideprojects/relay/internal/user"
 )
@@ -65,6 +68,14 @@ func (j *Job) Post(msg *stream.Message) error {
 		return j.eventEventAdded(msg)
 	case stream.TypeAccountLaunch:
 		return j.eventAccountLaunched(msg)
+	case stream.TypeSLAChanged:
+		return j.eventSLAChanged(msg)
+	case stream.TypeFieldMigration:
+		return j.eventFieldMigration(msg)
+	case stream.TypeMetricBackfill:
+		return j.eventMetricBackfill(msg)
+	case stream.TypeHealthScore:
+		return j.eventHealthScore(msg)
 	}
 	return nil
 }
@@ -121,6 +132,30 @@ func (j *Job) eventItemCreated(m *stream.Message) error {
 		}
 	}
 
+	//formulas & rollups
+	if m.State < stream.StateWorkflow {
+		err = j.actOnComputed(ctx, e, it, nil, it.Fields())
+		if err != nil {
+			log.Println("***>***> EventItemCreated: unexpected/unhandled error occurred on actOnComputed. error: ", err)
+		}
+	}
+
+	//metrics
+	if m.State < stream.StateWorkflow {
+		err = metric.Added(ctx, j.DB, e, it)
+		if err != nil {
+			log.Println("***>***> EventItemCreated: unexpected/unhandled error occurred on metric.Added. error: ", err)
+		}
+	}
+
+	//health
+	if m.State < stream.StateWorkflow {
+		err = j.actOnHealth(ctx, e, it, nil, it.Fields())
+		if err != nil {
+			log.Println("***>***> EventItemCreated: unexpected/unhandled error occurred on actOnHealth. error: ", err)
+		}
+	}
+
 	//workflows
 	if m.UserID != user.UUID_SYSTEM_USER && m.State < stream.StateWorkflow { // for now, preventing loops in workflows by this check!
 		err = j.actOnWorkflows(ctx, e, m.ItemID, nil, it.Fields(), j.DB, j.SDB)
@@ -132,6 +167,14 @@ func (j *Job) eventItemCreated(m *stream.Message) error {
 		}
 	}
 
+	//sla timers
+	if m.State < stream.StateCategory {
+		err = j.actOnSLA(ctx, e, it, m.UserID, nil, it.Fields())
+		if err != nil {
+			log.Println("***>***> EventItemCreated: unexpected/unhandled error occurred on actOnSLA. error: ", err)
+		}
+	}
+
 	//categories such as email,meeting,members
 	if m.State < stream.StateCategory {
 		err = actOnCategories(ctx, m.AccountID, m.UserID, m.Source, e, it, valueAddedFields, j.DB, j.SDB)
@@ -212,6 +255,30 @@ func (j *Job) eventItemUpdated(m *stream.Message) error {
 		}
 	}
 
+	//formulas & rollups
+	if m.State < stream.StateWorkflow {
+		err = j.actOnComputed(ctx, e, it, m.OldFields, m.NewFields)
+		if err != nil {
+			log.Println("***>***> EventItemUpdated: unexpected/unhandled error occurred on actOnComputed. error: ", err)
+		}
+	}
+
+	//metrics
+	if m.State < stream.StateWorkflow {
+		err = metric.Changed(ctx, j.DB, e, it, m.OldFields, m.NewFields)
+		if err != nil {
+			log.Println("***>***> EventItemUpdated: unexpected/unhandled error occurred on metric.Changed. error: ", err)
+		}
+	}
+
+	//health
+	if m.State < stream.StateWorkflow {
+		err = j.actOnHealth(ctx, e, it, m.OldFields, m.NewFields)
+		if err != nil {
+			log.Println("***>***> EventItemUpdated: unexpected/unhandled error occurred on actOnHealth. error: ", err)
+		}
+	}
+
 	//workflows
 	if m.UserID != user.UUID_SYSTEM_USER && m.State < stream.StateWorkflow { // for now, preventing loops in workflows by this check!
 		err = j.actOnWorkflows(ctx, e, m.ItemID, m.OldFields, m.NewFields, j.DB, j.SDB)
@@ -223,6 +290,22 @@ func (j *Job) eventItemUpdated(m *stream.Message) error {
 		}
 	}
 
+	//sla timers
+	if m.State < stream.StateWho {
+		err = j.actOnSLA(ctx, e, it, m.UserID, m.OldFields, m.NewFields)
+		if err != nil {
+			log.Println("***>***> EventItemUpdated: unexpected/unhandled error occurred on actOnSLA. error: ", err)
+		}
+	}
+
+	//calendar
+	if e.Category == entity.CategoryMeeting && m.State < stream.StateWho {
+		err = actOnCalendar(ctx, m.AccountID, e, it, j.DB)
+		if err != nil {
+			log.Println("***>***> EventItemUpdated: unexpected/unhandled error occurred on actOnCalendar. error: ", err)
+		}
+	}
+
 	//who
 	if m.State < stream.StateWho {
 		err = j.actOnWho(ctx, m.AccountID, e.TeamID, m.UserID, m.EntityID, m.ItemID, valueAddedFields, j.DB, j.SDB)
@@ -308,6 +391,13 @@ func (j *Job) eventItemDeleted(m *stream.Message) error {
 		log.Println("***>***> EventItemDeleted: unexpected/unhandled error occurred on destructOnIntegrations. error: ", err)
 	}
 
+	if m.State < stream.StatePrimaryDBDelete {
+		err = metric.Removed(ctx, j.DB, e, it)
+		if err != nil {
+			log.Println("***>***> EventItemDeleted: unexpected/unhandled error occurred on metric.Removed. error: ", err)
+		}
+	}
+
 	if m.State < stream.StatePrimaryDBDelete {
 		err = item.Delete(ctx, j.DB, m.AccountID, m.EntityID, m.ItemID)
 		if err != nil {
@@ -318,6 +408,18 @@ func (j *Job) eventItemDeleted(m *stream.Message) error {
 		}
 	}
 
+	//rollups of the parents
+	err = j.actOnComputed(ctx, e, it, it.Fields(), nil)
+	if err != nil {
+		log.Println("***>***> EventItemDeleted: unexpected/unhandled error occurred on actOnComputed. error: ", err)
+	}
+
+	//health of the items its tickets or tasks referred
+	err = j.actOnHealth(ctx, e, it, it.Fields(), nil)
+	if err != nil {
+		log.Println("***>***> EventItemDeleted: unexpected/unhandled error occurred on actOnHealth. error: ", err)
+	}
+
 	// if m.State < stream.StateSecDBDelete {
 	// 	err = graphdb.Delete(j.SDB.GraphPool(), m.AccountID, m.EntityID, m.ItemID)
 	// 	if err != nil {
@@ -667,6 +769,20 @@ func (j *Job) actOnWorkflows(ctx context.Context, e entity.Entity, itemID string
 		}
 	}
 
+	//workflows - entersSegment/leavesSegment. the date relative segments are re-evaluated by the scheduler
+	for _, ft := range []int{flow.FlowTypeEntersSegment, flow.FlowTypeLeavesSegment} {
+		segmentFlows, err := flow.List(ctx, []string{e.ID}, flow.FlowModeWorkFlow, ft, db)
+		if err != nil {
+			return err
+		}
+		if oldFields != nil {
+			segmentFlows = flow.DirtyFlows(ctx, segmentFlows, dirtyFields)
+		}
+		if len(segmentFlows) > 0 {
+			errs = append(errs, flow.Trigger(ctx, db, sdb, itemID, segmentFlows, eng)...)
+		}
+	}
+
 	err = actOnPipelines(ctx, eng, e, itemID, dirtyFields, newFields, db, sdb)
 	if err != nil && err != flow.ErrFlowActive {
 		errs = append(errs, err)
@@ -691,6 +807,10 @@ func actOnPipelines(ctx context.Context, eng engine.Engine, e entity.Entity, ite
 		if dirtyField, ok := dirtyFields[fi.Key]; ok && fi.IsNode() && dirtyField != nil && len(dirtyField.([]interface{})) > 0 && fi.Dependent != nil {
 			flowID := newFields[fi.Dependent.ParentKey].([]interface{})[0].(string)
 			nodeID := dirtyField.([]interface{})[0].(string)
+			// the move is recorded even when the stage could not be triggered, as the item is in it already.
+			if err := pipeline.Move(ctx, db, e.AccountID, flowID, itemID, nodeID, time.Now()); err != nil {
+				log.Printf("***> unexpected error occurred when recording the move of the item %s to the stage %s. error: %v\n", itemID, nodeID, err)
+			}
 			err := flow.DirectTrigger(ctx, db, sdb, e.AccountID, flowID, nodeID, e.ID, itemID, eng)
 			if err != nil {
 				return errors.Wrap(err, "error: acting on pipelines")
@@ -814,10 +934,7 @@ func actOnCategories(ctx context.Context, accountID, currentUserID string, sourc
 			return errors.Wrap(err, "unable to save conversation")
 		}
 	case entity.CategoryMeeting:
-		err = calendar.CreateCalendarEvent(ctx, accountID, e.TeamID, e.ID, it.ID, valueAddedFields, db)
-		if err == entity.ErrIntegNotFound {
-			return nil
-		}
+		err = actOnCalendar(ctx, accountID, e, it, db)
 	case entity.CategoryUsers:
 		var usr entity.UserEntity
 		jsonbody, _ := entity.MakeJSONBody(valueAddedFields)
@@ -881,6 +998,13 @@ func (j Job) actOnNotifications(ctx context.Context, accountID, userID string, e
 		return err
 	}
 
+	//slack channel of the entity
+	if verb, ok := channelVerbs[notificationType]; ok && (notificationType == notification.TypeCreated || len(dirtyFields) > 0) {
+		if err := slackapp.Notify(ctx, j.DB, j.SDB, accountID, e, it, verb); err != nil {
+			log.Println("***>***> actOnNotifications: unexpected/unhandled error occurred when posting to the slack channel. error: ", err)
+		}
+	}
+
 	if appNotifItem == nil {
 		return nil
 	}
@@ -904,6 +1028,26 @@ func destructOnIntegrations(ctx context.Context, accountID string, e entity.Enti
 		err = email.Destruct(ctx, accountID, e.ID, it.ID, db, sdb)
 	case entity.CategoryCalendar:
 		//calendar destruct yet to be implemented
+	case entity.CategoryMeeting:
+		err = calendar.Cancel(ctx, accountID, it.ID, db)
+		if err == entity.ErrIntegNotFound || err == entity.ErrFixedEntityNotFound {
+			return nil
+		}
+	}
+	return err
+}
+
+// channelVerbs are the events posted to the slack channels of the entities.
+var channelVerbs = map[notification.NotificationType]string{
+	notification.TypeCreated: "created",
+	notification.TypeUpdated: "updated",
+}
+
+// actOnCalendar writes the meeting to the calendar integrated, if any.
+func actOnCalendar(ctx context.Context, accountID string, e entity.Entity, it item.Item, db *sqlx.DB) error {
+	err := calendar.Push(ctx, accountID, e, it, db)
+	if err == entity.ErrIntegNotFound || err == entity.ErrFixedEntityNotFound {
+		return nil
 	}
 	return err
 }
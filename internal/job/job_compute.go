@@ -0,0 +1,218 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/connection"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/graphdb"
+)
+
+// maxRollupDepth stops the rollups of the rollups, such as the deals to the companies to the accounts,
+// going around the cyclic relationships.
+const maxRollupDepth = 3
+
+// actOnComputed evaluates the formula fields of the item and the rollups its own change touched, then rolls
+// the change up to the parent items. The old fields are nil when the item is created and the new fields
+// are nil when it is deleted.
+func (j *Job) actOnComputed(ctx context.Context, e entity.Entity, it item.Item, oldFields, newFields map[string]interface{}) error {
+	if newFields != nil {
+		// the rollups are kept by the worker, recompute the ones the caller overwrote.
+		touched := func(f entity.Field) bool {
+			return oldFields == nil || fmt.Sprint(oldFields[f.Key]) != fmt.Sprint(newFields[f.Key])
+		}
+		fields, _, err := j.recompute(ctx, e, it, touched)
+		if err != nil {
+			return err
+		}
+		newFields = fields
+	}
+	return j.rollupParents(ctx, e, it.ID, oldFields, newFields, 0)
+}
+
+// recompute evaluates the rollup fields chosen and every formula field of the item. Only the values which
+// changed are saved.
+func (j *Job) recompute(ctx context.Context, e entity.Entity, it item.Item, rollup func(f entity.Field) bool) (map[string]interface{}, bool, error) {
+	fields := it.Fields()
+	computed := make(map[string]interface{}, 0)
+	set := func(key string, v interface{}) {
+		if fmt.Sprint(fields[key]) != fmt.Sprint(v) {
+			fields[key] = v
+			computed[key] = v
+		}
+	}
+
+	entityFields := e.EasyFields()
+	for _, f := range entityFields {
+		if def, ok := f.RollupDef(); ok && rollup(f) {
+			v, err := j.rollupValue(ctx, e.AccountID, e.ID, it.ID, def)
+			if err != nil {
+				return nil, false, err
+			}
+			set(f.Key, v)
+		}
+	}
+	// the formulas go after the rollups as they could refer them.
+	for _, f := range entityFields {
+		if f.IsFormula() {
+			v, err := entity.EvalFormula(f.Formula(), fields)
+			if err != nil {
+				log.Printf("***> formula of the field %s in the entity %s not evaluated. error: %v\n", f.Key, e.ID, err)
+				set(f.Key, nil)
+				continue
+			}
+			set(f.Key, v)
+		}
+	}
+
+	if len(computed) == 0 {
+		return fields, false, nil
+	}
+	if err := j.saveComputed(ctx, e, it.ID, computed); err != nil {
+		return nil, false, errors.Wrapf(err, "saving the computed fields of %s", it.ID)
+	}
+	return fields, true, nil
+}
+
+// saveComputed merges the computed values into the stored item and sets them on the graph node of the item,
+// as the item update does, so the segments and the charts reading the graph see them too.
+func (j *Job) saveComputed(ctx context.Context, e entity.Entity, itemID string, values map[string]interface{}) error {
+	if _, err := item.MergeFields(ctx, j.DB, e.AccountID, e.ID, itemID, values); err != nil {
+		return err
+	}
+
+	valueAddedFields := make([]entity.Field, 0, len(values))
+	for _, f := range e.EasyFields() {
+		v, ok := values[f.Key]
+		// the list and the reference fields need the field of the element to form the edges
+		if !ok || ((f.IsList() || f.IsReference()) && f.Field == nil) {
+			continue
+		}
+		f.Value = v
+		valueAddedFields = append(valueAddedFields, f)
+	}
+	if len(valueAddedFields) == 0 {
+		return nil
+	}
+	gpbNode := graphdb.BuildGNode(e.AccountID, e.ID, false, nil).MakeBaseGNode(itemID, makeGraphFields(valueAddedFields))
+	return graphdb.UpsertNode(j.SDB.GraphPool(), gpbNode)
+}
+
+// rollupParents recomputes the rollups of the parent items related to the changed child item.
+func (j *Job) rollupParents(ctx context.Context, child entity.Entity, childID string, oldFields, newFields map[string]interface{}, depth int) error {
+	if depth >= maxRollupDepth {
+		return nil
+	}
+
+	parentEntities, err := entity.RollupEntities(ctx, child.AccountID, child.ID, j.DB)
+	if err != nil {
+		return err
+	}
+
+	for _, pe := range parentEntities {
+		// the parent items with the keys of the rollup fields to recompute
+		parents := make(map[string]map[string]bool, 0)
+		for _, f := range pe.EasyFields() {
+			def, ok := f.RollupDef()
+			if !ok || def.Of != child.ID || !def.Touched(oldFields, newFields) {
+				continue
+			}
+			ids, err := j.parentIDs(ctx, pe, childID, def, oldFields, newFields)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if parents[id] == nil {
+					parents[id] = make(map[string]bool, 0)
+				}
+				parents[id][f.Key] = true
+			}
+		}
+
+		for parentID, keys := range parents {
+			parent, err := item.Retrieve(ctx, pe.AccountID, pe.ID, parentID, j.DB)
+			if err != nil {
+				// the parent is gone, nothing to roll up to.
+				log.Printf("***> rollup parent %s of the entity %s not found. error: %v\n", parentID, pe.ID, err)
+				continue
+			}
+			before := parent.Fields()
+			after, changed, err := j.recompute(ctx, pe, parent, func(f entity.Field) bool { return keys[f.Key] })
+			if err != nil {
+				return err
+			}
+			if changed {
+				if err := j.rollupParents(ctx, pe, parentID, before, after, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parentIDs returns the parents of the child before and after the change. With the reference field, the child
+// moved from one parent to another updates both.
+func (j *Job) parentIDs(ctx context.Context, pe entity.Entity, childID string, def entity.Rollup, oldFields, newFields map[string]interface{}) ([]string, error) {
+	if def.Via == "" {
+		return connection.Parents(ctx, j.DB, pe.AccountID, pe.ID, childID)
+	}
+	seen := make(map[string]bool, 0)
+	ids := make([]string, 0)
+	for _, fields := range []map[string]interface{}{oldFields, newFields} {
+		refs, _ := fields[def.Via].([]interface{})
+		for _, ref := range refs {
+			id := fmt.Sprint(ref)
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// rollupValue folds the children of the parent item satisfying the filter of the rollup.
+func (j *Job) rollupValue(ctx context.Context, accountID, parentEntityID, parentID string, def entity.Rollup) (interface{}, error) {
+	var children []item.Item
+	if def.Via != "" {
+		items, err := item.Referring(ctx, accountID, def.Of, def.Via, parentID, j.DB)
+		if err != nil {
+			return nil, err
+		}
+		children = items
+	} else {
+		connected, err := connection.AllChild(ctx, j.DB, accountID, parentEntityID, parentID, def.Of, "")
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]interface{}, len(connected))
+		for i, c := range connected {
+			ids[i] = c.DstItemID
+		}
+		if len(ids) > 0 {
+			items, err := item.BulkRetrieveItems(ctx, accountID, ids, j.DB)
+			if err != nil {
+				return nil, err
+			}
+			children = items
+		}
+	}
+
+	values := make([]map[string]interface{}, 0, len(children))
+	for _, c := range children {
+		if c.State != item.StateDefault {
+			continue
+		}
+		fields := c.Fields()
+		if def.Filter != "" && !NewJabEngine().RunExpEvaluator(ctx, j.DB, j.SDB, accountID, def.Filter, fields) {
+			continue
+		}
+		values = append(values, fields)
+	}
+	return def.Aggregate(values), nil
+}
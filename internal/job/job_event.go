@@ -0,0 +1,18 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/event"
+)
+
+// PruneEventReceipts forgets the ids of the events older than the dedup window.
+func (j *Job) PruneEventReceipts() error {
+	if err := event.PruneReceipts(context.Background(), j.DB, time.Now().Add(-event.DedupWindow)); err != nil {
+		log.Println("***>***> PruneEventReceipts: unexpected/unhandled error occurred when deleting the event receipts. error:", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/health"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
+)
+
+// eventHealthScore scores all the items of the model, when it is created or asked to.
+func (j *Job) eventHealthScore(m *stream.Message) error {
+	log.Println("***>***> Reached EventHealthScore ***<***<")
+	ctx := context.Background()
+
+	modelID, _ := m.Meta["model_id"].(string)
+	hm, err := health.RetrieveModel(ctx, j.DB, m.AccountID, modelID)
+	if err != nil {
+		log.Println("***>***> EventHealthScore: unexpected/unhandled error occurred when retriving the health model. error:", err)
+		return err
+	}
+	return j.scoreModel(ctx, hm)
+}
+
+// ScoreHealth scores the items of all the health models. The usage of the items and the due by of their tasks
+// move without the items being updated, so the job update path alone cannot catch them.
+func (j *Job) ScoreHealth() error {
+	ctx := context.Background()
+	models, err := health.AllModels(ctx, j.DB)
+	if err != nil {
+		log.Println("***>***> ScoreHealth: unexpected/unhandled error occurred when retriving the health models. error:", err)
+		return err
+	}
+
+	for _, hm := range models {
+		if err := j.scoreModel(ctx, hm); err != nil {
+			log.Println("***>***> ScoreHealth: unexpected/unhandled error occurred when scoring the model. error:", err)
+		}
+	}
+	return nil
+}
+
+// scoreModel scores the items of the entity of the model page by page.
+func (j *Job) scoreModel(ctx context.Context, hm health.Model) error {
+	e, err := entity.Retrieve(ctx, hm.AccountID, hm.EntityID, j.DB, j.SDB)
+	if err != nil {
+		return err
+	}
+	afterID := ""
+	for {
+		items, err := item.Page(ctx, hm.AccountID, hm.EntityID, afterID, sweepBatch, j.DB)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, it := range items {
+			if err := j.scoreItem(ctx, hm, e, it); err != nil {
+				log.Printf("***> health of the item %s by the model %s not scored. error: %v\n", it.ID, hm.ID, err)
+			}
+		}
+		afterID = items[len(items)-1].ID
+	}
+}
+
+// actOnHealth scores the item again when its entity is scored, and the items its tickets or tasks refer to
+// when the entity is a component of the models. The new fields are nil when the item is deleted.
+func (j *Job) actOnHealth(ctx context.Context, e entity.Entity, it item.Item, oldFields, newFields map[string]interface{}) error {
+	models, err := health.AccountModels(ctx, j.DB, e.AccountID)
+	if err != nil {
+		return err
+	}
+
+	for _, hm := range models {
+		ids := hm.Referred(e.ID, oldFields, newFields)
+		if hm.EntityID == e.ID && newFields != nil {
+			ids = append(ids, it.ID)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		scored, err := entity.Retrieve(ctx, hm.AccountID, hm.EntityID, j.DB, j.SDB)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			// retrieved again as the formulas and the rollups could have changed it after the caller read it.
+			target, err := item.Retrieve(ctx, hm.AccountID, hm.EntityID, id, j.DB)
+			if err != nil {
+				log.Printf("***> health item %s of the model %s not found. error: %v\n", id, hm.ID, err)
+				continue
+			}
+			if err := j.scoreItem(ctx, hm, scored, target); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// scoreItem writes the score and the health of the item and keeps them in its history, only when they changed.
+// The health moving runs the update flows of the entity, such as alerting the owner when it turns critical.
+func (j *Job) scoreItem(ctx context.Context, hm health.Model, e entity.Entity, it item.Item) error {
+	now := time.Now()
+	eng := engine.Engine{
+		Job: j,
+	}
+	parts, err := health.Compute(ctx, j.DB, j.SDB, eng, hm, it, now)
+	if err != nil {
+		return err
+	}
+	score, ok := health.Combine(parts)
+	if !ok {
+		return nil
+	}
+
+	oldFields := it.Fields()
+	bucket := hm.Bucket(score)
+	if fmt.Sprint(oldFields[hm.ScoreKey]) == fmt.Sprint(score) && oldFields[hm.BucketKey] == bucket {
+		return nil
+	}
+	if _, err := health.Record(ctx, j.DB, hm, it.ID, score, parts, now); err != nil {
+		return err
+	}
+
+	newFields := it.Fields()
+	newFields[hm.ScoreKey] = score
+	newFields[hm.BucketKey] = bucket
+	// only the score and the health are merged, the item could have been changed after it was read.
+	values := map[string]interface{}{hm.ScoreKey: score, hm.BucketKey: bucket}
+	if err := j.saveComputed(ctx, e, it.ID, values); err != nil {
+		return err
+	}
+	if oldFields[hm.BucketKey] == bucket {
+		return nil
+	}
+	return j.actOnWorkflows(ctx, e, it.ID, oldFields, newFields, j.DB, j.SDB)
+}
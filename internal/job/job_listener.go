@@ -14,7 +14,9 @@ import (
 	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
 	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/sla"
 	"gitlab.com/vjsideprojects/relay/internal/team"
+	"gitlab.com/vjsideprojects/relay/internal/user"
 )
 
 const (
@@ -196,3 +198,20 @@ func zadd(c redis.Conn, key string, whenMilli int64, raw string) error {
 	}
 	return nil
 }
+
+// RunSLAListener sweeps the running sla timers every minute and streams the breach/near-breach events.
+func (l Listener) RunSLAListener(db *sqlx.DB, sdb *database.SecDB, fbSDKPath string) {
+	ctx := context.Background()
+	for {
+		log.Println("internalRunSLAListener: Sweeping...")
+		events, err := sla.Sweep(ctx, db, time.Now())
+		if err != nil {
+			log.Println("***> unexpected error occurred in RunSLAListener when sweeping the timers. error: ", err)
+		}
+		for _, ev := range events {
+			meta := map[string]interface{}{"metric": ev.Metric, "status": ev.Status}
+			go NewJob(db, sdb, fbSDKPath).Stream(stream.NewSLAMessage(ctx, db, ev.AccountID, user.UUID_ENGINE_USER, ev.EntityID, ev.ItemID, meta))
+		}
+		time.Sleep(60 * time.Second)
+	}
+}
@@ -0,0 +1,70 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/metric"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+)
+
+// eventMetricBackfill counts the items already in the entity so the charts could read its metrics.
+func (j *Job) eventMetricBackfill(m *stream.Message) error {
+	log.Println("***>***> Reached EventMetricBackfill ***<***<")
+	ctx := context.Background()
+
+	e, err := entity.Retrieve(ctx, m.AccountID, m.EntityID, j.DB, j.SDB)
+	if err != nil {
+		log.Println("***>***> EventMetricBackfill: unexpected/unhandled error occurred when retriving entity on job. error:", err)
+		return err
+	}
+	b, err := metric.Backfill(ctx, j.DB, e, time.Now())
+	if err != nil {
+		log.Println("***>***> EventMetricBackfill: unexpected/unhandled error occurred on backfill. error:", err)
+		return err
+	}
+	log.Printf("internal.job EventMetricBackfill: counted %d items of the entity %s\n", b.Items, e.ID)
+	return nil
+}
+
+// ReconcileMetrics recounts the metrics of all the entities built, correcting the counts the worker
+// got wrong by the events lost or replayed.
+func (j *Job) ReconcileMetrics() error {
+	ctx := context.Background()
+	builds, err := metric.Builds(ctx, j.DB)
+	if err != nil {
+		log.Println("***>***> ReconcileMetrics: unexpected/unhandled error occurred when retriving the metric builds. error:", err)
+		return err
+	}
+
+	for _, b := range builds {
+		if err := j.reconcileMetrics(ctx, b.AccountID, b.EntityID); err != nil {
+			log.Println("***>***> ReconcileMetrics: unexpected/unhandled error occurred when reconciling the entity. error:", err)
+		}
+	}
+	return nil
+}
+
+// reconcileMetrics recounts the metrics of the entity when they are built.
+func (j *Job) reconcileMetrics(ctx context.Context, accountID, entityID string) error {
+	if _, err := metric.Retrieve(ctx, j.DB, accountID, entityID); err != nil {
+		if err == metric.ErrNotBuilt {
+			return nil
+		}
+		return err
+	}
+	e, err := entity.Retrieve(ctx, accountID, entityID, j.DB, j.SDB)
+	if err != nil {
+		return err
+	}
+	b, err := metric.Reconcile(ctx, j.DB, e, time.Now())
+	if err != nil {
+		return err
+	}
+	if b.Drift > 0 {
+		log.Printf("internal.job reconcileMetrics: corrected %d points of the entity %s\n", b.Drift, e.ID)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/migration"
+	"gitlab.com/vjsideprojects/relay/internal/platform/graphdb"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+)
+
+// migrationBatch is the number of items migrated between the progress updates.
+const migrationBatch = 100
+
+// eventFieldMigration backfills the values of the items after the fields of the entity are removed or retyped.
+// The values which cannot be converted are cleared and counted as failed. The graph nodes get the converted
+// values and the properties of the removed fields are dropped from the graph at the end.
+func (j *Job) eventFieldMigration(m *stream.Message) error {
+	log.Println("***>***> Reached EventFieldMigration ***<***<")
+	ctx := context.Background()
+
+	migrationID, _ := m.Meta["migration_id"].(string)
+	mig, err := migration.Retrieve(ctx, j.DB, m.AccountID, migrationID)
+	if err != nil {
+		log.Println("***>***> EventFieldMigration: unexpected/unhandled error occurred when retriving migration on job. error:", err)
+		return err
+	}
+	if mig.Status == migration.StatusDone {
+		return nil
+	}
+
+	changes := mig.Changes()
+	refs, err := migration.References(ctx, j.DB, j.SDB, m.AccountID, changes)
+	if err != nil {
+		return j.failMigration(ctx, mig, 0, 0, err)
+	}
+
+	done, failed := 0, 0
+	afterID := ""
+	for {
+		items, err := item.WithFields(ctx, m.AccountID, m.EntityID, migration.Keys(changes), afterID, migrationBatch, j.DB)
+		if err != nil {
+			return j.failMigration(ctx, mig, done, failed, err)
+		}
+		if len(items) == 0 {
+			break
+		}
+
+		for _, it := range items {
+			fields, previews := migration.Apply(it.Fields(), changes, refs)
+			if _, err := item.UpdateFields(ctx, j.DB, m.AccountID, m.EntityID, it.ID, fields); err != nil {
+				return j.failMigration(ctx, mig, done, failed, err)
+			}
+			for _, p := range previews {
+				if p.Error != "" {
+					failed++
+					break
+				}
+			}
+			if err := j.migrateGraphNode(m.AccountID, m.EntityID, it.ID, fields, changes); err != nil {
+				log.Println("***>***> EventFieldMigration: unexpected/unhandled error occurred when updating the graph node. error:", err)
+			}
+			done++
+		}
+		afterID = items[len(items)-1].ID
+		migration.UpdateProgress(ctx, j.DB, mig.ID, migration.StatusRunning, done, failed, nil, time.Now())
+	}
+
+	removedKeys := make([]string, 0)
+	for _, c := range changes {
+		if c.Op != migration.OpRemove {
+			continue
+		}
+		removedKeys = append(removedKeys, c.Key)
+		if c.From.DataType == entity.TypeList {
+			if err := graphdb.DeleteLabel(j.SDB.GraphPool(), m.AccountID, c.Key); err != nil {
+				log.Println("***>***> EventFieldMigration: unexpected/unhandled error occurred when deleting the list nodes. error:", err)
+			}
+		}
+	}
+	if err := graphdb.RemoveProperties(j.SDB.GraphPool(), m.AccountID, m.EntityID, removedKeys); err != nil {
+		log.Println("***>***> EventFieldMigration: unexpected/unhandled error occurred when removing the graph properties. error:", err)
+	}
+
+	// the values moved without the update events, so the metrics are recounted
+	if err := j.reconcileMetrics(ctx, m.AccountID, m.EntityID); err != nil {
+		log.Println("***>***> EventFieldMigration: unexpected/unhandled error occurred when reconciling the metrics. error:", err)
+	}
+
+	return migration.UpdateProgress(ctx, j.DB, mig.ID, migration.StatusDone, done, failed, nil, time.Now())
+}
+
+// migrateGraphNode sets the converted values of the retyped fields on the graph node of the item.
+func (j *Job) migrateGraphNode(accountID, entityID, itemID string, fields map[string]interface{}, changes []migration.FieldChange) error {
+	valueAddedFields := make([]entity.Field, 0)
+	for _, c := range changes {
+		if c.Op != migration.OpRetype {
+			continue
+		}
+		f := *c.To
+		// the list and the reference fields need the field of the element to form the edges
+		if (f.IsList() || f.IsReference()) && f.Field == nil {
+			continue
+		}
+		f.Value = fields[c.Key]
+		valueAddedFields = append(valueAddedFields, f)
+	}
+	if len(valueAddedFields) == 0 {
+		return nil
+	}
+	gpbNode := graphdb.BuildGNode(accountID, entityID, false, nil).MakeBaseGNode(itemID, makeGraphFields(valueAddedFields))
+	return graphdb.UpsertNode(j.SDB.GraphPool(), gpbNode)
+}
+
+func (j *Job) failMigration(ctx context.Context, mig migration.Migration, done, failed int, err error) error {
+	log.Println("***>***> EventFieldMigration: unexpected/unhandled error occurred on backfill. error:", err)
+	errMsg := err.Error()
+	migration.UpdateProgress(ctx, j.DB, mig.ID, migration.StatusFailed, done, failed, &errMsg, time.Now())
+	return err
+}
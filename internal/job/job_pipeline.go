@@ -0,0 +1,42 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/pipeline"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+)
+
+// SnapshotForecasts keeps the forecast of every pipeline for the current week in the calendar of its account.
+// Running it again in the week overwrites the snapshot, so the week keeps how it ended.
+func (j *Job) SnapshotForecasts() error {
+	ctx := context.Background()
+	pipelines, err := flow.Pipelines(ctx, j.DB)
+	if err != nil {
+		log.Println("***>***> SnapshotForecasts: unexpected/unhandled error occurred when retriving the pipelines. error:", err)
+		return err
+	}
+
+	now := time.Now()
+	settings := make(map[string]timerange.Settings)
+	for _, f := range pipelines {
+		s, ok := settings[f.AccountID]
+		if !ok {
+			s, err = timerange.Load(ctx, j.DB, f.AccountID, "", time.UTC)
+			if err != nil {
+				log.Printf("***> calendar of the account %s not loaded. error: %v\n", f.AccountID, err)
+				continue
+			}
+			settings[f.AccountID] = s
+		}
+		err := pipeline.TakeSnapshot(ctx, j.DB, j.SDB, f, s, now)
+		if err != nil && errors.Cause(err) != pipeline.ErrNoAmount { // nothing to forecast in the pipelines without the amount
+			log.Printf("***> forecast of the pipeline %s not snapshotted. error: %v\n", f.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+)
+
+// sweepBatch is the number of items evaluated in one go while triggering the flows on all the items.
+const sweepBatch = 100
+
+// RunDueSchedules triggers the scheduled flows whose run is due on the items of their entity.
+// The once/all-time state of the flow decides whether an item runs the flow on every run or only on the first.
+func (j *Job) RunDueSchedules(now time.Time) error {
+	ctx := context.Background()
+	schedules, err := flow.DueSchedules(ctx, j.DB, now)
+	if err != nil {
+		log.Println("***>***> RunDueSchedules: unexpected/unhandled error occurred when retriving the due schedules. error:", err)
+		return err
+	}
+
+	for _, s := range schedules {
+		if err := s.MarkRun(ctx, j.DB, now); err != nil {
+			if err != flow.ErrScheduleClaimed {
+				log.Println("***>***> RunDueSchedules: unexpected/unhandled error occurred when moving the schedule. error:", err)
+			}
+			continue
+		}
+		f, err := flow.Retrieve(ctx, s.FlowID, j.DB)
+		if err != nil {
+			log.Println("***>***> RunDueSchedules: unexpected/unhandled error occurred when retriving the flow. error:", err)
+			continue
+		}
+		if f.Type != flow.FlowTypeScheduled {
+			continue
+		}
+		log.Printf("internal.job RunDueSchedules: running flow %s next run at %v\n", f.ID, s.NextRunAt)
+		if err := j.triggerOnItems(ctx, f); err != nil {
+			log.Println("***>***> RunDueSchedules: unexpected/unhandled error occurred when triggering the flow. error:", err)
+		}
+	}
+	return nil
+}
+
+// SweepSegments re-evaluates the flows triggered on entering or leaving the segment for all the items.
+// The items matching the date relative segments ("close date before now", "last activity before now-14d")
+// change without the items being updated, so the job update path alone cannot catch them.
+func (j *Job) SweepSegments() error {
+	ctx := context.Background()
+	flows, err := flow.SegmentFlows(ctx, j.DB)
+	if err != nil {
+		log.Println("***>***> SweepSegments: unexpected/unhandled error occurred when retriving the segment flows. error:", err)
+		return err
+	}
+
+	for _, f := range flows {
+		if err := j.triggerOnItems(ctx, f); err != nil {
+			log.Println("***>***> SweepSegments: unexpected/unhandled error occurred when triggering the flow. error:", err)
+		}
+	}
+	return nil
+}
+
+// triggerOnItems runs the flow trigger for every item of the entity of the flow page by page.
+func (j *Job) triggerOnItems(ctx context.Context, f flow.Flow) error {
+	eng := engine.Engine{
+		Job: j,
+	}
+	afterID := ""
+	for {
+		items, err := item.Page(ctx, f.AccountID, f.EntityID, afterID, sweepBatch, j.DB)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, it := range items {
+			flow.Trigger(ctx, j.DB, j.SDB, it.ID, []flow.Flow{f}, eng)
+		}
+		afterID = items[len(items)-1].ID
+	}
+}
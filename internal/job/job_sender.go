@@ -28,18 +28,34 @@ func (j *Job) Stream(message *stream.Message) error {
 	return nil
 }
 
-func queueSQS(message *stream.Message) error {
-	region := util.ExpvarGet("aws_region")
-	queueURL := util.ExpvarGet("aws_worker_sqs_url")
+// StreamBatch streams the messages together, the batches of the events for one. On the queue they go with
+// one session in the batches SQS accepts.
+func (j *Job) StreamBatch(messages []*stream.Message) error {
+	build := util.ExpvarGet("build")
+	if build == "prod" || build == "stage" {
+		if err := queueSQSBatch(messages); err != nil {
+			log.Println("***> unexpected error occurred when queing messages to SQS", err)
+			return err
+		}
+		return nil
+	}
+	for _, message := range messages {
+		if err := j.Post(message); err != nil {
+			log.Println("***> unexpected error occurred when queing message to SQS", err)
+			return err
+		}
+	}
+	return nil
+}
 
-	sess, err := session.NewSession(&aws.Config{
-		Region:   aws.String(region), //TODO: don't hardcode take this param from the ENV
-		Endpoint: aws.String(fmt.Sprintf("https://sqs.%s.amazonaws.com", region)),
-	})
+// sqsBatchSize is the most messages SQS takes in one batch.
+const sqsBatchSize = 10
+
+func queueSQS(message *stream.Message) error {
+	svc, queueURL, err := sqsService()
 	if err != nil {
 		return err
 	}
-	svc := sqs.New(sess)
 
 	// Make message JSON
 	msg, err := json.Marshal(message)
@@ -48,18 +64,76 @@ func queueSQS(message *stream.Message) error {
 	}
 
 	_, err = svc.SendMessage(&sqs.SendMessageInput{
-		MessageAttributes: map[string]*sqs.MessageAttributeValue{
-			"Title": {
-				DataType:    aws.String("String"),
-				StringValue: aws.String(message.ID),
-			},
-			"Action": {
-				DataType:    aws.String("String"),
-				StringValue: aws.String(message.TypeStr()),
-			},
-		},
-		MessageBody: aws.String(string(msg)),
-		QueueUrl:    aws.String(queueURL),
+		MessageAttributes: messageAttributes(message),
+		MessageBody:       aws.String(string(msg)),
+		QueueUrl:          aws.String(queueURL),
 	})
 	return err
 }
+
+func queueSQSBatch(messages []*stream.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	svc, queueURL, err := sqsService()
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(messages); start += sqsBatchSize {
+		end := start + sqsBatchSize
+		if end > len(messages) {
+			end = len(messages)
+		}
+		entries := make([]*sqs.SendMessageBatchRequestEntry, 0, end-start)
+		for i, message := range messages[start:end] {
+			msg, err := json.Marshal(message)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, &sqs.SendMessageBatchRequestEntry{
+				Id:                aws.String(fmt.Sprint(i)),
+				MessageAttributes: messageAttributes(message),
+				MessageBody:       aws.String(string(msg)),
+			})
+		}
+		out, err := svc.SendMessageBatch(&sqs.SendMessageBatchInput{
+			Entries:  entries,
+			QueueUrl: aws.String(queueURL),
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Failed) > 0 {
+			return fmt.Errorf("%d of %d messages not queued. first error: %s", len(out.Failed), len(entries), aws.StringValue(out.Failed[0].Message))
+		}
+	}
+	return nil
+}
+
+func sqsService() (*sqs.SQS, string, error) {
+	region := util.ExpvarGet("aws_region")
+	queueURL := util.ExpvarGet("aws_worker_sqs_url")
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:   aws.String(region), //TODO: don't hardcode take this param from the ENV
+		Endpoint: aws.String(fmt.Sprintf("https://sqs.%s.amazonaws.com", region)),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return sqs.New(sess), queueURL, nil
+}
+
+func messageAttributes(message *stream.Message) map[string]*sqs.MessageAttributeValue {
+	return map[string]*sqs.MessageAttributeValue{
+		"Title": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(message.ID),
+		},
+		"Action": {
+			DataType:    aws.String("String"),
+			StringValue: aws.String(message.TypeStr()),
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
+	"gitlab.com/vjsideprojects/relay/internal/sla"
+)
+
+// eventSLAChanged sets the breach/near-breach status on the sla field of the metric. The field change
+// triggers the eventUpdate workflows of the entity and notifies the assignees/followers.
+func (j *Job) eventSLAChanged(m *stream.Message) error {
+	log.Println("***>***> Reached EventSLAChanged ***<***<")
+	ctx := context.Background()
+
+	e, err := entity.Retrieve(ctx, m.AccountID, m.EntityID, j.DB, j.SDB)
+	if err != nil {
+		log.Println("***>***> EventSLAChanged: unexpected/unhandled error occurred when retriving entity on job. error:", err)
+		return err
+	}
+	it, err := item.Retrieve(ctx, m.AccountID, m.EntityID, m.ItemID, j.DB)
+	if err != nil {
+		log.Println("***>***> EventSLAChanged: unexpected/unhandled error occurred while retriving item on job. error:", err)
+		return err
+	}
+
+	status, _ := m.Meta["status"].(string)
+	metric, _ := m.Meta["metric"].(string)
+	oldFields := it.Fields()
+	newFields := it.Fields()
+
+	ls, _ := stream.Retrieve(ctx, m.AccountID, m.ID, j.DB)
+	m.State = ls.State
+
+	// first response and resolution have their own fields so one does not override the status of the other
+	slaField := sla.StatusField(e, metric)
+	if slaField.Key != "" && m.State < stream.StateWorkflow {
+		newFields[slaField.Key] = status
+		it, err = item.UpdateFields(ctx, j.DB, m.AccountID, m.EntityID, m.ItemID, newFields)
+		if err != nil {
+			log.Println("***>***> EventSLAChanged: unexpected/unhandled error occurred while updating the sla field. error:", err)
+			return err
+		}
+
+		err = j.actOnWorkflows(ctx, e, m.ItemID, oldFields, newFields, j.DB, j.SDB)
+		if err != nil {
+			log.Println("***>***> EventSLAChanged: unexpected/unhandled error occurred on actOnWorkflows. error: ", err)
+			return err
+		}
+		stream.Update(ctx, j.DB, m, "Workflow", stream.StateWorkflow)
+	}
+
+	if m.State < stream.StateNotification {
+		notificationType := notification.TypeSLANearBreach
+		if status == sla.StatusBreached {
+			notificationType = notification.TypeSLABreached
+		}
+		err = j.actOnNotifications(ctx, m.AccountID, m.UserID, e, it, it.UserID, oldFields, newFields, m.Source, notificationType)
+		if err != nil {
+			log.Println("***>***> EventSLAChanged: unexpected/unhandled error occurred on notification. error: ", err)
+			return err
+		}
+		stream.Update(ctx, j.DB, m, "Notification", stream.StateNotification)
+	}
+
+	log.Println("***>***> Completed EventSLAChanged ***<***<")
+	return nil
+}
+
+// actOnSLA starts the sla timers on create and moves them on update. The due by and the sla fields
+// of the item are updated without streaming so the change does not loop back into the job.
+func (j *Job) actOnSLA(ctx context.Context, e entity.Entity, it item.Item, userID string, oldFields, newFields map[string]interface{}) error {
+	now := time.Now()
+	fields := it.Fields()
+	dirty := false
+
+	if oldFields == nil {
+		eng := engine.Engine{
+			Job: j,
+		}
+		due, err := sla.Start(ctx, j.DB, j.SDB, eng, e, it, now)
+		if err != nil || due == nil {
+			return err
+		}
+		if dueByField := e.WhoField(entity.WhoDueBy); dueByField.Key != "" && (fields[dueByField.Key] == nil || fields[dueByField.Key] == "") {
+			fields[dueByField.Key] = util.FormatTimeGo(*due)
+			dirty = true
+		}
+		for _, metric := range []string{sla.MetricFirstResponse, sla.MetricResolution} {
+			if slaField := sla.StatusField(e, metric); slaField.Key != "" {
+				fields[slaField.Key] = sla.StatusOnTrack
+				dirty = true
+			}
+		}
+	} else {
+		statuses, err := sla.Track(ctx, j.DB, j.SDB, e, it, userID, oldFields, newFields, now)
+		if err != nil {
+			return err
+		}
+		for metric, status := range statuses {
+			if slaField := sla.StatusField(e, metric); slaField.Key != "" && fields[slaField.Key] != status {
+				fields[slaField.Key] = status
+				dirty = true
+			}
+		}
+	}
+
+	if !dirty {
+		return nil
+	}
+	_, err := item.UpdateFields(ctx, j.DB, it.AccountID, it.EntityID, it.ID, fields)
+	return err
+}
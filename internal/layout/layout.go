@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -20,7 +21,7 @@ var (
 	ErrInvalidID = errors.New("Layout ID is not in its proper form")
 )
 
-func Create(ctx context.Context, db *sqlx.DB, nl NewLayout, now time.Time) (Layout, error) {
+func Create(ctx context.Context, db database.Conn, nl NewLayout, now time.Time) (Layout, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.layout.Create")
 	defer span.End()
 
@@ -56,6 +57,34 @@ func Create(ctx context.Context, db *sqlx.DB, nl NewLayout, now time.Time) (Layo
 	return l, nil
 }
 
+// List returns the layouts of the entity shared with all the users.
+func List(ctx context.Context, accountID, entityID string, db *sqlx.DB) ([]Layout, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.layout.List")
+	defer span.End()
+
+	layouts := []Layout{}
+	const q = `SELECT * FROM layouts WHERE account_id = $1 AND entity_id = $2 AND user_id IS NULL`
+	if err := db.SelectContext(ctx, &layouts, q, accountID, entityID); err != nil {
+		return nil, errors.Wrap(err, "selecting layouts")
+	}
+
+	return layouts, nil
+}
+
+// Referring returns the layouts of the entity, including the ones of the users, which refer the field.
+func Referring(ctx context.Context, accountID, entityID, key string, db *sqlx.DB) ([]Layout, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.layout.Referring")
+	defer span.End()
+
+	layouts := []Layout{}
+	const q = `SELECT * FROM layouts WHERE account_id = $1 AND entity_id = $2 AND fieldsb::text LIKE $3`
+	if err := db.SelectContext(ctx, &layouts, q, accountID, entityID, "%"+key+"%"); err != nil {
+		return nil, errors.Wrap(err, "selecting layouts referring the field")
+	}
+
+	return layouts, nil
+}
+
 func Retrieve(ctx context.Context, accountID, entityID, name string, db *sqlx.DB) (Layout, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.layout.Retrieve")
 	defer span.End()
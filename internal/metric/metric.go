@@ -0,0 +1,416 @@
+package metric
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotBuilt is used when the metrics of the entity are asked for before they are backfilled.
+	ErrNotBuilt = errors.New("Metrics not built")
+)
+
+// recountBatch is the number of items read in one go while recounting the entity.
+const recountBatch = 500
+
+const dayFormat = "2006-01-02"
+
+// counts are the changes to the points, or the points themselves when counted afresh.
+type counts map[point]int
+
+// Tracked are the fields the items are counted by. The lists and the references have the few values the
+// charts group by, the other fields are left to the live counts.
+func Tracked(fields []entity.Field) []entity.Field {
+	tracked := make([]entity.Field, 0)
+	for _, f := range fields {
+		if f.DataType == entity.TypeList || f.DataType == entity.TypeReference {
+			tracked = append(tracked, f)
+		}
+	}
+	return tracked
+}
+
+// Added counts the item created in the metrics of its entity.
+func Added(ctx context.Context, db *sqlx.DB, e entity.Entity, it item.Item) error {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Added")
+	defer span.End()
+
+	return change(ctx, db, e, it, func(c counts, tracked []entity.Field, day string) {
+		tally(c, tracked, nil, it.Fields(), day, 1)
+	})
+}
+
+// Changed moves the item from the old values to the new values of the fields updated.
+func Changed(ctx context.Context, db *sqlx.DB, e entity.Entity, it item.Item, oldFields, newFields map[string]interface{}) error {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Changed")
+	defer span.End()
+
+	keys := make(map[string]bool, len(newFields))
+	for k := range newFields {
+		keys[k] = true
+	}
+	return change(ctx, db, e, it, func(c counts, tracked []entity.Field, day string) {
+		tally(c, tracked, keys, oldFields, day, -1)
+		tally(c, tracked, keys, newFields, day, 1)
+	})
+}
+
+// Removed takes the item deleted out of the metrics of its entity.
+func Removed(ctx context.Context, db *sqlx.DB, e entity.Entity, it item.Item) error {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Removed")
+	defer span.End()
+
+	return change(ctx, db, e, it, func(c counts, tracked []entity.Field, day string) {
+		tally(c, tracked, nil, it.Fields(), day, -1)
+	})
+}
+
+// Counts are the counts of the items created in the range by the values of the field, the KeyAll counting
+// all of them under the empty value. The zero times leave the range open. The counts are read only when
+// the build is ready and the whole days of its zone can answer the range, which the bool tells.
+func Counts(ctx context.Context, db *sqlx.DB, accountID, entityID, key string, start, end time.Time) (map[string]int, bool, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Counts")
+	defer span.End()
+
+	b, err := Retrieve(ctx, db, accountID, entityID)
+	if err == ErrNotBuilt || (err == nil && !b.Ready) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	from, to, ok := days(start, end, b.location())
+	if !ok {
+		return nil, false, nil
+	}
+
+	var rows []struct {
+		Value string `db:"value"`
+		Count int    `db:"count"`
+	}
+	const q = `SELECT value, sum(count) AS count FROM metrics
+		WHERE account_id = $1 AND entity_id = $2 AND field_key = $3 AND day >= $4 AND day < $5
+		GROUP BY value HAVING sum(count) > 0`
+	if err := db.SelectContext(ctx, &rows, q, accountID, entityID, key, from, to); err != nil {
+		return nil, false, errors.Wrap(err, "selecting metric counts")
+	}
+
+	m := make(map[string]int, len(rows))
+	for _, r := range rows {
+		m[r.Value] = r.Count
+	}
+	return m, true, nil
+}
+
+// Backfill starts the metrics of the entity and counts the items already there. The worker counts the
+// items created meanwhile as the build exists from the start, and the recount corrects the ones counted twice.
+func Backfill(ctx context.Context, db *sqlx.DB, e entity.Entity, now time.Time) (Build, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Backfill")
+	defer span.End()
+
+	zone, err := zoneOf(ctx, db, e.AccountID)
+	if err != nil {
+		return Build{}, err
+	}
+	const q = `INSERT INTO metric_builds (account_id, entity_id, zone, ready, items, drift, created_at)
+		VALUES ($1, $2, $3, false, 0, 0, $4)
+		ON CONFLICT (account_id, entity_id) DO NOTHING`
+	if _, err := db.ExecContext(ctx, q, e.AccountID, e.ID, zone, now.UTC()); err != nil {
+		return Build{}, errors.Wrap(err, "inserting metric build")
+	}
+	return Reconcile(ctx, db, e, now)
+}
+
+// Reconcile recounts the items of the entity and corrects the points drifted, such as by the events the
+// worker lost or replayed. The drift is the number of the points corrected. The points are counted afresh
+// when the zone of the account changed since the build, as the items then fall on the other days.
+// The build stays locked for the whole pass, so the changes of the worker wait for the drift to be applied.
+func Reconcile(ctx context.Context, db *sqlx.DB, e entity.Entity, now time.Time) (Build, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Reconcile")
+	defer span.End()
+
+	zone, err := zoneOf(ctx, db, e.AccountID)
+	if err != nil {
+		return Build{}, err
+	}
+
+	var b Build
+	err = database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		b, err = lock(ctx, tx, e.AccountID, e.ID, "UPDATE")
+		if err != nil {
+			return err
+		}
+		if zone != b.Zone {
+			const q = `UPDATE metric_builds SET zone = $3, ready = false WHERE account_id = $1 AND entity_id = $2`
+			if _, err := tx.ExecContext(ctx, q, e.AccountID, e.ID, zone); err != nil {
+				return errors.Wrap(err, "moving metric build zone")
+			}
+			const d = `DELETE FROM metrics WHERE account_id = $1 AND entity_id = $2`
+			if _, err := tx.ExecContext(ctx, d, e.AccountID, e.ID); err != nil {
+				return errors.Wrap(err, "clearing metrics")
+			}
+			b.Zone = zone
+		}
+
+		want, n, err := recount(ctx, db, e, b.location())
+		if err != nil {
+			return err
+		}
+		have, err := stored(ctx, tx, e.AccountID, e.ID)
+		if err != nil {
+			return err
+		}
+		d := drift(want, have)
+		if err := apply(ctx, tx, e.AccountID, e.ID, d); err != nil {
+			return err
+		}
+		const z = `DELETE FROM metrics WHERE account_id = $1 AND entity_id = $2 AND count = 0`
+		if _, err := tx.ExecContext(ctx, z, e.AccountID, e.ID); err != nil {
+			return errors.Wrap(err, "deleting empty metrics")
+		}
+
+		now = now.UTC()
+		b.Ready, b.Items, b.Drift, b.ReconciledAt = true, n, len(d), &now
+		const q = `UPDATE metric_builds SET ready = $3, items = $4, drift = $5, reconciled_at = $6 WHERE account_id = $1 AND entity_id = $2`
+		if _, err := tx.ExecContext(ctx, q, b.AccountID, b.EntityID, b.Ready, b.Items, b.Drift, b.ReconciledAt); err != nil {
+			return errors.Wrap(err, "updating metric build")
+		}
+		return nil
+	})
+	if err != nil {
+		return Build{}, err
+	}
+	return b, nil
+}
+
+// Retrieve gets the build of the metrics of the entity.
+func Retrieve(ctx context.Context, db *sqlx.DB, accountID, entityID string) (Build, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Retrieve")
+	defer span.End()
+
+	var b Build
+	const q = `SELECT * FROM metric_builds WHERE account_id = $1 AND entity_id = $2`
+	if err := db.GetContext(ctx, &b, q, accountID, entityID); err != nil {
+		if err == sql.ErrNoRows {
+			return Build{}, ErrNotBuilt
+		}
+		return Build{}, errors.Wrapf(err, "selecting metric build of entity %q", entityID)
+	}
+	return b, nil
+}
+
+// Builds are the builds of all the accounts, for the reconcile to go over.
+func Builds(ctx context.Context, db *sqlx.DB) ([]Build, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.metric.Builds")
+	defer span.End()
+
+	builds := []Build{}
+	const q = `SELECT * FROM metric_builds ORDER BY reconciled_at NULLS FIRST`
+	if err := db.SelectContext(ctx, &builds, q); err != nil {
+		return nil, errors.Wrap(err, "selecting metric builds")
+	}
+	return builds, nil
+}
+
+// change applies the counts the fn makes for the item, on the day it was created. The entities not yet
+// built are left alone, the backfill counts their items.
+// The build is locked in the share mode, so the change waits for the reconcile in progress and the
+// changes of the other items do not wait for each other.
+func change(ctx context.Context, db *sqlx.DB, e entity.Entity, it item.Item, fn func(c counts, tracked []entity.Field, day string)) error {
+	return database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+		b, err := lock(ctx, tx, e.AccountID, e.ID, "SHARE")
+		if err == ErrNotBuilt {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c := counts{}
+		fn(c, Tracked(e.EasyFields()), it.CreatedAt.In(b.location()).Format(dayFormat))
+		return apply(ctx, tx, e.AccountID, e.ID, c)
+	})
+}
+
+// lock reads the build of the entity and locks it in the mode, UPDATE or SHARE, till the transaction ends.
+func lock(ctx context.Context, tx *sqlx.Tx, accountID, entityID, mode string) (Build, error) {
+	var b Build
+	q := `SELECT * FROM metric_builds WHERE account_id = $1 AND entity_id = $2 FOR ` + mode
+	if err := tx.GetContext(ctx, &b, q, accountID, entityID); err != nil {
+		if err == sql.ErrNoRows {
+			return Build{}, ErrNotBuilt
+		}
+		return Build{}, errors.Wrapf(err, "locking metric build of entity %q", entityID)
+	}
+	return b, nil
+}
+
+// apply adds the counts to the points in one statement, so the item is counted in all its fields or in none.
+func apply(ctx context.Context, db database.Conn, accountID, entityID string, c counts) error {
+	var keys, values, days []string
+	var ns []int64
+	for p, n := range c {
+		if n == 0 {
+			continue
+		}
+		keys, values, days, ns = append(keys, p.key), append(values, p.value), append(days, p.day), append(ns, int64(n))
+	}
+	if len(ns) == 0 {
+		return nil
+	}
+
+	const q = `INSERT INTO metrics (account_id, entity_id, field_key, value, day, count)
+		SELECT $1, $2, unnest($3::text[]), unnest($4::text[]), unnest($5::date[]), unnest($6::bigint[])
+		ON CONFLICT (account_id, entity_id, field_key, value, day) DO UPDATE SET count = metrics.count + EXCLUDED.count`
+	if _, err := db.ExecContext(ctx, q, accountID, entityID, pq.Array(keys), pq.Array(values), pq.Array(days), pq.Array(ns)); err != nil {
+		return errors.Wrap(err, "upserting metrics")
+	}
+	return nil
+}
+
+// recount counts the points of the items of the entity afresh along with the number of the items.
+func recount(ctx context.Context, db *sqlx.DB, e entity.Entity, loc *time.Location) (counts, int, error) {
+	tracked := Tracked(e.EasyFields())
+	c := counts{}
+	n := 0
+	afterID := ""
+	for {
+		items, err := item.Page(ctx, e.AccountID, e.ID, afterID, recountBatch, db)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(items) == 0 {
+			return c, n, nil
+		}
+		for _, it := range items {
+			tally(c, tracked, nil, it.Fields(), it.CreatedAt.In(loc).Format(dayFormat), 1)
+		}
+		n += len(items)
+		afterID = items[len(items)-1].ID
+	}
+}
+
+// stored are the points of the entity as they are.
+func stored(ctx context.Context, db database.Conn, accountID, entityID string) (counts, error) {
+	points := []Point{}
+	const q = `SELECT account_id, entity_id, field_key, value, to_char(day, 'YYYY-MM-DD') AS day, count FROM metrics
+		WHERE account_id = $1 AND entity_id = $2`
+	if err := db.SelectContext(ctx, &points, q, accountID, entityID); err != nil {
+		return nil, errors.Wrap(err, "selecting metrics")
+	}
+	c := make(counts, len(points))
+	for _, p := range points {
+		c[point{p.Key, p.Value, p.Day}] = p.Count
+	}
+	return c, nil
+}
+
+// tally adds the item to the counts, n being 1 to count it in and -1 to take it out. The keys limit the
+// fields counted, the nil keys counting the item itself under the KeyAll and in all the tracked fields.
+func tally(c counts, tracked []entity.Field, keys map[string]bool, fields map[string]interface{}, day string, n int) {
+	if keys == nil {
+		c[point{KeyAll, "", day}] += n
+	}
+	for _, f := range tracked {
+		if keys != nil && !keys[f.Key] {
+			continue
+		}
+		if v, ok := valueOf(fields[f.Key]); ok {
+			c[point{f.Key, v, day}] += n
+		}
+	}
+}
+
+// drift is what the points stored are off by from the points counted.
+func drift(want, have counts) counts {
+	d := counts{}
+	for p, n := range want {
+		if n != have[p] {
+			d[p] = n - have[p]
+		}
+	}
+	for p, n := range have {
+		if _, ok := want[p]; !ok && n != 0 {
+			d[p] = -n
+		}
+	}
+	return d
+}
+
+// valueOf is the value the item is counted by in the field. The lists are counted by their first value
+// as the live counts group them, so the charts read the same either way.
+func valueOf(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case []interface{}:
+		if len(v) == 0 {
+			return "", false
+		}
+		return valueOf(v[0])
+	case string:
+		return v, v != ""
+	default:
+		return fmt.Sprint(v), true
+	}
+}
+
+// days are the dates from and before which the points answer the range. The range not starting at the
+// midnight is widened to the whole day only when it is long enough for the day not to matter, while the
+// range ending during the day takes that day in as the items after now are not there yet.
+func days(start, end time.Time, loc *time.Location) (string, string, bool) {
+	from, to := "0001-01-01", "9999-12-31"
+	if !start.IsZero() {
+		s := start.In(loc)
+		if !s.Equal(midnight(s)) && !end.IsZero() && end.Sub(start) < MinWidened {
+			return "", "", false
+		}
+		from = s.Format(dayFormat)
+	}
+	if !end.IsZero() {
+		e := end.In(loc)
+		last := midnight(e)
+		if !e.Equal(last) {
+			last = last.AddDate(0, 0, 1)
+		}
+		to = last.Format(dayFormat)
+	}
+	return from, to, true
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// zoneOf is the timezone of the account the days are counted in, UTC when it has none or an unknown one.
+func zoneOf(ctx context.Context, db *sqlx.DB, accountID string) (string, error) {
+	a, err := account.Retrieve(ctx, db, accountID)
+	if err != nil {
+		return "", err
+	}
+	if a.TimeZone == nil {
+		return "UTC", nil
+	}
+	if _, err := time.LoadLocation(*a.TimeZone); err != nil {
+		return "UTC", nil
+	}
+	return *a.TimeZone, nil
+}
+
+func (b Build) location() *time.Location {
+	loc, err := time.LoadLocation(b.Zone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
@@ -0,0 +1,93 @@
+package metric
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+var dealFields = []entity.Field{
+	{Key: "name", DataType: entity.TypeString},
+	{Key: "stage", DataType: entity.TypeList},
+	{Key: "owner", DataType: entity.TypeReference, RefID: "users"},
+	{Key: "amount", DataType: entity.TypeNumber},
+}
+
+func TestTally(t *testing.T) {
+	tracked := Tracked(dealFields)
+	deal := map[string]interface{}{"name": "Acme", "stage": []interface{}{"won", "lost"}, "owner": []interface{}{}, "amount": 100.0}
+
+	t.Log("Given the need to count the deals as they are created, updated and deleted")
+	{
+		if len(tracked) != 2 || tracked[0].Key != "stage" || tracked[1].Key != "owner" {
+			t.Fatalf("\t%s should track the lists and the references : %+v", tests.Failed, tracked)
+		}
+		t.Logf("\t%s should track the lists and the references", tests.Success)
+
+		c := counts{}
+		tally(c, tracked, nil, deal, "2024-03-10", 1)
+		if len(c) != 2 || c[point{KeyAll, "", "2024-03-10"}] != 1 || c[point{"stage", "won", "2024-03-10"}] != 1 {
+			t.Fatalf("\t%s should count the deal by its first stage and skip the empty owner : %+v", tests.Failed, c)
+		}
+		t.Logf("\t%s should count the deal by its first stage and skip the empty owner", tests.Success)
+
+		keys := map[string]bool{"stage": true, "name": true}
+		tally(c, tracked, keys, map[string]interface{}{"stage": []interface{}{"won"}}, "2024-03-10", -1)
+		tally(c, tracked, keys, map[string]interface{}{"stage": []interface{}{"lost"}, "name": "Acme Inc"}, "2024-03-10", 1)
+		if c[point{KeyAll, "", "2024-03-10"}] != 1 || c[point{"stage", "won", "2024-03-10"}] != 0 || c[point{"stage", "lost", "2024-03-10"}] != 1 {
+			t.Fatalf("\t%s should move the deal to the new stage : %+v", tests.Failed, c)
+		}
+		t.Logf("\t%s should move the deal to the new stage", tests.Success)
+
+		have := counts{{KeyAll, "", "2024-03-10"}: 2, {"stage", "won", "2024-03-10"}: 1, {"stage", "lost", "2024-03-09"}: 0}
+		d := drift(c, have)
+		if len(d) != 3 || d[point{KeyAll, "", "2024-03-10"}] != -1 || d[point{"stage", "won", "2024-03-10"}] != -1 || d[point{"stage", "lost", "2024-03-10"}] != 1 {
+			t.Fatalf("\t%s should correct the points drifted : %+v", tests.Failed, d)
+		}
+		t.Logf("\t%s should correct the points drifted", tests.Success)
+	}
+}
+
+func TestDays(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no zone info : ", err)
+	}
+
+	tt := []struct {
+		start, end string
+		from, to   string
+		ok         bool
+		about      string
+	}{
+		{"2024-03-01T05:00:00Z", "2024-03-11T16:00:00Z", "2024-03-01", "2024-03-12", true, "take the day ending now in"},
+		{"2024-03-10T05:00:00Z", "2024-03-11T04:00:00Z", "2024-03-10", "2024-03-11", true, "answer the day between the midnights of the zone"},
+		{"2024-03-11T10:00:00Z", "2024-03-11T16:00:00Z", "", "", false, "leave the hours to the live counts"},
+		{"2024-03-01T12:00:00Z", "2024-03-11T16:00:00Z", "2024-03-01", "2024-03-12", true, "widen the long ranges to the whole days"},
+		{"", "", "0001-01-01", "9999-12-31", true, "leave the range open"},
+	}
+
+	t.Log("Given the need to answer the ranges with the days of the zone")
+	{
+		for _, tc := range tt {
+			var start, end time.Time
+			if tc.start != "" {
+				start, end = at(tc.start), at(tc.end)
+			}
+			from, to, ok := days(start, end, newYork)
+			if ok != tc.ok || from != tc.from || to != tc.to {
+				t.Fatalf("\t%s should %s : got %s %s %v", tests.Failed, tc.about, from, to, ok)
+			}
+			t.Logf("\t%s should %s", tests.Success, tc.about)
+		}
+	}
+}
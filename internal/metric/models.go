@@ -0,0 +1,41 @@
+package metric
+
+import "time"
+
+// KeyAll is the key of the points counting all the items of the entity.
+const KeyAll = ""
+
+// MinWidened is the shortest range the metrics widen to the whole days. The shorter ranges not starting
+// at the midnight of the zone are counted live, as the day the range starts in would count too much.
+const MinWidened = 7 * 24 * time.Hour
+
+// Point is the count of the items of the entity created on the day with the value in the field.
+// The day is the date in the zone of the build.
+type Point struct {
+	AccountID string `db:"account_id" json:"account_id"`
+	EntityID  string `db:"entity_id" json:"entity_id"`
+	Key       string `db:"field_key" json:"key"`
+	Value     string `db:"value" json:"value"`
+	Day       string `db:"day" json:"day"`
+	Count     int    `db:"count" json:"count"`
+}
+
+// Build is the state of the metrics of the entity. The metrics are read for the charts only once ready,
+// that is after the backfill counted the items already there.
+type Build struct {
+	AccountID    string     `db:"account_id" json:"account_id"`
+	EntityID     string     `db:"entity_id" json:"entity_id"`
+	Zone         string     `db:"zone" json:"zone"`
+	Ready        bool       `db:"ready" json:"ready"`
+	Items        int        `db:"items" json:"items"`
+	Drift        int        `db:"drift" json:"drift"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	ReconciledAt *time.Time `db:"reconciled_at" json:"reconciled_at"`
+}
+
+// point is the identity of the point without its count.
+type point struct {
+	key   string
+	value string
+	day   string
+}
@@ -0,0 +1,200 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when the member has not set up the two-factor.
+	ErrNotFound = errors.New("Two-factor is not set up")
+	// ErrAlreadyEnabled is used when the two-factor is set up again without disabling it.
+	ErrAlreadyEnabled = errors.New("Two-factor is already enabled")
+	// ErrInvalidCode is used when neither the TOTP code nor the recovery code matches.
+	ErrInvalidCode = errors.New("Two-factor code is not valid")
+)
+
+// recoveryCount is the number of recovery codes given when the two-factor is enabled.
+const recoveryCount = 10
+
+// Setup generates the secret for the member. The two-factor is enabled only after
+// the member confirms a code from the authenticator app.
+func Setup(ctx context.Context, db *sqlx.DB, email string, now time.Time) (MFA, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Setup")
+	defer span.End()
+
+	existing, err := Retrieve(ctx, db, email)
+	if err == nil && existing.Enabled {
+		return MFA{}, ErrAlreadyEnabled
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return MFA{}, errors.Wrap(err, "generating two-factor secret")
+	}
+
+	m := MFA{
+		Email:         normalize(email),
+		Secret:        secret,
+		Enabled:       false,
+		RecoveryCodes: []string{},
+		CreatedAt:     now.UTC(),
+		UpdatedAt:     now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO user_mfa
+		(email, secret, enabled, recovery_codes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (email) DO UPDATE SET secret = $2, enabled = $3, recovery_codes = $4, updated_at = $6`
+	_, err = db.ExecContext(ctx, q, m.Email, m.Secret, m.Enabled, m.RecoveryCodes, m.CreatedAt, m.UpdatedAt)
+	if err != nil {
+		return MFA{}, errors.Wrap(err, "inserting two-factor")
+	}
+
+	return m, nil
+}
+
+// Enable turns on the two-factor once the code from the authenticator app matches. It returns
+// the recovery codes which are shown to the member only this once.
+func Enable(ctx context.Context, db *sqlx.DB, email, code string, now time.Time) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Enable")
+	defer span.End()
+
+	m, err := Retrieve(ctx, db, email)
+	if err != nil {
+		return nil, err
+	}
+	if m.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	if !Valid(m.Secret, code, now) {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := recoveryCodes()
+	if err != nil {
+		return nil, errors.Wrap(err, "generating recovery codes")
+	}
+
+	const q = `UPDATE user_mfa SET enabled = TRUE, recovery_codes = $2, updated_at = $3 WHERE email = $1`
+	if _, err := db.ExecContext(ctx, q, m.Email, hashes, now.UTC().Unix()); err != nil {
+		return nil, errors.Wrap(err, "enabling two-factor")
+	}
+
+	return codes, nil
+}
+
+// Disable turns off the two-factor. The member should prove the access with a valid code.
+func Disable(ctx context.Context, db *sqlx.DB, email, code string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Disable")
+	defer span.End()
+
+	if err := Verify(ctx, db, email, code, now); err != nil {
+		return err
+	}
+
+	const q = `DELETE FROM user_mfa WHERE email = $1`
+	if _, err := db.ExecContext(ctx, q, normalize(email)); err != nil {
+		return errors.Wrap(err, "disabling two-factor")
+	}
+
+	return nil
+}
+
+// Verify checks the code entered while signing in. A recovery code works only once.
+func Verify(ctx context.Context, db *sqlx.DB, email, code string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Verify")
+	defer span.End()
+
+	m, err := Retrieve(ctx, db, email)
+	if err != nil {
+		return err
+	}
+	if !m.Enabled {
+		return ErrNotFound
+	}
+	if Valid(m.Secret, code, now) {
+		return nil
+	}
+
+	hash := hashCode(code)
+	for i, h := range m.RecoveryCodes {
+		if h != hash {
+			continue
+		}
+		remaining := append(pq.StringArray{}, m.RecoveryCodes[:i]...)
+		remaining = append(remaining, m.RecoveryCodes[i+1:]...)
+		const q = `UPDATE user_mfa SET recovery_codes = $2, updated_at = $3 WHERE email = $1`
+		if _, err := db.ExecContext(ctx, q, m.Email, remaining, now.UTC().Unix()); err != nil {
+			return errors.Wrap(err, "using recovery code")
+		}
+		return nil
+	}
+
+	return ErrInvalidCode
+}
+
+// Enabled returns true if the member signs in with the two-factor.
+func Enabled(ctx context.Context, db *sqlx.DB, email string) (bool, error) {
+	m, err := Retrieve(ctx, db, email)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.Enabled, nil
+}
+
+// Retrieve gets the two-factor setting of the member.
+func Retrieve(ctx context.Context, db *sqlx.DB, email string) (MFA, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Retrieve")
+	defer span.End()
+
+	var m MFA
+	const q = `SELECT * FROM user_mfa WHERE email = $1`
+	if err := db.GetContext(ctx, &m, q, normalize(email)); err != nil {
+		if err == sql.ErrNoRows {
+			return MFA{}, ErrNotFound
+		}
+		return MFA{}, errors.Wrap(err, "selecting two-factor")
+	}
+
+	return m, nil
+}
+
+// recoveryCodes returns the codes along with their hashes which are stored in place of the codes.
+func recoveryCodes() ([]string, pq.StringArray, error) {
+	codes := make([]string, recoveryCount)
+	hashes := make(pq.StringArray, recoveryCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		c := strings.ToLower(hex.EncodeToString(b))
+		codes[i] = c[:5] + "-" + c[5:]
+		hashes[i] = hashCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+func hashCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
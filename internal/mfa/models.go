@@ -0,0 +1,23 @@
+package mfa
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MFA is the TOTP two-factor setting of the member. It is kept by the email as the member
+// signs in once with the email and then picks one of the accounts.
+type MFA struct {
+	Email         string         `db:"email" json:"email"`
+	Secret        string         `db:"secret" json:"-"`
+	Enabled       bool           `db:"enabled" json:"enabled"`
+	RecoveryCodes pq.StringArray `db:"recovery_codes" json:"-"`
+	CreatedAt     time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt     int64          `db:"updated_at" json:"updated_at"`
+}
+
+// Verification is the TOTP code or one of the recovery codes entered by the member.
+type Verification struct {
+	Code string `json:"code" validate:"required"`
+}
@@ -0,0 +1,83 @@
+package mfa
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// period is the life of the code in seconds as used by the authenticator apps.
+	period = 30
+	digits = 6
+	// skew is the number of periods before/after the current one which are accepted as well.
+	skew = 1
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret generates the random secret shared with the authenticator app.
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// KeyURI is the otpauth uri which the authenticator apps read from the QR code.
+func KeyURI(issuer, email, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("period", fmt.Sprint(period))
+	v.Set("digits", fmt.Sprint(digits))
+	return fmt.Sprintf("otpauth://totp/%s:%s?%s", url.PathEscape(issuer), url.PathEscape(email), v.Encode())
+}
+
+// Code returns the TOTP code (RFC 6238) of the secret at the time.
+func Code(secret string, t time.Time) (string, error) {
+	return code(secret, uint64(t.Unix()/period))
+}
+
+// Valid checks the code against the secret allowing the clock drift of the phone.
+func Valid(secret, c string, t time.Time) bool {
+	c = strings.TrimSpace(c)
+	if len(c) != digits {
+		return false
+	}
+	counter := t.Unix() / period
+	for i := -skew; i <= skew; i++ {
+		expected, err := code(secret, uint64(counter+int64(i)))
+		if err != nil {
+			return false
+		}
+		if hmac.Equal([]byte(expected), []byte(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+func code(secret string, counter uint64) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+	h := hmac.New(sha1.New, key)
+	h.Write(msg)
+	sum := h.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1000000), nil
+}
@@ -0,0 +1,77 @@
+package mfa_test
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/mfa"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+// rfcSecret is the base32 of the SHA1 secret "12345678901234567890" used by the test vectors of RFC 6238.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	t.Log("Given the need to generate the TOTP codes")
+	{
+		cases := []struct {
+			unix int64
+			want string
+		}{
+			{59, "287082"},
+			{1111111109, "081804"},
+			{1111111111, "050471"},
+			{1234567890, "005924"},
+			{2000000000, "279037"},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the code is generated at %d", c.unix)
+			{
+				got, err := mfa.Code(rfcSecret, time.Unix(c.unix, 0))
+				if err != nil {
+					t.Fatalf("\t%s should generate the code : %s", tests.Failed, err)
+				}
+				if got != c.want {
+					t.Fatalf("\t%s should match the RFC vector %s. got %s", tests.Failed, c.want, got)
+				}
+				t.Logf("\t%s should match the RFC vector", tests.Success)
+			}
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	t.Log("Given the need to validate the TOTP codes entered by the member")
+	{
+		secret, err := mfa.NewSecret()
+		if err != nil {
+			t.Fatalf("\t%s should generate the secret : %s", tests.Failed, err)
+		}
+		now := time.Unix(1600000015, 0)
+		current, _ := mfa.Code(secret, now)
+		previous, _ := mfa.Code(secret, now.Add(-30*time.Second))
+		stale, _ := mfa.Code(secret, now.Add(-2*time.Minute))
+
+		cases := []struct {
+			name string
+			code string
+			want bool
+		}{
+			{"current code", current, true},
+			{"previous code within the drift", previous, true},
+			{"code with spaces", " " + current + " ", true},
+			{"stale code", stale, false},
+			{"short code", current[:5], false},
+			{"empty code", "", false},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the %s is entered", c.name)
+			{
+				if got := mfa.Valid(secret, c.code, now); got != c.want {
+					t.Fatalf("\t%s should be valid %v. got %v", tests.Failed, c.want, got)
+				}
+				t.Logf("\t%s should be valid %v", tests.Success, c.want)
+			}
+		}
+	}
+}
@@ -18,6 +18,10 @@ func HasSeat(db *sqlx.DB) web.Middleware {
 	f := func(after web.Handler) web.Handler {
 
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			if web.Describe(ctx, func(*web.Route) {}) {
+				return after(ctx, w, r, params)
+			}
+
 			ctx, span := trace.StartSpan(ctx, "internal.mid.HasSeat")
 			defer span.End()
 
@@ -0,0 +1,237 @@
+package mid
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/ratelimit"
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// ErrTooManyRequests is returned when the caller or the account has used up its limit.
+var ErrTooManyRequests = web.NewRequestError(
+	errors.New("rate_limit_exceeded"), // value used in the UI dont change the string message.
+	http.StatusTooManyRequests,
+)
+
+// identityTTL is how long the account of the API key, the membership of the user and the plan of
+// the account are cached so the limiter does not hit the database on every request.
+const identityTTL = time.Minute
+
+// RateLimit throttles the requests with the limit of the route group and the quota of the account plan.
+// The public groups are limited per IP, the rest per API key or per user. The account quota is taken
+// only when the caller belongs to the account. It runs before the route middleware, so the token is
+// verified here without rejecting the request. The limiter lets the requests through when the store fails.
+func RateLimit(log *log.Logger, cfg ratelimit.Config, authenticator *auth.Authenticator, db *sqlx.DB) web.Middleware {
+	cache := newTTLCache(identityTTL)
+
+	// This is the actual middleware function to be executed.
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			if cfg.Store == nil || r.Method == http.MethodOptions {
+				return after(ctx, w, r, params)
+			}
+
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RateLimit")
+			defer span.End()
+
+			group := ratelimit.Group(r.Method, r.URL.Path)
+			key, accountID := "ip:"+group+":"+clientIP(r, cfg.TrustedHops), ""
+			if !ratelimit.IsPublic(group) {
+				key, accountID = callerKey(ctx, cache, authenticator, db, r, params, cfg.TrustedHops)
+			}
+
+			now := time.Now()
+			results := make([]ratelimit.Result, 0, 2)
+			if l, ok := cfg.Groups[group]; ok {
+				res, err := cfg.Store.Take(key, l, now)
+				if err != nil {
+					log.Printf("rate limit : %s : %v", key, err)
+					return after(ctx, w, r, params)
+				}
+				results = append(results, res)
+			}
+			if accountID != "" {
+				if l, ok := cfg.Plans[accountPlan(ctx, cache, db, accountID)]; ok {
+					res, err := cfg.Store.Take("account:"+accountID, l, now)
+					if err != nil {
+						log.Printf("rate limit : account %s : %v", accountID, err)
+						return after(ctx, w, r, params)
+					}
+					results = append(results, res)
+				}
+			}
+			if len(results) == 0 {
+				return after(ctx, w, r, params)
+			}
+
+			// the headers show the bucket closest to the limit, the retry waits for all of them.
+			shown, allowed, retryAfter := results[0], true, time.Duration(0)
+			for _, res := range results {
+				if res.Remaining < shown.Remaining {
+					shown = res
+				}
+				if !res.Allowed {
+					allowed = false
+					if res.RetryAfter > retryAfter {
+						retryAfter = res.RetryAfter
+					}
+				}
+			}
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(shown.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(shown.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(shown.Reset))
+			if !allowed {
+				w.Header().Set("Retry-After", seconds(retryAfter))
+				return ErrTooManyRequests
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// callerKey returns the bucket of the caller and the account whose quota is taken.
+// The invalid tokens fall back to the IP and are rejected later by Authenticate.
+func callerKey(ctx context.Context, cache *ttlCache, authenticator *auth.Authenticator, db *sqlx.DB, r *http.Request, params map[string]string, trustedHops int) (string, string) {
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "ip:" + ratelimit.GroupAPI + ":" + clientIP(r, trustedHops), ""
+	}
+	token := parts[1]
+
+	if auth.IsAPIKey(token) {
+		sum := sha256.Sum256([]byte(token))
+		hash := hex.EncodeToString(sum[:16])
+		accountID, _ := cache.get("apikey:"+hash, func() (interface{}, bool) {
+			if authenticator.APIKeyLookup == nil {
+				return "", false
+			}
+			apiKey, err := authenticator.APIKeyLookup(ctx, token)
+			return apiKey.AccountID, err == nil
+		}).(string)
+		return "apikey:" + hash, accountID
+	}
+
+	claims, err := authenticator.ParseClaims(token)
+	if err != nil {
+		return "ip:" + ratelimit.GroupAPI + ":" + clientIP(r, trustedHops), ""
+	}
+
+	accountID := params["account_id"]
+	if accountID != "" {
+		member, _ := cache.get("member:"+accountID+":"+claims.Subject, func() (interface{}, bool) {
+			_, err := user.RetrieveUser(ctx, db, accountID, claims.Subject)
+			return err == nil, err == nil
+		}).(bool)
+		if !member {
+			accountID = ""
+		}
+	}
+	return "user:" + claims.Subject, accountID
+}
+
+// accountPlan returns the plan of the account. The accounts which cannot be read get the free plan.
+func accountPlan(ctx context.Context, cache *ttlCache, db *sqlx.DB, accountID string) int {
+	plan, ok := cache.get("plan:"+accountID, func() (interface{}, bool) {
+		acc, err := account.Retrieve(ctx, db, accountID)
+		if err != nil {
+			return account.PlanFree, false
+		}
+		return acc.CustomerPlan, true
+	}).(int)
+	if !ok {
+		return account.PlanFree
+	}
+	return plan
+}
+
+// clientIP returns the address of the client. Each of the trusted proxies in front of the api appends
+// the address it got the request from to X-Forwarded-For, so the client is the entry added by the farthest
+// one, counted from the right. The entries before it are sent by the client and are not trusted. Without
+// the trusted proxies, or when the header has fewer entries than them, the address of the connection is used.
+func clientIP(r *http.Request, trustedHops int) string {
+	if trustedHops > 0 {
+		hops := make([]string, 0)
+		for _, fwd := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(fwd, ",")...)
+		}
+		if len(hops) >= trustedHops {
+			if ip := strings.TrimSpace(hops[len(hops)-trustedHops]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// ttlCache keeps the looked up values for a while. The failed lookups are not kept.
+type ttlCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]ttlEntry
+}
+
+type ttlEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// maxEntries bounds the cache. It is cleared when full as the entries are cheap to look up again.
+const maxEntries = 10000
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	return &ttlCache{ttl: ttl, entries: make(map[string]ttlEntry, 0)}
+}
+
+// get returns the cached value of the key or looks it up.
+func (c *ttlCache) get(key string, lookup func() (interface{}, bool)) interface{} {
+	now := time.Now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.value
+	}
+
+	value, found := lookup()
+	if !found {
+		return value
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxEntries {
+		c.entries = make(map[string]ttlEntry, 0)
+	}
+	c.entries[key] = ttlEntry{value: value, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return value
+}
@@ -0,0 +1,211 @@
+package migration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+)
+
+// ErrNotConvertible is used when the value of the item cannot be converted to the new data type.
+var ErrNotConvertible = errors.New("Value cannot be converted to the new data type")
+
+// conversions lists the data types each data type can be converted to.
+// The reference values are item ids, so they cannot be converted into anything else.
+var conversions = map[entity.DType][]entity.DType{
+	entity.TypeString:   {entity.TypeNumber, entity.TypeDate, entity.TypeDateTime, entity.TypeList, entity.TypeReference},
+	entity.TypeNumber:   {entity.TypeString, entity.TypeList},
+	entity.TypeDate:     {entity.TypeString, entity.TypeDateTime},
+	entity.TypeDateTime: {entity.TypeString, entity.TypeDate},
+	entity.TypeList:     {entity.TypeString, entity.TypeReference},
+}
+
+const dateLayout = "2006-01-02"
+
+// Convertible tells whether the values of the data type can be converted to the other.
+func Convertible(from, to entity.DType) bool {
+	if from == to {
+		return true
+	}
+	for _, t := range conversions[from] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert converts the value of the field to the new form of the field. The refs map is used when the
+// field becomes a reference and maps the lower cased names of the referred items to their ids.
+func Convert(value interface{}, from, to entity.Field, refs map[string]string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if !Convertible(from.DataType, to.DataType) {
+		return nil, ErrNotConvertible
+	}
+
+	if from.DataType == to.DataType {
+		if to.DataType == entity.TypeList && len(to.Choices) > 0 {
+			return keepChoices(value, to), nil
+		}
+		return value, nil
+	}
+
+	switch to.DataType {
+	case entity.TypeString:
+		return text(value, from), nil
+	case entity.TypeNumber:
+		s := strings.TrimSpace(text(value, from))
+		if s == "" {
+			return nil, nil
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, ErrNotConvertible
+		}
+		return n, nil
+	case entity.TypeDate, entity.TypeDateTime:
+		s := strings.TrimSpace(text(value, from))
+		if s == "" {
+			return nil, nil
+		}
+		t, err := parseTime(s)
+		if err != nil {
+			return nil, ErrNotConvertible
+		}
+		if to.DataType == entity.TypeDate {
+			return t.Format(dateLayout), nil
+		}
+		return util.FormatTimeGo(t), nil
+	case entity.TypeList:
+		values := list(value)
+		if len(to.Choices) == 0 {
+			return values, nil
+		}
+		choices := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			id, ok := choiceID(to, v)
+			if !ok {
+				return nil, ErrNotConvertible
+			}
+			choices = append(choices, id)
+		}
+		return choices, nil
+	case entity.TypeReference:
+		ids := make([]interface{}, 0)
+		for _, v := range list(value) {
+			id, ok := refs[strings.ToLower(display(from, v))]
+			if !ok {
+				return nil, ErrNotConvertible
+			}
+			ids = append(ids, id)
+		}
+		return ids, nil
+	}
+	return nil, ErrNotConvertible
+}
+
+// Apply migrates the values of the item for the changes. The values which cannot be converted are cleared
+// and reported with the error in the previews. The refs map is keyed by the field key.
+func Apply(fields map[string]interface{}, changes []FieldChange, refs map[string]map[string]string) (map[string]interface{}, []Preview) {
+	if fields == nil {
+		fields = make(map[string]interface{})
+	}
+	previews := make([]Preview, 0, len(changes))
+	for _, c := range changes {
+		before, ok := fields[c.Key]
+		if !ok {
+			continue
+		}
+		p := Preview{Key: c.Key, Before: before}
+		if c.Op == OpRemove {
+			delete(fields, c.Key)
+		} else {
+			after, err := Convert(before, c.From, *c.To, refs[c.Key])
+			if err != nil {
+				p.Error = err.Error()
+			}
+			fields[c.Key] = after
+			p.After = after
+		}
+		previews = append(previews, p)
+	}
+	return fields, previews
+}
+
+func keepChoices(value interface{}, to entity.Field) interface{} {
+	valid := to.ChoiceMap()
+	kept := make([]interface{}, 0)
+	for _, v := range list(value) {
+		if _, ok := valid[fmt.Sprint(v)]; ok {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func choiceID(f entity.Field, v interface{}) (string, bool) {
+	s := strings.ToLower(fmt.Sprint(v))
+	for _, c := range f.Choices {
+		if strings.ToLower(c.ID) == s || strings.ToLower(fmt.Sprint(c.DisplayValue)) == s {
+			return c.ID, true
+		}
+	}
+	return "", false
+}
+
+// display returns the display value of the choice when the value is one of the choices of the field.
+func display(f entity.Field, v interface{}) string {
+	s := fmt.Sprint(v)
+	for _, c := range f.Choices {
+		if c.ID == s && c.DisplayValue != nil {
+			return fmt.Sprint(c.DisplayValue)
+		}
+	}
+	return s
+}
+
+func text(value interface{}, from entity.Field) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case []interface{}:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			s = append(s, display(from, e))
+		}
+		return strings.Join(s, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func list(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case string:
+		if v == "" {
+			return []interface{}{}
+		}
+		return []interface{}{v}
+	default:
+		return []interface{}{text(v, entity.Field{})}
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := util.ParseTime(s); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, s)
+}
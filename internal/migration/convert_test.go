@@ -0,0 +1,122 @@
+package migration_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/migration"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestConvert(t *testing.T) {
+	text := entity.Field{Key: "k1", DataType: entity.TypeString}
+	number := entity.Field{Key: "k1", DataType: entity.TypeNumber}
+	status := entity.Field{Key: "k1", DataType: entity.TypeList, Choices: []entity.Choice{
+		{ID: "c1", DisplayValue: "Open"},
+		{ID: "c2", DisplayValue: "Closed"},
+	}}
+
+	t.Log("Given the need to convert the values of the retyped fields")
+	{
+		t.Log("\twhen the text holds a number")
+		{
+			v, err := migration.Convert("42.5", text, number, nil)
+			if err != nil || v != 42.5 {
+				t.Fatalf("\t%s should convert the text to the number. got %v %v", tests.Failed, v, err)
+			}
+			t.Logf("\t%s should convert the text to the number", tests.Success)
+		}
+
+		t.Log("\twhen the text does not hold a number")
+		{
+			if _, err := migration.Convert("forty", text, number, nil); err != migration.ErrNotConvertible {
+				t.Fatalf("\t%s should fail with ErrNotConvertible. got %v", tests.Failed, err)
+			}
+			t.Logf("\t%s should fail with ErrNotConvertible", tests.Success)
+		}
+
+		t.Log("\twhen the list becomes the text")
+		{
+			v, err := migration.Convert([]interface{}{"c1", "c2"}, status, text, nil)
+			if err != nil || v != "Open, Closed" {
+				t.Fatalf("\t%s should join the display values. got %v %v", tests.Failed, v, err)
+			}
+			t.Logf("\t%s should join the display values", tests.Success)
+		}
+
+		t.Log("\twhen a choice of the list is removed")
+		{
+			next := status
+			next.Choices = status.Choices[:1]
+			v, err := migration.Convert([]interface{}{"c1", "c2"}, status, next, nil)
+			vals, _ := v.([]interface{})
+			if err != nil || len(vals) != 1 || vals[0] != "c1" {
+				t.Fatalf("\t%s should drop the removed choice. got %v %v", tests.Failed, v, err)
+			}
+			t.Logf("\t%s should drop the removed choice", tests.Success)
+		}
+
+		t.Log("\twhen the reference is converted to the number")
+		{
+			ref := entity.Field{Key: "k1", DataType: entity.TypeReference}
+			if migration.Convertible(ref.DataType, number.DataType) {
+				t.Fatalf("\t%s should not be convertible", tests.Failed)
+			}
+			t.Logf("\t%s should not be convertible", tests.Success)
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	t.Log("Given the need to find the field changes which affect the items")
+	{
+		current := []entity.Field{
+			{Key: "k1", DisplayName: "Name", DataType: entity.TypeString},
+			{Key: "k2", DisplayName: "Amount", DataType: entity.TypeString},
+			{Key: "k3", DisplayName: "Status", DataType: entity.TypeList, Choices: []entity.Choice{{ID: "c1"}, {ID: "c2"}}},
+			{Key: "k4", DisplayName: "Notes", DataType: entity.TypeString},
+		}
+		next := []entity.Field{
+			{Key: "k1", DisplayName: "Full Name", DataType: entity.TypeString},
+			{Key: "k2", DisplayName: "Amount", DataType: entity.TypeNumber},
+			{Key: "k3", DisplayName: "Status", DataType: entity.TypeList, Choices: []entity.Choice{{ID: "c1"}}},
+		}
+
+		t.Log("\twhen the fields are renamed, retyped, trimmed and removed")
+		{
+			changes := migration.Changes(current, next)
+			if len(changes) != 3 {
+				t.Fatalf("\t%s should ignore the rename and report the other three. got %+v", tests.Failed, changes)
+			}
+			if changes[0].Key != "k2" || changes[0].Op != migration.OpRetype || !changes[0].Convertible {
+				t.Fatalf("\t%s should report the convertible retype. got %+v", tests.Failed, changes[0])
+			}
+			if changes[1].Key != "k3" || changes[1].Op != migration.OpChoices || len(changes[1].RemovedChoices) != 1 {
+				t.Fatalf("\t%s should report the removed choice. got %+v", tests.Failed, changes[1])
+			}
+			if changes[2].Key != "k4" || changes[2].Op != migration.OpRemove {
+				t.Fatalf("\t%s should report the removed field. got %+v", tests.Failed, changes[2])
+			}
+			t.Logf("\t%s should ignore the rename and report the other three", tests.Success)
+		}
+
+		t.Log("\twhen the changes are applied on the item")
+		{
+			fields := map[string]interface{}{"k1": "Jane", "k2": "ten", "k3": []interface{}{"c2"}, "k4": "call back"}
+			fields, previews := migration.Apply(fields, migration.Changes(current, next), nil)
+			if _, ok := fields["k4"]; ok {
+				t.Fatalf("\t%s should delete the removed field", tests.Failed)
+			}
+			if fields["k2"] != nil || previews[0].Error == "" {
+				t.Fatalf("\t%s should clear the value which cannot be converted. got %+v", tests.Failed, previews[0])
+			}
+			if vals, _ := fields["k3"].([]interface{}); len(vals) != 0 {
+				t.Fatalf("\t%s should drop the removed choice. got %v", tests.Failed, fields["k3"])
+			}
+			if fields["k1"] != "Jane" {
+				t.Fatalf("\t%s should leave the unchanged fields", tests.Failed)
+			}
+			t.Logf("\t%s should delete, clear and trim the values", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/chart"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/layout"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when a specific migration is requested but does not exist.
+	ErrNotFound = errors.New("Migration not found")
+	// ErrFieldInUse is used when the removed field is referred in the expression of the flows or the nodes.
+	ErrFieldInUse = errors.New("Removed field is used in the workflows")
+)
+
+// Changes compares the fields of the entity before and after the update and returns the changes
+// which affect the values stored in the items.
+func Changes(current, next []entity.Field) []FieldChange {
+	changes := make([]FieldChange, 0)
+	nextFields := entity.KeyMap(next)
+	for _, f := range current {
+		nf, ok := nextFields[f.Key]
+		if !ok {
+			changes = append(changes, FieldChange{Key: f.Key, Name: f.DisplayName, Op: OpRemove, From: f})
+			continue
+		}
+		if nf.DataType != f.DataType {
+			to := nf
+			changes = append(changes, FieldChange{Key: f.Key, Name: nf.DisplayName, Op: OpRetype, From: f, To: &to, Convertible: Convertible(f.DataType, nf.DataType)})
+			continue
+		}
+		if f.DataType == entity.TypeList && len(nf.Choices) > 0 {
+			kept := nf.ChoiceMap()
+			removed := make([]string, 0)
+			for _, c := range f.Choices {
+				if _, ok := kept[c.ID]; !ok {
+					removed = append(removed, c.ID)
+				}
+			}
+			if len(removed) > 0 {
+				to := nf
+				changes = append(changes, FieldChange{Key: f.Key, Name: nf.DisplayName, Op: OpChoices, From: f, To: &to, RemovedChoices: removed, Convertible: true})
+			}
+		}
+	}
+	return changes
+}
+
+// Keys returns the keys of the changed fields.
+func Keys(changes []FieldChange) []string {
+	keys := make([]string, 0, len(changes))
+	for _, c := range changes {
+		keys = append(keys, c.Key)
+	}
+	return keys
+}
+
+// Analyze lists the items, flows, nodes, charts and layouts affected by the changes. The flows and the nodes
+// referring a removed field in their expression block the change as they would fail silently afterwards.
+func Analyze(ctx context.Context, db *sqlx.DB, accountID, entityID string, changes []FieldChange) (Impact, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.migration.Analyze")
+	defer span.End()
+
+	impact := Impact{
+		Changes: changes,
+		Flows:   make([]Reference, 0),
+		Nodes:   make([]Reference, 0),
+		Charts:  make([]Reference, 0),
+		Layouts: make([]Reference, 0),
+	}
+	if len(changes) == 0 {
+		return impact, nil
+	}
+
+	var err error
+	impact.Items, err = item.CountWithFields(ctx, accountID, entityID, Keys(changes), db)
+	if err != nil {
+		return Impact{}, err
+	}
+
+	charts, err := chart.EntityCharts(ctx, accountID, entityID, db)
+	if err != nil {
+		return Impact{}, err
+	}
+
+	for _, c := range changes {
+		blocking := c.Op == OpRemove
+		// the expressions refer the fields as {{entity_id.key}}
+		term := entityID + "." + c.Key
+
+		flows, err := flow.Referring(ctx, accountID, term, db)
+		if err != nil {
+			return Impact{}, err
+		}
+		for _, f := range flows {
+			impact.Flows = append(impact.Flows, Reference{ID: f.ID, Name: f.Name, Key: c.Key, Blocking: blocking})
+		}
+
+		nodes, err := node.Referring(ctx, accountID, term, db)
+		if err != nil {
+			return Impact{}, err
+		}
+		for _, n := range nodes {
+			impact.Nodes = append(impact.Nodes, Reference{ID: n.ID, Name: n.Name, Key: c.Key, Blocking: blocking})
+		}
+
+		for _, ch := range charts {
+			for _, v := range ch.Meta() {
+				if v == c.Key || v == c.From.Name {
+					impact.Charts = append(impact.Charts, Reference{ID: ch.ID, Name: ch.DisplayName, Key: c.Key})
+					break
+				}
+			}
+		}
+
+		layouts, err := layout.Referring(ctx, accountID, entityID, c.Key, db)
+		if err != nil {
+			return Impact{}, err
+		}
+		for _, l := range layouts {
+			impact.Layouts = append(impact.Layouts, Reference{ID: l.Name, Name: l.Name, Key: c.Key})
+		}
+
+		if blocking && (len(flows) > 0 || len(nodes) > 0) {
+			impact.Blocking = true
+		}
+	}
+	return impact, nil
+}
+
+// Previews converts the values of the first few affected items without saving them.
+func Previews(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID, entityID string, changes []FieldChange, limit int) ([]Preview, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.migration.Previews")
+	defer span.End()
+
+	previews := make([]Preview, 0)
+	if len(changes) == 0 {
+		return previews, nil
+	}
+
+	refs, err := References(ctx, db, sdb, accountID, changes)
+	if err != nil {
+		return nil, err
+	}
+	items, err := item.WithFields(ctx, accountID, entityID, Keys(changes), "", limit, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		_, ps := Apply(it.Fields(), changes, refs)
+		for _, p := range ps {
+			p.ItemID = it.ID
+			previews = append(previews, p)
+		}
+	}
+	return previews, nil
+}
+
+// References returns the names of the items referred by the fields which become the reference field.
+// The map is keyed by the field key and the names are lower cased.
+func References(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID string, changes []FieldChange) (map[string]map[string]string, error) {
+	refs := make(map[string]map[string]string)
+	for _, c := range changes {
+		if c.Op != OpRetype || c.To.DataType != entity.TypeReference || c.To.RefID == "" {
+			continue
+		}
+		e, err := entity.Retrieve(ctx, accountID, c.To.RefID, db, sdb)
+		if err != nil {
+			return nil, err
+		}
+		titleKey := e.WhoField(entity.WhoTitle).Key
+
+		items, err := item.List(ctx, accountID, e.ID, db)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[string]string, len(items))
+		for _, it := range items {
+			if title, ok := it.Fields()[titleKey].(string); ok && title != "" {
+				names[strings.ToLower(title)] = it.ID
+			} else if it.Name != nil {
+				names[strings.ToLower(*it.Name)] = it.ID
+			}
+		}
+		refs[c.Key] = names
+	}
+	return refs, nil
+}
+
+// Create records the migration to be backfilled by the worker.
+func Create(ctx context.Context, db *sqlx.DB, accountID, entityID, userID string, changes []FieldChange, total int, now time.Time) (Migration, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.migration.Create")
+	defer span.End()
+
+	changesb, err := json.Marshal(changes)
+	if err != nil {
+		return Migration{}, errors.Wrap(err, "encode changes")
+	}
+
+	m := Migration{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		EntityID:  entityID,
+		UserID:    userID,
+		Changesb:  string(changesb),
+		Status:    StatusQueued,
+		Total:     total,
+		CreatedAt: now.UTC(),
+		UpdatedAt: now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO field_migrations
+		(migration_id, account_id, entity_id, user_id, changesb, status, total, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	_, err = db.ExecContext(
+		ctx, q,
+		m.ID, m.AccountID, m.EntityID, m.UserID, m.Changesb, m.Status, m.Total,
+		m.CreatedAt, m.UpdatedAt,
+	)
+	if err != nil {
+		return Migration{}, errors.Wrap(err, "inserting field migration")
+	}
+
+	return m, nil
+}
+
+// List returns the migrations of the entity.
+func List(ctx context.Context, db *sqlx.DB, accountID, entityID string) ([]Migration, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.migration.List")
+	defer span.End()
+
+	migrations := []Migration{}
+	const q = `SELECT * FROM field_migrations WHERE account_id = $1 AND entity_id = $2 ORDER BY created_at DESC LIMIT 50`
+	if err := db.SelectContext(ctx, &migrations, q, accountID, entityID); err != nil {
+		return nil, errors.Wrap(err, "selecting field migrations")
+	}
+	return migrations, nil
+}
+
+// Retrieve gets the specified migration from the database.
+func Retrieve(ctx context.Context, db *sqlx.DB, accountID, migrationID string) (Migration, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.migration.Retrieve")
+	defer span.End()
+
+	var m Migration
+	const q = `SELECT * FROM field_migrations WHERE account_id = $1 AND migration_id = $2`
+	if err := db.GetContext(ctx, &m, q, accountID, migrationID); err != nil {
+		if err == sql.ErrNoRows {
+			return Migration{}, ErrNotFound
+		}
+		return Migration{}, errors.Wrapf(err, "selecting field migration %q", migrationID)
+	}
+	return m, nil
+}
+
+// UpdateProgress saves the progress of the backfill.
+func UpdateProgress(ctx context.Context, db *sqlx.DB, migrationID string, status, done, failed int, errMsg *string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.migration.UpdateProgress")
+	defer span.End()
+
+	const q = `UPDATE field_migrations SET status = $2, done = $3, failed = $4, error = $5, updated_at = $6 WHERE migration_id = $1`
+	if _, err := db.ExecContext(ctx, q, migrationID, status, done, failed, errMsg, now.UTC().Unix()); err != nil {
+		return errors.Wrapf(err, "updating field migration %q", migrationID)
+	}
+	return nil
+}
+
+// Changes returns the field changes of the migration.
+func (m Migration) Changes() []FieldChange {
+	changes := make([]FieldChange, 0)
+	if m.Changesb == "" {
+		return changes
+	}
+	if err := json.Unmarshal([]byte(m.Changesb), &changes); err != nil {
+		log.Printf("***> unexpected error occurred when unmarshalling changes for migration: %v error: %v\n", m.ID, err)
+	}
+	return changes
+}
@@ -0,0 +1,78 @@
+package migration
+
+import (
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+)
+
+// Operations of the field change.
+const (
+	OpRemove  = "remove"  // the field is deleted from the entity
+	OpRetype  = "retype"  // the data type of the field is changed
+	OpChoices = "choices" // some choices of the list field are deleted
+)
+
+// Status of the backfill.
+const (
+	StatusQueued  = 0
+	StatusRunning = 1
+	StatusDone    = 2
+	StatusFailed  = 3
+)
+
+// FieldChange is the change of an existing field which needs the values of the items to be migrated.
+// The new fields and the display-only changes (name, dom, meta) are not listed as they are harmless.
+type FieldChange struct {
+	Key            string        `json:"key"`
+	Name           string        `json:"name"`
+	Op             string        `json:"op"`
+	From           entity.Field  `json:"from"`
+	To             *entity.Field `json:"to,omitempty"` // nil when the field is removed
+	RemovedChoices []string      `json:"removed_choices,omitempty"`
+	Convertible    bool          `json:"convertible"`
+}
+
+// Reference is a flow, node, chart or layout referring the changed field.
+type Reference struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Key      string `json:"key"`
+	Blocking bool   `json:"blocking"` // the reference breaks once the change is saved
+}
+
+// Impact lists everything affected by the field changes of the entity.
+type Impact struct {
+	Changes  []FieldChange `json:"changes"`
+	Items    int           `json:"items"`
+	Flows    []Reference   `json:"flows"`
+	Nodes    []Reference   `json:"nodes"`
+	Charts   []Reference   `json:"charts"`
+	Layouts  []Reference   `json:"layouts"`
+	Blocking bool          `json:"blocking"`
+}
+
+// Preview is the value of the item before and after the migration.
+type Preview struct {
+	ItemID string      `json:"item_id"`
+	Key    string      `json:"key"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+	Error  string      `json:"error,omitempty"` // the value is cleared during the backfill when it cannot be converted
+}
+
+// Migration tracks the backfill of the item values after the fields of the entity are changed.
+type Migration struct {
+	ID        string    `db:"migration_id" json:"id"`
+	AccountID string    `db:"account_id" json:"account_id"`
+	EntityID  string    `db:"entity_id" json:"entity_id"`
+	UserID    string    `db:"user_id" json:"user_id"`
+	Changesb  string    `db:"changesb" json:"changesb"`
+	Status    int       `db:"status" json:"status"`
+	Total     int       `db:"total" json:"total"`
+	Done      int       `db:"done" json:"done"`
+	Failed    int       `db:"failed" json:"failed"`
+	Error     *string   `db:"error" json:"error"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt int64     `db:"updated_at" json:"updated_at"`
+}
@@ -21,6 +21,7 @@ type EmailNotification struct {
 	Requester   string
 	AccountName string
 	MagicLink   string
+	Entries     []DigestEntry // the notifications of the digest
 }
 
 func (emNotif EmailNotification) Send(ctx context.Context, notifType NotificationType, db *sqlx.DB) error {
@@ -35,6 +36,7 @@ func (emNotif EmailNotification) Send(ctx context.Context, notifType Notificatio
 		Requester   string
 		Body        string
 		Subject     string
+		Entries     []DigestEntry
 	}{
 		Name:        emNotif.Name,
 		AccountName: emNotif.AccountName,
@@ -43,6 +45,7 @@ func (emNotif EmailNotification) Send(ctx context.Context, notifType Notificatio
 		Requester:   emNotif.Requester,
 		Body:        emNotif.Body,
 		Subject:     emNotif.Subject,
+		Entries:     emNotif.Entries,
 	}
 
 	template := "welcome.html"
@@ -53,6 +56,8 @@ func (emNotif EmailNotification) Send(ctx context.Context, notifType Notificatio
 		template = "invitation.html"
 	case TypeVisitorInvitation:
 		template = "visitor_invitation.html"
+	case TypeDigest:
+		template = "digest.html"
 	default:
 		template = "update.html"
 	}
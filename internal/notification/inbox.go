@@ -0,0 +1,206 @@
+package notification
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/conversation"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"go.opencensus.io/trace"
+)
+
+// inboxPageLimit is the notices listed in a page of the inbox.
+const inboxPageLimit = 25
+
+// Post puts the saved notification in the inbox of the users and pushes it to the ones listening.
+func (appNotif AppNotification) Post(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, notificationID string, users []entity.UserEntity, notifType NotificationType, now time.Time) ([]Notice, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.Post")
+	defer span.End()
+
+	notices := make([]Notice, 0, len(users))
+	posted := make(map[string]bool, 0)
+	for _, u := range users {
+		if u.UserID == "" || posted[u.UserID] {
+			continue
+		}
+		posted[u.UserID] = true
+
+		n := Notice{
+			ID:             uuid.New().String(),
+			AccountID:      appNotif.AccountID,
+			UserID:         u.UserID,
+			NotificationID: notificationID,
+			TeamID:         appNotif.TeamID,
+			EntityID:       appNotif.EntityID,
+			ItemID:         appNotif.ItemID,
+			Type:           int(notifType),
+			Subject:        appNotif.Subject,
+			Body:           appNotif.Body,
+			SenderName:     appNotif.UserName,
+			SenderAvatar:   appNotif.UserAvatar,
+			CreatedAt:      now.UTC(),
+		}
+
+		const q = `INSERT INTO notification_inbox
+			(notice_id, account_id, user_id, notification_id, team_id, entity_id, item_id, type, subject, body,
+			sender_name, sender_avatar, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+		_, err := db.ExecContext(
+			ctx, q,
+			n.ID, n.AccountID, n.UserID, n.NotificationID, n.TeamID, n.EntityID, n.ItemID, n.Type, n.Subject, n.Body,
+			n.SenderName, n.SenderAvatar, n.CreatedAt,
+		)
+		if err != nil {
+			return notices, errors.Wrap(err, "posting notice")
+		}
+		notices = append(notices, n)
+
+		if sdb != nil {
+			if err := conversation.PublishNotice(sdb.PubSubPool(), conversation.NoticeRoom(n.AccountID, n.UserID), n); err != nil {
+				log.Printf("***> notice of the user %s not pushed. error: %v\n", n.UserID, err)
+			}
+		}
+	}
+	return notices, nil
+}
+
+// Inbox returns the page of the notices of the user, the latest first.
+func Inbox(ctx context.Context, db *sqlx.DB, accountID, userID string, f InboxFilter, page int) ([]Notice, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.Inbox")
+	defer span.End()
+
+	notices := []Notice{}
+	q := `SELECT * FROM notification_inbox WHERE account_id = $1 AND user_id = $2` + f.where() +
+		` ORDER BY created_at DESC OFFSET $3 LIMIT $4`
+	if err := db.SelectContext(ctx, &notices, q, f.args(accountID, userID, page*inboxPageLimit, inboxPageLimit)...); err != nil {
+		return nil, errors.Wrap(err, "selecting notices")
+	}
+
+	return notices, nil
+}
+
+// Threads returns the page of the notices of the user grouped by the items they are on. Each thread holds
+// the latest notice on the item, the threads with the latest notices come first.
+func Threads(ctx context.Context, db *sqlx.DB, accountID, userID string, f InboxFilter, page int) ([]Thread, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.Threads")
+	defer span.End()
+
+	threads := []Thread{}
+	q := `SELECT n.*, g.count, g.unread FROM (
+			SELECT entity_id, item_id, max(created_at) AS latest, count(*) AS count,
+			count(*) FILTER (WHERE read_at IS NULL) AS unread
+			FROM notification_inbox WHERE account_id = $1 AND user_id = $2` + f.where() + `
+			GROUP BY entity_id, item_id ORDER BY latest DESC OFFSET $3 LIMIT $4
+		) AS g
+		JOIN LATERAL (
+			SELECT * FROM notification_inbox WHERE account_id = $1 AND user_id = $2` + f.where() + `
+			AND entity_id = g.entity_id AND item_id = g.item_id ORDER BY created_at DESC LIMIT 1
+		) AS n ON true
+		ORDER BY g.latest DESC`
+	if err := db.SelectContext(ctx, &threads, q, f.args(accountID, userID, page*inboxPageLimit, inboxPageLimit)...); err != nil {
+		return nil, errors.Wrap(err, "selecting notice threads")
+	}
+
+	return threads, nil
+}
+
+// UnreadCount returns the count of the unread notices of the user left in the inbox.
+func UnreadCount(ctx context.Context, db *sqlx.DB, accountID, userID string) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.UnreadCount")
+	defer span.End()
+
+	var count int
+	const q = `SELECT count(*) FROM notification_inbox WHERE account_id = $1 AND user_id = $2 AND read_at IS NULL AND archived_at IS NULL`
+	if err := db.GetContext(ctx, &count, q, accountID, userID); err != nil {
+		return 0, errors.Wrap(err, "counting unread notices")
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the notices of the user read, or unread when read is false.
+func MarkRead(ctx context.Context, db *sqlx.DB, accountID, userID string, m Mark, read bool, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.MarkRead")
+	defer span.End()
+
+	var readAt *time.Time
+	if read {
+		t := now.UTC()
+		readAt = &t
+	}
+	if err := mark(ctx, db, "read_at", accountID, userID, m, readAt); err != nil {
+		return errors.Wrap(err, "marking notices read")
+	}
+	return nil
+}
+
+// MarkAllRead marks every notice of the user read.
+func MarkAllRead(ctx context.Context, db *sqlx.DB, accountID, userID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.MarkAllRead")
+	defer span.End()
+
+	const q = `UPDATE notification_inbox SET read_at = $3 WHERE account_id = $1 AND user_id = $2 AND read_at IS NULL`
+	if _, err := db.ExecContext(ctx, q, accountID, userID, now.UTC()); err != nil {
+		return errors.Wrap(err, "marking all notices read")
+	}
+	return nil
+}
+
+// Archive takes the notices of the user out of the inbox. The archived notices are read.
+func Archive(ctx context.Context, db *sqlx.DB, accountID, userID string, m Mark, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.Archive")
+	defer span.End()
+
+	t := now.UTC()
+	if err := mark(ctx, db, "archived_at", accountID, userID, m, &t); err != nil {
+		return errors.Wrap(err, "archiving notices")
+	}
+	if err := mark(ctx, db, "read_at", accountID, userID, m, &t); err != nil {
+		return errors.Wrap(err, "archiving notices")
+	}
+	return nil
+}
+
+// mark sets the column of the notices picked by their ids or by their items. The read column of the
+// notices already read keeps the time they were read.
+func mark(ctx context.Context, db *sqlx.DB, column, accountID, userID string, m Mark, at *time.Time) error {
+	if len(m.IDs) == 0 && len(m.ItemIDs) == 0 {
+		return nil
+	}
+	q := `UPDATE notification_inbox SET ` + column + ` = $3 WHERE account_id = $1 AND user_id = $2
+		AND (notice_id = any($4) OR item_id = any($5))`
+	if at != nil {
+		q += ` AND ` + column + ` IS NULL`
+	}
+	_, err := db.ExecContext(ctx, q, accountID, userID, at, pq.Array(nonNil(m.IDs)), pq.Array(nonNil(m.ItemIDs)))
+	return err
+}
+
+// where narrows the inbox queries. The types, when asked for, are the fifth argument after the account,
+// the user, the offset and the limit.
+func (f InboxFilter) where() string {
+	w := ` AND archived_at IS NULL`
+	if f.Archived {
+		w = ` AND archived_at IS NOT NULL`
+	}
+	if f.Unread {
+		w += ` AND read_at IS NULL`
+	}
+	if len(f.Types) > 0 {
+		w += ` AND type = any($5)`
+	}
+	return w
+}
+
+func (f InboxFilter) args(args ...interface{}) []interface{} {
+	if len(f.Types) > 0 {
+		args = append(args, pq.Array(f.Types))
+	}
+	return args
+}
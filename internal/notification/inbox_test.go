@@ -0,0 +1,130 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/schema"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestInboxFilter(t *testing.T) {
+	t.Log("Given the need to narrow the notices of the inbox")
+	{
+		cases := []struct {
+			name  string
+			f     InboxFilter
+			where string
+			args  int
+		}{
+			{"the inbox", InboxFilter{}, ` AND archived_at IS NULL`, 4},
+			{"the unread", InboxFilter{Unread: true}, ` AND archived_at IS NULL AND read_at IS NULL`, 4},
+			{"the archived", InboxFilter{Archived: true}, ` AND archived_at IS NOT NULL`, 4},
+			{"the unread archived", InboxFilter{Archived: true, Unread: true}, ` AND archived_at IS NOT NULL AND read_at IS NULL`, 4},
+			{"the assigned", InboxFilter{Types: []int{int(TypeAssigned)}}, ` AND archived_at IS NULL AND type = any($5)`, 5},
+			{"the unread assigned and updated", InboxFilter{Unread: true, Types: []int{int(TypeAssigned), int(TypeUpdated)}}, ` AND archived_at IS NULL AND read_at IS NULL AND type = any($5)`, 5},
+			{"the archived reminders", InboxFilter{Archived: true, Types: []int{int(TypeReminder)}}, ` AND archived_at IS NOT NULL AND type = any($5)`, 5},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen listing %s", c.name)
+			{
+				if got := c.f.where(); got != c.where {
+					t.Fatalf("\t%s should narrow with %q. got %q", tests.Failed, c.where, got)
+				}
+				t.Logf("\t%s should narrow with %q", tests.Success, c.where)
+
+				if got := len(c.f.args("account", "user", 0, inboxPageLimit)); got != c.args {
+					t.Fatalf("\t%s should pass %d arguments. got %d", tests.Failed, c.args, got)
+				}
+				t.Logf("\t%s should pass %d arguments", tests.Success, c.args)
+			}
+		}
+	}
+}
+
+func TestThreads(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+	tests.SeedData(t, db)
+
+	ctx := tests.Context()
+	accountID, userID := schema.SeedAccountID, schema.SeedUserID1
+	entityID, deal, task := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	users := []entity.UserEntity{{UserID: userID}}
+	now := time.Now().Add(-time.Hour)
+
+	post := func(itemID string, notifType NotificationType, at time.Time) Notice {
+		an := AppNotification{AccountID: accountID, TeamID: schema.SeedTeamID, EntityID: entityID, ItemID: itemID, Subject: "subject"}
+		notices, err := an.Post(ctx, db, nil, uuid.New().String(), users, notifType, at)
+		if err != nil || len(notices) != 1 {
+			t.Fatalf("\t%s should post the notice : %v", tests.Failed, err)
+		}
+		return notices[0]
+	}
+
+	t.Log("Given the need to group the notices of the user by their items")
+	{
+		first := post(deal, TypeUpdated, now)
+		post(deal, TypeUpdated, now.Add(time.Minute))
+		latest := post(deal, TypeAssigned, now.Add(2*time.Minute))
+		post(task, TypeCreated, now.Add(time.Minute))
+		if err := MarkRead(ctx, db, accountID, userID, Mark{IDs: []string{first.ID}}, true, now); err != nil {
+			t.Fatalf("\t%s should mark the notice read : %s", tests.Failed, err)
+		}
+
+		t.Log("\twhen listing the threads of the inbox")
+		{
+			threads, err := Threads(ctx, db, accountID, userID, InboxFilter{}, 0)
+			if err != nil {
+				t.Fatalf("\t%s should list the threads : %s", tests.Failed, err)
+			}
+			if len(threads) != 2 || threads[0].ItemID != deal || threads[1].ItemID != task {
+				t.Fatalf("\t%s should list the deal before the task. got %+v", tests.Failed, threads)
+			}
+			t.Logf("\t%s should list the deal before the task", tests.Success)
+
+			if threads[0].ID != latest.ID || threads[0].Count != 3 || threads[0].Unread != 2 {
+				t.Fatalf("\t%s should hold the latest notice of the deal with 3 notices, 2 unread. got %s %d %d", tests.Failed, threads[0].ID, threads[0].Count, threads[0].Unread)
+			}
+			t.Logf("\t%s should hold the latest notice of the deal with 3 notices, 2 unread", tests.Success)
+		}
+
+		t.Log("\twhen listing the threads of the updates")
+		{
+			threads, err := Threads(ctx, db, accountID, userID, InboxFilter{Types: []int{int(TypeUpdated)}}, 0)
+			if err != nil {
+				t.Fatalf("\t%s should list the threads : %s", tests.Failed, err)
+			}
+			if len(threads) != 1 || threads[0].ItemID != deal || threads[0].Count != 2 || threads[0].Type != int(TypeUpdated) {
+				t.Fatalf("\t%s should list only the updates of the deal. got %+v", tests.Failed, threads)
+			}
+			t.Logf("\t%s should list only the updates of the deal", tests.Success)
+		}
+
+		t.Log("\twhen the task is archived")
+		{
+			if err := Archive(ctx, db, accountID, userID, Mark{ItemIDs: []string{task}}, now); err != nil {
+				t.Fatalf("\t%s should archive the task : %s", tests.Failed, err)
+			}
+			threads, err := Threads(ctx, db, accountID, userID, InboxFilter{}, 0)
+			if err != nil || len(threads) != 1 || threads[0].ItemID != deal {
+				t.Fatalf("\t%s should leave only the deal in the inbox. got %+v : %v", tests.Failed, threads, err)
+			}
+			t.Logf("\t%s should leave only the deal in the inbox", tests.Success)
+
+			threads, err = Threads(ctx, db, accountID, userID, InboxFilter{Archived: true}, 0)
+			if err != nil || len(threads) != 1 || threads[0].ItemID != task || threads[0].Unread != 0 {
+				t.Fatalf("\t%s should list the read task in the archive. got %+v : %v", tests.Failed, threads, err)
+			}
+			t.Logf("\t%s should list the read task in the archive", tests.Success)
+
+			threads, err = Threads(ctx, db, accountID, userID, InboxFilter{Unread: true}, 0)
+			if err != nil || len(threads) != 1 || threads[0].Count != 2 {
+				t.Fatalf("\t%s should list the 2 unread notices of the deal. got %+v : %v", tests.Failed, threads, err)
+			}
+			t.Logf("\t%s should list the 2 unread notices of the deal", tests.Success)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package notification
 
-import "time"
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
 
 type ClientRegister struct {
 	AccountID   string    `db:"account_id" json:"account_id"`
@@ -18,3 +22,127 @@ type ViewModelClientRegister struct {
 	DeviceType  string `json:"device_type"`
 	Status      int    `json:"status"`
 }
+
+// Channels the notifications are delivered through.
+const (
+	ChannelPush  = "push"
+	ChannelEmail = "email"
+	ChannelInApp = "in_app"
+	ChannelSlack = "slack"
+)
+
+// Digests the notifications are batched in. The immediate ones are delivered as they happen.
+const (
+	DigestImmediate = "immediate"
+	DigestHourly    = "hourly"
+	DigestDaily     = "daily"
+)
+
+// Events the routing rules match.
+const (
+	EventAssigned = "assigned" // any event on the item assigned to the user
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventReminder = "reminder"
+	EventSLA      = "sla"
+	EventComment  = "comment"
+)
+
+// Statuses of the deliveries in the queue.
+const (
+	DeliveryPending = 0
+	DeliverySending = 1
+	DeliverySent    = 2
+	DeliveryFailed  = 3
+	DeliverySkipped = 4 // the user unsubscribed after it was queued
+)
+
+// Rule routes the notifications of the user, or of everyone in the account when the user is not set, for
+// the entity, or for all the entities when it is not set. The empty events and fields match any.
+type Rule struct {
+	ID           string         `db:"rule_id" json:"id"`
+	AccountID    string         `db:"account_id" json:"account_id"`
+	UserID       *string        `db:"user_id" json:"user_id"`
+	EntityID     *string        `db:"entity_id" json:"entity_id"`
+	Channels     pq.StringArray `db:"channels" json:"channels"`
+	Events       pq.StringArray `db:"events" json:"events"`
+	Fields       pq.StringArray `db:"fields" json:"fields"`
+	Digest       string         `db:"digest" json:"digest"`
+	SlackWebhook string         `db:"slack_webhook" json:"slack_webhook"`
+	CreatedAt    time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt    int64          `db:"updated_at" json:"updated_at"`
+}
+
+// NewRule has the information needed to save the routing rule. Only the admins route for everyone.
+type NewRule struct {
+	Everyone     bool     `json:"everyone"`
+	EntityID     *string  `json:"entity_id"`
+	Channels     []string `json:"channels" validate:"required,min=1,dive,oneof=push email in_app slack"`
+	Events       []string `json:"events" validate:"dive,oneof=assigned created updated reminder sla comment"`
+	Fields       []string `json:"fields"`
+	Digest       string   `json:"digest" validate:"omitempty,oneof=immediate hourly daily"`
+	SlackWebhook string   `json:"slack_webhook" validate:"omitempty,url,startswith=https://hooks.slack.com/"`
+}
+
+// Delivery is the notification of the user waiting in the queue for its channel.
+type Delivery struct {
+	ID           string     `db:"delivery_id" json:"id"`
+	AccountID    string     `db:"account_id" json:"account_id"`
+	UserID       string     `db:"user_id" json:"user_id"`
+	Name         string     `db:"name" json:"name"`
+	Email        string     `db:"email" json:"email"`
+	Channel      string     `db:"channel" json:"channel"`
+	Digest       string     `db:"digest" json:"digest"`
+	SlackWebhook string     `db:"slack_webhook" json:"slack_webhook"`
+	Type         int        `db:"type" json:"type"`
+	Subject      string     `db:"subject" json:"subject"`
+	Body         string     `db:"body" json:"body"`
+	Link         string     `db:"link" json:"link"`
+	SenderName   string     `db:"sender_name" json:"sender_name"`
+	SenderAvatar string     `db:"sender_avatar" json:"sender_avatar"`
+	Status       int        `db:"status" json:"status"`
+	DeliverAt    time.Time  `db:"deliver_at" json:"deliver_at"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	SentAt       *time.Time `db:"sent_at" json:"sent_at"`
+	ClaimedAt    *time.Time `db:"claimed_at" json:"claimed_at"`
+}
+
+// Notice is the in-app notification in the inbox of the user.
+type Notice struct {
+	ID             string     `db:"notice_id" json:"id"`
+	AccountID      string     `db:"account_id" json:"account_id"`
+	UserID         string     `db:"user_id" json:"user_id"`
+	NotificationID string     `db:"notification_id" json:"notification_id"`
+	TeamID         string     `db:"team_id" json:"team_id"`
+	EntityID       string     `db:"entity_id" json:"entity_id"`
+	ItemID         string     `db:"item_id" json:"item_id"`
+	Type           int        `db:"type" json:"type"`
+	Subject        string     `db:"subject" json:"subject"`
+	Body           string     `db:"body" json:"body"`
+	SenderName     string     `db:"sender_name" json:"sender_name"`
+	SenderAvatar   string     `db:"sender_avatar" json:"sender_avatar"`
+	ReadAt         *time.Time `db:"read_at" json:"read_at"`
+	ArchivedAt     *time.Time `db:"archived_at" json:"archived_at"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Thread is the latest notice on the item with the count of the notices the item got.
+type Thread struct {
+	Notice
+	Count  int `db:"count" json:"count"`
+	Unread int `db:"unread" json:"unread"`
+}
+
+// InboxFilter narrows the notices listed. The archived notices are listed only when asked for.
+// The notices of any type are listed when the types are empty.
+type InboxFilter struct {
+	Unread   bool
+	Archived bool
+	Types    []int
+}
+
+// Mark picks the notices to mark, by their ids or by the items they are on.
+type Mark struct {
+	IDs     []string `json:"ids" validate:"dive,uuid"`
+	ItemIDs []string `json:"item_ids" validate:"dive,uuid"`
+}
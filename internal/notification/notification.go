@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"gitlab.com/vjsideprojects/relay/internal/account"
@@ -31,6 +32,9 @@ const (
 	TypeEmailConversationAdded NotificationType = 7
 	TypeChatConversationAdded  NotificationType = 8
 	TypeMemberAdded            NotificationType = 9
+	TypeSLANearBreach          NotificationType = 10
+	TypeSLABreached            NotificationType = 11
+	TypeDigest                 NotificationType = 12
 )
 
 type Notification interface {
@@ -197,6 +201,12 @@ func OnAnItemLevelEvent(ctx context.Context, usrID string, entityCategory int, e
 			}
 		}
 
+	case TypeSLANearBreach:
+		appNotif.Subject = fmt.Sprintf("%s is about to breach the SLA", util.UpperSinglarize(entityDisName))
+		appNotif.Body = fmt.Sprintf("%s", appNotif.Title)
+	case TypeSLABreached:
+		appNotif.Subject = fmt.Sprintf("%s breached the SLA", util.UpperSinglarize(entityDisName))
+		appNotif.Body = fmt.Sprintf("%s", appNotif.Title)
 	case TypeChatConversationAdded:
 		if val, exist := appNotif.DirtyFields[entity.WhoMessage]; exist {
 			appNotif.Body = val
@@ -208,37 +218,78 @@ func OnAnItemLevelEvent(ctx context.Context, usrID string, entityCategory int, e
 	if err != nil {
 		log.Println("***>***> OnAnItemLevelEvent: unexpected/unhandled error occurred when retriving userSettingsMap. error:", err)
 	}
+	rules, err := RulesOf(ctx, db, accountID, recipientIDs(appNotif.Assignees, appNotif.Followers))
+	if err != nil {
+		log.Println("***>***> OnAnItemLevelEvent: unexpected/unhandled error occurred when retriving the routing rules. error:", err)
+	}
+	dirty := make([]string, 0, len(dirtyFields))
+	for key := range dirtyFields {
+		dirty = append(dirty, key)
+	}
+
+	now := time.Now()
+	inApp := make(map[string]bool, 0)
 	duplicateMasker := make(map[string]bool, 0)
-	//Send email/firebase notification to assignees/followers/creators
-	for _, assignee := range appNotif.Assignees {
-		log.Printf("assignee -- %+v", assignee)
-		if _, ok := duplicateMasker[assignee.UserID]; !ok {
-			if notifSettingMap, ok := userSettingsMap[assignee.UserID]; ok {
-				if notifSettingMap[user.NSAssigned] == "true" {
-					appNotif.Send(ctx, assignee, notificationType, db, firebaseSDKPath)
-				}
-			} else { // go with default flow if user settings not exist
-				appNotif.Send(ctx, assignee, notificationType, db, firebaseSDKPath)
+	//Queue email/firebase/slack notification to assignees/followers/creators through their routes
+	route := func(u entity.UserEntity, assignee bool) {
+		if duplicateMasker[u.UserID] {
+			return
+		}
+		duplicateMasker[u.UserID] = true
+		settings := userSettingsMap[u.UserID] // nil when the user settings not exist
+		routes := Routes(rules, u.UserID, entityID, notificationType, assignee, dirty, settings)
+		for _, rt := range routes {
+			if rt.Channel == ChannelInApp {
+				inApp[u.MemberID] = true
 			}
-			duplicateMasker[assignee.UserID] = true
 		}
+		due, err := appNotif.Enqueue(ctx, db, u, routes, notificationType, settings, now)
+		if err != nil {
+			log.Println("***>***> OnAnItemLevelEvent: unexpected/unhandled error occurred when queueing the notification. error:", err)
+		}
+		Deliver(ctx, db, firebaseSDKPath, due)
+	}
+	for _, assignee := range appNotif.Assignees {
+		route(assignee, true)
 	}
-
 	for _, follower := range appNotif.Followers {
-		log.Printf("follower -- %+v", follower)
-		if _, ok := duplicateMasker[follower.UserID]; !ok {
-			if notifSettingMap, ok := userSettingsMap[follower.UserID]; ok {
-				if (notificationType == TypeCreated && notifSettingMap[user.NSCreated] == "true") || (notificationType == TypeUpdated && notifSettingMap[user.NSUpdated] == "true") {
-					appNotif.Send(ctx, follower, notificationType, db, firebaseSDKPath)
-				}
-			} else { // go with default flow if user settings not exist
-				appNotif.Send(ctx, follower, notificationType, db, firebaseSDKPath)
-			}
-			duplicateMasker[follower.UserID] = true
-		}
+		route(follower, false)
+	}
+
+	// the in-app notification lists only the users routing it.
+	appNotif.Assignees = onlyMembers(appNotif.Assignees, inApp)
+	appNotif.Followers = onlyMembers(appNotif.Followers, inApp)
+	it, err := appNotif.Save(ctx, notificationType, db)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := append(append([]entity.UserEntity{}, appNotif.Assignees...), appNotif.Followers...)
+	if _, err := appNotif.Post(ctx, db, sdb, it.ID, recipients, notificationType, now); err != nil {
+		log.Println("***>***> OnAnItemLevelEvent: unexpected/unhandled error occurred when posting the notices. error:", err)
+	}
+	return it, nil
+}
+
+func recipientIDs(assignees, followers []entity.UserEntity) []string {
+	ids := make([]string, 0, len(assignees)+len(followers))
+	for _, u := range assignees {
+		ids = append(ids, u.UserID)
 	}
+	for _, u := range followers {
+		ids = append(ids, u.UserID)
+	}
+	return ids
+}
 
-	return appNotif.Save(ctx, notificationType, db)
+func onlyMembers(users []entity.UserEntity, members map[string]bool) []entity.UserEntity {
+	kept := make([]entity.UserEntity, 0, len(users))
+	for _, u := range users {
+		if members[u.MemberID] {
+			kept = append(kept, u)
+		}
+	}
+	return kept
 }
 
 func notificationSettings(ctx context.Context, accountID string, assignees, followers []entity.UserEntity, db *sqlx.DB) (map[string]map[string]string, error) {
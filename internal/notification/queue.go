@@ -0,0 +1,244 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// flushLimit is the deliveries sent in one flush, the rest wait for the next.
+const flushLimit = 500
+
+// sendTimeout is how long the claimed delivery is left in sending. The ones still there after it, when the
+// sender stopped midway, are claimed again by the flush.
+const sendTimeout = 15 * time.Minute
+
+// errUnsubscribed is returned by send when the user unsubscribed from the emails after they were queued.
+var errUnsubscribed = errors.New("the user unsubscribed from the emails")
+
+// DigestEntry is the notification listed in the digest.
+type DigestEntry struct {
+	Subject string
+	Body    string
+	Link    string
+}
+
+var digestText = template.Must(template.New("digest").Parse(
+	`{{range $i, $e := .}}{{if $i}}
+{{end}}• {{$e.Subject}}{{if $e.Body}} - {{$e.Body}}{{end}}{{end}}`))
+
+// Enqueue puts the notification of the user in the queue through the routes. The in-app route is not queued
+// as the notification is saved for everyone at once. The deliveries due now are returned claimed for the
+// caller to send right away, the rest wait for the flush.
+func (appNotif AppNotification) Enqueue(ctx context.Context, db *sqlx.DB, u entity.UserEntity, routes []Route, notifType NotificationType, settings map[string]string, now time.Time) ([]Delivery, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.Enqueue")
+	defer span.End()
+
+	due := make([]Delivery, 0)
+	for _, rt := range routes {
+		if rt.Channel == ChannelInApp {
+			continue
+		}
+		d := Delivery{
+			ID:           uuid.New().String(),
+			AccountID:    appNotif.AccountID,
+			UserID:       u.UserID,
+			Name:         u.Name,
+			Email:        u.Email,
+			Channel:      rt.Channel,
+			Digest:       rt.Digest,
+			SlackWebhook: rt.SlackWebhook,
+			Type:         int(notifType),
+			Subject:      appNotif.Subject,
+			Body:         appNotif.Body,
+			Link:         auth.SimpleLink(appNotif.AccountID, appNotif.AccountDomain, appNotif.TeamID, appNotif.EntityID, appNotif.ItemID),
+			SenderName:   appNotif.UserName,
+			SenderAvatar: appNotif.UserAvatar,
+			Status:       DeliveryPending,
+			DeliverAt:    DeliverAt(rt.Digest, settings, now),
+			CreatedAt:    now.UTC(),
+		}
+		if rt.Digest == DigestImmediate && !d.DeliverAt.After(now) {
+			claimedAt := now.UTC()
+			d.Status, d.ClaimedAt = DeliverySending, &claimedAt
+			due = append(due, d)
+		}
+
+		const q = `INSERT INTO notification_queue
+			(delivery_id, account_id, user_id, name, email, channel, digest, slack_webhook, type, subject, body, link,
+			sender_name, sender_avatar, status, deliver_at, created_at, claimed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`
+		_, err := db.ExecContext(
+			ctx, q,
+			d.ID, d.AccountID, d.UserID, d.Name, d.Email, d.Channel, d.Digest, d.SlackWebhook, d.Type, d.Subject, d.Body, d.Link,
+			d.SenderName, d.SenderAvatar, d.Status, d.DeliverAt, d.CreatedAt, d.ClaimedAt,
+		)
+		if err != nil {
+			return due, errors.Wrap(err, "queueing notification")
+		}
+	}
+	return due, nil
+}
+
+// Flush sends the deliveries due, the digests and the ones held in the quiet hours. The deliveries are claimed
+// before they are sent so that the flushes running together do not send them twice, and the ones claimed
+// but left in sending past the timeout are claimed again.
+func Flush(ctx context.Context, db *sqlx.DB, firebaseSDKPath string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.Flush")
+	defer span.End()
+
+	ds := []Delivery{}
+	const q = `UPDATE notification_queue SET status = $1, claimed_at = $3 WHERE delivery_id IN
+		(SELECT delivery_id FROM notification_queue
+		WHERE (status = $2 AND deliver_at <= $3) OR (status = $1 AND COALESCE(claimed_at, deliver_at) <= $4)
+		ORDER BY created_at LIMIT $5 FOR UPDATE SKIP LOCKED)
+		RETURNING *`
+	if err := db.SelectContext(ctx, &ds, q, DeliverySending, DeliveryPending, now.UTC(), now.Add(-sendTimeout).UTC(), flushLimit); err != nil {
+		return errors.Wrap(err, "claiming due notifications")
+	}
+
+	Deliver(ctx, db, firebaseSDKPath, ds)
+	return nil
+}
+
+// Deliver sends the claimed deliveries. The deliveries of the user for the same channel are sent as one digest.
+func Deliver(ctx context.Context, db *sqlx.DB, firebaseSDKPath string, ds []Delivery) {
+	groups := make(map[string][]Delivery, 0)
+	keys := make([]string, 0)
+	for _, d := range ds {
+		key := strings.Join([]string{d.AccountID, d.UserID, d.Channel, d.SlackWebhook}, "#")
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], d)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		status := DeliverySent
+		if err := send(ctx, db, firebaseSDKPath, group); err == errUnsubscribed {
+			status = DeliverySkipped
+		} else if err != nil {
+			log.Printf("***> notification of the user %s through %s not delivered. error: %v\n", group[0].UserID, group[0].Channel, err)
+			status = DeliveryFailed
+		}
+		ids := make([]string, len(group))
+		for i, d := range group {
+			ids[i] = d.ID
+		}
+		if err := markDeliveries(ctx, db, ids, status, time.Now()); err != nil {
+			log.Printf("***> notification deliveries of the user %s not marked. error: %v\n", group[0].UserID, err)
+		}
+	}
+}
+
+func send(ctx context.Context, db *sqlx.DB, firebaseSDKPath string, group []Delivery) error {
+	first := group[0]
+	notifType := NotificationType(first.Type)
+	subject, body, link := first.Subject, first.Body, first.Link
+	var entries []DigestEntry
+	if len(group) > 1 {
+		notifType = TypeDigest
+		subject, body, entries = Digest(group)
+	}
+
+	switch first.Channel {
+	case ChannelEmail:
+		// the digests wait for hours, the user could have unsubscribed meanwhile.
+		subscribed, err := emailSubscribed(ctx, db, first.AccountID, first.UserID)
+		if err != nil {
+			return err
+		}
+		if !subscribed {
+			return errUnsubscribed
+		}
+		emailNotif := EmailNotification{
+			AccountID: first.AccountID,
+			Name:      strings.Title(first.Name),
+			To:        []interface{}{first.Email},
+			Subject:   subject,
+			Body:      body,
+			MagicLink: link,
+			Entries:   entries,
+		}
+		return emailNotif.Send(ctx, notifType, db)
+	case ChannelPush:
+		fbNotif := FirebaseNotification{
+			AccountID:    first.AccountID,
+			TargetUserID: first.UserID,
+			UserName:     first.SenderName,
+			UserAvatar:   first.SenderAvatar,
+			CreatedAt:    first.CreatedAt,
+			Subject:      subject,
+			Body:         body,
+			SDKPath:      firebaseSDKPath,
+		}
+		return fbNotif.Send(ctx, notifType, db)
+	case ChannelSlack:
+		if !ValidSlackWebhook(first.SlackWebhook) {
+			return errors.New("the webhook is not of slack")
+		}
+		return slack.PostMessage(first.SlackWebhook, fmt.Sprintf("*%s*\n%s\n%s", subject, body, link))
+	}
+	return errors.Errorf("unknown channel %q", first.Channel)
+}
+
+// emailSubscribed tells the user still takes the notifications by email.
+func emailSubscribed(ctx context.Context, db *sqlx.DB, accountID, userID string) (bool, error) {
+	us, err := user.RetrieveUserSetting(ctx, db, accountID, userID)
+	if err == user.ErrNotFound {
+		// the user is gone with the settings.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.UnmarshalNotificationSettings(us.NotificationSetting)[user.NSEmailSubscription] != "false", nil
+}
+
+// Digest renders the subject and the body of the digest of the deliveries.
+func Digest(ds []Delivery) (string, string, []DigestEntry) {
+	entries := make([]DigestEntry, len(ds))
+	for i, d := range ds {
+		entries[i] = DigestEntry{Subject: d.Subject, Body: d.Body, Link: d.Link}
+	}
+
+	subject := fmt.Sprintf("%d updates while you were away", len(ds))
+	switch ds[0].Digest {
+	case DigestHourly:
+		subject = fmt.Sprintf("Your hourly digest: %d updates", len(ds))
+	case DigestDaily:
+		subject = fmt.Sprintf("Your daily digest: %d updates", len(ds))
+	}
+
+	var buf bytes.Buffer
+	if err := digestText.Execute(&buf, entries); err != nil {
+		log.Printf("***> notification digest not rendered. error: %v\n", err)
+	}
+	return subject, buf.String(), entries
+}
+
+func markDeliveries(ctx context.Context, db *sqlx.DB, ids []string, status int, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.markDeliveries")
+	defer span.End()
+
+	const q = `UPDATE notification_queue SET status = $1, sent_at = $2 WHERE delivery_id = any($3)`
+	if _, err := db.ExecContext(ctx, q, status, now.UTC(), pq.Array(ids)); err != nil {
+		return errors.Wrap(err, "marking notification deliveries")
+	}
+	return nil
+}
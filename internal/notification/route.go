@@ -0,0 +1,247 @@
+package notification
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/user"
+)
+
+// defaultDigestAt is the time of the daily digest, in minutes of the day, when the user has not set one.
+const defaultDigestAt = 8 * 60
+
+// slackWebhookPrefix is where the incoming webhooks of slack are. The worker posts to no other host.
+const slackWebhookPrefix = "https://hooks.slack.com/"
+
+// Route is the channel the notification of the user goes through.
+type Route struct {
+	Channel      string
+	Digest       string
+	SlackWebhook string
+}
+
+// Routes picks the channels of the user for the notification. The most specific rules decide: the rules of
+// the user for the entity, then the rules of the user for all the entities, then the rules for everyone for
+// the entity and at last the rules for everyone for all. Without any rule the notification settings of the
+// user decide, as they did before the rules. The email is never sent to the user unsubscribed from it.
+func Routes(rules []Rule, userID, entityID string, notifType NotificationType, assignee bool, dirty []string, settings map[string]string) []Route {
+	tier, applicable := -1, make([]Rule, 0)
+	for _, r := range rules {
+		t, ok := r.tier(userID, entityID)
+		if !ok || t < tier {
+			continue
+		}
+		if t > tier {
+			tier, applicable = t, applicable[:0]
+		}
+		applicable = append(applicable, r)
+	}
+
+	var routes []Route
+	if tier == -1 {
+		routes = defaultRoutes(notifType, assignee, settings)
+	} else {
+		event := eventOf(notifType)
+		for _, r := range applicable {
+			if !r.matches(event, assignee, dirty) {
+				continue
+			}
+			digest := r.Digest
+			if digest == "" {
+				digest = DigestImmediate
+			}
+			for _, ch := range r.Channels {
+				routes = append(routes, Route{Channel: ch, Digest: digest, SlackWebhook: r.SlackWebhook})
+			}
+		}
+	}
+
+	// one route a channel, the sooner digest wins.
+	best := make(map[string]Route, 0)
+	for _, rt := range routes {
+		if rt.Channel == ChannelEmail && settings[user.NSEmailSubscription] == "false" {
+			continue
+		}
+		if rt.Channel == ChannelSlack && !ValidSlackWebhook(rt.SlackWebhook) {
+			continue
+		}
+		if cur, ok := best[rt.Channel]; !ok || digestOrder(rt.Digest) < digestOrder(cur.Digest) {
+			best[rt.Channel] = rt
+		}
+	}
+	picked := make([]Route, 0, len(best))
+	for _, rt := range best {
+		picked = append(picked, rt)
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Channel < picked[j].Channel })
+	return picked
+}
+
+// defaultRoutes keeps the notifications of the users without the rules as they were. The in-app notification
+// is always saved, the email and the push follow the assigned, created and updated settings.
+func defaultRoutes(notifType NotificationType, assignee bool, settings map[string]string) []Route {
+	routes := []Route{{Channel: ChannelInApp, Digest: DigestImmediate}}
+	send := settings == nil
+	if !send && assignee {
+		send = settings[user.NSAssigned] == "true"
+	} else if !send {
+		send = (notifType == TypeCreated && settings[user.NSCreated] == "true") || (notifType == TypeUpdated && settings[user.NSUpdated] == "true")
+	}
+	if send {
+		routes = append(routes, Route{Channel: ChannelEmail, Digest: DigestImmediate}, Route{Channel: ChannelPush, Digest: DigestImmediate})
+	}
+	return routes
+}
+
+// tier tells how specific the rule is for the user and the entity. False when the rule does not apply.
+func (r Rule) tier(userID, entityID string) (int, bool) {
+	t := 0
+	if r.UserID != nil {
+		if *r.UserID != userID {
+			return 0, false
+		}
+		t += 2
+	}
+	if r.EntityID != nil {
+		if *r.EntityID != entityID {
+			return 0, false
+		}
+		t++
+	}
+	return t, true
+}
+
+// matches tells whether the rule routes the event. The rule with the fields routes the updates only when
+// one of them changed.
+func (r Rule) matches(event string, assignee bool, dirty []string) bool {
+	if len(r.Events) > 0 {
+		found := false
+		for _, ev := range r.Events {
+			if ev == event || (assignee && ev == EventAssigned) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if event == EventUpdated && len(r.Fields) > 0 {
+		for _, key := range r.Fields {
+			for _, d := range dirty {
+				if key == d {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func eventOf(notifType NotificationType) string {
+	switch notifType {
+	case TypeAssigned:
+		return EventAssigned
+	case TypeCreated, TypeMemberAdded:
+		return EventCreated
+	case TypeUpdated:
+		return EventUpdated
+	case TypeReminder:
+		return EventReminder
+	case TypeSLANearBreach, TypeSLABreached:
+		return EventSLA
+	case TypeEmailConversationAdded, TypeChatConversationAdded:
+		return EventComment
+	}
+	return ""
+}
+
+func digestOrder(digest string) int {
+	switch digest {
+	case DigestHourly:
+		return 1
+	case DigestDaily:
+		return 2
+	}
+	return 0
+}
+
+// DeliverAt returns when the notification of the digest reaches the user. The hourly digests go at the next
+// hour, the daily ones at the digest time of the user and nothing goes in the quiet hours of the user.
+func DeliverAt(digest string, settings map[string]string, now time.Time) time.Time {
+	at := now
+	switch digest {
+	case DigestHourly:
+		at = now.Truncate(time.Hour).Add(time.Hour)
+	case DigestDaily:
+		digestAt, ok := clock(settings[user.NSDigestAt])
+		if !ok {
+			digestAt = defaultDigestAt
+		}
+		at = nextClock(now.In(location(settings)), digestAt)
+	}
+	if until, quiet := QuietUntil(settings, at); quiet {
+		at = until
+	}
+	return at.UTC()
+}
+
+// QuietUntil tells whether the time is in the quiet hours of the user and when they end.
+func QuietUntil(settings map[string]string, t time.Time) (time.Time, bool) {
+	start, ok1 := clock(settings[user.NSQuietStart])
+	end, ok2 := clock(settings[user.NSQuietEnd])
+	if !ok1 || !ok2 || start == end {
+		return time.Time{}, false
+	}
+	local := t.In(location(settings))
+	m := local.Hour()*60 + local.Minute()
+	quiet := m >= start && m < end
+	if start > end { // the quiet hours over the midnight
+		quiet = m >= start || m < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+	return nextClock(local, end), true
+}
+
+func location(settings map[string]string) *time.Location {
+	if tz := settings[user.NSTimezone]; tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// clock parses the time of the day, such as 22:30, in minutes.
+func clock(s string) (int, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// nextClock returns the next time of the day after the local time.
+func nextClock(local time.Time, minutes int) time.Time {
+	y, mo, d := local.Date()
+	next := time.Date(y, mo, d, minutes/60, minutes%60, 0, 0, local.Location())
+	if !next.After(local) {
+		next = time.Date(y, mo, d+1, minutes/60, minutes%60, 0, 0, local.Location())
+	}
+	return next
+}
+
+// ValidSlackWebhook tells the webhook is of slack. The rules saved before it was checked could point anywhere.
+func ValidSlackWebhook(webhook string) bool {
+	return strings.HasPrefix(webhook, slackWebhookPrefix)
+}
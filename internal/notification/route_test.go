@@ -0,0 +1,108 @@
+package notification_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/notification"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+)
+
+func TestRoutes(t *testing.T) {
+	alice, deals := "alice", "deals"
+	rules := []notification.Rule{
+		{UserID: nil, EntityID: nil, Channels: []string{notification.ChannelInApp}},
+		{UserID: &alice, EntityID: &deals, Channels: []string{notification.ChannelEmail}, Events: []string{notification.EventUpdated}, Fields: []string{"stage"}, Digest: notification.DigestDaily},
+		{UserID: &alice, EntityID: &deals, Channels: []string{notification.ChannelEmail, notification.ChannelSlack}, Events: []string{notification.EventAssigned}, Digest: notification.DigestImmediate, SlackWebhook: "https://hooks.slack.com/x"},
+	}
+
+	t.Log("Given the need to route the notifications of the users")
+	{
+		cases := []struct {
+			name      string
+			userID    string
+			entityID  string
+			notifType notification.NotificationType
+			assignee  bool
+			dirty     []string
+			settings  map[string]string
+			want      string
+		}{
+			{"the stage change of the deal", alice, deals, notification.TypeUpdated, false, []string{"stage"}, nil, "email/daily"},
+			{"the amount change of the deal", alice, deals, notification.TypeUpdated, false, []string{"amount"}, nil, ""},
+			{"the change of the assigned deal", alice, deals, notification.TypeUpdated, true, []string{"stage"}, nil, "email/immediate slack/immediate"},
+			{"the assigned deal unsubscribed", alice, deals, notification.TypeUpdated, true, nil, map[string]string{user.NSEmailSubscription: "false"}, "slack/immediate"},
+			{"the task of alice", alice, "tasks", notification.TypeCreated, false, nil, nil, "in_app/immediate"},
+			{"the deal of bob", "bob", deals, notification.TypeCreated, false, nil, nil, "in_app/immediate"},
+		}
+		for _, c := range cases {
+			got := ""
+			for i, rt := range notification.Routes(rules, c.userID, c.entityID, c.notifType, c.assignee, c.dirty, c.settings) {
+				if i > 0 {
+					got += " "
+				}
+				got += fmt.Sprintf("%s/%s", rt.Channel, rt.Digest)
+			}
+			if got != c.want {
+				t.Fatalf("\t%s %s should go through %q. got %q", tests.Failed, c.name, c.want, got)
+			}
+			t.Logf("\t%s %s should go through %q", tests.Success, c.name, c.want)
+		}
+
+		t.Log("\twhen the webhook of the rule is not of slack")
+		{
+			internal := []notification.Rule{{UserID: &alice, Channels: []string{notification.ChannelSlack}, Digest: notification.DigestImmediate, SlackWebhook: "http://169.254.169.254/latest"}}
+			if rts := notification.Routes(internal, alice, deals, notification.TypeCreated, false, nil, nil); len(rts) != 0 {
+				t.Fatalf("\t%s should not post to the webhook. got %v", tests.Failed, rts)
+			}
+			t.Logf("\t%s should not post to the webhook", tests.Success)
+		}
+
+		t.Log("\twhen the user has no rules")
+		{
+			off := map[string]string{user.NSAssigned: "false", user.NSUpdated: "true"}
+			if rts := notification.Routes(nil, "bob", deals, notification.TypeUpdated, true, nil, off); len(rts) != 1 || rts[0].Channel != notification.ChannelInApp {
+				t.Fatalf("\t%s should follow the settings of the user. got %v", tests.Failed, rts)
+			}
+			if rts := notification.Routes(nil, "bob", deals, notification.TypeUpdated, false, nil, off); len(rts) != 3 {
+				t.Fatalf("\t%s should follow the settings of the user. got %v", tests.Failed, rts)
+			}
+			t.Logf("\t%s should follow the settings of the user", tests.Success)
+		}
+	}
+}
+
+func TestDeliverAt(t *testing.T) {
+	settings := map[string]string{
+		user.NSTimezone:   "Asia/Kolkata",
+		user.NSQuietStart: "22:00",
+		user.NSQuietEnd:   "07:00",
+		user.NSDigestAt:   "09:30",
+	}
+	ist := time.FixedZone("IST", 5*3600+1800)
+
+	t.Log("Given the need to hold the notifications for the quiet hours and the digests")
+	{
+		cases := []struct {
+			name   string
+			digest string
+			now    time.Time
+			want   time.Time
+		}{
+			{"the immediate at the noon", notification.DigestImmediate, time.Date(2026, 3, 2, 12, 0, 0, 0, ist), time.Date(2026, 3, 2, 12, 0, 0, 0, ist)},
+			{"the immediate at the night", notification.DigestImmediate, time.Date(2026, 3, 2, 23, 15, 0, 0, ist), time.Date(2026, 3, 3, 7, 0, 0, 0, ist)},
+			{"the immediate before the dawn", notification.DigestImmediate, time.Date(2026, 3, 3, 6, 59, 0, 0, ist), time.Date(2026, 3, 3, 7, 0, 0, 0, ist)},
+			{"the hourly at the evening", notification.DigestHourly, time.Date(2026, 3, 2, 8, 10, 0, 0, time.UTC), time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+			{"the hourly at the night", notification.DigestHourly, time.Date(2026, 3, 2, 18, 10, 0, 0, time.UTC), time.Date(2026, 3, 3, 7, 0, 0, 0, ist)},
+			{"the daily after the digest time", notification.DigestDaily, time.Date(2026, 3, 2, 10, 0, 0, 0, ist), time.Date(2026, 3, 3, 9, 30, 0, 0, ist)},
+		}
+		for _, c := range cases {
+			if got := notification.DeliverAt(c.digest, settings, c.now); !got.Equal(c.want) {
+				t.Fatalf("\t%s %s should be delivered at %v. got %v", tests.Failed, c.name, c.want, got.In(ist))
+			}
+			t.Logf("\t%s %s should be delivered at %v", tests.Success, c.name, c.want)
+		}
+	}
+}
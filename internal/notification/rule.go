@@ -0,0 +1,150 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// ErrRuleNotFound is used when a specific routing rule is requested but does not exist.
+var ErrRuleNotFound = errors.New("Notification rule not found")
+
+// CreateRule saves the routing rule. The rule of the user applies to the user alone, the one without
+// applies to everyone in the account.
+func CreateRule(ctx context.Context, db *sqlx.DB, accountID string, userID *string, nr NewRule, now time.Time) (Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.CreateRule")
+	defer span.End()
+
+	r := Rule{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		UserID:    userID,
+		CreatedAt: now.UTC(),
+	}
+	r.apply(nr, now)
+
+	const q = `INSERT INTO notification_rules
+		(rule_id, account_id, user_id, entity_id, channels, events, fields, digest, slack_webhook, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
+	_, err := db.ExecContext(
+		ctx, q,
+		r.ID, r.AccountID, r.UserID, r.EntityID, r.Channels, r.Events, r.Fields, r.Digest, r.SlackWebhook,
+		r.CreatedAt, r.UpdatedAt,
+	)
+	if err != nil {
+		return Rule{}, errors.Wrap(err, "inserting notification rule")
+	}
+
+	return r, nil
+}
+
+// UpdateRule replaces the routing of the rule. The user the rule applies to stays as it is.
+func UpdateRule(ctx context.Context, db *sqlx.DB, r Rule, nr NewRule, now time.Time) (Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.UpdateRule")
+	defer span.End()
+
+	r.apply(nr, now)
+
+	const q = `UPDATE notification_rules SET
+		"entity_id" = $3, "channels" = $4, "events" = $5, "fields" = $6, "digest" = $7, "slack_webhook" = $8, "updated_at" = $9
+		WHERE account_id = $1 AND rule_id = $2`
+	_, err := db.ExecContext(ctx, q, r.AccountID, r.ID,
+		r.EntityID, r.Channels, r.Events, r.Fields, r.Digest, r.SlackWebhook, r.UpdatedAt,
+	)
+	if err != nil {
+		return Rule{}, errors.Wrapf(err, "updating notification rule %q", r.ID)
+	}
+
+	return r, nil
+}
+
+// ListRules returns the rules of the user with the rules for everyone in the account.
+func ListRules(ctx context.Context, db *sqlx.DB, accountID, userID string) ([]Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.ListRules")
+	defer span.End()
+
+	rules := []Rule{}
+	const q = `SELECT * FROM notification_rules WHERE account_id = $1 AND (user_id = $2 OR user_id IS NULL) ORDER BY created_at`
+	if err := db.SelectContext(ctx, &rules, q, accountID, userID); err != nil {
+		return nil, errors.Wrap(err, "selecting notification rules")
+	}
+
+	return rules, nil
+}
+
+// RulesOf returns the rules routing the notifications of the users, including the rules for everyone.
+func RulesOf(ctx context.Context, db *sqlx.DB, accountID string, userIDs []string) ([]Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.RulesOf")
+	defer span.End()
+
+	rules := []Rule{}
+	const q = `SELECT * FROM notification_rules WHERE account_id = $1 AND (user_id = any($2) OR user_id IS NULL)`
+	if err := db.SelectContext(ctx, &rules, q, accountID, pq.Array(userIDs)); err != nil {
+		return nil, errors.Wrap(err, "selecting notification rules of the users")
+	}
+
+	return rules, nil
+}
+
+// RetrieveRule gets the routing rule.
+func RetrieveRule(ctx context.Context, db *sqlx.DB, accountID, ruleID string) (Rule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.RetrieveRule")
+	defer span.End()
+
+	if _, err := uuid.Parse(ruleID); err != nil {
+		return Rule{}, ErrInvalidID
+	}
+
+	var r Rule
+	const q = `SELECT * FROM notification_rules WHERE account_id = $1 AND rule_id = $2`
+	if err := db.GetContext(ctx, &r, q, accountID, ruleID); err != nil {
+		if err == sql.ErrNoRows {
+			return Rule{}, ErrRuleNotFound
+		}
+		return Rule{}, errors.Wrapf(err, "selecting notification rule %q", ruleID)
+	}
+
+	return r, nil
+}
+
+// DeleteRule removes the routing rule.
+func DeleteRule(ctx context.Context, db *sqlx.DB, accountID, ruleID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.notification.DeleteRule")
+	defer span.End()
+
+	const q = `DELETE FROM notification_rules WHERE account_id = $1 AND rule_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, ruleID); err != nil {
+		return errors.Wrapf(err, "deleting notification rule %q", ruleID)
+	}
+
+	return nil
+}
+
+func (r *Rule) apply(nr NewRule, now time.Time) {
+	if nr.Digest == "" {
+		nr.Digest = DigestImmediate
+	}
+	if nr.EntityID != nil && *nr.EntityID == "" {
+		nr.EntityID = nil
+	}
+	r.EntityID = nr.EntityID
+	r.Channels = nonNil(nr.Channels)
+	r.Events = nonNil(nr.Events)
+	r.Fields = nonNil(nr.Fields)
+	r.Digest = nr.Digest
+	r.SlackWebhook = nr.SlackWebhook
+	r.UpdatedAt = now.UTC().Unix()
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
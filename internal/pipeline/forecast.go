@@ -0,0 +1,372 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+	"go.opencensus.io/trace"
+)
+
+// pageSize is the number of the items read at once while collecting the deals.
+const pageSize = 500
+
+// ErrNoAmount is used when the entity of the pipeline has no amount field to forecast.
+var ErrNoAmount = errors.New("Pipeline entity has no amount field to forecast")
+
+// categories in the order the forecasts list them.
+var categories = []string{CategoryCommit, CategoryBestCase, CategoryPipeline, CategoryWon, CategoryLost}
+
+// keys are the fields of the entity the deals are read from.
+type keys struct {
+	flow   string
+	stage  string
+	amount string
+	close  string
+	owner  string
+}
+
+// Project forecasts the deals of the pipeline closing in the range by the period of their close date.
+func Project(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, f flow.Flow, period string, start, end time.Time, s timerange.Settings) (Forecast, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Project")
+	defer span.End()
+
+	deals, stages, err := collect(ctx, db, sdb, f)
+	if err != nil {
+		return Forecast{}, err
+	}
+	rows, totals, err := weigh(deals, stages, period, start, end, s)
+	if err != nil {
+		return Forecast{}, err
+	}
+	if period == "" {
+		period = timerange.PeriodMonth
+	}
+	return Forecast{Period: period, Rows: rows, Totals: totals}, nil
+}
+
+// TakeSnapshot keeps the forecast of all the deals of the pipeline for the week of the time.
+func TakeSnapshot(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, f flow.Flow, s timerange.Settings, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.TakeSnapshot")
+	defer span.End()
+
+	deals, stages, err := collect(ctx, db, sdb, f)
+	if err != nil {
+		return err
+	}
+	week, err := timerange.Period(timerange.PeriodWeek, now, s)
+	if err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO forecast_snapshots
+		(account_id, flow_id, week, category, deals, amount, weighted, taken_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (flow_id, week, category) DO UPDATE SET deals = $5, amount = $6, weighted = $7, taken_at = $8`
+	for _, sn := range tally(deals, stages) {
+		_, err := db.ExecContext(ctx, q, f.AccountID, f.ID, week, sn.Category, sn.Deals, sn.Amount, sn.Weighted, now.UTC())
+		if err != nil {
+			return errors.Wrap(err, "upserting forecast snapshot")
+		}
+	}
+	return nil
+}
+
+// Trends returns the snapshots of the pipeline of the last weeks with the changes of the latest week.
+func Trends(ctx context.Context, db *sqlx.DB, accountID, flowID string, weeks int, now time.Time) (Trend, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Trends")
+	defer span.End()
+
+	snapshots := []Snapshot{}
+	const q = `SELECT * FROM forecast_snapshots WHERE account_id = $1 AND flow_id = $2 AND week >= $3 ORDER BY week DESC, category`
+	if err := db.SelectContext(ctx, &snapshots, q, accountID, flowID, now.UTC().AddDate(0, 0, -7*weeks)); err != nil {
+		return Trend{}, errors.Wrap(err, "selecting forecast snapshots")
+	}
+	return Trend{Snapshots: snapshots, Changes: compare(snapshots)}, nil
+}
+
+// collect reads the deals of the pipeline with its stages by their node.
+func collect(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, f flow.Flow) ([]Deal, map[string]Stage, error) {
+	e, err := entity.Retrieve(ctx, f.AccountID, f.EntityID, db, sdb)
+	if err != nil {
+		return nil, nil, err
+	}
+	k, err := keysOf(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	stages, err := Stages(ctx, db, f.AccountID, f.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	stageMap := make(map[string]Stage, len(stages))
+	for _, s := range stages {
+		stageMap[s.NodeID] = s
+	}
+
+	deals := make([]Deal, 0)
+	afterID := ""
+	for {
+		// only the items of the pipeline are read when the entity has the flow field.
+		var items []item.Item
+		if k.flow != "" {
+			items, err = item.PageHaving(ctx, f.AccountID, f.EntityID, k.flow, f.ID, afterID, pageSize, db)
+		} else {
+			items, err = item.Page(ctx, f.AccountID, f.EntityID, afterID, pageSize, db)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(items) == 0 {
+			return deals, stageMap, nil
+		}
+		for _, it := range items {
+			if d, ok := dealOf(k, f.ID, it); ok {
+				deals = append(deals, d)
+			}
+		}
+		afterID = items[len(items)-1].ID
+	}
+}
+
+// keysOf finds the pipeline, the stage, the amount, the close date and the owner fields of the entity.
+func keysOf(e entity.Entity) (keys, error) {
+	var k keys
+	fields := e.EasyFields()
+	for _, f := range fields {
+		if f.IsFlow() && k.flow == "" {
+			k.flow = f.Key
+		}
+	}
+	for _, f := range fields {
+		switch {
+		case f.IsNode() && f.Dependent != nil && f.Dependent.ParentKey == k.flow:
+			k.stage = f.Key
+		case f.Who == entity.WhoAmount:
+			k.amount = f.Key
+		case f.Who == entity.WhoCloseDate:
+			k.close = f.Key
+		case f.Who == entity.WhoAssignee:
+			k.owner = f.Key
+		}
+	}
+	if k.amount == "" {
+		return k, errors.Wrapf(ErrNoAmount, "entity %q", e.ID)
+	}
+	return k, nil
+}
+
+// dealOf reads the deal from the item in the pipeline, false for the items of the other pipelines.
+func dealOf(k keys, flowID string, it item.Item) (Deal, bool) {
+	fields := it.Fields()
+	if k.flow != "" && valueOf(fields[k.flow]) != flowID {
+		return Deal{}, false
+	}
+	d := Deal{ItemID: it.ID, NodeID: valueOf(fields[k.stage])}
+	d.Amount, _ = number(fields[k.amount])
+	d.CloseAt, _ = date(valueOf(fields[k.close]))
+	d.Owner = valueOf(fields[k.owner])
+	if d.Owner == "" && it.UserID != nil {
+		d.Owner = *it.UserID
+	}
+	return d, true
+}
+
+// weigh groups the deals closing in the range by the period, the owner and the category. The lost deals and
+// the deals without the close date are left out.
+func weigh(deals []Deal, stages map[string]Stage, period string, start, end time.Time, s timerange.Settings) ([]Row, []Row, error) {
+	type group struct {
+		period   time.Time
+		owner    string
+		category string
+	}
+	grouped := make(map[group]*Row)
+	totals := make(map[string]*Row)
+	for _, d := range deals {
+		st := stages[d.NodeID]
+		category := st.category()
+		if category == CategoryLost || d.CloseAt.IsZero() || !within(d.CloseAt, start, end) {
+			continue
+		}
+		p, err := timerange.Period(period, d.CloseAt, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		weighted := worth(d, st)
+
+		g := group{period: p, owner: d.Owner, category: category}
+		if grouped[g] == nil {
+			grouped[g] = &Row{Period: p, Owner: d.Owner, Category: category}
+		}
+		if totals[category] == nil {
+			totals[category] = &Row{Category: category}
+		}
+		for _, r := range []*Row{grouped[g], totals[category]} {
+			r.Deals++
+			r.Amount += d.Amount
+			r.Weighted += weighted
+		}
+	}
+
+	rows := make([]Row, 0, len(grouped))
+	for _, r := range grouped {
+		rows = append(rows, *r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].Period.Equal(rows[j].Period) {
+			return rows[i].Period.Before(rows[j].Period)
+		}
+		if rows[i].Owner != rows[j].Owner {
+			return rows[i].Owner < rows[j].Owner
+		}
+		return rank(rows[i].Category) < rank(rows[j].Category)
+	})
+
+	totalRows := make([]Row, 0, len(totals))
+	for _, c := range categories {
+		if r, ok := totals[c]; ok {
+			totalRows = append(totalRows, *r)
+		}
+	}
+	return rows, totalRows, nil
+}
+
+// tally totals all the deals by the category for the snapshot, listing every category so the week shows the
+// ones emptied.
+func tally(deals []Deal, stages map[string]Stage) []Snapshot {
+	totals := make(map[string]*Snapshot, len(categories))
+	for _, c := range categories {
+		totals[c] = &Snapshot{Category: c}
+	}
+	for _, d := range deals {
+		st := stages[d.NodeID]
+		sn := totals[st.category()]
+		sn.Deals++
+		sn.Amount += d.Amount
+		sn.Weighted += worth(d, st)
+	}
+
+	snapshots := make([]Snapshot, 0, len(categories))
+	for _, c := range categories {
+		snapshots = append(snapshots, *totals[c])
+	}
+	return snapshots
+}
+
+// compare changes the categories of the latest week of the snapshots, ordered newest first, against the week
+// before it. The categories missing in the week before changed from nothing.
+func compare(snapshots []Snapshot) []Change {
+	changes := make([]Change, 0)
+	if len(snapshots) == 0 {
+		return changes
+	}
+	latest := snapshots[0].Week
+	var prev time.Time
+	for _, sn := range snapshots {
+		if sn.Week.Before(latest) {
+			prev = sn.Week
+			break
+		}
+	}
+
+	before := make(map[string]Snapshot)
+	for _, sn := range snapshots {
+		if !prev.IsZero() && sn.Week.Equal(prev) {
+			before[sn.Category] = sn
+		}
+	}
+	for _, sn := range snapshots {
+		if !sn.Week.Equal(latest) {
+			continue
+		}
+		b := before[sn.Category]
+		changes = append(changes, Change{
+			Category:      sn.Category,
+			Deals:         sn.Deals,
+			Amount:        sn.Amount,
+			Weighted:      sn.Weighted,
+			DealsDelta:    sn.Deals - b.Deals,
+			AmountDelta:   sn.Amount - b.Amount,
+			WeightedDelta: sn.Weighted - b.Weighted,
+		})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return rank(changes[i].Category) < rank(changes[j].Category) })
+	return changes
+}
+
+// worth is the amount of the deal weighted by the probability of its stage. The won deals are worth all of it
+// and the lost ones nothing.
+func worth(d Deal, st Stage) float64 {
+	switch st.category() {
+	case CategoryWon:
+		return d.Amount
+	case CategoryLost:
+		return 0
+	}
+	return d.Amount * float64(st.Probability) / 100
+}
+
+func rank(category string) int {
+	for i, c := range categories {
+		if c == category {
+			return i
+		}
+	}
+	return len(categories)
+}
+
+// date reads the close date kept by the forms as the time or the day.
+func date(s string) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	if t, err := util.ParseTime(s); err == nil {
+		return t, true
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// valueOf is the value of the field, the first one of the references.
+func valueOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []interface{}:
+		if len(t) > 0 {
+			return fmt.Sprint(t[0])
+		}
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// number is the value of the number field, which the forms could keep as the string.
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
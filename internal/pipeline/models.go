@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// Forecast categories of the stages. The open deals are forecasted as the pipeline, the best case or the commit,
+// the won deals are closed and the lost ones are left out of the forecast.
+const (
+	CategoryPipeline = "pipeline"
+	CategoryBestCase = "best_case"
+	CategoryCommit   = "commit"
+	CategoryWon      = "won"
+	CategoryLost     = "lost"
+)
+
+// Probabilities from which the stages without the category are forecasted as the best case or the commit.
+const (
+	BestCaseFrom = 40
+	CommitFrom   = 70
+)
+
+// Stage is the stage node of the pipeline with the chance of the deals in it to be won and the days they are
+// expected to spend in it. The stages never set are returned with their defaults.
+type Stage struct {
+	AccountID    string `db:"account_id" json:"account_id"`
+	FlowID       string `db:"flow_id" json:"flow_id"`
+	NodeID       string `db:"node_id" json:"node_id"`
+	Name         string `db:"-" json:"name"`
+	Probability  int    `db:"probability" json:"probability"`
+	ExpectedDays int    `db:"expected_days" json:"expected_days"`
+	Category     string `db:"category" json:"category"`
+	UpdatedAt    int64  `db:"updated_at" json:"updated_at"`
+}
+
+// NewStage contains information needed to set the stage.
+type NewStage struct {
+	Probability  int    `json:"probability"`
+	ExpectedDays int    `json:"expected_days"`
+	Category     string `json:"category"`
+}
+
+// Transition is the move of the item into the stage. The from node is nil when the item enters the pipeline
+// and the spent is the seconds the item was in the stage it left.
+type Transition struct {
+	AccountID  string    `db:"account_id" json:"account_id"`
+	FlowID     string    `db:"flow_id" json:"flow_id"`
+	ItemID     string    `db:"item_id" json:"item_id"`
+	FromNodeID *string   `db:"from_node_id" json:"from_node_id"`
+	ToNodeID   string    `db:"to_node_id" json:"to_node_id"`
+	Spent      int64     `db:"spent" json:"spent"`
+	MovedAt    time.Time `db:"moved_at" json:"moved_at"`
+}
+
+// Conversion is how many of the items entering the stage in the range went on to a later stage. The lost
+// stages end the items, so moving into them is not advancing.
+type Conversion struct {
+	NodeID   string  `json:"node_id"`
+	Name     string  `json:"name"`
+	Entered  int     `json:"entered"`
+	Advanced int     `json:"advanced"`
+	Won      int     `json:"won"`
+	Rate     float64 `json:"rate"`
+	WinRate  float64 `json:"win_rate"`
+}
+
+// Velocity is the days the items leaving the stage in the range spent in it. The slow are the ones over the
+// expected days of the stage.
+type Velocity struct {
+	NodeID       string  `json:"node_id"`
+	Name         string  `json:"name"`
+	Moves        int     `json:"moves"`
+	AvgDays      float64 `json:"avg_days"`
+	MedianDays   float64 `json:"median_days"`
+	ExpectedDays int     `json:"expected_days"`
+	Slow         int     `json:"slow"`
+}
+
+// Deal is the item of the pipeline as the forecast reads it. The owner is the assignee of the item, or the
+// user who added it when the entity has none.
+type Deal struct {
+	ItemID  string    `json:"item_id"`
+	NodeID  string    `json:"node_id"`
+	Owner   string    `json:"owner"`
+	Amount  float64   `json:"amount"`
+	CloseAt time.Time `json:"close_at"`
+}
+
+// Row is the deals of the owner closing in the period in the category, with their amount weighted by the
+// probability of their stages.
+type Row struct {
+	Period   time.Time `json:"period"`
+	Owner    string    `json:"owner"`
+	Category string    `json:"category"`
+	Deals    int       `json:"deals"`
+	Amount   float64   `json:"amount"`
+	Weighted float64   `json:"weighted"`
+}
+
+// Forecast is the deals closing in the range by the period, the owner and the category with their totals by
+// the category.
+type Forecast struct {
+	Period string `json:"period"`
+	Rows   []Row  `json:"rows"`
+	Totals []Row  `json:"totals"`
+}
+
+// Snapshot is the forecast of all the deals of the pipeline in the category taken in the week. The snapshot of
+// the week is taken again until the week ends, so it keeps how the week closed.
+type Snapshot struct {
+	AccountID string    `db:"account_id" json:"account_id"`
+	FlowID    string    `db:"flow_id" json:"flow_id"`
+	Week      time.Time `db:"week" json:"week"`
+	Category  string    `db:"category" json:"category"`
+	Deals     int       `db:"deals" json:"deals"`
+	Amount    float64   `db:"amount" json:"amount"`
+	Weighted  float64   `db:"weighted" json:"weighted"`
+	TakenAt   time.Time `db:"taken_at" json:"taken_at"`
+}
+
+// Change is the category of the latest week against the week before it.
+type Change struct {
+	Category      string  `json:"category"`
+	Deals         int     `json:"deals"`
+	Amount        float64 `json:"amount"`
+	Weighted      float64 `json:"weighted"`
+	DealsDelta    int     `json:"deals_delta"`
+	AmountDelta   float64 `json:"amount_delta"`
+	WeightedDelta float64 `json:"weighted_delta"`
+}
+
+// Trend is the weekly snapshots of the pipeline, newest first, with the changes of the latest week.
+type Trend struct {
+	Snapshots []Snapshot `json:"snapshots"`
+	Changes   []Change   `json:"changes"`
+}
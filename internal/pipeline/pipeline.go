@@ -0,0 +1,385 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/rule/flow"
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"go.opencensus.io/trace"
+)
+
+const secondsInDay = 24 * 60 * 60
+
+var (
+	// ErrNotFound is used when the pipeline or its stage does not exist.
+	ErrNotFound = errors.New("Pipeline not found")
+
+	// ErrInvalidStage is used when the probability, the expected days or the category of the stage are not valid.
+	ErrInvalidStage = errors.New("Stage is not in its proper form")
+)
+
+// Retrieve gets the pipeline of the account, telling not found for the flows in the other modes.
+func Retrieve(ctx context.Context, db *sqlx.DB, accountID, flowID string) (flow.Flow, error) {
+	f, err := flow.Retrieve(ctx, flowID, db)
+	if err == flow.ErrNotFound || err == flow.ErrInvalidID {
+		return flow.Flow{}, ErrNotFound
+	}
+	if err != nil {
+		return flow.Flow{}, err
+	}
+	if f.AccountID != accountID || f.Mode != flow.FlowModePipeLine {
+		return flow.Flow{}, ErrNotFound
+	}
+	return f, nil
+}
+
+// Stages returns the stages of the pipeline in their order with what was set on them.
+func Stages(ctx context.Context, db *sqlx.DB, accountID, flowID string) ([]Stage, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Stages")
+	defer span.End()
+
+	nodes, err := node.Stages(ctx, accountID, []string{flowID}, "", db)
+	if err != nil {
+		return nil, err
+	}
+
+	set := []Stage{}
+	const q = `SELECT * FROM pipeline_stages WHERE account_id = $1 AND flow_id = $2`
+	if err := db.SelectContext(ctx, &set, q, accountID, flowID); err != nil {
+		return nil, errors.Wrap(err, "selecting pipeline stages")
+	}
+	setMap := make(map[string]Stage, len(set))
+	for _, s := range set {
+		setMap[s.NodeID] = s
+	}
+
+	stages := make([]Stage, 0, len(nodes))
+	for _, n := range order(nodes) {
+		s, ok := setMap[n.ID]
+		if !ok {
+			s = Stage{AccountID: accountID, FlowID: flowID, NodeID: n.ID}
+		}
+		s.Name = n.Name
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
+
+// SaveStage sets the probability, the expected days and the category of the stage.
+func SaveStage(ctx context.Context, db *sqlx.DB, accountID, flowID, nodeID string, ns NewStage, now time.Time) (Stage, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.SaveStage")
+	defer span.End()
+
+	if err := validate(ns); err != nil {
+		return Stage{}, err
+	}
+	n, err := node.Retrieve(ctx, accountID, flowID, nodeID, db)
+	if err == node.ErrNodeNotFound || err == node.ErrInvalidID {
+		return Stage{}, ErrNotFound
+	}
+	if err != nil {
+		return Stage{}, err
+	}
+	if !n.IsStageNode() {
+		return Stage{}, errors.Wrapf(ErrInvalidStage, "node %q is not a stage", nodeID)
+	}
+
+	s := Stage{
+		AccountID:    accountID,
+		FlowID:       flowID,
+		NodeID:       nodeID,
+		Name:         n.Name,
+		Probability:  ns.Probability,
+		ExpectedDays: ns.ExpectedDays,
+		Category:     ns.Category,
+		UpdatedAt:    now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO pipeline_stages
+		(account_id, flow_id, node_id, probability, expected_days, category, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (flow_id, node_id) DO UPDATE SET probability = $4, expected_days = $5, category = $6, updated_at = $7`
+	if _, err := db.ExecContext(ctx, q, s.AccountID, s.FlowID, s.NodeID, s.Probability, s.ExpectedDays, s.Category, s.UpdatedAt); err != nil {
+		return Stage{}, errors.Wrap(err, "upserting pipeline stage")
+	}
+	return s, nil
+}
+
+// Move records the item moving into the stage with the time it spent in the stage it left. Moving into the
+// stage the item is already in is not recorded.
+func Move(ctx context.Context, db *sqlx.DB, accountID, flowID, itemID, nodeID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Move")
+	defer span.End()
+
+	t := Transition{
+		AccountID: accountID,
+		FlowID:    flowID,
+		ItemID:    itemID,
+		ToNodeID:  nodeID,
+		MovedAt:   now.UTC(),
+	}
+
+	var last Transition
+	const lq = `SELECT * FROM stage_transitions WHERE flow_id = $1 AND item_id = $2 ORDER BY moved_at DESC LIMIT 1`
+	err := db.GetContext(ctx, &last, lq, flowID, itemID)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "selecting last stage transition")
+	}
+	if err == nil {
+		if last.ToNodeID == nodeID {
+			return nil
+		}
+		t.FromNodeID = &last.ToNodeID
+		t.Spent = int64(t.MovedAt.Sub(last.MovedAt.UTC()).Seconds())
+	}
+
+	const q = `INSERT INTO stage_transitions
+		(account_id, flow_id, item_id, from_node_id, to_node_id, spent, moved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := db.ExecContext(ctx, q, t.AccountID, t.FlowID, t.ItemID, t.FromNodeID, t.ToNodeID, t.Spent, t.MovedAt); err != nil {
+		return errors.Wrap(err, "inserting stage transition")
+	}
+	return nil
+}
+
+// Transitions returns the moves of the item in the pipeline, oldest first.
+func Transitions(ctx context.Context, db *sqlx.DB, accountID, flowID, itemID string) ([]Transition, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Transitions")
+	defer span.End()
+
+	transitions := []Transition{}
+	const q = `SELECT * FROM stage_transitions WHERE account_id = $1 AND flow_id = $2 AND item_id = $3 ORDER BY moved_at`
+	if err := db.SelectContext(ctx, &transitions, q, accountID, flowID, itemID); err != nil {
+		return nil, errors.Wrap(err, "selecting stage transitions")
+	}
+	return transitions, nil
+}
+
+// Conversions returns how the items entering the stages of the pipeline in the range went on.
+func Conversions(ctx context.Context, db *sqlx.DB, accountID, flowID string, start, end time.Time) ([]Conversion, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Conversions")
+	defer span.End()
+
+	stages, err := Stages(ctx, db, accountID, flowID)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := moved(ctx, db, accountID, flowID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return convert(stages, transitions, start, end), nil
+}
+
+// Velocities returns the days the items leaving the stages of the pipeline in the range spent in them.
+func Velocities(ctx context.Context, db *sqlx.DB, accountID, flowID string, start, end time.Time) ([]Velocity, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.pipeline.Velocities")
+	defer span.End()
+
+	stages, err := Stages(ctx, db, accountID, flowID)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := moved(ctx, db, accountID, flowID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return velocity(stages, transitions, start, end), nil
+}
+
+// moved returns all the moves of the items moved in the range, as the conversion follows them after it.
+func moved(ctx context.Context, db *sqlx.DB, accountID, flowID string, start, end time.Time) ([]Transition, error) {
+	transitions := []Transition{}
+	const q = `SELECT * FROM stage_transitions WHERE account_id = $1 AND flow_id = $2 AND item_id IN
+		(SELECT item_id FROM stage_transitions WHERE account_id = $1 AND flow_id = $2 AND moved_at >= $3 AND moved_at < $4)
+		ORDER BY item_id, moved_at`
+	if err := db.SelectContext(ctx, &transitions, q, accountID, flowID, start.UTC(), end.UTC()); err != nil {
+		return nil, errors.Wrap(err, "selecting moved stage transitions")
+	}
+	return transitions, nil
+}
+
+// order puts the stages one after the other from the stage under the root, as the pipelines chain them. The
+// stages not in the chain follow by their weight.
+func order(nodes []node.Node) []node.Node {
+	byParent := make(map[string]node.Node, len(nodes))
+	ids := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		byParent[n.ParentNodeID] = n
+		ids[n.ID] = true
+	}
+
+	first, ok := byParent[node.Root]
+	if !ok {
+		for _, n := range nodes {
+			if !ids[n.ParentNodeID] {
+				first, ok = n, true
+				break
+			}
+		}
+	}
+
+	ordered := make([]node.Node, 0, len(nodes))
+	placed := make(map[string]bool, len(nodes))
+	for next := first; ok && !placed[next.ID]; next, ok = byParent[next.ID] {
+		ordered = append(ordered, next)
+		placed[next.ID] = true
+	}
+
+	rest := make([]node.Node, 0)
+	for _, n := range nodes {
+		if !placed[n.ID] {
+			rest = append(rest, n)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool { return rest[i].Weight < rest[j].Weight })
+	return append(ordered, rest...)
+}
+
+// convert counts the items entering each stage in the range, the ones of them later moving to a stage after it
+// and the ones won. The transitions are grouped by the item in the order they moved.
+func convert(stages []Stage, transitions []Transition, start, end time.Time) []Conversion {
+	index := make(map[string]int, len(stages))
+	for i, s := range stages {
+		index[s.NodeID] = i
+	}
+
+	conversions := make([]Conversion, len(stages))
+	for i, s := range stages {
+		conversions[i] = Conversion{NodeID: s.NodeID, Name: s.Name}
+	}
+
+	for _, moves := range byItem(transitions) {
+		counted := make(map[int]bool)
+		for i, t := range moves {
+			at, ok := index[t.ToNodeID]
+			if !ok || counted[at] || !within(t.MovedAt, start, end) {
+				continue
+			}
+			counted[at] = true
+			c := &conversions[at]
+			c.Entered++
+
+			var advanced, won bool
+			for _, later := range moves[i:] {
+				to, ok := index[later.ToNodeID]
+				if !ok {
+					continue
+				}
+				switch stages[to].category() {
+				case CategoryWon:
+					won = true
+					advanced = advanced || to > at
+				case CategoryLost:
+				default:
+					advanced = advanced || to > at
+				}
+			}
+			if advanced {
+				c.Advanced++
+			}
+			if won {
+				c.Won++
+			}
+		}
+	}
+
+	for i := range conversions {
+		c := &conversions[i]
+		if c.Entered > 0 {
+			c.Rate = float64(c.Advanced) / float64(c.Entered)
+			c.WinRate = float64(c.Won) / float64(c.Entered)
+		}
+	}
+	return conversions
+}
+
+// velocity measures the days spent in each stage by the items leaving it in the range.
+func velocity(stages []Stage, transitions []Transition, start, end time.Time) []Velocity {
+	spent := make(map[string][]float64, len(stages))
+	for _, t := range transitions {
+		if t.FromNodeID == nil || !within(t.MovedAt, start, end) {
+			continue
+		}
+		spent[*t.FromNodeID] = append(spent[*t.FromNodeID], float64(t.Spent)/secondsInDay)
+	}
+
+	velocities := make([]Velocity, len(stages))
+	for i, s := range stages {
+		v := Velocity{NodeID: s.NodeID, Name: s.Name, ExpectedDays: s.ExpectedDays}
+		days := spent[s.NodeID]
+		if len(days) > 0 {
+			sort.Float64s(days)
+			var sum float64
+			for _, d := range days {
+				sum += d
+				if s.ExpectedDays > 0 && d > float64(s.ExpectedDays) {
+					v.Slow++
+				}
+			}
+			v.Moves = len(days)
+			v.AvgDays = sum / float64(len(days))
+			v.MedianDays = median(days)
+		}
+		velocities[i] = v
+	}
+	return velocities
+}
+
+// byItem groups the transitions ordered by the item and the time into the moves of each item.
+func byItem(transitions []Transition) map[string][]Transition {
+	moves := make(map[string][]Transition)
+	for _, t := range transitions {
+		moves[t.ItemID] = append(moves[t.ItemID], t)
+	}
+	for _, m := range moves {
+		sort.SliceStable(m, func(i, j int) bool { return m[i].MovedAt.Before(m[j].MovedAt) })
+	}
+	return moves
+}
+
+// median of the sorted values.
+func median(sorted []float64) float64 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func within(t, start, end time.Time) bool {
+	return !t.Before(start) && t.Before(end)
+}
+
+func validate(ns NewStage) error {
+	if ns.Probability < 0 || ns.Probability > 100 {
+		return errors.Wrapf(ErrInvalidStage, "probability %d is not between 0 and 100", ns.Probability)
+	}
+	if ns.ExpectedDays < 0 {
+		return errors.Wrapf(ErrInvalidStage, "expected days %d is negative", ns.ExpectedDays)
+	}
+	switch ns.Category {
+	case "", CategoryPipeline, CategoryBestCase, CategoryCommit, CategoryWon, CategoryLost:
+		return nil
+	}
+	return errors.Wrapf(ErrInvalidStage, "category %q not known", ns.Category)
+}
+
+// category of the stage, by its probability when it was not set.
+func (s Stage) category() string {
+	if s.Category != "" {
+		return s.Category
+	}
+	switch {
+	case s.Probability >= CommitFrom:
+		return CategoryCommit
+	case s.Probability >= BestCaseFrom:
+		return CategoryBestCase
+	}
+	return CategoryPipeline
+}
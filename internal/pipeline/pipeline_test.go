@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+)
+
+var (
+	day0   = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	stages = []Stage{
+		{NodeID: "lead", Name: "Lead", Probability: 10, ExpectedDays: 5},
+		{NodeID: "proposal", Name: "Proposal", Probability: 50, ExpectedDays: 10},
+		{NodeID: "won", Name: "Won", Probability: 100, Category: CategoryWon},
+		{NodeID: "lost", Name: "Lost", Category: CategoryLost},
+	}
+)
+
+func move(itemID, from, to string, days int, spent float64) Transition {
+	t := Transition{ItemID: itemID, ToNodeID: to, MovedAt: day0.AddDate(0, 0, days), Spent: int64(spent * secondsInDay)}
+	if from != "" {
+		t.FromNodeID = &from
+	}
+	return t
+}
+
+func TestOrder(t *testing.T) {
+	t.Log("Given the need to put the stages of the pipeline in their order")
+	{
+		nodes := []node.Node{
+			{ID: "won", ParentNodeID: "proposal"},
+			{ID: "orphan", ParentNodeID: "gone", Weight: 2},
+			{ID: "proposal", ParentNodeID: "lead"},
+			{ID: "lead", ParentNodeID: node.Root},
+		}
+		got := ""
+		for _, n := range order(nodes) {
+			got += n.ID + " "
+		}
+		if got != "lead proposal won orphan " {
+			t.Fatalf("\t%s should follow the chain from the root : got %s", tests.Failed, got)
+		}
+		t.Logf("\t%s should follow the chain from the root and leave the others after it", tests.Success)
+	}
+}
+
+func TestReports(t *testing.T) {
+	transitions := []Transition{
+		move("a", "", "lead", 0, 0),
+		move("a", "lead", "proposal", 4, 4),
+		move("a", "proposal", "won", 16, 12),
+		move("b", "", "lead", 1, 0),
+		move("b", "lead", "lost", 3, 2),
+		move("c", "", "lead", 2, 0),
+		move("c", "lead", "proposal", 8, 6),
+		move("d", "", "lead", 40, 0), // after the range
+	}
+	start, end := day0, day0.AddDate(0, 0, 30)
+
+	t.Log("Given the need to measure how the items go through the pipeline")
+	{
+		cs := convert(stages, transitions, start, end)
+		lead, proposal, won := cs[0], cs[1], cs[2]
+		if lead.Entered != 3 || lead.Advanced != 2 || lead.Won != 1 {
+			t.Fatalf("\t%s should convert the stage : %+v", tests.Failed, lead)
+		}
+		if proposal.Entered != 2 || proposal.Advanced != 1 || proposal.Rate != 0.5 {
+			t.Fatalf("\t%s should count the won as advancing : %+v", tests.Failed, proposal)
+		}
+		if won.Entered != 1 || won.WinRate != 1 || cs[3].Advanced != 0 {
+			t.Fatalf("\t%s should not advance out of the last stages : %+v %+v", tests.Failed, won, cs[3])
+		}
+		t.Logf("\t%s should convert the items entering the stages in the range", tests.Success)
+
+		vs := velocity(stages, transitions, start, end)
+		if vs[0].Moves != 3 || vs[0].MedianDays != 4 || vs[0].AvgDays != 4 || vs[0].Slow != 1 {
+			t.Fatalf("\t%s should measure the days in the stage : %+v", tests.Failed, vs[0])
+		}
+		if vs[1].Moves != 1 || vs[1].Slow != 1 || vs[2].Moves != 0 {
+			t.Fatalf("\t%s should count the slow moves : %+v %+v", tests.Failed, vs[1], vs[2])
+		}
+		t.Logf("\t%s should measure the days spent by the items leaving the stages", tests.Success)
+	}
+}
+
+func TestForecast(t *testing.T) {
+	stageMap := make(map[string]Stage)
+	for _, s := range stages {
+		stageMap[s.NodeID] = s
+	}
+	stageMap["commit"] = Stage{NodeID: "commit", Probability: 80}
+	deals := []Deal{
+		{ItemID: "1", NodeID: "proposal", Owner: "ann", Amount: 1000, CloseAt: day0.AddDate(0, 0, 3)},
+		{ItemID: "2", NodeID: "commit", Owner: "ann", Amount: 500, CloseAt: day0.AddDate(0, 0, 5)},
+		{ItemID: "3", NodeID: "won", Owner: "bob", Amount: 300, CloseAt: day0.AddDate(0, 1, 0)},
+		{ItemID: "4", NodeID: "lost", Owner: "bob", Amount: 900, CloseAt: day0},
+		{ItemID: "5", NodeID: "", Owner: "bob", Amount: 200},
+		{ItemID: "6", NodeID: "lead", Owner: "bob", Amount: 100, CloseAt: day0.AddDate(1, 0, 0)},
+	}
+
+	t.Log("Given the need to forecast the deals of the pipeline")
+	{
+		rows, totals, err := weigh(deals, stageMap, timerange.PeriodMonth, day0, day0.AddDate(0, 3, 0), timerange.Settings{})
+		if err != nil {
+			t.Fatalf("\t%s should weigh the deals : %s", tests.Failed, err)
+		}
+		if len(rows) != 3 || rows[0].Category != CategoryCommit || rows[1].Weighted != 500 || rows[2].Owner != "bob" || rows[2].Weighted != 300 {
+			t.Fatalf("\t%s should group the deals by the period, the owner and the category : %+v", tests.Failed, rows)
+		}
+		if len(totals) != 3 || totals[0].Weighted != 400 || totals[2].Category != CategoryWon {
+			t.Fatalf("\t%s should total the categories : %+v", tests.Failed, totals)
+		}
+		t.Logf("\t%s should weigh the open deals by their stages and leave out the lost ones", tests.Success)
+
+		snaps := tally(deals, stageMap)
+		if len(snaps) != len(categories) || snaps[2].Deals != 2 || snaps[4].Amount != 900 || snaps[4].Weighted != 0 {
+			t.Fatalf("\t%s should tally all the deals : %+v", tests.Failed, snaps)
+		}
+		t.Logf("\t%s should tally all the deals by the category", tests.Success)
+
+		week := day0.AddDate(0, 0, 7)
+		for i := range snaps {
+			snaps[i].Week = day0
+		}
+		latest := []Snapshot{{Week: week, Category: CategoryPipeline, Deals: 3, Amount: 400, Weighted: 20}, {Week: week, Category: CategoryCommit, Deals: 1, Amount: 500, Weighted: 400}}
+		changes := compare(append(latest, snaps...))
+		if len(changes) != 2 || changes[0].Category != CategoryCommit || changes[0].WeightedDelta != 0 || changes[1].DealsDelta != 1 || changes[1].AmountDelta != 100 {
+			t.Fatalf("\t%s should compare the latest week with the one before : %+v", tests.Failed, changes)
+		}
+		t.Logf("\t%s should compare the latest week with the one before", tests.Success)
+	}
+}
@@ -45,6 +45,11 @@ type Authenticator struct {
 	GoogleClientSecret string
 	SlackSignature     string
 	SlackBotToken      string
+	// SlackClientID and SlackClientSecret install the slack app to the workspaces of the accounts.
+	SlackClientID     string
+	SlackClientSecret string
+	// APIKeyLookup resolves the API keys. API keys are rejected when it is not set.
+	APIKeyLookup APIKeyLookupFunc
 }
 
 // NewAuthenticator creates an *Authenticator for use. It will error if:
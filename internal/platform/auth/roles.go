@@ -32,10 +32,13 @@ const Key ctxKey = 1
 const SocketKey ctxKey = 100
 const RoleKey ctxKey = 200
 const ValidateMyItemKey ctxKey = 300
+const APIKeyKey ctxKey = 400
 
 // Claims represents the authorization claims transmitted via a JWT.
 type Claims struct {
 	Roles []string `json:"roles"`
+	// MFA is set when the member completed the two-factor for the sign in the token is issued for.
+	MFA bool `json:"mfa,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// APIKeyPrefix marks the bearer tokens which are the API keys of the account instead of a JWT.
+const APIKeyPrefix = "rly_"
+
+// Scope actions. The write action of a resource covers the read action as well.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeIngest = "ingest"
+	ScopeAny    = "*"
+)
+
+// APIKey is the identity resolved from an API key passed as the bearer token.
+type APIKey struct {
+	ID        string
+	AccountID string
+	Scopes    []string
+	Claims    Claims
+}
+
+// APIKeyLookupFunc resolves the API key passed as the bearer token. The authenticator
+// does not have access to the storage of the keys, so the caller wires it up.
+type APIKeyLookupFunc func(ctx context.Context, key string) (APIKey, error)
+
+// IsAPIKey returns true if the bearer token is an API key.
+func IsAPIKey(bearer string) bool {
+	return strings.HasPrefix(bearer, APIKeyPrefix)
+}
+
+// ValidScope checks the scope is of the form "resource:action" or "resource:action:qualifier".
+func ValidScope(scope string) bool {
+	parts := strings.Split(scope, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
+	}
+	for _, p := range parts {
+		if p == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// HasScope returns true if one of the scopes allows the action on the resource.
+// A scope without the qualifier allows every qualifier, for example "items:write" allows
+// writing the items of all the entities while "items:write:<entity_id>" allows only one.
+// The qualifier is always the entity id, the entity names are resolved to it when the key is created.
+func HasScope(scopes []string, resource, action, qualifier string) bool {
+	for _, s := range scopes {
+		parts := strings.Split(s, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			continue
+		}
+		if parts[0] != ScopeAny && parts[0] != resource {
+			continue
+		}
+		if !actionAllowed(parts[1], action) {
+			continue
+		}
+		if len(parts) == 3 && parts[2] != qualifier {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func actionAllowed(granted, action string) bool {
+	switch {
+	case granted == ScopeAny, granted == action:
+		return true
+	case granted == ScopeWrite && action == ScopeRead:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package auth_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestHasScope(t *testing.T) {
+	t.Log("Given the need to check the scopes of the api key")
+	{
+		cases := []struct {
+			scopes    []string
+			resource  string
+			action    string
+			qualifier string
+			want      bool
+		}{
+			{[]string{"items:read"}, "items", auth.ScopeRead, "e1", true},
+			{[]string{"items:read"}, "items", auth.ScopeWrite, "e1", false},
+			{[]string{"items:write"}, "items", auth.ScopeRead, "e1", true},
+			{[]string{"items:write:e1"}, "items", auth.ScopeWrite, "e1", true},
+			{[]string{"items:write:e1"}, "items", auth.ScopeWrite, "e2", false},
+			{[]string{"items:write:e1"}, "notes", auth.ScopeWrite, "e1", false},
+			{[]string{"events:ingest"}, "events", auth.ScopeIngest, "", true},
+			{[]string{"events:ingest"}, "events", auth.ScopeRead, "", false},
+			{[]string{"*:read"}, "flows", auth.ScopeRead, "", true},
+			{[]string{"*:*"}, "flows", auth.ScopeWrite, "", true},
+			{[]string{"items"}, "items", auth.ScopeRead, "", false},
+			{nil, "items", auth.ScopeRead, "", false},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the scopes %v are checked for %s:%s on %q", c.scopes, c.resource, c.action, c.qualifier)
+			{
+				if got := auth.HasScope(c.scopes, c.resource, c.action, c.qualifier); got != c.want {
+					t.Fatalf("\t%s should be allowed %v. got %v", tests.Failed, c.want, got)
+				}
+				t.Logf("\t%s should be allowed %v", tests.Success, c.want)
+			}
+		}
+	}
+}
@@ -110,6 +110,27 @@ func (client *Client) ReadPump(rp *redis.Pool, messageChan chan Message) {
 	}
 }
 
+// ListenPump keeps the connection of the client listening to the room alive. The messages from the
+// client are dropped as nothing is sent to the room by its listeners.
+func (client *Client) ListenPump() {
+	defer func() {
+		client.disconnect()
+	}()
+
+	client.conn.SetReadLimit(maxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(pongWait))
+	client.conn.SetPongHandler(func(string) error { client.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Println("***> unexpected/unhandled error occurred when closing the listen connection. error:", err)
+			}
+			break
+		}
+	}
+}
+
 func (client *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
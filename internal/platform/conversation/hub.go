@@ -74,3 +74,11 @@ func (hub *Hub) handleIncomingMessage(msg Message) {
 	}
 
 }
+
+func (hub *Hub) handleNotice(msg Message) {
+	if roomClients, ok := hub.Clients[msg.Room]; ok {
+		for client := range roomClients {
+			client.send <- msg.Notice
+		}
+	}
+}
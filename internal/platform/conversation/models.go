@@ -12,6 +12,7 @@ type Message struct {
 	Room     string                `json:"room"`
 	User     string                `json:"user"`
 	ClientID string                `json:"client_id"`
+	Notice   json.RawMessage       `json:"notice,omitempty"`
 }
 
 type ViewModelConversation struct {
@@ -2,6 +2,7 @@ package conversation
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/pkg/errors"
@@ -13,6 +14,7 @@ const (
 	SendMessageAction = "send-message"
 	UserJoinedAction  = "user-join"
 	UserLeftAction    = "user-left"
+	NoticeAction      = "notice"
 )
 
 type Publisher struct {
@@ -42,6 +44,25 @@ func (hub *Hub) publishReceivedMessage(message *Message, rp *redis.Pool) error {
 	return err
 }
 
+// NoticeRoom is the room of the user listening to the in-app notifications of the account.
+func NoticeRoom(accountID, userID string) string {
+	return fmt.Sprintf("%s#notifications#%s", accountID, userID)
+}
+
+// PublishNotice sends the notice to the clients in the room, on every instance of the hub.
+func PublishNotice(rp *redis.Pool, room string, notice interface{}) error {
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return errors.Wrap(err, "Error on marshal JSON notice")
+	}
+	message := &Message{Action: NoticeAction, Room: room, Notice: data}
+
+	conn := rp.Get()
+	defer conn.Close()
+	_, err = conn.Do("PUBLISH", PubSubGeneralChannel, message.encode())
+	return err
+}
+
 func (hub *Hub) listenPubSubChannel(rp *redis.Pool) error {
 	conn := rp.Get()
 	defer conn.Close()
@@ -63,6 +84,8 @@ func (hub *Hub) listenPubSubChannel(rp *redis.Pool) error {
 				hub.handleUserJoined(message)
 			case UserLeftAction:
 				hub.handleUserLeft(message)
+			case NoticeAction:
+				hub.handleNotice(message)
 			}
 		case redis.Subscription:
 			//DEBUGGING LOG fmt.Printf("redis.Subscription -----------------> %s: %s %d\n", v.Channel, v.Kind, v.Count)
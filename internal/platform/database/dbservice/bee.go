@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"regexp"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
@@ -17,6 +18,9 @@ const (
 	NoID = "00000000-0000-0000-0000-000000000000"
 )
 
+// sortKey guards the sort key interpolated in the query, the field keys are the UUIDs.
+var sortKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 type BeeService struct {
 	pdb *sqlx.DB
 	sdb *database.SecDB
@@ -28,7 +32,7 @@ func (bee BeeService) Result(ctx context.Context, accountID, entityID, sortby, d
 	if len(wh) > 0 {
 		where = fmt.Sprintf("AND %s", where)
 	}
-	items, err := item.Result(ctx, accountID, entityID, page, where, bee.pdb)
+	items, err := item.Result(ctx, accountID, entityID, page, where, OrderBuilder(sortby, direction), bee.pdb)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -148,7 +152,7 @@ func (bee BeeService) Search2(ctx context.Context, accountID, entityID string, c
 	if len(wh) > 0 {
 		where = fmt.Sprintf("AND %s", where)
 	}
-	items, err := item.Result(ctx, accountID, entityID, 0, where, bee.pdb)
+	items, err := item.Result(ctx, accountID, entityID, 0, where, "", bee.pdb)
 	if err != nil {
 		return nil, err
 	}
@@ -231,7 +235,7 @@ func WhBuilder(conditionFields []graphdb.Field) []string {
 			if f.IsDate { // the make condition field converts datetime to number for graph convinence
 				wh = append(wh, fmt.Sprintf("(fieldsb->>'%s')::timestamp %s '%v'", f.Key, f.Expression, util.ConvertMilliToTimeFromIntf(f.Value)))
 			} else {
-				wh = append(wh, fmt.Sprintf("(fieldsb->>'%s')::int %s %v", f.Key, f.Expression, f.Value))
+				wh = append(wh, fmt.Sprintf("(fieldsb->>'%s')::numeric %s %v", f.Key, f.Expression, f.Value)) // numeric, the computed values are decimals
 			}
 		case graphdb.TypeDateTime: //datetime in graph DB always expects a range
 			wh = append(wh, fmt.Sprintf("(fieldsb->>'%s')::timestamp %s '%v'", f.Key, f.Expression, util.ConvertMilliToTimeFromIntf(f.Value)))
@@ -246,6 +250,22 @@ func WhBuilder(conditionFields []graphdb.Field) []string {
 	return wh
 }
 
+// OrderBuilder returns the order clause sorting by the field key. The numbers, such as the computed values,
+// are sorted by the value and the rest by the text. Empty when the key is not given or not safe to use.
+func OrderBuilder(sortby, direction string) string {
+	if sortby == "" || !sortKey.MatchString(sortby) {
+		return ""
+	}
+	dir := "ASC"
+	if strings.ToUpper(direction) == "DESC" {
+		dir = "DESC"
+	}
+	if sortby == "created_at" || sortby == "updated_at" {
+		return fmt.Sprintf("%s %s", sortby, dir)
+	}
+	return fmt.Sprintf("CASE WHEN jsonb_typeof(fieldsb->'%s') = 'number' THEN (fieldsb->>'%s')::numeric END %s NULLS LAST, fieldsb->>'%s' %s NULLS LAST, created_at DESC", sortby, sortby, dir, sortby, dir)
+}
+
 func grpByKey(conditionFields []graphdb.Field) string {
 	var grpId string
 	for _, c := range conditionFields {
@@ -68,3 +68,23 @@ func TestWhString(t *testing.T) {
 
 	}
 }
+
+func TestOrderBuilder(t *testing.T) {
+	t.Log("Given the need to sort the results by the field")
+	{
+		cases := []struct {
+			sortby, direction, want string
+		}{
+			{"", "desc", ""},
+			{"created_at", "desc", "created_at DESC"},
+			{"amount-key", "", "CASE WHEN jsonb_typeof(fieldsb->'amount-key') = 'number' THEN (fieldsb->>'amount-key')::numeric END ASC NULLS LAST, fieldsb->>'amount-key' ASC NULLS LAST, created_at DESC"},
+			{"amount'; DROP TABLE items; --", "desc", ""},
+		}
+		for _, c := range cases {
+			if got := dbservice.OrderBuilder(c.sortby, c.direction); got != c.want {
+				t.Fatalf("\tShould order %q %q by %q. got %q", c.sortby, c.direction, c.want, got)
+			}
+			t.Logf("\tShould order %q %q by %q", c.sortby, c.direction, c.want)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"log"
 	"net/url"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // The database driver in use.
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 )
 
@@ -65,3 +68,31 @@ func StatusCheck(ctx context.Context, db *sqlx.DB) error {
 	var tmp bool
 	return db.QueryRowContext(ctx, q).Scan(&tmp)
 }
+
+// Conn is satisfied by both *sqlx.DB and *sqlx.Tx. The writes which must be able to join
+// a transaction take it in the place of *sqlx.DB.
+type Conn interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
+// WithTx runs fn inside a transaction. The transaction is committed when fn returns nil
+// and rolled back otherwise.
+func WithTx(ctx context.Context, db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	if err := fn(tx); err != nil {
+		if rerr := tx.Rollback(); rerr != nil {
+			log.Println("***> unexpected error occurred when rolling back the transaction. error: ", rerr)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "committing transaction")
+	}
+	return nil
+}
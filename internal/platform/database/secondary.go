@@ -11,6 +11,7 @@ const (
 	SocketNameSpace = "SocketAuth"
 	EntityNameSpace = "Entity"
 	ItemIDNameSpace = "ItemID"
+	AuthNameSpace   = "AuthChallenge"
 )
 
 type SecDB struct {
@@ -76,6 +77,30 @@ func (sdb *SecDB) GetUserToken(key string) (string, error) {
 
 }
 
+// SetAuthChallenge keeps the short lived state of the logins in progress (sso state, 2fa challenge)
+// which expires after the ttl seconds.
+func (sdb *SecDB) SetAuthChallenge(key string, value []byte, ttl int) error {
+	conn := sdb.redisCachePool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", fmt.Sprintf("%s:%s", AuthNameSpace, key), value, "EX", ttl)
+	return err
+}
+
+// PopAuthChallenge returns the state of the login and removes it so it can be used only once.
+func (sdb *SecDB) PopAuthChallenge(key string) (string, error) {
+	conn := sdb.redisCachePool.Get()
+	defer conn.Close()
+
+	nsKey := fmt.Sprintf("%s:%s", AuthNameSpace, key)
+	value, err := redis.String(conn.Do("GET", nsKey))
+	if err != nil {
+		return "", err
+	}
+	_, err = conn.Do("DEL", nsKey)
+	return value, err
+}
+
 func (sdb *SecDB) SetEntity(key string, encodedEntity []byte) error {
 	if sdb == nil {
 		return errors.New("SDB is null. USE primary DB")
@@ -320,6 +320,44 @@ func Delete(rPool *redis.Pool, graphName, label, itemID string) error {
 	return nil
 }
 
+// RemoveProperties drops the properties of the removed fields from all the nodes of the label.
+func RemoveProperties(rPool *redis.Pool, graphName, label string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	conn := rPool.Get()
+	defer conn.Close()
+	graph := graph(graphName, conn)
+
+	p := make([]string, 0, len(keys))
+	for _, k := range keys {
+		p = append(p, fmt.Sprintf("i.%s = NULL", quote(k)))
+	}
+	query := fmt.Sprintf(`MATCH (i:%s) SET %s`, quote(label), strings.Join(p, ", "))
+	_, err := graph.Query(query)
+	if err != nil {
+		return errors.Wrap(err, "removing properties")
+	}
+
+	return nil
+}
+
+// DeleteLabel deletes all the nodes of the label along with their edges. The list fields keep their
+// values as the nodes labeled with the field key, so this cleans up the removed list fields.
+func DeleteLabel(rPool *redis.Pool, graphName, label string) error {
+	conn := rPool.Get()
+	defer conn.Close()
+	graph := graph(graphName, conn)
+
+	query := fmt.Sprintf(`MATCH (i:%s) DETACH DELETE i`, quote(label))
+	_, err := graph.Query(query)
+	if err != nil {
+		return errors.Wrap(err, "deleting label nodes")
+	}
+
+	return nil
+}
+
 func makeQuery(rPool *redis.Pool, gn *GraphNode) string {
 	srcNode := gn.justNode()
 	gn.SourceNode = srcNode
@@ -0,0 +1,222 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CalDAV reads and writes the events of the CalDAV calendar collection, the calendar id being the URL of the
+// collection. The changes are read with the sync-collection report of RFC 6578.
+type CalDAV struct {
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+type multistatus struct {
+	Responses []davResponse `xml:"DAV: response"`
+	SyncToken string        `xml:"DAV: sync-token"`
+}
+
+type davResponse struct {
+	Href     string `xml:"DAV: href"`
+	Status   string `xml:"DAV: status"`
+	Propstat []struct {
+		ETag   string `xml:"DAV: prop>getetag"`
+		Status string `xml:"DAV: status"`
+	} `xml:"DAV: propstat"`
+}
+
+const syncCollection = `<?xml version="1.0" encoding="utf-8"?>
+<d:sync-collection xmlns:d="DAV:"><d:sync-token>%s</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`
+
+// Changes lists the events changed in the collection since the sync token. Without the token every event
+// of the collection is listed.
+func (c CalDAV) Changes(calendarID, syncToken string) (Changes, error) {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(syncToken))
+	resp, err := c.do("REPORT", calendarID, strings.NewReader(fmt.Sprintf(syncCollection, buf.String())), map[string]string{
+		"Depth":        "1",
+		"Content-Type": "application/xml; charset=utf-8",
+	})
+	if err != nil {
+		return Changes{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMultiStatus:
+	case http.StatusForbidden, http.StatusConflict:
+		if syncToken != "" {
+			return Changes{}, ErrSyncExpired
+		}
+		fallthrough
+	default:
+		return Changes{}, errors.Errorf("listing calendar changes: %s", resp.Status)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return Changes{}, errors.Wrap(err, "reading calendar changes")
+	}
+
+	changes := Changes{Events: make([]Event, 0, len(ms.Responses)), Token: ms.SyncToken, Full: syncToken == ""}
+	for _, r := range ms.Responses {
+		if path.Clean(r.Href) == path.Clean(collectionPath(calendarID)) {
+			continue
+		}
+		if strings.Contains(r.Status, " 404 ") {
+			changes.Events = append(changes.Events, Event{ID: r.Href, Cancelled: true})
+			continue
+		}
+		ev, err := c.Get(calendarID, r.Href)
+		if err == ErrEventNotFound {
+			changes.Events = append(changes.Events, Event{ID: r.Href, Cancelled: true})
+			continue
+		}
+		if err != nil {
+			return Changes{}, err
+		}
+		changes.Events = append(changes.Events, ev)
+	}
+	return changes, nil
+}
+
+// Get reads the event at its href.
+func (c CalDAV) Get(calendarID, eventID string) (Event, error) {
+	resp, err := c.do(http.MethodGet, resolve(calendarID, eventID), nil, nil)
+	if err != nil {
+		return Event{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return Event{}, ErrEventNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Event{}, errors.Errorf("reading calendar event: %s", resp.Status)
+	}
+	events, err := DecodeICS(resp.Body)
+	if err != nil {
+		return Event{}, err
+	}
+	if len(events) == 0 {
+		return Event{}, ErrEventNotFound
+	}
+
+	ev := events[0]
+	ev.ID = eventID
+	ev.ETag = resp.Header.Get("ETag")
+	return ev, nil
+}
+
+// Put creates the event in the collection, named by its UID, or replaces it when it has the href.
+func (c CalDAV) Put(calendarID string, ev Event) (Event, error) {
+	if ev.UID == "" {
+		return Event{}, errors.New("the calendar event has no UID")
+	}
+	headers := map[string]string{"Content-Type": "text/calendar; charset=utf-8"}
+	if ev.ID == "" {
+		ev.ID = strings.TrimSuffix(collectionPath(calendarID), "/") + "/" + url.PathEscape(ev.UID) + ".ics"
+		headers["If-None-Match"] = "*"
+	} else if ev.ETag != "" {
+		headers["If-Match"] = ev.ETag
+	}
+
+	var body bytes.Buffer
+	if err := EncodeICS(&body, []Event{ev}); err != nil {
+		return Event{}, err
+	}
+	resp, err := c.do(http.MethodPut, resolve(calendarID, ev.ID), &body, headers)
+	if err != nil {
+		return Event{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed:
+		return Event{}, ErrConflict
+	default:
+		return Event{}, errors.Errorf("saving calendar event: %s", resp.Status)
+	}
+
+	ev.ETag = resp.Header.Get("ETag")
+	if ev.ETag == "" { // the servers changing the event on the way in do not return the etag
+		return c.Get(calendarID, ev.ID)
+	}
+	return ev, nil
+}
+
+// Cancel removes the event from the collection.
+func (c CalDAV) Cancel(calendarID string, ev Event) error {
+	headers := map[string]string{}
+	if ev.ETag != "" {
+		headers["If-Match"] = ev.ETag
+	}
+	resp, err := c.do(http.MethodDelete, resolve(calendarID, ev.ID), nil, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound, http.StatusGone:
+		return nil
+	case http.StatusPreconditionFailed:
+		return ErrConflict
+	}
+	return errors.Errorf("cancelling calendar event: %s", resp.Status)
+}
+
+func (c CalDAV) do(method, target string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "calendar %s", method)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client(c.Client).Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "calendar %s", method)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, errors.New("calendar credentials rejected")
+	}
+	return resp, nil
+}
+
+// resolve returns the URL of the href in the collection.
+func resolve(collection, href string) string {
+	base, err := url.Parse(collection)
+	if err != nil {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func collectionPath(collection string) string {
+	if u, err := url.Parse(collection); err == nil {
+		return u.Path
+	}
+	return collection
+}
@@ -0,0 +1,169 @@
+// Package caldavtest runs the CalDAV calendar collection in memory for the tests of the calendar sync. It
+// serves GET, PUT and DELETE with the etag preconditions and the sync-collection report.
+package caldavtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Collection is the path of the calendar collection served.
+const Collection = "/calendars/test/"
+
+const tokenPrefix = "http://caldavtest/sync/"
+
+var syncTokenRE = regexp.MustCompile(`<[^>]*sync-token>([^<]*)<`)
+
+type resource struct {
+	body []byte
+	etag string
+}
+
+// Server is the CalDAV stand-in.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	seq       int
+	floor     int
+	resources map[string]resource
+	changed   map[string]int // the sequence each href last changed at
+}
+
+// NewServer starts the stand-in. Close it at the end of the test.
+func NewServer() *Server {
+	s := &Server{
+		resources: make(map[string]resource),
+		changed:   make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// CollectionURL is the URL of the calendar collection.
+func (s *Server) CollectionURL() string {
+	return s.URL + Collection
+}
+
+// ExpireTokens makes the sync tokens given so far invalid, as the servers do when they drop their history.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.floor = s.seq
+}
+
+// Len is the count of the events in the collection.
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.resources)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path, Collection) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		res, ok := s.resources[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("ETag", res.etag)
+		w.Write(res.body)
+	case http.MethodPut:
+		res, exists := s.resources[r.URL.Path]
+		if !s.preconditions(r, res, exists) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.seq++
+		res = resource{body: body, etag: fmt.Sprintf(`"%d"`, s.seq)}
+		s.resources[r.URL.Path] = res
+		s.changed[r.URL.Path] = s.seq
+		w.Header().Set("ETag", res.etag)
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodDelete:
+		res, exists := s.resources[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		if !s.preconditions(r, res, exists) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		s.seq++
+		delete(s.resources, r.URL.Path)
+		s.changed[r.URL.Path] = s.seq
+		w.WriteHeader(http.StatusNoContent)
+	case "REPORT":
+		s.report(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) preconditions(r *http.Request, res resource, exists bool) bool {
+	if m := r.Header.Get("If-Match"); m != "" && (!exists || m != res.etag) {
+		return false
+	}
+	if r.Header.Get("If-None-Match") == "*" && exists {
+		return false
+	}
+	return true
+}
+
+func (s *Server) report(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	since := 0
+	if m := syncTokenRE.FindSubmatch(body); m != nil && len(m[1]) > 0 {
+		n, err := strconv.Atoi(strings.TrimPrefix(string(m[1]), tokenPrefix))
+		if err != nil || !strings.HasPrefix(string(m[1]), tokenPrefix) || n < s.floor || n > s.seq {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><d:error xmlns:d="DAV:"><d:valid-sync-token/></d:error>`)
+			return
+		}
+		since = n
+	}
+
+	hrefs := make([]string, 0)
+	for href, seq := range s.changed {
+		_, exists := s.resources[href]
+		if seq > since && (exists || since > 0) {
+			hrefs = append(hrefs, href)
+		}
+	}
+	sort.Strings(hrefs)
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><d:multistatus xmlns:d="DAV:">`)
+	for _, href := range hrefs {
+		if res, ok := s.resources[href]; ok {
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:propstat><d:prop><d:getetag>%s</d:getetag></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, res.etag)
+		} else {
+			fmt.Fprintf(w, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, href)
+		}
+	}
+	fmt.Fprintf(w, `<d:sync-token>%s%d</d:sync-token></d:multistatus>`, tokenPrefix, s.seq)
+}
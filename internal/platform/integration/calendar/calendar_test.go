@@ -0,0 +1,145 @@
+package calendar_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/integration/calendar"
+	"gitlab.com/vjsideprojects/relay/internal/platform/integration/calendar/caldavtest"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestCalDAV(t *testing.T) {
+	srv := caldavtest.NewServer()
+	defer srv.Close()
+	c := calendar.CalDAV{}
+	cal := srv.CollectionURL()
+
+	t.Log("Given the need to sync the events with the CalDAV calendar")
+	{
+		start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		ev, err := c.Put(cal, calendar.Event{UID: "demo-1", Summary: "Demo, with Acme", Start: start, End: start.Add(time.Hour), Attendees: []string{"jo@acme.com"}})
+		if err != nil || ev.ETag == "" {
+			t.Fatalf("\t%s should create the event : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should create the event", tests.Success)
+
+		changes, err := c.Changes(cal, "")
+		if err != nil || !changes.Full || len(changes.Events) != 1 || changes.Events[0].Summary != "Demo, with Acme" {
+			t.Fatalf("\t%s should list the calendar in full : %v %+v", tests.Failed, err, changes)
+		}
+		if got := changes.Events[0]; !got.Start.Equal(start) || len(got.Attendees) != 1 || got.ID != ev.ID {
+			t.Fatalf("\t%s should read the event as it was written : %+v", tests.Failed, got)
+		}
+		t.Logf("\t%s should list the calendar in full", tests.Success)
+
+		moved := ev
+		moved.Start, moved.End = start.Add(time.Hour), start.Add(2*time.Hour)
+		moved, err = c.Put(cal, moved)
+		if err != nil {
+			t.Fatalf("\t%s should move the event : %v", tests.Failed, err)
+		}
+		if _, err := c.Put(cal, ev); err != calendar.ErrConflict {
+			t.Fatalf("\t%s should reject the write with the stale etag : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should reject the write with the stale etag", tests.Success)
+
+		next, err := c.Changes(cal, changes.Token)
+		if err != nil || next.Full || len(next.Events) != 1 || !next.Events[0].Start.Equal(moved.Start) {
+			t.Fatalf("\t%s should list the changes since the token : %v %+v", tests.Failed, err, next)
+		}
+		t.Logf("\t%s should list the changes since the token", tests.Success)
+
+		if err := c.Cancel(cal, moved); err != nil {
+			t.Fatalf("\t%s should cancel the event : %v", tests.Failed, err)
+		}
+		last, err := c.Changes(cal, next.Token)
+		if err != nil || len(last.Events) != 1 || !last.Events[0].Cancelled || last.Events[0].ID != ev.ID {
+			t.Fatalf("\t%s should list the cancelled event : %v %+v", tests.Failed, err, last)
+		}
+		t.Logf("\t%s should list the cancelled event", tests.Success)
+
+		srv.ExpireTokens()
+		if _, err := c.Changes(cal, next.Token); err != calendar.ErrSyncExpired {
+			t.Fatalf("\t%s should tell the sync token expired : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should tell the sync token expired", tests.Success)
+	}
+}
+
+func TestICS(t *testing.T) {
+	t.Log("Given the need to read and write the iCalendar feeds")
+	{
+		start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		events := []calendar.Event{{
+			UID:         "weekly-1",
+			Summary:     "Weekly review; pipeline, forecast",
+			Description: strings.Repeat("The long agenda of the review ", 5) + "\nwith the second line",
+			Start:       start,
+			End:         start.Add(30 * time.Minute),
+			Recurrence:  "FREQ=WEEKLY;BYDAY=MO",
+			Attendees:   []string{"jo@acme.com", "al@acme.com"},
+		}}
+
+		var buf bytes.Buffer
+		if err := calendar.EncodeICS(&buf, events); err != nil {
+			t.Fatalf("\t%s should write the feed : %v", tests.Failed, err)
+		}
+		for _, l := range strings.Split(buf.String(), "\r\n") {
+			if len(l) > 75 {
+				t.Fatalf("\t%s should fold the long lines : %q", tests.Failed, l)
+			}
+		}
+		got, err := calendar.DecodeICS(&buf)
+		if err != nil || len(got) != 1 {
+			t.Fatalf("\t%s should read the feed back : %v", tests.Failed, err)
+		}
+		ev := got[0]
+		if ev.Summary != events[0].Summary || ev.Description != events[0].Description || ev.Recurrence != events[0].Recurrence || !ev.End.Equal(events[0].End) || len(ev.Attendees) != 2 {
+			t.Fatalf("\t%s should read the feed back as it was written : %+v", tests.Failed, ev)
+		}
+		t.Logf("\t%s should read the feed back as it was written", tests.Success)
+
+		const feed = "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:local-1\r\nDTSTART;TZID=Asia/Kolkata:20260302T100000\r\nDTEND;TZID=Asia/Kolkata:20260302T110000\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nUID:local-1\r\nRECURRENCE-ID:20260309T100000\r\nDTSTART:20260309T050000Z\r\nSUMMARY:Moved standup\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nUID:offsite\r\nDTSTART;VALUE=DATE:20260310\r\nSUMMARY:Offsite\r\nSTATUS:CANCELLED\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+		got, err = calendar.DecodeICS(strings.NewReader(feed))
+		if err != nil || len(got) != 2 {
+			t.Fatalf("\t%s should read the feed of the other calendars : %v %+v", tests.Failed, err, got)
+		}
+		if !got[0].Start.Equal(time.Date(2026, 3, 2, 4, 30, 0, 0, time.UTC)) || got[0].TimeZone != "Asia/Kolkata" || !got[1].Cancelled {
+			t.Fatalf("\t%s should read the time zones and the cancellations : %+v", tests.Failed, got)
+		}
+		t.Logf("\t%s should read the feed of the other calendars", tests.Success)
+
+		hits := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Write([]byte(feed))
+		}))
+		defer ts.Close()
+
+		f := calendar.ICSFeed{}
+		changes, err := f.Changes(ts.URL, "")
+		if err != nil || !changes.Full || len(changes.Events) != 2 {
+			t.Fatalf("\t%s should import the feed : %v %+v", tests.Failed, err, changes)
+		}
+		unchanged, err := f.Changes(ts.URL, changes.Token)
+		if err != nil || len(unchanged.Events) != 0 || unchanged.Full || hits != 2 {
+			t.Fatalf("\t%s should skip the feed not changed : %v %+v", tests.Failed, err, unchanged)
+		}
+		if _, err := f.Put(ts.URL, got[0]); err != calendar.ErrReadOnly {
+			t.Fatalf("\t%s should not write to the feed : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should import the feed and skip it when not changed", tests.Success)
+	}
+}
@@ -0,0 +1,55 @@
+package calendar
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrConflict is used when the event changed in the calendar since the etag was read.
+	ErrConflict = errors.New("Calendar event changed since it was read")
+
+	// ErrSyncExpired is used when the calendar no longer accepts the sync token. The calendar should be
+	// read in full again.
+	ErrSyncExpired = errors.New("Calendar sync token expired")
+
+	// ErrReadOnly is used when the events are written to the calendar which can only be read.
+	ErrReadOnly = errors.New("Calendar is read-only")
+
+	// ErrEventNotFound is used when the event does not exist in the calendar.
+	ErrEventNotFound = errors.New("Calendar event not found")
+)
+
+// Event is the meeting as the calendars see it.
+type Event struct {
+	ID          string // the id of the event in the calendar, the href for CalDAV
+	UID         string // the iCalendar UID, shared by the copies of the event in all the calendars
+	ETag        string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	TimeZone    string
+	Recurrence  string // the RRULE without its name
+	Attendees   []string
+	Cancelled   bool
+	Updated     time.Time
+}
+
+// Changes are the events changed in the calendar since the sync token. When full, the events are all the
+// events of the calendar and the ones missing are gone.
+type Changes struct {
+	Events []Event
+	Token  string
+	Full   bool
+}
+
+// Provider reads and writes the events of the calendar. The writes carry the etag of the event last read,
+// the provider returns ErrConflict when the event changed since.
+type Provider interface {
+	Changes(calendarID, syncToken string) (Changes, error)
+	Get(calendarID, eventID string) (Event, error)
+	Put(calendarID string, ev Event) (Event, error)
+	Cancel(calendarID string, ev Event) error
+}
@@ -1,14 +1,15 @@
 package calendar
 
 import (
-	"fmt"
-	"log"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"gitlab.com/vjsideprojects/relay/internal/platform/integration"
 	"golang.org/x/oauth2"
 	"google.golang.org/api/calendar/v3"
+	"google.golang.org/api/googleapi"
 )
 
 type Gcalendar struct {
@@ -33,42 +34,6 @@ func getCalendarService(oAuthFile, tokenJson string) (*calendar.Service, error)
 	return calendar.New(client)
 }
 
-func (g *Gcalendar) EventCreate(calendarID string, meeting *integration.Meeting) error {
-	srv, err := getCalendarService(g.OAuthFile, g.TokenJson)
-	if err != nil {
-		return err
-	}
-
-	eventAttendees := make([]*calendar.EventAttendee, len(meeting.Attendees))
-	for i, attendeeMail := range meeting.Attendees {
-		eventAttendees[i] = &calendar.EventAttendee{Email: attendeeMail}
-	}
-
-	event := &calendar.Event{
-		Summary:     meeting.Summary,
-		Description: meeting.Description,
-		Start: &calendar.EventDateTime{
-			DateTime: meeting.StartTime,
-			TimeZone: meeting.TimeZone,
-		},
-		End: &calendar.EventDateTime{
-			DateTime: meeting.EndTime,
-			TimeZone: meeting.TimeZone,
-		},
-		Attendees: eventAttendees,
-	}
-
-	event, err = srv.Events.Insert(calendarID, event).Do()
-	if err != nil {
-		return errors.Wrapf(err, "Unable to create event")
-	}
-	meeting.ID = event.Id
-	meeting.CalID = event.ICalUID
-	meeting.Created = event.Created
-	meeting.Updated = event.Updated
-	return nil
-}
-
 func (g *Gcalendar) Watch(calendarID, channelID string) error {
 	srv, err := getCalendarService(g.OAuthFile, g.TokenJson)
 	if err != nil {
@@ -89,47 +54,237 @@ func (g *Gcalendar) Watch(calendarID, channelID string) error {
 	return nil
 }
 
-func (g *Gcalendar) Sync(calendarID string, syncToken string) (string, error) {
+// Changes lists the events changed since the sync token. Without the token the upcoming events are listed,
+// the past ones being left out it is never full. The expired token is reported with ErrSyncExpired.
+func (g *Gcalendar) Changes(calendarID, syncToken string) (Changes, error) {
 	srv, err := getCalendarService(g.OAuthFile, g.TokenJson)
 	if err != nil {
-		return syncToken, err
+		return Changes{}, err
 	}
 
-	t := time.Now().Format(time.RFC3339)
-
+	changes := Changes{Events: make([]Event, 0)}
 	pageToken := ""
 	for {
 		evl := srv.Events.List(calendarID)
 		if syncToken != "" {
 			evl.SyncToken(syncToken)
 		} else {
-			evl.TimeMin(t)
+			evl.TimeMin(time.Now().Format(time.RFC3339))
 		}
 		evl.PageToken(pageToken)
-		evl.ShowDeleted(true).SingleEvents(true).MaxResults(10)
+		evl.ShowDeleted(syncToken != "").MaxResults(250)
 		events, err := evl.Do()
 		if err != nil {
-			log.Fatalf("Unable to retrieve next ten of the user's events: %v", err)
-			//delete token and retry
+			if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusGone {
+				return Changes{}, ErrSyncExpired
+			}
+			return Changes{}, errors.Wrap(err, "listing calendar events")
 		}
 
-		if len(events.Items) == 0 {
-			fmt.Println("No upcoming events found.")
-		} else {
-			for _, item := range events.Items {
-				date := item.Start.DateTime
-				if date == "" {
-					date = item.Start.Date
-				}
-				fmt.Printf("%v (%v)\n", item.Summary, date)
-			}
+		for _, item := range events.Items {
+			changes.Events = append(changes.Events, fromGoogle(item))
 		}
 		if events.NextPageToken == "" {
-			syncToken = events.NextSyncToken
+			changes.Token = events.NextSyncToken
 			break
 		}
 		pageToken = events.NextPageToken
 	}
 
-	return syncToken, nil
+	return changes, nil
+}
+
+// Get reads the event.
+func (g *Gcalendar) Get(calendarID, eventID string) (Event, error) {
+	srv, err := getCalendarService(g.OAuthFile, g.TokenJson)
+	if err != nil {
+		return Event{}, err
+	}
+
+	item, err := srv.Events.Get(calendarID, eventID).Do()
+	if err != nil {
+		return Event{}, googleError(err, "reading calendar event")
+	}
+	return fromGoogle(item), nil
+}
+
+// Put creates the event, or updates it when it has the id.
+func (g *Gcalendar) Put(calendarID string, ev Event) (Event, error) {
+	srv, err := getCalendarService(g.OAuthFile, g.TokenJson)
+	if err != nil {
+		return Event{}, err
+	}
+
+	var item *calendar.Event
+	if ev.ID == "" {
+		item, err = srv.Events.Insert(calendarID, toGoogle(ev)).Do()
+	} else {
+		// the meeting has only some of the fields of the event, the rest are kept as they are in the calendar.
+		remote, gerr := srv.Events.Get(calendarID, ev.ID).Do()
+		if gerr != nil {
+			return Event{}, googleError(gerr, "reading calendar event")
+		}
+		call := srv.Events.Patch(calendarID, ev.ID, patchGoogle(remote, ev))
+		if ev.ETag != "" {
+			call.Header().Set("If-Match", ev.ETag)
+		}
+		item, err = call.Do()
+	}
+	if err != nil {
+		return Event{}, googleError(err, "saving calendar event")
+	}
+	return fromGoogle(item), nil
+}
+
+// Cancel removes the event from the calendar.
+func (g *Gcalendar) Cancel(calendarID string, ev Event) error {
+	srv, err := getCalendarService(g.OAuthFile, g.TokenJson)
+	if err != nil {
+		return err
+	}
+
+	call := srv.Events.Delete(calendarID, ev.ID)
+	if ev.ETag != "" {
+		call.Header().Set("If-Match", ev.ETag)
+	}
+	if err := call.Do(); err != nil {
+		if err := googleError(err, "cancelling calendar event"); err != ErrEventNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+func googleError(err error, msg string) error {
+	if gerr, ok := err.(*googleapi.Error); ok {
+		switch gerr.Code {
+		case http.StatusPreconditionFailed:
+			return ErrConflict
+		case http.StatusNotFound, http.StatusGone:
+			return ErrEventNotFound
+		}
+	}
+	return errors.Wrap(err, msg)
+}
+
+func fromGoogle(item *calendar.Event) Event {
+	ev := Event{
+		ID:          item.Id,
+		UID:         item.ICalUID,
+		ETag:        item.Etag,
+		Summary:     item.Summary,
+		Description: item.Description,
+		Cancelled:   item.Status == "cancelled",
+	}
+	if item.Start != nil {
+		ev.Start, ev.TimeZone = googleTime(item.Start)
+	}
+	if item.End != nil {
+		ev.End, _ = googleTime(item.End)
+	}
+	for _, r := range item.Recurrence {
+		if strings.HasPrefix(r, "RRULE:") {
+			ev.Recurrence = strings.TrimPrefix(r, "RRULE:")
+		}
+	}
+	for _, a := range item.Attendees {
+		if a.Email != "" {
+			ev.Attendees = append(ev.Attendees, a.Email)
+		}
+	}
+	ev.Updated, _ = time.Parse(time.RFC3339, item.Updated)
+	return ev
+}
+
+func toGoogle(ev Event) *calendar.Event {
+	item := &calendar.Event{
+		Id:          ev.ID,
+		Summary:     ev.Summary,
+		Description: ev.Description,
+		Start:       &calendar.EventDateTime{DateTime: ev.Start.Format(time.RFC3339), TimeZone: ev.TimeZone},
+		End:         &calendar.EventDateTime{DateTime: ev.End.Format(time.RFC3339), TimeZone: ev.TimeZone},
+	}
+	if ev.UID != "" && ev.ID == "" {
+		item.ICalUID = ev.UID
+	}
+	if ev.Recurrence != "" {
+		item.Recurrence = []string{"RRULE:" + ev.Recurrence}
+	}
+	for _, email := range ev.Attendees {
+		item.Attendees = append(item.Attendees, &calendar.EventAttendee{Email: email})
+	}
+	return item
+}
+
+// patchGoogle returns the patch of the event read from the calendar with the fields of the meeting. The
+// reminders, the conferencing and the responses of the attendees are not in the patch and stay, and so do
+// the exceptions of the recurrence and the all-day dates not moved.
+func patchGoogle(remote *calendar.Event, ev Event) *calendar.Event {
+	patch := &calendar.Event{
+		Summary:         ev.Summary,
+		Description:     ev.Description,
+		ForceSendFields: []string{"Summary", "Description"},
+	}
+
+	moved := true
+	if remote.Start != nil && remote.Start.Date != "" {
+		remoteStart, _ := googleTime(remote.Start)
+		moved = !remoteStart.Equal(ev.Start)
+	}
+	if moved {
+		patch.Start = &calendar.EventDateTime{DateTime: ev.Start.Format(time.RFC3339), TimeZone: ev.TimeZone, NullFields: []string{"Date"}}
+		patch.End = &calendar.EventDateTime{DateTime: ev.End.Format(time.RFC3339), TimeZone: ev.TimeZone, NullFields: []string{"Date"}}
+	}
+
+	recurrence := make([]string, 0, len(remote.Recurrence)+1)
+	for _, r := range remote.Recurrence {
+		if !strings.HasPrefix(r, "RRULE:") {
+			recurrence = append(recurrence, r)
+		}
+	}
+	if ev.Recurrence != "" {
+		recurrence = append(recurrence, "RRULE:"+ev.Recurrence)
+	}
+	patch.Recurrence = recurrence
+	if len(recurrence) == 0 {
+		patch.NullFields = append(patch.NullFields, "Recurrence")
+	}
+
+	// the attendees of the meeting are the contacts, the organizer and the rooms are kept as they are.
+	known := make(map[string]*calendar.EventAttendee, len(remote.Attendees))
+	for _, a := range remote.Attendees {
+		known[strings.ToLower(a.Email)] = a
+		if a.Organizer || a.Self || a.Resource {
+			patch.Attendees = append(patch.Attendees, a)
+		}
+	}
+	for _, email := range ev.Attendees {
+		a, ok := known[strings.ToLower(email)]
+		if !ok {
+			a = &calendar.EventAttendee{Email: email}
+		} else if a.Organizer || a.Self || a.Resource {
+			continue
+		}
+		patch.Attendees = append(patch.Attendees, a)
+	}
+	if len(patch.Attendees) == 0 {
+		patch.NullFields = append(patch.NullFields, "Attendees")
+	}
+	return patch
+}
+
+// googleTime reads the time of the event. The all-day events start at the midnight of their time zone.
+func googleTime(edt *calendar.EventDateTime) (time.Time, string) {
+	loc := time.UTC
+	if edt.TimeZone != "" {
+		if l, err := time.LoadLocation(edt.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	if edt.DateTime != "" {
+		t, _ := time.Parse(time.RFC3339, edt.DateTime)
+		return t, edt.TimeZone
+	}
+	t, _ := time.ParseInLocation("2006-01-02", edt.Date, loc)
+	return t, edt.TimeZone
 }
@@ -0,0 +1,317 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	icsUTC      = "20060102T150405Z"
+	icsLocal    = "20060102T150405"
+	icsDate     = "20060102"
+	icsFoldSize = 75
+)
+
+// EncodeICS writes the events as the iCalendar document.
+func EncodeICS(w io.Writer, events []Event) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeFolded(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Relay//Meetings//EN")
+	for _, ev := range events {
+		stamp := ev.Updated
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+		line("BEGIN", "VEVENT")
+		line("UID", escapeText(ev.UID))
+		line("DTSTAMP", stamp.UTC().Format(icsUTC))
+		line("LAST-MODIFIED", stamp.UTC().Format(icsUTC))
+		line("DTSTART", ev.Start.UTC().Format(icsUTC))
+		if !ev.End.IsZero() {
+			line("DTEND", ev.End.UTC().Format(icsUTC))
+		}
+		line("SUMMARY", escapeText(ev.Summary))
+		if ev.Description != "" {
+			line("DESCRIPTION", escapeText(ev.Description))
+		}
+		if ev.Recurrence != "" {
+			line("RRULE", ev.Recurrence)
+		}
+		for _, email := range ev.Attendees {
+			line("ATTENDEE", "mailto:"+email)
+		}
+		if ev.Cancelled {
+			line("STATUS", "CANCELLED")
+		}
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// DecodeICS reads the events of the iCalendar document. The overrides of the single occurrences of the
+// recurring events are skipped, the series stands for them.
+func DecodeICS(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, 0)
+	var (
+		ev       *Event
+		override bool
+	)
+	for _, l := range lines {
+		name, params, value, ok := splitLine(l)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			ev, override = &Event{}, false
+		case name == "END" && value == "VEVENT":
+			if ev != nil && !override && ev.UID != "" {
+				ev.ID = ev.UID
+				events = append(events, *ev)
+			}
+			ev = nil
+		case ev == nil:
+			continue
+		case name == "UID":
+			ev.UID = unescapeText(value)
+		case name == "SUMMARY":
+			ev.Summary = unescapeText(value)
+		case name == "DESCRIPTION":
+			ev.Description = unescapeText(value)
+		case name == "DTSTART":
+			ev.Start, ev.TimeZone, err = icsTime(value, params)
+		case name == "DTEND":
+			ev.End, _, err = icsTime(value, params)
+		case name == "RRULE":
+			ev.Recurrence = value
+		case name == "ATTENDEE":
+			if strings.HasPrefix(strings.ToLower(value), "mailto:") {
+				ev.Attendees = append(ev.Attendees, value[len("mailto:"):])
+			}
+		case name == "STATUS":
+			ev.Cancelled = strings.EqualFold(value, "CANCELLED")
+		case name == "LAST-MODIFIED":
+			ev.Updated, _, err = icsTime(value, params)
+		case name == "DTSTAMP":
+			if ev.Updated.IsZero() {
+				ev.Updated, _, err = icsTime(value, params)
+			}
+		case name == "RECURRENCE-ID":
+			override = true
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s of the event", name)
+		}
+	}
+	return events, nil
+}
+
+// ICSFeed reads the events of the calendar published as the iCalendar feed, the calendar id being the URL of
+// the feed. The feed is read in full on every change, the ETag of the feed is its sync token.
+type ICSFeed struct {
+	Client *http.Client
+}
+
+// Changes reads the feed when it changed since the sync token.
+func (f ICSFeed) Changes(calendarID, syncToken string) (Changes, error) {
+	events, token, err := f.read(calendarID, syncToken)
+	if err != nil {
+		return Changes{}, err
+	}
+	if events == nil {
+		return Changes{Events: []Event{}, Token: syncToken}, nil
+	}
+	return Changes{Events: events, Token: token, Full: true}, nil
+}
+
+// Get reads the event from the feed.
+func (f ICSFeed) Get(calendarID, eventID string) (Event, error) {
+	events, _, err := f.read(calendarID, "")
+	if err != nil {
+		return Event{}, err
+	}
+	for _, ev := range events {
+		if ev.ID == eventID {
+			return ev, nil
+		}
+	}
+	return Event{}, ErrEventNotFound
+}
+
+// Put cannot write to the feed.
+func (f ICSFeed) Put(calendarID string, ev Event) (Event, error) {
+	return Event{}, ErrReadOnly
+}
+
+// Cancel cannot write to the feed.
+func (f ICSFeed) Cancel(calendarID string, ev Event) error {
+	return ErrReadOnly
+}
+
+// read returns the events of the feed with its token, or no events when the feed is the same as the token.
+func (f ICSFeed) read(url, token string) ([]Event, string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading calendar feed")
+	}
+	if token != "" && !strings.HasPrefix(token, "sha1:") {
+		req.Header.Set("If-None-Match", token)
+	}
+	resp, err := client(f.Client).Do(req)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading calendar feed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, token, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.Errorf("reading calendar feed: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "reading calendar feed")
+	}
+
+	next := resp.Header.Get("ETag")
+	if next == "" {
+		sum := sha1.Sum(body)
+		next = "sha1:" + hex.EncodeToString(sum[:])
+	}
+	if next == token {
+		return nil, token, nil
+	}
+
+	events, err := DecodeICS(bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	return events, next, nil
+}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return &http.Client{Timeout: 30 * time.Second}
+	}
+	return c
+}
+
+// writeFolded writes the content line folded at 75 octets, without splitting the UTF-8 characters.
+func writeFolded(w *bufio.Writer, l string) {
+	size := icsFoldSize
+	for len(l) > size {
+		cut := size
+		for cut > 0 && l[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(l[:cut])
+		w.WriteString("\r\n ")
+		l = l[cut:]
+		size = icsFoldSize - 1 // the folded lines start with the space
+	}
+	w.WriteString(l)
+	w.WriteString("\r\n")
+}
+
+func unfold(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading calendar document")
+	}
+	return lines, nil
+}
+
+// splitLine splits the content line into its name, its parameters and its value.
+func splitLine(l string) (string, map[string]string, string, bool) {
+	colon, quoted := -1, false
+	for i, c := range l {
+		if c == '"' {
+			quoted = !quoted
+		}
+		if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return "", nil, "", false
+	}
+
+	parts := strings.Split(l[:colon], ";")
+	params := make(map[string]string, len(parts)-1)
+	for _, p := range parts[1:] {
+		if kv := strings.SplitN(p, "=", 2); len(kv) == 2 {
+			params[strings.ToUpper(kv[0])] = strings.Trim(kv[1], `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), params, l[colon+1:], true
+}
+
+// icsTime reads the time in UTC, in the time zone of its TZID or the date of the all-day event.
+func icsTime(value string, params map[string]string) (time.Time, string, error) {
+	tzid := params["TZID"]
+	loc := time.UTC
+	if tzid != "" {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("unknown time zone %q", tzid)
+		}
+		loc = l
+	}
+
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == len(icsDate):
+		t, err := time.ParseInLocation(icsDate, value, loc)
+		return t, tzid, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := time.Parse(icsUTC, value)
+		return t, tzid, err
+	}
+	t, err := time.ParseInLocation(icsLocal, value, loc)
+	return t, tzid, err
+}
+
+var (
+	textEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+	textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+func escapeText(s string) string {
+	return textEscaper.Replace(strings.Replace(s, "\r\n", "\n", -1))
+}
+
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
@@ -19,6 +19,9 @@ const (
 	TypeGmail          = "gmail"
 	TypeMailGun        = "mailgun"
 	TypeGoogleCalendar = "google_calendar"
+	TypeCalDAV         = "caldav"
+	TypeICSFeed        = "ics_feed"
+	TypeSlack          = "slack"
 	//though the type is not integration. It is the by-product of integration
 	TypeMails  = "mails"
 	TypeOwners = "owners"
@@ -42,12 +45,6 @@ type DoMail interface {
 	Watch(topic string) (string, error)
 }
 
-type DoCalendar interface {
-	EventCreate(calendarID, meeting Meeting) error
-	Sync(calendarID string, syncToken string) (string, error)
-	Watch(calendarID, channelID string) error
-}
-
 // GetGoogleAccessURL gets the access-url for the scopes mentioned. This url should be loaded in the UI
 func GetGoogleAccessURL(ctx context.Context, oAuthFile string, integId string, scope ...string) (string, error) {
 	config, err := GetConfig(oAuthFile, scope...)
@@ -0,0 +1,227 @@
+// Package openapi builds the OpenAPI 3.1 document of the api from the routes registered on the app
+// and the operations annotating them with the request and the response types.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/web"
+)
+
+// Version is the version of the OpenAPI specification the document follows.
+const Version = "3.1.0"
+
+// securityScheme is the name of the bearer scheme, used for both the JWT and the API keys.
+const securityScheme = "bearer"
+
+// Operation annotates the route with the parts not known from the route itself.
+type Operation struct {
+	Summary  string
+	Request  interface{} // the value decoded from the body, nil when the route has no body
+	Response interface{} // the value responded, nil when the response has no documented schema
+	Status   int         // the status of the success response, 200 when not set
+}
+
+// Key returns the key of the operation of the route.
+func Key(verb, path string) string {
+	return verb + " " + path
+}
+
+// Document is the OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the api.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem holds the operations of the path by the lower case method.
+type PathItem map[string]OperationObject
+
+// OperationObject is the documented operation.
+type OperationObject struct {
+	OperationID   string                `json:"operationId"`
+	Summary       string                `json:"summary,omitempty"`
+	Tags          []string              `json:"tags,omitempty"`
+	Parameters    []Parameter           `json:"parameters,omitempty"`
+	RequestBody   *RequestBody          `json:"requestBody,omitempty"`
+	Responses     map[string]Response   `json:"responses"`
+	Security      []map[string][]string `json:"security,omitempty"`
+	Roles         []string              `json:"x-roles,omitempty"`
+	AccountAccess bool                  `json:"x-account-access,omitempty"`
+}
+
+// Parameter is the path parameter of the operation.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is the body of the operation.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is the response of the operation.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of the content.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referred by the operations and the security schemes.
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how the caller authenticates.
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+// Build builds the document of the routes. The routes without the operation are left out of the document
+// and returned, so the caller can insist every route is documented.
+func Build(info Info, routes []web.Route, ops map[string]Operation) (Document, []string) {
+	g := newGenerator()
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem, 0),
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				securityScheme: {Type: "http", Scheme: "bearer", Description: "The JWT of the user or the API key of the account."},
+			},
+		},
+	}
+
+	missing := make([]string, 0)
+	for _, rt := range routes {
+		op, ok := ops[Key(rt.Verb, rt.Path)]
+		if !ok {
+			missing = append(missing, Key(rt.Verb, rt.Path))
+			continue
+		}
+
+		path, params := pathTemplate(rt.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem, 0)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.Verb)] = g.operation(rt, op, params)
+	}
+	sort.Strings(missing)
+
+	return doc, missing
+}
+
+func (g *generator) operation(rt web.Route, op Operation, params []string) OperationObject {
+	oo := OperationObject{
+		OperationID:   operationID(rt.Verb, rt.Path),
+		Summary:       op.Summary,
+		Tags:          []string{tag(rt.Path)},
+		Responses:     make(map[string]Response, 0),
+		Roles:         rt.Roles,
+		AccountAccess: rt.AccountAccess,
+	}
+	for _, p := range params {
+		oo.Parameters = append(oo.Parameters, Parameter{Name: p, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+
+	if op.Request != nil {
+		oo.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schema(op.Request)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res := Response{Description: http.StatusText(status)}
+	if op.Response != nil && status != http.StatusNoContent {
+		res.Content = map[string]MediaType{"application/json": {Schema: g.schema(op.Response)}}
+	}
+	oo.Responses[fmt.Sprint(status)] = res
+
+	if rt.Auth {
+		oo.Security = []map[string][]string{{securityScheme: {}}}
+		oo.Responses["401"] = Response{Description: "The token is missing or invalid."}
+	}
+	if len(rt.Roles) > 0 || rt.AccountAccess {
+		oo.Responses["403"] = Response{Description: "The caller does not have the role or the access to the account."}
+	}
+	return oo
+}
+
+// pathTemplate converts the route path to the OpenAPI template, e.g. "/v1/accounts/:account_id" to
+// "/v1/accounts/{account_id}", and returns the names of the parameters.
+func pathTemplate(path string) (string, []string) {
+	segs := strings.Split(path, "/")
+	params := make([]string, 0)
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// operationID derives the unique ID from the method and the static segments of the path, e.g.
+// "GET /v1/accounts/:account_id/teams" is "get_accounts_teams_by_account_id".
+func operationID(verb, path string) string {
+	statics := make([]string, 0)
+	params := make([]string, 0)
+	for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+		switch {
+		case seg == "" || seg == "v1":
+		case strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*"):
+			params = append(params, seg[1:])
+		default:
+			statics = append(statics, strings.ToLower(seg))
+		}
+	}
+	id := strings.ToLower(verb) + "_" + strings.Join(statics, "_")
+	if len(params) > 0 {
+		id += "_by_" + strings.Join(params, "_")
+	}
+	return id
+}
+
+// tag groups the operation by the last collection of the path.
+func tag(path string) string {
+	t := "api"
+	for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+		if seg == "" || seg == "v1" || strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			continue
+		}
+		t = strings.ToLower(seg)
+		if seg == "accounts" || seg == "teams" || seg == "entities" || seg == "items" {
+			continue
+		}
+		break
+	}
+	return t
+}
@@ -0,0 +1,136 @@
+// Package ratelimit throttles the requests with the token buckets kept in redis, so all the
+// instances of the api share the same buckets.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limit allows the requests at the rate of Requests per Per. The bucket holds at most Burst
+// tokens so the client can send the burst after being idle.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// Result is the state of the bucket after taking the token for the request.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the next request is allowed, set only when not allowed
+}
+
+// Store keeps the buckets.
+type Store interface {
+	Take(key string, l Limit, now time.Time) (Result, error)
+}
+
+// ParseLimit parses the limit written as "<requests>/<period>", e.g. "600/1m".
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("limit %q should be <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("limit %q should have the positive number of requests", s)
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("limit %q should have the positive period", s)
+	}
+	return Limit{Requests: requests, Per: per, Burst: requests}, nil
+}
+
+// String returns the limit in the format read by ParseLimit.
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// rate is the number of tokens added per millisecond.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / float64(l.Per/time.Millisecond)
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// refill adds the tokens earned since the last request and takes one for this request.
+// The redis store runs the same logic in the lua script.
+func refill(l Limit, tokens float64, lastMS, nowMS int64) (float64, bool) {
+	if nowMS > lastMS {
+		tokens = math.Min(l.capacity(), tokens+float64(nowMS-lastMS)*l.rate())
+	}
+	if tokens >= 1 {
+		return tokens - 1, true
+	}
+	return tokens, false
+}
+
+// result builds the result from the tokens left in the bucket.
+func result(l Limit, tokens float64, allowed bool) Result {
+	r := Result{
+		Allowed:   allowed,
+		Limit:     int(l.capacity()),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((l.capacity()-tokens)/l.rate())) * time.Millisecond,
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration(math.Ceil((1-tokens)/l.rate())) * time.Millisecond
+	}
+	return r
+}
+
+type bucket struct {
+	tokens float64
+	lastMS int64
+}
+
+// MemoryStore keeps the buckets in the process. It is used when the api runs without redis.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+}
+
+// NewMemoryStore creates the store keeping the buckets in the process.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]bucket, 0)}
+}
+
+// Take takes the token for the request from the bucket of the key.
+func (m *MemoryStore) Take(key string, l Limit, now time.Time) (Result, error) {
+	if l.Requests <= 0 || l.Per <= 0 {
+		return Result{}, errors.Errorf("invalid limit %s", l)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nowMS := now.UnixNano() / int64(time.Millisecond)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = bucket{tokens: l.capacity(), lastMS: nowMS}
+	}
+	tokens, allowed := refill(l, b.tokens, b.lastMS, nowMS)
+	if nowMS > b.lastMS {
+		b.lastMS = nowMS
+	}
+	b.tokens = tokens
+	m.buckets[key] = b
+
+	return result(l, tokens, allowed), nil
+}
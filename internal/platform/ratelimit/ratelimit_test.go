@@ -0,0 +1,107 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/ratelimit"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestParseLimit(t *testing.T) {
+	t.Log("Given the need to read the limits from the config")
+	{
+		cases := []struct {
+			limit string
+			want  ratelimit.Limit
+			valid bool
+		}{
+			{"600/1m", ratelimit.Limit{Requests: 600, Per: time.Minute, Burst: 600}, true},
+			{" 5/10s ", ratelimit.Limit{Requests: 5, Per: 10 * time.Second, Burst: 5}, true},
+			{"600", ratelimit.Limit{}, false},
+			{"0/1m", ratelimit.Limit{}, false},
+			{"10/soon", ratelimit.Limit{}, false},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the limit is %q", c.limit)
+			{
+				got, err := ratelimit.ParseLimit(c.limit)
+				if (err == nil) != c.valid || got != c.want {
+					t.Fatalf("\t%s should parse to %+v (valid %v). got %+v, %v", tests.Failed, c.want, c.valid, got, err)
+				}
+				t.Logf("\t%s should parse to %+v", tests.Success, c.want)
+			}
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	t.Log("Given the need to throttle the requests with the token bucket")
+	{
+		store := ratelimit.NewMemoryStore()
+		l := ratelimit.Limit{Requests: 3, Per: 3 * time.Second, Burst: 3}
+		now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		t.Log("\twhen the burst is used up")
+		{
+			for i := 2; i >= 0; i-- {
+				res, err := store.Take("ip:1.1.1.1", l, now)
+				if err != nil || !res.Allowed || res.Remaining != i {
+					t.Fatalf("\t%s should allow the request with %d remaining. got %+v, %v", tests.Failed, i, res, err)
+				}
+			}
+			res, _ := store.Take("ip:1.1.1.1", l, now)
+			if res.Allowed || res.Remaining != 0 || res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+				t.Fatalf("\t%s should reject the request and retry after a second. got %+v", tests.Failed, res)
+			}
+			t.Logf("\t%s should reject the request and retry after a second", tests.Success)
+		}
+
+		t.Log("\twhen the other key sends the request")
+		{
+			res, _ := store.Take("ip:2.2.2.2", l, now)
+			if !res.Allowed || res.Remaining != 2 {
+				t.Fatalf("\t%s should use its own bucket. got %+v", tests.Failed, res)
+			}
+			t.Logf("\t%s should use its own bucket", tests.Success)
+		}
+
+		t.Log("\twhen the bucket is refilled")
+		{
+			res, _ := store.Take("ip:1.1.1.1", l, now.Add(1500*time.Millisecond))
+			if !res.Allowed || res.Remaining != 0 {
+				t.Fatalf("\t%s should allow one request after a second. got %+v", tests.Failed, res)
+			}
+			res, _ = store.Take("ip:1.1.1.1", l, now.Add(time.Hour))
+			if !res.Allowed || res.Remaining != 2 {
+				t.Fatalf("\t%s should not refill over the burst. got %+v", tests.Failed, res)
+			}
+			t.Logf("\t%s should refill up to the burst", tests.Success)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	t.Log("Given the need to limit the route groups separately")
+	{
+		cases := []struct {
+			method, path, group string
+		}{
+			{http.MethodGet, "/v1/users/verify", ratelimit.GroupAuth},
+			{http.MethodGet, "/v1/sso/acc-1/login", ratelimit.GroupAuth},
+			{http.MethodPost, "/v1/accounts/drafts", ratelimit.GroupSignup},
+			{http.MethodPost, "/v1/accounts/launch/draft-1", ratelimit.GroupSignup},
+			{http.MethodPost, "/v1/accounts/acc-1/teams/t-1/entities/e-1/forms/i-1", ratelimit.GroupForms},
+			{http.MethodPost, "/aws/sns/a/p", ratelimit.GroupWebhooks},
+			{http.MethodPost, "/stripe/webhook", ratelimit.GroupWebhooks},
+			{http.MethodGet, "/v1/accounts/acc-1/teams/t-1/entities", ratelimit.GroupAPI},
+		}
+		for _, c := range cases {
+			if got := ratelimit.Group(c.method, c.path); got != c.group {
+				t.Fatalf("\t%s %s %s should be in %s. got %s", tests.Failed, c.method, c.path, c.group, got)
+			}
+			t.Logf("\t%s %s %s should be in %s", tests.Success, c.method, c.path, c.group)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// NameSpace is the prefix of the keys of the buckets in redis.
+const NameSpace = "RateLimit"
+
+// takeScript refills the bucket and takes the token atomically. It mirrors refill.
+var takeScript = redis.NewScript(1, `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore keeps the buckets in redis. The bucket expires once it is full again.
+type RedisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedisStore creates the store keeping the buckets in redis.
+func NewRedisStore(pool *redis.Pool) *RedisStore {
+	return &RedisStore{pool: pool}
+}
+
+// Take takes the token for the request from the bucket of the key.
+func (rs *RedisStore) Take(key string, l Limit, now time.Time) (Result, error) {
+	if l.Requests <= 0 || l.Per <= 0 {
+		return Result{}, errors.Errorf("invalid limit %s", l)
+	}
+
+	conn := rs.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Values(takeScript.Do(conn,
+		NameSpace+":"+key,
+		strconv.FormatFloat(l.capacity(), 'f', -1, 64),
+		strconv.FormatFloat(l.rate(), 'f', -1, 64),
+		now.UnixNano()/int64(time.Millisecond),
+	))
+	if err != nil {
+		return Result{}, errors.Wrap(err, "taking the token")
+	}
+
+	var allowed int
+	var tokensStr string
+	if _, err := redis.Scan(reply, &allowed, &tokensStr); err != nil {
+		return Result{}, errors.Wrap(err, "reading the bucket")
+	}
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "reading the tokens")
+	}
+
+	return result(l, tokens, allowed == 1), nil
+}
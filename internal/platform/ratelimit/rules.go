@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"net/http"
+	"strings"
+)
+
+// The route groups have their own limits. The public groups are limited per IP as the caller is not known yet.
+const (
+	GroupAuth     = "auth"     // login, join, visitor and single sign on tokens
+	GroupSignup   = "signup"   // account drafts and launch
+	GroupForms    = "forms"    // public forms submitted by the visitors
+	GroupWebhooks = "webhooks" // aws and stripe callbacks
+	GroupAPI      = "api"      // everything else, limited per user or per API key
+)
+
+// Config holds the limits of the route groups and the quotas of the plans. The plan quota is shared by
+// all the members and the API keys of the account. The nil store turns the limits off. TrustedHops is the
+// number of the proxies in front of the api whose X-Forwarded-For entries identify the client.
+type Config struct {
+	Store       Store
+	Groups      map[string]Limit
+	Plans       map[int]Limit
+	TrustedHops int
+}
+
+// NewConfig parses the limits of the groups and of the plans.
+func NewConfig(store Store, groups map[string]string, plans map[int]string) (Config, error) {
+	cfg := Config{
+		Store:  store,
+		Groups: make(map[string]Limit, len(groups)),
+		Plans:  make(map[int]Limit, len(plans)),
+	}
+	for g, s := range groups {
+		l, err := ParseLimit(s)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Groups[g] = l
+	}
+	for p, s := range plans {
+		l, err := ParseLimit(s)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg.Plans[p] = l
+	}
+	return cfg, nil
+}
+
+// Group returns the route group of the request.
+func Group(method, path string) string {
+	switch {
+	case strings.HasPrefix(path, "/v1/sso/"),
+		path == "/v1/users/verify",
+		path == "/v1/users/join",
+		path == "/v1/users/visit",
+		path == "/v1/users/mfa/verify":
+		return GroupAuth
+	case path == "/v1/accounts/drafts",
+		path == "/v1/accounts/availability",
+		strings.HasPrefix(path, "/v1/accounts/launch/"):
+		return GroupSignup
+	case method == http.MethodPost && strings.Contains(path, "/forms/"):
+		return GroupForms
+	case strings.HasPrefix(path, "/aws/"),
+		path == "/stripe/webhook":
+		return GroupWebhooks
+	}
+	return GroupAPI
+}
+
+// IsPublic reports whether the group is limited per IP.
+func IsPublic(group string) bool {
+	return group != GroupAPI
+}
@@ -0,0 +1,4 @@
+package ruler
+
+// RelativeNow exposes relativeNow to the tests of the package.
+var RelativeNow = relativeNow
@@ -380,8 +380,8 @@ func (c *caster) deepCaster(left bool) OperandDT {
 			return NumberDT
 		}
 		//time
-		if c.rightString == "now" {
-			c.rightString = time.Now().Format("2006-01-02 15:04:05 -07:00")
+		if rel, ok := relativeNow(c.rightString, time.Now()); ok {
+			c.rightString = rel.Format("2006-01-02 15:04:05 -07:00")
 		}
 		tR, errR := time.Parse("2006-01-02 15:04:05 -07:00", c.rightString)
 		if errR == nil {
@@ -392,6 +392,39 @@ func (c *caster) deepCaster(left bool) OperandDT {
 	return StrDT
 }
 
+// relativeNow resolves "now" and the date relative forms such as "now-14d", "now+2h" or "now-30m".
+// The supported units are m(inutes), h(ours), d(ays) and w(eeks).
+func relativeNow(s string, now time.Time) (time.Time, bool) {
+	if !strings.HasPrefix(s, "now") {
+		return now, false
+	}
+	offset := s[len("now"):]
+	if offset == "" {
+		return now, true
+	}
+	if len(offset) < 3 || (offset[0] != '-' && offset[0] != '+') {
+		return now, false
+	}
+	n, err := strconv.Atoi(offset[1 : len(offset)-1])
+	if err != nil {
+		return now, false
+	}
+	if offset[0] == '-' {
+		n = -n
+	}
+	switch offset[len(offset)-1] {
+	case 'm':
+		return now.Add(time.Duration(n) * time.Minute), true
+	case 'h':
+		return now.Add(time.Duration(n) * time.Hour), true
+	case 'd':
+		return now.AddDate(0, 0, n), true
+	case 'w':
+		return now.AddDate(0, 0, 7*n), true
+	}
+	return now, false
+}
+
 func findDT(right Operand) OperandDT {
 	c := caster{}
 	c.setRight(right)
@@ -0,0 +1,49 @@
+package ruler_test
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/ruleengine/services/ruler"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestRelativeNow(t *testing.T) {
+	now := time.Date(2020, time.March, 10, 12, 30, 0, 0, time.UTC)
+
+	tt := []struct {
+		name string
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{"bare now", "now", now, true},
+		{"minutes back", "now-30m", now.Add(-30 * time.Minute), true},
+		{"minutes ahead", "now+30m", now.Add(30 * time.Minute), true},
+		{"hours back", "now-2h", now.Add(-2 * time.Hour), true},
+		{"hours ahead", "now+2h", now.Add(2 * time.Hour), true},
+		{"days back", "now-14d", now.AddDate(0, 0, -14), true},
+		{"days ahead", "now+1d", now.AddDate(0, 0, 1), true},
+		{"weeks back", "now-2w", now.AddDate(0, 0, -14), true},
+		{"weeks ahead", "now+1w", now.AddDate(0, 0, 7), true},
+		{"bad unit", "now-3y", now, false},
+		{"missing number", "now-d", now, false},
+		{"missing sign", "now14d", now, false},
+		{"not a number", "now-xd", now, false},
+		{"not now", "today", now, false},
+	}
+
+	t.Log("Given the need to resolve the date relative to now")
+	{
+		for _, tc := range tt {
+			t.Logf("\twhen resolving %s %q", tc.name, tc.in)
+			{
+				got, ok := ruler.RelativeNow(tc.in, now)
+				if ok != tc.ok || !got.Equal(tc.want) {
+					t.Fatalf("\t%s should resolve %q to %v(%v) : got %v(%v)", tests.Failed, tc.in, tc.want, tc.ok, got, ok)
+				}
+				t.Logf("\t%s should resolve %q to %v(%v)", tests.Success, tc.in, tc.want, tc.ok)
+			}
+		}
+	}
+}
@@ -20,6 +20,10 @@ const (
 	TypeChatConversationAdded  = 7
 	TypeEventAdded             = 8
 	TypeAccountLaunch          = 9
+	TypeSLAChanged             = 10
+	TypeFieldMigration         = 11
+	TypeMetricBackfill         = 12
+	TypeHealthScore            = 13
 )
 
 const (
@@ -187,6 +191,62 @@ func NewEventItemMessage(ctx context.Context, db *sqlx.DB, accountID, userID, en
 	return m
 }
 
+func NewSLAMessage(ctx context.Context, db *sqlx.DB, accountID, userID, entityID, itemID string, meta map[string]interface{}) *Message {
+	m := &Message{
+		ID:        fmt.Sprintf("%s#%s", "sla", uuid.New().String()),
+		Type:      TypeSLAChanged,
+		AccountID: accountID,
+		UserID:    userID,
+		EntityID:  entityID,
+		ItemID:    itemID,
+		Meta:      meta,
+		State:     StateQueued,
+	}
+	add(ctx, db, m, "Queued", StateQueued)
+	return m
+}
+
+func NewFieldMigrationMessage(ctx context.Context, db *sqlx.DB, accountID, userID, entityID, migrationID string) *Message {
+	m := &Message{
+		ID:        fmt.Sprintf("%s#%s", "migration", uuid.New().String()),
+		Type:      TypeFieldMigration,
+		AccountID: accountID,
+		UserID:    userID,
+		EntityID:  entityID,
+		Meta:      map[string]interface{}{"migration_id": migrationID},
+		State:     StateQueued,
+	}
+	add(ctx, db, m, "Queued", StateQueued)
+	return m
+}
+
+func NewMetricBackfillMessage(ctx context.Context, db *sqlx.DB, accountID, userID, entityID string) *Message {
+	m := &Message{
+		ID:        fmt.Sprintf("%s#%s", "metric", uuid.New().String()),
+		Type:      TypeMetricBackfill,
+		AccountID: accountID,
+		UserID:    userID,
+		EntityID:  entityID,
+		State:     StateQueued,
+	}
+	add(ctx, db, m, "Queued", StateQueued)
+	return m
+}
+
+func NewHealthScoreMessage(ctx context.Context, db *sqlx.DB, accountID, userID, entityID, modelID string) *Message {
+	m := &Message{
+		ID:        fmt.Sprintf("%s#%s", "health", uuid.New().String()),
+		Type:      TypeHealthScore,
+		AccountID: accountID,
+		UserID:    userID,
+		EntityID:  entityID,
+		Meta:      map[string]interface{}{"model_id": modelID},
+		State:     StateQueued,
+	}
+	add(ctx, db, m, "Queued", StateQueued)
+	return m
+}
+
 func (m Message) TypeStr() string {
 	switch m.Type {
 	case TypeDefault:
@@ -205,6 +265,14 @@ func (m Message) TypeStr() string {
 		return "Type Email Conversation"
 	case TypeChatConversationAdded:
 		return "Type Chat Conversation"
+	case TypeSLAChanged:
+		return "Type SLA Changed"
+	case TypeFieldMigration:
+		return "Type Field Migration"
+	case TypeMetricBackfill:
+		return "Type Metric Backfill"
+	case TypeHealthScore:
+		return "Type Health Score"
 	default:
 		return "Type Not Implemented"
 	}
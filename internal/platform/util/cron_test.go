@@ -0,0 +1,52 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestCron(t *testing.T) {
+	// 2021-03-03 is a wednesday
+	from := time.Date(2021, 3, 3, 10, 30, 0, 0, time.UTC)
+
+	t.Log("Given the need to find the next run of the cron")
+	{
+		cases := []struct {
+			spec string
+			want time.Time
+		}{
+			{"*/15 * * * *", time.Date(2021, 3, 3, 10, 45, 0, 0, time.UTC)},
+			{"0 9 * * 1", time.Date(2021, 3, 8, 9, 0, 0, 0, time.UTC)},
+			{"0 9 * * 1-5", time.Date(2021, 3, 4, 9, 0, 0, 0, time.UTC)},
+			{"30 10 1 * *", time.Date(2021, 4, 1, 10, 30, 0, 0, time.UTC)},
+			{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+			{"0 12 15 * 0", time.Date(2021, 3, 3, 12, 0, 0, 0, time.UTC).AddDate(0, 0, 4)},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the spec is %q", c.spec)
+			{
+				cron, err := util.ParseCron(c.spec)
+				if err != nil {
+					t.Fatalf("\t%s should parse the spec : %s", tests.Failed, err)
+				}
+				if got := cron.Next(from); !got.Equal(c.want) {
+					t.Fatalf("\t%s should run next at %v. got %v", tests.Failed, c.want, got)
+				}
+				t.Logf("\t%s should run next at %v", tests.Success, c.want)
+			}
+		}
+
+		t.Log("\twhen the spec is malformed")
+		{
+			for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a b c d e"} {
+				if _, err := util.ParseCron(spec); err == nil {
+					t.Fatalf("\t%s should fail to parse %q", tests.Failed, spec)
+				}
+			}
+			t.Logf("\t%s should fail to parse", tests.Success)
+		}
+	}
+}
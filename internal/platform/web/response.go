@@ -46,6 +46,19 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 	return nil
 }
 
+// Redirect sends the client to the url. It is used by the endpoints which the browser
+// hits directly like the callbacks of the identity providers.
+func Redirect(ctx context.Context, w http.ResponseWriter, r *http.Request, url string) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+	v.StatusCode = http.StatusFound
+
+	http.Redirect(w, r, url, http.StatusFound)
+	return nil
+}
+
 // RespondError sends an error reponse back to the client.
 func RespondError(ctx context.Context, w http.ResponseWriter, err error) error {
 
@@ -102,3 +115,24 @@ func RespondXML(ctx context.Context, w http.ResponseWriter, data string, statusC
 
 	return nil
 }
+
+// RespondRaw sends the data as it is with the content type.
+func RespondRaw(ctx context.Context, w http.ResponseWriter, data []byte, contentType string, statusCode int) error {
+
+	// Set the status code for the request logger middleware.
+	// If the context is missing this value, request the service
+	// to be shutdown gracefully.
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+	v.StatusCode = statusCode
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"context"
+	"net/http"
+)
+
+// Route is the route registered on the app along with what its middleware requires from the caller.
+type Route struct {
+	Verb          string
+	Path          string
+	Auth          bool     // the bearer token is required
+	Roles         []string // any of the roles is required, empty when all the roles are allowed
+	AccountAccess bool     // the caller should belong to the account of the path
+}
+
+// describeKey is the context key holding the route being described.
+type describeKey struct{}
+
+// Describe adds the requirement of the middleware to the route when the app registers it.
+// The middleware calls it first and passes on to the next handler without doing its work
+// when it returns true.
+func Describe(ctx context.Context, requires func(rt *Route)) bool {
+	rt, ok := ctx.Value(describeKey{}).(*Route)
+	if !ok {
+		return false
+	}
+	requires(rt)
+	return true
+}
+
+// Routes returns the routes registered on the app in the order they were registered.
+func (a *App) Routes() []Route {
+	routes := make([]Route, len(a.routes))
+	copy(routes, a.routes)
+	return routes
+}
+
+// describe runs the route middleware in the describe mode to collect the requirements of the route.
+// The request is never served in this mode, so the middleware gets neither the writer nor the request.
+func describe(verb, path string, mw []Middleware) Route {
+	rt := Route{Verb: verb, Path: path}
+	h := wrapMiddleware(mw, func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+		return nil
+	})
+	h(context.WithValue(context.Background(), describeKey{}, &rt), nil, nil, map[string]string{})
+	return rt
+}
@@ -40,6 +40,7 @@ type App struct {
 	shutdown chan os.Signal
 	log      *log.Logger
 	mw       []Middleware
+	routes   []Route
 }
 
 // NewApp creates an App value that handle a set of routes for the application.
@@ -75,6 +76,9 @@ func (a *App) SignalShutdown() {
 // Handle is our mechanism for mounting Handlers for a given HTTP verb and path
 // pair, this makes for really easy, convenient routing.
 func (a *App) Handle(verb, path string, handler Handler, mw ...Middleware) {
+	// Keep the route with what its middleware requires so the API can be documented.
+	a.routes = append(a.routes, describe(verb, path, mw))
+
 	// First wrap handler specific middleware around this handler.
 	handler = wrapMiddleware(mw, handler)
 
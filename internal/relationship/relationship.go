@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -32,13 +33,13 @@ var (
 
 // Bonding creates the implicit relationships between two entities based on the reference fields
 // This type of associations are always 1:N
-func Bonding(ctx context.Context, db *sqlx.DB, accountID, srcEntityID string, rFields map[string]Relatable) error {
+func Bonding(ctx context.Context, db database.Conn, accountID, srcEntityID string, rFields map[string]Relatable) error {
 	relationships := populateBonds(accountID, srcEntityID, rFields)
 	return bulkCreate(ctx, db, accountID, relationships)
 }
 
 // ReBonding updates the implicit relationships between two entities based on the reference fields on the event of entity update
-func ReBonding(ctx context.Context, db *sqlx.DB, accountID, srcEntityID string, rFields map[string]Relatable) error {
+func ReBonding(ctx context.Context, db database.Conn, accountID, srcEntityID string, rFields map[string]Relatable) error {
 	existingRelationships, err := Relationships(ctx, db, accountID, srcEntityID)
 	if err != nil {
 		return err
@@ -69,13 +70,13 @@ func ReBonding(ctx context.Context, db *sqlx.DB, accountID, srcEntityID string,
 
 // Associate creates the explicit relationships between two entities given by the customer
 // This type of associations are always N:N
-func Associate(ctx context.Context, db *sqlx.DB, accountID, srcEntityID, dstEntityID string) (string, error) {
+func Associate(ctx context.Context, db database.Conn, accountID, srcEntityID, dstEntityID string) (string, error) {
 	relationshipID, relationships := populateAssociation(accountID, srcEntityID, dstEntityID)
 	return relationshipID, bulkCreate(ctx, db, accountID, relationships)
 }
 
 //TODO: implement bulk create
-func bulkCreate(ctx context.Context, db *sqlx.DB, accountID string, relationships []Relationship) error {
+func bulkCreate(ctx context.Context, db database.Conn, accountID string, relationships []Relationship) error {
 	for _, r := range relationships {
 		_, err := Create(ctx, db, r)
 		if err != nil {
@@ -87,7 +88,7 @@ func bulkCreate(ctx context.Context, db *sqlx.DB, accountID string, relationship
 }
 
 //TODO: implement bulk update
-func bulkUpdate(ctx context.Context, db *sqlx.DB, accountID string, relationships []Relationship) error {
+func bulkUpdate(ctx context.Context, db database.Conn, accountID string, relationships []Relationship) error {
 	for _, r := range relationships {
 		err := Update(ctx, db, r)
 		if err != nil {
@@ -97,7 +98,7 @@ func bulkUpdate(ctx context.Context, db *sqlx.DB, accountID string, relationship
 	return nil
 }
 
-func bulkDelete(ctx context.Context, db *sqlx.DB, accountID string, relationshipIDs []string) error {
+func bulkDelete(ctx context.Context, db database.Conn, accountID string, relationshipIDs []string) error {
 	for _, rID := range relationshipIDs {
 		err := Delete(ctx, db, accountID, rID)
 		if err != nil {
@@ -108,7 +109,7 @@ func bulkDelete(ctx context.Context, db *sqlx.DB, accountID string, relationship
 }
 
 // Create adds new relationship with respective types.
-func Create(ctx context.Context, db *sqlx.DB, r Relationship) (Relationship, error) {
+func Create(ctx context.Context, db database.Conn, r Relationship) (Relationship, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.relationship.Create")
 	defer span.End()
 
@@ -127,7 +128,7 @@ func Create(ctx context.Context, db *sqlx.DB, r Relationship) (Relationship, err
 	return r, nil
 }
 
-func Update(ctx context.Context, db *sqlx.DB, r Relationship) error {
+func Update(ctx context.Context, db database.Conn, r Relationship) error {
 	ctx, span := trace.StartSpan(ctx, "internal.relationship.Update")
 	defer span.End()
 
@@ -142,7 +143,7 @@ func Update(ctx context.Context, db *sqlx.DB, r Relationship) error {
 	return nil
 }
 
-func Delete(ctx context.Context, db *sqlx.DB, accountID, relationshipID string) error {
+func Delete(ctx context.Context, db database.Conn, accountID, relationshipID string) error {
 	ctx, span := trace.StartSpan(ctx, "internal.relationship.Delete")
 	defer span.End()
 
@@ -228,7 +229,7 @@ func List(ctx context.Context, db *sqlx.DB, accountID, teamID, entityID string,
 	return trimmedBonds, nil
 }
 
-func Relationships(ctx context.Context, db *sqlx.DB, accountID, entityID string) ([]Relationship, error) {
+func Relationships(ctx context.Context, db database.Conn, accountID, entityID string) ([]Relationship, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.relationship.List")
 	defer span.End()
 
@@ -0,0 +1,101 @@
+package report
+
+// Calc is how the measure aggregates the values of the field.
+type Calc string
+
+// Calcs of the measure. The count without the field counts the items.
+const (
+	CalcCount    Calc = "count"
+	CalcSum      Calc = "sum"
+	CalcAvg      Calc = "avg"
+	CalcMin      Calc = "min"
+	CalcMax      Calc = "max"
+	CalcDistinct Calc = "distinct"
+)
+
+// Buckets the date dimensions are grouped by.
+const (
+	BucketDay     = "day"
+	BucketWeek    = "week"
+	BucketMonth   = "month"
+	BucketQuarter = "quarter"
+	BucketYear    = "year"
+
+	// the fiscal buckets are named by the year the fiscal year ends in, as FY2025 from april 2024
+	// when it starts in april.
+	BucketFiscalQuarter = "fiscal_quarter"
+	BucketFiscalYear    = "fiscal_year"
+)
+
+// Columns of the items the dimensions and the measures could use along with the field keys.
+const (
+	KeyCreatedAt = "created_at"
+	KeyUpdatedAt = "updated_at"
+)
+
+// DefaultLimit is the number of groups returned when the report does not specify one and MaxLimit
+// is the most it could ask for.
+const (
+	DefaultLimit = 1000
+	MaxLimit     = 10000
+)
+
+// Report is the ad-hoc report over the items of an entity. The items matching the expression are grouped
+// by the rows and the columns and every group gets the measures. The columns are pivoted across.
+type Report struct {
+	EntityID string      `json:"entity_id"`
+	Name     string      `json:"name"`
+	Rows     []Dimension `json:"rows"`
+	Columns  []Dimension `json:"columns"`
+	Measures []Measure   `json:"measures"`
+	Exp      string      `json:"exp"`
+	Limit    int         `json:"limit"`
+}
+
+// Dimension groups the items by the value of the field. With via, the field is of the entity
+// the reference field via refers, such as the industry of the company of the deal. The date
+// fields are grouped by the bucket.
+type Dimension struct {
+	Key    string `json:"key"`
+	Via    string `json:"via"`
+	Bucket string `json:"bucket"`
+	Label  string `json:"label"`
+}
+
+// Measure aggregates the field of the items in the group.
+type Measure struct {
+	Calc  Calc   `json:"calc"`
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// Result is the flat output of the report, one row for every group.
+type Result struct {
+	Dimensions []string `json:"dimensions"`
+	Measures   []string `json:"measures"`
+	Rows       []Row    `json:"rows"`
+}
+
+// Row is the group with the display values of its dimensions and its measures. The measure is nil
+// when no item in the group has the value.
+type Row struct {
+	Keys   []string   `json:"keys"`
+	Values []*float64 `json:"values"`
+}
+
+// Pivot is the output of the report with the column dimensions pivoted across. The cells are indexed
+// by the row, the column and the measure.
+type Pivot struct {
+	RowDimensions    []string       `json:"row_dimensions"`
+	ColumnDimensions []string       `json:"column_dimensions"`
+	Measures         []string       `json:"measures"`
+	Rows             [][]string     `json:"rows"`
+	Columns          [][]string     `json:"columns"`
+	Cells            [][][]*float64 `json:"cells"`
+}
+
+// Table is the report laid out for the export. The values are the strings, the float64s or nil.
+type Table struct {
+	Header []string
+	Rows   [][]interface{}
+}
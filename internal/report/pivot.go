@@ -0,0 +1,103 @@
+package report
+
+import (
+	"sort"
+	"strings"
+)
+
+// Pivot lays the result out with its first rows dimensions down and the rest across. The rows keep
+// the order of the result and the columns are sorted, so the date buckets read left to right.
+func (res Result) Pivot(rows int) Pivot {
+	if rows > len(res.Dimensions) {
+		rows = len(res.Dimensions)
+	}
+	p := Pivot{
+		RowDimensions:    res.Dimensions[:rows],
+		ColumnDimensions: res.Dimensions[rows:],
+		Measures:         res.Measures,
+		Rows:             make([][]string, 0),
+		Columns:          make([][]string, 0),
+	}
+
+	rowIndex := map[string]int{}
+	columnIndex := map[string]int{}
+	for _, r := range res.Rows {
+		if _, ok := rowIndex[id(r.Keys[:rows])]; !ok {
+			rowIndex[id(r.Keys[:rows])] = len(p.Rows)
+			p.Rows = append(p.Rows, r.Keys[:rows])
+		}
+		if _, ok := columnIndex[id(r.Keys[rows:])]; !ok {
+			columnIndex[id(r.Keys[rows:])] = 0
+			p.Columns = append(p.Columns, r.Keys[rows:])
+		}
+	}
+	sort.SliceStable(p.Columns, func(i, j int) bool { return id(p.Columns[i]) < id(p.Columns[j]) })
+	for i, c := range p.Columns {
+		columnIndex[id(c)] = i
+	}
+
+	p.Cells = make([][][]*float64, len(p.Rows))
+	for i := range p.Cells {
+		p.Cells[i] = make([][]*float64, len(p.Columns))
+	}
+	for _, r := range res.Rows {
+		p.Cells[rowIndex[id(r.Keys[:rows])]][columnIndex[id(r.Keys[rows:])]] = r.Values
+	}
+	return p
+}
+
+// Table lays the result out as it is, the dimensions followed by the measures.
+func (res Result) Table() Table {
+	t := Table{Header: append(append([]string{}, res.Dimensions...), res.Measures...), Rows: make([][]interface{}, 0, len(res.Rows))}
+	for _, r := range res.Rows {
+		row := make([]interface{}, 0, len(t.Header))
+		for _, k := range r.Keys {
+			row = append(row, k)
+		}
+		t.Rows = append(t.Rows, append(row, values(r.Values, len(res.Measures))...))
+	}
+	return t
+}
+
+// Table lays the pivot out with a column for every measure of every pivoted column.
+func (p Pivot) Table() Table {
+	t := Table{Header: append([]string{}, p.RowDimensions...), Rows: make([][]interface{}, 0, len(p.Rows))}
+	for _, c := range p.Columns {
+		for _, m := range p.Measures {
+			switch {
+			case len(c) == 0:
+				t.Header = append(t.Header, m)
+			case len(p.Measures) == 1:
+				t.Header = append(t.Header, strings.Join(c, " / "))
+			default:
+				t.Header = append(t.Header, strings.Join(append(append([]string{}, c...), m), " / "))
+			}
+		}
+	}
+	for i, r := range p.Rows {
+		row := make([]interface{}, 0, len(t.Header))
+		for _, k := range r {
+			row = append(row, k)
+		}
+		for _, cell := range p.Cells[i] {
+			row = append(row, values(cell, len(p.Measures))...)
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// values are the measures for the table, nil for the measures missing.
+func values(vs []*float64, n int) []interface{} {
+	out := make([]interface{}, n)
+	for i := 0; i < n && i < len(vs); i++ {
+		if vs[i] != nil {
+			out[i] = *vs[i]
+		}
+	}
+	return out
+}
+
+func id(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
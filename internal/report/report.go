@@ -0,0 +1,441 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/chart"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database/dbservice"
+	"gitlab.com/vjsideprojects/relay/internal/platform/graphdb"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrInvalidReport is used when the report asks for the fields or the calcs it cannot have.
+	ErrInvalidReport = errors.New("Report is not valid")
+)
+
+// maxDimensions is the most rows and columns the report could group by together.
+const maxDimensions = 5
+
+// fieldKey guards the keys interpolated in the query, the field keys are the UUIDs.
+var fieldKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// number matches the values the numeric measures could cast.
+const number = `^-?[0-9]+(\.[0-9]+)?$`
+
+// bucketFormats are the postgres formats the dates are grouped by.
+var bucketFormats = map[string]string{
+	BucketDay:     "YYYY-MM-DD",
+	BucketWeek:    `IYYY-"W"IW`,
+	BucketMonth:   "YYYY-MM",
+	BucketQuarter: `YYYY-"Q"Q`,
+	BucketYear:    "YYYY",
+
+	BucketFiscalQuarter: `"FY"YYYY-"Q"Q`,
+	BucketFiscalYear:    `"FY"YYYY`,
+}
+
+// calcLabels label the measures not named.
+var calcLabels = map[Calc]string{
+	CalcCount:    "Count of %s",
+	CalcSum:      "Sum of %s",
+	CalcAvg:      "Average %s",
+	CalcMin:      "Min %s",
+	CalcMax:      "Max %s",
+	CalcDistinct: "Distinct %s",
+}
+
+// column is the dimension resolved against the fields of the entities.
+type column struct {
+	label string
+	expr  string
+	field entity.Field
+}
+
+// join is the entity referred by the reference field of the items.
+type join struct {
+	alias string
+	key   string
+}
+
+// query is the report resolved against the fields of the entities.
+type query struct {
+	dims     []column
+	measures []string
+	labels   []string
+	joins    []join
+}
+
+// Run groups the items of the report entity matching the conditions and measures every group. The
+// dimensions of the reference and the list fields show the display values of the items and the choices.
+// The dates are bucketed in the zone and by the week start of the calendar.
+func Run(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID string, rp Report, conditions []graphdb.Field, cal timerange.Settings) (Result, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.report.Run")
+	defer span.End()
+
+	e, err := entity.Retrieve(ctx, accountID, rp.EntityID, db, sdb)
+	if err != nil {
+		return Result{}, err
+	}
+	fields := e.EasyFields()
+	refs := map[string][]entity.Field{}
+	for _, d := range rp.dimensions() {
+		if d.Via == "" {
+			continue
+		}
+		via := entity.KeyMap(fields)[d.Via]
+		if !via.IsReference() || refs[via.RefID] != nil {
+			continue
+		}
+		re, err := entity.Retrieve(ctx, accountID, via.RefID, db, sdb)
+		if err != nil {
+			return Result{}, err
+		}
+		refs[via.RefID] = re.EasyFields()
+	}
+
+	qu, err := resolve(rp, fields, refs, cal)
+	if err != nil {
+		return Result{}, err
+	}
+	where := strings.Join(dbservice.WhBuilder(conditions), " AND ")
+	if where != "" {
+		where = "AND " + where
+	}
+
+	rows, err := db.QueryxContext(ctx, qu.sql(where, rp.limit()), accountID, rp.EntityID, item.StateDefault, pgZone(cal.Location))
+	if err != nil {
+		return Result{}, errors.Wrap(err, "selecting report")
+	}
+	defer rows.Close()
+
+	res := Result{Dimensions: make([]string, 0, len(qu.dims)), Measures: qu.labels, Rows: make([]Row, 0)}
+	for _, c := range qu.dims {
+		res.Dimensions = append(res.Dimensions, c.label)
+	}
+	for rows.Next() {
+		keys := make([]sql.NullString, len(qu.dims))
+		values := make([]sql.NullFloat64, len(qu.measures))
+		dest := make([]interface{}, 0, len(keys)+len(values))
+		for i := range keys {
+			dest = append(dest, &keys[i])
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return Result{}, errors.Wrap(err, "scanning report")
+		}
+
+		r := Row{Keys: make([]string, len(keys)), Values: make([]*float64, len(values))}
+		for i, k := range keys {
+			r.Keys[i] = k.String
+		}
+		for i, v := range values {
+			if v.Valid {
+				f := v.Float64
+				r.Values[i] = &f
+			}
+		}
+		res.Rows = append(res.Rows, r)
+	}
+	if err := rows.Err(); err != nil {
+		return Result{}, errors.Wrap(err, "reading report")
+	}
+
+	for i, c := range qu.dims {
+		labels, err := displayValues(ctx, db, sdb, accountID, c.field, res.Rows, i)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, r := range res.Rows {
+			if l, ok := labels[r.Keys[i]]; ok {
+				r.Keys[i] = l
+			}
+		}
+	}
+	return res, nil
+}
+
+// resolve validates the report against the fields of its entity and the fields of the entities referred,
+// keyed by their entity id.
+func resolve(rp Report, fields []entity.Field, refs map[string][]entity.Field, cal timerange.Settings) (query, error) {
+	if len(rp.Measures) == 0 {
+		return query{}, errors.Wrap(ErrInvalidReport, "no measures")
+	}
+	if len(rp.dimensions()) > maxDimensions {
+		return query{}, errors.Wrapf(ErrInvalidReport, "more than %d dimensions", maxDimensions)
+	}
+
+	var qu query
+	base := entity.KeyMap(fields)
+	aliases := map[string]string{}
+	for _, d := range rp.dimensions() {
+		alias, owner := "i", base
+		if d.Via != "" {
+			via, ok := base[d.Via]
+			if !ok || !via.IsReference() || refs[via.RefID] == nil {
+				return query{}, errors.Wrapf(ErrInvalidReport, "unknown reference field %q", d.Via)
+			}
+			if alias, ok = aliases[d.Via]; !ok {
+				alias = fmt.Sprintf("j%d", len(qu.joins))
+				aliases[d.Via] = alias
+				qu.joins = append(qu.joins, join{alias: alias, key: d.Via})
+			}
+			owner = entity.KeyMap(refs[via.RefID])
+		}
+		c, err := dimension(d, alias, owner, cal)
+		if err != nil {
+			return query{}, err
+		}
+		qu.dims = append(qu.dims, c)
+	}
+
+	for _, m := range rp.Measures {
+		expr, label, err := measure(m, base)
+		if err != nil {
+			return query{}, err
+		}
+		qu.measures = append(qu.measures, expr)
+		qu.labels = append(qu.labels, label)
+	}
+	return qu, nil
+}
+
+// dimension resolves the dimension against the fields of the items aliased. The reference and the list
+// fields are grouped by their first value.
+func dimension(d Dimension, alias string, fields map[string]entity.Field, cal timerange.Settings) (column, error) {
+	f, ok := fields[d.Key]
+	isColumn := d.Key == KeyCreatedAt || d.Key == KeyUpdatedAt
+	if !fieldKey.MatchString(d.Key) || (!ok && !isColumn) {
+		return column{}, errors.Wrapf(ErrInvalidReport, "unknown field %q", d.Key)
+	}
+
+	c := column{label: d.Label, field: f}
+	if c.label == "" {
+		c.label = f.DisplayName
+	}
+	if c.label == "" {
+		c.label = d.Key
+	}
+
+	switch {
+	case isColumn && !ok:
+		c.expr = fmt.Sprintf("%s.%s", alias, d.Key)
+	case f.IsReference(), f.IsList():
+		c.expr = fmt.Sprintf("%s.fieldsb->'%s'->>0", alias, d.Key)
+	default:
+		c.expr = fmt.Sprintf("%s.fieldsb->>'%s'", alias, d.Key)
+	}
+
+	if d.Bucket != "" {
+		format, known := bucketFormats[d.Bucket]
+		if !known {
+			return column{}, errors.Wrapf(ErrInvalidReport, "unknown bucket %q", d.Bucket)
+		}
+		if ok && !f.IsDateOrTime() {
+			return column{}, errors.Wrapf(ErrInvalidReport, "field %q is not a date", d.Key)
+		}
+		local := localTime(c.expr, d.Key, isColumn && !ok)
+		switch d.Bucket {
+		case BucketWeek:
+			// the iso weeks start on monday, the dates are moved so the week start of the calendar falls on it.
+			local = fmt.Sprintf("(%s + interval '%d days')", local, (8-int(cal.WeekStart))%7)
+		case BucketFiscalQuarter, BucketFiscalYear:
+			// the fiscal start is moved to january so the calendar quarters and years are the fiscal ones.
+			if shift := (13 - int(cal.FiscalMonth())) % 12; shift > 0 {
+				local = fmt.Sprintf("(%s + interval '%d months')", local, shift)
+			}
+		}
+		c.expr = fmt.Sprintf("to_char(%s, '%s')", local, format)
+	}
+	return c, nil
+}
+
+// localTime reads the date of the dimension as the time in the zone of the calendar, the query param $4.
+// The created at is kept in UTC, the updated at is the unix seconds and the older clients saved the dates
+// as the unix milliseconds.
+func localTime(expr, key string, isColumn bool) string {
+	switch {
+	case isColumn && key == KeyUpdatedAt:
+		return fmt.Sprintf("(to_timestamp(%s) AT TIME ZONE $4)", expr)
+	case isColumn:
+		return fmt.Sprintf("((%s AT TIME ZONE 'UTC') AT TIME ZONE $4)", expr)
+	}
+	return fmt.Sprintf("((CASE WHEN %[1]s ~ '^[0-9]+$' THEN to_timestamp((%[1]s)::bigint / 1000.0) ELSE (%[1]s)::timestamptz END) AT TIME ZONE $4)", expr)
+}
+
+// pgZone names the zone of the calendar for postgres, UTC when it is not set. The fixed zones, such as the
+// offset of the browser, are written in the POSIX form which counts the hours west of greenwich.
+func pgZone(loc *time.Location) string {
+	if loc == nil {
+		return "UTC"
+	}
+	if name := loc.String(); name != "" && name != "Local" {
+		if _, err := time.LoadLocation(name); err == nil {
+			return name
+		}
+	}
+	_, offset := time.Now().In(loc).Zone()
+	sign := "-"
+	if offset < 0 {
+		sign, offset = "+", -offset
+	}
+	return fmt.Sprintf("UTC%s%02d:%02d", sign, offset/3600, offset%3600/60)
+}
+
+// measure resolves the measure against the fields of the report entity. The sums and the rest skip
+// the values that are not numbers.
+func measure(m Measure, fields map[string]entity.Field) (string, string, error) {
+	if m.Key == "" {
+		if m.Calc != CalcCount {
+			return "", "", errors.Wrapf(ErrInvalidReport, "%s needs the field", m.Calc)
+		}
+		return "count(*)", labelOr(m.Label, "Count"), nil
+	}
+	f, ok := fields[m.Key]
+	if !ok || !fieldKey.MatchString(m.Key) {
+		return "", "", errors.Wrapf(ErrInvalidReport, "unknown field %q", m.Key)
+	}
+
+	format, known := calcLabels[m.Calc]
+	if !known {
+		return "", "", errors.Wrapf(ErrInvalidReport, "unknown calc %q", m.Calc)
+	}
+
+	value := fmt.Sprintf("i.fieldsb->>'%s'", m.Key)
+	label := labelOr(m.Label, fmt.Sprintf(format, f.DisplayName))
+	switch m.Calc {
+	case CalcCount:
+		return fmt.Sprintf("count(%s)", value), label, nil
+	case CalcDistinct:
+		return fmt.Sprintf("count(DISTINCT %s)", value), label, nil
+	default:
+		return fmt.Sprintf("%s(CASE WHEN %s ~ '%s' THEN (%s)::numeric END)", m.Calc, value, number, value), label, nil
+	}
+}
+
+// sql selects the dimensions as d0, d1.. and the measures as m0, m1.. ordered by the dimensions.
+func (qu query) sql(where string, limit int) string {
+	sel := make([]string, 0, len(qu.dims)+len(qu.measures))
+	grp := make([]string, 0, len(qu.dims))
+	for i, c := range qu.dims {
+		sel = append(sel, fmt.Sprintf("(%s)::text AS d%d", c.expr, i))
+		grp = append(grp, strconv.Itoa(i+1))
+	}
+	for i, m := range qu.measures {
+		sel = append(sel, fmt.Sprintf("(%s)::float8 AS m%d", m, i))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s FROM (SELECT * FROM items WHERE account_id = $1 AND entity_id = $2 AND state = $3 %s) i", strings.Join(sel, ", "), where)
+	for _, j := range qu.joins {
+		fmt.Fprintf(&b, " LEFT JOIN items %s ON %s.account_id = i.account_id AND %s.item_id::text = i.fieldsb->'%s'->>0", j.alias, j.alias, j.alias, j.key)
+	}
+	if len(grp) > 0 {
+		fmt.Fprintf(&b, " GROUP BY %s ORDER BY %s", strings.Join(grp, ", "), strings.Join(grp, ", "))
+	}
+	fmt.Fprintf(&b, " LIMIT %d", limit)
+	return b.String()
+}
+
+// displayValues maps the ids in the dimension to the display values of the items referred or the choices.
+func displayValues(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID string, f entity.Field, rows []Row, dim int) (map[string]string, error) {
+	labels := map[string]string{}
+	switch {
+	case f.IsList():
+		for _, c := range f.Choices {
+			if c.DisplayValue != nil {
+				labels[c.ID] = fmt.Sprint(c.DisplayValue)
+			}
+		}
+	case f.IsReference() && f.RefID != "" && !f.IsFlow() && !f.IsNode():
+		ids := make([]interface{}, 0)
+		for _, r := range rows {
+			if r.Keys[dim] != "" {
+				ids = append(ids, r.Keys[dim])
+			}
+		}
+		if len(ids) == 0 {
+			return labels, nil
+		}
+		key := f.DisplayGex()
+		if key == "" {
+			re, err := entity.Retrieve(ctx, accountID, f.RefID, db, sdb)
+			if err != nil {
+				return nil, err
+			}
+			key = entity.TitleField(re.EasyFields()).Key
+		}
+		items, err := item.BulkRetrieveItems(ctx, accountID, ids, db)
+		if err != nil {
+			return nil, err
+		}
+		for _, it := range items {
+			if v, ok := it.Fields()[key]; ok && v != nil {
+				labels[it.ID] = fmt.Sprint(v)
+			}
+		}
+	}
+	return labels, nil
+}
+
+// dimensions are the rows followed by the columns.
+func (rp Report) dimensions() []Dimension {
+	return append(append(make([]Dimension, 0, len(rp.Rows)+len(rp.Columns)), rp.Rows...), rp.Columns...)
+}
+
+func (rp Report) limit() int {
+	if rp.Limit <= 0 {
+		return DefaultLimit
+	}
+	if rp.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return rp.Limit
+}
+
+func labelOr(label, fallback string) string {
+	if label != "" {
+		return label
+	}
+	return fallback
+}
+
+// Chart makes the chart of the dashboard showing the report.
+func (rp Report) Chart(accountID, teamID, dashboardID, displayName string, t chart.Type) (*chart.NewChart, error) {
+	spec, err := json.Marshal(rp)
+	if err != nil {
+		return nil, errors.Wrap(err, "encode report to spec")
+	}
+	name := rp.Name
+	if name == "" {
+		name = displayName
+	}
+	return chart.BuildNewChart(accountID, teamID, dashboardID, rp.EntityID, name, displayName, "", t).SetDurationAllTime().SetReport(string(spec)), nil
+}
+
+// FromChart reads the report the chart was saved from.
+func FromChart(c chart.Chart) (Report, error) {
+	var rp Report
+	if c.GetDType() != string(chart.DTypeReport) {
+		return rp, errors.Wrapf(ErrInvalidReport, "chart %q is not a report", c.ID)
+	}
+	if err := json.Unmarshal([]byte(c.GetReport()), &rp); err != nil {
+		return rp, errors.Wrap(err, "decode report spec")
+	}
+	return rp, nil
+}
@@ -0,0 +1,169 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/timerange"
+)
+
+var (
+	dealFields = []entity.Field{
+		{Key: "amount", DisplayName: "Amount", DataType: entity.TypeNumber},
+		{Key: "close", DisplayName: "Close Date", DataType: entity.TypeDateTime},
+		{Key: "company", DisplayName: "Company", DataType: entity.TypeReference, RefID: "companies"},
+	}
+	companyFields = []entity.Field{
+		{Key: "industry", DisplayName: "Industry", DataType: entity.TypeString},
+	}
+)
+
+func TestQuery(t *testing.T) {
+	rp := Report{
+		Rows:     []Dimension{{Key: "industry", Via: "company"}},
+		Columns:  []Dimension{{Key: "close", Bucket: BucketQuarter}},
+		Measures: []Measure{{Calc: CalcCount}, {Calc: CalcSum, Key: "amount"}, {Calc: CalcDistinct, Key: "company", Label: "Companies"}},
+	}
+
+	t.Log("Given the need to group the deals by the industry of their company and the quarter they close")
+	{
+		qu, err := resolve(rp, dealFields, map[string][]entity.Field{"companies": companyFields}, timerange.Settings{})
+		if err != nil {
+			t.Fatalf("\t%s should resolve the report : %s", tests.Failed, err)
+		}
+		got := qu.sql("AND fieldsb->>'amount' IS NOT NULL", rp.limit())
+		for _, want := range []string{
+			"SELECT (j0.fieldsb->>'industry')::text AS d0, (to_char(((CASE WHEN i.fieldsb->>'close' ~ '^[0-9]+$' THEN to_timestamp((i.fieldsb->>'close')::bigint / 1000.0) ELSE (i.fieldsb->>'close')::timestamptz END) AT TIME ZONE $4), 'YYYY-\"Q\"Q'))::text AS d1, (count(*))::float8 AS m0",
+			"(sum(CASE WHEN i.fieldsb->>'amount' ~ '" + number + "' THEN (i.fieldsb->>'amount')::numeric END))::float8 AS m1",
+			"(count(DISTINCT i.fieldsb->>'company'))::float8 AS m2",
+			"FROM (SELECT * FROM items WHERE account_id = $1 AND entity_id = $2 AND state = $3 AND fieldsb->>'amount' IS NOT NULL) i",
+			"LEFT JOIN items j0 ON j0.account_id = i.account_id AND j0.item_id::text = i.fieldsb->'company'->>0",
+			"GROUP BY 1, 2 ORDER BY 1, 2 LIMIT 1000",
+		} {
+			if !strings.Contains(got, want) {
+				t.Fatalf("\t%s should build the query with %q : %s", tests.Failed, want, got)
+			}
+		}
+		if strings.Join(qu.labels, ",") != "Count,Sum of Amount,Companies" || qu.dims[0].label != "Industry" {
+			t.Fatalf("\t%s should label the dimensions and the measures : %v", tests.Failed, qu.labels)
+		}
+		t.Logf("\t%s should build the query", tests.Success)
+
+		invalid := []Report{
+			{},
+			{Measures: []Measure{{Calc: CalcSum}}},
+			{Measures: []Measure{{Calc: "median", Key: "amount"}}},
+			{Measures: []Measure{{Calc: CalcCount}}, Rows: []Dimension{{Key: "amount", Bucket: BucketMonth}}},
+			{Measures: []Measure{{Calc: CalcCount}}, Rows: []Dimension{{Key: "industry", Via: "amount"}}},
+			{Measures: []Measure{{Calc: CalcCount}}, Rows: []Dimension{{Key: "x' OR 1=1 --"}}},
+		}
+		for _, rp := range invalid {
+			if _, err := resolve(rp, dealFields, nil, timerange.Settings{}); errors.Cause(err) != ErrInvalidReport {
+				t.Fatalf("\t%s should reject the report %+v : %v", tests.Failed, rp, err)
+			}
+		}
+		t.Logf("\t%s should reject the reports asking for the fields or the calcs it cannot have", tests.Success)
+	}
+}
+
+func TestBucketCalendar(t *testing.T) {
+	t.Log("Given the need to bucket the dates by the calendar of the account")
+	{
+		rp := Report{Rows: []Dimension{{Key: KeyCreatedAt, Bucket: BucketWeek}}, Measures: []Measure{{Calc: CalcCount}}}
+		qu, err := resolve(rp, dealFields, nil, timerange.Settings{WeekStart: time.Sunday})
+		if err != nil {
+			t.Fatalf("\t%s should resolve the report : %s", tests.Failed, err)
+		}
+		want := `to_char((((i.created_at AT TIME ZONE 'UTC') AT TIME ZONE $4) + interval '1 days'), 'IYYY-"W"IW')`
+		if qu.dims[0].expr != want {
+			t.Fatalf("\t%s should move the weeks to start on sunday : %s", tests.Failed, qu.dims[0].expr)
+		}
+		t.Logf("\t%s should move the weeks to start on sunday", tests.Success)
+
+		rp = Report{Rows: []Dimension{{Key: KeyUpdatedAt, Bucket: BucketFiscalQuarter}}, Measures: []Measure{{Calc: CalcCount}}}
+		qu, err = resolve(rp, dealFields, nil, timerange.Settings{FiscalStart: time.April})
+		if err != nil {
+			t.Fatalf("\t%s should resolve the report : %s", tests.Failed, err)
+		}
+		want = `to_char(((to_timestamp(i.updated_at) AT TIME ZONE $4) + interval '9 months'), '"FY"YYYY-"Q"Q')`
+		if qu.dims[0].expr != want {
+			t.Fatalf("\t%s should move the fiscal start in april to the first quarter : %s", tests.Failed, qu.dims[0].expr)
+		}
+		t.Logf("\t%s should move the fiscal start in april to the first quarter", tests.Success)
+
+		zones := []struct {
+			loc  *time.Location
+			want string
+		}{
+			{nil, "UTC"},
+			{time.UTC, "UTC"},
+			{time.FixedZone("IST", 5*3600+1800), "UTC-05:30"},
+			{time.FixedZone("", -8*3600), "UTC+08:00"},
+		}
+		for _, z := range zones {
+			if got := pgZone(z.loc); got != z.want {
+				t.Fatalf("\t%s should name the zone %v for postgres as %q : got %q", tests.Failed, z.loc, z.want, got)
+			}
+		}
+		t.Logf("\t%s should name the zones for postgres", tests.Success)
+	}
+}
+
+func TestPivot(t *testing.T) {
+	v := func(f float64) *float64 { return &f }
+	res := Result{
+		Dimensions: []string{"Industry", "Close Date"},
+		Measures:   []string{"Count"},
+		Rows: []Row{
+			{Keys: []string{"Retail", "2024-Q2"}, Values: []*float64{v(2)}},
+			{Keys: []string{"Software", "2024-Q1"}, Values: []*float64{v(1)}},
+			{Keys: []string{"Software", "2024-Q2"}, Values: []*float64{v(4)}},
+		},
+	}
+
+	t.Log("Given the need to pivot the quarters across the industries")
+	{
+		p := res.Pivot(1)
+		if len(p.Rows) != 2 || len(p.Columns) != 2 || p.Columns[0][0] != "2024-Q1" {
+			t.Fatalf("\t%s should sort the columns : %+v", tests.Failed, p)
+		}
+		if p.Cells[0][0] != nil || *p.Cells[0][1][0] != 2 || *p.Cells[1][0][0] != 1 {
+			t.Fatalf("\t%s should place the cells : %+v", tests.Failed, p.Cells)
+		}
+		t.Logf("\t%s should pivot the columns", tests.Success)
+
+		tb := p.Table()
+		if strings.Join(tb.Header, ",") != "Industry,2024-Q1,2024-Q2" || tb.Rows[0][1] != nil || tb.Rows[1][2] != 4.0 {
+			t.Fatalf("\t%s should lay the pivot out : %+v", tests.Failed, tb)
+		}
+		t.Logf("\t%s should lay the pivot out", tests.Success)
+
+		var buf bytes.Buffer
+		if err := WriteCSV(&buf, tb); err != nil || buf.String() != "Industry,2024-Q1,2024-Q2\nRetail,,2\nSoftware,1,4\n" {
+			t.Fatalf("\t%s should write the csv : %v %q", tests.Failed, err, buf.String())
+		}
+		t.Logf("\t%s should write the csv", tests.Success)
+
+		buf.Reset()
+		if err := WriteXLSX(&buf, res.Table()); err != nil {
+			t.Fatalf("\t%s should write the xlsx : %v", tests.Failed, err)
+		}
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil || len(zr.File) != 5 {
+			t.Fatalf("\t%s should write the workbook : %v", tests.Failed, err)
+		}
+		f, _ := zr.File[4].Open()
+		sheet, _ := ioutil.ReadAll(f)
+		if !strings.Contains(string(sheet), `<c r="A2" t="inlineStr"><is><t xml:space="preserve">Retail</t></is></c>`) || !strings.Contains(string(sheet), `<c r="C4"><v>4</v></c>`) {
+			t.Fatalf("\t%s should write the cells : %s", tests.Failed, sheet)
+		}
+		t.Logf("\t%s should write the xlsx", tests.Success)
+	}
+}
@@ -36,6 +36,9 @@ func (ruleResult *RuleResult) executePosCase(ctx context.Context, eng *Engine, n
 	case node.Delay:
 		ruleResult.Pause = true
 		err = eng.executeDelay(ctx, n, ruleResult.Response, db, sdb)
+	case node.Schedule:
+		ruleResult.Pause = true
+		err = eng.executeSchedule(ctx, n, ruleResult.Response, db, sdb)
 	case node.Stage:
 		err = nil
 	}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+)
+
+// executeSchedule pauses the flow like the delay node but until the next run of the cron of the schedule item.
+func (eng *Engine) executeSchedule(ctx context.Context, n node.Node, rulesetResponse map[string]interface{}, db *sqlx.DB, sdb *database.SecDB) error {
+	entityFields, err := valueAdd(ctx, db, sdb, n.AccountID, n.ActorID, n.ActualsItemID())
+	if err != nil {
+		return err
+	}
+
+	var scheduleEntityItem entity.ScheduleEntity
+	err = entity.ParseFixedEntity(entityFields, &scheduleEntityItem)
+	if err != nil {
+		return err
+	}
+	actualItemID := n.ActualsMap()[n.ActorID]
+
+	cron, err := util.ParseCron(scheduleEntityItem.Cron)
+	if err != nil {
+		return err
+	}
+	loc := time.UTC
+	if scheduleEntityItem.Timezone != "" {
+		if loc, err = time.LoadLocation(scheduleEntityItem.Timezone); err != nil {
+			return err
+		}
+	}
+	runAt := cron.Next(time.Now().In(loc))
+	if runAt.IsZero() {
+		return fmt.Errorf("schedule %q never runs", scheduleEntityItem.Cron)
+	}
+
+	meta := rulesetResponse // overload the ruleset response if already exist
+	if meta == nil {
+		meta = make(map[string]interface{}, 0)
+	}
+	meta["trigger_entity_id"] = n.Meta.EntityID
+	meta["trigger_item_id"] = n.Meta.ItemID
+	meta["trigger_flow_type"] = n.Meta.FlowType
+	meta["trigger_flow_id"] = n.FlowID
+	meta["trigger_node_id"] = n.ID
+
+	err = eng.Job.AddDelay(n.AccountID, user.UUID_SYSTEM_USER, n.ActorID, actualItemID, meta, runAt, sdb)
+	return err
+}
@@ -141,6 +141,7 @@ func (af ActiveFlow) exitFlowTrigger(ctx context.Context, db *sqlx.DB, sdb *data
 	_, span := trace.StartSpan(ctx, "internal.rule.flow.Trigger.exitFlowTrigger")
 	defer span.End()
 	log.Printf("internal.rule.flow.active_flow  triggering exitflow %+v\n", af)
+	af.Life = af.Life + 1
 	if err := af.disableAF(ctx, db); err != nil {
 		return err
 	}
@@ -149,7 +150,10 @@ func (af ActiveFlow) exitFlowTrigger(ctx context.Context, db *sqlx.DB, sdb *data
 
 //stops re-running the flow for an item if it already active
 func (af ActiveFlow) stopEntryTriggerFlow(ftype, fstate int, itemID string) bool {
-	isRanAlready := af.IsActive && af.ItemID == itemID && fstate == FlowStateOnce && (ftype == FlowTypeEntersSegment || ftype == FlowTypeEventUpdate || ftype == FlowTypeEventCreate || ftype == FlowTypeEventCreateOrUpdate)
+	isRanAlready := af.IsActive && af.ItemID == itemID && fstate == FlowStateOnce && (ftype == FlowTypeEntersSegment || ftype == FlowTypeEventUpdate || ftype == FlowTypeEventCreate || ftype == FlowTypeEventCreateOrUpdate || ftype == FlowTypeScheduled)
+	if ftype == FlowTypeEntersSegment && af.ItemID == itemID { //still in the segment or entered it once already
+		isRanAlready = af.IsActive || (fstate == FlowStateOnce && af.Life > 0)
+	}
 	if isRanAlready {
 		log.Println("------------------------########################################------------------------")
 		log.Println("------------------------########################################------------------------")
@@ -162,8 +166,15 @@ func (af ActiveFlow) stopEntryTriggerFlow(ftype, fstate int, itemID string) bool
 	return isRanAlready
 }
 
-func (af ActiveFlow) stopExitTriggerFlow(ftype int) bool {
-	return ((ftype == FlowTypeLeavesSegment) && (af.Life == 0 && !af.IsActive))
+//stops the exit trigger if the item was not in the segment or if it has left the segment once already
+func (af ActiveFlow) stopExitTriggerFlow(ftype, fstate int) bool {
+	if ftype != FlowTypeEntersSegment && ftype != FlowTypeLeavesSegment {
+		return true
+	}
+	if !af.IsActive {
+		return true
+	}
+	return ftype == FlowTypeLeavesSegment && fstate == FlowStateOnce && af.Life > 0
 }
 
 func (af ActiveFlow) enableAF(ctx context.Context, db *sqlx.DB, accountID, flowID, nodeID, itemID string) error {
@@ -189,6 +200,28 @@ func (af ActiveFlow) enableAF(ctx context.Context, db *sqlx.DB, accountID, flowI
 	return UpdateAF(ctx, db, af)
 }
 
+//joinAF marks the item as in the segment without running the flow. The life counts the runs, so it stays untouched.
+func (af ActiveFlow) joinAF(ctx context.Context, db *sqlx.DB, accountID, flowID, nodeID, itemID string) error {
+	if af.IsActive {
+		return nil
+	}
+	now := time.Now()
+	if af.ItemID != itemID {
+		af.AccountID = accountID
+		af.FlowID = flowID
+		af.ItemID = itemID
+		af.NodeID = nodeID
+		af.IsActive = true
+		af.CreatedAt = now.UTC()
+		af.UpdatedAt = now.UTC().Unix()
+		_, err := CreateAF(ctx, db, af)
+		return err
+	}
+	af.IsActive = true
+	af.UpdatedAt = now.Unix()
+	return UpdateAF(ctx, db, af)
+}
+
 func (af ActiveFlow) disableAF(ctx context.Context, db *sqlx.DB) error {
 	af.IsActive = false
 	af.UpdatedAt = time.Now().Unix()
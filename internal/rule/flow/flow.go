@@ -55,7 +55,7 @@ func List(ctx context.Context, entityIDs []string, fm int, ft int, db *sqlx.DB)
 
 	types := []int{ft}
 	if ft == FlowTypeAll {
-		types = []int{FlowTypeUnknown, FlowTypeEntersSegment, FlowTypeLeavesSegment, FlowTypeEventCreate, FlowTypeEventUpdate, FlowTypeEventCreateOrUpdate}
+		types = []int{FlowTypeUnknown, FlowTypeEntersSegment, FlowTypeLeavesSegment, FlowTypeEventCreate, FlowTypeEventUpdate, FlowTypeEventCreateOrUpdate, FlowTypeScheduled}
 	}
 
 	if ft == FlowTypeEventCreate || ft == FlowTypeEventUpdate {
@@ -74,8 +74,21 @@ func List(ctx context.Context, entityIDs []string, fm int, ft int, db *sqlx.DB)
 	return flows, nil
 }
 
+// Pipelines retrieves the active pipelines of all the accounts.
+func Pipelines(ctx context.Context, db *sqlx.DB) ([]Flow, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.Pipelines")
+	defer span.End()
+
+	flows := []Flow{}
+	const q = `SELECT * FROM flows where mode = $1 AND status = $2`
+	if err := db.SelectContext(ctx, &flows, q, FlowModePipeLine, FlowStatusActive); err != nil {
+		return nil, errors.Wrap(err, "selecting pipelines")
+	}
+	return flows, nil
+}
+
 // Create inserts a new item into the database.
-func Create(ctx context.Context, db *sqlx.DB, nf NewFlow, now time.Time) (Flow, error) {
+func Create(ctx context.Context, db database.Conn, nf NewFlow, now time.Time) (Flow, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.Create")
 	defer span.End()
 
@@ -118,7 +131,7 @@ func Create(ctx context.Context, db *sqlx.DB, nf NewFlow, now time.Time) (Flow,
 	return f, nil
 }
 
-func Update(ctx context.Context, db *sqlx.DB, uf NewFlow, now time.Time) (Flow, error) {
+func Update(ctx context.Context, db database.Conn, uf NewFlow, now time.Time) (Flow, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.Update")
 	defer span.End()
 
@@ -198,7 +211,7 @@ func (f *Flow) Tokens() map[string]interface{} {
 }
 
 // Retrieve gets the specified flow from the database.
-func Retrieve(ctx context.Context, id string, db *sqlx.DB) (Flow, error) {
+func Retrieve(ctx context.Context, id string, db database.Conn) (Flow, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.Retrieve")
 	defer span.End()
 
@@ -219,6 +232,21 @@ func Retrieve(ctx context.Context, id string, db *sqlx.DB) (Flow, error) {
 	return f, nil
 }
 
+// Referring returns the flows of the account whose expression or tokens refer the given term.
+func Referring(ctx context.Context, accountID, term string, db *sqlx.DB) ([]Flow, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.flow.Referring")
+	defer span.End()
+
+	flows := []Flow{}
+	const q = `SELECT * FROM flows where account_id = $1 AND (expression LIKE $2 OR tokenb::text LIKE $2)`
+
+	if err := db.SelectContext(ctx, &flows, q, accountID, "%"+term+"%"); err != nil {
+		return nil, errors.Wrap(err, "selecting flows referring the term")
+	}
+
+	return flows, nil
+}
+
 func SearchByKey(ctx context.Context, accountID, entityID, term string, db *sqlx.DB) ([]Flow, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.flow.SearchByKey")
 	defer span.End()
@@ -268,10 +296,11 @@ func DirtyFlows(ctx context.Context, flows []Flow, dirtyFields map[string]interf
 		return flows
 	}
 	dirtyFlows := make([]Flow, 0)
-	for key := range dirtyFields {
-		for _, flow := range flows {
+	for _, flow := range flows {
+		for key := range dirtyFields {
 			if strings.Contains(flow.Expression, key) {
 				dirtyFlows = append(dirtyFlows, flow)
+				break
 			}
 		}
 	}
@@ -299,23 +328,27 @@ func Trigger(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, itemID strin
 		}
 
 		af := activeFlowMap[f.ID]
+		err = nil
 		n := node.RootNode(f.AccountID, f.ID, f.EntityID, itemID, f.Expression).UpdateMeta(f.EntityID, itemID, f.Type).UpdateVariables(f.EntityID, itemID)
 		if eng.RunExpEvaluator(ctx, db, sdb, n.AccountID, n.Expression, n.VariablesMap()) { //entry
-			if af.stopEntryTriggerFlow(f.Type, f.State, itemID) { //skip trigger if already active or of exit condition
+			if f.Type == FlowTypeLeavesSegment { //remember the item is in the segment to trigger when it leaves
+				err = af.joinAF(ctx, db, n.AccountID, n.FlowID, n.ID, itemID)
+			} else if af.stopEntryTriggerFlow(f.Type, f.State, itemID) { //skip trigger if already active or of exit condition
 				err = ErrFlowActive
 			} else {
 				err = af.entryFlowTrigger(ctx, db, sdb, n, eng)
 			}
+		} else if f.Type == FlowTypeEntersSegment || f.Type == FlowTypeLeavesSegment { //exit
+			if af.stopExitTriggerFlow(f.Type, f.State) { //skip trigger if the item was not in the segment
+				err = ErrFlowInActive
+			} else if f.Type == FlowTypeEntersSegment { //the item can enter the segment again
+				err = af.disableAF(ctx, db)
+			} else {
+				err = af.exitFlowTrigger(ctx, db, sdb, n, eng)
+			}
 		}
-		// else if f.Type == FlowTypeLeavesSegment {
-		// 	if af.stopExitTriggerFlow(f.Type) { //skip trigger if new or inactive or not allowed( i.e ftype != segment).
-		// 		err = ErrFlowInActive
-		// 	} else {
-		// 		err = af.exitFlowTrigger(ctx, db, sdb, n, eng)
-		// 	}
-		// }
 		//concat errors in the loop. nil if no error exists
-		if err != nil && err != ErrFlowActive {
+		if err != nil && err != ErrFlowActive && err != ErrFlowInActive {
 			err = errors.Wrapf(err, "error in entry/exit trigger for flowID %q", f.ID)
 			triggerErrors = append(triggerErrors, err)
 		}
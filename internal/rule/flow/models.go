@@ -15,6 +15,7 @@ const (
 	FlowTypeEventCreate         = 3
 	FlowTypeEventUpdate         = 4
 	FlowTypeEventCreateOrUpdate = 5
+	FlowTypeScheduled           = 6
 )
 
 const (
@@ -74,6 +75,7 @@ type ViewModelFlow struct {
 	Status      int                    `json:"status"`
 	Nodes       []node.ViewModelNode   `json:"nodes"`
 	Tokens      map[string]interface{} `json:"tokens"`
+	Schedule    *Schedule              `json:"schedule,omitempty"`
 }
 
 // NewFlow has information needed to creat new flow
@@ -92,6 +94,8 @@ type NewFlow struct {
 	Condition   int                    `json:"condition"`
 	Nodes       []node.NewNode         `json:"nodes"`
 	Queries     []node.Query           `json:"queries"`
+	Cron        string                 `json:"cron"`
+	Timezone    string                 `json:"timezone"`
 }
 
 // ActiveFlow represents the flow which are currently active
@@ -118,3 +122,16 @@ type ActiveNode struct {
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt int64     `db:"updated_at" json:"updated_at"`
 }
+
+// Schedule represents the cron of the scheduled flow. On every run the flow is triggered
+// for the items of the entity which match the expression of the flow.
+type Schedule struct {
+	FlowID    string     `db:"flow_id" json:"flow_id"`
+	AccountID string     `db:"account_id" json:"account_id"`
+	Cron      string     `db:"cron" json:"cron"`
+	Timezone  string     `db:"timezone" json:"timezone"`
+	NextRunAt time.Time  `db:"next_run_at" json:"next_run_at"`
+	LastRunAt *time.Time `db:"last_run_at" json:"last_run_at"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt int64      `db:"updated_at" json:"updated_at"`
+}
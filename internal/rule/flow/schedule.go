@@ -0,0 +1,147 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrInvalidSchedule occurs when the cron or the timezone of the scheduled flow cannot be parsed
+	ErrInvalidSchedule = errors.New("Schedule is not in its proper form")
+
+	// ErrScheduleClaimed occurs when the due run of the schedule is already picked by another scheduler
+	ErrScheduleClaimed = errors.New("Schedule run is already claimed")
+)
+
+// NextRun returns the next time the cron runs after now in the given timezone.
+func NextRun(cron, timezone string, now time.Time) (time.Time, error) {
+	c, err := util.ParseCron(cron)
+	if err != nil {
+		return time.Time{}, ErrInvalidSchedule
+	}
+	loc := time.UTC
+	if timezone != "" {
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return time.Time{}, ErrInvalidSchedule
+		}
+	}
+	next := c.Next(now.In(loc))
+	if next.IsZero() {
+		return time.Time{}, ErrInvalidSchedule
+	}
+	return next.UTC(), nil
+}
+
+// SaveSchedule creates or replaces the cron of the scheduled flow.
+func SaveSchedule(ctx context.Context, db *sqlx.DB, accountID, flowID, cron, timezone string, now time.Time) (Schedule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.SaveSchedule")
+	defer span.End()
+
+	next, err := NextRun(cron, timezone, now)
+	if err != nil {
+		return Schedule{}, err
+	}
+
+	s := Schedule{
+		FlowID:    flowID,
+		AccountID: accountID,
+		Cron:      cron,
+		Timezone:  timezone,
+		NextRunAt: next,
+		CreatedAt: now.UTC(),
+		UpdatedAt: now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO flow_schedules
+		(flow_id, account_id, cron, timezone, next_run_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (flow_id) DO UPDATE SET cron = $3, timezone = $4, next_run_at = $5, updated_at = $7`
+	_, err = db.ExecContext(
+		ctx, q,
+		s.FlowID, s.AccountID, s.Cron, s.Timezone, s.NextRunAt,
+		s.CreatedAt, s.UpdatedAt,
+	)
+	if err != nil {
+		return Schedule{}, errors.Wrap(err, "upserting flow schedule")
+	}
+
+	return s, nil
+}
+
+// RetrieveSchedule gets the schedule of the flow.
+func RetrieveSchedule(ctx context.Context, db *sqlx.DB, flowID string) (Schedule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.RetrieveSchedule")
+	defer span.End()
+
+	var s Schedule
+	const q = `SELECT * FROM flow_schedules WHERE flow_id = $1`
+	if err := db.GetContext(ctx, &s, q, flowID); err != nil {
+		if err == sql.ErrNoRows {
+			return Schedule{}, ErrNotFound
+		}
+		return Schedule{}, errors.Wrapf(err, "selecting flow schedule %q", flowID)
+	}
+	return s, nil
+}
+
+// DueSchedules returns the schedules of the active flows whose next run is due.
+func DueSchedules(ctx context.Context, db *sqlx.DB, now time.Time) ([]Schedule, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.DueSchedules")
+	defer span.End()
+
+	schedules := []Schedule{}
+	const q = `SELECT s.* FROM flow_schedules s JOIN flows f ON f.flow_id = s.flow_id
+		WHERE s.next_run_at <= $1 AND f.status = $2 ORDER BY s.next_run_at LIMIT 100`
+	if err := db.SelectContext(ctx, &schedules, q, now.UTC(), FlowStatusActive); err != nil {
+		return nil, errors.Wrap(err, "selecting due flow schedules")
+	}
+	return schedules, nil
+}
+
+// MarkRun records the run of the schedule and moves the next run after now. The next run is moved
+// before the flow is triggered so the missed runs are skipped and not replayed.
+func (s *Schedule) MarkRun(ctx context.Context, db *sqlx.DB, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.Schedule.MarkRun")
+	defer span.End()
+
+	next, err := NextRun(s.Cron, s.Timezone, now)
+	if err != nil {
+		return err
+	}
+	lastRun := now.UTC()
+
+	const q = `UPDATE flow_schedules SET next_run_at = $3, last_run_at = $4, updated_at = $5
+		WHERE flow_id = $1 AND next_run_at = $2`
+	res, err := db.ExecContext(ctx, q, s.FlowID, s.NextRunAt, next, lastRun, now.UTC().Unix())
+	if err != nil {
+		return errors.Wrapf(err, "updating flow schedule %q", s.FlowID)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrScheduleClaimed
+	}
+
+	s.NextRunAt = next
+	s.LastRunAt = &lastRun
+	return nil
+}
+
+// SegmentFlows returns the active workflows triggered when the items enter or leave the segment.
+// The date relative expressions of these flows change without the items being changed, so they are
+// re-evaluated periodically.
+func SegmentFlows(ctx context.Context, db *sqlx.DB) ([]Flow, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.rule.flow.SegmentFlows")
+	defer span.End()
+
+	flows := []Flow{}
+	const q = `SELECT * FROM flows WHERE mode = $1 AND type IN ($2, $3) AND status = $4`
+	if err := db.SelectContext(ctx, &flows, q, FlowModeWorkFlow, FlowTypeEntersSegment, FlowTypeLeavesSegment, FlowStatusActive); err != nil {
+		return nil, errors.Wrap(err, "selecting segment flows")
+	}
+	return flows, nil
+}
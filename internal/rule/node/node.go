@@ -11,6 +11,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -62,6 +63,21 @@ func List(ctx context.Context, flowID string, db *sqlx.DB) ([]Node, error) {
 	return nodes, nil
 }
 
+// Referring returns the nodes of the account whose expression, tokens or actuals refer the given term.
+func Referring(ctx context.Context, accountID, term string, db *sqlx.DB) ([]Node, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.node.Referring")
+	defer span.End()
+
+	nodes := []Node{}
+	const q = `SELECT * FROM nodes where account_id = $1 AND (expression LIKE $2 OR tokenb::text LIKE $2 OR actuals::text LIKE $2)`
+
+	if err := db.SelectContext(ctx, &nodes, q, accountID, "%"+term+"%"); err != nil {
+		return nil, errors.Wrap(err, "selecting nodes referring the term")
+	}
+
+	return nodes, nil
+}
+
 // Stages retrieves a list of existing stages for the flow.
 func Stages(ctx context.Context, accountID string, flowIDs []string, term string, db *sqlx.DB) ([]Node, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.node.Stages")
@@ -117,7 +133,7 @@ func NodeActorsList(ctx context.Context, accountID, flowID string, db *sqlx.DB)
 }
 
 // Create inserts a new node into the database.
-func Create(ctx context.Context, db *sqlx.DB, nn NewNode, now time.Time) (Node, error) {
+func Create(ctx context.Context, db database.Conn, nn NewNode, now time.Time) (Node, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.node.Create")
 	defer span.End()
 
@@ -167,7 +183,7 @@ func Create(ctx context.Context, db *sqlx.DB, nn NewNode, now time.Time) (Node,
 }
 
 // Update replaces just the name all other fields are not updatable currenlty.
-func Update(ctx context.Context, db *sqlx.DB, accountID, flowID, nodeID, name, expression string, tokens map[string]interface{}, now time.Time) error {
+func Update(ctx context.Context, db database.Conn, accountID, flowID, nodeID, name, expression string, tokens map[string]interface{}, now time.Time) error {
 	ctx, span := trace.StartSpan(ctx, "internal.node.Update")
 	defer span.End()
 	updatedAt := now.Unix()
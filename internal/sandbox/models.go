@@ -0,0 +1,21 @@
+package sandbox
+
+import (
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+)
+
+// NewSandbox contains the information needed to create the sandbox of an account.
+type NewSandbox struct {
+	Name    string `json:"name" validate:"required"`
+	Domain  string `json:"domain"`
+	Samples bool   `json:"samples"` // copy a subset of the items of each entity along with the configuration
+}
+
+// TeamChanges is the list of config changes made in the team of the sandbox which are not available in the parent account.
+// The ids of the changes are the ids of the parent account, except for the objects created in the sandbox.
+type TeamChanges struct {
+	TeamID       string        `json:"team_id"` // team of the sandbox
+	ParentTeamID string        `json:"parent_team_id"`
+	Name         string        `json:"name"`
+	Changes      []pack.Change `json:"changes"`
+}
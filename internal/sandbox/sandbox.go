@@ -0,0 +1,429 @@
+package sandbox
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap"
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/team"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// version is recorded as the package version of the teams cloned from the parent account.
+const version = "sandbox"
+
+var (
+	// ErrNotSandbox is used when the changes are requested for an account which is not a sandbox.
+	ErrNotSandbox = errors.New("Account is not a sandbox")
+	// ErrNestedSandbox is used when the sandbox is created from another sandbox.
+	ErrNestedSandbox = errors.New("Sandbox cannot be created from a sandbox")
+	// ErrNameTaken is used when another account already uses the name of the sandbox.
+	ErrNameTaken = errors.New("Account name is not available")
+	// ErrUnknownUser is used when the user promoting the changes does not belong to the parent account.
+	ErrUnknownUser = errors.New("User not found in the parent account")
+)
+
+// List retrieves the sandboxes of the account.
+func List(ctx context.Context, db *sqlx.DB, accountID string) ([]account.Account, error) {
+	return account.Children(ctx, db, accountID)
+}
+
+// Create makes the sandbox as the child account of the parent and deep copies the teams, entities, relationships,
+// flows, nodes, layouts, dashboards and the users of the parent into it. All the ids get new values in the sandbox
+// and the references to them, including the ones inside the fields and the node expressions, are remapped.
+// When samples is set, a subset of the items of each entity is copied too. The owners are always copied.
+func Create(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, parentAccountID, userID string, ns NewSandbox, fbSDKPath string, now time.Time) (account.Account, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sandbox.Create")
+	defer span.End()
+
+	parent, err := account.Retrieve(ctx, db, parentAccountID)
+	if err != nil {
+		return account.Account{}, err
+	}
+	if parent.ParentAccountID != nil {
+		return account.Account{}, ErrNestedSandbox
+	}
+	if _, err := account.CheckAvailability(ctx, ns.Name, db); err == nil {
+		return account.Account{}, ErrNameTaken
+	} else if err != account.ErrNotFound {
+		return account.Account{}, err
+	}
+
+	na := account.NewAccount{
+		ID:              uuid.New().String(),
+		ParentAccountID: &parent.ID,
+		Name:            ns.Name,
+		Domain:          ns.Domain,
+		CustomerPlan:    parent.CustomerPlan,
+		CustomerStatus:  parent.CustomerStatus,
+		TrailStart:      parent.TrailStart,
+		TrailEnd:        parent.TrailEnd,
+		UseDB:           parent.UseDB,
+	}
+	child, err := account.Create(ctx, db, na, now)
+	if err != nil {
+		return account.Account{}, err
+	}
+
+	if err := clone(ctx, db, sdb, *parent, child, userID, ns.Samples, fbSDKPath, now); err != nil {
+		// everything created in the sandbox cascades with the account
+		if err := account.Delete(ctx, db, child.ID); err != nil {
+			return account.Account{}, err
+		}
+		return account.Account{}, errors.Wrapf(err, "cloning account %s", parent.ID)
+	}
+	return child, nil
+}
+
+// Changes returns the config changes made in the teams of the sandbox which are not available in the parent account.
+func Changes(ctx context.Context, db *sqlx.DB, sandboxAccountID string) ([]TeamChanges, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sandbox.Changes")
+	defer span.End()
+
+	plans, err := plans(ctx, db, sandboxAccountID)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]TeamChanges, 0, len(plans))
+	for _, pl := range plans {
+		changes = append(changes, pl.changes)
+	}
+	return changes, nil
+}
+
+// Promote applies the selected changes of the sandbox on the parent account. The changes the selected ones depend on
+// (the entity of the new field, the flow of the new node) are promoted along with them. Like the package upgrade,
+// the removals are never applied.
+func Promote(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, sandboxAccountID, userID string, selected []TeamChanges, fbSDKPath string, now time.Time) ([]TeamChanges, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sandbox.Promote")
+	defer span.End()
+
+	plans, err := plans(ctx, db, sandboxAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	picks := make(map[string][]pack.Change, len(selected))
+	for _, tc := range selected {
+		picks[tc.TeamID] = append(picks[tc.TeamID], tc.Changes...)
+	}
+
+	promoted := make([]TeamChanges, 0)
+	for _, pl := range plans {
+		changes := Select(pl.changes.Changes, picks[pl.changes.TeamID])
+		if len(changes) == 0 {
+			continue
+		}
+
+		parentUserID, ok := pl.ids[userID]
+		if !ok {
+			return promoted, ErrUnknownUser
+		}
+		next := restrict(pl.current, pl.next, changes)
+
+		// the objects created in the sandbox are matched with the promoted ones from now on
+		idmap := pl.inst.IDMap()
+		for sandboxID, parentID := range pl.ids {
+			if _, ok := idmap[parentID]; !ok {
+				idmap[parentID] = sandboxID
+			}
+		}
+
+		var created []item.Item
+		err := database.WithTx(ctx, db, func(tx *sqlx.Tx) error {
+			var err error
+			created, err = pack.Apply(ctx, tx, sdb, pl.parentAccountID, pl.changes.ParentTeamID, parentUserID, pl.current, next, changes, now)
+			if err != nil {
+				return errors.Wrapf(err, "promoting the changes of team %s", pl.changes.Name)
+			}
+			return pack.UpdateInstallation(ctx, tx, pl.inst.ID, pl.inst.Version, idmap, now)
+		})
+		if err != nil {
+			return promoted, err
+		}
+		pack.Settle(ctx, db, sdb, pl.parentAccountID, parentUserID, next, created, fbSDKPath)
+
+		tc := pl.changes
+		tc.Changes = changes
+		promoted = append(promoted, tc)
+	}
+	return promoted, nil
+}
+
+// Select returns the changes picked from all the changes along with the additions they depend on.
+// The removals are dropped as they are never applied.
+func Select(all, picked []pack.Change) []pack.Change {
+	want := make(map[pack.Change]bool, len(picked))
+	for _, c := range picked {
+		if c.Op != pack.OpRemove {
+			want[c] = true
+		}
+	}
+	added := make(map[string]pack.Change)
+	for _, c := range all {
+		if c.Op == pack.OpAdd && (c.Kind == pack.KindEntity || c.Kind == pack.KindFlow) {
+			added[c.ID] = c
+		}
+	}
+	for c := range want {
+		if dep, ok := added[c.Parent]; ok && c.Parent != "" {
+			want[dep] = true
+		}
+		if c.Kind == pack.KindAssociation {
+			if dep, ok := added[c.ID]; ok {
+				want[dep] = true
+			}
+		}
+	}
+
+	// keep the order of the diff as the entities must be created before their fields
+	changes := make([]pack.Change, 0, len(want))
+	for _, c := range all {
+		if want[c] {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// plan is the sandbox team and its parent team in the same id space.
+type plan struct {
+	inst            pack.Installation
+	parentAccountID string
+	ids             map[string]string // sandbox ids -> parent ids
+	current         pack.Package
+	next            pack.Package
+	changes         TeamChanges
+}
+
+func plans(ctx context.Context, db *sqlx.DB, sandboxAccountID string) ([]plan, error) {
+	sb, err := account.Retrieve(ctx, db, sandboxAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if sb.ParentAccountID == nil {
+		return nil, ErrNotSandbox
+	}
+	namespace, err := uuid.Parse(sb.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := team.List(ctx, sb.ID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]plan, 0, len(teams))
+	for _, t := range teams {
+		inst, err := pack.RetrieveInstallation(ctx, db, sb.ID, t.ID)
+		if err == pack.ErrNotInstalled {
+			// the team is created in the sandbox
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		ids := make(map[string]string)
+		for parentID, sandboxID := range inst.IDMap() {
+			ids[sandboxID] = parentID
+		}
+
+		p, err := pack.Export(ctx, db, sb.ID, t.ID, inst.Version, false)
+		if err != nil {
+			return nil, err
+		}
+		// the objects created in the sandbox get the same parent id on every call, so the
+		// changes listed earlier can be promoted later.
+		for _, c := range pack.Diff(pack.Package{}, p) {
+			for _, id := range []string{c.ID, c.Parent} {
+				if _, ok := ids[id]; !ok && id != "" {
+					if _, err := uuid.Parse(id); err == nil {
+						ids[id] = uuid.NewSHA1(namespace, []byte(id)).String()
+					}
+				}
+			}
+		}
+		next, err := pack.Remap(p, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		parentTeamID := ids[t.ID]
+		current, err := pack.Export(ctx, db, *sb.ParentAccountID, parentTeamID, inst.Version, false)
+		if err == pack.ErrTeamNotFound {
+			// the team is removed from the parent account
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		plans = append(plans, plan{
+			inst:            inst,
+			parentAccountID: *sb.ParentAccountID,
+			ids:             ids,
+			current:         current,
+			next:            next,
+			changes:         TeamChanges{TeamID: t.ID, ParentTeamID: parentTeamID, Name: t.Name, Changes: pack.Diff(current, next)},
+		})
+	}
+	return plans, nil
+}
+
+// restrict keeps the fields of the next package which are not selected in their current form, because the
+// fields of the entity are saved together.
+func restrict(current, next pack.Package, changes []pack.Change) pack.Package {
+	selected := make(map[string]bool)
+	for _, c := range changes {
+		if c.Kind == pack.KindField {
+			selected[c.Parent+c.ID] = true
+		}
+	}
+	currentFields := make(map[string]entity.Field)
+	for _, e := range current.Entities {
+		for _, f := range e.Fields {
+			currentFields[e.ID+f.Key] = f
+		}
+	}
+
+	entities := make([]pack.Entity, 0, len(next.Entities))
+	for _, e := range next.Entities {
+		fields := make([]entity.Field, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			if selected[e.ID+f.Key] {
+				fields = append(fields, f)
+			} else if cf, ok := currentFields[e.ID+f.Key]; ok {
+				fields = append(fields, cf)
+			}
+		}
+		e.Fields = fields
+		entities = append(entities, e)
+	}
+	next.Entities = entities
+	return next
+}
+
+// clone copies the users, the teams and the items of the parent into the sandbox.
+func clone(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, parent, child account.Account, userID string, samples bool, fbSDKPath string, now time.Time) error {
+	// the base team shares the id with the account
+	ids := map[string]string{parent.ID: child.ID}
+
+	users, err := user.AccountUsers(ctx, parent.ID, db)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		ids[u.ID] = uuid.New().String()
+		if u.MemberID != user.UUID_SYSTEM_USER {
+			ids[u.MemberID] = uuid.New().String()
+		}
+	}
+
+	teams, err := team.List(ctx, parent.ID, db)
+	if err != nil {
+		return err
+	}
+	// the teams referred by the others are created first
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].CreatedAt.Before(teams[j].CreatedAt)
+	})
+
+	pkgs := make([]pack.Package, 0, len(teams))
+	sampled := pack.Package{Name: "samples"}
+	for _, t := range teams {
+		if t.ID != parent.ID {
+			ids[t.ID] = uuid.New().String()
+		}
+		p, err := pack.Export(ctx, db, parent.ID, t.ID, version, false)
+		if err != nil {
+			return err
+		}
+		pkgs = append(pkgs, p)
+
+		exported := make(map[string]bool, len(p.Items))
+		for _, i := range p.Items {
+			exported[i.ID] = true
+		}
+		for _, e := range p.Entities {
+			if !samples && e.Category != entity.CategoryUsers {
+				continue
+			}
+			items, err := item.EntityItems(ctx, parent.ID, e.ID, db)
+			if err != nil {
+				return err
+			}
+			for _, i := range items {
+				if !exported[i.ID] {
+					sampled.Items = append(sampled.Items, pack.Item{ID: i.ID, EntityID: i.EntityID, Name: i.Name, Type: i.Type, State: i.State, Fields: i.Fields(), Meta: i.Meta()})
+				}
+			}
+		}
+	}
+
+	// the first pass gives the new ids to everything, so the references across the teams are remapped in the second
+	for _, p := range append(pkgs, sampled) {
+		if _, err := pack.Remap(p, ids); err != nil {
+			return err
+		}
+	}
+
+	for _, u := range users {
+		memberID, ok := ids[u.MemberID]
+		if !ok {
+			memberID = u.MemberID
+		}
+		if _, err := user.Copy(ctx, db, u, child.ID, ids[u.ID], memberID, now); err != nil {
+			return err
+		}
+	}
+	sandboxUserID, ok := ids[userID]
+	if !ok {
+		sandboxUserID = userID
+	}
+
+	// the items are streamed once the whole account is cloned, so a failed clone leaves nothing behind
+	installed := pack.Package{}
+	created := make([]item.Item, 0)
+	for i, t := range teams {
+		rp, err := pack.Remap(pkgs[i], ids)
+		if err != nil {
+			return err
+		}
+		description := ""
+		if t.Description != nil {
+			description = *t.Description
+		}
+		if err := bootstrap.BootstrapTeam(ctx, db, child.ID, ids[t.ID], t.LookUp, t.Name, description); err != nil {
+			return errors.Wrapf(err, "creating team %s", t.Name)
+		}
+		_, items, err := pack.Install(ctx, db, sdb, child.ID, ids[t.ID], sandboxUserID, rp, ids, now)
+		if err != nil {
+			return err
+		}
+		installed.Entities = append(installed.Entities, rp.Entities...)
+		created = append(created, items...)
+	}
+
+	rs, err := pack.Remap(sampled, ids)
+	if err != nil {
+		return err
+	}
+	items, err := pack.Apply(ctx, db, sdb, child.ID, child.ID, sandboxUserID, pack.Package{}, rs, pack.Diff(pack.Package{}, rs), now)
+	if err != nil {
+		return err
+	}
+	created = append(created, items...)
+
+	pack.Settle(ctx, db, sdb, child.ID, sandboxUserID, installed, created, fbSDKPath)
+	return nil
+}
@@ -0,0 +1,46 @@
+package sandbox_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/bootstrap/pack"
+	"gitlab.com/vjsideprojects/relay/internal/sandbox"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestSelect(t *testing.T) {
+	t.Log("Given the need to promote the selected changes of the sandbox")
+	{
+		newEntity := pack.Change{Kind: pack.KindEntity, Op: pack.OpAdd, ID: "e1", Name: "Tasks"}
+		newField := pack.Change{Kind: pack.KindField, Op: pack.OpAdd, ID: "f1", Name: "Due", Parent: "e1"}
+		oldField := pack.Change{Kind: pack.KindField, Op: pack.OpUpdate, ID: "f2", Name: "Amount", Parent: "e2"}
+		newFlow := pack.Change{Kind: pack.KindFlow, Op: pack.OpAdd, ID: "w1", Name: "Follow up"}
+		newNode := pack.Change{Kind: pack.KindNode, Op: pack.OpAdd, ID: "n1", Name: "Email", Parent: "w1"}
+		removed := pack.Change{Kind: pack.KindFlow, Op: pack.OpRemove, ID: "w2", Name: "Old"}
+		all := []pack.Change{newEntity, newField, oldField, newFlow, newNode, removed}
+
+		t.Log("\twhen only the field of the new entity and the node of the new flow are selected")
+		{
+			changes := sandbox.Select(all, []pack.Change{newNode, newField})
+			want := []pack.Change{newEntity, newField, newFlow, newNode}
+			if len(changes) != len(want) {
+				t.Fatalf("\t%s should include the entity and the flow they depend on. got %+v", tests.Failed, changes)
+			}
+			for i := range want {
+				if changes[i] != want[i] {
+					t.Fatalf("\t%s should keep the order of the diff. got %+v", tests.Failed, changes)
+				}
+			}
+			t.Logf("\t%s should include the entity and the flow they depend on", tests.Success)
+		}
+
+		t.Log("\twhen the removal is selected")
+		{
+			changes := sandbox.Select(all, []pack.Change{removed, oldField})
+			if len(changes) != 1 || changes[0] != oldField {
+				t.Fatalf("\t%s should drop the removal. got %+v", tests.Failed, changes)
+			}
+			t.Logf("\t%s should drop the removal", tests.Success)
+		}
+	}
+}
@@ -466,4 +466,516 @@ var migrations = []darwin.Migration{
 		ON charts(dashboard_id);
 		`,
 	},
+	{
+		Version:     2,
+		Description: "Add alerts and alert rules",
+		Script: `
+		CREATE TABLE alerts (
+			alert_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			item_id      		    UUID,
+			rule_id      		    UUID,
+			incident_id      		UUID,
+			fingerprint             TEXT NOT NULL,
+			source                  TEXT,
+			status                  TEXT,
+			name                    TEXT,
+			count                   INTEGER DEFAULT 1,
+			labelsb          		JSONB,
+			first_seen              TIMESTAMP NOT NULL,
+			last_seen               TIMESTAMP NOT NULL,
+			resolved_at             TIMESTAMP,
+			PRIMARY KEY (alert_id)
+		);
+		CREATE INDEX idx_alerts_account_id
+		ON alerts(account_id);
+		CREATE INDEX idx_alerts_rule_id
+		ON alerts(rule_id);
+		CREATE UNIQUE INDEX idx_alerts_open_fingerprint
+		ON alerts(account_id, fingerprint) WHERE status = 'firing';
+
+		CREATE TABLE alert_rules (
+			rule_id    			    UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			incident_entity_id      UUID REFERENCES entities ON DELETE CASCADE,
+			name                    TEXT,
+			expression              TEXT,
+			window_mins             INTEGER DEFAULT 60,
+			position    		    INTEGER DEFAULT 0,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (rule_id)
+		);
+		CREATE INDEX idx_alert_rules_account_id
+		ON alert_rules(account_id);
+		CREATE INDEX idx_alert_rules_entity_id
+		ON alert_rules(entity_id);
+		`,
+	},
+	{
+		Version:     3,
+		Description: "Add sla calendars, policies and timers",
+		Script: `
+		CREATE TABLE sla_calendars (
+			calendar_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			name                    TEXT,
+			timezone                TEXT,
+			hoursb          		JSONB,
+			holidays				TEXT[],
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (calendar_id)
+		);
+		CREATE INDEX idx_sla_calendars_account_id
+		ON sla_calendars(account_id);
+
+		CREATE TABLE sla_policies (
+			policy_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			calendar_id      		UUID REFERENCES sla_calendars ON DELETE SET NULL,
+			name                    TEXT,
+			expression              TEXT,
+			position    		    INTEGER DEFAULT 0,
+			first_response_mins     INTEGER DEFAULT 0,
+			resolution_mins         INTEGER DEFAULT 0,
+			near_breach_pct         INTEGER DEFAULT 80,
+			pause_statuses			TEXT[],
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (policy_id)
+		);
+		CREATE INDEX idx_sla_policies_entity_id
+		ON sla_policies(account_id, entity_id);
+
+		CREATE TABLE sla_timers (
+			timer_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			item_id      		    UUID REFERENCES items ON DELETE CASCADE,
+			policy_id      		    UUID REFERENCES sla_policies ON DELETE CASCADE,
+			metric                  TEXT,
+			state                   TEXT,
+			target_secs             BIGINT DEFAULT 0,
+			elapsed_secs            BIGINT DEFAULT 0,
+			started_at              TIMESTAMP NOT NULL,
+			resumed_at              TIMESTAMP,
+			near_at                 TIMESTAMP,
+			due_at                  TIMESTAMP,
+			warned                  BOOLEAN DEFAULT FALSE,
+			breached_at             TIMESTAMP,
+			met_at                  TIMESTAMP,
+			PRIMARY KEY (timer_id)
+		);
+		CREATE INDEX idx_sla_timers_item_id
+		ON sla_timers(account_id, item_id);
+		CREATE INDEX idx_sla_timers_state_due_at
+		ON sla_timers(state, due_at);
+		CREATE INDEX idx_sla_timers_entity_id
+		ON sla_timers(account_id, entity_id, started_at);
+		`,
+	},
+	{
+		Version:     4,
+		Description: "Add installed app packages",
+		Script: `
+		CREATE TABLE packages (
+			package_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			team_id      		    UUID REFERENCES teams ON DELETE CASCADE,
+			name                    TEXT,
+			version                 TEXT,
+			idmapb          		JSONB,
+			installed_at    	    TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (package_id),
+			UNIQUE (account_id, team_id)
+		);
+		CREATE INDEX idx_packages_account_id
+		ON packages(account_id);
+		`,
+	},
+	{
+		Version:     5,
+		Description: "Add field migrations of the entities",
+		Script: `
+		CREATE TABLE field_migrations (
+			migration_id    		UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			user_id      		    UUID,
+			changesb          		JSONB,
+			status                  INTEGER DEFAULT 0,
+			total                   INTEGER DEFAULT 0,
+			done                    INTEGER DEFAULT 0,
+			failed                  INTEGER DEFAULT 0,
+			error                   TEXT,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (migration_id)
+		);
+		CREATE INDEX idx_field_migrations_entity_id
+		ON field_migrations(account_id, entity_id);
+		`,
+	},
+	{
+		Version:     6,
+		Description: "Add schedules of the flows",
+		Script: `
+		CREATE TABLE flow_schedules (
+			flow_id    			    UUID REFERENCES flows ON DELETE CASCADE,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			cron                    TEXT,
+			timezone                TEXT,
+			next_run_at             TIMESTAMP NOT NULL,
+			last_run_at             TIMESTAMP,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (flow_id)
+		);
+		CREATE INDEX idx_flow_schedules_next_run_at
+		ON flow_schedules(next_run_at);
+		`,
+	},
+	{
+		Version:     7,
+		Description: "Add the API keys to the tokens",
+		Script: `
+		ALTER TABLE tokens ADD COLUMN token_id UUID NOT NULL DEFAULT md5(random()::text)::uuid;
+		ALTER TABLE tokens ADD COLUMN name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE tokens ADD COLUMN prefix TEXT NOT NULL DEFAULT '';
+		ALTER TABLE tokens ADD COLUMN created_by UUID;
+		ALTER TABLE tokens ADD COLUMN last_used_at TIMESTAMP;
+		CREATE UNIQUE INDEX idx_tokens_token_id
+		ON tokens(token_id);
+		`,
+	},
+	{
+		Version:     8,
+		Description: "Add identity providers and two-factor",
+		Script: `
+		CREATE TABLE sso_providers (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			type                    TEXT,
+			issuer                  TEXT,
+			client_id               TEXT,
+			client_secret           TEXT,
+			metadata                TEXT,
+			groups_claim            TEXT,
+			role_mappingsb          JSONB,
+			default_role            TEXT,
+			enforced                BOOLEAN DEFAULT FALSE,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (account_id)
+		);
+
+		CREATE TABLE user_mfa (
+			email                   TEXT,
+			secret                  TEXT,
+			enabled                 BOOLEAN DEFAULT FALSE,
+			recovery_codes          TEXT[],
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (email)
+		);
+		`,
+	},
+	{
+		Version:     9,
+		Description: "Add the provisioning config",
+		Script: `
+		CREATE TABLE scim_configs (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			deprovision_policy      TEXT,
+			reassign_to             UUID,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (account_id)
+		);
+		`,
+	},
+	{
+		Version:     10,
+		Description: "Add the saved views of the entities",
+		Script: `
+		CREATE TABLE views (
+			view_id    			    UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			team_id      		    UUID,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			user_id      		    UUID,
+			name                    TEXT,
+			expression              TEXT,
+			columns                 TEXT[],
+			sort_by                 TEXT,
+			direction               TEXT,
+			group_by                TEXT,
+			style                   TEXT,
+			flow_id                 UUID,
+			scope                   TEXT,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (view_id)
+		);
+		CREATE INDEX idx_views_entity_id
+		ON views(account_id, entity_id);
+		`,
+	},
+	{
+		Version:     11,
+		Description: "Add the notification routing rules and the delivery queue",
+		Script: `
+		CREATE TABLE notification_rules (
+			rule_id    			    UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			user_id      		    UUID,
+			entity_id      		    UUID,
+			channels                TEXT[],
+			events                  TEXT[],
+			fields                  TEXT[],
+			digest                  TEXT,
+			slack_webhook           TEXT,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (rule_id)
+		);
+		CREATE INDEX idx_notification_rules_user_id
+		ON notification_rules(account_id, user_id);
+
+		CREATE TABLE notification_queue (
+			delivery_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			user_id      		    UUID,
+			name                    TEXT,
+			email                   TEXT,
+			channel                 TEXT,
+			digest                  TEXT,
+			slack_webhook           TEXT,
+			type                    INTEGER,
+			subject                 TEXT,
+			body                    TEXT,
+			link                    TEXT,
+			sender_name             TEXT,
+			sender_avatar           TEXT,
+			status                  INTEGER,
+			deliver_at    	        TIMESTAMP,
+			created_at    	        TIMESTAMP,
+			sent_at    	            TIMESTAMP,
+			PRIMARY KEY (delivery_id)
+		);
+		CREATE INDEX idx_notification_queue_due
+		ON notification_queue(status, deliver_at);
+		`,
+	},
+	{
+		Version:     12,
+		Description: "Add the in-app notification inbox of the users",
+		Script: `
+		CREATE TABLE notification_inbox (
+			notice_id    			UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			user_id      		    UUID,
+			notification_id         UUID,
+			team_id      		    UUID,
+			entity_id      		    UUID,
+			item_id      		    UUID,
+			type                    INTEGER,
+			subject                 TEXT,
+			body                    TEXT,
+			sender_name             TEXT,
+			sender_avatar           TEXT,
+			read_at    	            TIMESTAMP,
+			archived_at    	        TIMESTAMP,
+			created_at    	        TIMESTAMP,
+			PRIMARY KEY (notice_id)
+		);
+		CREATE INDEX idx_notification_inbox_user_id
+		ON notification_inbox(account_id, user_id, created_at);
+		CREATE INDEX idx_notification_inbox_item_id
+		ON notification_inbox(account_id, user_id, entity_id, item_id);
+		`,
+	},
+	{
+		Version:     13,
+		Description: "Add the links of the synced calendar events to the meetings",
+		Script: `
+		CREATE TABLE calendar_links (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			calendar_id      		TEXT,
+			event_id      		    TEXT,
+			uid      		        TEXT,
+			entity_id      		    UUID,
+			item_id      		    UUID,
+			etag                    TEXT,
+			item_updated_at         BIGINT,
+			synced_at    	        TIMESTAMP,
+			PRIMARY KEY (account_id, calendar_id, event_id)
+		);
+		CREATE INDEX idx_calendar_links_item_id
+		ON calendar_links(account_id, item_id);
+		`,
+	},
+	{
+		Version:     14,
+		Description: "Add the slack workspaces of the accounts and the channels of the entities",
+		Script: `
+		CREATE TABLE slack_workspaces (
+			team_id      		    TEXT,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			bot_token      		    TEXT,
+			bot_user_id      		TEXT,
+			installed_by      		TEXT,
+			created_at    	        TIMESTAMP,
+			PRIMARY KEY (team_id)
+		);
+		CREATE INDEX idx_slack_workspaces_account_id
+		ON slack_workspaces(account_id);
+		CREATE TABLE slack_channels (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID,
+			channel_id      		TEXT,
+			created_at    	        TIMESTAMP,
+			PRIMARY KEY (account_id, entity_id)
+		);
+		`,
+	},
+	{
+		Version:     15,
+		Description: "Add the week start and the fiscal year start of the accounts",
+		Script: `
+		ALTER TABLE accounts ADD COLUMN week_start INTEGER DEFAULT 0;
+		ALTER TABLE accounts ADD COLUMN fiscal_start INTEGER DEFAULT 1;
+		`,
+	},
+	{
+		Version:     16,
+		Description: "Add the daily metrics of the entities kept by the worker",
+		Script: `
+		CREATE TABLE metrics (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID,
+			field_key      		    TEXT,
+			value      		        TEXT,
+			day      		        DATE,
+			count                   BIGINT DEFAULT 0,
+			PRIMARY KEY (account_id, entity_id, field_key, value, day)
+		);
+		CREATE TABLE metric_builds (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID,
+			zone      		        TEXT,
+			ready                   BOOLEAN DEFAULT FALSE,
+			items                   BIGINT DEFAULT 0,
+			drift                   BIGINT DEFAULT 0,
+			created_at    	        TIMESTAMP,
+			reconciled_at    	    TIMESTAMP,
+			PRIMARY KEY (account_id, entity_id)
+		);
+		`,
+	},
+	{
+		Version:     17,
+		Description: "Add the receipts of the events to skip the events sent again",
+		Script: `
+		CREATE TABLE event_receipts (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			event_id      		    TEXT,
+			received_at    	        TIMESTAMP,
+			PRIMARY KEY (account_id, event_id)
+		);
+		CREATE INDEX idx_event_receipts_received_at ON event_receipts (received_at);
+		`,
+	},
+	{
+		Version:     18,
+		Description: "Add the health models of the entities and the history of the scores of their items",
+		Script: `
+		CREATE TABLE health_models (
+			model_id      		    UUID,
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			entity_id      		    UUID REFERENCES entities ON DELETE CASCADE,
+			name      		        TEXT,
+			componentsb      		JSONB,
+			healthy_at              INTEGER DEFAULT 70,
+			critical_below          INTEGER DEFAULT 40,
+			score_key      		    TEXT,
+			bucket_key      		TEXT,
+			created_at    	        TIMESTAMP,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (model_id)
+		);
+		CREATE TABLE health_scores (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			model_id      		    UUID REFERENCES health_models ON DELETE CASCADE,
+			item_id      		    UUID,
+			score                   INTEGER,
+			bucket      		    TEXT,
+			partsb      		    JSONB,
+			computed_at    	        TIMESTAMP
+		);
+		CREATE INDEX idx_health_scores_item ON health_scores (account_id, item_id, computed_at);
+		`,
+	},
+	{
+		Version:     19,
+		Description: "Add the stages of the pipelines, the moves of the items between them and the weekly forecasts",
+		Script: `
+		CREATE TABLE pipeline_stages (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			flow_id      		    UUID REFERENCES flows ON DELETE CASCADE,
+			node_id      		    UUID,
+			probability             INTEGER DEFAULT 0,
+			expected_days           INTEGER DEFAULT 0,
+			category      		    TEXT,
+			updated_at    	        BIGINT,
+			PRIMARY KEY (flow_id, node_id)
+		);
+		CREATE TABLE stage_transitions (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			flow_id      		    UUID REFERENCES flows ON DELETE CASCADE,
+			item_id      		    UUID,
+			from_node_id      		UUID,
+			to_node_id      		UUID,
+			spent                   BIGINT DEFAULT 0,
+			moved_at    	        TIMESTAMP
+		);
+		CREATE INDEX idx_stage_transitions_flow ON stage_transitions (account_id, flow_id, moved_at);
+		CREATE INDEX idx_stage_transitions_item ON stage_transitions (flow_id, item_id, moved_at);
+		CREATE TABLE forecast_snapshots (
+			account_id      		UUID REFERENCES accounts ON DELETE CASCADE,
+			flow_id      		    UUID REFERENCES flows ON DELETE CASCADE,
+			week      		        DATE,
+			category      		    TEXT,
+			deals                   INTEGER,
+			amount                  DOUBLE PRECISION,
+			weighted                DOUBLE PRECISION,
+			taken_at    	        TIMESTAMP,
+			PRIMARY KEY (flow_id, week, category)
+		);
+		`,
+	},
+	{
+		Version:     20,
+		Description: "Add the claim time of the notification deliveries to send again the ones left in sending",
+		Script: `
+		ALTER TABLE notification_queue ADD COLUMN claimed_at TIMESTAMP;
+		`,
+	},
+	{
+		Version:     21,
+		Description: "Tag the amount and the close date fields of the deals created before the forecasts",
+		Script: `
+		UPDATE entities SET fieldsb = (
+			SELECT jsonb_agg(CASE
+				WHEN f->>'name' = 'deal_amount' AND COALESCE(f->>'who', '') = '' THEN f || '{"who": "amount"}'::jsonb
+				WHEN f->>'name' = 'close_date' AND COALESCE(f->>'who', '') = '' THEN f || '{"who": "close_date"}'::jsonb
+				ELSE f END ORDER BY i)
+			FROM jsonb_array_elements(fieldsb) WITH ORDINALITY AS fields(f, i)
+		)
+		WHERE fieldsb @> '[{"name": "deal_amount"}]'::jsonb;
+		`,
+	},
 }
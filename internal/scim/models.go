@@ -0,0 +1,128 @@
+package scim
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Schemas of the SCIM 2.0 resources and messages (RFC 7643, RFC 7644).
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// Policies for the items owned by the deprovisioned member.
+const (
+	// PolicyKeep leaves the items with the deprovisioned member.
+	PolicyKeep = "keep"
+	// PolicyReassign moves the items to the member configured in ReassignTo.
+	PolicyReassign = "reassign"
+	// PolicySystem moves the items to the system user.
+	PolicySystem = "system"
+)
+
+// Config is the provisioning setting of the account.
+type Config struct {
+	AccountID         string  `db:"account_id" json:"account_id"`
+	DeprovisionPolicy string  `db:"deprovision_policy" json:"deprovision_policy"`
+	ReassignTo        *string `db:"reassign_to" json:"reassign_to"`
+	UpdatedAt         int64   `db:"updated_at" json:"updated_at"`
+}
+
+// NewConfig has the information needed to change the provisioning setting of the account.
+type NewConfig struct {
+	DeprovisionPolicy string  `json:"deprovision_policy" validate:"required,oneof=keep reassign system"`
+	ReassignTo        *string `json:"reassign_to"`
+}
+
+// User is the SCIM user. The id is the member item of the owners entity, so the member
+// is still found after the access is removed by setting active to false.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	Active      *bool    `json:"active,omitempty"`
+	Groups      []Ref    `json:"groups,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Name is the name of the SCIM user.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Email is the email of the SCIM user.
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Group is the SCIM group which maps to the team of the account.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// Ref refers to the member of the group or the group of the member.
+type Ref struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// Meta is the resource metadata.
+type Meta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+}
+
+// ListResponse is the page of the resources returned by the list endpoints.
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// Error is the error response expected by the SCIM clients.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// PatchOp is the request of the PATCH endpoints.
+type PatchOp struct {
+	Schemas    []string    `json:"schemas"`
+	Operations []Operation `json:"Operations" validate:"required"`
+}
+
+// Operation is one change of the PATCH request. The value is decoded as per the path.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Filter is the equality filter sent by the clients to look up a resource, e.g. userName eq "jane@example.com".
+type Filter struct {
+	Attribute string
+	Value     string
+}
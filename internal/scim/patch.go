@@ -0,0 +1,272 @@
+package scim
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidFilter is used when the filter is not the equality filter on a supported attribute.
+	ErrInvalidFilter = errors.New("Filter is not supported")
+	// ErrInvalidPatch is used when the operation of the PATCH request cannot be applied.
+	ErrInvalidPatch = errors.New("Patch operation is not supported")
+)
+
+var (
+	filterExp = regexp.MustCompile(`^\s*([A-Za-z.]+)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+	// memberPathExp matches the path used by the clients to remove one member, e.g. members[value eq "id"].
+	memberPathExp = regexp.MustCompile(`^members\[\s*value\s+eq\s+"([^"]+)"\s*\]$`)
+)
+
+// GroupPatch is the change to the group collected from the operations of the PATCH request.
+type GroupPatch struct {
+	DisplayName    *string
+	Add            []string
+	Remove         []string
+	ReplaceMembers bool
+	Members        []string
+}
+
+// ParseFilter parses the equality filter. Only the "eq" operator is supported as the clients use the filter
+// to find out whether the resource already exists before creating it.
+func ParseFilter(filter string) (Filter, error) {
+	m := filterExp.FindStringSubmatch(filter)
+	if m == nil {
+		return Filter{}, errors.Wrapf(ErrInvalidFilter, "%q", filter)
+	}
+	value, err := strconv.Unquote(`"` + m[2] + `"`)
+	if err != nil {
+		return Filter{}, errors.Wrapf(ErrInvalidFilter, "%q", filter)
+	}
+	return Filter{Attribute: m[1], Value: value}, nil
+}
+
+// Email returns the email of the user which is the username or else the primary email.
+func (u User) Email() string {
+	if strings.Contains(u.UserName, "@") {
+		return strings.ToLower(strings.TrimSpace(u.UserName))
+	}
+	for _, e := range u.Emails {
+		if e.Primary {
+			return strings.ToLower(strings.TrimSpace(e.Value))
+		}
+	}
+	if len(u.Emails) > 0 {
+		return strings.ToLower(strings.TrimSpace(u.Emails[0].Value))
+	}
+	return ""
+}
+
+// FullName returns the name shown for the member.
+func (u User) FullName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+	if u.Name != nil {
+		if u.Name.Formatted != "" {
+			return u.Name.Formatted
+		}
+		if full := strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName); full != "" {
+			return full
+		}
+	}
+	return ""
+}
+
+// IsActive returns true unless the user is deactivated. A new user is active when the client omits it.
+func (u User) IsActive() bool {
+	return u.Active == nil || *u.Active
+}
+
+// ApplyPatch applies the operations of the PATCH request to the user.
+func (u *User) ApplyPatch(ops []Operation) error {
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		if opName != "add" && opName != "replace" && opName != "remove" {
+			return errors.Wrapf(ErrInvalidPatch, "op %q", op.Op)
+		}
+
+		if op.Path == "" {
+			if opName == "remove" {
+				return errors.Wrap(ErrInvalidPatch, "remove needs the path")
+			}
+			values := make(map[string]json.RawMessage, 0)
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return errors.Wrap(ErrInvalidPatch, "value should be an object when the path is empty")
+			}
+			for path, v := range values {
+				if err := u.set(path, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value := op.Value
+		if opName == "remove" {
+			value = nil
+		}
+		if err := u.set(op.Path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// set changes the attribute of the path. The nil value clears it.
+func (u *User) set(path string, v json.RawMessage) error {
+	if u.Name == nil {
+		u.Name = &Name{}
+	}
+
+	var err error
+	switch strings.ToLower(path) {
+	case "active":
+		if v == nil {
+			return errors.Wrap(ErrInvalidPatch, "active cannot be removed")
+		}
+		var active bool
+		active, err = decodeBool(v)
+		u.Active = &active
+	case "username":
+		err = decodeString(v, &u.UserName)
+	case "displayname":
+		err = decodeString(v, &u.DisplayName)
+	case "externalid":
+		err = decodeString(v, &u.ExternalID)
+	case "name.givenname":
+		err = decodeString(v, &u.Name.GivenName)
+	case "name.familyname":
+		err = decodeString(v, &u.Name.FamilyName)
+	case "name.formatted":
+		err = decodeString(v, &u.Name.Formatted)
+	case "name":
+		u.Name = &Name{}
+		if v != nil {
+			err = json.Unmarshal(v, u.Name)
+		}
+	case "emails":
+		u.Emails = nil
+		if v != nil {
+			err = json.Unmarshal(v, &u.Emails)
+		}
+	case `emails[type eq "work"].value`, "emails[primary eq true].value":
+		var email string
+		if err = decodeString(v, &email); err == nil {
+			u.Emails = []Email{{Value: email, Type: "work", Primary: true}}
+		}
+	default:
+		// the attributes not kept for the member (phone numbers, addresses...) are ignored
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(ErrInvalidPatch, "value of %q: %v", path, err)
+	}
+	return nil
+}
+
+// GroupChanges collects the changes to the group from the operations of the PATCH request.
+func GroupChanges(ops []Operation) (GroupPatch, error) {
+	var gp GroupPatch
+	for _, op := range ops {
+		opName := strings.ToLower(op.Op)
+		path := strings.TrimSpace(op.Path)
+
+		if m := memberPathExp.FindStringSubmatch(path); m != nil && opName == "remove" {
+			gp.Remove = append(gp.Remove, m[1])
+			continue
+		}
+
+		switch {
+		case path == "" && (opName == "add" || opName == "replace"):
+			var g Group
+			if err := json.Unmarshal(op.Value, &g); err != nil {
+				return GroupPatch{}, errors.Wrap(ErrInvalidPatch, "value should be the group when the path is empty")
+			}
+			if g.DisplayName != "" {
+				name := g.DisplayName
+				gp.DisplayName = &name
+			}
+			if g.Members != nil {
+				gp.replace(refValues(g.Members), opName == "replace")
+			}
+		case strings.EqualFold(path, "displayName") && opName != "remove":
+			var name string
+			if err := decodeString(op.Value, &name); err != nil {
+				return GroupPatch{}, errors.Wrap(ErrInvalidPatch, "displayName should be a string")
+			}
+			gp.DisplayName = &name
+		case strings.EqualFold(path, "members"):
+			var refs []Ref
+			if len(op.Value) > 0 && string(op.Value) != "null" {
+				if err := json.Unmarshal(op.Value, &refs); err != nil {
+					return GroupPatch{}, errors.Wrap(ErrInvalidPatch, "members should be the list of references")
+				}
+			}
+			switch opName {
+			case "add":
+				gp.Add = append(gp.Add, refValues(refs)...)
+			case "replace":
+				gp.replace(refValues(refs), true)
+			case "remove":
+				if len(refs) == 0 {
+					gp.replace([]string{}, true)
+				} else {
+					gp.Remove = append(gp.Remove, refValues(refs)...)
+				}
+			default:
+				return GroupPatch{}, errors.Wrapf(ErrInvalidPatch, "op %q", op.Op)
+			}
+		default:
+			return GroupPatch{}, errors.Wrapf(ErrInvalidPatch, "%s of %q", op.Op, op.Path)
+		}
+	}
+	return gp, nil
+}
+
+// replace sets the members of the group. When replaceAll is false the members are added.
+func (gp *GroupPatch) replace(members []string, replaceAll bool) {
+	if !replaceAll {
+		gp.Add = append(gp.Add, members...)
+		return
+	}
+	gp.ReplaceMembers = true
+	gp.Members = members
+	gp.Add = nil
+	gp.Remove = nil
+}
+
+func refValues(refs []Ref) []string {
+	values := make([]string, 0, len(refs))
+	for _, r := range refs {
+		if r.Value != "" {
+			values = append(values, r.Value)
+		}
+	}
+	return values
+}
+
+func decodeString(v json.RawMessage, s *string) error {
+	if v == nil {
+		*s = ""
+		return nil
+	}
+	return json.Unmarshal(v, s)
+}
+
+// decodeBool reads the boolean which some clients send as the string "True"/"False".
+func decodeBool(v json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(v, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
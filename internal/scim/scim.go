@@ -0,0 +1,129 @@
+package scim
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrInvalidConfig is used when the items are to be reassigned without the member to reassign.
+	ErrInvalidConfig = errors.New("Provisioning configuration is not valid")
+)
+
+// RetrieveConfig gets the provisioning setting of the account. The items are kept with the
+// deprovisioned member until the account changes the policy.
+func RetrieveConfig(ctx context.Context, db *sqlx.DB, accountID string) (Config, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.scim.RetrieveConfig")
+	defer span.End()
+
+	var c Config
+	const q = `SELECT * FROM scim_configs WHERE account_id = $1`
+	if err := db.GetContext(ctx, &c, q, accountID); err != nil {
+		if err == sql.ErrNoRows {
+			return Config{AccountID: accountID, DeprovisionPolicy: PolicyKeep}, nil
+		}
+		return Config{}, errors.Wrapf(err, "selecting provisioning config of %q", accountID)
+	}
+
+	return c, nil
+}
+
+// SaveConfig creates or replaces the provisioning setting of the account.
+func SaveConfig(ctx context.Context, db *sqlx.DB, accountID string, nc NewConfig, now time.Time) (Config, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.scim.SaveConfig")
+	defer span.End()
+
+	if nc.DeprovisionPolicy != PolicyReassign {
+		nc.ReassignTo = nil
+	} else if nc.ReassignTo == nil || *nc.ReassignTo == "" {
+		return Config{}, errors.Wrap(ErrInvalidConfig, "reassign policy needs the member to reassign")
+	} else if _, err := user.RetrieveUser(ctx, db, accountID, *nc.ReassignTo); err != nil {
+		return Config{}, errors.Wrapf(ErrInvalidConfig, "member %q to reassign not found", *nc.ReassignTo)
+	}
+
+	c := Config{
+		AccountID:         accountID,
+		DeprovisionPolicy: nc.DeprovisionPolicy,
+		ReassignTo:        nc.ReassignTo,
+		UpdatedAt:         now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO scim_configs
+		(account_id, deprovision_policy, reassign_to, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id) DO UPDATE SET deprovision_policy = $2, reassign_to = $3, updated_at = $4`
+	if _, err := db.ExecContext(ctx, q, c.AccountID, c.DeprovisionPolicy, c.ReassignTo, c.UpdatedAt); err != nil {
+		return Config{}, errors.Wrap(err, "saving provisioning config")
+	}
+
+	return c, nil
+}
+
+// Deprovision removes the access of the member to the account after handing over the
+// items owned by the member as per the policy of the account.
+func Deprovision(ctx context.Context, db *sqlx.DB, usr user.User) error {
+	ctx, span := trace.StartSpan(ctx, "internal.scim.Deprovision")
+	defer span.End()
+
+	c, err := RetrieveConfig(ctx, db, usr.AccountID)
+	if err != nil {
+		return err
+	}
+
+	switch c.DeprovisionPolicy {
+	case PolicyReassign:
+		if c.ReassignTo != nil && *c.ReassignTo != usr.ID {
+			to, err := user.RetrieveUser(ctx, db, usr.AccountID, *c.ReassignTo)
+			if err != nil {
+				return errors.Wrapf(err, "member %q to reassign", *c.ReassignTo)
+			}
+			if err := item.ReassignUser(ctx, db, usr.AccountID, usr.ID, to.ID); err != nil {
+				return err
+			}
+			if err := reassignAssignees(ctx, db, usr.AccountID, usr.MemberID, to.MemberID); err != nil {
+				return err
+			}
+		}
+	case PolicySystem:
+		if err := item.ReassignUser(ctx, db, usr.AccountID, usr.ID, user.UUID_SYSTEM_USER); err != nil {
+			return err
+		}
+		// the system user is not a member, so the items are left unassigned
+		if err := reassignAssignees(ctx, db, usr.AccountID, usr.MemberID, ""); err != nil {
+			return err
+		}
+	}
+
+	return usr.RemoveAccount(ctx, db)
+}
+
+// reassignAssignees hands over the items assigned to the member in the assignee fields of the entities.
+func reassignAssignees(ctx context.Context, db *sqlx.DB, accountID, fromMemberID, toMemberID string) error {
+	if fromMemberID == "" {
+		return nil
+	}
+
+	entities, err := entity.AccountEntities(ctx, accountID, []int{}, db)
+	if err != nil {
+		return err
+	}
+	for _, e := range entities {
+		for _, f := range e.EasyFields() {
+			if f.Who != entity.WhoAssignee {
+				continue
+			}
+			if err := item.ReassignMember(ctx, db, accountID, e.ID, f.Key, fromMemberID, toMemberID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/scim"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Log("Given the need to parse the filters sent by the SCIM clients")
+	{
+		cases := []struct {
+			filter string
+			want   scim.Filter
+			valid  bool
+		}{
+			{`userName eq "jane@example.com"`, scim.Filter{Attribute: "userName", Value: "jane@example.com"}, true},
+			{`displayName eq "Sales \"EU\""`, scim.Filter{Attribute: "displayName", Value: `Sales "EU"`}, true},
+			{`emails.value eq "jane@example.com"`, scim.Filter{Attribute: "emails.value", Value: "jane@example.com"}, true},
+			{`userName sw "jane"`, scim.Filter{}, false},
+			{`userName eq jane`, scim.Filter{}, false},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the filter is %s", c.filter)
+			{
+				got, err := scim.ParseFilter(c.filter)
+				if (err == nil) != c.valid || got != c.want {
+					t.Fatalf("\t%s should parse to %+v (valid %v). got %+v, %v", tests.Failed, c.want, c.valid, got, err)
+				}
+				t.Logf("\t%s should parse to %+v", tests.Success, c.want)
+			}
+		}
+	}
+}
+
+func TestUserPatch(t *testing.T) {
+	t.Log("Given the need to apply the changes of the IdP to the user")
+	{
+		cases := []struct {
+			name   string
+			ops    string
+			active bool
+			email  string
+			full   string
+		}{
+			{"deactivate with the path", `[{"op":"replace","path":"active","value":false}]`, false, "jane@example.com", "Jane"},
+			{"deactivate with the string value", `[{"op":"Replace","path":"active","value":"False"}]`, false, "jane@example.com", "Jane"},
+			{"deactivate without the path", `[{"op":"replace","value":{"active":false}}]`, false, "jane@example.com", "Jane"},
+			{"rename", `[{"op":"replace","path":"name.givenName","value":"Janet"},{"op":"replace","path":"name.familyName","value":"Doe"},{"op":"remove","path":"displayName"}]`, true, "jane@example.com", "Janet Doe"},
+			{"change the email", `[{"op":"replace","path":"userName","value":"Janet@Example.com"}]`, true, "janet@example.com", "Jane"},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the IdP sends the patch to %s", c.name)
+			{
+				var ops []scim.Operation
+				if err := json.Unmarshal([]byte(c.ops), &ops); err != nil {
+					t.Fatal(err)
+				}
+				active := true
+				u := scim.User{UserName: "jane@example.com", DisplayName: "Jane", Active: &active}
+				if err := u.ApplyPatch(ops); err != nil {
+					t.Fatalf("\t%s should apply the patch : %s", tests.Failed, err)
+				}
+				if u.IsActive() != c.active || u.Email() != c.email || u.FullName() != c.full {
+					t.Fatalf("\t%s should get active %v, %s, %s. got %v, %s, %s", tests.Failed, c.active, c.email, c.full, u.IsActive(), u.Email(), u.FullName())
+				}
+				t.Logf("\t%s should get active %v, %s, %s", tests.Success, c.active, c.email, c.full)
+			}
+		}
+
+		t.Log("\twhen the IdP sends an unknown op")
+		{
+			u := scim.User{UserName: "jane@example.com"}
+			if err := u.ApplyPatch([]scim.Operation{{Op: "move", Path: "active"}}); err == nil {
+				t.Fatalf("\t%s should reject the patch", tests.Failed)
+			}
+			t.Logf("\t%s should reject the patch", tests.Success)
+		}
+	}
+}
+
+func TestGroupChanges(t *testing.T) {
+	t.Log("Given the need to collect the changes of the IdP to the group")
+	{
+		t.Log("\twhen the members are added and removed one by one")
+		{
+			var ops []scim.Operation
+			json.Unmarshal([]byte(`[
+				{"op":"add","path":"members","value":[{"value":"m1"},{"value":"m2"}]},
+				{"op":"remove","path":"members[value eq \"m3\"]"},
+				{"op":"remove","path":"members","value":[{"value":"m4"}]},
+				{"op":"replace","path":"displayName","value":"Sales"}
+			]`), &ops)
+			gp, err := scim.GroupChanges(ops)
+			if err != nil {
+				t.Fatalf("\t%s should collect the changes : %s", tests.Failed, err)
+			}
+			if len(gp.Add) != 2 || len(gp.Remove) != 2 || gp.Remove[0] != "m3" || gp.ReplaceMembers || gp.DisplayName == nil || *gp.DisplayName != "Sales" {
+				t.Fatalf("\t%s should add m1, m2 and remove m3, m4. got %+v", tests.Failed, gp)
+			}
+			t.Logf("\t%s should add m1, m2 and remove m3, m4", tests.Success)
+		}
+
+		t.Log("\twhen the members are replaced")
+		{
+			var ops []scim.Operation
+			json.Unmarshal([]byte(`[{"op":"replace","value":{"displayName":"Ops","members":[{"value":"m5"}]}}]`), &ops)
+			gp, err := scim.GroupChanges(ops)
+			if err != nil {
+				t.Fatalf("\t%s should collect the changes : %s", tests.Failed, err)
+			}
+			if !gp.ReplaceMembers || len(gp.Members) != 1 || gp.Members[0] != "m5" || *gp.DisplayName != "Ops" {
+				t.Fatalf("\t%s should replace the members with m5. got %+v", tests.Failed, gp)
+			}
+			t.Logf("\t%s should replace the members with m5", tests.Success)
+		}
+
+		t.Log("\twhen an unsupported attribute is changed")
+		{
+			if _, err := scim.GroupChanges([]scim.Operation{{Op: "replace", Path: "externalId", Value: json.RawMessage(`"x"`)}}); err == nil {
+				t.Fatalf("\t%s should reject the patch", tests.Failed)
+			}
+			t.Logf("\t%s should reject the patch", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package sla
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BusinessHours computes the due times using the working shifts and the holidays.
+// The zero value (no shifts) represents the 24x7 calendar.
+type BusinessHours struct {
+	loc      *time.Location
+	shifts   map[time.Weekday]shift
+	holidays map[string]bool
+}
+
+type shift struct {
+	start time.Duration // offset from the midnight
+	end   time.Duration
+}
+
+// maxDays stops the walk when the calendar has no working hours at all.
+const maxDays = 3660
+
+// NewBusinessHours builds the business hours from the calendar. A nil calendar is 24x7.
+func NewBusinessHours(c *Calendar) (BusinessHours, error) {
+	bh := BusinessHours{loc: time.UTC}
+	if c == nil {
+		return bh, nil
+	}
+
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return bh, errors.Wrapf(err, "loading timezone %s", c.Timezone)
+		}
+		bh.loc = loc
+	}
+
+	hours := map[time.Weekday]Shift{}
+	if c.Hoursb != "" {
+		if err := json.Unmarshal([]byte(c.Hoursb), &hours); err != nil {
+			return bh, errors.Wrap(err, "decoding calendar hours")
+		}
+	}
+	bh.shifts = make(map[time.Weekday]shift, len(hours))
+	for day, s := range hours {
+		start, err := clock(s.Start)
+		if err != nil {
+			return bh, err
+		}
+		end, err := clock(s.End)
+		if err != nil {
+			return bh, err
+		}
+		if end > start {
+			bh.shifts[day] = shift{start: start, end: end}
+		}
+	}
+
+	bh.holidays = make(map[string]bool, len(c.Holidays))
+	for _, h := range c.Holidays {
+		bh.holidays[h] = true
+	}
+	return bh, nil
+}
+
+// Add returns the time after the business duration d is spent from the time from.
+func (bh BusinessHours) Add(from time.Time, d time.Duration) time.Time {
+	if bh.is24x7() {
+		return from.Add(d)
+	}
+
+	t := from.In(bh.loc)
+	for i := 0; i < maxDays; i++ {
+		start, end, ok := bh.window(t)
+		if ok && t.Before(end) {
+			if t.Before(start) {
+				t = start
+			}
+			if left := end.Sub(t); d <= left {
+				return t.Add(d)
+			} else {
+				d -= left
+			}
+		}
+		t = midnight(t).AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// Elapsed returns the business duration spent between the times.
+func (bh BusinessHours) Elapsed(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	if bh.is24x7() {
+		return to.Sub(from)
+	}
+
+	var elapsed time.Duration
+	t := from.In(bh.loc)
+	to = to.In(bh.loc)
+	for i := 0; i < maxDays && t.Before(to); i++ {
+		start, end, ok := bh.window(t)
+		if ok {
+			if t.After(start) {
+				start = t
+			}
+			if to.Before(end) {
+				end = to
+			}
+			if end.After(start) {
+				elapsed += end.Sub(start)
+			}
+		}
+		t = midnight(t).AddDate(0, 0, 1)
+	}
+	return elapsed
+}
+
+func (bh BusinessHours) is24x7() bool {
+	return len(bh.shifts) == 0
+}
+
+// window returns the working window of the day of t.
+func (bh BusinessHours) window(t time.Time) (time.Time, time.Time, bool) {
+	if bh.holidays[t.Format("2006-01-02")] {
+		return time.Time{}, time.Time{}, false
+	}
+	s, ok := bh.shifts[t.Weekday()]
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+	return clockOn(t, s.start), clockOn(t, s.end), true
+}
+
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// clockOn builds the wall clock time on the day of t. Using time.Date keeps it correct on the DST days.
+func clockOn(t time.Time, offset time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, int(offset/time.Minute), 0, 0, t.Location())
+}
+
+func clock(hhmm string) (time.Duration, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parsing shift time %s", hhmm)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,79 @@
+package sla_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/sla"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestBusinessHours(t *testing.T) {
+	t.Log("Given the need to compute the SLA due times using the business hours")
+	{
+		t.Log("\twhen the policy does not have a calendar")
+		{
+			bh, err := sla.NewBusinessHours(nil)
+			if err != nil {
+				t.Fatalf("\t%s should build the 24x7 hours - %s", tests.Failed, err)
+			}
+			from := time.Date(2022, 3, 5, 22, 0, 0, 0, time.UTC) // saturday
+			if due := bh.Add(from, 4*time.Hour); !due.Equal(from.Add(4 * time.Hour)) {
+				t.Fatalf("\t%s should add the duration as is. got %v", tests.Failed, due)
+			}
+			t.Logf("\t%s should add the duration as is", tests.Success)
+		}
+
+		weekdays := map[time.Weekday]sla.Shift{}
+		for d := time.Monday; d <= time.Friday; d++ {
+			weekdays[d] = sla.Shift{Start: "09:00", End: "17:00"}
+		}
+		hoursb, _ := json.Marshal(weekdays)
+
+		t.Log("\twhen the duration spills over the weekend")
+		{
+			bh, err := sla.NewBusinessHours(&sla.Calendar{Hoursb: string(hoursb)})
+			if err != nil {
+				t.Fatalf("\t%s should build the business hours - %s", tests.Failed, err)
+			}
+			from := time.Date(2022, 3, 4, 15, 0, 0, 0, time.UTC) // friday
+			due := bh.Add(from, 4*time.Hour)
+			if want := time.Date(2022, 3, 7, 11, 0, 0, 0, time.UTC); !due.Equal(want) {
+				t.Fatalf("\t%s should skip the weekend. want %v got %v", tests.Failed, want, due)
+			}
+			if elapsed := bh.Elapsed(from, due); elapsed != 4*time.Hour {
+				t.Fatalf("\t%s should count only the working hours. got %v", tests.Failed, elapsed)
+			}
+			t.Logf("\t%s should skip the weekend", tests.Success)
+		}
+
+		t.Log("\twhen the item is created before the shift on a holiday eve")
+		{
+			bh, err := sla.NewBusinessHours(&sla.Calendar{Hoursb: string(hoursb), Holidays: []string{"2022-03-08"}})
+			if err != nil {
+				t.Fatalf("\t%s should build the business hours - %s", tests.Failed, err)
+			}
+			from := time.Date(2022, 3, 7, 6, 0, 0, 0, time.UTC) // monday
+			due := bh.Add(from, 10*time.Hour)
+			if want := time.Date(2022, 3, 9, 11, 0, 0, 0, time.UTC); !due.Equal(want) {
+				t.Fatalf("\t%s should start at the shift and skip the holiday. want %v got %v", tests.Failed, want, due)
+			}
+			t.Logf("\t%s should start at the shift and skip the holiday", tests.Success)
+		}
+
+		t.Log("\twhen the calendar is in a different timezone")
+		{
+			bh, err := sla.NewBusinessHours(&sla.Calendar{Timezone: "Asia/Kolkata", Hoursb: string(hoursb)})
+			if err != nil {
+				t.Fatalf("\t%s should build the business hours - %s", tests.Failed, err)
+			}
+			from := time.Date(2022, 3, 7, 2, 0, 0, 0, time.UTC) // 07:30 IST
+			due := bh.Add(from, time.Hour)
+			if want := time.Date(2022, 3, 7, 4, 30, 0, 0, time.UTC); !due.Equal(want) {
+				t.Fatalf("\t%s should use the shift of the calendar timezone. want %v got %v", tests.Failed, want, due)
+			}
+			t.Logf("\t%s should use the shift of the calendar timezone", tests.Success)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sla
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Metrics tracked by the policy.
+const (
+	MetricFirstResponse = "first_response"
+	MetricResolution    = "resolution"
+)
+
+// States of the timer.
+const (
+	StateRunning  = "running"
+	StatePaused   = "paused"
+	StateMet      = "met"
+	StateBreached = "breached"
+)
+
+// Status values written into the sla field (who: sla) of the item. Workflows can use them in the expression.
+const (
+	StatusOnTrack    = "on_track"
+	StatusPaused     = "paused"
+	StatusNearBreach = "near_breach"
+	StatusBreached   = "breached"
+	StatusMet        = "met"
+)
+
+// DefaultNearBreachPct is used when the policy does not specify when to warn.
+const DefaultNearBreachPct = 80
+
+// Policy decides the SLA targets of the items of an entity. The first policy (by position)
+// whose expression matches the item gets applied. An empty expression matches all the items.
+type Policy struct {
+	ID                string         `db:"policy_id" json:"id"`
+	AccountID         string         `db:"account_id" json:"account_id"`
+	EntityID          string         `db:"entity_id" json:"entity_id"`
+	CalendarID        *string        `db:"calendar_id" json:"calendar_id"` // * because it could be null. null means 24x7
+	Name              string         `db:"name" json:"name"`
+	Expression        string         `db:"expression" json:"expression"`
+	Position          int            `db:"position" json:"position"`
+	FirstResponseMins int            `db:"first_response_mins" json:"first_response_mins"`
+	ResolutionMins    int            `db:"resolution_mins" json:"resolution_mins"`
+	NearBreachPct     int            `db:"near_breach_pct" json:"near_breach_pct"`
+	PauseStatuses     pq.StringArray `db:"pause_statuses" json:"pause_statuses"`
+	CreatedAt         time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt         int64          `db:"updated_at" json:"updated_at"`
+}
+
+// NewPolicy contains information needed to create a new policy.
+type NewPolicy struct {
+	ID                string   `json:"id"`
+	AccountID         string   `json:"account_id"`
+	EntityID          string   `json:"entity_id"`
+	CalendarID        *string  `json:"calendar_id"`
+	Name              string   `json:"name" validate:"required"`
+	Expression        string   `json:"expression"`
+	Position          int      `json:"position"`
+	FirstResponseMins int      `json:"first_response_mins"`
+	ResolutionMins    int      `json:"resolution_mins"`
+	NearBreachPct     int      `json:"near_breach_pct"`
+	PauseStatuses     []string `json:"pause_statuses"`
+}
+
+// Calendar represents the business hours and the holidays of the account.
+type Calendar struct {
+	ID        string         `db:"calendar_id" json:"id"`
+	AccountID string         `db:"account_id" json:"account_id"`
+	Name      string         `db:"name" json:"name"`
+	Timezone  string         `db:"timezone" json:"timezone"`
+	Hoursb    string         `db:"hoursb" json:"hoursb"`
+	Holidays  pq.StringArray `db:"holidays" json:"holidays"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt int64          `db:"updated_at" json:"updated_at"`
+}
+
+// NewCalendar contains information needed to create a new calendar.
+// Hours is keyed by the weekday (0 - sunday) and the holidays are in 2006-01-02 format.
+type NewCalendar struct {
+	ID       string                 `json:"id"`
+	Name     string                 `json:"name" validate:"required"`
+	Timezone string                 `json:"timezone"`
+	Hours    map[time.Weekday]Shift `json:"hours"`
+	Holidays []string               `json:"holidays"`
+}
+
+// Shift is the working window of a day in 15:04 format.
+type Shift struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Timer tracks a metric of the policy for an item. Elapsed keeps the business seconds consumed
+// till the last pause, so the due time can be recomputed when the timer resumes.
+type Timer struct {
+	ID          string     `db:"timer_id" json:"id"`
+	AccountID   string     `db:"account_id" json:"account_id"`
+	EntityID    string     `db:"entity_id" json:"entity_id"`
+	ItemID      string     `db:"item_id" json:"item_id"`
+	PolicyID    string     `db:"policy_id" json:"policy_id"`
+	Metric      string     `db:"metric" json:"metric"`
+	State       string     `db:"state" json:"state"`
+	TargetSecs  int64      `db:"target_secs" json:"target_secs"`
+	ElapsedSecs int64      `db:"elapsed_secs" json:"elapsed_secs"`
+	StartedAt   time.Time  `db:"started_at" json:"started_at"`
+	ResumedAt   *time.Time `db:"resumed_at" json:"resumed_at"`
+	NearAt      *time.Time `db:"near_at" json:"near_at"`
+	DueAt       *time.Time `db:"due_at" json:"due_at"`
+	Warned      bool       `db:"warned" json:"warned"`
+	BreachedAt  *time.Time `db:"breached_at" json:"breached_at"`
+	MetAt       *time.Time `db:"met_at" json:"met_at"`
+}
+
+// Event is raised by the sweep when a running timer crosses its near-breach or due time.
+type Event struct {
+	AccountID string
+	EntityID  string
+	ItemID    string
+	Metric    string
+	Status    string
+}
@@ -0,0 +1,596 @@
+package sla
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/rule/engine"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when a specific policy/calendar is requested but does not exist.
+	ErrNotFound = errors.New("SLA not found")
+)
+
+// CreatePolicy inserts the SLA policy of the entity.
+func CreatePolicy(ctx context.Context, db *sqlx.DB, np NewPolicy, now time.Time) (Policy, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.CreatePolicy")
+	defer span.End()
+
+	if np.ID == "" {
+		np.ID = uuid.New().String()
+	}
+	if np.NearBreachPct <= 0 || np.NearBreachPct >= 100 {
+		np.NearBreachPct = DefaultNearBreachPct
+	}
+	if np.CalendarID != nil && *np.CalendarID == "" {
+		np.CalendarID = nil
+	}
+
+	p := Policy{
+		ID:                np.ID,
+		AccountID:         np.AccountID,
+		EntityID:          np.EntityID,
+		CalendarID:        np.CalendarID,
+		Name:              np.Name,
+		Expression:        np.Expression,
+		Position:          np.Position,
+		FirstResponseMins: np.FirstResponseMins,
+		ResolutionMins:    np.ResolutionMins,
+		NearBreachPct:     np.NearBreachPct,
+		PauseStatuses:     np.PauseStatuses,
+		CreatedAt:         now.UTC(),
+		UpdatedAt:         now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO sla_policies
+		(policy_id, account_id, entity_id, calendar_id, name, expression, position, first_response_mins, resolution_mins, near_breach_pct, pause_statuses, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+	_, err := db.ExecContext(
+		ctx, q,
+		p.ID, p.AccountID, p.EntityID, p.CalendarID, p.Name, p.Expression, p.Position, p.FirstResponseMins, p.ResolutionMins, p.NearBreachPct, p.PauseStatuses,
+		p.CreatedAt, p.UpdatedAt,
+	)
+	if err != nil {
+		return Policy{}, errors.Wrap(err, "inserting sla policy")
+	}
+
+	return p, nil
+}
+
+// ListPolicies returns the policies of the entity in the order of evaluation.
+func ListPolicies(ctx context.Context, db *sqlx.DB, accountID, entityID string) ([]Policy, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.ListPolicies")
+	defer span.End()
+
+	policies := []Policy{}
+	const q = `SELECT * FROM sla_policies WHERE account_id = $1 AND entity_id = $2 ORDER BY position, created_at`
+	if err := db.SelectContext(ctx, &policies, q, accountID, entityID); err != nil {
+		return nil, errors.Wrap(err, "selecting sla policies")
+	}
+	return policies, nil
+}
+
+// RetrievePolicy gets the policy.
+func RetrievePolicy(ctx context.Context, db *sqlx.DB, accountID, policyID string) (Policy, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.RetrievePolicy")
+	defer span.End()
+
+	var p Policy
+	const q = `SELECT * FROM sla_policies WHERE account_id = $1 AND policy_id = $2`
+	if err := db.GetContext(ctx, &p, q, accountID, policyID); err != nil {
+		if err == sql.ErrNoRows {
+			return Policy{}, ErrNotFound
+		}
+		return Policy{}, errors.Wrapf(err, "selecting sla policy %q", policyID)
+	}
+	return p, nil
+}
+
+// DeletePolicy removes the policy. The timers started by the policy are removed too.
+func DeletePolicy(ctx context.Context, db *sqlx.DB, accountID, policyID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.DeletePolicy")
+	defer span.End()
+
+	const q = `DELETE FROM sla_policies WHERE account_id = $1 AND policy_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, policyID); err != nil {
+		return errors.Wrapf(err, "deleting sla policy %s", policyID)
+	}
+	return nil
+}
+
+// CreateCalendar inserts the business hours calendar.
+func CreateCalendar(ctx context.Context, db *sqlx.DB, accountID string, nc NewCalendar, now time.Time) (Calendar, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.CreateCalendar")
+	defer span.End()
+
+	if nc.ID == "" {
+		nc.ID = uuid.New().String()
+	}
+	hoursBytes, err := json.Marshal(nc.Hours)
+	if err != nil {
+		return Calendar{}, errors.Wrap(err, "encode hours to bytes")
+	}
+
+	c := Calendar{
+		ID:        nc.ID,
+		AccountID: accountID,
+		Name:      nc.Name,
+		Timezone:  nc.Timezone,
+		Hoursb:    string(hoursBytes),
+		Holidays:  nc.Holidays,
+		CreatedAt: now.UTC(),
+		UpdatedAt: now.UTC().Unix(),
+	}
+
+	//validates the timezone and the shifts before saving
+	if _, err := NewBusinessHours(&c); err != nil {
+		return Calendar{}, err
+	}
+
+	const q = `INSERT INTO sla_calendars
+		(calendar_id, account_id, name, timezone, hoursb, holidays, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err = db.ExecContext(
+		ctx, q,
+		c.ID, c.AccountID, c.Name, c.Timezone, c.Hoursb, c.Holidays,
+		c.CreatedAt, c.UpdatedAt,
+	)
+	if err != nil {
+		return Calendar{}, errors.Wrap(err, "inserting sla calendar")
+	}
+	return c, nil
+}
+
+// ListCalendars returns the calendars of the account.
+func ListCalendars(ctx context.Context, db *sqlx.DB, accountID string) ([]Calendar, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.ListCalendars")
+	defer span.End()
+
+	calendars := []Calendar{}
+	const q = `SELECT * FROM sla_calendars WHERE account_id = $1`
+	if err := db.SelectContext(ctx, &calendars, q, accountID); err != nil {
+		return nil, errors.Wrap(err, "selecting sla calendars")
+	}
+	return calendars, nil
+}
+
+// RetrieveCalendar gets the calendar.
+func RetrieveCalendar(ctx context.Context, db *sqlx.DB, accountID, calendarID string) (Calendar, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.RetrieveCalendar")
+	defer span.End()
+
+	var c Calendar
+	const q = `SELECT * FROM sla_calendars WHERE account_id = $1 AND calendar_id = $2`
+	if err := db.GetContext(ctx, &c, q, accountID, calendarID); err != nil {
+		if err == sql.ErrNoRows {
+			return Calendar{}, ErrNotFound
+		}
+		return Calendar{}, errors.Wrapf(err, "selecting sla calendar %q", calendarID)
+	}
+	return c, nil
+}
+
+// DeleteCalendar removes the calendar. Policies using it fall back to 24x7.
+func DeleteCalendar(ctx context.Context, db *sqlx.DB, accountID, calendarID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.DeleteCalendar")
+	defer span.End()
+
+	const q = `DELETE FROM sla_calendars WHERE account_id = $1 AND calendar_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, calendarID); err != nil {
+		return errors.Wrapf(err, "deleting sla calendar %s", calendarID)
+	}
+	return nil
+}
+
+// ItemTimers returns the timers of the item.
+func ItemTimers(ctx context.Context, db *sqlx.DB, accountID, itemID string) ([]Timer, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.ItemTimers")
+	defer span.End()
+
+	timers := []Timer{}
+	const q = `SELECT * FROM sla_timers WHERE account_id = $1 AND item_id = $2 ORDER BY started_at`
+	if err := db.SelectContext(ctx, &timers, q, accountID, itemID); err != nil {
+		return nil, errors.Wrap(err, "selecting sla timers")
+	}
+	return timers, nil
+}
+
+// Compliance counts the timers of the entity by state for the timers started between the times.
+func Compliance(ctx context.Context, db *sqlx.DB, accountID, entityID, metric string, startTime, endTime time.Time) (map[string]int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.Compliance")
+	defer span.End()
+
+	var rows []struct {
+		State string `db:"state"`
+		Count int    `db:"count"`
+	}
+	const q = `SELECT state, count(*) AS count FROM sla_timers WHERE account_id = $1 AND entity_id = $2 AND metric = $3 AND started_at > $4 AND started_at < $5 GROUP BY state`
+	if err := db.SelectContext(ctx, &rows, q, accountID, entityID, metric, startTime, endTime); err != nil {
+		return nil, errors.Wrap(err, "selecting sla compliance")
+	}
+
+	counts := map[string]int{StateRunning: 0, StatePaused: 0, StateMet: 0, StateBreached: 0}
+	for _, r := range rows {
+		counts[r.State] = r.Count
+	}
+	return counts, nil
+}
+
+// Start selects the policy for the newly created item and starts its timers.
+// It returns the resolution due time to be set on the due by field of the item.
+func Start(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, eng engine.Engine, e entity.Entity, it item.Item, now time.Time) (*time.Time, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.Start")
+	defer span.End()
+
+	p, err := selectPolicy(ctx, db, sdb, eng, e, it)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	bh, err := businessHours(ctx, db, *p)
+	if err != nil {
+		return nil, err
+	}
+
+	paused := p.pauses(statusOf(e, it.Fields()))
+	var due *time.Time
+	for metric, mins := range map[string]int{MetricFirstResponse: p.FirstResponseMins, MetricResolution: p.ResolutionMins} {
+		if mins <= 0 {
+			continue
+		}
+		t := Timer{
+			ID:         uuid.New().String(),
+			AccountID:  it.AccountID,
+			EntityID:   it.EntityID,
+			ItemID:     it.ID,
+			PolicyID:   p.ID,
+			Metric:     metric,
+			State:      StateRunning,
+			TargetSecs: int64(mins) * 60,
+			StartedAt:  now.UTC(),
+		}
+		if paused {
+			t.State = StatePaused
+		} else {
+			t.resume(bh, *p, now)
+		}
+		if err := createTimer(ctx, db, t); err != nil {
+			return nil, err
+		}
+		if metric == MetricResolution {
+			due = t.DueAt
+		}
+	}
+	return due, nil
+}
+
+// Track moves the timers of the item on update. The first response is met when a member other than the
+// requester changes the item (see responded). The timers are met when the status moves to done, paused when the
+// status is one of the pause statuses of the policy and resumed otherwise. It returns the status to be set on the
+// sla fields keyed by the metric. Metrics without a change are left out.
+func Track(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, e entity.Entity, it item.Item, userID string, oldFields, newFields map[string]interface{}, now time.Time) (map[string]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.Track")
+	defer span.End()
+
+	timers, err := openTimers(ctx, db, it.AccountID, it.ID)
+	if err != nil || len(timers) == 0 {
+		return nil, err
+	}
+
+	p, err := RetrievePolicy(ctx, db, it.AccountID, timers[0].PolicyID)
+	if err != nil {
+		return nil, err
+	}
+	bh, err := businessHours(ctx, db, p)
+	if err != nil {
+		return nil, err
+	}
+
+	statusID := statusOf(e, newFields)
+	done := false
+	if statusField := e.WhoField(entity.WhoStatus); statusID != "" && statusField.RefID != "" {
+		doneID, err := entity.DiscoverDoneStatusID(ctx, it.AccountID, statusField.RefID, db, sdb)
+		if err != nil && err != entity.ErrNotFound {
+			return nil, err
+		}
+		done = doneID != "" && doneID == statusID
+	}
+	paused := p.pauses(statusID)
+	response := responded(e, it, userID, oldFields, newFields)
+
+	statuses := map[string]string{}
+	for _, t := range timers {
+		before := t.State
+		switch {
+		case done, response && t.Metric == MetricFirstResponse:
+			t.met(now)
+		case paused && t.State == StateRunning:
+			t.pause(bh, now)
+		case !paused && t.State == StatePaused:
+			t.resume(bh, p, now)
+		}
+		if t.State == before {
+			continue
+		}
+		if err := updateTimer(ctx, db, t); err != nil {
+			return nil, err
+		}
+		statuses[t.Metric] = t.status()
+	}
+	return statuses, nil
+}
+
+// Sweep claims the running timers which crossed the near-breach or the due time and returns the events to raise.
+// The timers are marked in the same statement that selects them and the rows locked by another sweeper are skipped,
+// so every breach/near-breach is raised once even when many workers sweep together.
+func Sweep(ctx context.Context, db *sqlx.DB, now time.Time) ([]Event, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sla.Sweep")
+	defer span.End()
+
+	timers := []Timer{}
+	const q = `UPDATE sla_timers t SET
+		"state" = CASE WHEN t.due_at <= $2 THEN $3 ELSE t.state END,
+		"breached_at" = CASE WHEN t.due_at <= $2 THEN $2 ELSE t.breached_at END,
+		"warned" = true
+		FROM (
+			SELECT timer_id FROM sla_timers
+			WHERE state = $1 AND ((warned = false AND near_at <= $2) OR due_at <= $2)
+			FOR UPDATE SKIP LOCKED
+		) due
+		WHERE t.timer_id = due.timer_id AND t.state = $1
+		RETURNING t.*`
+	if err := db.SelectContext(ctx, &timers, q, StateRunning, now.UTC(), StateBreached); err != nil {
+		return nil, errors.Wrap(err, "claiming due sla timers")
+	}
+
+	events := make([]Event, 0, len(timers))
+	for _, t := range timers {
+		ev := Event{AccountID: t.AccountID, EntityID: t.EntityID, ItemID: t.ItemID, Metric: t.Metric, Status: StatusNearBreach}
+		if t.State == StateBreached {
+			ev.Status = StatusBreached
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+// StatusField returns the sla field of the entity which carries the status of the metric.
+func StatusField(e entity.Entity, metric string) entity.Field {
+	if metric == MetricFirstResponse {
+		return e.WhoField(entity.WhoSLAResponse)
+	}
+	return e.WhoField(entity.WhoSLA)
+}
+
+// EnsureField adds the hidden sla fields (resolution and first response) to the entity if they do not exist already.
+// Workflows of the entity use these fields in the expressions to act on the breach/near-breach.
+func EnsureField(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, e entity.Entity) error {
+	fields, err := e.Fields()
+	if err != nil {
+		return err
+	}
+
+	dirty := false
+	for _, f := range []struct{ who, name, displayName string }{
+		{entity.WhoSLA, "sla_status", "SLA"},
+		{entity.WhoSLAResponse, "sla_response_status", "First Response SLA"},
+	} {
+		if e.WhoField(f.who).Key != "" {
+			continue
+		}
+		fields = append(fields, entity.Field{
+			Key:         uuid.New().String(),
+			Name:        f.name,
+			DisplayName: f.displayName,
+			DomType:     entity.DomNotApplicable,
+			DataType:    entity.TypeString,
+			Who:         f.who,
+			Meta:        map[string]string{entity.MetaKeyHidden: "true"},
+		})
+		dirty = true
+	}
+	if !dirty {
+		return nil
+	}
+
+	input, err := json.Marshal(fields)
+	if err != nil {
+		return errors.Wrap(err, "encode fields to input")
+	}
+	return entity.Update(ctx, db, sdb, e.AccountID, e.ID, string(input), time.Now())
+}
+
+func selectPolicy(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, eng engine.Engine, e entity.Entity, it item.Item) (*Policy, error) {
+	policies, err := ListPolicies(ctx, db, it.AccountID, e.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range policies {
+		if p.Expression == "" || eng.RunExpEvaluator(ctx, db, sdb, it.AccountID, p.Expression, map[string]interface{}{e.ID: it.ID}) {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+func businessHours(ctx context.Context, db *sqlx.DB, p Policy) (BusinessHours, error) {
+	if p.CalendarID == nil {
+		return NewBusinessHours(nil)
+	}
+	c, err := RetrieveCalendar(ctx, db, p.AccountID, *p.CalendarID)
+	if err != nil {
+		if err == ErrNotFound {
+			log.Printf("***> unexpected error occurred in internal.sla. calendar %s of the policy %s missing. using 24x7\n", *p.CalendarID, p.ID)
+			return NewBusinessHours(nil)
+		}
+		return BusinessHours{}, err
+	}
+	return NewBusinessHours(&c)
+}
+
+func openTimers(ctx context.Context, db *sqlx.DB, accountID, itemID string) ([]Timer, error) {
+	timers := []Timer{}
+	const q = `SELECT * FROM sla_timers WHERE account_id = $1 AND item_id = $2 AND state IN ($3, $4)`
+	if err := db.SelectContext(ctx, &timers, q, accountID, itemID, StateRunning, StatePaused); err != nil {
+		return nil, errors.Wrap(err, "selecting open sla timers")
+	}
+	return timers, nil
+}
+
+func createTimer(ctx context.Context, db *sqlx.DB, t Timer) error {
+	const q = `INSERT INTO sla_timers
+		(timer_id, account_id, entity_id, item_id, policy_id, metric, state, target_secs, elapsed_secs, started_at, resumed_at, near_at, due_at, warned)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	_, err := db.ExecContext(
+		ctx, q,
+		t.ID, t.AccountID, t.EntityID, t.ItemID, t.PolicyID, t.Metric, t.State, t.TargetSecs, t.ElapsedSecs,
+		t.StartedAt, t.ResumedAt, t.NearAt, t.DueAt, t.Warned,
+	)
+	if err != nil {
+		return errors.Wrap(err, "inserting sla timer")
+	}
+	return nil
+}
+
+func updateTimer(ctx context.Context, db *sqlx.DB, t Timer) error {
+	const q = `UPDATE sla_timers SET
+		"state" = $3,
+		"elapsed_secs" = $4,
+		"resumed_at" = $5,
+		"near_at" = $6,
+		"due_at" = $7,
+		"warned" = $8,
+		"breached_at" = $9,
+		"met_at" = $10
+		WHERE account_id = $1 AND timer_id = $2`
+	_, err := db.ExecContext(ctx, q, t.AccountID, t.ID, t.State, t.ElapsedSecs, t.ResumedAt, t.NearAt, t.DueAt, t.Warned, t.BreachedAt, t.MetAt)
+	if err != nil {
+		return errors.Wrap(err, "updating sla timer")
+	}
+	return nil
+}
+
+// statusOf returns the status item id of the item fields.
+func statusOf(e entity.Entity, fields map[string]interface{}) string {
+	statusField := e.WhoField(entity.WhoStatus)
+	if statusField.Key == "" {
+		return ""
+	}
+	switch v := fields[statusField.Key].(type) {
+	case []interface{}:
+		if len(v) > 0 {
+			if id, ok := v[0].(string); ok {
+				return id
+			}
+		}
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	case string:
+		return v
+	}
+	return ""
+}
+
+// responded reports whether the update counts as the first response. It must come from a member (not the
+// engine or an integration) other than the requester who created the item, and it must change a field other
+// than the bookkeeping fields the sla itself writes.
+func responded(e entity.Entity, it item.Item, userID string, oldFields, newFields map[string]interface{}) bool {
+	if userID == "" || userID == user.UUID_ENGINE_USER || userID == user.UUID_SYSTEM_USER {
+		return false
+	}
+	if it.UserID != nil && *it.UserID == userID {
+		return false
+	}
+	skip := map[string]bool{
+		e.WhoField(entity.WhoSLA).Key:         true,
+		e.WhoField(entity.WhoSLAResponse).Key: true,
+		e.WhoField(entity.WhoDueBy).Key:       true,
+	}
+	for key, nv := range newFields {
+		if skip[key] {
+			continue
+		}
+		if !reflect.DeepEqual(oldFields[key], nv) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Policy) pauses(statusID string) bool {
+	if statusID == "" {
+		return false
+	}
+	for _, s := range p.PauseStatuses {
+		if s == statusID {
+			return true
+		}
+	}
+	return false
+}
+
+// resume starts the clock again and recomputes the near-breach and the due time with the remaining target.
+func (t *Timer) resume(bh BusinessHours, p Policy, now time.Time) {
+	resumedAt := now.UTC()
+	remaining := time.Duration(t.TargetSecs-t.ElapsedSecs) * time.Second
+	due := bh.Add(resumedAt, remaining).UTC()
+	t.State = StateRunning
+	t.ResumedAt = &resumedAt
+	t.DueAt = &due
+	if !t.Warned {
+		nearSecs := t.TargetSecs*int64(p.NearBreachPct)/100 - t.ElapsedSecs
+		if nearSecs < 0 {
+			nearSecs = 0
+		}
+		near := bh.Add(resumedAt, time.Duration(nearSecs)*time.Second).UTC()
+		t.NearAt = &near
+	}
+}
+
+// pause stops the clock and keeps the business time consumed so far.
+func (t *Timer) pause(bh BusinessHours, now time.Time) {
+	if t.ResumedAt != nil {
+		t.ElapsedSecs += int64(bh.Elapsed(*t.ResumedAt, now) / time.Second)
+	}
+	t.State = StatePaused
+	t.ResumedAt = nil
+	t.NearAt = nil
+	t.DueAt = nil
+}
+
+func (t *Timer) met(now time.Time) {
+	metAt := now.UTC()
+	t.State = StateMet
+	t.MetAt = &metAt
+}
+
+func (t Timer) status() string {
+	switch t.State {
+	case StatePaused:
+		return StatusPaused
+	case StateMet:
+		return StatusMet
+	case StateBreached:
+		return StatusBreached
+	}
+	if t.Warned {
+		return StatusNearBreach
+	}
+	return StatusOnTrack
+}
@@ -0,0 +1,203 @@
+package slack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	apiURL       = "https://slack.com/api/"
+	authorizeURL = "https://slack.com/oauth/v2/authorize"
+)
+
+// BotScopes are the scopes the app asks for when installed to the workspace.
+var BotScopes = []string{"commands", "chat:write", "links:read", "links:write", "users:read", "users:read.email"}
+
+// Response types of the messages sent to the response url.
+const (
+	ResponseEphemeral = "ephemeral"
+	ResponseInChannel = "in_channel"
+)
+
+// Message is the message posted to the channel or sent to the response url of the command and the interaction.
+type Message struct {
+	Channel         string  `json:"channel,omitempty"`
+	Text            string  `json:"text"`
+	Blocks          []Block `json:"blocks,omitempty"`
+	ThreadTs        string  `json:"thread_ts,omitempty"`
+	ResponseType    string  `json:"response_type,omitempty"`
+	ReplaceOriginal bool    `json:"replace_original,omitempty"`
+}
+
+// Unfurl is the preview of the link shared in the message.
+type Unfurl struct {
+	Blocks []Block `json:"blocks"`
+}
+
+// Install is the workspace the app got installed to.
+type Install struct {
+	TeamID    string
+	BotToken  string
+	BotUserID string
+}
+
+// API calls the web API of slack with the bot token of the workspace. The base url is the one of slack when empty.
+type API struct {
+	Token   string
+	BaseURL string
+	Client  *http.Client
+}
+
+// PostMessage posts the message to the channel and returns its ts.
+func (a API) PostMessage(m Message) (string, error) {
+	var res struct {
+		Ts string `json:"ts"`
+	}
+	if err := a.call("chat.postMessage", m, &res); err != nil {
+		return "", err
+	}
+	return res.Ts, nil
+}
+
+// Unfurl previews the links of the message.
+func (a API) Unfurl(channel, ts string, unfurls map[string]Unfurl) error {
+	body := map[string]interface{}{
+		"channel": channel,
+		"ts":      ts,
+		"unfurls": unfurls,
+	}
+	return a.call("chat.unfurl", body, nil)
+}
+
+// UserEmail gets the email of the slack user.
+func (a API) UserEmail(userID string) (string, error) {
+	var res struct {
+		User struct {
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+		} `json:"user"`
+	}
+	if err := a.form("users.info", url.Values{"user": {userID}}, &res); err != nil {
+		return "", err
+	}
+	return res.User.Profile.Email, nil
+}
+
+// Permalink gets the link to the message.
+func (a API) Permalink(channel, ts string) (string, error) {
+	var res struct {
+		Permalink string `json:"permalink"`
+	}
+	if err := a.form("chat.getPermalink", url.Values{"channel": {channel}, "message_ts": {ts}}, &res); err != nil {
+		return "", err
+	}
+	return res.Permalink, nil
+}
+
+// OAuthAccess exchanges the code of the install for the bot token of the workspace.
+func (a API) OAuthAccess(clientID, clientSecret, code string) (Install, error) {
+	var res struct {
+		AccessToken string `json:"access_token"`
+		BotUserID   string `json:"bot_user_id"`
+		Team        Ref    `json:"team"`
+	}
+	v := url.Values{"client_id": {clientID}, "client_secret": {clientSecret}, "code": {code}}
+	if err := a.form("oauth.v2.access", v, &res); err != nil {
+		return Install{}, err
+	}
+	return Install{TeamID: res.Team.ID, BotToken: res.AccessToken, BotUserID: res.BotUserID}, nil
+}
+
+// AuthorizeURL is the url installing the app to the workspace. The state comes back with the code.
+func AuthorizeURL(clientID, state string) string {
+	v := url.Values{"client_id": {clientID}, "scope": {strings.Join(BotScopes, ",")}, "state": {state}}
+	return authorizeURL + "?" + v.Encode()
+}
+
+// Respond sends the message to the response url of the command or the interaction.
+func Respond(responseURL string, m Message) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err, "encode slack response")
+	}
+	resp, err := httpClient(nil).Post(responseURL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "sending slack response")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("sending slack response: %s", resp.Status)
+	}
+	return nil
+}
+
+func (a API) call(method string, body, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrapf(err, "encode slack %s", method)
+	}
+	req, err := http.NewRequest(http.MethodPost, a.url(method), bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrapf(err, "calling slack %s", method)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	return a.do(method, req, out)
+}
+
+func (a API) form(method string, v url.Values, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, a.url(method), strings.NewReader(v.Encode()))
+	if err != nil {
+		return errors.Wrapf(err, "calling slack %s", method)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return a.do(method, req, out)
+}
+
+func (a API) do(method string, req *http.Request, out interface{}) error {
+	if a.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.Token))
+	}
+	resp, err := httpClient(a.Client).Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "calling slack %s", method)
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return errors.Wrapf(err, "reading slack %s", method)
+	}
+	var sr SlackViewResponse
+	if err := json.Unmarshal(raw, &sr); err != nil {
+		return errors.Wrapf(err, "reading slack %s", method)
+	}
+	if !sr.Ok {
+		return fmt.Errorf("slack %s responded with error %s", method, sr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+func (a API) url(method string) string {
+	if a.BaseURL == "" {
+		return apiURL + method
+	}
+	return strings.TrimSuffix(a.BaseURL, "/") + "/" + method
+}
+
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return &http.Client{Timeout: 10 * time.Second}
+	}
+	return c
+}
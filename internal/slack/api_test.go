@@ -0,0 +1,87 @@
+package slack_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestSlackAPI(t *testing.T) {
+	var got struct {
+		path, auth, contentType string
+		body                    map[string]interface{}
+		form                    url.Values
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path, got.auth, got.contentType = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		switch r.URL.Path {
+		case "/chat.postMessage":
+			json.NewDecoder(r.Body).Decode(&got.body)
+			w.Write([]byte(`{"ok":true,"ts":"1700000000.000100"}`))
+		case "/users.info":
+			r.ParseForm()
+			got.form = r.PostForm
+			w.Write([]byte(`{"ok":true,"user":{"id":"U1","profile":{"email":"jo@acme.com"}}}`))
+		default:
+			w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+		}
+	}))
+	defer ts.Close()
+	api := slack.API{Token: "xoxb-test", BaseURL: ts.URL}
+
+	t.Log("Given the need to call the web API of slack with the bot token")
+	{
+		msg := slack.Message{Channel: "C1", Text: "Ticket created", Blocks: []slack.Block{slack.Section(slack.Markdown("*Printer down*"))}}
+		sent, err := api.PostMessage(msg)
+		if err != nil || sent != "1700000000.000100" {
+			t.Fatalf("\t%s should post the message : %v", tests.Failed, err)
+		}
+		if got.auth != "Bearer xoxb-test" || got.body["channel"] != "C1" || len(got.body["blocks"].([]interface{})) != 1 {
+			t.Fatalf("\t%s should post the blocks with the token : %s %+v", tests.Failed, got.auth, got.body)
+		}
+		t.Logf("\t%s should post the message", tests.Success)
+
+		email, err := api.UserEmail("U1")
+		if err != nil || email != "jo@acme.com" || got.form.Get("user") != "U1" || got.contentType != "application/x-www-form-urlencoded" {
+			t.Fatalf("\t%s should read the email of the user : %v %q", tests.Failed, err, email)
+		}
+		t.Logf("\t%s should read the email of the user", tests.Success)
+
+		if err := api.Unfurl("C404", "1.2", map[string]slack.Unfurl{}); err == nil {
+			t.Fatalf("\t%s should return the error slack responded with", tests.Failed)
+		}
+		t.Logf("\t%s should return the error slack responded with", tests.Success)
+	}
+}
+
+func TestSlackRequests(t *testing.T) {
+	t.Log("Given the need to read the commands and the interactions slack posts")
+	{
+		c := slack.ParseCommand(url.Values{"team_id": {"T1"}, "user_id": {"U1"}, "command": {"/relay"}, "text": {"create tickets Printer down"}})
+		if c.TeamID != "T1" || c.UserID != "U1" || c.Text != "create tickets Printer down" {
+			t.Fatalf("\t%s should read the command : %+v", tests.Failed, c)
+		}
+		t.Logf("\t%s should read the command", tests.Success)
+
+		payload := `{"type":"block_actions","team":{"id":"T1"},"user":{"id":"U1"},"response_url":"https://hooks.slack.com/actions/1",
+			"actions":[{"action_id":"relay_status","block_id":"a/e/i","selected_option":{"text":{"type":"plain_text","text":"Closed"},"value":"s-2"}}]}`
+		in, err := slack.ParseInteraction(url.Values{"payload": {payload}})
+		if err != nil || in.Type != slack.InteractionBlockActions || in.Team.ID != "T1" || len(in.Actions) != 1 {
+			t.Fatalf("\t%s should read the interaction : %v %+v", tests.Failed, err, in)
+		}
+		if got := in.Actions[0].Selected(); got != "s-2" {
+			t.Fatalf("\t%s should read the option selected : %q", tests.Failed, got)
+		}
+		t.Logf("\t%s should read the interaction", tests.Success)
+
+		if _, err := slack.ParseInteraction(url.Values{"payload": {"{"}}); err == nil {
+			t.Fatalf("\t%s should reject the broken payload", tests.Failed)
+		}
+		t.Logf("\t%s should reject the broken payload", tests.Success)
+	}
+}
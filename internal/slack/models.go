@@ -1,5 +1,12 @@
 package slack
 
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
 type Slack struct {
 	BotToken string
 	Payload
@@ -10,12 +17,14 @@ type Payload struct {
 	TeamID   string `json:"team_id"`
 	APIAppID string `json:"api_app_id"`
 	Event    struct {
-		Type    string `json:"type"`
-		User    string `json:"user"`
-		Text    string `json:"text"`
-		Ts      string `json:"ts"`
-		Channel string `json:"channel"`
-		EventTs string `json:"event_ts"`
+		Type      string `json:"type"`
+		User      string `json:"user"`
+		Text      string `json:"text"`
+		Ts        string `json:"ts"`
+		Channel   string `json:"channel"`
+		EventTs   string `json:"event_ts"`
+		MessageTs string `json:"message_ts"` // the message of the shared links
+		Links     []Link `json:"links"`
 	} `json:"event"`
 	Type        string   `json:"type"`
 	EventID     string   `json:"event_id"`
@@ -24,8 +33,96 @@ type Payload struct {
 	Challenge   string   `json:"challenge"`
 }
 
+// Link is the link shared in the message to be unfurled.
+type Link struct {
+	Domain string `json:"domain"`
+	URL    string `json:"url"`
+}
+
 type SlackViewResponse struct {
 	Ok      bool   `json:"ok"`
 	Error   string `json:"error"`
 	Warning string `json:"warning"`
 }
+
+// Command is the slash command posted by slack as the form.
+type Command struct {
+	TeamID      string
+	UserID      string
+	ChannelID   string
+	Command     string
+	Text        string
+	ResponseURL string
+	TriggerID   string
+}
+
+// ParseCommand reads the slash command from the posted form.
+func ParseCommand(form url.Values) Command {
+	return Command{
+		TeamID:      form.Get("team_id"),
+		UserID:      form.Get("user_id"),
+		ChannelID:   form.Get("channel_id"),
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+	}
+}
+
+// Interaction types
+const (
+	InteractionBlockActions  = "block_actions"
+	InteractionMessageAction = "message_action"
+)
+
+// Ref is the team, the user or the channel in the interaction.
+type Ref struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Interaction is the click on the block action or the message shortcut.
+type Interaction struct {
+	Type        string   `json:"type"`
+	Team        Ref      `json:"team"`
+	User        Ref      `json:"user"`
+	Channel     Ref      `json:"channel"`
+	CallbackID  string   `json:"callback_id"`
+	TriggerID   string   `json:"trigger_id"`
+	ResponseURL string   `json:"response_url"`
+	Message     Posted   `json:"message"`
+	Actions     []Action `json:"actions"`
+}
+
+// Posted is the message the interaction happened on.
+type Posted struct {
+	Ts       string `json:"ts"`
+	ThreadTs string `json:"thread_ts"`
+	User     string `json:"user"`
+	Text     string `json:"text"`
+}
+
+// Action is the button clicked or the option selected.
+type Action struct {
+	ActionID       string  `json:"action_id"`
+	BlockID        string  `json:"block_id"`
+	Value          string  `json:"value"`
+	SelectedOption *Option `json:"selected_option"`
+}
+
+// Selected returns the value of the button or of the option selected.
+func (a Action) Selected() string {
+	if a.SelectedOption != nil {
+		return a.SelectedOption.Value
+	}
+	return a.Value
+}
+
+// ParseInteraction reads the interaction from the payload of the posted form.
+func ParseInteraction(form url.Values) (Interaction, error) {
+	var in Interaction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &in); err != nil {
+		return Interaction{}, errors.Wrap(err, "unmarshal slack interaction")
+	}
+	return in, nil
+}
@@ -8,6 +8,7 @@ const (
 	EventTypeHomeOpened string = "app_home_opened"
 	EventTypeMention    string = "app_mention"
 	EventTypeMessage    string = "message"
+	EventTypeLinkShared string = "link_shared"
 )
 
 func (s Slack) Call() error {
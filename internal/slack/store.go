@@ -0,0 +1,142 @@
+package slack
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrWorkspaceNotFound is used when the app is not installed to the slack workspace.
+	ErrWorkspaceNotFound = errors.New("Slack workspace not found")
+	// ErrChannelNotFound is used when the entity is not posting to any channel.
+	ErrChannelNotFound = errors.New("Slack channel not found")
+)
+
+// Workspace is the slack workspace the app got installed to for the account.
+type Workspace struct {
+	TeamID      string    `db:"team_id" json:"team_id"`
+	AccountID   string    `db:"account_id" json:"account_id"`
+	BotToken    string    `db:"bot_token" json:"-"`
+	BotUserID   string    `db:"bot_user_id" json:"bot_user_id"`
+	InstalledBy string    `db:"installed_by" json:"installed_by"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+// Channel is the slack channel the items of the entity are notified to.
+type Channel struct {
+	AccountID string    `db:"account_id" json:"account_id"`
+	EntityID  string    `db:"entity_id" json:"entity_id"`
+	ChannelID string    `db:"channel_id" json:"channel_id"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// SaveWorkspace inserts the workspace or updates the token of the one installed again.
+func SaveWorkspace(ctx context.Context, db *sqlx.DB, w Workspace) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.SaveWorkspace")
+	defer span.End()
+
+	const q = `INSERT INTO slack_workspaces
+		(team_id, account_id, bot_token, bot_user_id, installed_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (team_id) DO UPDATE SET
+		account_id = $2, bot_token = $3, bot_user_id = $4, installed_by = $5`
+
+	if _, err := db.ExecContext(ctx, q, w.TeamID, w.AccountID, w.BotToken, w.BotUserID, w.InstalledBy, w.CreatedAt.UTC()); err != nil {
+		return errors.Wrapf(err, "saving slack workspace %q", w.TeamID)
+	}
+	return nil
+}
+
+// RetrieveWorkspace gets the workspace of the slack team.
+func RetrieveWorkspace(ctx context.Context, db *sqlx.DB, teamID string) (Workspace, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.RetrieveWorkspace")
+	defer span.End()
+
+	var w Workspace
+	const q = `SELECT * FROM slack_workspaces WHERE team_id = $1`
+	if err := db.GetContext(ctx, &w, q, teamID); err != nil {
+		if err == sql.ErrNoRows {
+			return Workspace{}, ErrWorkspaceNotFound
+		}
+		return Workspace{}, errors.Wrapf(err, "selecting slack workspace %q", teamID)
+	}
+	return w, nil
+}
+
+// AccountWorkspace gets the workspace the account installed the app to.
+func AccountWorkspace(ctx context.Context, db *sqlx.DB, accountID string) (Workspace, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.AccountWorkspace")
+	defer span.End()
+
+	var w Workspace
+	const q = `SELECT * FROM slack_workspaces WHERE account_id = $1 ORDER BY created_at DESC LIMIT 1`
+	if err := db.GetContext(ctx, &w, q, accountID); err != nil {
+		if err == sql.ErrNoRows {
+			return Workspace{}, ErrWorkspaceNotFound
+		}
+		return Workspace{}, errors.Wrap(err, "selecting slack workspace of account")
+	}
+	return w, nil
+}
+
+// SaveChannel sets the channel of the entity.
+func SaveChannel(ctx context.Context, db *sqlx.DB, c Channel) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.SaveChannel")
+	defer span.End()
+
+	const q = `INSERT INTO slack_channels
+		(account_id, entity_id, channel_id, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id, entity_id) DO UPDATE SET channel_id = $3`
+
+	if _, err := db.ExecContext(ctx, q, c.AccountID, c.EntityID, c.ChannelID, c.CreatedAt.UTC()); err != nil {
+		return errors.Wrapf(err, "saving slack channel of entity %q", c.EntityID)
+	}
+	return nil
+}
+
+// RetrieveChannel gets the channel of the entity.
+func RetrieveChannel(ctx context.Context, db *sqlx.DB, accountID, entityID string) (Channel, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.RetrieveChannel")
+	defer span.End()
+
+	var c Channel
+	const q = `SELECT * FROM slack_channels WHERE account_id = $1 AND entity_id = $2`
+	if err := db.GetContext(ctx, &c, q, accountID, entityID); err != nil {
+		if err == sql.ErrNoRows {
+			return Channel{}, ErrChannelNotFound
+		}
+		return Channel{}, errors.Wrapf(err, "selecting slack channel of entity %q", entityID)
+	}
+	return c, nil
+}
+
+// Channels gets the channels of the entities of the account.
+func Channels(ctx context.Context, db *sqlx.DB, accountID string) ([]Channel, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.Channels")
+	defer span.End()
+
+	channels := []Channel{}
+	const q = `SELECT * FROM slack_channels WHERE account_id = $1`
+	if err := db.SelectContext(ctx, &channels, q, accountID); err != nil {
+		return nil, errors.Wrap(err, "selecting slack channels")
+	}
+	return channels, nil
+}
+
+// DeleteChannel stops notifying the entity to its channel.
+func DeleteChannel(ctx context.Context, db *sqlx.DB, accountID, entityID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slack.DeleteChannel")
+	defer span.End()
+
+	const q = `DELETE FROM slack_channels WHERE account_id = $1 AND entity_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, entityID); err != nil {
+		return errors.Wrapf(err, "deleting slack channel of entity %q", entityID)
+	}
+	return nil
+}
@@ -20,15 +20,30 @@ type Text struct {
 	Emoji bool   `json:"emoji,omitempty"`
 }
 
+// Option is the choice of the select menu.
+type Option struct {
+	Text  Text   `json:"text"`
+	Value string `json:"value"`
+}
+
 type Element struct {
-	Type string `json:"type"`
-	Text Text   `json:"text"`
+	Type        string   `json:"type"`
+	Text        *Text    `json:"text,omitempty"`
+	ActionID    string   `json:"action_id,omitempty"`
+	Value       string   `json:"value,omitempty"`
+	Style       string   `json:"style,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Placeholder *Text    `json:"placeholder,omitempty"`
+	Options     []Option `json:"options,omitempty"`
 }
 
 type Block struct {
-	Type     string    `json:"type"`
-	Text     *Text     `json:"text,omitempty"`
-	Elements []Element `json:"elements,omitempty"`
+	Type      string    `json:"type"`
+	BlockID   string    `json:"block_id,omitempty"`
+	Text      *Text     `json:"text,omitempty"`
+	Fields    []Text    `json:"fields,omitempty"`
+	Accessory *Element  `json:"accessory,omitempty"`
+	Elements  []Element `json:"elements,omitempty"`
 }
 
 type View struct {
@@ -41,46 +56,56 @@ type HomeView struct {
 	View   View   `json:"view"`
 }
 
-func MakeHomeView(userID string) HomeView {
+// MakeHomeView makes the home tab of the user with the guide of the app followed by the blocks.
+func MakeHomeView(userID string, blocks ...Block) HomeView {
 	return HomeView{
 		UserID: userID,
 		View: View{
 			Type: "home",
-			Blocks: []Block{
-				{
-					Type: "section",
-					Text: &Text{
-						Type: "mrkdwn",
-						Text: "A simple stack of blocks for the simple sample Block Kit Home tab.",
-					},
-				},
-				{
-					Type: "actions",
-					Text: nil,
-					Elements: []Element{
-						{
-							Type: "button",
-							Text: Text{
-								Type:  "plain_text",
-								Text:  "Action A",
-								Emoji: true,
-							},
-						},
-						{
-							Type: "button",
-							Text: Text{
-								Type:  "plain_text",
-								Text:  "Action B",
-								Emoji: true,
-							},
-						},
-					},
-				},
-			},
+			Blocks: append([]Block{
+				Section(Markdown("*Relay* keeps your tickets, tasks and incidents one slash away.")),
+				Section(Markdown("`/relay create <entity> <title>` creates the item\n`/relay search <entity> <term>` finds the items\nUse the *Create ticket* or *Create task* shortcut on any message to track it in Relay.")),
+				{Type: "divider"},
+			}, blocks...),
 		},
 	}
 }
 
+// Markdown is the text formatted with the slack mrkdwn.
+func Markdown(text string) *Text {
+	return &Text{Type: "mrkdwn", Text: text}
+}
+
+// Plain is the plain text.
+func Plain(text string) *Text {
+	return &Text{Type: "plain_text", Text: text, Emoji: true}
+}
+
+// Section is the block of text.
+func Section(text *Text) Block {
+	return Block{Type: "section", Text: text}
+}
+
+// Actions is the block of the buttons and the menus.
+func Actions(blockID string, elements ...Element) Block {
+	return Block{Type: "actions", BlockID: blockID, Elements: elements}
+}
+
+// Button is the button posting the value on click.
+func Button(actionID, label, value, style string) Element {
+	return Element{Type: "button", Text: Plain(label), ActionID: actionID, Value: value, Style: style}
+}
+
+// LinkButton is the button opening the url.
+func LinkButton(actionID, label, url string) Element {
+	return Element{Type: "button", Text: Plain(label), ActionID: actionID, URL: url}
+}
+
+// Select is the menu posting the value of the option selected.
+func Select(actionID, placeholder string, options []Option) Element {
+	return Element{Type: "static_select", Placeholder: Plain(placeholder), ActionID: actionID, Options: options}
+}
+
 func UpdateHomeView(botToken string, hv HomeView) error {
 	client := &http.Client{
 		Timeout: time.Second * 10,
@@ -0,0 +1,548 @@
+// Package slackapp answers the slash commands, the interactions and the events of the slack workspaces the
+// relay app is installed to, and notifies the items of the entities to their channels.
+package slackapp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/conversation"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/platform/stream"
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotInstalled is used when the slack workspace is not connected to any account.
+	ErrNotInstalled = errors.New("Relay is not installed to this slack workspace. Connect it from the integrations of your account")
+	// ErrUnknownUser is used when the email of the slack user is not the one of any user of the account.
+	ErrUnknownUser = errors.New("Your slack email is not the one of any user of the relay account")
+	// ErrUnknownEntity is used when the entity named in the command does not exist.
+	ErrUnknownEntity = errors.New("No such entity in the relay account. Try `/relay help`")
+	// ErrNotAllowed is used when the item on the card is not the one of the account of the workspace.
+	ErrNotAllowed = errors.New("This item belongs to another relay account")
+	// ErrForbidden is used when the role of the user in the account does not let them change the item.
+	ErrForbidden = errors.New("You do not have the access to change this item in relay")
+)
+
+const searchLimit = 5
+
+// App is the relay app of the slack workspaces. Stream sends the items created or updated to the job.
+type App struct {
+	DB     *sqlx.DB
+	SDB    *database.SecDB
+	Stream func(*stream.Message) error
+}
+
+// session is the relay user behind the slack user of the workspace.
+type session struct {
+	ws  slack.Workspace
+	api slack.API
+	usr user.User
+}
+
+// Command runs the slash command and returns the response shown to the user.
+func (a App) Command(ctx context.Context, c slack.Command) (slack.Message, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.slackapp.Command")
+	defer span.End()
+
+	s, err := a.session(ctx, c.TeamID, c.UserID)
+	if err != nil {
+		return reply(err)
+	}
+	return a.run(ctx, s, c.Text)
+}
+
+// Mention runs the text following the mention of the bot as the command and replies in the thread.
+func (a App) Mention(ctx context.Context, p slack.Payload) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slackapp.Mention")
+	defer span.End()
+
+	s, err := a.session(ctx, p.TeamID, p.Event.User)
+	if err == ErrNotInstalled {
+		return nil
+	}
+	var msg slack.Message
+	if err != nil {
+		msg, err = reply(err)
+	} else {
+		msg, err = a.run(ctx, s, stripMention(p.Event.Text))
+	}
+	if err != nil {
+		return err
+	}
+	msg.Channel, msg.ThreadTs, msg.ResponseType = p.Event.Channel, p.Event.Ts, ""
+	_, err = s.api.PostMessage(msg)
+	return err
+}
+
+func (a App) run(ctx context.Context, s session, text string) (slack.Message, error) {
+	verb, name, rest := parseCommand(text)
+	switch verb {
+	case "create":
+		if rest == "" {
+			return ephemeral("Tell the title of the item: `/relay create <entity> <title>`"), nil
+		}
+		e, err := a.entity(ctx, s.ws.AccountID, name)
+		if err != nil {
+			return reply(err)
+		}
+		it, err := a.create(ctx, s, e, rest, "")
+		if err != nil {
+			return reply(err)
+		}
+		c, err := a.card(ctx, e, it)
+		if err != nil {
+			return reply(err)
+		}
+		subject := fmt.Sprintf("%s created by %s", e.DisplayName, s.name())
+		return slack.Message{Text: c.text(subject), Blocks: c.blocks("", true), ResponseType: slack.ResponseInChannel}, nil
+	case "search":
+		e, err := a.entity(ctx, s.ws.AccountID, name)
+		if err != nil {
+			return reply(err)
+		}
+		items, err := item.Search(ctx, s.ws.AccountID, e.ID, titleKey(e), rest, searchLimit, a.DB)
+		if err != nil {
+			return reply(err)
+		}
+		if len(items) == 0 {
+			return ephemeral(fmt.Sprintf("No %s matching _%s_", e.DisplayName, escape(rest))), nil
+		}
+		blocks := make([]slack.Block, 0)
+		for _, it := range items {
+			c, err := a.card(ctx, e, it)
+			if err != nil {
+				return reply(err)
+			}
+			blocks = append(blocks, c.blocks("", false)...)
+			blocks = append(blocks, slack.Block{Type: "divider"})
+		}
+		msg := ephemeral(fmt.Sprintf("%d %s matching %s", len(items), e.DisplayName, rest))
+		msg.Blocks = blocks
+		return msg, nil
+	}
+	return ephemeral("`/relay create <entity> <title>` creates the item\n`/relay search <entity> <term>` finds the items of the entity by their title"), nil
+}
+
+// Interact acts on the buttons and the shortcuts and tells the result through the response url.
+func (a App) Interact(ctx context.Context, in slack.Interaction) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slackapp.Interact")
+	defer span.End()
+
+	msg, err := a.interact(ctx, in)
+	if err != nil {
+		if msg, err = reply(err); err != nil {
+			return err
+		}
+	}
+	if in.ResponseURL == "" || (msg.Text == "" && len(msg.Blocks) == 0) {
+		return nil
+	}
+	return slack.Respond(in.ResponseURL, msg)
+}
+
+func (a App) interact(ctx context.Context, in slack.Interaction) (slack.Message, error) {
+	s, err := a.session(ctx, in.Team.ID, in.User.ID)
+	if err != nil {
+		return slack.Message{}, err
+	}
+
+	switch in.Type {
+	case slack.InteractionMessageAction:
+		return a.shortcut(ctx, s, in)
+	case slack.InteractionBlockActions:
+		for _, act := range in.Actions {
+			if act.ActionID == ActionOpen {
+				continue
+			}
+			r, ok := parseRef(act.BlockID)
+			if !ok {
+				continue
+			}
+			if r.AccountID != s.ws.AccountID {
+				return slack.Message{}, ErrNotAllowed
+			}
+			msg, err := a.act(ctx, s, r, act)
+			if err != nil {
+				return slack.Message{}, err
+			}
+			return msg, nil
+		}
+	}
+	return slack.Message{}, nil
+}
+
+// act changes the item on the card and returns the card updated to replace the original one.
+func (a App) act(ctx context.Context, s session, r ref, act slack.Action) (slack.Message, error) {
+	e, err := entity.Retrieve(ctx, r.AccountID, r.EntityID, a.DB, a.SDB)
+	if err != nil {
+		return slack.Message{}, err
+	}
+	it, err := item.Retrieve(ctx, r.AccountID, r.EntityID, r.ItemID, a.DB)
+	if err != nil {
+		return slack.Message{}, err
+	}
+	if !permitted(e, it, s.usr) {
+		return slack.Message{}, ErrForbidden
+	}
+
+	var subject string
+	switch act.ActionID {
+	case ActionAssign:
+		f := e.WhoField(entity.WhoAssignee)
+		if f.Key == "" {
+			return ephemeral(fmt.Sprintf("The %s cannot be assigned", e.DisplayName)), nil
+		}
+		it, err = a.update(ctx, s, e, it, f.Key, []interface{}{s.usr.MemberID})
+		subject = fmt.Sprintf("Assigned to %s", s.name())
+	case ActionStatus:
+		var (
+			key    string
+			stages []stage
+		)
+		key, stages, err = a.stages(ctx, e, it)
+		if err != nil {
+			return slack.Message{}, err
+		}
+		// the selected option comes from the client, only the stages of the item are taken.
+		if key == "" || byID(stages, act.Selected()).ID == "" {
+			return ephemeral(fmt.Sprintf("The status of the %s cannot be changed to that", e.DisplayName)), nil
+		}
+		it, err = a.update(ctx, s, e, it, key, []interface{}{act.Selected()})
+		subject = fmt.Sprintf("Status changed by %s", s.name())
+	case ActionAck:
+		var (
+			key    string
+			stages []stage
+		)
+		key, stages, err = a.stages(ctx, e, it)
+		if err != nil {
+			return slack.Message{}, err
+		}
+		ack := byExpression(stages, expAcknowledged)
+		if ack.ID == "" || e.NodeField() == nil || key != e.NodeField().Key {
+			return ephemeral(fmt.Sprintf("The %s cannot be acknowledged", e.DisplayName)), nil
+		}
+		it, err = a.update(ctx, s, e, it, key, []interface{}{ack.ID})
+		subject = fmt.Sprintf("Acknowledged by %s", s.name())
+	default:
+		return slack.Message{}, nil
+	}
+	if err != nil {
+		return slack.Message{}, err
+	}
+
+	c, err := a.card(ctx, e, it)
+	if err != nil {
+		return slack.Message{}, err
+	}
+	return slack.Message{Text: c.text(subject), Blocks: c.blocks(subject, true), ReplaceOriginal: true}, nil
+}
+
+// permitted tells the user changes the item from slack as the api would let them. The visitors change nothing,
+// the users only the public items they created or are assigned to, the admins and the members every item.
+func permitted(e entity.Entity, it item.Item, usr user.User) bool {
+	roles := []string(usr.Roles)
+	if len(roles) == 0 || auth.IsRoleVisitor(roles) {
+		return false
+	}
+	for _, r := range roles {
+		if r == auth.RoleAdmin || r == auth.RoleMember {
+			return true
+		}
+	}
+	if !it.IsPublic {
+		return false
+	}
+	if it.UserID != nil && *it.UserID == usr.ID {
+		return true
+	}
+	if f := e.WhoField(entity.WhoAssignee); f.Key != "" {
+		assignees, _ := it.Fields()[f.Key].([]interface{})
+		for _, v := range assignees {
+			if v == usr.MemberID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shortcut turns the message into the ticket or the task, links the message back as the conversation of the
+// item and replies in the thread of the message.
+func (a App) shortcut(ctx context.Context, s session, in slack.Interaction) (slack.Message, error) {
+	name := entity.FixedEntityTickets
+	if in.CallbackID == CallbackTask {
+		name = entity.FixedEntityTask
+	}
+	e, err := entity.RetrieveByName(ctx, s.ws.AccountID, name, a.DB)
+	if err != nil {
+		if err == entity.ErrEntityNotFoundByName {
+			return slack.Message{}, ErrUnknownEntity
+		}
+		return slack.Message{}, err
+	}
+
+	permalink, err := s.api.Permalink(in.Channel.ID, in.Message.Ts)
+	if err != nil {
+		log.Println("***> unexpected error occurred when getting the permalink of the slack message. error:", err)
+	}
+	it, err := a.create(ctx, s, e, title(in.Message.Text), in.Message.Text)
+	if err != nil {
+		return slack.Message{}, err
+	}
+
+	nc := conversation.NewConversation{
+		ID:        uuid.New().String(),
+		AccountID: s.ws.AccountID,
+		EntityID:  e.ID,
+		ItemID:    &it.ID,
+		UserID:    s.usr.ID,
+		Type:      conversation.TypeConvReceived,
+		State:     conversation.StateSent,
+		Message:   strings.TrimSpace(in.Message.Text + "\n" + permalink),
+		Payload: map[string]interface{}{
+			"slack_channel":   in.Channel.ID,
+			"slack_ts":        in.Message.Ts,
+			"slack_permalink": permalink,
+		},
+	}
+	if _, err := conversation.Create(ctx, a.DB, nc, time.Now()); err != nil {
+		return slack.Message{}, err
+	}
+
+	c, err := a.card(ctx, e, it)
+	if err != nil {
+		return slack.Message{}, err
+	}
+	thread := in.Message.ThreadTs
+	if thread == "" {
+		thread = in.Message.Ts
+	}
+	subject := fmt.Sprintf("Tracked as the %s by %s", strings.ToLower(e.DisplayName), s.name())
+	_, err = s.api.PostMessage(slack.Message{Channel: in.Channel.ID, ThreadTs: thread, Text: c.text(subject), Blocks: c.blocks(subject, true)})
+	return slack.Message{}, err
+}
+
+// Unfurl previews the links of the items of the account shared in the workspace.
+func (a App) Unfurl(ctx context.Context, p slack.Payload) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slackapp.Unfurl")
+	defer span.End()
+
+	ws, err := slack.RetrieveWorkspace(ctx, a.DB, p.TeamID)
+	if err != nil {
+		if err == slack.ErrWorkspaceNotFound {
+			return nil
+		}
+		return err
+	}
+
+	unfurls := make(map[string]slack.Unfurl, 0)
+	for _, l := range p.Event.Links {
+		r, ok := parseLink(l.URL)
+		if !ok || r.AccountID != ws.AccountID {
+			continue
+		}
+		e, err := entity.Retrieve(ctx, r.AccountID, r.EntityID, a.DB, a.SDB)
+		if err != nil {
+			continue
+		}
+		it, err := item.Retrieve(ctx, r.AccountID, r.EntityID, r.ItemID, a.DB)
+		if err != nil {
+			continue
+		}
+		c, err := a.card(ctx, e, it)
+		if err != nil {
+			return err
+		}
+		unfurls[l.URL] = slack.Unfurl{Blocks: c.blocks(e.DisplayName, false)}
+	}
+	if len(unfurls) == 0 {
+		return nil
+	}
+	ts := p.Event.MessageTs
+	if ts == "" {
+		ts = p.Event.Ts
+	}
+	return slack.API{Token: ws.BotToken}.Unfurl(p.Event.Channel, ts, unfurls)
+}
+
+// Home publishes the home tab of the user with the items assigned to them in the entities having channels.
+func (a App) Home(ctx context.Context, p slack.Payload) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slackapp.Home")
+	defer span.End()
+
+	s, err := a.session(ctx, p.TeamID, p.Event.User)
+	if err != nil {
+		if err == ErrNotInstalled {
+			return nil
+		}
+		if s.ws.BotToken == "" {
+			return err
+		}
+		return slack.UpdateHomeView(s.ws.BotToken, slack.MakeHomeView(p.Event.User, slack.Section(slack.Markdown(err.Error()))))
+	}
+
+	channels, err := slack.Channels(ctx, a.DB, s.ws.AccountID)
+	if err != nil {
+		return err
+	}
+	blocks := make([]slack.Block, 0)
+	for _, ch := range channels {
+		e, err := entity.Retrieve(ctx, ch.AccountID, ch.EntityID, a.DB, a.SDB)
+		if err != nil {
+			continue
+		}
+		f := e.WhoField(entity.WhoAssignee)
+		if f.Key == "" {
+			continue
+		}
+		items, err := item.Referring(ctx, s.ws.AccountID, e.ID, f.Key, s.usr.MemberID, a.DB)
+		if err != nil {
+			return err
+		}
+		if len(items) > searchLimit {
+			items = items[:searchLimit]
+		}
+		for _, it := range items {
+			c, err := a.card(ctx, e, it)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, c.blocks(e.DisplayName, false)...)
+		}
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, slack.Section(slack.Markdown("Nothing is assigned to you right now.")))
+	}
+	return slack.UpdateHomeView(s.ws.BotToken, slack.MakeHomeView(p.Event.User, blocks...))
+}
+
+// session finds the workspace and the relay user of the slack user by their email.
+func (a App) session(ctx context.Context, teamID, slackUserID string) (session, error) {
+	ws, err := slack.RetrieveWorkspace(ctx, a.DB, teamID)
+	if err != nil {
+		if err == slack.ErrWorkspaceNotFound {
+			return session{}, ErrNotInstalled
+		}
+		return session{}, err
+	}
+	s := session{ws: ws, api: slack.API{Token: ws.BotToken}}
+
+	email, err := s.api.UserEmail(slackUserID)
+	if err != nil {
+		return s, err
+	}
+	if email == "" {
+		return s, ErrUnknownUser
+	}
+	s.usr, err = user.RetrieveUserByUniqIdentifier(ctx, ws.AccountID, email, "", a.DB)
+	if err != nil {
+		return s, ErrUnknownUser
+	}
+	return s, nil
+}
+
+func (s session) name() string {
+	if s.usr.Name != nil && *s.usr.Name != "" {
+		return *s.usr.Name
+	}
+	return s.usr.Email
+}
+
+// entity finds the entity by its name or its display name.
+func (a App) entity(ctx context.Context, accountID, name string) (entity.Entity, error) {
+	if name == "" {
+		return entity.Entity{}, ErrUnknownEntity
+	}
+	e, err := entity.RetrieveByName(ctx, accountID, name, a.DB)
+	if err == nil {
+		return e, nil
+	}
+	if err != entity.ErrEntityNotFoundByName {
+		return entity.Entity{}, err
+	}
+
+	entities, err := entity.AccountEntities(ctx, accountID, []int{entity.CategoryData, entity.CategoryTask, entity.CategoryMeeting}, a.DB)
+	if err != nil {
+		return entity.Entity{}, err
+	}
+	for _, e := range entities {
+		if strings.EqualFold(e.DisplayName, name) || strings.EqualFold(strings.TrimSuffix(e.Name, "s"), name) {
+			return e, nil
+		}
+	}
+	return entity.Entity{}, ErrUnknownEntity
+}
+
+// create creates the item with the title and the description and streams it to the job.
+func (a App) create(ctx context.Context, s session, e entity.Entity, title, desc string) (item.Item, error) {
+	fields := map[string]interface{}{titleKey(e): title}
+	if f := e.WhoField(entity.WhoDesc); f.Key != "" && desc != "" {
+		fields[f.Key] = desc
+	}
+
+	ni := item.NewItem{
+		ID:        uuid.New().String(),
+		AccountID: s.ws.AccountID,
+		EntityID:  e.ID,
+		UserID:    &s.usr.ID,
+		Fields:    fields,
+	}
+	it, err := item.Create(ctx, a.DB, ni, time.Now())
+	if err != nil {
+		return item.Item{}, err
+	}
+	go a.Stream(stream.NewCreteItemMessage(ctx, a.DB, ni.AccountID, s.usr.ID, ni.EntityID, it.ID, ni.Source))
+	return it, nil
+}
+
+// update sets the value of the field of the item and streams the change to the job.
+func (a App) update(ctx context.Context, s session, e entity.Entity, it item.Item, key string, value interface{}) (item.Item, error) {
+	oldFields := it.Fields()
+	newFields := it.Fields()
+	if newFields == nil {
+		newFields = make(map[string]interface{}, 1)
+	}
+	newFields[key] = value
+
+	updated, err := item.UpdateFields(ctx, a.DB, it.AccountID, e.ID, it.ID, newFields)
+	if err != nil {
+		return item.Item{}, errors.Wrapf(err, "updating %s of item %q from slack", key, it.ID)
+	}
+	go a.Stream(stream.NewUpdateItemMessage(ctx, a.DB, it.AccountID, s.usr.ID, e.ID, it.ID, updated.Fields(), oldFields))
+	return updated, nil
+}
+
+func titleKey(e entity.Entity) string {
+	if f := entity.TitleField(e.EasyFields()); f.Key != "" {
+		return f.Key
+	}
+	return e.WhoField(entity.WhoTitle).Key
+}
+
+func ephemeral(text string) slack.Message {
+	return slack.Message{Text: text, ResponseType: slack.ResponseEphemeral}
+}
+
+// reply tells the user the errors they can act on and hides the rest.
+func reply(err error) (slack.Message, error) {
+	switch err {
+	case ErrNotInstalled, ErrUnknownUser, ErrUnknownEntity, ErrNotAllowed, ErrForbidden:
+		return ephemeral(err.Error()), nil
+	}
+	log.Println("***> unexpected error occurred when answering slack. error:", err)
+	return ephemeral("Something went wrong, try again in a bit"), nil
+}
@@ -0,0 +1,46 @@
+package slackapp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+)
+
+func TestPermitted(t *testing.T) {
+	fieldsb, _ := json.Marshal([]entity.Field{{Key: "assignees", DataType: entity.TypeReference, Who: entity.WhoAssignee}})
+	e := entity.Entity{ID: "tickets", Fieldsb: string(fieldsb)}
+	owner := "u-owner"
+	newItem := func(public bool, assignees ...interface{}) item.Item {
+		b, _ := json.Marshal(map[string]interface{}{"assignees": assignees})
+		return item.Item{ID: "it-1", UserID: &owner, IsPublic: public, Fieldsb: string(b)}
+	}
+
+	t.Log("Given the need to change the items from slack as the api lets the user")
+	{
+		cases := []struct {
+			name string
+			it   item.Item
+			usr  user.User
+			want bool
+		}{
+			{"the admin on the private item", newItem(false), user.User{ID: "u-admin", Roles: []string{auth.RoleAdmin}}, true},
+			{"the visitor on the public item", newItem(true), user.User{ID: owner, Roles: []string{auth.RoleVisitor}}, false},
+			{"the user without the roles", newItem(true), user.User{ID: owner}, false},
+			{"the owner on the public item", newItem(true), user.User{ID: owner, Roles: []string{auth.RoleUser}}, true},
+			{"the owner on the private item", newItem(false), user.User{ID: owner, Roles: []string{auth.RoleUser}}, false},
+			{"the assignee on the public item", newItem(true, "m-1"), user.User{ID: "u-1", MemberID: "m-1", Roles: []string{auth.RoleUser}}, true},
+			{"another user on the public item", newItem(true, "m-1"), user.User{ID: "u-2", MemberID: "m-2", Roles: []string{auth.RoleUser}}, false},
+		}
+		for _, c := range cases {
+			if got := permitted(e, c.it, c.usr); got != c.want {
+				t.Fatalf("\t%s should permit %s : %v. got %v", tests.Failed, c.name, c.want, got)
+			}
+		}
+		t.Logf("\t%s should permit the users by their role, the ownership and the visibility of the item", tests.Success)
+	}
+}
@@ -0,0 +1,142 @@
+package slackapp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+)
+
+// Action ids of the buttons and the menus on the cards.
+const (
+	ActionAssign = "relay_assign"
+	ActionStatus = "relay_status"
+	ActionAck    = "relay_ack"
+	ActionOpen   = "relay_open"
+)
+
+// Callback ids of the message shortcuts.
+const (
+	CallbackTicket = "relay_ticket"
+	CallbackTask   = "relay_task"
+)
+
+// expAcknowledged is the expression of the stage the incidents are acknowledged at.
+const expAcknowledged = "acknowledged"
+
+var itemLink = regexp.MustCompile(`/v1/accounts/([^/]+)/teams/[^/]+/entities/([^/]+)/items/([^/?#]+)`)
+
+// ref is the item the card stands for. It is the block id of the actions on the card.
+type ref struct {
+	AccountID string
+	EntityID  string
+	ItemID    string
+}
+
+func (r ref) String() string {
+	return strings.Join([]string{r.AccountID, r.EntityID, r.ItemID}, "/")
+}
+
+// parseRef reads the ref from the block id of the actions.
+func parseRef(s string) (ref, bool) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return ref{}, false
+	}
+	return ref{AccountID: parts[0], EntityID: parts[1], ItemID: parts[2]}, true
+}
+
+// parseLink reads the ref from the link of the item shared in slack.
+func parseLink(url string) (ref, bool) {
+	m := itemLink.FindStringSubmatch(url)
+	if m == nil {
+		return ref{}, false
+	}
+	return ref{AccountID: m[1], EntityID: m[2], ItemID: m[3]}, true
+}
+
+// card is the item as it is shown in slack.
+type card struct {
+	Ref      ref
+	Entity   string
+	Title    string
+	Link     string
+	Fields   [][2]string // the label and the value of the fields shown
+	Statuses []slack.Option
+	Incident bool
+	Acked    bool
+}
+
+// blocks lays the card out with the subject on top. The preview of the shared links leaves the actions out.
+func (c card) blocks(subject string, actions bool) []slack.Block {
+	head := fmt.Sprintf("*<%s|%s>*", c.Link, escape(c.Title))
+	if subject != "" {
+		head = escape(subject) + "\n" + head
+	}
+	blocks := []slack.Block{slack.Section(slack.Markdown(head))}
+
+	if len(c.Fields) > 0 {
+		fields := make([]slack.Text, 0, len(c.Fields))
+		for _, f := range c.Fields {
+			fields = append(fields, *slack.Markdown(fmt.Sprintf("*%s*\n%s", escape(f[0]), escape(f[1]))))
+		}
+		blocks = append(blocks, slack.Block{Type: "section", Fields: fields})
+	}
+
+	if !actions {
+		return blocks
+	}
+	elements := []slack.Element{slack.Button(ActionAssign, "Assign to me", c.Ref.ItemID, "")}
+	if len(c.Statuses) > 0 {
+		elements = append(elements, slack.Select(ActionStatus, "Change status", c.Statuses))
+	}
+	if c.Incident && !c.Acked {
+		elements = append(elements, slack.Button(ActionAck, "Acknowledge", c.Ref.ItemID, "danger"))
+	}
+	elements = append(elements, slack.LinkButton(ActionOpen, "Open in Relay", c.Link))
+	return append(blocks, slack.Actions(c.Ref.String(), elements...))
+}
+
+// text is the fallback of the card for the notifications.
+func (c card) text(subject string) string {
+	if subject == "" {
+		return fmt.Sprintf("%s: %s", c.Entity, c.Title)
+	}
+	return fmt.Sprintf("%s: %s", subject, c.Title)
+}
+
+// parseCommand splits the text of the command into the verb, the entity and the rest.
+func parseCommand(text string) (string, string, string) {
+	parts := strings.Fields(text)
+	switch len(parts) {
+	case 0:
+		return "", "", ""
+	case 1:
+		return strings.ToLower(parts[0]), "", ""
+	}
+	return strings.ToLower(parts[0]), strings.ToLower(parts[1]), strings.Join(parts[2:], " ")
+}
+
+// title is the first line of the message cut to the size of the titles.
+func title(text string) string {
+	t := strings.TrimSpace(strings.SplitN(strings.TrimSpace(text), "\n", 2)[0])
+	if r := []rune(t); len(r) > 80 {
+		return string(r[:79]) + "…"
+	}
+	return t
+}
+
+var mention = regexp.MustCompile(`^\s*<@[A-Z0-9]+>\s*`)
+
+// stripMention removes the mention of the bot the text starts with.
+func stripMention(text string) string {
+	return mention.ReplaceAllString(text, "")
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escape escapes the control characters of the slack mrkdwn.
+func escape(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,86 @@
+package slackapp
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestCardBlocks(t *testing.T) {
+	c := card{
+		Ref:      ref{AccountID: "acc", EntityID: "incidents", ItemID: "it-1"},
+		Entity:   "Incidents",
+		Title:    "Checkout <down>",
+		Link:     "https://acme.relay.app/v1/accounts/acc/teams/t/entities/incidents/items/it-1",
+		Fields:   [][2]string{{"Status", "Triggered"}},
+		Statuses: []slack.Option{{Text: *slack.Plain("Triggered"), Value: "s-1"}, {Text: *slack.Plain("Resolved"), Value: "s-2"}},
+		Incident: true,
+	}
+
+	t.Log("Given the need to show the item as the card with its actions")
+	{
+		blocks := c.blocks("Incidents created", true)
+		if len(blocks) != 3 || blocks[0].Text.Text != "Incidents created\n*<"+c.Link+"|Checkout &lt;down&gt;>*" {
+			t.Fatalf("\t%s should lay out the subject and the escaped title : %+v", tests.Failed, blocks)
+		}
+		actions := blocks[2]
+		if actions.BlockID != "acc/incidents/it-1" {
+			t.Fatalf("\t%s should keep the item in the block id : %q", tests.Failed, actions.BlockID)
+		}
+		ids := []string{}
+		for _, el := range actions.Elements {
+			ids = append(ids, el.ActionID)
+		}
+		if len(ids) != 4 || ids[0] != ActionAssign || ids[1] != ActionStatus || ids[2] != ActionAck || ids[3] != ActionOpen {
+			t.Fatalf("\t%s should offer to assign, change the status and acknowledge : %v", tests.Failed, ids)
+		}
+		t.Logf("\t%s should offer to assign, change the status and acknowledge", tests.Success)
+
+		c.Acked = true
+		if els := c.blocks("", true)[2].Elements; len(els) != 3 {
+			t.Fatalf("\t%s should not acknowledge the incident twice : %+v", tests.Failed, els)
+		}
+		if blocks := c.blocks("", false); len(blocks) != 2 {
+			t.Fatalf("\t%s should leave the actions out of the previews : %+v", tests.Failed, blocks)
+		}
+		t.Logf("\t%s should leave the actions out of the previews", tests.Success)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Log("Given the need to read the refs, the links and the commands")
+	{
+		r, ok := parseRef(ref{AccountID: "a", EntityID: "e", ItemID: "i"}.String())
+		if !ok || r.AccountID != "a" || r.EntityID != "e" || r.ItemID != "i" {
+			t.Fatalf("\t%s should read the ref back : %+v", tests.Failed, r)
+		}
+		if _, ok := parseRef("a/e"); ok {
+			t.Fatalf("\t%s should reject the partial ref", tests.Failed)
+		}
+		t.Logf("\t%s should read the ref back", tests.Success)
+
+		r, ok = parseLink("https://acme.relay.app/v1/accounts/a-1/teams/t-1/entities/e-1/items/i-1?tab=notes")
+		if !ok || r.AccountID != "a-1" || r.EntityID != "e-1" || r.ItemID != "i-1" {
+			t.Fatalf("\t%s should read the item from the link : %+v", tests.Failed, r)
+		}
+		if _, ok := parseLink("https://acme.relay.app/v1/accounts/a-1/teams/t-1"); ok {
+			t.Fatalf("\t%s should skip the links not of the items", tests.Failed)
+		}
+		t.Logf("\t%s should read the item from the link", tests.Success)
+
+		verb, name, rest := parseCommand("  Create Tickets  Printer  on 3rd floor ")
+		if verb != "create" || name != "tickets" || rest != "Printer on 3rd floor" {
+			t.Fatalf("\t%s should split the command : %q %q %q", tests.Failed, verb, name, rest)
+		}
+		if verb, _, _ := parseCommand(stripMention("<@U0RELAY> help")); verb != "help" {
+			t.Fatalf("\t%s should read the command after the mention : %q", tests.Failed, verb)
+		}
+		t.Logf("\t%s should split the command", tests.Success)
+
+		if got := title("Printer on the 3rd floor is jammed\nsince the morning"); got != "Printer on the 3rd floor is jammed" {
+			t.Fatalf("\t%s should take the first line of the message as the title : %q", tests.Failed, got)
+		}
+		t.Logf("\t%s should take the first line of the message as the title", tests.Success)
+	}
+}
@@ -0,0 +1,188 @@
+package slackapp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/rule/node"
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+)
+
+// maxOptions is the most options slack takes in the menu.
+const maxOptions = 100
+
+// stage is the status the item could be moved to.
+type stage struct {
+	ID         string
+	Name       string
+	Expression string
+}
+
+// card reads the item with the labels of its status, its assignees and its priority.
+func (a App) card(ctx context.Context, e entity.Entity, it item.Item) (card, error) {
+	acc, err := account.Retrieve(ctx, a.DB, it.AccountID)
+	if err != nil {
+		return card{}, err
+	}
+
+	c := card{
+		Ref:      ref{AccountID: it.AccountID, EntityID: e.ID, ItemID: it.ID},
+		Entity:   e.DisplayName,
+		Link:     auth.SimpleLink(acc.ID, acc.Domain, e.TeamID, e.ID, it.ID),
+		Incident: e.Name == entity.FixedEntityIncidents,
+	}
+
+	key, stages, err := a.stages(ctx, e, it)
+	if err != nil {
+		return card{}, err
+	}
+	for _, s := range stages {
+		if len(c.Statuses) == maxOptions {
+			break
+		}
+		c.Statuses = append(c.Statuses, slack.Option{Text: *slack.Plain(s.Name), Value: s.ID})
+	}
+
+	tk := titleKey(e)
+	for _, f := range e.ValueAdd(it.Fields()) {
+		switch {
+		case f.Key == tk:
+			if f.Value != nil {
+				c.Title = fmt.Sprint(f.Value)
+			}
+		case f.Key == key:
+			current := byID(stages, first(f.Value))
+			if current.ID != "" {
+				c.Fields = append(c.Fields, [2]string{f.DisplayName, current.Name})
+			}
+			c.Acked = current.Expression != "" && current.Expression != "open" && current.Expression != "triggered"
+		case f.Who == entity.WhoAssignee, f.Who == entity.WhoPriority:
+			if l := a.labels(ctx, it.AccountID, f); l != "" {
+				c.Fields = append(c.Fields, [2]string{f.DisplayName, l})
+			}
+		}
+	}
+	if c.Title == "" {
+		c.Title = "Untitled " + strings.ToLower(e.DisplayName)
+	}
+	c.Incident = c.Incident && byExpression(stages, expAcknowledged).ID != ""
+	return c, nil
+}
+
+// stages returns the key the status of the item is kept in with the statuses it could be moved to. The items
+// in the pipeline move through the stages of their flow, the rest through the items of their status entity.
+func (a App) stages(ctx context.Context, e entity.Entity, it item.Item) (string, []stage, error) {
+	stages := make([]stage, 0)
+	if ff, nf := e.FlowField(), e.NodeField(); ff != nil && nf != nil {
+		flowID := first(it.Fields()[ff.Key])
+		if flowID == "" {
+			return "", stages, nil
+		}
+		nodes, err := node.NodeActorsList(ctx, it.AccountID, flowID, a.DB)
+		if err != nil {
+			return "", nil, err
+		}
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Weight < nodes[j].Weight })
+		for _, n := range nodes {
+			if n.Type == node.Stage {
+				stages = append(stages, stage{ID: n.ID, Name: n.Name, Expression: n.Expression})
+			}
+		}
+		return nf.Key, stages, nil
+	}
+
+	f := e.WhoField(entity.WhoStatus)
+	if f.Key == "" || f.RefID == "" {
+		return "", stages, nil
+	}
+	items, err := item.List(ctx, it.AccountID, f.RefID, a.DB)
+	if err != nil {
+		return "", nil, err
+	}
+	display, err := a.displayKey(ctx, it.AccountID, f)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, s := range items {
+		stages = append(stages, stage{ID: s.ID, Name: label(s, display)})
+	}
+	return f.Key, stages, nil
+}
+
+// labels joins the display values of the items the reference field refers.
+func (a App) labels(ctx context.Context, accountID string, f entity.Field) string {
+	if !f.ValidRefField() {
+		return ""
+	}
+	items, err := item.BulkRetrieveItems(ctx, accountID, f.RefValues(), a.DB)
+	if err != nil {
+		return ""
+	}
+	display, err := a.displayKey(ctx, accountID, f)
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(items))
+	for _, it := range items {
+		names = append(names, label(it, display))
+	}
+	return strings.Join(names, ", ")
+}
+
+// displayKey is the key of the field the items referred are displayed by.
+func (a App) displayKey(ctx context.Context, accountID string, f entity.Field) (string, error) {
+	if key := f.DisplayGex(); key != "" {
+		return key, nil
+	}
+	re, err := entity.Retrieve(ctx, accountID, f.RefID, a.DB, a.SDB)
+	if err != nil {
+		return "", err
+	}
+	return titleKey(re), nil
+}
+
+func label(it item.Item, key string) string {
+	if v, ok := it.Fields()[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	if it.Name != nil {
+		return *it.Name
+	}
+	return it.ID
+}
+
+// byExpression returns the stage having the expression.
+func byExpression(stages []stage, expression string) stage {
+	for _, s := range stages {
+		if s.Expression == expression {
+			return s
+		}
+	}
+	return stage{}
+}
+
+// byID returns the stage having the id.
+func byID(stages []stage, id string) stage {
+	for _, s := range stages {
+		if s.ID == id {
+			return s
+		}
+	}
+	return stage{}
+}
+
+// first is the first id of the reference value.
+func first(v interface{}) string {
+	ids, ok := v.([]interface{})
+	if !ok || len(ids) == 0 {
+		return ""
+	}
+	id, _ := ids[0].(string)
+	return id
+}
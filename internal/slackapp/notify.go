@@ -0,0 +1,43 @@
+package slackapp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/item"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
+	"gitlab.com/vjsideprojects/relay/internal/slack"
+	"go.opencensus.io/trace"
+)
+
+// Notify posts the card of the item to the channel of its entity, when the entity has one and the account
+// installed the app. The verb tells what happened to the item.
+func Notify(ctx context.Context, db *sqlx.DB, sdb *database.SecDB, accountID string, e entity.Entity, it item.Item, verb string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.slackapp.Notify")
+	defer span.End()
+
+	ch, err := slack.RetrieveChannel(ctx, db, accountID, e.ID)
+	if err != nil {
+		if err == slack.ErrChannelNotFound {
+			return nil
+		}
+		return err
+	}
+	ws, err := slack.AccountWorkspace(ctx, db, accountID)
+	if err != nil {
+		if err == slack.ErrWorkspaceNotFound {
+			return nil
+		}
+		return err
+	}
+
+	c, err := App{DB: db, SDB: sdb}.card(ctx, e, it)
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("%s %s", e.DisplayName, verb)
+	_, err = slack.API{Token: ws.BotToken}.PostMessage(slack.Message{Channel: ch.ChannelID, Text: c.text(subject), Blocks: c.blocks(subject, true)})
+	return err
+}
@@ -0,0 +1,50 @@
+package sso
+
+import (
+	"time"
+)
+
+// Types of the identity provider.
+const (
+	TypeOIDC = "oidc"
+	TypeSAML = "saml"
+)
+
+// Provider is the identity provider configured for the account. An account has at most one provider.
+// OIDC providers are configured with the issuer (used for the discovery) and the client credentials.
+// SAML providers are configured with the metadata XML of the IdP.
+type Provider struct {
+	AccountID     string    `db:"account_id" json:"account_id"`
+	Type          string    `db:"type" json:"type"`
+	Issuer        string    `db:"issuer" json:"issuer"`
+	ClientID      string    `db:"client_id" json:"client_id"`
+	ClientSecret  string    `db:"client_secret" json:"-"`
+	Metadata      string    `db:"metadata" json:"metadata"`
+	GroupsClaim   string    `db:"groups_claim" json:"groups_claim"`
+	RoleMappingsb string    `db:"role_mappingsb" json:"-"`
+	DefaultRole   string    `db:"default_role" json:"default_role"`
+	Enforced      bool      `db:"enforced" json:"enforced"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     int64     `db:"updated_at" json:"updated_at"`
+}
+
+// NewProvider has the information needed to configure the identity provider of the account.
+// RoleMappings maps the group names sent by the IdP to the roles of the members.
+type NewProvider struct {
+	Type         string            `json:"type" validate:"required,oneof=oidc saml"`
+	Issuer       string            `json:"issuer"`
+	ClientID     string            `json:"client_id"`
+	ClientSecret string            `json:"client_secret"`
+	Metadata     string            `json:"metadata"`
+	GroupsClaim  string            `json:"groups_claim"`
+	RoleMappings map[string]string `json:"role_mappings"`
+	DefaultRole  string            `json:"default_role"`
+	Enforced     bool              `json:"enforced"`
+}
+
+// Identity is the member identified by the IdP.
+type Identity struct {
+	Email  string
+	Name   string
+	Groups []string
+}
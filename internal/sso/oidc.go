@@ -0,0 +1,214 @@
+package sso
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// discovery is the part of the openid configuration used for the sign in.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// OIDCAuthURL returns the url of the IdP where the member signs in. The nonce is
+// echoed back in the id token to tie it to this sign in.
+func OIDCAuthURL(ctx context.Context, p Provider, redirectURL, state, nonce string) (string, error) {
+	d, err := discover(ctx, p.Issuer)
+	if err != nil {
+		return "", err
+	}
+	return oauthConfig(p, d, redirectURL).AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)), nil
+}
+
+// OIDCExchange exchanges the code sent to the callback for the id token and returns the member identified by it.
+func OIDCExchange(ctx context.Context, p Provider, redirectURL, code, nonce string) (Identity, error) {
+	d, err := discover(ctx, p.Issuer)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	tok, err := oauthConfig(p, d, redirectURL).Exchange(context.WithValue(ctx, oauth2.HTTPClient, httpClient), code)
+	if err != nil {
+		return Identity{}, errors.Wrap(err, "exchanging oidc code")
+	}
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "id token missing in the token response")
+	}
+
+	return verifyIDToken(ctx, p, d, rawIDToken, nonce)
+}
+
+func verifyIDToken(ctx context.Context, p Provider, d discovery, rawIDToken, nonce string) (Identity, error) {
+	keys, err := fetchKeys(ctx, d.JWKSURI)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	parser := jwt.Parser{ValidMethods: []string{"RS256"}}
+	claims := jwt.MapClaims{}
+	_, err = parser.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := keys[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(keys) == 1 {
+			for _, key := range keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	})
+	if err != nil {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, err.Error())
+	}
+
+	if !claims.VerifyIssuer(d.Issuer, true) {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "issuer mismatch")
+	}
+	if !audienceContains(claims["aud"], p.ClientID) {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "audience mismatch")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "nonce mismatch")
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "email is not verified by the identity provider")
+	}
+
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "email missing in the id token")
+	}
+	name, _ := claims["name"].(string)
+
+	groupsClaim := p.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	return Identity{
+		Email:  strings.ToLower(email),
+		Name:   name,
+		Groups: stringList(claims[groupsClaim]),
+	}, nil
+}
+
+func oauthConfig(p Provider, d discovery, redirectURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  redirectURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  d.AuthorizationEndpoint,
+			TokenURL: d.TokenEndpoint,
+		},
+		Scopes: []string{"openid", "email", "profile"},
+	}
+}
+
+func discover(ctx context.Context, issuer string) (discovery, error) {
+	var d discovery
+	wellKnown := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	if err := getJSON(ctx, wellKnown, &d); err != nil {
+		return discovery{}, errors.Wrap(err, "oidc discovery")
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return discovery{}, errors.Wrapf(ErrInvalidProvider, "discovered issuer %q does not match %q", d.Issuer, issuer)
+	}
+	return d, nil
+}
+
+func fetchKeys(ctx context.Context, uri string) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	if err := getJSON(ctx, uri, &set); err != nil {
+		return nil, errors.Wrap(err, "fetching oidc keys")
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	for _, a := range stringList(aud) {
+		if a == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// stringList reads the claim which can either be a string or a list of strings.
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		list := make([]string, 0, len(val))
+		for _, i := range val {
+			if s, ok := i.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return []string{}
+}
@@ -0,0 +1,296 @@
+package sso
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/pkg/errors"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	bindingRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+	statusSuccess   = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	// clockSkew is the drift allowed between the clocks of the IdP and the server.
+	clockSkew = 3 * time.Minute
+)
+
+// emailAttributes are the attribute names used by the common IdPs for the email of the member.
+var emailAttributes = []string{"email", "mail", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+
+// nameAttributes are the attribute names used by the common IdPs for the name of the member.
+var nameAttributes = []string{"name", "displayName", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"}
+
+// idpMetadata is the part of the SAML metadata of the IdP used for the sign in.
+type idpMetadata struct {
+	EntityID string
+	SSOURL   string
+	Certs    []*x509.Certificate
+}
+
+type entityDescriptor struct {
+	EntityID         string `xml:"entityID,attr"`
+	IDPSSODescriptor struct {
+		KeyDescriptors []struct {
+			Use          string   `xml:"use,attr"`
+			Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+		} `xml:"KeyDescriptor"`
+		SingleSignOnServices []struct {
+			Binding  string `xml:"Binding,attr"`
+			Location string `xml:"Location,attr"`
+		} `xml:"SingleSignOnService"`
+	} `xml:"IDPSSODescriptor"`
+}
+
+// SAMLRequest is the AuthnRequest sent to the IdP. The ID is checked against the
+// InResponseTo of the response so that a response cannot be replayed for another sign in.
+type SAMLRequest struct {
+	ID  string
+	URL string
+}
+
+// SAMLAuthRequest builds the AuthnRequest with the redirect binding.
+func SAMLAuthRequest(p Provider, spEntityID, acsURL, relayState string, now time.Time) (SAMLRequest, error) {
+	md, err := parseMetadata(p.Metadata)
+	if err != nil {
+		return SAMLRequest{}, err
+	}
+
+	id := "id-" + strings.Replace(relayState, "-", "", -1)
+	req := fmt.Sprintf(`<samlp:AuthnRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"><saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress" AllowCreate="true"/></samlp:AuthnRequest>`,
+		id, now.UTC().Format(time.RFC3339), xmlEscape(md.SSOURL), xmlEscape(acsURL), xmlEscape(spEntityID))
+
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return SAMLRequest{}, err
+	}
+	if _, err := fw.Write([]byte(req)); err != nil {
+		return SAMLRequest{}, err
+	}
+	if err := fw.Close(); err != nil {
+		return SAMLRequest{}, err
+	}
+
+	u, err := url.Parse(md.SSOURL)
+	if err != nil {
+		return SAMLRequest{}, errors.Wrap(ErrInvalidProvider, err.Error())
+	}
+	q := u.Query()
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(buf.Bytes()))
+	q.Set("RelayState", relayState)
+	u.RawQuery = q.Encode()
+
+	return SAMLRequest{ID: id, URL: u.String()}, nil
+}
+
+// SAMLIdentity verifies the signed response posted by the IdP to the ACS url and returns the member identified by it.
+// Either the response or the assertion should be signed by a certificate from the metadata of the IdP.
+func SAMLIdentity(p Provider, spEntityID, acsURL, requestID, samlResponse string, now time.Time) (Identity, error) {
+	md, err := parseMetadata(p.Metadata)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "response is not base64")
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "response is not xml")
+	}
+	resp := doc.Root()
+	if resp == nil || resp.Tag != "Response" {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "response element missing")
+	}
+
+	vctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: md.Certs})
+	vctx.Clock = dsig.NewFakeClockAt(now)
+
+	// only the elements returned by the validation are trusted, the rest of the document can be tampered.
+	var assertion *etree.Element
+	validResp, err := vctx.Validate(resp)
+	switch err {
+	case nil:
+		resp = validResp
+		assertion = resp.SelectElement("Assertion")
+	case dsig.ErrMissingSignature:
+		assertions := resp.SelectElements("Assertion")
+		if len(assertions) != 1 {
+			return Identity{}, errors.Wrap(ErrInvalidResponse, "expected exactly one assertion")
+		}
+		assertion, err = vctx.Validate(assertions[0])
+		if err != nil {
+			return Identity{}, errors.Wrap(ErrInvalidResponse, err.Error())
+		}
+	default:
+		return Identity{}, errors.Wrap(ErrInvalidResponse, err.Error())
+	}
+	if assertion == nil {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "assertion missing")
+	}
+
+	if status := resp.FindElement("./Status/StatusCode"); status == nil || status.SelectAttrValue("Value", "") != statusSuccess {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "sign in failed at the identity provider")
+	}
+	if d := resp.SelectAttrValue("Destination", acsURL); d != acsURL {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "destination mismatch")
+	}
+	if resp.SelectAttrValue("InResponseTo", "") != requestID {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "response is not for this sign in")
+	}
+	if issuer := assertion.SelectElement("Issuer"); issuer == nil || strings.TrimSpace(issuer.Text()) != md.EntityID {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "issuer mismatch")
+	}
+	if err := checkConditions(assertion, spEntityID, now); err != nil {
+		return Identity{}, err
+	}
+	if err := checkSubject(assertion, acsURL, requestID, now); err != nil {
+		return Identity{}, err
+	}
+
+	attrs := attributes(assertion)
+	email := first(attrs, emailAttributes)
+	if email == "" {
+		if nameID := assertion.FindElement("./Subject/NameID"); nameID != nil && strings.Contains(nameID.Text(), "@") {
+			email = strings.TrimSpace(nameID.Text())
+		}
+	}
+	if email == "" {
+		return Identity{}, errors.Wrap(ErrInvalidResponse, "email missing in the assertion")
+	}
+
+	groupsAttr := p.GroupsClaim
+	if groupsAttr == "" {
+		groupsAttr = "groups"
+	}
+
+	return Identity{
+		Email:  strings.ToLower(email),
+		Name:   first(attrs, nameAttributes),
+		Groups: attrs[groupsAttr],
+	}, nil
+}
+
+func checkConditions(assertion *etree.Element, spEntityID string, now time.Time) error {
+	cond := assertion.SelectElement("Conditions")
+	if cond == nil {
+		return errors.Wrap(ErrInvalidResponse, "conditions missing")
+	}
+	if err := checkWindow(cond.SelectAttrValue("NotBefore", ""), cond.SelectAttrValue("NotOnOrAfter", ""), now); err != nil {
+		return err
+	}
+	audiences := cond.FindElements("./AudienceRestriction/Audience")
+	if len(audiences) == 0 {
+		return nil
+	}
+	for _, a := range audiences {
+		if strings.TrimSpace(a.Text()) == spEntityID {
+			return nil
+		}
+	}
+	return errors.Wrap(ErrInvalidResponse, "audience mismatch")
+}
+
+func checkSubject(assertion *etree.Element, acsURL, requestID string, now time.Time) error {
+	data := assertion.FindElement("./Subject/SubjectConfirmation/SubjectConfirmationData")
+	if data == nil {
+		return errors.Wrap(ErrInvalidResponse, "subject confirmation missing")
+	}
+	if r := data.SelectAttrValue("Recipient", ""); r != acsURL {
+		return errors.Wrap(ErrInvalidResponse, "recipient mismatch")
+	}
+	if irt := data.SelectAttrValue("InResponseTo", requestID); irt != requestID {
+		return errors.Wrap(ErrInvalidResponse, "subject is not for this sign in")
+	}
+	return checkWindow("", data.SelectAttrValue("NotOnOrAfter", ""), now)
+}
+
+func checkWindow(notBefore, notOnOrAfter string, now time.Time) error {
+	if notBefore != "" {
+		t, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil || now.Add(clockSkew).Before(t) {
+			return errors.Wrap(ErrInvalidResponse, "assertion is not valid yet")
+		}
+	}
+	if notOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339, notOnOrAfter)
+		if err != nil || !now.Add(-clockSkew).Before(t) {
+			return errors.Wrap(ErrInvalidResponse, "assertion expired")
+		}
+	}
+	return nil
+}
+
+func attributes(assertion *etree.Element) map[string][]string {
+	attrs := make(map[string][]string, 0)
+	for _, a := range assertion.FindElements("./AttributeStatement/Attribute") {
+		name := a.SelectAttrValue("Name", "")
+		for _, v := range a.SelectElements("AttributeValue") {
+			attrs[name] = append(attrs[name], strings.TrimSpace(v.Text()))
+		}
+	}
+	return attrs
+}
+
+func first(attrs map[string][]string, names []string) string {
+	for _, n := range names {
+		if vals := attrs[n]; len(vals) > 0 && vals[0] != "" {
+			return vals[0]
+		}
+	}
+	return ""
+}
+
+func parseMetadata(metadata string) (idpMetadata, error) {
+	var ed entityDescriptor
+	if err := xml.Unmarshal([]byte(metadata), &ed); err != nil {
+		return idpMetadata{}, errors.Wrap(ErrInvalidProvider, "metadata is not valid xml")
+	}
+
+	md := idpMetadata{EntityID: ed.EntityID}
+	for _, s := range ed.IDPSSODescriptor.SingleSignOnServices {
+		if s.Binding == bindingRedirect {
+			md.SSOURL = s.Location
+		}
+	}
+	for _, kd := range ed.IDPSSODescriptor.KeyDescriptors {
+		if kd.Use != "" && kd.Use != "signing" {
+			continue
+		}
+		for _, c := range kd.Certificates {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(c), ""))
+			if err != nil {
+				continue
+			}
+			if cert, err := x509.ParseCertificate(der); err == nil {
+				md.Certs = append(md.Certs, cert)
+			}
+		}
+	}
+
+	switch {
+	case md.EntityID == "":
+		return idpMetadata{}, errors.Wrap(ErrInvalidProvider, "metadata has no entity id")
+	case md.SSOURL == "":
+		return idpMetadata{}, errors.Wrap(ErrInvalidProvider, "metadata has no sso url with the redirect binding")
+	case len(md.Certs) == 0:
+		return idpMetadata{}, errors.Wrap(ErrInvalidProvider, "metadata has no signing certificate")
+	}
+	return md, nil
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,173 @@
+package sso
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when the account does not have the identity provider.
+	ErrNotFound = errors.New("Identity provider not found")
+	// ErrInvalidProvider is used when the configuration of the identity provider is incomplete.
+	ErrInvalidProvider = errors.New("Identity provider configuration is not valid")
+	// ErrInvalidResponse is used when the response of the identity provider cannot be trusted.
+	ErrInvalidResponse = errors.New("Identity provider response is not valid")
+)
+
+// rolePriority decides the role when the member belongs to the groups mapped to different roles.
+var rolePriority = map[string]int{
+	auth.RoleUser:   1,
+	auth.RoleMember: 2,
+	auth.RoleAdmin:  3,
+}
+
+// Save creates or replaces the identity provider of the account. The client secret
+// is kept as it is when the update does not carry it.
+func Save(ctx context.Context, db *sqlx.DB, accountID string, np NewProvider, now time.Time) (Provider, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sso.Save")
+	defer span.End()
+
+	if np.DefaultRole == "" {
+		np.DefaultRole = auth.RoleMember
+	}
+	if _, ok := rolePriority[np.DefaultRole]; !ok {
+		return Provider{}, errors.Wrapf(ErrInvalidProvider, "unknown default role %q", np.DefaultRole)
+	}
+	for group, role := range np.RoleMappings {
+		if _, ok := rolePriority[role]; !ok {
+			return Provider{}, errors.Wrapf(ErrInvalidProvider, "unknown role %q for the group %q", role, group)
+		}
+	}
+
+	if np.Type == TypeOIDC && np.ClientSecret == "" {
+		if existing, err := Retrieve(ctx, db, accountID); err == nil && existing.Type == TypeOIDC {
+			np.ClientSecret = existing.ClientSecret
+		}
+	}
+	switch np.Type {
+	case TypeOIDC:
+		if np.Issuer == "" || np.ClientID == "" || np.ClientSecret == "" {
+			return Provider{}, errors.Wrap(ErrInvalidProvider, "oidc needs the issuer, client id and client secret")
+		}
+	case TypeSAML:
+		if _, err := parseMetadata(np.Metadata); err != nil {
+			return Provider{}, errors.Wrap(ErrInvalidProvider, err.Error())
+		}
+	default:
+		return Provider{}, errors.Wrapf(ErrInvalidProvider, "unknown type %q", np.Type)
+	}
+
+	mappingsb, err := json.Marshal(np.RoleMappings)
+	if err != nil {
+		return Provider{}, errors.Wrap(err, "encode role mappings")
+	}
+
+	p := Provider{
+		AccountID:     accountID,
+		Type:          np.Type,
+		Issuer:        np.Issuer,
+		ClientID:      np.ClientID,
+		ClientSecret:  np.ClientSecret,
+		Metadata:      np.Metadata,
+		GroupsClaim:   np.GroupsClaim,
+		RoleMappingsb: string(mappingsb),
+		DefaultRole:   np.DefaultRole,
+		Enforced:      np.Enforced,
+		CreatedAt:     now.UTC(),
+		UpdatedAt:     now.UTC().Unix(),
+	}
+
+	const q = `INSERT INTO sso_providers
+		(account_id, type, issuer, client_id, client_secret, metadata, groups_claim, role_mappingsb, default_role, enforced, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (account_id) DO UPDATE SET
+		type = $2, issuer = $3, client_id = $4, client_secret = $5, metadata = $6, groups_claim = $7,
+		role_mappingsb = $8, default_role = $9, enforced = $10, updated_at = $12`
+	_, err = db.ExecContext(
+		ctx, q,
+		p.AccountID, p.Type, p.Issuer, p.ClientID, p.ClientSecret, p.Metadata, p.GroupsClaim,
+		p.RoleMappingsb, p.DefaultRole, p.Enforced, p.CreatedAt, p.UpdatedAt,
+	)
+	if err != nil {
+		return Provider{}, errors.Wrap(err, "saving identity provider")
+	}
+
+	return p, nil
+}
+
+// Retrieve gets the identity provider of the account.
+func Retrieve(ctx context.Context, db *sqlx.DB, accountID string) (Provider, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.sso.Retrieve")
+	defer span.End()
+
+	var p Provider
+	const q = `SELECT * FROM sso_providers WHERE account_id = $1`
+	if err := db.GetContext(ctx, &p, q, accountID); err != nil {
+		if err == sql.ErrNoRows {
+			return Provider{}, ErrNotFound
+		}
+		return Provider{}, errors.Wrapf(err, "selecting identity provider of %q", accountID)
+	}
+
+	return p, nil
+}
+
+// Delete removes the identity provider of the account. The members fall back to the magic links.
+func Delete(ctx context.Context, db *sqlx.DB, accountID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.sso.Delete")
+	defer span.End()
+
+	const q = `DELETE FROM sso_providers WHERE account_id = $1`
+	if _, err := db.ExecContext(ctx, q, accountID); err != nil {
+		return errors.Wrapf(err, "deleting identity provider of %q", accountID)
+	}
+
+	return nil
+}
+
+// Enforced returns true if the members of the account can sign in only through the identity provider.
+func Enforced(ctx context.Context, db *sqlx.DB, accountID string) (bool, error) {
+	p, err := Retrieve(ctx, db, accountID)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return p.Enforced, nil
+}
+
+// RoleMappings returns the roles of the groups.
+func (p Provider) RoleMappings() map[string]string {
+	mappings := make(map[string]string, 0)
+	if p.RoleMappingsb == "" {
+		return mappings
+	}
+	if err := json.Unmarshal([]byte(p.RoleMappingsb), &mappings); err != nil {
+		log.Printf("***> unexpected error occurred when unmarshalling role mappings for account: %v error: %v\n", p.AccountID, err)
+	}
+	return mappings
+}
+
+// Role maps the groups of the member to the role. The highest role of the mapped groups wins.
+// The default role is returned with mapped as false when none of the groups are mapped.
+func (p Provider) Role(groups []string) (role string, mapped bool) {
+	mappings := p.RoleMappings()
+	for _, g := range groups {
+		if r, ok := mappings[g]; ok && rolePriority[r] > rolePriority[role] {
+			role = r
+		}
+	}
+	if role == "" {
+		return p.DefaultRole, false
+	}
+	return role, true
+}
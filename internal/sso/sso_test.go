@@ -0,0 +1,334 @@
+package sso_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/dgrijalva/jwt-go"
+	dsig "github.com/russellhaering/goxmldsig"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/sso"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+const (
+	clientID    = "relay-client"
+	redirectURL = "https://app.example.com/v1/sso/acc1/oidc/callback"
+	acsURL      = "https://app.example.com/v1/sso/acc1/saml/acs"
+	spEntityID  = "https://app.example.com/v1/sso/acc1/saml"
+	idpEntityID = "https://idp.example.com/saml"
+)
+
+// mockOIDC is the local IdP which serves the discovery, the keys and the token endpoint.
+// The id token issued by the token endpoint carries the claims set by the test.
+type mockOIDC struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDC{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, m.claims)
+		tok.Header["kid"] = "k1"
+		idToken, err := tok.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+func (m *mockOIDC) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.URL,
+		"aud":            clientID,
+		"sub":            "u1",
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          "n1",
+		"email":          "Jane@Example.com",
+		"email_verified": true,
+		"name":           "Jane",
+		"groups":         []string{"eng", "admins"},
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	idp := newMockOIDC(t)
+	defer idp.Close()
+	p := sso.Provider{AccountID: "acc1", Type: sso.TypeOIDC, Issuer: idp.URL, ClientID: clientID, ClientSecret: "secret"}
+	ctx := context.Background()
+
+	t.Log("Given the need to sign in through the OIDC provider")
+	{
+		t.Log("\twhen the auth url is built")
+		{
+			u, err := sso.OIDCAuthURL(ctx, p, redirectURL, "s1", "n1")
+			if err != nil {
+				t.Fatalf("\t%s should build the auth url : %s", tests.Failed, err)
+			}
+			parsed, _ := url.Parse(u)
+			q := parsed.Query()
+			if !strings.HasPrefix(u, idp.URL+"/authorize") || q.Get("state") != "s1" || q.Get("nonce") != "n1" || q.Get("client_id") != clientID {
+				t.Fatalf("\t%s should point to the discovered endpoint with the state and nonce. got %s", tests.Failed, u)
+			}
+			t.Logf("\t%s should point to the discovered endpoint with the state and nonce", tests.Success)
+		}
+
+		t.Log("\twhen the code is exchanged for a valid id token")
+		{
+			idp.claims = idp.validClaims()
+			identity, err := sso.OIDCExchange(ctx, p, redirectURL, "good-code", "n1")
+			if err != nil {
+				t.Fatalf("\t%s should exchange the code : %s", tests.Failed, err)
+			}
+			if identity.Email != "jane@example.com" || identity.Name != "Jane" || len(identity.Groups) != 2 {
+				t.Fatalf("\t%s should return the identity. got %+v", tests.Failed, identity)
+			}
+			t.Logf("\t%s should return the identity", tests.Success)
+		}
+
+		rejections := []struct {
+			name   string
+			code   string
+			nonce  string
+			mutate func(c jwt.MapClaims)
+		}{
+			{"the code is not valid", "bad-code", "n1", func(c jwt.MapClaims) {}},
+			{"the nonce is replayed", "good-code", "n2", func(c jwt.MapClaims) {}},
+			{"the token is for another client", "good-code", "n1", func(c jwt.MapClaims) { c["aud"] = "other" }},
+			{"the token is from another issuer", "good-code", "n1", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+			{"the token expired", "good-code", "n1", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+			{"the email is not verified", "good-code", "n1", func(c jwt.MapClaims) { c["email_verified"] = false }},
+		}
+		for _, rj := range rejections {
+			t.Logf("\twhen %s", rj.name)
+			{
+				idp.claims = idp.validClaims()
+				rj.mutate(idp.claims)
+				if _, err := sso.OIDCExchange(ctx, p, redirectURL, rj.code, rj.nonce); err == nil {
+					t.Fatalf("\t%s should reject the sign in", tests.Failed)
+				}
+				t.Logf("\t%s should reject the sign in", tests.Success)
+			}
+		}
+	}
+}
+
+// mockSAML is the local IdP which signs the responses with a random key.
+type mockSAML struct {
+	ks       dsig.X509KeyStore
+	metadata string
+}
+
+func newMockSAML(t *testing.T) mockSAML {
+	ks := dsig.RandomKeyStoreForTest()
+	_, cert, err := ks.GetKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := fmt.Sprintf(`<EntityDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">
+  <IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <KeyDescriptor use="signing">
+      <KeyInfo xmlns="http://www.w3.org/2000/09/xmldsig#"><X509Data><X509Certificate>%s</X509Certificate></X509Data></KeyInfo>
+    </KeyDescriptor>
+    <SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </IDPSSODescriptor>
+</EntityDescriptor>`, idpEntityID, base64.StdEncoding.EncodeToString(cert))
+	return mockSAML{ks: ks, metadata: metadata}
+}
+
+// response builds the response with the assertion signed by the IdP. The tamper func
+// changes the assertion after it is signed.
+func (m mockSAML) response(t *testing.T, requestID, audience string, now time.Time, tamper func(a *etree.Element)) string {
+	assertion := fmt.Sprintf(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="a1" Version="2.0" IssueInstant="%[1]s">
+<saml:Issuer>%[2]s</saml:Issuer>
+<saml:Subject>
+<saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">jane@example.com</saml:NameID>
+<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData InResponseTo="%[3]s" NotOnOrAfter="%[4]s" Recipient="%[5]s"/></saml:SubjectConfirmation>
+</saml:Subject>
+<saml:Conditions NotBefore="%[1]s" NotOnOrAfter="%[4]s"><saml:AudienceRestriction><saml:Audience>%[6]s</saml:Audience></saml:AudienceRestriction></saml:Conditions>
+<saml:AttributeStatement>
+<saml:Attribute Name="name"><saml:AttributeValue>Jane</saml:AttributeValue></saml:Attribute>
+<saml:Attribute Name="groups"><saml:AttributeValue>eng</saml:AttributeValue><saml:AttributeValue>admins</saml:AttributeValue></saml:Attribute>
+</saml:AttributeStatement>
+</saml:Assertion>`, now.UTC().Format(time.RFC3339), idpEntityID, requestID, now.Add(5*time.Minute).UTC().Format(time.RFC3339), acsURL, audience)
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(assertion); err != nil {
+		t.Fatal(err)
+	}
+	sctx := dsig.NewDefaultSigningContext(m.ks)
+	sctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signed, err := sctx.SignEnveloped(doc.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(signed)
+	}
+
+	resp := etree.NewDocument()
+	err = resp.ReadFromString(fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="r1" Version="2.0" Destination="%s" InResponseTo="%s"><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status></samlp:Response>`, acsURL, requestID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Root().AddChild(signed)
+	raw, err := resp.WriteToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func TestSAML(t *testing.T) {
+	idp := newMockSAML(t)
+	p := sso.Provider{AccountID: "acc1", Type: sso.TypeSAML, Metadata: idp.metadata}
+	now := time.Now()
+
+	t.Log("Given the need to sign in through the SAML provider")
+	{
+		var requestID string
+		t.Log("\twhen the authn request is built")
+		{
+			req, err := sso.SAMLAuthRequest(p, spEntityID, acsURL, "relay-state", now)
+			if err != nil {
+				t.Fatalf("\t%s should build the request : %s", tests.Failed, err)
+			}
+			parsed, _ := url.Parse(req.URL)
+			if !strings.HasPrefix(req.URL, "https://idp.example.com/sso") || parsed.Query().Get("RelayState") != "relay-state" || parsed.Query().Get("SAMLRequest") == "" {
+				t.Fatalf("\t%s should redirect to the sso url of the metadata. got %s", tests.Failed, req.URL)
+			}
+			requestID = req.ID
+			t.Logf("\t%s should redirect to the sso url of the metadata", tests.Success)
+		}
+
+		t.Log("\twhen the signed response is posted")
+		{
+			identity, err := sso.SAMLIdentity(p, spEntityID, acsURL, requestID, idp.response(t, requestID, spEntityID, now, nil), now)
+			if err != nil {
+				t.Fatalf("\t%s should accept the response : %s", tests.Failed, err)
+			}
+			if identity.Email != "jane@example.com" || identity.Name != "Jane" || len(identity.Groups) != 2 {
+				t.Fatalf("\t%s should return the identity. got %+v", tests.Failed, identity)
+			}
+			t.Logf("\t%s should return the identity", tests.Success)
+		}
+
+		rejections := []struct {
+			name      string
+			requestID string
+			audience  string
+			at        time.Time
+			tamper    func(a *etree.Element)
+		}{
+			{"the response is for another sign in", "id-other", spEntityID, now, nil},
+			{"the response is for another service provider", requestID, "https://other.example.com", now, nil},
+			{"the response expired", requestID, spEntityID, now.Add(-time.Hour), nil},
+			{"the assertion is tampered", requestID, spEntityID, now, func(a *etree.Element) {
+				a.FindElement("./Subject/NameID").SetText("mallory@example.com")
+			}},
+		}
+		for _, rj := range rejections {
+			t.Logf("\twhen %s", rj.name)
+			{
+				resp := idp.response(t, rj.requestID, rj.audience, rj.at, rj.tamper)
+				if _, err := sso.SAMLIdentity(p, spEntityID, acsURL, requestID, resp, now); err == nil {
+					t.Fatalf("\t%s should reject the sign in", tests.Failed)
+				}
+				t.Logf("\t%s should reject the sign in", tests.Success)
+			}
+		}
+
+		t.Log("\twhen the response is signed by another IdP")
+		{
+			other := newMockSAML(t)
+			if _, err := sso.SAMLIdentity(p, spEntityID, acsURL, requestID, other.response(t, requestID, spEntityID, now, nil), now); err == nil {
+				t.Fatalf("\t%s should reject the sign in", tests.Failed)
+			}
+			t.Logf("\t%s should reject the sign in", tests.Success)
+		}
+	}
+}
+
+func TestRole(t *testing.T) {
+	p := sso.Provider{
+		DefaultRole:   auth.RoleMember,
+		RoleMappingsb: `{"eng": "MEMBER", "admins": "ADMIN", "guests": "USER"}`,
+	}
+
+	t.Log("Given the need to map the groups of the IdP to the role")
+	{
+		cases := []struct {
+			groups []string
+			role   string
+			mapped bool
+		}{
+			{[]string{"eng", "admins"}, auth.RoleAdmin, true},
+			{[]string{"guests"}, auth.RoleUser, true},
+			{[]string{"guests", "eng"}, auth.RoleMember, true},
+			{[]string{"sales"}, auth.RoleMember, false},
+			{nil, auth.RoleMember, false},
+		}
+		for _, c := range cases {
+			t.Logf("\twhen the member belongs to %v", c.groups)
+			{
+				role, mapped := p.Role(c.groups)
+				if role != c.role || mapped != c.mapped {
+					t.Fatalf("\t%s should get %s (mapped %v). got %s (mapped %v)", tests.Failed, c.role, c.mapped, role, mapped)
+				}
+				t.Logf("\t%s should get %s", tests.Success, c.role)
+			}
+		}
+	}
+}
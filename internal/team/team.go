@@ -7,7 +7,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/database"
 	"go.opencensus.io/trace"
 )
 
@@ -46,13 +48,14 @@ func List(ctx context.Context, accountID string, db *sqlx.DB) ([]Team, error) {
 }
 
 // Create inserts a new user into the database.
-func Create(ctx context.Context, db *sqlx.DB, n NewTeam, now time.Time) (Team, error) {
+func Create(ctx context.Context, db database.Conn, n NewTeam, now time.Time) (Team, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.team.Create")
 	defer span.End()
 
 	t := Team{
 		ID:          uuid.New().String(),
 		AccountID:   n.AccountID,
+		LookUp:      n.LookUp,
 		Name:        n.Name,
 		Description: &n.Description,
 		CreatedAt:   now.UTC(),
@@ -61,7 +64,7 @@ func Create(ctx context.Context, db *sqlx.DB, n NewTeam, now time.Time) (Team, e
 
 	const q = `INSERT INTO teams
 		(team_id, account_id, look_up, name, description, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)`
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
 	_, err := db.ExecContext(
 		ctx, q,
 		t.ID, t.AccountID, t.LookUp, t.Name, t.Description,
@@ -93,6 +96,50 @@ func Retrieve(ctx context.Context, teamID int64, db *sqlx.DB) (Team, error) {
 	return t, nil
 }
 
+// RetrieveByName gets the team of the account with the name.
+func RetrieveByName(ctx context.Context, db *sqlx.DB, accountID, name string) (Team, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.team.RetrieveByName")
+	defer span.End()
+
+	var t Team
+	const q = `SELECT * FROM teams WHERE account_id = $1 AND name = $2 LIMIT 1`
+	if err := db.GetContext(ctx, &t, q, accountID, name); err != nil {
+		if err == sql.ErrNoRows {
+			return Team{}, ErrNotFound
+		}
+		return Team{}, errors.Wrapf(err, "selecting team %q", name)
+	}
+
+	return t, nil
+}
+
+// BulkRetrieve gets the teams of the account with the ids.
+func BulkRetrieve(ctx context.Context, db *sqlx.DB, accountID string, ids []string) ([]Team, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.team.BulkRetrieve")
+	defer span.End()
+
+	teams := []Team{}
+	const q = `SELECT * FROM teams WHERE account_id = $1 AND team_id = any($2)`
+	if err := db.SelectContext(ctx, &teams, q, accountID, pq.Array(ids)); err != nil {
+		return nil, errors.Wrap(err, "selecting teams for the ids")
+	}
+
+	return teams, nil
+}
+
+// UpdateName renames the team.
+func UpdateName(ctx context.Context, db *sqlx.DB, accountID, teamID, name string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.team.UpdateName")
+	defer span.End()
+
+	const q = `UPDATE teams SET name = $3, updated_at = $4 WHERE account_id = $1 AND team_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, teamID, name, now.UTC().Unix()); err != nil {
+		return errors.Wrapf(err, "updating team %q", teamID)
+	}
+
+	return nil
+}
+
 func CustomModules() []Module {
 	modules := make([]Module, 0)
 	for k, v := range modulesMap {
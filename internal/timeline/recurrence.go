@@ -0,0 +1,172 @@
+package timeline
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Frequencies of the recurrence rule.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxSteps stops the expansion of the rules recurring for ever.
+const maxSteps = 10000
+
+// ErrInvalidRule is used when the recurrence rule is not understood.
+var ErrInvalidRule = errors.New("Recurrence rule is not valid")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Rule is the subset of the iCalendar RRULE the meetings recur with, such as
+// "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;UNTIL=20261231T000000Z".
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+// ParseRule parses the recurrence rule. The "RRULE:" prefix is optional.
+func ParseRule(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return Rule{}, errors.Wrapf(ErrInvalidRule, "part %q", part)
+		}
+		key, val := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+		switch key {
+		case "FREQ":
+			switch val {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = val
+			default:
+				return Rule{}, errors.Wrapf(ErrInvalidRule, "frequency %q", val)
+			}
+		case "INTERVAL", "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return Rule{}, errors.Wrapf(ErrInvalidRule, "%s %q", key, val)
+			}
+			if key == "INTERVAL" {
+				r.Interval = n
+			} else {
+				r.Count = n
+			}
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return Rule{}, errors.Wrapf(ErrInvalidRule, "until %q", val)
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, d := range strings.Split(val, ",") {
+				wd, ok := weekdays[d]
+				if !ok {
+					return Rule{}, errors.Wrapf(ErrInvalidRule, "day %q", d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "WKST":
+			// the weeks start on monday.
+		default:
+			return Rule{}, errors.Wrapf(ErrInvalidRule, "part %q", key)
+		}
+	}
+	if r.Freq == "" {
+		return Rule{}, errors.Wrap(ErrInvalidRule, "frequency missing")
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return weekOffset(r.ByDay[i]) < weekOffset(r.ByDay[j]) })
+	return r, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Starts returns the starts of the series beginning at the start that overlap the window, where each
+// occurrence lasts for the duration. The occurrences before the window still count for the COUNT.
+func (r Rule) Starts(start time.Time, d time.Duration, from, to time.Time) []time.Time {
+	starts := make([]time.Time, 0)
+	n := 0
+	for step := 0; step < maxSteps; step++ {
+		period := r.period(start, step)
+		if period.IsZero() {
+			continue
+		}
+		for _, t := range r.expand(start, period) {
+			if t.Before(start) {
+				continue
+			}
+			if !t.Before(to) || (!r.Until.IsZero() && t.After(r.Until)) || (r.Count > 0 && n >= r.Count) {
+				return starts
+			}
+			n++
+			if t.Add(d).After(from) || t.Equal(from) {
+				starts = append(starts, t)
+			}
+		}
+	}
+	return starts
+}
+
+// period returns the start of the step-th period of the series. Zero when the day is not in the month,
+// as the monthly meeting on the 31st skips the shorter months.
+func (r Rule) period(start time.Time, step int) time.Time {
+	n := step * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		return start.AddDate(0, 0, n)
+	case FreqWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FreqMonthly:
+		t := start.AddDate(0, n, 0)
+		if t.Day() != start.Day() {
+			return time.Time{}
+		}
+		return t
+	case FreqYearly:
+		t := start.AddDate(n, 0, 0)
+		if t.Day() != start.Day() {
+			return time.Time{}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// expand returns the occurrences in the period. Only the weekly rules have more than one.
+func (r Rule) expand(start, period time.Time) []time.Time {
+	if r.Freq != FreqWeekly || len(r.ByDay) == 0 {
+		return []time.Time{period}
+	}
+	monday := period.AddDate(0, 0, -weekOffset(period.Weekday()))
+	ts := make([]time.Time, len(r.ByDay))
+	for i, wd := range r.ByDay {
+		ts[i] = monday.AddDate(0, 0, weekOffset(wd))
+	}
+	return ts
+}
+
+// weekOffset is the days since monday.
+func weekOffset(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
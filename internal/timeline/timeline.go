@@ -0,0 +1,145 @@
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/entity"
+	"gitlab.com/vjsideprojects/relay/internal/platform/util"
+)
+
+// Occurrence is the item placed on the calendar or the timeline. The recurring item has an occurrence
+// for each time it repeats in the window.
+type Occurrence struct {
+	ItemID    string    `json:"item_id"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Recurring bool      `json:"recurring"`
+}
+
+// Edge is the dependency between the timeline items. The item To starts after the item From ends.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Keys are the fields of the entity the items are placed with.
+type Keys struct {
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Recurrence string `json:"recurrence"`
+	DependsOn  string `json:"depends_on"`
+}
+
+// EntityKeys returns the fields placing the items of the entity. The meetings and the projects have the start
+// and the end times, the tasks and the milestones only the due by which is used for both. The start is empty
+// when the entity has no dates.
+func EntityKeys(e entity.Entity) Keys {
+	k := Keys{
+		Start:      e.WhoField(entity.WhoStartTime).Key,
+		End:        e.WhoField(entity.WhoEndTime).Key,
+		Recurrence: e.WhoField(entity.WhoRecurrence).Key,
+		DependsOn:  e.WhoField(entity.WhoDependsOn).Key,
+	}
+	due := e.WhoField(entity.WhoDueBy).Key
+	if k.Start == "" {
+		k.Start, k.End = due, ""
+	} else if k.End == "" {
+		k.End = due
+	}
+	return k
+}
+
+// Expand places the item in the window. The item without the end lasts for an instant and the item with the
+// recurrence rule is repeated. Nothing is returned when the item has no valid start.
+func Expand(itemID string, fields map[string]interface{}, k Keys, from, to time.Time) ([]Occurrence, error) {
+	start, ok := ValueTime(fields[k.Start])
+	if !ok {
+		return nil, nil
+	}
+	end, ok := ValueTime(fields[k.End])
+	if !ok || end.Before(start) {
+		end = start
+	}
+
+	rule, _ := fields[k.Recurrence].(string)
+	if k.Recurrence == "" || rule == "" {
+		if start.Before(to) && (end.After(from) || !start.Before(from)) {
+			return []Occurrence{{ItemID: itemID, Start: start, End: end}}, nil
+		}
+		return nil, nil
+	}
+
+	r, err := ParseRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	d := end.Sub(start)
+	starts := r.Starts(start, d, from, to)
+	occs := make([]Occurrence, len(starts))
+	for i, s := range starts {
+		occs[i] = Occurrence{ItemID: itemID, Start: s, End: s.Add(d), Recurring: true}
+	}
+	return occs, nil
+}
+
+// Sort orders the occurrences by the start, the longer first when they start together.
+func Sort(occs []Occurrence) {
+	sort.SliceStable(occs, func(i, j int) bool {
+		if occs[i].Start.Equal(occs[j].Start) {
+			return occs[i].End.After(occs[j].End)
+		}
+		return occs[i].Start.Before(occs[j].Start)
+	})
+}
+
+// Edges returns the dependencies between the items in the window, keyed by the item with the items it
+// depends on.
+func Edges(dependsOn map[string][]string) []Edge {
+	edges := make([]Edge, 0)
+	for to, froms := range dependsOn {
+		for _, from := range froms {
+			if _, ok := dependsOn[from]; ok && from != to {
+				edges = append(edges, Edge{From: from, To: to})
+			}
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From == edges[j].From {
+			return edges[i].To < edges[j].To
+		}
+		return edges[i].From < edges[j].From
+	})
+	return edges
+}
+
+// DependsOn returns the items the item depends on.
+func DependsOn(fields map[string]interface{}, k Keys) []string {
+	ids := make([]string, 0)
+	if k.DependsOn == "" {
+		return ids
+	}
+	refs, _ := fields[k.DependsOn].([]interface{})
+	for _, ref := range refs {
+		if id := fmt.Sprint(ref); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ValueTime reads the value of the date field. The older clients saved the unix milliseconds.
+func ValueTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case string:
+		if t == "" {
+			return time.Time{}, false
+		}
+		parsed, err := entity.ParseValueTime(t)
+		return parsed, err == nil
+	case float64:
+		return util.ConvertMilliToTime(int64(t)), true
+	}
+	return time.Time{}, false
+}
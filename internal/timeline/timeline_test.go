@@ -0,0 +1,78 @@
+package timeline_test
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/timeline"
+)
+
+func TestExpand(t *testing.T) {
+	k := timeline.Keys{Start: "start", End: "end", Recurrence: "rule"}
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Log("Given the need to place the items in the window")
+	{
+		cases := []struct {
+			name   string
+			fields map[string]interface{}
+			starts []string
+		}{
+			{"single", map[string]interface{}{"start": "2026-03-10T10:00:00Z", "end": "2026-03-10T11:00:00Z"}, []string{"2026-03-10T10:00"}},
+			{"overlapping the start", map[string]interface{}{"start": "2026-02-27", "end": "2026-03-02"}, []string{"2026-02-27T00:00"}},
+			{"outside", map[string]interface{}{"start": "2026-04-01", "end": "2026-04-02"}, []string{}},
+			{"no start", map[string]interface{}{"end": "2026-03-10"}, []string{}},
+			{"weekly", map[string]interface{}{"start": "2026-02-02T09:00:00Z", "end": "2026-02-02T09:30:00Z", "rule": "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;UNTIL=20260320T000000Z"},
+				[]string{"2026-03-02T09:00", "2026-03-05T09:00", "2026-03-16T09:00", "2026-03-19T09:00"}},
+			{"counted", map[string]interface{}{"start": "2026-02-26", "rule": "FREQ=DAILY;COUNT=5"}, []string{"2026-03-01T00:00", "2026-03-02T00:00"}},
+			{"monthly on the 31st", map[string]interface{}{"start": "2026-01-31", "rule": "FREQ=MONTHLY"}, []string{"2026-03-31T00:00"}},
+		}
+		for _, c := range cases {
+			occs, err := timeline.Expand("item", c.fields, k, from, to)
+			if err != nil {
+				t.Fatalf("\t%s the %s item should be placed : %s.", tests.Failed, c.name, err)
+			}
+			got := make([]string, len(occs))
+			for i, o := range occs {
+				got[i] = o.Start.UTC().Format("2006-01-02T15:04")
+			}
+			if len(got) != len(c.starts) {
+				t.Fatalf("\t%s the %s item should start at %v. got %v", tests.Failed, c.name, c.starts, got)
+			}
+			for i := range got {
+				if got[i] != c.starts[i] {
+					t.Fatalf("\t%s the %s item should start at %v. got %v", tests.Failed, c.name, c.starts, got)
+				}
+			}
+			t.Logf("\t%s the %s item should start at %v", tests.Success, c.name, c.starts)
+		}
+
+		t.Log("\twhen the rule is not valid")
+		{
+			for _, rule := range []string{"FREQ=HOURLY", "INTERVAL=2", "FREQ=WEEKLY;BYDAY=XX"} {
+				if _, err := timeline.ParseRule(rule); err == nil {
+					t.Fatalf("\t%s the rule %q should not be parsed.", tests.Failed, rule)
+				}
+			}
+			t.Logf("\t%s should not parse the rules not supported", tests.Success)
+		}
+	}
+}
+
+func TestEdges(t *testing.T) {
+	t.Log("Given the need to link the dependent items on the timeline")
+	{
+		edges := timeline.Edges(map[string][]string{
+			"design": {},
+			"build":  {"design", "outside"},
+			"ship":   {"build", "ship"},
+		})
+		want := []timeline.Edge{{From: "build", To: "ship"}, {From: "design", To: "build"}}
+		if len(edges) != len(want) || edges[0] != want[0] || edges[1] != want[1] {
+			t.Fatalf("\t%s should link the items in the window. got %v", tests.Failed, edges)
+		}
+		t.Logf("\t%s should link the items in the window", tests.Success)
+	}
+}
@@ -0,0 +1,58 @@
+package timerange
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlab.com/vjsideprojects/relay/internal/account"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+// Load reads the calendar of the account with the timezone and the week start of the user over it. The
+// fallback zone, such as the one of the browser, is used when neither has the timezone.
+func Load(ctx context.Context, db *sqlx.DB, accountID, userID string, fallback *time.Location) (Settings, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.timerange.Load")
+	defer span.End()
+
+	acc, err := account.Retrieve(ctx, db, accountID)
+	if err != nil {
+		return Settings{}, err
+	}
+	s := Settings{Location: fallback, WeekStart: time.Weekday(acc.WeekStart), FiscalStart: time.Month(acc.FiscalStart)}
+	if acc.TimeZone != nil {
+		if loc := zone(*acc.TimeZone); loc != nil {
+			s.Location = loc
+		}
+	}
+	if userID == "" {
+		return s, nil
+	}
+
+	us, err := user.UserSettingRetrieve(ctx, accountID, userID, db)
+	if err != nil {
+		return Settings{}, err
+	}
+	meta := user.UnmarshalMeta(us.Metab)
+	if loc := zone(meta[user.MetaTimezone]); loc != nil {
+		s.Location = loc
+	}
+	if ws, err := strconv.Atoi(meta[user.MetaWeekStart]); err == nil && ws >= 0 && ws <= 6 {
+		s.WeekStart = time.Weekday(ws)
+	}
+	return s, nil
+}
+
+// zone loads the IANA zone, nil when it is not set or not known.
+func zone(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
@@ -0,0 +1,285 @@
+package timerange
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnknownRange is used when the range asked for is not one of the named ranges.
+	ErrUnknownRange = errors.New("Time range not known")
+
+	// ErrInvalidRange is used when the custom range does not end after it starts.
+	ErrInvalidRange = errors.New("Time range is not valid")
+)
+
+// The rolling ranges end now and go back by their length.
+const (
+	LastHour    = "last_hr"
+	Last6Hours  = "last_6hr"
+	Last24Hours = "last_24hrs"
+	Last7Days   = "last_7days"
+	Last30Days  = "last_30days"
+	Last90Days  = "last_90days"
+	LastWeek    = "last_week"   // the last 7 days, kept for the charts saved with it
+	LastMonth   = "last_month"  // the last calendar month length, not the previous month
+	Last6Months = "last_6month" // the last 6 calendar months
+	LastYear    = "last_year"
+	AllTime     = "all_time"
+	CustomRange = "custom"
+)
+
+// defaultRange is the range of the charts not asking for one.
+const defaultRange = LastWeek
+
+// The calendar ranges start at the beginning of the day, the week, the month, the quarter or the
+// year in the zone of the settings. This X ranges end now, previous X ranges are the whole periods.
+const (
+	Today             = "today"
+	Yesterday         = "yesterday"
+	ThisWeek          = "this_week"
+	PrevWeek          = "prev_week"
+	ThisMonth         = "this_month"
+	PrevMonth         = "prev_month"
+	ThisQuarter       = "this_quarter"
+	PrevQuarter       = "prev_quarter"
+	ThisYear          = "this_year"
+	PrevYear          = "prev_year"
+	ThisFiscalQuarter = "this_fiscal_quarter"
+	PrevFiscalQuarter = "prev_fiscal_quarter"
+	ThisFiscalYear    = "this_fiscal_year"
+	PrevFiscalYear    = "prev_fiscal_year"
+)
+
+// Settings are the calendar the ranges follow. The zero value is UTC with the weeks starting on
+// sunday and the fiscal year in january.
+type Settings struct {
+	Location    *time.Location
+	WeekStart   time.Weekday
+	FiscalStart time.Month
+}
+
+// The periods the times are bucketed into, such as the close dates of the deals.
+const (
+	PeriodWeek          = "week"
+	PeriodMonth         = "month"
+	PeriodQuarter       = "quarter"
+	PeriodYear          = "year"
+	PeriodFiscalQuarter = "fiscal_quarter"
+	PeriodFiscalYear    = "fiscal_year"
+)
+
+// Range is the half open interval from the start to the end with the previous period it is compared
+// with. The times are in UTC.
+type Range struct {
+	Name      string    `json:"name"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	PrevStart time.Time `json:"prev_start"`
+	PrevEnd   time.Time `json:"prev_end"`
+}
+
+// period is the calendar unit of the range. The shift moves the time by the units, back when negative.
+type period struct {
+	start func(t time.Time, s Settings) time.Time
+	shift func(t time.Time, n int) time.Time
+}
+
+var (
+	day      = period{start: startOfDay, shift: func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) }}
+	week     = period{start: startOfWeek, shift: func(t time.Time, n int) time.Time { return t.AddDate(0, 0, 7*n) }}
+	month    = period{start: months(1, false), shift: shiftMonths(1)}
+	quarter  = period{start: months(3, false), shift: shiftMonths(3)}
+	year     = period{start: months(12, false), shift: shiftMonths(12)}
+	fquarter = period{start: months(3, true), shift: shiftMonths(3)}
+	fyear    = period{start: months(12, true), shift: shiftMonths(12)}
+)
+
+// periods are the buckets by their name.
+var periods = map[string]period{
+	PeriodWeek:          week,
+	PeriodMonth:         month,
+	PeriodQuarter:       quarter,
+	PeriodYear:          year,
+	PeriodFiscalQuarter: fquarter,
+	PeriodFiscalYear:    fyear,
+}
+
+// calendars are the calendar ranges with their period and how many periods back they are.
+var calendars = map[string]struct {
+	period period
+	back   int
+}{
+	Today:             {day, 0},
+	Yesterday:         {day, 1},
+	ThisWeek:          {week, 0},
+	PrevWeek:          {week, 1},
+	ThisMonth:         {month, 0},
+	PrevMonth:         {month, 1},
+	ThisQuarter:       {quarter, 0},
+	PrevQuarter:       {quarter, 1},
+	ThisYear:          {year, 0},
+	PrevYear:          {year, 1},
+	ThisFiscalQuarter: {fquarter, 0},
+	PrevFiscalQuarter: {fquarter, 1},
+	ThisFiscalYear:    {fyear, 0},
+	PrevFiscalYear:    {fyear, 1},
+}
+
+// rollings are the rolling ranges with how far they go back from now.
+var rollings = map[string]func(t time.Time, n int) time.Time{
+	LastHour:    hours(1),
+	Last6Hours:  hours(6),
+	Last24Hours: hours(24),
+	Last7Days:   days(7),
+	Last30Days:  days(30),
+	Last90Days:  days(90),
+	LastWeek:    days(7),
+	LastMonth:   shiftMonths(1),
+	Last6Months: shiftMonths(6),
+	LastYear:    shiftMonths(12),
+}
+
+// Named resolves the named range at the time. The empty name is the last week, as the charts default to.
+func Named(name string, now time.Time, s Settings) (Range, error) {
+	if name == "" {
+		name = defaultRange
+	}
+	now = now.In(s.location())
+
+	if c, ok := calendars[name]; ok {
+		start := c.period.shift(c.period.start(now, s), -c.back)
+		end := now
+		if c.back > 0 {
+			end = c.period.shift(start, 1)
+		}
+		return compare(name, start, end, c.period.shift), nil
+	}
+	if shift, ok := rollings[name]; ok {
+		return compare(name, shift(now, -1), now, shift), nil
+	}
+	if name == AllTime {
+		epoch := time.Unix(0, 0).UTC()
+		return Range{Name: name, Start: epoch, End: now.UTC(), PrevStart: epoch, PrevEnd: epoch}, nil
+	}
+	return Range{}, errors.Wrapf(ErrUnknownRange, "%q", name)
+}
+
+// Period is the start of the period the time falls in, in UTC. The empty name is the month.
+func Period(name string, t time.Time, s Settings) (time.Time, error) {
+	if name == "" {
+		name = PeriodMonth
+	}
+	p, ok := periods[name]
+	if !ok {
+		return time.Time{}, errors.Wrapf(ErrUnknownRange, "period %q", name)
+	}
+	return p.start(t.In(s.location()), s).UTC(), nil
+}
+
+// Custom is the range between the times, compared with the range of the same length before it.
+func Custom(start, end time.Time) (Range, error) {
+	if !end.After(start) {
+		return Range{}, errors.Wrapf(ErrInvalidRange, "%s is not after %s", end, start)
+	}
+	length := end.Sub(start)
+	return compare(CustomRange, start, end, func(t time.Time, n int) time.Time { return t.Add(time.Duration(n) * length) }), nil
+}
+
+// Parse reads the custom range from the bounds given as RFC3339 times or as the dates in the zone of the
+// settings. The dates are inclusive, so the range to the date ends when the next day starts.
+func Parse(from, to string, s Settings) (Range, error) {
+	start, _, err := bound(from, s.location())
+	if err != nil {
+		return Range{}, err
+	}
+	end, date, err := bound(to, s.location())
+	if err != nil {
+		return Range{}, err
+	}
+	if date {
+		end = end.AddDate(0, 0, 1)
+	}
+	return Custom(start, end)
+}
+
+// compare makes the range with the previous period shifted back by one unit. The previous period of the range
+// not yet complete, such as this month, ends as far into its period, but not after the range starts.
+func compare(name string, start, end time.Time, shift func(t time.Time, n int) time.Time) Range {
+	prevEnd := shift(end, -1)
+	if prevEnd.After(start) {
+		prevEnd = start
+	}
+	return Range{Name: name, Start: start.UTC(), End: end.UTC(), PrevStart: shift(start, -1).UTC(), PrevEnd: prevEnd.UTC()}
+}
+
+func startOfDay(t time.Time, _ Settings) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func startOfWeek(t time.Time, s Settings) time.Time {
+	back := (int(t.Weekday()) - int(s.WeekStart) + 7) % 7
+	return startOfDay(t, s).AddDate(0, 0, -back)
+}
+
+// months starts the periods of the months every n months from january or from the fiscal start.
+func months(n int, fiscal bool) func(t time.Time, s Settings) time.Time {
+	return func(t time.Time, s Settings) time.Time {
+		anchor := time.January
+		if fiscal {
+			anchor = s.FiscalMonth()
+		}
+		into := (int(t.Month()) - int(anchor) + 12) % 12 % n
+		return time.Date(t.Year(), t.Month()-time.Month(into), 1, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// shiftMonths moves the time by n months a unit. The day past the end of the month lands on the
+// last day, so a month back from march 31 is the end of february and not march 3.
+func shiftMonths(n int) func(t time.Time, units int) time.Time {
+	return func(t time.Time, units int) time.Time {
+		first := time.Date(t.Year(), t.Month()+time.Month(n*units), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		d := t.Day()
+		if d > last {
+			d = last
+		}
+		return first.AddDate(0, 0, d-1)
+	}
+}
+
+func hours(n int) func(t time.Time, units int) time.Time {
+	return func(t time.Time, units int) time.Time { return t.Add(time.Duration(n*units) * time.Hour) }
+}
+
+func days(n int) func(t time.Time, units int) time.Time {
+	return func(t time.Time, units int) time.Time { return t.AddDate(0, 0, n*units) }
+}
+
+// bound reads the time or the date in the zone, telling which it was.
+func bound(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(ErrInvalidRange, "%q is neither a time nor a date", s)
+	}
+	return t, true, nil
+}
+
+func (s Settings) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// FiscalMonth is the month the fiscal year starts in, january when it is not set.
+func (s Settings) FiscalMonth() time.Month {
+	if s.FiscalStart < time.January || s.FiscalStart > time.December {
+		return time.January
+	}
+	return s.FiscalStart
+}
@@ -0,0 +1,130 @@
+package timerange
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+)
+
+func TestNamed(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no zone info : ", err)
+	}
+
+	now := at("2024-03-31T15:00:00Z")
+	monday := Settings{WeekStart: time.Monday}
+	april := Settings{FiscalStart: time.April}
+	tt := []struct {
+		name  string
+		now   time.Time
+		s     Settings
+		want  [4]string
+		about string
+	}{
+		{ThisWeek, now, monday, [4]string{"2024-03-25T00:00:00Z", "2024-03-31T15:00:00Z", "2024-03-18T00:00:00Z", "2024-03-24T15:00:00Z"}, "start the week on the day of the settings"},
+		{ThisMonth, now, Settings{}, [4]string{"2024-03-01T00:00:00Z", "2024-03-31T15:00:00Z", "2024-02-01T00:00:00Z", "2024-02-29T15:00:00Z"}, "compare the month to date with as far into the shorter month"},
+		{PrevMonth, now, Settings{}, [4]string{"2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z", "2024-01-01T00:00:00Z", "2024-02-01T00:00:00Z"}, "compare the whole previous months"},
+		{ThisQuarter, now, Settings{}, [4]string{"2024-01-01T00:00:00Z", "2024-03-31T15:00:00Z", "2023-10-01T00:00:00Z", "2023-12-31T15:00:00Z"}, "start the quarter in january"},
+		{ThisFiscalQuarter, now, april, [4]string{"2024-01-01T00:00:00Z", "2024-03-31T15:00:00Z", "2023-10-01T00:00:00Z", "2023-12-31T15:00:00Z"}, "end the fiscal year starting in april with the quarter from january"},
+		{ThisFiscalYear, now, april, [4]string{"2023-04-01T00:00:00Z", "2024-03-31T15:00:00Z", "2022-04-01T00:00:00Z", "2023-03-31T15:00:00Z"}, "start the fiscal year in april of the last year"},
+		{PrevYear, now, Settings{}, [4]string{"2023-01-01T00:00:00Z", "2024-01-01T00:00:00Z", "2022-01-01T00:00:00Z", "2023-01-01T00:00:00Z"}, "compare the whole previous years"},
+		{Yesterday, at("2024-03-11T16:00:00Z"), Settings{Location: newYork}, [4]string{"2024-03-10T05:00:00Z", "2024-03-11T04:00:00Z", "2024-03-09T05:00:00Z", "2024-03-10T05:00:00Z"}, "keep the day to the midnights of the zone across the daylight saving"},
+		{Last24Hours, now, Settings{}, [4]string{"2024-03-30T15:00:00Z", "2024-03-31T15:00:00Z", "2024-03-29T15:00:00Z", "2024-03-30T15:00:00Z"}, "go back by the hours"},
+		{"", now, Settings{}, [4]string{"2024-03-24T15:00:00Z", "2024-03-31T15:00:00Z", "2024-03-17T15:00:00Z", "2024-03-24T15:00:00Z"}, "default to the last week"},
+		{LastMonth, now, Settings{}, [4]string{"2024-02-29T15:00:00Z", "2024-03-31T15:00:00Z", "2024-01-29T15:00:00Z", "2024-02-29T15:00:00Z"}, "go back a month landing on the end of the shorter month"},
+	}
+
+	t.Log("Given the need to resolve the named ranges with their previous periods")
+	{
+		for _, tc := range tt {
+			rg, err := Named(tc.name, tc.now, tc.s)
+			if err != nil {
+				t.Fatalf("\t%s should resolve %q : %s", tests.Failed, tc.name, err)
+			}
+			got := [4]string{}
+			for i, tm := range []time.Time{rg.Start, rg.End, rg.PrevStart, rg.PrevEnd} {
+				got[i] = tm.Format(time.RFC3339)
+			}
+			if got != tc.want {
+				t.Fatalf("\t%s should %s : got %v want %v", tests.Failed, tc.about, got, tc.want)
+			}
+			t.Logf("\t%s should %s", tests.Success, tc.about)
+		}
+
+		rg, err := Named(AllTime, now, Settings{})
+		if err != nil || !rg.Start.Equal(time.Unix(0, 0)) || !rg.End.Equal(now) {
+			t.Fatalf("\t%s should resolve all the time : %v %+v", tests.Failed, err, rg)
+		}
+		t.Logf("\t%s should resolve all the time", tests.Success)
+
+		if _, err := Named("next_week", now, Settings{}); errors.Cause(err) != ErrUnknownRange {
+			t.Fatalf("\t%s should not know the range : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should not know the range", tests.Success)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Log("Given the need to read the custom ranges")
+	{
+		rg, err := Parse("2024-01-01", "2024-01-31", Settings{})
+		if err != nil {
+			t.Fatalf("\t%s should read the dates : %s", tests.Failed, err)
+		}
+		if rg.Name != CustomRange || rg.End.Format(time.RFC3339) != "2024-02-01T00:00:00Z" || rg.PrevStart.Format(time.RFC3339) != "2023-12-01T00:00:00Z" || !rg.PrevEnd.Equal(rg.Start) {
+			t.Fatalf("\t%s should include the last date and compare the days before : %+v", tests.Failed, rg)
+		}
+		t.Logf("\t%s should include the last date and compare the days before", tests.Success)
+
+		rg, err = Parse("2024-01-01T10:00:00Z", "2024-01-01T12:00:00Z", Settings{})
+		if err != nil || rg.PrevStart.Format(time.RFC3339) != "2024-01-01T08:00:00Z" {
+			t.Fatalf("\t%s should read the times : %v %+v", tests.Failed, err, rg)
+		}
+		t.Logf("\t%s should read the times", tests.Success)
+
+		for _, b := range [][2]string{{"2024-01-31", "2024-01-01"}, {"yesterday", "2024-01-01"}, {"2024-01-01", ""}} {
+			if _, err := Parse(b[0], b[1], Settings{}); errors.Cause(err) != ErrInvalidRange {
+				t.Fatalf("\t%s should reject the range %v : %v", tests.Failed, b, err)
+			}
+		}
+		t.Logf("\t%s should reject the ranges not ending after they start", tests.Success)
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	t.Log("Given the need to bucket the times into the periods")
+	{
+		at := time.Date(2024, 5, 15, 10, 0, 0, 0, time.UTC) // a wednesday
+		s := Settings{WeekStart: time.Monday, FiscalStart: time.April}
+		want := map[string]string{
+			"":                  "2024-05-01T00:00:00Z",
+			PeriodWeek:          "2024-05-13T00:00:00Z",
+			PeriodQuarter:       "2024-04-01T00:00:00Z",
+			PeriodYear:          "2024-01-01T00:00:00Z",
+			PeriodFiscalQuarter: "2024-04-01T00:00:00Z",
+			PeriodFiscalYear:    "2024-04-01T00:00:00Z",
+		}
+		for name, start := range want {
+			p, err := Period(name, at, s)
+			if err != nil || p.Format(time.RFC3339) != start {
+				t.Fatalf("\t%s should start the period %q at %s : %v %s", tests.Failed, name, start, err, p)
+			}
+		}
+		t.Logf("\t%s should start the periods in the calendar of the settings", tests.Success)
+
+		if _, err := Period("decade", at, s); errors.Cause(err) != ErrUnknownRange {
+			t.Fatalf("\t%s should not know the period : %v", tests.Failed, err)
+		}
+		t.Logf("\t%s should not know the period", tests.Success)
+	}
+}
@@ -40,3 +40,12 @@ type NewTimeseries struct {
 	StartTime   time.Time              `json:"start_time"`
 	EndTime     time.Time              `json:"end_time"`
 }
+
+// Fold is the change the event makes to the timeseries it rolls into. The values of Set replace the stored
+// ones and the ones of Add are added to them. Count is added to the count when AddCount, else it is set.
+type Fold struct {
+	Set      map[string]interface{}
+	Add      map[string]float64
+	Count    int
+	AddCount bool
+}
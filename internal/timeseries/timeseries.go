@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -79,6 +81,45 @@ func Update(ctx context.Context, db *sqlx.DB, ts Timeseries, now time.Time) (*Ti
 	return &ts, nil
 }
 
+// FoldInto folds the event into the timeseries in place. The numbers added go onto the stored values, and
+// the count too when it adds, so the events folded at the same time do not overwrite each other.
+func FoldInto(ctx context.Context, db *sqlx.DB, ts Timeseries, fo Fold, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.timeseries.FoldInto")
+	defer span.End()
+
+	setBytes, err := json.Marshal(fo.Set)
+	if err != nil {
+		return errors.Wrap(err, "encode fields to bytes")
+	}
+	args := []interface{}{ts.AccountID, ts.EntityID, ts.ID, now.UTC(), fo.Count, string(setBytes)}
+	fields := "fieldsb || $6::jsonb"
+	keys := make([]string, 0, len(fo.Add))
+	for k := range fo.Add {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, k, fo.Add[k])
+		key, n := len(args)-1, len(args)
+		// the values which are not the numbers are taken as zero, fieldsb is the stored one in the expression.
+		fields = fmt.Sprintf(`jsonb_set(%[1]s, ARRAY[$%[2]d::text], to_jsonb(CASE WHEN fieldsb->>$%[2]d::text ~ '^-?[0-9]+(\.[0-9]+)?$' THEN (fieldsb->>$%[2]d::text)::numeric ELSE 0 END + $%[3]d))`, fields, key, n)
+	}
+	count := "$5"
+	if fo.AddCount {
+		count = "count + $5"
+	}
+
+	q := fmt.Sprintf(`UPDATE timeseries SET
+		"fieldsb" = %s,
+		"count" = %s,
+		"end_time" = GREATEST(end_time, $4)
+		WHERE account_id = $1 AND entity_id = $2 AND timeseries_id = $3`, fields, count)
+	if _, err := db.ExecContext(ctx, q, args...); err != nil {
+		return errors.Wrapf(err, "folding into timeseries %q", ts.ID)
+	}
+	return nil
+}
+
 func List(ctx context.Context, accountID, entityID string, startTime, endTime time.Time, db *sqlx.DB) ([]Timeseries, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.timeseries.List")
 	defer span.End()
@@ -108,6 +149,20 @@ func Count(ctx context.Context, accountID, entityID string, startTime, endTime t
 	return count, nil
 }
 
+// Total sums the events of the identifier ending after the time. The rolled up row counts its count, while the
+// row without one still counts as the event.
+func Total(ctx context.Context, accountID, entityID, identifier string, since time.Time, db *sqlx.DB) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.timeseries.Total")
+	defer span.End()
+
+	var total int
+	const q = `SELECT COALESCE(SUM(GREATEST(count, 1)), 0) FROM timeseries where account_id = $1 AND entity_id = $2 AND identifier = $3 AND end_time > $4`
+	if err := db.GetContext(ctx, &total, q, accountID, entityID, identifier, since); err != nil {
+		return total, errors.Wrapf(err, "selecting total")
+	}
+	return total, nil
+}
+
 func RetriveLatest(ctx context.Context, accountID, entityID string, identifier *string, db *sqlx.DB) ([]Timeseries, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.timeseries.List")
 	defer span.End()
@@ -162,42 +217,3 @@ func (t Timeseries) Fields() map[string]interface{} {
 	}
 	return fields
 }
-
-func Duration(duration string) (time.Time, time.Time, time.Time) {
-	loc, _ := time.LoadLocation("UTC")
-	return DurationWithZone(duration, loc)
-}
-
-func DurationWithZone(duration string, zone *time.Location) (time.Time, time.Time, time.Time) {
-	t := time.Now().In(zone)
-	endTime := t
-	stTime := endTime
-	switch duration {
-	case "last_hr":
-		stTime = endTime.Add(-time.Hour * 1)
-	case "last_6hr":
-		stTime = endTime.Add(-time.Hour * 6)
-	case "last_24hrs":
-		stTime = endTime.Add(-time.Hour * 24)
-	case "today":
-		stTime = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
-	case "yesterday":
-		stTime = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
-		stTime = stTime.Add(-time.Hour * 24)
-	case "this_week":
-		stTime = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
-		stTime = endTime.AddDate(0, 0, int(t.Weekday()))
-	case "last_week":
-		stTime = endTime.AddDate(0, 0, -7)
-	case "this_month":
-		stTime = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
-		stTime = endTime.AddDate(0, 0, -t.Day())
-	case "last_month":
-		stTime = endTime.AddDate(0, 0, -30)
-	case "last_6month":
-		stTime = endTime.AddDate(0, 0, -1)
-	}
-	difference := stTime.Sub(endTime)
-	lastStart := stTime.Add(difference)
-	return stTime.UTC(), endTime.UTC(), lastStart.UTC()
-}
@@ -0,0 +1,234 @@
+package token
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"gitlab.com/vjsideprojects/relay/internal/platform/auth"
+	"gitlab.com/vjsideprojects/relay/internal/user"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrInvalidKey is used when the API key does not exist, is revoked or expired.
+	ErrInvalidKey = errors.New("API key is not valid")
+	// ErrInvalidScope is used when the scope of the new API key is malformed or its qualifier is not an entity id.
+	ErrInvalidScope = errors.New("API key scope is not valid")
+)
+
+// prefixLen is the length of the key kept in plain text so the admin can tell the keys apart.
+const prefixLen = len(auth.APIKeyPrefix) + 6
+
+// lastUsedGap avoids writing the last used time on every request made with the key.
+const lastUsedGap = time.Minute
+
+// CreateAPIKey creates the API key for the account and returns the key along with the stored token.
+// The key is only available at this point, the token holds its hash. The qualifier of the scopes must be
+// the entity id, the names are resolved by the caller.
+func CreateAPIKey(ctx context.Context, db *sqlx.DB, accountID, userID string, nk NewAPIKey, now time.Time) (string, Token, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.token.CreateAPIKey")
+	defer span.End()
+
+	for _, s := range nk.Scopes {
+		if !auth.ValidScope(s) {
+			return "", Token{}, errors.Wrapf(ErrInvalidScope, "scope %q", s)
+		}
+		// the requests are matched with the entity id in the path, a name would never match
+		if parts := strings.Split(s, ":"); len(parts) == 3 {
+			if _, err := uuid.Parse(parts[2]); err != nil {
+				return "", Token{}, errors.Wrapf(ErrInvalidScope, "qualifier of scope %q is not an entity id", s)
+			}
+		}
+	}
+
+	expiry := now.UTC().Add(time.Hour * 24 * 7 * time.Duration(1000)) //roughly 20 years
+	if nk.ExpiresInDays > 0 {
+		expiry = now.UTC().AddDate(0, 0, nk.ExpiresInDays)
+	}
+
+	return createAPIKey(ctx, db, accountID, userID, nk.Name, nk.Scopes, expiry, now)
+}
+
+func createAPIKey(ctx context.Context, db *sqlx.DB, accountID, userID, name string, scopes []string, expiry, now time.Time) (string, Token, error) {
+	random, err := auth.GenerateRandomToken(32)
+	if err != nil {
+		return "", Token{}, errors.Wrap(err, "generating api key")
+	}
+	key := auth.APIKeyPrefix + strings.TrimRight(random, "=")
+
+	t := Token{
+		Token:     Hash(key),
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Name:      name,
+		Prefix:    key[:prefixLen],
+		Type:      TypeAPIKey,
+		State:     StateActive,
+		Scope:     scopes,
+		CreatedBy: &userID,
+		IssuedAt:  now.UTC(),
+		Expiry:    expiry,
+		CreatedAt: now.UTC(),
+	}
+
+	const q = `INSERT INTO tokens
+		(token, token_id, account_id, name, prefix, type, state, scope, created_by, issued_at, expiry, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+	_, err = db.ExecContext(
+		ctx, q,
+		t.Token, t.ID, t.AccountID, t.Name, t.Prefix,
+		t.Type, t.State, t.Scope, t.CreatedBy,
+		t.IssuedAt, t.Expiry, t.CreatedAt,
+	)
+	if err != nil {
+		return "", Token{}, errors.Wrap(err, "inserting api key")
+	}
+
+	return key, t, nil
+}
+
+// ListAPIKeys returns the API keys of the account including the rotated and revoked ones.
+func ListAPIKeys(ctx context.Context, db *sqlx.DB, accountID string) ([]Token, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.token.ListAPIKeys")
+	defer span.End()
+
+	tokens := []Token{}
+	const q = `SELECT * FROM tokens WHERE account_id = $1 AND type = $2 ORDER BY created_at DESC`
+	if err := db.SelectContext(ctx, &tokens, q, accountID, TypeAPIKey); err != nil {
+		return nil, errors.Wrap(err, "selecting api keys")
+	}
+
+	return tokens, nil
+}
+
+// RetrieveAPIKey gets the API key of the account by its id.
+func RetrieveAPIKey(ctx context.Context, db *sqlx.DB, accountID, keyID string) (Token, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.token.RetrieveAPIKey")
+	defer span.End()
+
+	var t Token
+	const q = `SELECT * FROM tokens WHERE account_id = $1 AND token_id = $2 AND type = $3`
+	if err := db.GetContext(ctx, &t, q, accountID, keyID, TypeAPIKey); err != nil {
+		if err == sql.ErrNoRows {
+			return Token{}, ErrTokenNotFound
+		}
+		return Token{}, errors.Wrapf(err, "selecting api key %q", keyID)
+	}
+
+	return t, nil
+}
+
+// RotateAPIKey creates a new key with the name and scopes of the existing one. The existing key keeps
+// working for the overlap period so the clients can move to the new key without downtime.
+func RotateAPIKey(ctx context.Context, db *sqlx.DB, accountID, keyID, userID string, overlap time.Duration, now time.Time) (string, Token, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.token.RotateAPIKey")
+	defer span.End()
+
+	old, err := RetrieveAPIKey(ctx, db, accountID, keyID)
+	if err != nil {
+		return "", Token{}, err
+	}
+	if old.State != StateActive {
+		return "", Token{}, ErrInvalidKey
+	}
+
+	key, t, err := createAPIKey(ctx, db, accountID, userID, old.Name, old.Scope, old.Expiry, now)
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	expiry := now.UTC().Add(overlap)
+	if old.Expiry.Before(expiry) {
+		expiry = old.Expiry
+	}
+	const q = `UPDATE tokens SET state = $3, expiry = $4 WHERE account_id = $1 AND token_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, keyID, StateRotated, expiry); err != nil {
+		return "", Token{}, errors.Wrapf(err, "rotating api key %q", keyID)
+	}
+
+	return key, t, nil
+}
+
+// RevokeAPIKey stops the key from working immediately.
+func RevokeAPIKey(ctx context.Context, db *sqlx.DB, accountID, keyID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.token.RevokeAPIKey")
+	defer span.End()
+
+	const q = `UPDATE tokens SET state = $4 WHERE account_id = $1 AND token_id = $2 AND type = $3`
+	res, err := db.ExecContext(ctx, q, accountID, keyID, TypeAPIKey, StateRevoked)
+	if err != nil {
+		return errors.Wrapf(err, "revoking api key %q", keyID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+// Authenticate validates the API key and records its usage.
+func Authenticate(ctx context.Context, db *sqlx.DB, key string, now time.Time) (Token, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.token.Authenticate")
+	defer span.End()
+
+	var t Token
+	const q = `SELECT * FROM tokens WHERE token = $1 AND type = $2`
+	if err := db.GetContext(ctx, &t, q, Hash(key), TypeAPIKey); err != nil {
+		if err == sql.ErrNoRows {
+			return Token{}, ErrInvalidKey
+		}
+		return Token{}, errors.Wrap(err, "selecting api key")
+	}
+	if t.State == StateRevoked || !now.UTC().Before(t.Expiry) {
+		return Token{}, ErrInvalidKey
+	}
+
+	const uq = `UPDATE tokens SET last_used_at = $2 WHERE token = $1 AND (last_used_at IS NULL OR last_used_at < $3)`
+	if _, err := db.ExecContext(ctx, uq, t.Token, now.UTC(), now.UTC().Add(-lastUsedGap)); err != nil {
+		return Token{}, errors.Wrap(err, "updating api key usage")
+	}
+
+	return t, nil
+}
+
+// NewAPIKeyLookupFunc resolves the API keys for the authenticator. The key acts on behalf of the user
+// who created it with the roles the user has now, limited by the scopes of the key.
+func NewAPIKeyLookupFunc(db *sqlx.DB) auth.APIKeyLookupFunc {
+	f := func(ctx context.Context, key string) (auth.APIKey, error) {
+		now := time.Now()
+		t, err := Authenticate(ctx, db, key, now)
+		if err != nil {
+			return auth.APIKey{}, err
+		}
+		if t.CreatedBy == nil {
+			return auth.APIKey{}, ErrInvalidKey
+		}
+
+		u, err := user.RetrieveUser(ctx, db, t.AccountID, *t.CreatedBy)
+		if err != nil {
+			return auth.APIKey{}, ErrInvalidKey
+		}
+
+		return auth.APIKey{
+			ID:        t.ID,
+			AccountID: t.AccountID,
+			Scopes:    t.Scope,
+			Claims:    auth.NewClaims(u.ID, u.Roles, now, time.Until(t.Expiry)),
+		}, nil
+	}
+
+	return f
+}
+
+// Hash returns the hash of the API key which is stored in place of the key.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -6,13 +6,40 @@ import (
 	"github.com/lib/pq"
 )
 
+// Types of the token
+const (
+	TypeSystem = 0
+	TypeAPIKey = 1
+)
+
+// States of the token. A rotated token keeps working until its expiry (the overlap period).
+const (
+	StateActive  = 0
+	StateRotated = 1
+	StateRevoked = 2
+)
+
+// Token is the system token of the account or the API key created by the admin.
+// For the API keys the token holds the sha256 hash of the key, the key itself is never stored.
 type Token struct {
-	Token     string         `db:"token" json:"token"`
-	AccountID string         `db:"account_id" json:"account_id"`
-	Type      int            `db:"type" json:"type"`
-	State     int            `db:"state" json:"state"`
-	Scope     pq.StringArray `db:"scope" json:"scope"`
-	IssuedAt  time.Time      `db:"issued_at" json:"issued_at"`
-	Expiry    time.Time      `db:"expiry" json:"expiry"`
-	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	Token      string         `db:"token" json:"token"`
+	ID         string         `db:"token_id" json:"id"`
+	AccountID  string         `db:"account_id" json:"account_id"`
+	Name       string         `db:"name" json:"name"`
+	Prefix     string         `db:"prefix" json:"prefix"`
+	Type       int            `db:"type" json:"type"`
+	State      int            `db:"state" json:"state"`
+	Scope      pq.StringArray `db:"scope" json:"scope"`
+	CreatedBy  *string        `db:"created_by" json:"created_by"`
+	IssuedAt   time.Time      `db:"issued_at" json:"issued_at"`
+	Expiry     time.Time      `db:"expiry" json:"expiry"`
+	LastUsedAt *time.Time     `db:"last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+}
+
+// NewAPIKey has the information needed to create the API key.
+type NewAPIKey struct {
+	Name          string   `json:"name" validate:"required"`
+	Scopes        []string `json:"scopes" validate:"required"`
+	ExpiresInDays int      `json:"expires_in_days"`
 }
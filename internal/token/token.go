@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
@@ -20,9 +21,10 @@ func Create(ctx context.Context, db *sqlx.DB, token, accountID string, now time.
 	defer span.End()
 	t := Token{
 		Token:     token,
+		ID:        uuid.New().String(),
 		AccountID: accountID,
-		Type:      0,
-		State:     0,
+		Type:      TypeSystem,
+		State:     StateActive,
 		Scope:     []string{},
 		IssuedAt:  now.UTC(),
 		Expiry:    now.UTC().Add(time.Hour * 24 * 7 * time.Duration(1000)), //roughly 20 years
@@ -30,11 +32,11 @@ func Create(ctx context.Context, db *sqlx.DB, token, accountID string, now time.
 	}
 
 	const q = `INSERT INTO tokens
-		(token, account_id, type, state, scope, issued_at, expiry, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+		(token, token_id, account_id, type, state, scope, issued_at, expiry, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 	_, err := db.ExecContext(
 		ctx, q,
-		t.Token, t.AccountID, t.Type,
+		t.Token, t.ID, t.AccountID, t.Type,
 		t.State, t.Scope,
 		t.IssuedAt, t.Expiry, t.CreatedAt,
 	)
@@ -58,13 +60,14 @@ func Delete(ctx context.Context, db *sqlx.DB, token string) error {
 	return nil
 }
 
+// Retrieve gets the system token of the account. The API keys are retrieved with RetrieveAPIKey.
 func Retrieve(ctx context.Context, db *sqlx.DB, accountID string) (*Token, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.token.Retrieve")
 	defer span.End()
 
 	var t Token
-	const q = `SELECT * FROM tokens WHERE account_id = $1`
-	if err := db.GetContext(ctx, &t, q, accountID); err != nil {
+	const q = `SELECT * FROM tokens WHERE account_id = $1 AND type = $2`
+	if err := db.GetContext(ctx, &t, q, accountID, TypeSystem); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrTokenNotFound
 		}
@@ -163,6 +163,49 @@ func BulkRetrieveUsers(ctx context.Context, accountID string, ids []string, db *
 	return users, nil
 }
 
+// AccountUsers retrieves all the users of the account.
+func AccountUsers(ctx context.Context, accountID string, db *sqlx.DB) ([]User, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.AccountUsers")
+	defer span.End()
+
+	users := []User{}
+	const q = `SELECT * FROM users where account_id = $1`
+
+	if err := db.SelectContext(ctx, &users, q, accountID); err != nil {
+		return users, errors.Wrap(err, "selecting users of the account")
+	}
+
+	return users, nil
+}
+
+// Copy inserts the user into another account with the new id. The password hash and the roles are kept as it is.
+func Copy(ctx context.Context, db *sqlx.DB, u User, accountID, userID, memberID string, now time.Time) (User, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.Copy")
+	defer span.End()
+
+	u.ID = userID
+	u.AccountID = accountID
+	u.MemberID = memberID
+	u.IssuedAt = now.UTC()
+	u.CreatedAt = now.UTC()
+	u.UpdatedAt = now.UTC().Unix()
+
+	const q = `INSERT INTO users
+		(user_id, account_id, member_id, name, email, avatar, phone, provider, verified, issued_at, password_hash, roles, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	_, err := db.ExecContext(
+		ctx, q,
+		u.ID, u.AccountID, u.MemberID, u.Name, u.Email, u.Avatar, u.Phone, u.Provider, u.Verified, u.IssuedAt,
+		u.PasswordHash, u.Roles,
+		u.CreatedAt, u.UpdatedAt,
+	)
+	if err != nil {
+		return User{}, errors.Wrap(err, "copying user")
+	}
+
+	return u, nil
+}
+
 // Create inserts a new user into the database.
 func Create(ctx context.Context, db *sqlx.DB, n NewUser, now time.Time) (User, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.user.Create")
@@ -266,6 +309,19 @@ func Update(ctx context.Context, claims auth.Claims, db *sqlx.DB, id, accountID
 	return nil
 }
 
+// UpdateRoles replaces the roles of the user. It keeps the roles of the members signing in
+// through the identity provider in sync with their groups.
+func UpdateRoles(ctx context.Context, db *sqlx.DB, userID string, roles []string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UpdateRoles")
+	defer span.End()
+
+	const q = `UPDATE users SET "roles" = $2, "updated_at" = $3 WHERE user_id = $1`
+	if _, err := db.ExecContext(ctx, q, userID, pq.StringArray(roles), now.Unix()); err != nil {
+		return errors.Wrap(err, "updating user roles")
+	}
+	return nil
+}
+
 func UpdatePassword(ctx context.Context, db *sqlx.DB, userID string, password string, now time.Time) error {
 	//TODO exploitation possible. Anyone without claims can update the password it seems
 	ctx, span := trace.StartSpan(ctx, "internal.user.UpdatePassword")
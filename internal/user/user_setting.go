@@ -18,6 +18,16 @@ const (
 	NSCreated           = "created"
 	NSAssigned          = "assigned"
 	NSEmailSubscription = "email_subscription"
+	NSQuietStart        = "quiet_start" // the notifications wait from this time, such as 22:00
+	NSQuietEnd          = "quiet_end"   // till this time, such as 07:00
+	NSTimezone          = "timezone"    // the IANA zone the quiet hours and the digests follow
+	NSDigestAt          = "digest_at"   // the time the daily digest is sent, 08:00 when not set
+)
+
+// Meta keys of the user setting the charts and the dashboards follow over the calendar of the account.
+const (
+	MetaTimezone  = "timezone"   // the IANA zone of the user
+	MetaWeekStart = "week_start" // the weekday the weeks start, 0 is sunday
 )
 
 func RetrieveUserSetting(ctx context.Context, db *sqlx.DB, accountID, userID string) (*NotificationUserSetting, error) {
@@ -0,0 +1,57 @@
+package view
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Scopes of the view. The private view is seen only by its owner, the team view by the members
+// of the team and the account view by everyone in the account.
+const (
+	ScopePrivate = "private"
+	ScopeTeam    = "team"
+	ScopeAccount = "account"
+)
+
+// Styles the items of the view are laid out with.
+const (
+	StyleList     = "list"
+	StylePipe     = "pipe"
+	StyleCalendar = "calendar"
+	StyleTimeline = "timeline"
+)
+
+// View is the saved view of the entity. It keeps the filter, the visible columns in their order,
+// the sorting, the grouping and the layout style the items are listed with.
+type View struct {
+	ID         string         `db:"view_id" json:"id"`
+	AccountID  string         `db:"account_id" json:"account_id"`
+	TeamID     string         `db:"team_id" json:"team_id"`
+	EntityID   string         `db:"entity_id" json:"entity_id"`
+	UserID     string         `db:"user_id" json:"user_id"` // the owner
+	Name       string         `db:"name" json:"name"`
+	Expression string         `db:"expression" json:"expression"`
+	Columns    pq.StringArray `db:"columns" json:"columns"`
+	SortBy     string         `db:"sort_by" json:"sort_by"`
+	Direction  string         `db:"direction" json:"direction"`
+	GroupBy    string         `db:"group_by" json:"group_by"`
+	Style      string         `db:"style" json:"style"`
+	FlowID     *string        `db:"flow_id" json:"flow_id"` // the pipeline of the pipe style
+	Scope      string         `db:"scope" json:"scope"`
+	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt  int64          `db:"updated_at" json:"updated_at"`
+}
+
+// NewView has the information needed to save the view.
+type NewView struct {
+	Name       string   `json:"name" validate:"required"`
+	Expression string   `json:"expression"`
+	Columns    []string `json:"columns"`
+	SortBy     string   `json:"sort_by"`
+	Direction  string   `json:"direction" validate:"omitempty,oneof=asc desc"`
+	GroupBy    string   `json:"group_by"`
+	Style      string   `json:"style" validate:"omitempty,oneof=list pipe calendar timeline"`
+	FlowID     *string  `json:"flow_id"`
+	Scope      string   `json:"scope" validate:"omitempty,oneof=private team account"`
+}
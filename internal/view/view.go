@@ -0,0 +1,172 @@
+package view
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrNotFound is used when a specific view is requested but does not exist.
+	ErrNotFound = errors.New("View not found")
+
+	// ErrInvalidID occurs when an ID is not in a valid form.
+	ErrInvalidID = errors.New("View ID is not in its proper form")
+
+	// ErrInvalidView is used when the pipe view is saved without its pipeline.
+	ErrInvalidView = errors.New("View is not valid")
+)
+
+// Create saves the view of the entity owned by the user.
+func Create(ctx context.Context, db *sqlx.DB, accountID, teamID, entityID, userID string, nv NewView, now time.Time) (View, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.view.Create")
+	defer span.End()
+
+	v := View{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		TeamID:    teamID,
+		EntityID:  entityID,
+		UserID:    userID,
+		CreatedAt: now.UTC(),
+	}
+	if err := v.apply(nv, now); err != nil {
+		return View{}, err
+	}
+
+	const q = `INSERT INTO views
+		(view_id, account_id, team_id, entity_id, user_id, name, expression, columns, sort_by, direction, group_by, style, flow_id, scope, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+	_, err := db.ExecContext(
+		ctx, q,
+		v.ID, v.AccountID, v.TeamID, v.EntityID, v.UserID, v.Name, v.Expression, v.Columns,
+		v.SortBy, v.Direction, v.GroupBy, v.Style, v.FlowID, v.Scope,
+		v.CreatedAt, v.UpdatedAt,
+	)
+	if err != nil {
+		return View{}, errors.Wrap(err, "inserting view")
+	}
+
+	return v, nil
+}
+
+// Update replaces the settings of the view. The owner and the team stay as they are.
+func Update(ctx context.Context, db *sqlx.DB, v View, nv NewView, now time.Time) (View, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.view.Update")
+	defer span.End()
+
+	if err := v.apply(nv, now); err != nil {
+		return View{}, err
+	}
+
+	const q = `UPDATE views SET
+		"name" = $3, "expression" = $4, "columns" = $5, "sort_by" = $6, "direction" = $7,
+		"group_by" = $8, "style" = $9, "flow_id" = $10, "scope" = $11, "updated_at" = $12
+		WHERE account_id = $1 AND view_id = $2`
+	_, err := db.ExecContext(ctx, q, v.AccountID, v.ID,
+		v.Name, v.Expression, v.Columns, v.SortBy, v.Direction,
+		v.GroupBy, v.Style, v.FlowID, v.Scope, v.UpdatedAt,
+	)
+	if err != nil {
+		return View{}, errors.Wrapf(err, "updating view %q", v.ID)
+	}
+
+	return v, nil
+}
+
+// List returns the views of the entity the user sees in the team: the own views, the views shared
+// with the team and the views shared with the account.
+func List(ctx context.Context, db *sqlx.DB, accountID, teamID, entityID, userID string) ([]View, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.view.List")
+	defer span.End()
+
+	views := []View{}
+	const q = `SELECT * FROM views WHERE account_id = $1 AND entity_id = $2 AND
+		(user_id = $3 OR scope = $4 OR (scope = $5 AND team_id = $6)) ORDER BY created_at`
+	if err := db.SelectContext(ctx, &views, q, accountID, entityID, userID, ScopeAccount, ScopeTeam, teamID); err != nil {
+		return nil, errors.Wrap(err, "selecting views")
+	}
+
+	return views, nil
+}
+
+// Retrieve gets the view. The caller checks whether the user sees it with Visible.
+func Retrieve(ctx context.Context, db *sqlx.DB, accountID, viewID string) (View, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.view.Retrieve")
+	defer span.End()
+
+	if _, err := uuid.Parse(viewID); err != nil {
+		return View{}, ErrInvalidID
+	}
+
+	var v View
+	const q = `SELECT * FROM views WHERE account_id = $1 AND view_id = $2`
+	if err := db.GetContext(ctx, &v, q, accountID, viewID); err != nil {
+		if err == sql.ErrNoRows {
+			return View{}, ErrNotFound
+		}
+		return View{}, errors.Wrapf(err, "selecting view %q", viewID)
+	}
+
+	return v, nil
+}
+
+// Delete removes the view.
+func Delete(ctx context.Context, db *sqlx.DB, accountID, viewID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.view.Delete")
+	defer span.End()
+
+	const q = `DELETE FROM views WHERE account_id = $1 AND view_id = $2`
+	if _, err := db.ExecContext(ctx, q, accountID, viewID); err != nil {
+		return errors.Wrapf(err, "deleting view %q", viewID)
+	}
+
+	return nil
+}
+
+// Visible tells whether the user in the team sees the view.
+func (v View) Visible(userID, teamID string) bool {
+	switch v.Scope {
+	case ScopeAccount:
+		return true
+	case ScopeTeam:
+		return v.TeamID == teamID || v.UserID == userID
+	}
+	return v.UserID == userID
+}
+
+// apply copies the settings to the view with the defaults for the ones not given.
+func (v *View) apply(nv NewView, now time.Time) error {
+	if nv.Style == "" {
+		nv.Style = StyleList
+	}
+	if nv.Scope == "" {
+		nv.Scope = ScopePrivate
+	}
+	if nv.Style == StylePipe && (nv.FlowID == nil || *nv.FlowID == "") {
+		return errors.Wrap(ErrInvalidView, "the pipe view needs the pipeline")
+	}
+	if nv.Style != StylePipe {
+		nv.FlowID = nil
+	}
+	if nv.Columns == nil {
+		nv.Columns = []string{}
+	}
+
+	v.Name = nv.Name
+	v.Expression = nv.Expression
+	v.Columns = nv.Columns
+	v.SortBy = nv.SortBy
+	v.Direction = nv.Direction
+	v.GroupBy = nv.GroupBy
+	v.Style = nv.Style
+	v.FlowID = nv.FlowID
+	v.Scope = nv.Scope
+	v.UpdatedAt = now.UTC().Unix()
+	return nil
+}
@@ -0,0 +1,31 @@
+package view_test
+
+import (
+	"testing"
+
+	"gitlab.com/vjsideprojects/relay/internal/tests"
+	"gitlab.com/vjsideprojects/relay/internal/view"
+)
+
+func TestVisible(t *testing.T) {
+	t.Log("Given the need to share the saved views")
+	{
+		cases := []struct {
+			scope, userID, teamID string
+			want                  bool
+		}{
+			{view.ScopePrivate, "owner", "t-1", true},
+			{view.ScopePrivate, "other", "t-1", false},
+			{view.ScopeTeam, "other", "t-1", true},
+			{view.ScopeTeam, "other", "t-2", false},
+			{view.ScopeAccount, "other", "t-2", true},
+		}
+		for _, c := range cases {
+			v := view.View{UserID: "owner", TeamID: "t-1", Scope: c.scope}
+			if got := v.Visible(c.userID, c.teamID); got != c.want {
+				t.Fatalf("\t%s the %s view should be visible to %s in %s: %v. got %v", tests.Failed, c.scope, c.userID, c.teamID, c.want, got)
+			}
+			t.Logf("\t%s the %s view should be visible to %s in %s: %v", tests.Success, c.scope, c.userID, c.teamID, c.want)
+		}
+	}
+}